@@ -18,9 +18,11 @@ import (
 	"go.uber.org/zap/zapcore"
 
 	"github.com/distributedio/titan"
+	"github.com/distributedio/titan/command"
 	"github.com/distributedio/titan/conf"
 	"github.com/distributedio/titan/context"
 	"github.com/distributedio/titan/db"
+	"github.com/distributedio/titan/memcached"
 	"github.com/distributedio/titan/metrics"
 	"github.com/distributedio/titan/server"
 )
@@ -61,62 +63,101 @@ func main() {
 		os.Exit(1)
 	}
 
+	if err := command.RenameCommands(config.Server.RenameCommand); err != nil {
+		zap.L().Fatal("apply rename-command failed", zap.Error(err))
+		os.Exit(1)
+	}
+
+	if err := command.AliasCommands(config.Server.AliasCommand); err != nil {
+		zap.L().Fatal("apply alias-command failed", zap.Error(err))
+		os.Exit(1)
+	}
+
+	if err := command.SetLatencySLOs(config.Server.LatencySLO); err != nil {
+		zap.L().Fatal("apply latency-slo failed", zap.Error(err))
+		os.Exit(1)
+	}
+
+	if err := command.SetNamespaceDBRanges(config.Server.NamespaceDBRange); err != nil {
+		zap.L().Fatal("apply namespace-db-range failed", zap.Error(err))
+		os.Exit(1)
+	}
+
 	store, err := db.Open(&config.Tikv)
 	if err != nil {
 		zap.L().Fatal("open db failed", zap.Error(err))
 		os.Exit(1)
 	}
 
+	node, err := db.NewNodeStatus(config.Tikv.RateLimit.InterfaceName, context.ReleaseVersion, "server")
+	if err != nil {
+		zap.L().Error("build node status failed, node registry disabled", zap.Error(err))
+	} else {
+		go db.StartNodeRegistry(store, &config.Tikv.Registry, node)
+	}
+
 	svr := metrics.NewServer(&config.Status)
 	limitersMgr, err := db.NewLimitersMgr(store, &config.Tikv.RateLimit)
 	if err != nil {
 		zap.L().Fatal("create limitersMgr failed", zap.Error(err))
 		os.Exit(1)
 	}
-	serv := titan.New(&context.ServerContext{
-		RequirePass:       config.Server.Auth,
-		Store:             store,
-		ListZipThreshold:  config.Server.ListZipThreshold,
-		LimitersMgr:       limitersMgr,
-		LimitConnection:   config.Server.LimitConnection,
-		MaxConnection:     config.Server.MaxConnection,
-		MaxConnectionWait: config.Server.MaxConnectionWait,
-	})
-
-	var servOpts, statusOpts []continuous.ServerOption
-
-	// titan server tls options
-	if config.Server.SSLCertFile != "" && config.Server.SSLKeyFile != "" {
-		tlsConfig, err := server.TLSConfig(config.Server.SSLCertFile, config.Server.SSLKeyFile)
-		if err != nil {
-			zap.L().Fatal("failed to load server TLS config", zap.Error(err))
-		}
-		servOpts = append(servOpts, continuous.TLSConfig(tlsConfig))
+	var authProvider context.AuthProvider
+	if config.Server.AuthProviderURL != "" {
+		authProvider = command.NewExternalAuth(config.Server.AuthProviderURL,
+			config.Server.AuthTimeout, config.Server.AuthCacheTTL)
+	} else if config.Server.Auth != "" {
+		authProvider = command.NewHMACAuth(config.Server.Auth)
 	}
 
-	// status server tls options
-	if config.Status.SSLCertFile != "" && config.Status.SSLKeyFile != "" {
-		tlsConfig, err := server.TLSConfig(config.Status.SSLCertFile, config.Status.SSLKeyFile)
-		if err != nil {
-			zap.L().Fatal("failed to load status server TLS config", zap.Error(err))
-		}
-		statusOpts = append(statusOpts, continuous.TLSConfig(tlsConfig))
-	}
+	serv := titan.New(&context.ServerContext{
+		RequirePass:        config.Server.Auth,
+		AuthProvider:       authProvider,
+		Store:              store,
+		ListZipThreshold:   config.Server.ListZipThreshold,
+		StrictClusterSlots: config.Server.StrictClusterSlots,
+		MaxPipelineBurst:   config.Server.MaxPipelineBurst,
+		MaxReplyElements:   config.Server.MaxReplyElements,
+		MaxReplyBytes:      config.Server.MaxReplyBytes,
+		MaxBatchArgs:       config.Server.MaxBatchArgs,
+		MaxRequestBytes:    config.Server.MaxRequestBytes,
+		LimitersMgr:        limitersMgr,
+		ReadOnlyMgr:        db.NewReadOnlyMgr(store, &config.Tikv.ReadOnly),
+		NamespaceReg:       db.NewNamespaceRegistry(store, nil),
+		TxnPool:            db.NewTxnPool(&config.Server.TxnPool),
+		AcceptLimiter:      server.NewAcceptLimiter(&config.Server.AcceptLimit),
+		SnapshotReuse:      config.Server.SnapshotReuse,
+		ExplicitTxn:        config.Server.ExplicitTxn,
+		EventLoop:          config.Server.EventLoop,
+		Journal:            server.NewJournal(&config.Server.Journal),
+		LimitConnection:    config.Server.LimitConnection,
+		MaxConnection:      config.Server.MaxConnection,
+		MaxConnectionWait:  config.Server.MaxConnectionWait,
+	})
 
 	writer, err := Writer(config.Logger.Path, config.Logger.TimeRotate, config.Logger.Compress)
 	if err != nil {
 		zap.L().Fatal("create writer for continuous failed", zap.Error(err))
 	}
-	cont := continuous.New(continuous.LoggerOutput(writer), continuous.PidFile(config.PIDFileName))
-	if err := cont.AddServer(serv, &continuous.ListenOn{Network: "tcp", Address: config.Server.Listen}, servOpts...); err != nil {
+	mgr := server.NewManager(continuous.LoggerOutput(writer), continuous.PidFile(config.PIDFileName))
+	if err := mgr.Register("titan", serv, config.Server.Listen,
+		config.Server.SSLCertFile, config.Server.SSLKeyFile); err != nil {
 		zap.L().Fatal("add titan server failed:", zap.Error(err))
 	}
 
-	if err := cont.AddServer(svr, &continuous.ListenOn{Network: "tcp", Address: config.Status.Listen}, statusOpts...); err != nil {
-		zap.L().Fatal("add statues server failed:", zap.Error(err))
+	if err := mgr.Register("status", svr, config.Status.Listen,
+		config.Status.SSLCertFile, config.Status.SSLKeyFile); err != nil {
+		zap.L().Fatal("add status server failed:", zap.Error(err))
+	}
+
+	if config.Memcached.Listen != "" {
+		mc := memcached.NewServer(store, &config.Memcached)
+		if err := mgr.Register("memcached", mc, config.Memcached.Listen, "", ""); err != nil {
+			zap.L().Fatal("add memcached server failed:", zap.Error(err))
+		}
 	}
 
-	if err := cont.Serve(); err != nil {
+	if err := mgr.Serve(); err != nil {
 		zap.L().Fatal("run server failed:", zap.Error(err))
 	}
 }
@@ -203,7 +244,7 @@ func ConfigureLogrus(path, level, pattern string, compress bool) error {
 	return nil
 }
 
-//Writer generate the rollingWriter
+// Writer generate the rollingWriter
 func Writer(path, pattern string, compress bool) (io.Writer, error) {
 	var opts []rolling.Option
 	opts = append(opts, rolling.WithRollingTimePattern(pattern))
@@ -5,6 +5,7 @@ import (
 	"io"
 	"io/ioutil"
 	"net"
+	"runtime"
 	"strings"
 	"time"
 
@@ -20,8 +21,11 @@ type client struct {
 	conn   net.Conn
 	exec   *command.Executor
 	r      *bufio.Reader
+	fd     int32 //socket descriptor, only set by the event loop frontend
 
-	remoteClosed bool //is the connection closed by remote peer?
+	remoteClosed    bool //is the connection closed by remote peer?
+	unknownCmdTimes int
+	pipelined       int
 }
 
 func newClient(cliCtx *context.ClientContext, s *Server, exec *command.Executor) *client {
@@ -36,7 +40,15 @@ func newClient(cliCtx *context.ClientContext, s *Server, exec *command.Executor)
 // Write to conn and log error if needed
 func (c *client) Write(p []byte) (int, error) {
 	zap.L().Debug("write to client", zap.Int64("clientid", c.cliCtx.ID), zap.String("msg", string(p)))
-	n, err := c.conn.Write(p)
+	var n int
+	var err error
+	if t := c.cliCtx.CompressionThreshold; t > 0 && len(p) >= t {
+		if err = writeLZ4Frame(c.conn, p); err == nil {
+			n = len(p)
+		}
+	} else {
+		n, err = c.conn.Write(p)
+	}
 	if err != nil {
 		if err == io.EOF {
 			zap.L().Info("connection was half-closed by remote peer", zap.String("addr", c.cliCtx.RemoteAddr),
@@ -60,13 +72,18 @@ func (c *client) Write(p []byte) (int, error) {
 	return n, err
 }
 
-func (c *client) serve(conn net.Conn) error {
+// attach binds the connection to the client, serve does it on its own,
+// the event loop frontend calls it once before the first park
+func (c *client) attach(conn net.Conn) {
 	c.conn = conn
 	c.r = bufio.NewReader(conn)
+}
+
+func (c *client) serve(conn net.Conn) error {
+	c.attach(conn)
 
 	var cmd []string
 	var err error
-	unknownCmdTimes := int(0)
 	for {
 		select {
 		case <-c.cliCtx.Done:
@@ -91,63 +108,136 @@ func (c *client) serve(conn net.Conn) error {
 			}
 		}
 
-		if c.server.servCtx.Pause > 0 {
-			time.Sleep(c.server.servCtx.Pause)
-			c.server.servCtx.Pause = 0
+		if done, err := c.dispatch(cmd); done {
+			return err
 		}
+	}
+}
 
-		if len(cmd) <= 0 {
-			err := command.ErrEmptyCommand
-			zap.L().Error(err.Error(), zap.String("addr", c.cliCtx.RemoteAddr),
-				zap.Int64("clientid", c.cliCtx.ID))
-			resp.ReplyError(c, err.Error())
-			c.conn.Close()
-			return nil
+// serveBurst drains the commands currently readable on the connection and
+// returns, the event loop frontend re-arms the descriptor afterwards so
+// an idle connection holds no goroutine. It reports whether the
+// connection is finished. A command split across packets blocks the
+// worker until its remaining bytes arrive, like the goroutine frontend
+// would
+func (c *client) serveBurst() (bool, error) {
+	first := true
+	for {
+		select {
+		case <-c.cliCtx.Done:
+			return true, c.conn.Close()
+		default:
+		}
+		if c.remoteClosed {
+			zap.L().Info("close connection", zap.String("addr", c.cliCtx.RemoteAddr),
+				zap.Int64("clientid", c.cliCtx.ID), zap.String("namespace", c.cliCtx.Namespace))
+			return true, c.conn.Close()
+		}
+		// the burst is over once the buffered input is drained, the first
+		// read may block briefly to pull in what the poller saw
+		if !first && c.r.Buffered() == 0 {
+			return false, nil
 		}
+		first = false
 
-		c.cliCtx.Updated = time.Now()
-		c.cliCtx.LastCmd = cmd[0]
-		if !c.exec.CanExecute(c.cliCtx.LastCmd) {
-			err := command.ErrUnKnownCommand(c.cliCtx.LastCmd)
-			zap.L().Error(err.Error(), zap.String("addr", c.cliCtx.RemoteAddr),
-				zap.Int64("clientid", c.cliCtx.ID))
-			resp.ReplyError(c, err.Error())
-			unknownCmdTimes++
-			if unknownCmdTimes >= 3 {
-				c.conn.Close()
-				return nil
-			} else {
-				continue
+		cmd, err := c.readCommand()
+		if err != nil {
+			c.conn.Close()
+			if err == io.EOF {
+				zap.L().Info("close connection", zap.String("addr", c.cliCtx.RemoteAddr),
+					zap.Int64("clientid", c.cliCtx.ID), zap.String("namespace", c.cliCtx.Namespace))
+				return true, nil
 			}
+			zap.L().Error("read command failed", zap.String("addr", c.cliCtx.RemoteAddr),
+				zap.Int64("clientid", c.cliCtx.ID), zap.String("namespace", c.cliCtx.Namespace), zap.Error(err))
+			return true, err
 		}
 
-		ctx := &command.Context{
-			Name:    cmd[0],
-			Args:    cmd[1:],
-			In:      c.r,
-			Out:     c,
-			TraceID: GenerateTraceID(),
+		if done, err := c.dispatch(cmd); done {
+			return true, err
 		}
+	}
+}
+
+// dispatch runs one parsed command through the executor, it reports
+// whether the connection is finished
+func (c *client) dispatch(cmd []string) (bool, error) {
+	if len(cmd) > 0 {
+		c.server.servCtx.ClientPause.Wait(command.IsWriteCommand(cmd[0]))
+	}
 
-		ctx.Context = context.New(c.cliCtx, c.server.servCtx)
+	if len(cmd) <= 0 {
+		err := command.ErrEmptyCommand
+		zap.L().Error(err.Error(), zap.String("addr", c.cliCtx.RemoteAddr),
+			zap.Int64("clientid", c.cliCtx.ID))
+		resp.ReplyError(c, err.Error())
+		c.conn.Close()
+		return true, nil
+	}
 
-		// Skip reply if necessary
-		if c.cliCtx.SkipN != 0 {
-			ctx.Out = ioutil.Discard
-			if c.cliCtx.SkipN > 0 {
-				c.cliCtx.SkipN--
-			}
+	c.cliCtx.Updated = time.Now()
+	c.cliCtx.LastCmd = cmd[0]
+	c.cliCtx.CommandCount++
+	if !c.exec.CanExecute(c.cliCtx.LastCmd) {
+		err := command.ErrUnKnownCommand(c.cliCtx.LastCmd)
+		zap.L().Error(err.Error(), zap.String("addr", c.cliCtx.RemoteAddr),
+			zap.Int64("clientid", c.cliCtx.ID))
+		resp.ReplyError(c, err.Error())
+		c.unknownCmdTimes++
+		if c.unknownCmdTimes >= 3 {
+			c.conn.Close()
+			return true, nil
 		}
-		if env := zap.L().Check(zap.DebugLevel, "recv client command"); env != nil {
-			env.Write(zap.String("addr", c.cliCtx.RemoteAddr),
-				zap.Int64("clientid", c.cliCtx.ID),
-				zap.String("traceid", ctx.TraceID),
-				zap.String("command", ctx.Name),
-				zap.Strings("arguments", ctx.Args))
+		return false, nil
+	}
+
+	ctx := &command.Context{
+		Name:    cmd[0],
+		Args:    cmd[1:],
+		In:      c.r,
+		Out:     c,
+		TraceID: GenerateTraceID(),
+	}
+
+	ctx.Context = context.New(c.cliCtx, c.server.servCtx)
+
+	// Skip reply if necessary
+	if c.cliCtx.SkipN != 0 {
+		ctx.Out = ioutil.Discard
+		if c.cliCtx.SkipN > 0 {
+			c.cliCtx.SkipN--
 		}
+	}
+	if env := zap.L().Check(zap.DebugLevel, "recv client command"); env != nil {
+		env.Write(zap.String("addr", c.cliCtx.RemoteAddr),
+			zap.Int64("clientid", c.cliCtx.ID),
+			zap.String("traceid", ctx.TraceID),
+			zap.String("command", ctx.Name),
+			zap.Strings("arguments", ctx.Args))
+	}
 
+	// keepalive probes from connection pools are frequent and trivial,
+	// answer them straight from the read loop when possible
+	if command.FastPath(ctx) {
+		c.cliCtx.FastPathCount++
+	} else {
 		c.exec.Execute(ctx)
 	}
+
+	// A deeply pipelined connection keeps its goroutine runnable without
+	// ever blocking on the read, starving other connections. Yield the
+	// processor after every burst so contended connections are served
+	// round-robin instead of one draining tikv alone
+	if max := c.server.servCtx.MaxPipelineBurst; max > 0 && c.r.Buffered() > 0 {
+		c.pipelined++
+		if c.pipelined >= max {
+			c.pipelined = 0
+			runtime.Gosched()
+		}
+	} else {
+		c.pipelined = 0
+	}
+	return false, nil
 }
 
 func (c *client) readInlineCommand() ([]string, error) {
@@ -174,7 +264,8 @@ func (c *client) readCommand() ([]string, error) {
 	if err != nil {
 		return nil, err
 	}
-	if argc == 0 {
+	// argc is -1 for a null array, treat it as an empty command
+	if argc <= 0 {
 		return []string{}, nil
 	}
 
@@ -0,0 +1,74 @@
+// Package client embeds titan's db layer so Go programs can call
+// typed operations directly against TiKV, without going through the
+// RESP protocol or a titan sidecar. Every operation runs in its own
+// transaction and retries on conflicts the same way the command layer
+// does.
+package client
+
+import (
+	"context"
+
+	"github.com/distributedio/titan/conf"
+	"github.com/distributedio/titan/db"
+	"github.com/shafreeck/retry"
+)
+
+// Client is an embedded titan instance bound to one namespace and db
+type Client struct {
+	store *db.RedisStore
+	db    *db.DB
+}
+
+// Open connects to tikv with the given config and binds the client to
+// a namespace, the background workers (gc, expire, ...) are started
+// the same as in a full titan server
+func Open(config *conf.Tikv, namespace string, dbID int) (*Client, error) {
+	store, err := db.Open(config)
+	if err != nil {
+		return nil, err
+	}
+	return &Client{store: store, db: store.DB(namespace, dbID)}, nil
+}
+
+// Close releases the underlying tikv client
+func (c *Client) Close() error {
+	return c.store.Close()
+}
+
+// Strings returns the typed api of the string structure
+func (c *Client) Strings() *Strings {
+	return &Strings{c}
+}
+
+// ZSets returns the typed api of the sorted set structure
+func (c *Client) ZSets() *ZSets {
+	return &ZSets{c}
+}
+
+// Kv returns the structure agnostic api working on whole keys
+func (c *Client) Kv() *Kv {
+	return &Kv{c}
+}
+
+// execute runs fn in its own transaction, commits it and retries the
+// whole operation when the commit fails with a retryable error
+func (c *Client) execute(ctx context.Context, fn func(txn *db.Transaction) error) error {
+	return retry.Ensure(ctx, func() error {
+		txn, err := c.db.Begin()
+		if err != nil {
+			return err
+		}
+		if err := fn(txn); err != nil {
+			txn.Rollback()
+			return err
+		}
+		if err := txn.Commit(ctx); err != nil {
+			txn.Rollback()
+			if db.IsRetryableError(err) {
+				return retry.Retriable(err)
+			}
+			return err
+		}
+		return nil
+	})
+}
@@ -0,0 +1,100 @@
+package client
+
+import (
+	"context"
+	"testing"
+
+	"github.com/distributedio/titan/conf"
+	"github.com/distributedio/titan/db"
+	"github.com/stretchr/testify/assert"
+)
+
+var (
+	testClient *Client
+	testCtx    = context.Background()
+)
+
+func init() {
+	mockConf := conf.MockConf()
+	c, err := Open(&mockConf.Tikv, "embed-ns", 0)
+	if err != nil {
+		panic(err)
+	}
+	testClient = c
+}
+
+func TestStrings(t *testing.T) {
+	strs := testClient.Strings()
+	key := []byte("client-string")
+
+	assert.NoError(t, strs.Set(testCtx, key, []byte("value")))
+	val, err := strs.Get(testCtx, key)
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("value"), val)
+
+	old, err := strs.GetSet(testCtx, key, []byte("new"))
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("value"), old)
+
+	_, err = strs.Get(testCtx, []byte("client-missing"))
+	assert.Equal(t, db.ErrKeyNotFound, err)
+
+	counter := []byte("client-counter")
+	v, err := strs.Incr(testCtx, counter, 2)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), v)
+	v, err = strs.Incr(testCtx, counter, 3)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(5), v)
+}
+
+func TestZSets(t *testing.T) {
+	zsets := testClient.ZSets()
+	key := []byte("client-zset")
+
+	added, err := zsets.Add(testCtx, key,
+		[][]byte{[]byte("m1"), []byte("m2"), []byte("m3")},
+		[]float64{3, 1, 2})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(3), added)
+
+	card, err := zsets.Card(testCtx, key)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(3), card)
+
+	score, exist, err := zsets.Score(testCtx, key, []byte("m2"))
+	assert.NoError(t, err)
+	assert.True(t, exist)
+	assert.Equal(t, float64(1), score)
+
+	_, exist, err = zsets.Score(testCtx, key, []byte("missing"))
+	assert.NoError(t, err)
+	assert.False(t, exist)
+
+	members, err := zsets.Range(testCtx, key, 0, -1, true)
+	assert.NoError(t, err)
+	assert.Equal(t, [][]byte{[]byte("m2"), []byte("m3"), []byte("m1")}, members)
+
+	removed, err := zsets.Rem(testCtx, key, []byte("m1"), []byte("missing"))
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), removed)
+}
+
+func TestKv(t *testing.T) {
+	strs := testClient.Strings()
+	kv := testClient.Kv()
+	key := []byte("client-kv")
+
+	assert.NoError(t, strs.Set(testCtx, key, []byte("value")))
+	count, err := kv.Exists(testCtx, key, []byte("client-kv-missing"))
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), count)
+
+	deleted, err := kv.Delete(testCtx, key)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), deleted)
+
+	count, err = kv.Exists(testCtx, key)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), count)
+}
@@ -0,0 +1,48 @@
+package client
+
+import (
+	"context"
+
+	"github.com/distributedio/titan/db"
+)
+
+// Kv is the structure agnostic api working on whole keys
+type Kv struct {
+	c *Client
+}
+
+// Delete removes keys of any type and returns the count of deleted
+// keys
+func (k *Kv) Delete(ctx context.Context, keys ...[]byte) (int64, error) {
+	var deleted int64
+	err := k.c.execute(ctx, func(txn *db.Transaction) error {
+		var err error
+		deleted, err = db.GetKv(txn).Delete(keys)
+		return err
+	})
+	if err != nil {
+		return 0, err
+	}
+	return deleted, nil
+}
+
+// Exists returns the count of existing keys
+func (k *Kv) Exists(ctx context.Context, keys ...[]byte) (int64, error) {
+	var count int64
+	err := k.c.execute(ctx, func(txn *db.Transaction) error {
+		var err error
+		count, err = db.GetKv(txn).Exists(keys)
+		return err
+	})
+	if err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+// ExpireAt sets the expiration time of a key in nanoseconds
+func (k *Kv) ExpireAt(ctx context.Context, key []byte, at int64) error {
+	return k.c.execute(ctx, func(txn *db.Transaction) error {
+		return db.GetKv(txn).ExpireAt(key, at)
+	})
+}
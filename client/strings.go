@@ -0,0 +1,77 @@
+package client
+
+import (
+	"context"
+
+	"github.com/distributedio/titan/db"
+)
+
+// Strings is the typed api of the string structure
+type Strings struct {
+	c *Client
+}
+
+// Set sets the string value of a key, an optional expire is the ttl
+// in nanoseconds as in the db layer
+func (s *Strings) Set(ctx context.Context, key, value []byte, expire ...int64) error {
+	return s.c.execute(ctx, func(txn *db.Transaction) error {
+		str, err := db.GetString(txn, key)
+		if err != nil {
+			return err
+		}
+		return str.Set(value, expire...)
+	})
+}
+
+// Get returns the value of a key, db.ErrKeyNotFound is returned when
+// the key does not exist
+func (s *Strings) Get(ctx context.Context, key []byte) ([]byte, error) {
+	var value []byte
+	err := s.c.execute(ctx, func(txn *db.Transaction) error {
+		str, err := db.GetString(txn, key)
+		if err != nil {
+			return err
+		}
+		value, err = str.Get()
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return value, nil
+}
+
+// GetSet sets the value of a key and returns its old value
+func (s *Strings) GetSet(ctx context.Context, key, value []byte) ([]byte, error) {
+	var old []byte
+	err := s.c.execute(ctx, func(txn *db.Transaction) error {
+		str, err := db.GetString(txn, key)
+		if err != nil {
+			return err
+		}
+		old, err = str.GetSet(value)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return old, nil
+}
+
+// Incr increments the integer value of a key by delta and returns the
+// value after the increment
+func (s *Strings) Incr(ctx context.Context, key []byte, delta int64) (int64, error) {
+	var value int64
+	err := s.c.execute(ctx, func(txn *db.Transaction) error {
+		str, err := db.GetString(txn, key)
+		if err != nil {
+			return err
+		}
+		value, err = str.Incr(delta)
+		return err
+	})
+	if err != nil {
+		return 0, err
+	}
+	return value, nil
+}
@@ -0,0 +1,114 @@
+package client
+
+import (
+	"context"
+	"strconv"
+
+	"github.com/distributedio/titan/db"
+)
+
+// ZSets is the typed api of the sorted set structure
+type ZSets struct {
+	c *Client
+}
+
+// Add adds members with their scores to a sorted set and returns the
+// count of newly added members
+func (z *ZSets) Add(ctx context.Context, key []byte, members [][]byte, scores []float64) (int64, error) {
+	var added int64
+	err := z.c.execute(ctx, func(txn *db.Transaction) error {
+		zset, err := db.GetZSet(txn, key)
+		if err != nil {
+			return err
+		}
+		added, err = zset.ZAdd(members, scores)
+		return err
+	})
+	if err != nil {
+		return 0, err
+	}
+	return added, nil
+}
+
+// Rem removes members from a sorted set and returns the count of
+// removed members
+func (z *ZSets) Rem(ctx context.Context, key []byte, members ...[]byte) (int64, error) {
+	var removed int64
+	err := z.c.execute(ctx, func(txn *db.Transaction) error {
+		zset, err := db.GetZSet(txn, key)
+		if err != nil {
+			return err
+		}
+		removed, err = zset.ZRem(members)
+		return err
+	})
+	if err != nil {
+		return 0, err
+	}
+	return removed, nil
+}
+
+// Card returns the member count of a sorted set
+func (z *ZSets) Card(ctx context.Context, key []byte) (int64, error) {
+	var card int64
+	err := z.c.execute(ctx, func(txn *db.Transaction) error {
+		zset, err := db.GetZSet(txn, key)
+		if err != nil {
+			return err
+		}
+		card = zset.ZCard()
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return card, nil
+}
+
+// Score returns the score of a member, the second return value is
+// false when the member or the key does not exist
+func (z *ZSets) Score(ctx context.Context, key, member []byte) (float64, bool, error) {
+	var score float64
+	var exist bool
+	err := z.c.execute(ctx, func(txn *db.Transaction) error {
+		zset, err := db.GetZSet(txn, key)
+		if err != nil {
+			return err
+		}
+		val, err := zset.ZScore(member)
+		if err != nil || val == nil {
+			return err
+		}
+		// the db layer formats scores with FormatScore which keeps
+		// enough digits for a lossless round trip
+		score, err = strconv.ParseFloat(string(val), 64)
+		if err != nil {
+			return err
+		}
+		exist = true
+		return nil
+	})
+	if err != nil {
+		return 0, false, err
+	}
+	return score, exist, nil
+}
+
+// Range returns the members of a sorted set between the start and
+// stop indexes, in score order when positiveOrder is true and in
+// reverse order otherwise
+func (z *ZSets) Range(ctx context.Context, key []byte, start, stop int64, positiveOrder bool) ([][]byte, error) {
+	var members [][]byte
+	err := z.c.execute(ctx, func(txn *db.Transaction) error {
+		zset, err := db.GetZSet(txn, key)
+		if err != nil {
+			return err
+		}
+		members, err = zset.ZAnyOrderRange(start, stop, false, positiveOrder)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return members, nil
+}
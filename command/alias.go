@@ -0,0 +1,54 @@
+package command
+
+import (
+	"fmt"
+	"strings"
+)
+
+// commandAliases maps an alias back to the canonical command it stands
+// for, dispatch resolves through it so metrics and the journal always
+// record the canonical name
+var commandAliases = map[string]string{}
+
+// AliasCommands applies an alias-command spec to the command tables
+// before the server starts serving, so legacy clients can keep using
+// vendor specific command names. The spec is a comma separated list of
+// alias:command pairs, the alias shares the Desc of the command it
+// points to
+func AliasCommands(spec string) error {
+	for _, pair := range strings.Split(spec, ",") {
+		if pair = strings.TrimSpace(pair); pair == "" {
+			continue
+		}
+		parts := strings.SplitN(pair, ":", 2)
+		if len(parts) != 2 {
+			return fmt.Errorf("invalid alias-command pair(%s), expect alias:command", pair)
+		}
+		alias := strings.ToLower(strings.TrimSpace(parts[0]))
+		canonical := strings.ToLower(strings.TrimSpace(parts[1]))
+		if alias == "" || canonical == "" {
+			return fmt.Errorf("invalid alias-command pair(%s), expect alias:command", pair)
+		}
+		desc, ok := commands[canonical]
+		if !ok {
+			return fmt.Errorf("alias-command unknown command(%s)", canonical)
+		}
+		if _, exists := commands[alias]; exists {
+			return fmt.Errorf("alias-command name(%s) already exists", alias)
+		}
+		commands[alias] = desc
+		if txnCmd, ok := txnCommands[canonical]; ok {
+			txnCommands[alias] = txnCmd
+		}
+		commandAliases[alias] = canonical
+	}
+	return nil
+}
+
+// resolveAlias maps an aliased command name back to its canonical name
+func resolveAlias(name string) string {
+	if canonical, ok := commandAliases[name]; ok {
+		return canonical
+	}
+	return name
+}
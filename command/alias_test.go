@@ -0,0 +1,44 @@
+package command
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAliasCommands(t *testing.T) {
+	defer func() {
+		delete(commands, "getx")
+		delete(txnCommands, "getx")
+		delete(commands, "pingx")
+		delete(commandAliases, "getx")
+		delete(commandAliases, "pingx")
+	}()
+
+	// an alias shares the tables of the command it points to
+	assert.NoError(t, AliasCommands("getx:get, pingx:ping"))
+	assert.Equal(t, commands["get"].Cons, commands["getx"].Cons)
+	_, ok := txnCommands["getx"]
+	assert.True(t, ok)
+	_, ok = txnCommands["pingx"]
+	assert.False(t, ok)
+
+	// dispatch resolves to the canonical name, so the alias answers
+	// like the original
+	ctx := ContextTest("set", "alias-key", "v")
+	Call(ctx)
+	ctx = ContextTest("getx", "alias-key")
+	Call(ctx)
+	assert.Equal(t, "get", ctx.Name)
+	lines := ctxLines(ctx.Out)
+	assert.Equal(t, "$1", lines[0])
+	assert.Equal(t, "v", lines[1])
+
+	// errors: malformed pair, unknown command, existing name
+	assert.Error(t, AliasCommands("setx"))
+	assert.Error(t, AliasCommands("setx:nosuchcommand"))
+	assert.Error(t, AliasCommands("mget:mset"))
+
+	// an empty spec is a no-op
+	assert.NoError(t, AliasCommands(""))
+}
@@ -0,0 +1,98 @@
+package command
+
+import (
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// HMACAuth validates namespace tokens signed with the shared static
+// secret, the scheme titan has always used
+type HMACAuth struct {
+	key []byte
+}
+
+// NewHMACAuth creates a provider validating tokens against key
+func NewHMACAuth(key string) *HMACAuth {
+	return &HMACAuth{key: []byte(key)}
+}
+
+// Authenticate implements context.AuthProvider
+func (a *HMACAuth) Authenticate(token string) (string, error) {
+	namespace, err := Verify([]byte(token), a.key)
+	if err != nil {
+		return "", err
+	}
+	return string(namespace), nil
+}
+
+type externalVerdict struct {
+	namespace string
+	expireAt  time.Time
+}
+
+// ExternalAuth validates namespace tokens by calling out to an external
+// verifier over http, so tokens can be issued by an identity system
+// instead of the static secret. The verifier receives the token as the
+// POST body and replies 200 with the namespace as the body when the
+// token is valid. Positive verdicts are cached for cacheTTL to keep the
+// verifier off the hot path
+type ExternalAuth struct {
+	url    string
+	ttl    time.Duration
+	client *http.Client
+
+	mu    sync.Mutex
+	cache map[string]externalVerdict
+}
+
+// NewExternalAuth creates a provider calling the verifier at url
+func NewExternalAuth(url string, timeout, cacheTTL time.Duration) *ExternalAuth {
+	return &ExternalAuth{
+		url:    url,
+		ttl:    cacheTTL,
+		client: &http.Client{Timeout: timeout},
+		cache:  make(map[string]externalVerdict),
+	}
+}
+
+// Authenticate implements context.AuthProvider
+func (a *ExternalAuth) Authenticate(token string) (string, error) {
+	now := time.Now()
+	a.mu.Lock()
+	if v, ok := a.cache[token]; ok {
+		if now.Before(v.expireAt) {
+			a.mu.Unlock()
+			return v.namespace, nil
+		}
+		delete(a.cache, token)
+	}
+	a.mu.Unlock()
+
+	res, err := a.client.Post(a.url, "text/plain", strings.NewReader(token))
+	if err != nil {
+		return "", err
+	}
+	defer res.Body.Close()
+	body, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return "", err
+	}
+	if res.StatusCode != http.StatusOK {
+		return "", errors.New("token rejected by auth provider")
+	}
+	namespace := strings.TrimSpace(string(body))
+	if namespace == "" {
+		return "", errors.New("auth provider replied an empty namespace")
+	}
+
+	if a.ttl > 0 {
+		a.mu.Lock()
+		a.cache[token] = externalVerdict{namespace: namespace, expireAt: now.Add(a.ttl)}
+		a.mu.Unlock()
+	}
+	return namespace, nil
+}
@@ -0,0 +1,89 @@
+package command
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHMACAuth(t *testing.T) {
+	token, err := Token([]byte("secret"), []byte("tenant"), time.Now().Unix())
+	assert.NoError(t, err)
+
+	provider := NewHMACAuth("secret")
+	namespace, err := provider.Authenticate(string(token))
+	assert.NoError(t, err)
+	assert.Equal(t, "tenant", namespace)
+
+	_, err = NewHMACAuth("wrong").Authenticate(string(token))
+	assert.Error(t, err)
+}
+
+func TestAuthInvalidNamespaceName(t *testing.T) {
+	// a token minted for "bad@1" would alias generation one of "bad"
+	token, err := Token([]byte("secret"), []byte("bad@1"), time.Now().Unix())
+	assert.NoError(t, err)
+	ctx := ContextTest("auth", string(token))
+	ctx.Server.RequirePass = "secret"
+	Call(ctx)
+	assert.Equal(t, "-"+ErrInvalidNamespace.Error(), ctxLines(ctx.Out)[0])
+}
+
+func TestExternalAuth(t *testing.T) {
+	var calls int
+	verifier := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.Write([]byte("tenant\n"))
+	}))
+	defer verifier.Close()
+
+	provider := NewExternalAuth(verifier.URL, time.Second, time.Minute)
+	namespace, err := provider.Authenticate("token-1")
+	assert.NoError(t, err)
+	assert.Equal(t, "tenant", namespace)
+
+	// the second check hits the cache, not the verifier
+	namespace, err = provider.Authenticate("token-1")
+	assert.NoError(t, err)
+	assert.Equal(t, "tenant", namespace)
+	assert.Equal(t, 1, calls)
+}
+
+func TestExternalAuthRejected(t *testing.T) {
+	verifier := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "unknown token", http.StatusForbidden)
+	}))
+	defer verifier.Close()
+
+	provider := NewExternalAuth(verifier.URL, time.Second, time.Minute)
+	_, err := provider.Authenticate("bad-token")
+	assert.Error(t, err)
+
+	// negative verdicts are not cached, the verifier is asked again
+	_, err = provider.Authenticate("bad-token")
+	assert.Error(t, err)
+}
+
+func TestAuthSystemFlag(t *testing.T) {
+	adminToken, err := Token([]byte("secret"), []byte(sysAdminNamespace), time.Now().Unix())
+	assert.NoError(t, err)
+	userToken, err := Token([]byte("secret"), []byte("tenant"), time.Now().Unix())
+	assert.NoError(t, err)
+
+	// authenticating into the operator namespace marks the connection
+	// as a system one
+	ctx := ContextTest("auth", string(adminToken))
+	ctx.Server.RequirePass = "secret"
+	Call(ctx)
+	assert.Equal(t, "+OK", ctxLines(ctx.Out)[0])
+	assert.True(t, ctx.Client.System)
+
+	// re-authing into a user namespace drops the exemption
+	ctx.Args = []string{string(userToken)}
+	Auth(ctx)
+	assert.Equal(t, "tenant", ctx.Client.Namespace)
+	assert.False(t, ctx.Client.System)
+}
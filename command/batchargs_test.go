@@ -0,0 +1,41 @@
+package command
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBatchArgsGuard(t *testing.T) {
+	key := "batch-args-zset"
+
+	ctx := ContextTest("zadd", key, "1", "m1", "2", "m2", "3", "m3")
+	ctx.Server.MaxBatchArgs = 5
+	Call(ctx)
+	lines := ctxLines(ctx.Out)
+	assert.True(t, strings.HasPrefix(lines[0], "-ERR 7 arguments exceed"), lines[0])
+
+	// a shorter command still goes through
+	ctx = ContextTest("zadd", key, "1", "m1", "2", "m2")
+	ctx.Server.MaxBatchArgs = 5
+	Call(ctx)
+	lines = ctxLines(ctx.Out)
+	assert.Equal(t, ":2", lines[0])
+
+	// admin connections bypass the cap
+	ctx = ContextTest("zrem", key, "m1", "m2", "m3", "m4", "m5")
+	ctx.Server.MaxBatchArgs = 5
+	ctx.Client.Namespace = sysAdminNamespace
+	Call(ctx)
+	lines = ctxLines(ctx.Out)
+	assert.Equal(t, ":2", lines[0])
+}
+
+func TestFirstOccurrences(t *testing.T) {
+	args := []string{"b", "a", "b", "c", "a", "b"}
+	kept := firstOccurrences(len(args), func(i int) string { return args[i] })
+	assert.Equal(t, []int{0, 1, 3}, kept)
+
+	assert.Empty(t, firstOccurrences(0, func(i int) string { return "" }))
+}
@@ -0,0 +1,113 @@
+package command
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+
+	"github.com/distributedio/titan/db"
+)
+
+// SlotCount is the number of hash slots of a redis cluster
+const SlotCount = 16384
+
+// crc16 implements the CCITT polynomial (0x1021) used by redis cluster
+// to map keys to hash slots
+func crc16(data []byte) uint16 {
+	var crc uint16
+	for _, b := range data {
+		crc ^= uint16(b) << 8
+		for i := 0; i < 8; i++ {
+			if crc&0x8000 != 0 {
+				crc = crc<<1 ^ 0x1021
+			} else {
+				crc <<= 1
+			}
+		}
+	}
+	return crc
+}
+
+// Slot returns the redis cluster hash slot of key, honoring hash tags,
+// only the part between the first '{' and the following '}' is hashed
+// when it is not empty
+func Slot(key string) int {
+	if start := strings.IndexByte(key, '{'); start >= 0 {
+		if end := strings.IndexByte(key[start+1:], '}'); end > 0 {
+			key = key[start+1 : start+1+end]
+		}
+	}
+	return int(crc16([]byte(key))) % SlotCount
+}
+
+// Keys extracts the key arguments of a command from args using the
+// first-key/last-key/key-step metadata, args excludes the command name
+// while the metadata counts it as position zero, the redis convention
+func (c *Constraint) Keys(args []string) []string {
+	if c.FirstKey <= 0 || c.KeyStep <= 0 {
+		return nil
+	}
+	last := c.LastKey
+	if last < 0 {
+		// negative positions count back from the last argument
+		last = len(args) + 1 + last
+	}
+	var keys []string
+	for i := c.FirstKey; i <= last && i <= len(args); i += c.KeyStep {
+		keys = append(keys, args[i-1])
+	}
+	return keys
+}
+
+// crossSlot reports whether keys hash to more than one cluster slot
+func crossSlot(keys []string) bool {
+	if len(keys) < 2 {
+		return false
+	}
+	slot := Slot(keys[0])
+	for _, key := range keys[1:] {
+		if Slot(key) != slot {
+			return true
+		}
+	}
+	return false
+}
+
+// Cluster dispatches the cluster subcommands, the membership views are
+// backed by the node registry in tikv
+func Cluster(ctx *Context, txn *db.Transaction) (OnCommit, error) {
+	switch strings.ToLower(ctx.Args[0]) {
+	case "nodes":
+		nodes, err := db.ListNodes(txn, ctx.Server.Store.NodeLifeTime())
+		if err != nil {
+			return nil, errors.New("ERR " + err.Error())
+		}
+		var lines []string
+		for _, node := range nodes {
+			lines = append(lines, strings.Join([]string{
+				node.ID,
+				node.IP,
+				node.Version,
+				node.Roles,
+				strconv.FormatInt(node.StartAt, 10),
+				strconv.FormatInt(node.HeartbeatAt, 10),
+			}, " "))
+		}
+		return BulkString(ctx.Out, strings.Join(lines, "\n")+"\n"), nil
+	case "info":
+		nodes, err := db.ListNodes(txn, ctx.Server.Store.NodeLifeTime())
+		if err != nil {
+			return nil, errors.New("ERR " + err.Error())
+		}
+		var lines []string
+		lines = append(lines, "cluster_enabled:0")
+		lines = append(lines, "cluster_known_nodes:"+strconv.Itoa(len(nodes)))
+		lines = append(lines, "cluster_size:"+strconv.Itoa(len(nodes)))
+		return BulkString(ctx.Out, strings.Join(lines, "\n")+"\n"), nil
+	case "help":
+		lines, _ := subcommandHelpLines("cluster")
+		return BytesArray(ctx.Out, lines), nil
+	default:
+		return nil, errors.New("ERR Syntax error, try CLUSTER (NODES | INFO | HELP)")
+	}
+}
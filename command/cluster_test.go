@@ -0,0 +1,41 @@
+package command
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSlot(t *testing.T) {
+	// value published in the redis cluster specification
+	assert.Equal(t, 12182, Slot("foo"))
+
+	// hash tags make different keys share a slot
+	assert.Equal(t, Slot("user1000"), Slot("{user1000}.following"))
+	assert.Equal(t, Slot("{user1000}.followers"), Slot("{user1000}.following"))
+
+	// an empty tag hashes the whole key
+	assert.Equal(t, Slot("foo{}bar"), Slot("foo{}bar"))
+	assert.NotEqual(t, Slot("{}foo"), Slot("{}bar"))
+}
+
+func TestConstraintKeys(t *testing.T) {
+	get := commands["get"].Cons
+	assert.Equal(t, []string{"key"}, get.Keys([]string{"key"}))
+
+	mget := commands["mget"].Cons
+	assert.Equal(t, []string{"k1", "k2", "k3"}, mget.Keys([]string{"k1", "k2", "k3"}))
+
+	mset := commands["mset"].Cons
+	assert.Equal(t, []string{"k1", "k2"}, mset.Keys([]string{"k1", "v1", "k2", "v2"}))
+
+	ping := commands["ping"].Cons
+	assert.Nil(t, ping.Keys([]string{}))
+}
+
+func TestCrossSlot(t *testing.T) {
+	assert.False(t, crossSlot(nil))
+	assert.False(t, crossSlot([]string{"foo"}))
+	assert.False(t, crossSlot([]string{"{user1000}.a", "{user1000}.b"}))
+	assert.True(t, crossSlot([]string{"foo", "bar"}))
+}
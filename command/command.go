@@ -1,6 +1,7 @@
 package command
 
 import (
+	"fmt"
 	"io"
 	"strconv"
 	"strings"
@@ -9,6 +10,7 @@ import (
 	"github.com/distributedio/titan/context"
 	"github.com/distributedio/titan/db"
 	"github.com/distributedio/titan/encoding/resp"
+	"github.com/distributedio/titan/failpoint"
 	"github.com/distributedio/titan/metrics"
 	"github.com/shafreeck/retry"
 	"go.uber.org/zap"
@@ -21,6 +23,11 @@ type Context struct {
 	In      io.Reader
 	Out     io.Writer
 	TraceID string
+
+	// allocated is the approximate bytes of intermediate buffers the
+	// command held in memory, charged through accountReplySize
+	allocated int
+
 	*context.Context
 }
 
@@ -87,20 +94,127 @@ func BytesArrayOnce(w io.Writer, a [][]byte) OnCommit {
 // TxnCommand runs a command in transaction
 type TxnCommand func(ctx *Context, txn *db.Transaction) (OnCommit, error)
 
+// checkReplySize guards commands that buffer whole collections against the
+// configured element cap, admin connections bypass it
+func checkReplySize(ctx *Context, elements int) error {
+	if ctx.Server == nil {
+		return nil
+	}
+	max := ctx.Server.MaxReplyElements
+	if max <= 0 || elements <= max || ctx.Client.Namespace == sysAdminNamespace {
+		return nil
+	}
+	return fmt.Errorf("ERR reply of %d elements exceeds the maximum of %d, narrow the range", elements, max)
+}
+
+// checkBatchArgs guards multi-member commands against unbounded argument
+// lists, admin connections bypass it
+func checkBatchArgs(ctx *Context, args int) error {
+	if ctx.Server == nil {
+		return nil
+	}
+	max := ctx.Server.MaxBatchArgs
+	if max <= 0 || args <= max || ctx.Client.Namespace == sysAdminNamespace {
+		return nil
+	}
+	return fmt.Errorf("ERR %d arguments exceed the maximum of %d, split the command", args, max)
+}
+
+// accountReplySize charges a buffered collection against the per-request
+// memory budget, commands that hold a whole collection in memory call it
+// before replying so an abusive request is refused while the buffer is
+// still its only copy, admin connections bypass the cap
+func accountReplySize(ctx *Context, a [][]byte) error {
+	for i := range a {
+		ctx.allocated += len(a[i])
+	}
+	if ctx.Server == nil {
+		return nil
+	}
+	max := ctx.Server.MaxRequestBytes
+	if max <= 0 || ctx.allocated <= max || ctx.Client.Namespace == sysAdminNamespace {
+		return nil
+	}
+	return ErrRequestBytes
+}
+
+// replyWriter wraps the client writer to track whether anything was
+// written and the first write error, so a failed OnCommit does not end as
+// a silently truncated reply
+type replyWriter struct {
+	w       io.Writer
+	wrote   bool
+	written int
+	limit   int // max reply bytes, 0 means unlimited
+	err     error
+}
+
+func (r *replyWriter) Write(p []byte) (int, error) {
+	if r.err != nil {
+		return 0, r.err
+	}
+	if r.limit > 0 && r.written+len(p) > r.limit {
+		r.err = ErrReplyBytes
+		return 0, r.err
+	}
+	n, err := r.w.Write(p)
+	r.written += n
+	if n > 0 {
+		r.wrote = true
+	}
+	if err != nil {
+		r.err = err
+	}
+	return n, err
+}
+
+// handleReplyFailure accounts a reply write failure and kicks the
+// connection, a client that missed part of a reply cannot stay on the
+// stream. When nothing was flushed yet an error reply is attempted so the
+// client sees a failure instead of silence
+func handleReplyFailure(ctx *Context, rw *replyWriter) {
+	metrics.GetMetrics().ReplyFailuresCounterVec.WithLabelValues(ctx.Name).Inc()
+	if !rw.wrote {
+		resp.ReplyError(rw.w, "ERR reply failed: "+rw.err.Error())
+	}
+	ctx.Client.Kick()
+	zap.L().Error("reply write failed",
+		zap.Int64("clientid", ctx.Client.ID),
+		zap.String("command", ctx.Name),
+		zap.String("traceid", ctx.TraceID),
+		zap.Bool("partial", rw.wrote),
+		zap.Error(rw.err))
+}
+
 // Call a command
 func Call(ctx *Context) {
-	ctx.Name = strings.ToLower(ctx.Name)
+	ctx.Name = resolveAlias(strings.ToLower(ctx.Name))
+	traceCommand(ctx)
 
-	if _, ok := txnCommands[ctx.Name]; ok && ctx.Server.LimitersMgr != nil {
+	if _, ok := txnCommands[ctx.Name]; ok && ctx.Server.LimitersMgr != nil && !ctx.Client.System {
 		ctx.Server.LimitersMgr.CheckLimit(ctx.Client.Namespace, ctx.Name, ctx.Args)
 	}
 
 	if ctx.Name != "auth" &&
-		ctx.Server.RequirePass != "" &&
+		(ctx.Server.RequirePass != "" || ctx.Server.AuthProvider != nil) &&
 		ctx.Client.Authenticated == false {
 		resp.ReplyError(ctx.Out, ErrNoAuth.Error())
 		return
 	}
+	// Tokens of the reserved system keyspace exist only for internal
+	// workers, a client holding one may only re-auth or leave
+	if ctx.Name != "auth" && ctx.Name != "quit" &&
+		ctx.Client.Namespace != sysAdminNamespace &&
+		db.IsReservedNamespace(ctx.Client.Namespace) {
+		resp.ReplyError(ctx.Out, ErrReservedNamespace.Error())
+		return
+	}
+	// the journal records accepted write commands before they touch
+	// storage, the trail is what crash diagnostics work from
+	if ctx.Server.Journal != nil && commands[ctx.Name].Cons.Flags&CmdWrite != 0 {
+		ctx.Server.Journal.Record(ctx.Client.Namespace, ctx.Name, ctx.Args)
+	}
+
 	// Exec all queued commands if this is an exec command
 	if ctx.Name == "exec" {
 		if len(ctx.Args) != 0 {
@@ -147,6 +261,39 @@ func Call(ctx *Context) {
 		return
 	}
 
+	// Reject writes of a namespace in read-only maintenance mode, the
+	// operator namespace stays writable to be able to lift the mode
+	if cmdInfoCommand.Cons.Flags&CmdWrite != 0 &&
+		ctx.Server.ReadOnlyMgr != nil &&
+		ctx.Client.Namespace != sysAdminNamespace &&
+		ctx.Server.ReadOnlyMgr.IsReadOnly(ctx.Client.Namespace) {
+		replyError(ctx, ErrReadOnly.Error())
+		return
+	}
+
+	// Fail transactional commands fast while the tikv circuit breaker is
+	// open, the operator namespace passes to keep the override reachable
+	if _, ok := txnCommands[ctx.Name]; ok && ctx.Client.Namespace != sysAdminNamespace {
+		if b := db.GetBreaker(); b != nil && !b.Allow() {
+			metrics.GetMetrics().BreakerFastFailTotal.Inc()
+			replyError(ctx, ErrTryAgain.Error())
+			return
+		}
+	}
+
+	// Delay writes of unprotected namespaces while commit latency
+	// backpressure is shedding load, the operator namespace never waits
+	if cmdInfoCommand.Cons.Flags&CmdWrite != 0 && ctx.Client.Namespace != sysAdminNamespace {
+		db.BackpressureWait(ctx.Client.Namespace)
+	}
+
+	// Reject cross slot multi-key commands before queueing them, the
+	// same way a real redis cluster node does
+	if ctx.Server.StrictClusterSlots && crossSlot(cmdInfoCommand.Cons.Keys(ctx.Args)) {
+		resp.ReplyError(ctx.Out, ErrCrossSlot.Error())
+		return
+	}
+
 	// We now in a multi block, queue the command and return
 	if ctx.Client.Multi {
 		if ctx.Name == "multi" {
@@ -160,6 +307,17 @@ func Call(ctx *Context) {
 		return
 	}
 
+	if _, ok := txnCommands[ctx.Name]; ok && ctx.Server.TxnPool != nil {
+		// admin commands and system connections take the reserved lane
+		// so they stay responsive on a node saturated with user traffic
+		admin := cmdInfoCommand.Cons.Flags&CmdAdmin != 0 || ctx.Client.System
+		if err := ctx.Server.TxnPool.Acquire(ctx.Client.Namespace, admin); err != nil {
+			replyError(ctx, ErrTxnPoolTimeout.Error())
+			return
+		}
+		defer ctx.Server.TxnPool.Release(ctx.Client.Namespace, admin)
+	}
+
 	feedMonitors(ctx)
 	start := time.Now()
 	cmdInfoCommand.Proc(ctx)
@@ -171,7 +329,7 @@ func Call(ctx *Context) {
 
 // TxnCall calls a command with transaction, it is used with multi/exec
 func TxnCall(ctx *Context, txn *db.Transaction) (OnCommit, error) {
-	name := strings.ToLower(ctx.Name)
+	name := resolveAlias(strings.ToLower(ctx.Name))
 	cmd, ok := txnCommands[name]
 	if !ok {
 		return nil, ErrUnKnownCommand(ctx.Name)
@@ -180,24 +338,94 @@ func TxnCall(ctx *Context, txn *db.Transaction) (OnCommit, error) {
 	return cmd(ctx, txn)
 }
 
+// fastPathCommands are cheap enough to answer straight from the connection
+// read loop, they never touch storage or the limiters
+var fastPathCommands = map[string]Command{
+	"ping":   Ping,
+	"echo":   Echo,
+	"select": Select,
+}
+
+// FastPath answers keepalive style commands without the executor machinery
+// and reports whether it did. Anything protocol-stateful, a queued multi
+// command, an unauthenticated client, a watching monitor or a wrong arity,
+// falls back to the full path
+func FastPath(ctx *Context) bool {
+	ctx.Name = strings.ToLower(ctx.Name)
+	cmd, ok := fastPathCommands[ctx.Name]
+	if !ok || ctx.Client.Multi {
+		return false
+	}
+	if (ctx.Server.RequirePass != "" || ctx.Server.AuthProvider != nil) &&
+		!ctx.Client.Authenticated {
+		return false
+	}
+	if ctx.Client.Namespace != sysAdminNamespace && db.IsReservedNamespace(ctx.Client.Namespace) {
+		return false
+	}
+	monitored := false
+	ctx.Server.Monitors.Range(func(k, v interface{}) bool {
+		monitored = true
+		return false
+	})
+	if monitored {
+		return false
+	}
+	arity := commands[ctx.Name].Cons.Arity
+	argc := len(ctx.Args) + 1
+	if (arity > 0 && argc != arity) || (arity < 0 && argc < -arity) {
+		return false
+	}
+	cmd(ctx)
+	return true
+}
+
+// reusableSnapshot reports whether the command may run on the shared read
+// snapshot of its connection, see the snapshot-reuse server config
+func reusableSnapshot(ctx *Context) bool {
+	if ctx.Server == nil || ctx.Server.SnapshotReuse.Disable ||
+		ctx.Server.SnapshotReuse.MaxAge <= 0 || ctx.Client.Multi {
+		return false
+	}
+	cmd, ok := commands[ctx.Name]
+	return ok && cmd.Cons.Flags&CmdReadOnly != 0
+}
+
 // AutoCommit commits to database after run a txn command
 func AutoCommit(cmd TxnCommand) Command {
 	return func(ctx *Context) {
 		retry.Ensure(ctx, func() error {
 			mt := metrics.GetMetrics()
+			nsLabel := metrics.NamespaceLabel(ctx.Client.Namespace)
 			start := time.Now()
-			txn, err := ctx.Client.DB.Begin()
+			reuse := reusableSnapshot(ctx)
+			var txn *db.Transaction
+			var err error
+			if reuse {
+				txn = ctx.Client.SharedReadTxn(ctx.Server.SnapshotReuse.MaxAge)
+			} else {
+				// anything but a read must see its own effects, drop the
+				// shared snapshot so the next read begins a fresh one
+				ctx.Client.InvalidateReadTxn()
+			}
+			if txn == nil {
+				txn, err = ctx.Client.DB.Begin()
+				if reuse && err == nil {
+					ctx.Client.ReadTxn = txn
+					ctx.Client.ReadTxnAt = time.Now()
+				}
+			}
 			key := ""
 			if len(ctx.Args) > 0 {
 				key = ctx.Args[0]
-				mt.CommandArgsNumHistogramVec.WithLabelValues(ctx.Client.Namespace, ctx.Name).Observe(float64(len(ctx.Args)))
+				mt.CommandArgsNumHistogramVec.WithLabelValues(nsLabel, ctx.Name).Observe(float64(len(ctx.Args)))
 			}
 			cost := time.Since(start).Seconds()
-			mt.TxnBeginHistogramVec.WithLabelValues(ctx.Client.Namespace, ctx.Name).Observe(cost)
+			mt.TxnBeginHistogramVec.WithLabelValues(nsLabel, ctx.Name).Observe(cost)
 			zap.L().Debug("transation begin", zap.String("name", ctx.Name), zap.String("key", key), zap.Int64("cost(us)", int64(cost*1000000)))
 			if err != nil {
-				mt.TxnFailuresCounterVec.WithLabelValues(ctx.Client.Namespace, ctx.Name).Inc()
-				resp.ReplyError(ctx.Out, "ERR "+err.Error())
+				mt.TxnFailuresCounterVec.WithLabelValues(nsLabel, ctx.Name).Inc()
+				replyError(ctx, "ERR "+err.Error())
 				zap.L().Error("txn begin failed",
 					zap.Int64("clientid", ctx.Client.ID),
 					zap.String("command", ctx.Name),
@@ -209,12 +437,16 @@ func AutoCommit(cmd TxnCommand) Command {
 			start = time.Now()
 			onCommit, err := cmd(ctx, txn)
 			cost = time.Since(start).Seconds()
-			mt.CommandFuncDoneHistogramVec.WithLabelValues(ctx.Client.Namespace, ctx.Name).Observe(cost)
+			mt.CommandFuncDoneHistogramVec.WithLabelValues(nsLabel, ctx.Name).Observe(cost)
 			zap.L().Debug("command done", zap.String("name", ctx.Name), zap.String("key", key), zap.Int64("cost(us)", int64(cost*1000000)))
 			if err != nil {
-				mt.TxnFailuresCounterVec.WithLabelValues(ctx.Client.Namespace, ctx.Name).Inc()
-				resp.ReplyError(ctx.Out, err.Error())
-				txn.Rollback()
+				mt.TxnFailuresCounterVec.WithLabelValues(nsLabel, ctx.Name).Inc()
+				replyError(ctx, err.Error())
+				if reuse {
+					ctx.Client.InvalidateReadTxn()
+				} else {
+					txn.Rollback()
+				}
 				zap.L().Error("command process failed",
 					zap.Int64("clientid", ctx.Client.ID),
 					zap.String("command", ctx.Name),
@@ -226,14 +458,26 @@ func AutoCommit(cmd TxnCommand) Command {
 			start = time.Now()
 			mtFunc := func() {
 				cost = time.Since(start).Seconds()
-				mt.TxnCommitHistogramVec.WithLabelValues(ctx.Client.Namespace, ctx.Name).Observe(cost)
+				mt.TxnCommitHistogramVec.WithLabelValues(nsLabel, ctx.Name).Observe(cost)
+			}
+			if reuse {
+				// a read-only transaction has nothing to commit, the
+				// snapshot stays open for the next read of the connection
+				if onCommit != nil {
+					onCommit()
+				}
+				return nil
 			}
-			if err := txn.Commit(ctx); err != nil {
+			err = txn.Commit(ctx)
+			if err == nil {
+				err = failpoint.Error("txn-commit")
+			}
+			if err != nil {
 				txn.Rollback()
-				mt.TxnFailuresCounterVec.WithLabelValues(ctx.Client.Namespace, ctx.Name).Inc()
+				mt.TxnFailuresCounterVec.WithLabelValues(nsLabel, ctx.Name).Inc()
 				if db.IsRetryableError(err) {
-					mt.TxnRetriesCounterVec.WithLabelValues(ctx.Client.Namespace, ctx.Name).Inc()
-					mt.TxnConflictsCounterVec.WithLabelValues(ctx.Client.Namespace, ctx.Name).Inc()
+					mt.TxnRetriesCounterVec.WithLabelValues(nsLabel, ctx.Name).Inc()
+					mt.TxnConflictsCounterVec.WithLabelValues(nsLabel, ctx.Name).Inc()
 					mtFunc()
 					zap.L().Error("txn commit retry",
 						zap.Int64("clientid", ctx.Client.ID),
@@ -242,7 +486,7 @@ func AutoCommit(cmd TxnCommand) Command {
 						zap.Error(err))
 					return retry.Retriable(err)
 				}
-				resp.ReplyError(ctx.Out, "ERR "+err.Error())
+				replyError(ctx, "ERR "+err.Error())
 				mtFunc()
 				zap.L().Error("txn commit failed",
 					zap.Int64("clientid", ctx.Client.ID),
@@ -258,7 +502,7 @@ func AutoCommit(cmd TxnCommand) Command {
 				onCommit()
 			}
 			cost = time.Since(start).Seconds()
-			mt.ReplyFuncDoneHistogramVec.WithLabelValues(ctx.Client.Namespace, ctx.Name).Observe(cost)
+			mt.ReplyFuncDoneHistogramVec.WithLabelValues(nsLabel, ctx.Name).Observe(cost)
 			zap.L().Debug("onCommit ", zap.String("name", ctx.Name), zap.String("key", key), zap.Int64("cost(us)", int64(cost*1000000)))
 			mtFunc()
 			return nil
@@ -306,12 +550,48 @@ func (e *Executor) CanExecute(cmd string) bool {
 	return ok
 }
 
+// IsWriteCommand reports whether name is a write command, used by the
+// client pause gate to tell writes apart in write mode
+func IsWriteCommand(name string) bool {
+	cmd, ok := commands[strings.ToLower(name)]
+	return ok && cmd.Cons.Flags&CmdWrite != 0
+}
+
 // Execute a command
 func (e *Executor) Execute(ctx *Context) {
 	start := time.Now()
+	argBytes := len(ctx.Name)
+	for i := range ctx.Args {
+		argBytes += len(ctx.Args[i])
+	}
+	rw := &replyWriter{w: ctx.Out}
+	if ctx.Server != nil && ctx.Client.Namespace != sysAdminNamespace {
+		rw.limit = ctx.Server.MaxReplyBytes
+		// the request budget covers arguments and reply together, leave
+		// the tighter of the two caps on the writer
+		if max := ctx.Server.MaxRequestBytes; max > 0 {
+			if argBytes >= max {
+				// the arguments alone blow the budget, refuse to run
+				resp.ReplyError(ctx.Out, ErrRequestBytes.Error())
+				ctx.Client.Kick()
+				return
+			}
+			if left := max - argBytes; rw.limit <= 0 || left < rw.limit {
+				rw.limit = left
+			}
+		}
+	}
+	ctx.Out = rw
 	Call(ctx)
-	cost := time.Since(start).Seconds()
-	metrics.GetMetrics().CommandCallHistogramVec.WithLabelValues(ctx.Client.Namespace, ctx.Name).Observe(cost)
+	ctx.Out = rw.w
+	if rw.err != nil {
+		handleReplyFailure(ctx, rw)
+	}
+	cost := time.Since(start)
+	mt := metrics.GetMetrics()
+	mt.CommandCallHistogramVec.WithLabelValues(metrics.NamespaceLabel(ctx.Client.Namespace), ctx.Name).Observe(cost.Seconds())
+	mt.CommandMemoryHistogramVec.WithLabelValues(metrics.NamespaceLabel(ctx.Client.Namespace), ctx.Name).Observe(float64(argBytes + ctx.allocated + rw.written))
+	observeLatencySLO(ctx, cost)
 }
 
 // Desc describes a command with constraints
@@ -113,3 +113,58 @@ func BenchmarkGlobMatch(b *testing.B) {
 		globMatch([]byte("hellabcdlo"), []byte("h*lo"), false)
 	}
 }
+
+func TestCompress(t *testing.T) {
+	ctx := ContextTest("compress", "on", "1024")
+	Call(ctx)
+	assert.Contains(t, ctxString(ctx.Out), "OK")
+	assert.Equal(t, 1024, ctx.Client.CompressionThreshold)
+
+	ctx2 := ContextTest("compress", "on")
+	ctx2.Client = ctx.Client
+	Call(ctx2)
+	assert.Contains(t, ctxString(ctx2.Out), "OK")
+	assert.Equal(t, DefaultCompressionThreshold, ctx.Client.CompressionThreshold)
+
+	ctx3 := ContextTest("compress", "off")
+	ctx3.Client = ctx.Client
+	Call(ctx3)
+	assert.Contains(t, ctxString(ctx3.Out), "OK")
+	assert.Equal(t, 0, ctx.Client.CompressionThreshold)
+
+	ctx4 := ContextTest("compress", "on", "notanumber")
+	Call(ctx4)
+	assert.Contains(t, ctxString(ctx4.Out), "ERR")
+
+	ctx5 := ContextTest("compress", "bogus")
+	Call(ctx5)
+	assert.Contains(t, ctxString(ctx5.Out), "ERR")
+}
+
+func TestFastPath(t *testing.T) {
+	ctx := ContextTest("ping")
+	assert.True(t, FastPath(ctx))
+	assert.Equal(t, "PONG", ctxLines(ctx.Out)[1])
+
+	ctx = ContextTest("echo", "hello")
+	assert.True(t, FastPath(ctx))
+	assert.Equal(t, "hello", ctxLines(ctx.Out)[1])
+
+	// a wrong arity falls back to the full path and its error reply
+	ctx = ContextTest("echo")
+	assert.False(t, FastPath(ctx))
+
+	// only keepalive style commands qualify
+	ctx = ContextTest("get", "key")
+	assert.False(t, FastPath(ctx))
+
+	// queued multi commands keep the full semantics
+	ctx = ContextTest("ping")
+	ctx.Client.Multi = true
+	assert.False(t, FastPath(ctx))
+
+	// an unauthenticated client is handled by the full path
+	ctx = ContextTest("ping")
+	ctx.Server.RequirePass = "secret"
+	assert.False(t, FastPath(ctx))
+}
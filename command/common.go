@@ -224,6 +224,9 @@ func getFloatAndInclude(strf string) (float64, bool, error) {
 	var f float64
 	include := true
 	var err error
+	if len(strf) == 0 {
+		return f, include, ErrMinOrMaxNotFloat
+	}
 	lowerStrf := strings.ToLower(strf)
 	if lowerStrf[0] == '(' {
 		include = false
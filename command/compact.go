@@ -0,0 +1,39 @@
+package command
+
+import (
+	"strconv"
+
+	"github.com/distributedio/titan/db"
+	"github.com/distributedio/titan/encoding/resp"
+)
+
+// Compact rewrites a collection under a fresh object id and swaps the meta
+// atomically, COMPACT <namespace> <dbid> <key> replies the number of data
+// keys copied. It shrinks the sparse data range left behind by heavy
+// ZREM/ZADD style churn and is restricted to operators since it can move a
+// lot of data of any tenant
+func Compact(ctx *Context) {
+	if ctx.Client.Namespace != sysAdminNamespace {
+		resp.ReplyError(ctx.Out, "ERR compact can be used by $sys.admin only")
+		return
+	}
+	id, err := strconv.Atoi(ctx.Args[1])
+	if err != nil || id < 0 {
+		resp.ReplyError(ctx.Out, ErrInteger.Error())
+		return
+	}
+	d := ctx.Server.Store.DB(ctx.Args[0], id)
+	copied, err := db.CompactKey(d, []byte(ctx.Args[2]))
+	if err != nil {
+		switch err {
+		case db.ErrKeyNotFound:
+			resp.ReplyError(ctx.Out, "ERR no such key")
+		case db.ErrTypeMismatch:
+			resp.ReplyError(ctx.Out, "ERR string keys have no data range to compact")
+		default:
+			resp.ReplyError(ctx.Out, "ERR "+err.Error())
+		}
+		return
+	}
+	resp.ReplyInteger(ctx.Out, copied)
+}
@@ -2,31 +2,63 @@ package command
 
 import (
 	"strconv"
+	"strings"
 
+	"github.com/distributedio/titan/db"
 	"github.com/distributedio/titan/encoding/resp"
 	"github.com/distributedio/titan/metrics"
+	"go.uber.org/zap"
 )
 
 // Auth verifies the client
 func Auth(ctx *Context) {
-	args := ctx.Args
-	serverauth := []byte(ctx.Server.RequirePass)
-	if len(serverauth) == 0 {
+	provider := ctx.Server.AuthProvider
+	if provider == nil && ctx.Server.RequirePass != "" {
+		provider = NewHMACAuth(ctx.Server.RequirePass)
+	}
+	if provider == nil {
 		resp.ReplyError(ctx.Out, "ERR Client sent AUTH, but no password is set")
 		return
 	}
 
-	token := []byte(args[0])
-	namespace, err := Verify(token, serverauth)
+	namespace, err := provider.Authenticate(ctx.Args[0])
 	if err != nil {
 		resp.ReplyError(ctx.Out, "ERR invalid password")
 		return
 	}
-	metrics.GetMetrics().ConnectionOnlineGaugeVec.WithLabelValues(ctx.Client.Namespace).Dec()
-	metrics.GetMetrics().ConnectionOnlineGaugeVec.WithLabelValues(string(namespace)).Inc()
-	ctx.Client.Namespace = string(namespace)
-	ctx.Client.DB.Namespace = string(namespace)
+	// the system keyspace holds limiter and other internal data, regular
+	// clients may not authenticate into it even with a valid token
+	if namespace != sysAdminNamespace && db.IsReservedNamespace(namespace) {
+		resp.ReplyError(ctx.Out, ErrReservedNamespace.Error())
+		return
+	}
+	// the key separator and the generation tag would let keys of this
+	// namespace alias another one's
+	if !db.ValidNamespaceName(namespace) {
+		resp.ReplyError(ctx.Out, ErrInvalidNamespace.Error())
+		return
+	}
+	metrics.GetMetrics().ConnectionOnlineGaugeVec.WithLabelValues(metrics.NamespaceLabel(ctx.Client.Namespace)).Dec()
+	metrics.GetMetrics().ConnectionOnlineGaugeVec.WithLabelValues(metrics.NamespaceLabel(namespace)).Inc()
+	ctx.Client.Namespace = namespace
+	ctx.Client.DB.Namespace = namespace
+	// a namespace pinned to a db range starts inside it, the index kept
+	// from before the auth may not be allowed anymore
+	if !dbIndexAllowed(namespace, int(ctx.Client.DB.ID)) {
+		ctx.Client.DB = ctx.Server.Store.DB(namespace, defaultDBIndex(namespace))
+	}
 	ctx.Client.Authenticated = true
+	// operator connections are never throttled, diagnosing a node must
+	// work even when its user traffic is being limited. Re-authing into
+	// a user namespace drops the exemption again
+	ctx.Client.System = namespace == sysAdminNamespace
+	// a namespace seen for the first time is registered with a fresh id,
+	// a registry hiccup never fails the auth itself
+	if ctx.Server.NamespaceReg != nil && !db.IsReservedNamespace(namespace) {
+		if _, err := ctx.Server.NamespaceReg.Bootstrap(namespace); err != nil {
+			zap.L().Error("namespace bootstrap failed", zap.String("namespace", namespace), zap.Error(err))
+		}
+	}
 	resp.ReplySimpleString(ctx.Out, OK)
 }
 
@@ -58,6 +90,10 @@ func Select(ctx *Context) {
 		return
 	}
 	namespace := ctx.Client.Namespace
+	if !dbIndexAllowed(namespace, idx) {
+		resp.ReplyError(ctx.Out, "ERR DB index is out of range for your namespace")
+		return
+	}
 	ctx.Client.DB = ctx.Server.Store.DB(namespace, idx)
 	resp.ReplySimpleString(ctx.Out, OK)
 }
@@ -72,3 +108,34 @@ func Quit(ctx *Context) {
 func SwapDB(ctx *Context) {
 	resp.ReplyError(ctx.Out, "ERR not supported")
 }
+
+// DefaultCompressionThreshold is used when compress on is called
+// without an explicit threshold
+const DefaultCompressionThreshold = 4096
+
+// Compress negotiates transparent lz4 compression of replies on this
+// connection, replies of at least threshold bytes are sent as self
+// contained lz4 frames which the client tells apart from plain RESP
+// by the frame magic number
+func Compress(ctx *Context) {
+	switch strings.ToLower(ctx.Args[0]) {
+	case "on":
+		threshold := DefaultCompressionThreshold
+		if len(ctx.Args) > 1 {
+			v, err := strconv.Atoi(ctx.Args[1])
+			if err != nil || v <= 0 {
+				resp.ReplyError(ctx.Out, "ERR value is not an integer or out of range")
+				return
+			}
+			threshold = v
+		}
+		// reply before enabling so the OK itself is never compressed
+		resp.ReplySimpleString(ctx.Out, OK)
+		ctx.Client.CompressionThreshold = threshold
+	case "off":
+		ctx.Client.CompressionThreshold = 0
+		resp.ReplySimpleString(ctx.Out, OK)
+	default:
+		resp.ReplyError(ctx.Out, "ERR syntax error")
+	}
+}
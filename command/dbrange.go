@@ -0,0 +1,71 @@
+package command
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// namespaceDBRanges maps a namespace to the db index range its clients may
+// SELECT, set once at startup. A namespace without an entry may select any
+// index, the operator namespace is never restricted
+var namespaceDBRanges map[string][2]int
+
+// SetNamespaceDBRanges applies a namespace-db-range spec before the server
+// starts serving, pinning namespaces to specific db index ranges so a
+// tenant cannot wander into indexes reserved for something else, like db 0
+// of the limiter namespace. The spec is a comma separated list of
+// namespace=min-max entries, a single index works too
+func SetNamespaceDBRanges(spec string) error {
+	ranges := make(map[string][2]int)
+	for _, entry := range strings.Split(spec, ",") {
+		if entry = strings.TrimSpace(entry); entry == "" {
+			continue
+		}
+		parts := strings.SplitN(entry, "=", 2)
+		if len(parts) != 2 || strings.TrimSpace(parts[0]) == "" {
+			return fmt.Errorf("invalid namespace-db-range entry(%s), expect namespace=min-max", entry)
+		}
+		bounds := strings.SplitN(parts[1], "-", 2)
+		min, err := strconv.Atoi(strings.TrimSpace(bounds[0]))
+		if err != nil {
+			return fmt.Errorf("invalid namespace-db-range entry(%s), expect namespace=min-max", entry)
+		}
+		max := min
+		if len(bounds) == 2 {
+			if max, err = strconv.Atoi(strings.TrimSpace(bounds[1])); err != nil {
+				return fmt.Errorf("invalid namespace-db-range entry(%s), expect namespace=min-max", entry)
+			}
+		}
+		if min < 0 || max > 255 || min > max {
+			return fmt.Errorf("namespace-db-range entry(%s) out of the 0-255 db index range", entry)
+		}
+		ranges[strings.TrimSpace(parts[0])] = [2]int{min, max}
+	}
+	if len(ranges) == 0 {
+		ranges = nil
+	}
+	namespaceDBRanges = ranges
+	return nil
+}
+
+// dbIndexAllowed reports whether the namespace may use the db index
+func dbIndexAllowed(namespace string, idx int) bool {
+	if namespace == sysAdminNamespace {
+		return true
+	}
+	r, ok := namespaceDBRanges[namespace]
+	if !ok {
+		return true
+	}
+	return idx >= r[0] && idx <= r[1]
+}
+
+// defaultDBIndex returns the db index a fresh connection of the namespace
+// starts at, the lower bound of a pinned range and 0 otherwise
+func defaultDBIndex(namespace string) int {
+	if r, ok := namespaceDBRanges[namespace]; ok && namespace != sysAdminNamespace {
+		return r[0]
+	}
+	return 0
+}
@@ -0,0 +1,42 @@
+package command
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSetNamespaceDBRanges(t *testing.T) {
+	assert.Error(t, SetNamespaceDBRanges("tenant1"))
+	assert.Error(t, SetNamespaceDBRanges("tenant1=a-b"))
+	assert.Error(t, SetNamespaceDBRanges("tenant1=3-1"))
+	assert.Error(t, SetNamespaceDBRanges("tenant1=0-300"))
+
+	assert.NoError(t, SetNamespaceDBRanges("tenant1=1-3, tenant2=5"))
+	defer SetNamespaceDBRanges("")
+
+	assert.False(t, dbIndexAllowed("tenant1", 0))
+	assert.True(t, dbIndexAllowed("tenant1", 2))
+	assert.False(t, dbIndexAllowed("tenant1", 4))
+	assert.True(t, dbIndexAllowed("tenant2", 5))
+	assert.False(t, dbIndexAllowed("tenant2", 0))
+	assert.True(t, dbIndexAllowed("unpinned", 200))
+	assert.True(t, dbIndexAllowed(sysAdminNamespace, 0))
+	assert.Equal(t, 1, defaultDBIndex("tenant1"))
+	assert.Equal(t, 0, defaultDBIndex("unpinned"))
+}
+
+func TestSelectRestricted(t *testing.T) {
+	assert.NoError(t, SetNamespaceDBRanges("seltenant=2-4"))
+	defer SetNamespaceDBRanges("")
+
+	ctx := ContextTest("select", "3")
+	ctx.Client.Namespace = "seltenant"
+	Call(ctx)
+	assert.Equal(t, "+OK", ctxLines(ctx.Out)[0])
+
+	ctx = ContextTest("select", "0")
+	ctx.Client.Namespace = "seltenant"
+	Call(ctx)
+	assert.Contains(t, ctxLines(ctx.Out)[0], "-ERR DB index is out of range")
+}
@@ -0,0 +1,60 @@
+package command
+
+import (
+	"strings"
+
+	"github.com/distributedio/titan/encoding/resp"
+)
+
+// The errcode command opts a connection into a machine readable error
+// taxonomy. Errors that map to a known failure class are replied as
+// -TITAN:CODE <original message>, so client SDKs can pick a retry policy
+// from the code instead of matching message strings. Connections that do
+// not opt in keep receiving the unprefixed messages
+
+// ErrCode toggles the error code taxonomy on this connection
+func ErrCode(ctx *Context) {
+	switch strings.ToLower(ctx.Args[0]) {
+	case "on":
+		ctx.Client.ErrorCodes = true
+		resp.ReplySimpleString(ctx.Out, OK)
+	case "off":
+		ctx.Client.ErrorCodes = false
+		resp.ReplySimpleString(ctx.Out, OK)
+	default:
+		resp.ReplyError(ctx.Out, "ERR syntax error")
+	}
+}
+
+// errorCode classifies an error message into the taxonomy, an empty code
+// means the error has no machine readable class
+func errorCode(msg string) string {
+	switch {
+	case strings.HasPrefix(msg, "READONLY"):
+		return "READONLY"
+	case strings.HasPrefix(msg, "BUSY"):
+		return "RATE_LIMITED"
+	case strings.HasPrefix(msg, "TRYAGAIN"):
+		return "STORE_UNAVAILABLE"
+	}
+	lower := strings.ToLower(msg)
+	switch {
+	case strings.Contains(lower, "timeout"), strings.Contains(lower, "timed out"):
+		return "STORE_TIMEOUT"
+	case strings.Contains(lower, "conflict"), strings.Contains(lower, "try again later"):
+		return "CONFLICT"
+	}
+	return ""
+}
+
+// replyError writes an error to the client, prefixed with its taxonomy
+// code when the connection opted in with errcode on
+func replyError(ctx *Context, msg string) {
+	if ctx.Client != nil && ctx.Client.ErrorCodes {
+		if code := errorCode(msg); code != "" {
+			resp.ReplyError(ctx.Out, "TITAN:"+code+" "+msg)
+			return
+		}
+	}
+	resp.ReplyError(ctx.Out, msg)
+}
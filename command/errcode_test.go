@@ -0,0 +1,52 @@
+package command
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestErrorCode(t *testing.T) {
+	assert.Equal(t, "READONLY", errorCode(ErrReadOnly.Error()))
+	assert.Equal(t, "RATE_LIMITED", errorCode(ErrTxnPoolTimeout.Error()))
+	assert.Equal(t, "STORE_UNAVAILABLE", errorCode(ErrTryAgain.Error()))
+	assert.Equal(t, "STORE_TIMEOUT", errorCode("ERR tikv request timeout"))
+	assert.Equal(t, "CONFLICT", errorCode("ERR write conflict, txn aborted"))
+	assert.Equal(t, "", errorCode(ErrSyntax.Error()))
+	assert.Equal(t, "", errorCode(ErrTypeMismatch.Error()))
+}
+
+func TestErrCodeCommand(t *testing.T) {
+	ctx := ContextTest("errcode", "on")
+	Call(ctx)
+	assert.Equal(t, "+OK", ctxLines(ctx.Out)[0])
+	assert.True(t, ctx.Client.ErrorCodes)
+
+	// an opted in connection receives the taxonomy prefix
+	cli := ctx.Client
+	ctx = ContextTest("get", "errcode-key")
+	ctx.Client = cli
+	replyError(ctx, ErrReadOnly.Error())
+	assert.Equal(t, "-TITAN:READONLY "+ErrReadOnly.Error(), ctxLines(ctx.Out)[0])
+
+	// unclassified errors stay untouched
+	ctx = ContextTest("get", "errcode-key")
+	ctx.Client = cli
+	replyError(ctx, ErrSyntax.Error())
+	assert.Equal(t, "-"+ErrSyntax.Error(), ctxLines(ctx.Out)[0])
+
+	ctx = ContextTest("errcode", "off")
+	ctx.Client = cli
+	Call(ctx)
+	assert.Equal(t, "+OK", ctxLines(ctx.Out)[0])
+	assert.False(t, cli.ErrorCodes)
+
+	ctx = ContextTest("get", "errcode-key")
+	ctx.Client = cli
+	replyError(ctx, ErrReadOnly.Error())
+	assert.Equal(t, "-"+ErrReadOnly.Error(), ctxLines(ctx.Out)[0])
+
+	ctx = ContextTest("errcode", "maybe")
+	Call(ctx)
+	assert.Equal(t, "-ERR syntax error", ctxLines(ctx.Out)[0])
+}
@@ -28,6 +28,18 @@ var (
 	// ErrNoAuth authentication required
 	ErrNoAuth = errors.New("NOAUTH Authentication required")
 
+	// ErrReadOnly the namespace is in read-only maintenance mode
+	ErrReadOnly = errors.New("READONLY You can't write against a read only namespace")
+
+	// ErrTryAgain the tikv circuit breaker is open
+	ErrTryAgain = errors.New("TRYAGAIN tikv is temporarily unavailable, retry later")
+
+	// ErrReservedNamespace the namespace belongs to the system keyspace
+	ErrReservedNamespace = errors.New("ERR namespace is reserved for system use")
+
+	// ErrInvalidNamespace the namespace name would make keys ambiguous
+	ErrInvalidNamespace = errors.New("ERR namespace name must not contain ':' or '@'")
+
 	// ErrAuthInvalid invalid password
 	ErrAuthInvalid = errors.New("ERR invalid password")
 
@@ -58,6 +70,9 @@ var (
 	// ErrBitOffset bit offset is not an integer or out of range
 	ErrBitOffset = errors.New("ERR bit offset is not an integer or out of range")
 
+	// ErrCrossSlot multi-key command keys hash to different cluster slots
+	ErrCrossSlot = errors.New("CROSSSLOT Keys in request don't hash to the same slot")
+
 	//ErrBitOp not must be called with a single source key.
 	ErrBitOp = errors.New("BITOP NOT must be called with a single source key.")
 
@@ -82,6 +97,15 @@ var (
 	//ErrMaximum allows the maximum size of a string
 	ErrMaximum = errors.New("ERR string exceeds maximum allowed size")
 
+	// ErrReplyBytes reply grew beyond the configured max-reply-bytes
+	ErrReplyBytes = errors.New("ERR reply exceeds the maximum allowed bytes")
+
+	// ErrRequestBytes request allocations grew beyond the configured max-request-bytes
+	ErrRequestBytes = errors.New("ERR request exceeds the maximum allowed memory")
+
+	// ErrLexRange a lex range bound is not prefixed with [ or ( and is not - or +
+	ErrLexRange = errors.New("ERR min or max not valid string range item")
+
 	// ErrMultiNested indicates a nested multi command which is not allowed
 	ErrMultiNested = errors.New("ERR MULTI calls can not be nested")
 
@@ -101,6 +125,9 @@ var (
 
 	//argument min or max isn't float
 	ErrMinOrMaxNotFloat = errors.New("ERR min or max is not a float")
+
+	//ErrTxnPoolTimeout server is too busy to start a new transaction
+	ErrTxnPoolTimeout = errors.New("BUSY too many concurrent transactions, try again later")
 )
 
 //ErrUnKnownCommand return RedisError of the cmd
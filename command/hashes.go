@@ -116,6 +116,12 @@ func HGetAll(ctx *Context, txn *db.Transaction) (OnCommit, error) {
 		results = append(results, vals[i])
 	}
 
+	if err := checkReplySize(ctx, len(results)); err != nil {
+		return nil, err
+	}
+	if err := accountReplySize(ctx, results); err != nil {
+		return nil, err
+	}
 	return BytesArray(ctx.Out, results), nil
 }
 
@@ -383,7 +389,7 @@ func HScan(ctx *Context, txn *db.Transaction) (OnCommit, error) {
 			}
 		case "match":
 			pattern = []byte(next)
-			isAll = (pattern[0] == '*' && len(pattern) == 1)
+			isAll = (len(pattern) == 1 && pattern[0] == '*')
 		}
 	}
 
@@ -0,0 +1,96 @@
+package command
+
+import (
+	"io"
+	"strings"
+
+	"github.com/distributedio/titan/encoding/resp"
+)
+
+// subcommandHelp holds the HELP text of every container command, keyed by
+// the lowercase command name. Each entry describes one subcommand in the
+// redis "SUBCOMMAND -- description" form, the header and the trailing HELP
+// line are generated
+var subcommandHelp = map[string][]string{
+	"client": {
+		"GETNAME -- Return the name of the current connection.",
+		"KILL [ADDR addr] [ID id] [TYPE type] [SKIPME yes/no] -- Kill connections matching the filters, an old style single address is accepted too.",
+		"LIST -- Return information about client connections of your namespace.",
+		"PAUSE <timeout-ms> [WRITE|ALL] -- Suspend commands of all clients for the given time.",
+		"UNPAUSE -- Lift a pause set before its timeout.",
+		"REPLY (ON|OFF|SKIP) -- Control the replies sent to the current connection.",
+		"SETNAME <name> -- Assign the name to the current connection.",
+	},
+	"cluster": {
+		"NODES -- Return the titan nodes registered in the store, one per line.",
+		"INFO -- Return information about the cluster in the redis INFO format.",
+	},
+	"command": {
+		"(no subcommand) -- Return details about all commands.",
+		"COUNT -- Return the total number of commands in this server.",
+		"GETKEYS <full-command> -- Return the keys the given command would touch.",
+		"INFO [command-name ...] -- Return details about the given commands.",
+	},
+	"debug": {
+		"BREAKER (STATUS|OPEN|CLOSE|AUTO) -- Inspect or override the tikv circuit breaker, overrides are for operators only.",
+		"COMMITTS <key> -- Return the tikv commit version of the last write to the key, nil when it does not exist.",
+		"DIFF <from-ts> <to-ts> [cursor [count]] -- Report keys added, removed or changed between two tikv versions of your db, NOW pins the current version.",
+		"LAYOUT [cursor [count]] -- Verify the metas of your db against the documented storage layout, reporting keys in unknown encodings.",
+		"OBJECT <key> -- Return low level info about the object backing key.",
+		"QUICKCHECK <key> [...] -- Verify the store level consistency of the given keys.",
+		"TRACE <namespace> <every> -- Sample one of every N commands of the namespace to the debug log, 0 turns it off.",
+		"TTLHISTORY (<namespace> <depth>|<key>) -- Journal the last <depth> ttl mutations per key of a namespace, or list the journal of a key.",
+	},
+	"object": {
+		"ENCODING <key> -- Return the kind of internal representation used in order to store the value associated with a key.",
+		"FREQ <key> -- Return the access frequency index of the key. The returned integer is proportional to the logarithm of the recent access frequency of the key.",
+		"IDLETIME <key> -- Return the idle time of the key, that is the approximated number of seconds elapsed since the last access to the key.",
+		"REFCOUNT <key> -- Return the number of references of the value associated with the specified key.",
+	},
+	"limit": {
+		"SET <rule> <limit> <burst> -- Write a limit rule, <limit> accepts a K/M suffix for rate rules.",
+		"GET <rule> -- Return the value of a limit rule, nil when it is not set.",
+		"DEL <rule> -- Remove a limit rule, return how many string keys were deleted.",
+	},
+	"namespace": {
+		"USAGE -- Report key count, approximate bytes and the configured limits of your namespace.",
+		"READONLY <namespace> (ON|OFF) -- Switch the read-only maintenance mode of the namespace, operators only.",
+		"LIST -- List the registered namespaces with their id, registration time and flags, operators only.",
+	},
+	"rewrite": {
+		"START <namespace> [ops-per-sec] -- Start a background rewrite of the namespace.",
+		"PAUSE -- Suspend the running rewrite job.",
+		"RESUME -- Resume a paused rewrite job.",
+		"STATUS -- Return the state of the current rewrite job.",
+	},
+}
+
+// subcommandHelpLines assembles the full HELP reply of a container command,
+// it reports false when the command has no help registered
+func subcommandHelpLines(name string) ([][]byte, bool) {
+	entries, ok := subcommandHelp[name]
+	if !ok {
+		return nil, false
+	}
+	lines := make([][]byte, 0, len(entries)+2)
+	lines = append(lines, []byte(strings.ToUpper(name)+" <subcommand> arg arg ... arg. Subcommands are:"))
+	for _, entry := range entries {
+		lines = append(lines, []byte(entry))
+	}
+	lines = append(lines, []byte("HELP -- Print this help."))
+	return lines, true
+}
+
+// replySubcommandHelp writes the HELP array of a container command directly,
+// for dispatchers that reply outside of a transaction
+func replySubcommandHelp(w io.Writer, name string) {
+	lines, ok := subcommandHelpLines(name)
+	if !ok {
+		resp.ReplyError(w, "ERR no help entries for '"+name+"'")
+		return
+	}
+	resp.ReplyArray(w, len(lines))
+	for _, line := range lines {
+		resp.ReplyBulkString(w, string(line))
+	}
+}
@@ -0,0 +1,30 @@
+package command
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSubcommandHelp(t *testing.T) {
+	// CLIENT replies outside of a transaction
+	ctx := ContextTest("client", "help")
+	Call(ctx)
+	lines := ctxLines(ctx.Out)
+	assert.Equal(t, "*"+strconv.Itoa(len(subcommandHelp["client"])+2), lines[0])
+	assert.Equal(t, "CLIENT <subcommand> arg arg ... arg. Subcommands are:", lines[2])
+	assert.Equal(t, "HELP -- Print this help.", lines[len(lines)-2])
+
+	// OBJECT replies through the transactional path
+	ctx = ContextTest("object", "help")
+	Call(ctx)
+	lines = ctxLines(ctx.Out)
+	assert.Equal(t, "*"+strconv.Itoa(len(subcommandHelp["object"])+2), lines[0])
+	assert.Equal(t, "OBJECT <subcommand> arg arg ... arg. Subcommands are:", lines[2])
+
+	ctx = ContextTest("command", "help")
+	Call(ctx)
+	lines = ctxLines(ctx.Out)
+	assert.Equal(t, "COMMAND <subcommand> arg arg ... arg. Subcommands are:", lines[2])
+}
@@ -27,17 +27,20 @@ func init() {
 		"append":   Append,
 		"getset":   GetSet,
 		"getrange": GetRange,
+		"strpatch": StrPatch,
+		"cas":      Cas,
+		"cad":      Cad,
 		// "msetnx":   MSetNx,
 		"setnx":  SetNx,
 		"setex":  SetEx,
 		"psetex": PSetEx,
 		//"setrange": SetRange,
-		//"setbit":   SetBit,
+		"setbit": SetBit,
 		// "bitop":       BitOp,
 		// "bitfield":    BitField,
-		//"getbit":      GetBit,
-		//"bitpos":      BitPos,
-		//"bitcount":    BitCount,
+		"getbit":      GetBit,
+		"bitpos":      BitPos,
+		"bitcount":    BitCount,
 		"incr":        Incr,
 		"incrby":      IncrBy,
 		"decr":        Decr,
@@ -45,22 +48,29 @@ func init() {
 		"incrbyfloat": IncrByFloat,
 
 		// keys
-		"type":      Type,
-		"exists":    Exists,
-		"keys":      Keys,
-		"del":       Delete,
-		"expire":    Expire,
-		"expireat":  ExpireAt,
-		"pexpire":   PExpire,
-		"pexpireat": PExpireAt,
-		"persist":   Persist,
-		"ttl":       TTL,
-		"pttl":      PTTL,
-		"object":    Object,
-		"scan":      Scan,
-		"randomkey": RandomKey,
+		"type":        Type,
+		"exists":      Exists,
+		"keys":        Keys,
+		"del":         Delete,
+		"expire":      Expire,
+		"expireat":    ExpireAt,
+		"pexpire":     PExpire,
+		"pexpireat":   PExpireAt,
+		"persist":     Persist,
+		"ttl":         TTL,
+		"pttl":        PTTL,
+		"ttlmulti":    TTLMulti,
+		"expiretime":  ExpireTime,
+		"pexpiretime": PExpireTime,
+		"mexpire":     MExpire,
+		"object":      Object,
+		"scan":        Scan,
+		"randomkey":   RandomKey,
+		"mclaim":      MClaim,
 
 		// server
+		"cluster":  Cluster,
+		"rewrite":  Rewrite,
 		"debug":    Debug,
 		"flushdb":  FlushDB,
 		"flushall": FlushAll,
@@ -95,25 +105,58 @@ func init() {
 		"smove":     SMove,
 
 		//zsets(sorted sets)
-		"zadd":          ZAdd,
-		"zrange":        ZRange,
-		"zrevrange":     ZRevRange,
-		"zrangebyscore": ZRangeByScore,
-		"ZRem":          ZRem,
-		"zcard":         ZCard,
-		"zscore":        ZScore,
+		"zadd":             ZAdd,
+		"zincrby":          ZIncrBy,
+		"zrange":           ZRange,
+		"zrevrange":        ZRevRange,
+		"zrangebyscore":    ZRangeByScore,
+		"zrevrangebyscore": ZRevRangeByScore,
+		"zrangebylex":      ZRangeByLex,
+		"zrevrangebylex":   ZRevRangeByLex,
+		"zcount":           ZCount,
+		"zlexcount":        ZLexCount,
+		"ZRem":             ZRem,
+		"zcard":            ZCard,
+		"zscore":           ZScore,
+		"zmscore":          ZMScore,
+		"zrandmember":      ZRandMember,
+		"zexpiremember":    ZExpireMember,
+
+		// sketches
+		"cms.initbydim": CMSInitByDim,
+		"cms.incrby":    CMSIncrBy,
+		"cms.query":     CMSQuery,
+		"cms.info":      CMSInfo,
+		"topk.reserve":  TopKReserve,
+		"topk.add":      TopKAdd,
+		"topk.query":    TopKQuery,
+		"topk.count":    TopKCount,
+		"topk.list":     TopKList,
+		"topk.info":     TopKInfo,
+
+		// time series
+		"ts.add":    TSAdd,
+		"ts.incrby": TSIncrBy,
+		"ts.range":  TSRange,
+
+		// sequences
+		"seq.next": SeqNext,
+		"seq.set":  SeqSet,
+		"seq.get":  SeqGet,
 	}
 
 	// commands contains all commands that open to clients
 	// exec should not be in this table to avoid 'initialization loop', and it indeed not necessary be here in fact.
 	commands = map[string]Desc{
 		// connections
-		"auth":   Desc{Proc: Auth, Cons: Constraint{2, flags("sltF"), 0, 0, 0}},
-		"echo":   Desc{Proc: Echo, Cons: Constraint{2, flags("F"), 0, 0, 0}},
-		"ping":   Desc{Proc: Ping, Cons: Constraint{-1, flags("tF"), 0, 0, 0}},
-		"quit":   Desc{Proc: Quit, Cons: Constraint{1, 0, 0, 0, 0}},
-		"select": Desc{Proc: Select, Cons: Constraint{2, flags("lF"), 0, 0, 0}},
-		"swapdb": Desc{Proc: SwapDB, Cons: Constraint{3, flags("wF"), 0, 0, 0}},
+		"auth":     Desc{Proc: Auth, Cons: Constraint{2, flags("sltF"), 0, 0, 0}},
+		"echo":     Desc{Proc: Echo, Cons: Constraint{2, flags("F"), 0, 0, 0}},
+		"ping":     Desc{Proc: Ping, Cons: Constraint{-1, flags("tF"), 0, 0, 0}},
+		"quit":     Desc{Proc: Quit, Cons: Constraint{1, 0, 0, 0, 0}},
+		"select":   Desc{Proc: Select, Cons: Constraint{2, flags("lF"), 0, 0, 0}},
+		"swapdb":   Desc{Proc: SwapDB, Cons: Constraint{3, flags("wF"), 0, 0, 0}},
+		"compress": Desc{Proc: Compress, Cons: Constraint{-2, flags("sltF"), 0, 0, 0}},
+		"errcode":  Desc{Proc: ErrCode, Cons: Constraint{2, flags("sltF"), 0, 0, 0}},
 
 		// transactions, exec and discard should called explicitly, so they are registered here
 		"multi":   Desc{Proc: Multi, Cons: Constraint{1, flags("sF"), 0, 0, 0}},
@@ -121,6 +164,11 @@ func init() {
 		"discard": Desc{Proc: Discard, Cons: Constraint{1, flags("sF"), 0, 0, 0}},
 		"watch":   Desc{Proc: Watch, Cons: Constraint{-2, flags("sF"), 1, -1, 1}},
 		"unwatch": Desc{Proc: Unwatch, Cons: Constraint{1, flags("sF"), 0, 0, 0}},
+		// explicit transactions, a titan extension
+		"txn.begin":    Desc{Proc: TxnBegin, Cons: Constraint{1, flags("sF"), 0, 0, 0}},
+		"txn.exec":     Desc{Proc: TxnExec, Cons: Constraint{-2, flags("s"), 0, 0, 0}},
+		"txn.commit":   Desc{Proc: TxnCommit, Cons: Constraint{1, flags("s"), 0, 0, 0}},
+		"txn.rollback": Desc{Proc: TxnRollback, Cons: Constraint{1, flags("sF"), 0, 0, 0}},
 
 		// lists
 		"lindex":  Desc{Proc: AutoCommit(LIndex), Cons: Constraint{3, flags("r"), 1, 1, 1}},
@@ -139,6 +187,7 @@ func init() {
 		"set":    Desc{Proc: AutoCommit(Set), Cons: Constraint{-3, flags("wm"), 1, 1, 1}},
 		"setnx":  Desc{Proc: AutoCommit(SetNx), Cons: Constraint{3, flags("wmF"), 1, 1, 1}},
 		"setex":  Desc{Proc: AutoCommit(SetEx), Cons: Constraint{4, flags("wm"), 1, 1, 1}},
+		"getset": Desc{Proc: AutoCommit(GetSet), Cons: Constraint{3, flags("wm"), 1, 1, 1}},
 		"psetex": Desc{Proc: AutoCommit(PSetEx), Cons: Constraint{4, flags("wm"), 1, 1, 1}},
 		"mget":   Desc{Proc: AutoCommit(MGet), Cons: Constraint{-2, flags("rF"), 1, -1, 1}},
 		"mset":   Desc{Proc: AutoCommit(MSet), Cons: Constraint{-3, flags("wm"), 1, -1, 2}},
@@ -147,43 +196,56 @@ func init() {
 		"append": Desc{Proc: AutoCommit(Append), Cons: Constraint{3, flags("wm"), 1, 1, 1}},
 		//"setrange":    Desc{Proc: AutoCommit(SetRange), Cons: Constraint{4, flags("wm"), 1, 1, 1}}, //run test in tests/redis/unit/type/string failed
 		"getrange":    Desc{Proc: AutoCommit(GetRange), Cons: Constraint{4, flags("r"), 1, 1, 1}},
+		"strpatch":    Desc{Proc: AutoCommit(StrPatch), Cons: Constraint{-4, flags("wm"), 1, 1, 1}},
+		"cas":         Desc{Proc: AutoCommit(Cas), Cons: Constraint{4, flags("wm"), 1, 1, 1}},
+		"cad":         Desc{Proc: AutoCommit(Cad), Cons: Constraint{3, flags("wm"), 1, 1, 1}},
 		"incr":        Desc{Proc: AutoCommit(Incr), Cons: Constraint{2, flags("wmF"), 1, 1, 1}},
 		"decr":        Desc{Proc: AutoCommit(Decr), Cons: Constraint{2, flags("wmF"), 1, 1, 1}},
 		"incrby":      Desc{Proc: AutoCommit(IncrBy), Cons: Constraint{3, flags("wmF"), 1, 1, 1}},
 		"decrby":      Desc{Proc: AutoCommit(DecrBy), Cons: Constraint{3, flags("wmF"), 1, 1, 1}},
 		"incrbyfloat": Desc{Proc: AutoCommit(IncrByFloat), Cons: Constraint{3, flags("wmF"), 1, 1, 1}},
-		//"setbit":      Desc{Proc: AutoCommit(SetBit), Cons: Constraint{4, flags("wm"), 1, 1, 1}},
+		"setbit":      Desc{Proc: AutoCommit(SetBit), Cons: Constraint{4, flags("wm"), 1, 1, 1}},
 		// "bitop":       Desc{Proc: AutoCommit(BitOp), Cons: Constraint{-4, flags("wm"), 2, -1, 1}},
 		// "bitfield":    Desc{Proc: AutoCommit(BitField), Cons: Constraint{-2, flags("wm"), 1, 1, 1}},
-		//"getbit":   Desc{Proc: AutoCommit(GetBit), Cons: Constraint{3, flags("r"), 1, 1, 1}},
-		//"bitcount": Desc{Proc: AutoCommit(BitCount), Cons: Constraint{-2, flags("r"), 1, 1, 1}},
-		//"bitpos":   Desc{Proc: AutoCommit(BitPos), Cons: Constraint{-3, flags("r"), 1, 1, 1}},
+		"getbit":   Desc{Proc: AutoCommit(GetBit), Cons: Constraint{3, flags("r"), 1, 1, 1}},
+		"bitcount": Desc{Proc: AutoCommit(BitCount), Cons: Constraint{-2, flags("r"), 1, 1, 1}},
+		"bitpos":   Desc{Proc: AutoCommit(BitPos), Cons: Constraint{-3, flags("r"), 1, 1, 1}},
 
 		// keys
-		"type":      Desc{Proc: AutoCommit(Type), Cons: Constraint{2, flags("rF"), 1, 1, 1}},
-		"exists":    Desc{Proc: AutoCommit(Exists), Cons: Constraint{-2, flags("rF"), 1, -1, 1}},
-		"keys":      Desc{Proc: AutoCommit(Keys), Cons: Constraint{-2, flags("rS"), 0, 0, 0}},
-		"del":       Desc{Proc: AutoCommit(Delete), Cons: Constraint{-2, flags("w"), 1, -1, 1}},
-		"expire":    Desc{Proc: AutoCommit(Expire), Cons: Constraint{3, flags("wF"), 1, 1, 1}},
-		"expireat":  Desc{Proc: AutoCommit(ExpireAt), Cons: Constraint{3, flags("wF"), 1, 1, 1}},
-		"pexpire":   Desc{Proc: AutoCommit(PExpire), Cons: Constraint{3, flags("wF"), 1, 1, 1}},
-		"pexpireat": Desc{Proc: AutoCommit(PExpireAt), Cons: Constraint{3, flags("wF"), 1, 1, 1}},
-		"persist":   Desc{Proc: AutoCommit(Persist), Cons: Constraint{2, flags("wF"), 1, 1, 1}},
-		"ttl":       Desc{Proc: AutoCommit(TTL), Cons: Constraint{2, flags("rF"), 1, 1, 1}},
-		"pttl":      Desc{Proc: AutoCommit(PTTL), Cons: Constraint{2, flags("rF"), 1, 1, 1}},
-		"object":    Desc{Proc: AutoCommit(Object), Cons: Constraint{-2, flags("rR"), 0, 0, 0}},
-		"scan":      Desc{Proc: AutoCommit(Scan), Cons: Constraint{-2, flags("rR"), 0, 0, 0}},
-		"randomkey": Desc{Proc: AutoCommit(RandomKey), Cons: Constraint{1, flags("rR"), 0, 0, 0}},
+		"type":        Desc{Proc: AutoCommit(Type), Cons: Constraint{2, flags("rF"), 1, 1, 1}},
+		"exists":      Desc{Proc: AutoCommit(Exists), Cons: Constraint{-2, flags("rF"), 1, -1, 1}},
+		"keys":        Desc{Proc: AutoCommit(Keys), Cons: Constraint{-2, flags("rS"), 0, 0, 0}},
+		"del":         Desc{Proc: AutoCommit(Delete), Cons: Constraint{-2, flags("w"), 1, -1, 1}},
+		"expire":      Desc{Proc: AutoCommit(Expire), Cons: Constraint{-3, flags("wF"), 1, 1, 1}},
+		"expireat":    Desc{Proc: AutoCommit(ExpireAt), Cons: Constraint{-3, flags("wF"), 1, 1, 1}},
+		"pexpire":     Desc{Proc: AutoCommit(PExpire), Cons: Constraint{-3, flags("wF"), 1, 1, 1}},
+		"pexpireat":   Desc{Proc: AutoCommit(PExpireAt), Cons: Constraint{-3, flags("wF"), 1, 1, 1}},
+		"persist":     Desc{Proc: AutoCommit(Persist), Cons: Constraint{2, flags("wF"), 1, 1, 1}},
+		"ttl":         Desc{Proc: AutoCommit(TTL), Cons: Constraint{2, flags("rF"), 1, 1, 1}},
+		"pttl":        Desc{Proc: AutoCommit(PTTL), Cons: Constraint{2, flags("rF"), 1, 1, 1}},
+		"ttlmulti":    Desc{Proc: AutoCommit(TTLMulti), Cons: Constraint{-2, flags("rF"), 1, -1, 1}},
+		"expiretime":  Desc{Proc: AutoCommit(ExpireTime), Cons: Constraint{2, flags("rF"), 1, 1, 1}},
+		"pexpiretime": Desc{Proc: AutoCommit(PExpireTime), Cons: Constraint{2, flags("rF"), 1, 1, 1}},
+		"mexpire":     Desc{Proc: AutoCommit(MExpire), Cons: Constraint{-3, flags("wF"), 2, -1, 1}},
+		"object":      Desc{Proc: AutoCommit(Object), Cons: Constraint{-2, flags("rR"), 0, 0, 0}},
+		"scan":        Desc{Proc: AutoCommit(Scan), Cons: Constraint{-2, flags("rR"), 0, 0, 0}},
+		"randomkey":   Desc{Proc: AutoCommit(RandomKey), Cons: Constraint{1, flags("rR"), 0, 0, 0}},
+		"mclaim":      Desc{Proc: AutoCommit(MClaim), Cons: Constraint{3, flags("wR"), 0, 0, 0}},
 
 		// server
-		"monitor":  Desc{Proc: Monitor, Cons: Constraint{1, flags("as"), 0, 0, 0}},
-		"client":   Desc{Proc: Client, Cons: Constraint{-2, flags("as"), 0, 0, 0}},
-		"debug":    Desc{Proc: AutoCommit(Debug), Cons: Constraint{-2, flags("as"), 0, 0, 0}},
-		"command":  Desc{Proc: RedisCommand, Cons: Constraint{0, flags("lt"), 0, 0, 0}},
-		"flushdb":  Desc{Proc: AutoCommit(FlushDB), Cons: Constraint{-1, flags("w"), 0, 0, 0}},
-		"flushall": Desc{Proc: AutoCommit(FlushAll), Cons: Constraint{-1, flags("w"), 0, 0, 0}},
-		"time":     Desc{Proc: Time, Cons: Constraint{1, flags("RF"), 0, 0, 0}},
-		"info":     Desc{Proc: Info, Cons: Constraint{-1, flags("lt"), 0, 0, 0}},
+		"monitor":   Desc{Proc: Monitor, Cons: Constraint{1, flags("as"), 0, 0, 0}},
+		"client":    Desc{Proc: Client, Cons: Constraint{-2, flags("as"), 0, 0, 0}},
+		"cluster":   Desc{Proc: AutoCommit(Cluster), Cons: Constraint{-2, flags("as"), 0, 0, 0}},
+		"rewrite":   Desc{Proc: AutoCommit(Rewrite), Cons: Constraint{-2, flags("as"), 0, 0, 0}},
+		"debug":     Desc{Proc: AutoCommit(Debug), Cons: Constraint{-2, flags("as"), 0, 0, 0}},
+		"command":   Desc{Proc: RedisCommand, Cons: Constraint{0, flags("lt"), 0, 0, 0}},
+		"flushdb":   Desc{Proc: AutoCommit(FlushDB), Cons: Constraint{-1, flags("w"), 0, 0, 0}},
+		"flushall":  Desc{Proc: AutoCommit(FlushAll), Cons: Constraint{-1, flags("w"), 0, 0, 0}},
+		"time":      Desc{Proc: Time, Cons: Constraint{1, flags("RF"), 0, 0, 0}},
+		"info":      Desc{Proc: Info, Cons: Constraint{-1, flags("lt"), 0, 0, 0}},
+		"namespace": Desc{Proc: Namespace, Cons: Constraint{-2, flags("lt"), 0, 0, 0}},
+		"compact":   Desc{Proc: Compact, Cons: Constraint{4, flags("as"), 0, 0, 0}},
+		"limit":     Desc{Proc: Limit, Cons: Constraint{-2, flags("as"), 0, 0, 0}},
 
 		// hashes
 		"hdel":         Desc{Proc: AutoCommit(HDel), Cons: Constraint{-3, flags("wF"), 1, 1, 1}},
@@ -215,12 +277,43 @@ func init() {
 		"smove":     Desc{Proc: AutoCommit(SMove), Cons: Constraint{4, flags("wF"), 1, 2, 1}},
 
 		// zsets
-		"zadd":          Desc{Proc: AutoCommit(ZAdd), Cons: Constraint{-4, flags("wmF"), 1, 1, 1}},
-		"zrange":        Desc{Proc: AutoCommit(ZRange), Cons: Constraint{-4, flags("rF"), 1, 1, 1}},
-		"zrevrange":     Desc{Proc: AutoCommit(ZRevRange), Cons: Constraint{-4, flags("rF"), 1, 1, 1}},
-		"zrangebyscore": {Proc: AutoCommit(ZRangeByScore), Cons: Constraint{-4, flags("rF"), 1, 1, 1}},
-		"zrem":          Desc{Proc: AutoCommit(ZRem), Cons: Constraint{-3, flags("wF"), 1, 1, 1}},
-		"zcard":         Desc{Proc: AutoCommit(ZCard), Cons: Constraint{2, flags("rF"), 1, 1, 1}},
-		"zscore":        Desc{Proc: AutoCommit(ZScore), Cons: Constraint{3, flags("rF"), 1, 1, 1}},
+		"zadd":             Desc{Proc: AutoCommit(ZAdd), Cons: Constraint{-4, flags("wmF"), 1, 1, 1}},
+		"zincrby":          Desc{Proc: AutoCommit(ZIncrBy), Cons: Constraint{4, flags("wmF"), 1, 1, 1}},
+		"zrange":           Desc{Proc: AutoCommit(ZRange), Cons: Constraint{-4, flags("rF"), 1, 1, 1}},
+		"zrevrange":        Desc{Proc: AutoCommit(ZRevRange), Cons: Constraint{-4, flags("rF"), 1, 1, 1}},
+		"zrangebyscore":    {Proc: AutoCommit(ZRangeByScore), Cons: Constraint{-4, flags("rF"), 1, 1, 1}},
+		"zrevrangebyscore": {Proc: AutoCommit(ZRevRangeByScore), Cons: Constraint{-4, flags("rF"), 1, 1, 1}},
+		"zrangebylex":      {Proc: AutoCommit(ZRangeByLex), Cons: Constraint{-4, flags("rF"), 1, 1, 1}},
+		"zrevrangebylex":   {Proc: AutoCommit(ZRevRangeByLex), Cons: Constraint{-4, flags("rF"), 1, 1, 1}},
+		"zcount":           {Proc: AutoCommit(ZCount), Cons: Constraint{4, flags("rF"), 1, 1, 1}},
+		"zlexcount":        {Proc: AutoCommit(ZLexCount), Cons: Constraint{4, flags("rF"), 1, 1, 1}},
+		"zrem":             Desc{Proc: AutoCommit(ZRem), Cons: Constraint{-3, flags("wF"), 1, 1, 1}},
+		"zcard":            Desc{Proc: AutoCommit(ZCard), Cons: Constraint{2, flags("rF"), 1, 1, 1}},
+		"zscore":           Desc{Proc: AutoCommit(ZScore), Cons: Constraint{-3, flags("rF"), 1, 1, 1}},
+		"zmscore":          Desc{Proc: AutoCommit(ZMScore), Cons: Constraint{-3, flags("rF"), 1, 1, 1}},
+		"zrandmember":      Desc{Proc: AutoCommit(ZRandMember), Cons: Constraint{-2, flags("rR"), 1, 1, 1}},
+		"zexpiremember":    Desc{Proc: AutoCommit(ZExpireMember), Cons: Constraint{4, flags("wF"), 1, 1, 1}},
+
+		// sketches
+		"cms.initbydim": Desc{Proc: AutoCommit(CMSInitByDim), Cons: Constraint{4, flags("wF"), 1, 1, 1}},
+		"cms.incrby":    Desc{Proc: AutoCommit(CMSIncrBy), Cons: Constraint{-4, flags("wF"), 1, 1, 1}},
+		"cms.query":     Desc{Proc: AutoCommit(CMSQuery), Cons: Constraint{-3, flags("rF"), 1, 1, 1}},
+		"cms.info":      Desc{Proc: AutoCommit(CMSInfo), Cons: Constraint{2, flags("rF"), 1, 1, 1}},
+		"topk.reserve":  Desc{Proc: AutoCommit(TopKReserve), Cons: Constraint{-3, flags("wF"), 1, 1, 1}},
+		"topk.add":      Desc{Proc: AutoCommit(TopKAdd), Cons: Constraint{-3, flags("wF"), 1, 1, 1}},
+		"topk.query":    Desc{Proc: AutoCommit(TopKQuery), Cons: Constraint{-3, flags("rF"), 1, 1, 1}},
+		"topk.count":    Desc{Proc: AutoCommit(TopKCount), Cons: Constraint{-3, flags("rF"), 1, 1, 1}},
+		"topk.list":     Desc{Proc: AutoCommit(TopKList), Cons: Constraint{2, flags("rF"), 1, 1, 1}},
+		"topk.info":     Desc{Proc: AutoCommit(TopKInfo), Cons: Constraint{2, flags("rF"), 1, 1, 1}},
+
+		// time series
+		"ts.add":    Desc{Proc: AutoCommit(TSAdd), Cons: Constraint{4, flags("wF"), 1, 1, 1}},
+		"ts.incrby": Desc{Proc: AutoCommit(TSIncrBy), Cons: Constraint{-3, flags("wF"), 1, 1, 1}},
+		"ts.range":  Desc{Proc: AutoCommit(TSRange), Cons: Constraint{-4, flags("rF"), 1, 1, 1}},
+
+		// sequences
+		"seq.next": Desc{Proc: AutoCommit(SeqNext), Cons: Constraint{-2, flags("wmF"), 1, 1, 1}},
+		"seq.set":  Desc{Proc: AutoCommit(SeqSet), Cons: Constraint{3, flags("wF"), 1, 1, 1}},
+		"seq.get":  Desc{Proc: AutoCommit(SeqGet), Cons: Constraint{2, flags("rF"), 1, 1, 1}},
 	}
 }
@@ -47,54 +47,108 @@ func Exists(ctx *Context, txn *db.Transaction) (OnCommit, error) {
 	return Integer(ctx.Out, c), nil
 }
 
+// parseExpireOption validates the optional NX/XX/GT/LT modifier of the
+// expire command family, redis 7 accepts at most one of them
+func parseExpireOption(args []string) (string, error) {
+	if len(args) == 0 {
+		return "", nil
+	}
+	if len(args) > 1 {
+		return "", ErrSyntax
+	}
+	opt := strings.ToLower(args[0])
+	switch opt {
+	case "nx", "xx", "gt", "lt":
+		return opt, nil
+	}
+	return "", errors.New("ERR Unsupported option " + args[0])
+}
+
+// expireAtWith checks the modifier against the current ExpireAt of key and
+// sets the new deadline when it passes. The read and the expire index
+// update share the command transaction, so a concurrent ttl change aborts
+// the commit instead of losing the condition
+func expireAtWith(ctx *Context, txn *db.Transaction, key []byte, at int64, opt string) (OnCommit, error) {
+	if opt != "" {
+		obj, err := txn.Kv().Object(key)
+		if err == db.ErrKeyNotFound {
+			return Integer(ctx.Out, 0), nil
+		}
+		if err != nil {
+			return nil, errors.New("ERR " + err.Error())
+		}
+		cur := obj.ExpireAt
+		switch opt {
+		case "nx":
+			if cur != 0 {
+				return Integer(ctx.Out, 0), nil
+			}
+		case "xx":
+			if cur == 0 {
+				return Integer(ctx.Out, 0), nil
+			}
+		case "gt":
+			// a key without a ttl lives forever, nothing is greater
+			if cur == 0 || at <= cur {
+				return Integer(ctx.Out, 0), nil
+			}
+		case "lt":
+			if cur != 0 && at >= cur {
+				return Integer(ctx.Out, 0), nil
+			}
+		}
+	}
+	if err := txn.Kv().ExpireAt(key, at); err != nil {
+		if err == db.ErrKeyNotFound {
+			return Integer(ctx.Out, 0), nil
+		}
+		return nil, errors.New("ERR " + err.Error())
+	}
+	recordTTLChange(ctx, key, "expire_at="+strconv.FormatInt(at/int64(time.Second), 10))
+	return Integer(ctx.Out, 1), nil
+}
+
 // Expire sets a timeout on key
 func Expire(ctx *Context, txn *db.Transaction) (OnCommit, error) {
-	kv := txn.Kv()
 	key := []byte(ctx.Args[0])
 	seconds, err := strconv.ParseInt(ctx.Args[1], 10, 64)
 	if err != nil {
 		return nil, ErrInteger
 	}
+	opt, err := parseExpireOption(ctx.Args[2:])
+	if err != nil {
+		return nil, err
+	}
 
 	at := time.Now().Add(time.Second * time.Duration(seconds)).UnixNano()
-	if err := kv.ExpireAt(key, at); err != nil {
-		if err == db.ErrKeyNotFound {
-			return Integer(ctx.Out, 0), nil
-		}
-		return nil, errors.New("ERR " + err.Error())
-	}
-	return Integer(ctx.Out, 1), nil
+	return expireAtWith(ctx, txn, key, at, opt)
 }
 
 // ExpireAt sets an absolute timestamp to expire on key
 func ExpireAt(ctx *Context, txn *db.Transaction) (OnCommit, error) {
-	kv := txn.Kv()
 	key := []byte(ctx.Args[0])
 	timestamp, err := strconv.ParseInt(ctx.Args[1], 10, 64)
 	if err != nil {
 		return nil, ErrInteger
 	}
+	opt, err := parseExpireOption(ctx.Args[2:])
+	if err != nil {
+		return nil, err
+	}
 
 	at := int64(time.Second * time.Duration(timestamp))
 	if at <= 0 {
 		at = 1
 	}
 
-	if err := kv.ExpireAt(key, at); err != nil {
-		if err == db.ErrKeyNotFound {
-			return Integer(ctx.Out, 0), nil
-		}
-		return nil, errors.New("ERR " + err.Error())
-	}
-
-	return Integer(ctx.Out, 1), nil
+	return expireAtWith(ctx, txn, key, at, opt)
 }
 
 // Persist removes the existing timeout on key, turning the key from volatile to persistent
 func Persist(ctx *Context, txn *db.Transaction) (OnCommit, error) {
 	kv := txn.Kv()
 	key := []byte(ctx.Args[0])
-	obj, err := txn.Object(key)
+	obj, err := txn.Kv().Object(key)
 	if err != nil && err != db.ErrKeyNotFound {
 		return nil, errors.New("ERR " + err.Error())
 	}
@@ -108,55 +162,49 @@ func Persist(ctx *Context, txn *db.Transaction) (OnCommit, error) {
 		}
 		return nil, errors.New("ERR " + err.Error())
 	}
+	recordTTLChange(ctx, key, "ttl_cleared")
 	return Integer(ctx.Out, 1), nil
 }
 
 // PExpire works exactly like expire but the time to live of the key is specified in milliseconds instead of seconds
 func PExpire(ctx *Context, txn *db.Transaction) (OnCommit, error) {
-	kv := txn.Kv()
 	key := []byte(ctx.Args[0])
 	ms, err := strconv.ParseInt(ctx.Args[1], 10, 64)
 	if err != nil {
 		return nil, ErrInteger
 	}
-	at := time.Now().Add(time.Millisecond * time.Duration(ms)).UnixNano()
-	if err := kv.ExpireAt(key, at); err != nil {
-		if err == db.ErrKeyNotFound {
-			return Integer(ctx.Out, 0), nil
-		}
-		return nil, errors.New("ERR " + err.Error())
+	opt, err := parseExpireOption(ctx.Args[2:])
+	if err != nil {
+		return nil, err
 	}
-	return Integer(ctx.Out, 1), nil
-
+	at := time.Now().Add(time.Millisecond * time.Duration(ms)).UnixNano()
+	return expireAtWith(ctx, txn, key, at, opt)
 }
 
 // PExpireAt has the same effect and semantic as expireAt,
 // but the Unix time at which the key will expire is specified in milliseconds instead of seconds
 func PExpireAt(ctx *Context, txn *db.Transaction) (OnCommit, error) {
-	kv := txn.Kv()
 	key := []byte(ctx.Args[0])
 	ms, err := strconv.ParseInt(ctx.Args[1], 10, 64)
 	if err != nil {
 		return nil, ErrInteger
 	}
+	opt, err := parseExpireOption(ctx.Args[2:])
+	if err != nil {
+		return nil, err
+	}
 	at := int64(time.Millisecond * time.Duration(ms))
 	if at <= 0 {
 		at = 1
 	}
-	if err := kv.ExpireAt(key, at); err != nil {
-		if err == db.ErrKeyNotFound {
-			return Integer(ctx.Out, 0), nil
-		}
-		return nil, errors.New("ERR " + err.Error())
-	}
-	return Integer(ctx.Out, 1), nil
+	return expireAtWith(ctx, txn, key, at, opt)
 }
 
 // TTL returns the remaining time to live of a key that has a timeout
 func TTL(ctx *Context, txn *db.Transaction) (OnCommit, error) {
 	key := []byte(ctx.Args[0])
 	now := db.Now()
-	obj, err := txn.Object(key)
+	obj, err := txn.Kv().Object(key)
 	if err != nil {
 		if err == db.ErrKeyNotFound {
 			return Integer(ctx.Out, -2), nil
@@ -175,16 +223,13 @@ func TTL(ctx *Context, txn *db.Transaction) (OnCommit, error) {
 func PTTL(ctx *Context, txn *db.Transaction) (OnCommit, error) {
 	key := []byte(ctx.Args[0])
 	now := db.Now()
-	obj, err := txn.Object(key)
+	obj, err := txn.Kv().Object(key)
 	if err != nil {
 		if err == db.ErrKeyNotFound {
 			return Integer(ctx.Out, -2), nil
 		}
 		return nil, errors.New("ERR " + err.Error())
 	}
-	if db.IsExpired(obj, now) {
-		return Integer(ctx.Out, -2), nil
-	}
 	if obj.ExpireAt == 0 {
 		return Integer(ctx.Out, -1), nil
 	}
@@ -193,24 +238,113 @@ func PTTL(ctx *Context, txn *db.Transaction) (OnCommit, error) {
 
 }
 
+// ExpireTime returns the absolute unix timestamp in seconds at which the
+// key will expire, -1 when the key has no timeout and -2 when it is missing
+func ExpireTime(ctx *Context, txn *db.Transaction) (OnCommit, error) {
+	key := []byte(ctx.Args[0])
+	obj, err := txn.Kv().Object(key)
+	if err != nil {
+		if err == db.ErrKeyNotFound {
+			return Integer(ctx.Out, -2), nil
+		}
+		return nil, errors.New("ERR " + err.Error())
+	}
+	if obj.ExpireAt == 0 {
+		return Integer(ctx.Out, -1), nil
+	}
+	return Integer(ctx.Out, obj.ExpireAt/int64(time.Second)), nil
+}
+
+// PExpireTime works like expiretime but reports the timestamp in
+// milliseconds, backup tooling uses it to reconstruct ttls precisely
+func PExpireTime(ctx *Context, txn *db.Transaction) (OnCommit, error) {
+	key := []byte(ctx.Args[0])
+	obj, err := txn.Kv().Object(key)
+	if err != nil {
+		if err == db.ErrKeyNotFound {
+			return Integer(ctx.Out, -2), nil
+		}
+		return nil, errors.New("ERR " + err.Error())
+	}
+	if obj.ExpireAt == 0 {
+		return Integer(ctx.Out, -1), nil
+	}
+	return Integer(ctx.Out, obj.ExpireAt/int64(time.Millisecond)), nil
+}
+
+// TTLMulti is a titan extension that returns the remaining time to live in
+// milliseconds of every given key, resolving all meta keys with a single
+// batch get. Each reply entry follows the PTTL convention
+func TTLMulti(ctx *Context, txn *db.Transaction) (OnCommit, error) {
+	keys := make([][]byte, len(ctx.Args))
+	for i, arg := range ctx.Args {
+		keys[i] = []byte(arg)
+	}
+	ttls, err := txn.Kv().TTL(keys)
+	if err != nil {
+		return nil, errors.New("ERR " + err.Error())
+	}
+	return func() {
+		resp.ReplyArray(ctx.Out, len(ttls))
+		for _, ttl := range ttls {
+			if ttl > 0 {
+				ttl /= int64(time.Millisecond)
+			}
+			resp.ReplyInteger(ctx.Out, ttl)
+		}
+	}, nil
+}
+
+// MExpire is a titan extension that sets the same timeout in seconds on
+// every given key, the reply holds 1 for each key that was touched and 0
+// for missing keys
+func MExpire(ctx *Context, txn *db.Transaction) (OnCommit, error) {
+	seconds, err := strconv.ParseInt(ctx.Args[0], 10, 64)
+	if err != nil {
+		return nil, ErrInteger
+	}
+	at := db.Now() + seconds*int64(time.Second)
+	if at <= 0 {
+		at = 1
+	}
+	keys := make([][]byte, len(ctx.Args)-1)
+	for i, arg := range ctx.Args[1:] {
+		keys[i] = []byte(arg)
+	}
+	ttls, err := txn.Kv().TTL(keys)
+	if err != nil {
+		return nil, errors.New("ERR " + err.Error())
+	}
+	results := make([]int64, len(keys))
+	for i, ttl := range ttls {
+		if ttl == -2 {
+			continue
+		}
+		if err := txn.Kv().ExpireAt(keys[i], at); err != nil {
+			return nil, errors.New("ERR " + err.Error())
+		}
+		recordTTLChange(ctx, keys[i], "expire_at="+strconv.FormatInt(at/int64(time.Second), 10))
+		results[i] = 1
+	}
+	return func() {
+		resp.ReplyArray(ctx.Out, len(results))
+		for _, res := range results {
+			resp.ReplyInteger(ctx.Out, res)
+		}
+	}, nil
+}
+
 // Object inspects the internals of Redis Objects
 func Object(ctx *Context, txn *db.Transaction) (OnCommit, error) {
 	argc := len(ctx.Args)
 	subCmd := strings.ToLower(ctx.Args[0])
 	cmdErr := fmt.Errorf("ERR Unknown subcommand or wrong number of arguments for '%s'. Try OBJECT help", subCmd)
 	if argc == 1 && subCmd == "help" {
-
-		helpInfo := [][]byte{
-			[]byte("OBJECT <subcommand> key. Subcommands:"),
-			[]byte("ENCODING <key> -- Return the kind of internal representation used in order to store the value associated with a key."),
-			[]byte("FREQ <key> -- Return the access frequency index of the key. The returned integer is proportional to the logarithm of the recent access frequency of the key."),
-			[]byte("IDLETIME <key> -- Return the idle time of the key, that is the approximated number of seconds elapsed since the last access to the key."),
-			[]byte("REFCOUNT <key> -- Return the number of references of the value associated with the specified key."),
-		}
+		helpInfo, _ := subcommandHelpLines("object")
 		return BytesArray(ctx.Out, helpInfo), nil
 	} else if argc == 2 {
 		key := []byte(ctx.Args[1])
-		obj, err := txn.Object(key)
+		obj, err := txn.Kv().Object(key)
 		if err != nil {
 			if err == db.ErrKeyNotFound {
 				return NullBulkString(ctx.Out), nil
@@ -233,7 +367,7 @@ func Object(ctx *Context, txn *db.Transaction) (OnCommit, error) {
 // Type returns the string representation of the type of the value stored at key
 func Type(ctx *Context, txn *db.Transaction) (OnCommit, error) {
 	key := []byte(ctx.Args[0])
-	obj, err := txn.Object(key)
+	obj, err := txn.Kv().Object(key)
 	if err != nil {
 		if err == db.ErrKeyNotFound {
 			return SimpleString(ctx.Out, "none"), nil
@@ -248,7 +382,7 @@ func Type(ctx *Context, txn *db.Transaction) (OnCommit, error) {
 func Keys(ctx *Context, txn *db.Transaction) (OnCommit, error) {
 	list := make([][]byte, 0)
 	pattern := []byte(ctx.Args[0])
-	all := (pattern[0] == '*' && len(pattern) == 1)
+	all := (len(pattern) == 1 && pattern[0] == '*')
 	prefix := globMatchPrefix(pattern)
 
 	kv := txn.Kv()
@@ -268,25 +402,34 @@ func Keys(ctx *Context, txn *db.Transaction) (OnCommit, error) {
 // Scan incrementally iterates the key space
 func Scan(ctx *Context, txn *db.Transaction) (OnCommit, error) {
 	var (
-		start   []byte
-		end            = []byte("0")
-		count   uint64 = defaultScanCount
-		pattern []byte
-		prefix  []byte
-		all     bool
-		err     error
+		start      []byte
+		end               = []byte("0")
+		count      uint64 = defaultScanCount
+		pattern    []byte
+		prefix     []byte
+		all        bool
+		typed      bool
+		typeName   string
+		consistent bool
+		ver        uint64
+		err        error
 	)
 	if strings.Compare(ctx.Args[0], "0") != 0 {
 		start = []byte(ctx.Args[0])
 	}
 
-	if len(ctx.Args)%2 == 0 {
-		return nil, ErrInteger
-	}
-
-	for i := 1; i < len(ctx.Args); i += 2 {
+	for i := 1; i < len(ctx.Args); i++ {
 		arg := strings.ToLower(ctx.Args[i])
+		// consistent is the only option without a value
+		if arg == "consistent" {
+			consistent = true
+			continue
+		}
+		if i+1 >= len(ctx.Args) {
+			return nil, ErrInteger
+		}
 		next := ctx.Args[i+1]
+		i++
 		switch arg {
 		case "count":
 			if count, err = strconv.ParseUint(next, 10, 64); err != nil {
@@ -300,7 +443,32 @@ func Scan(ctx *Context, txn *db.Transaction) (OnCommit, error) {
 			}
 		case "match":
 			pattern = []byte(next)
-			all = (pattern[0] == '*' && len(pattern) == 1)
+			all = (len(pattern) == 1 && pattern[0] == '*')
+		case "type":
+			typed = true
+			typeName = strings.ToLower(next)
+		}
+	}
+
+	if consistent {
+		if typed {
+			return nil, ErrSyntax
+		}
+		// a consistent cursor carries the pinned tikv version ahead of
+		// the resume key, the first call pins the version of its own
+		// transaction
+		if start != nil {
+			cursor := string(start)
+			sep := strings.IndexByte(cursor, '|')
+			if sep <= 0 {
+				return nil, errors.New("ERR invalid cursor")
+			}
+			if ver, err = strconv.ParseUint(cursor[:sep], 10, 64); err != nil {
+				return nil, errors.New("ERR invalid cursor")
+			}
+			start = []byte(cursor[sep+1:])
+		} else {
+			ver = txn.SnapshotVersion()
 		}
 	}
 
@@ -330,7 +498,26 @@ func Scan(ctx *Context, txn *db.Transaction) (OnCommit, error) {
 		return true
 	}
 
-	if err := kv.Keys(start, f); err != nil {
+	if consistent {
+		if kv.VersionExpired(ver) {
+			return nil, errors.New("ERR cursor snapshot predates the tikv gc safe point, restart the scan")
+		}
+		if err := kv.KeysAtVersion(ver, start, f); err != nil {
+			return nil, errors.New("ERR " + err.Error())
+		}
+		if string(end) != "0" {
+			end = []byte(strconv.FormatUint(ver, 10) + "|" + string(end))
+		}
+	} else if typed {
+		// filter on the object type server side, only the meta header
+		// is decoded for skipped keys. A name no object carries, like
+		// redis, simply matches nothing
+		if objType, known := db.ObjectTypeByName(typeName); known {
+			if err := kv.KeysByType(start, objType, f); err != nil {
+				return nil, errors.New("ERR " + err.Error())
+			}
+		}
+	} else if err := kv.Keys(start, f); err != nil {
 		return nil, errors.New("ERR " + err.Error())
 	}
 	return func() {
@@ -344,6 +531,50 @@ func Scan(ctx *Context, txn *db.Transaction) (OnCommit, error) {
 
 }
 
+// MClaim atomically claims up to count keys matching pattern, the claimed
+// keys are returned and deleted within the same transaction so concurrent
+// claimers never share a key. It replaces the racy SCAN plus DEL sequence
+// of work queue consumers
+func MClaim(ctx *Context, txn *db.Transaction) (OnCommit, error) {
+	pattern := []byte(ctx.Args[0])
+	count, err := strconv.ParseUint(ctx.Args[1], 10, 64)
+	if err != nil {
+		return nil, ErrInteger
+	}
+	if count == 0 {
+		return nil, errors.New("ERR count should be greater than 0")
+	}
+	if count > ScanMaxCount {
+		count = ScanMaxCount
+	}
+	all := (len(pattern) == 1 && pattern[0] == '*')
+	prefix := globMatchPrefix(pattern)
+
+	kv := txn.Kv()
+	list := make([][]byte, 0, count)
+	f := func(key []byte) bool {
+		if prefix != nil && !bytes.HasPrefix(key, prefix) {
+			return false
+		}
+		if all || globMatch(pattern, key, false) {
+			list = append(list, key)
+			if uint64(len(list)) >= count {
+				return false
+			}
+		}
+		return true
+	}
+	if err := kv.Keys(prefix, f); err != nil {
+		return nil, errors.New("ERR " + err.Error())
+	}
+	if len(list) > 0 {
+		if _, err := kv.Delete(list); err != nil {
+			return nil, errors.New("ERR " + err.Error())
+		}
+	}
+	return BytesArray(ctx.Out, list), nil
+}
+
 // RandomKey returns a random key from the currently selected database
 func RandomKey(ctx *Context, txn *db.Transaction) (OnCommit, error) {
 	kv := txn.Kv()
@@ -145,6 +145,51 @@ func TestExpire(t *testing.T) {
 	NotEquealKeyExists(t, keys[2])
 }
 
+func TestExpireOptions(t *testing.T) {
+	key := "keys-expire-opts"
+	InitData(t, []string{key}, "val")
+
+	expireWith := func(seconds, opt string) string {
+		ctx := ContextTest("expire", key, seconds, opt)
+		Call(ctx)
+		return ctxLines(ctx.Out)[0]
+	}
+
+	// XX on a key without a ttl does nothing
+	assert.Equal(t, ":0", expireWith("100", "xx"))
+	// NX sets the first ttl
+	assert.Equal(t, ":1", expireWith("100", "nx"))
+	// NX refuses to touch an existing ttl
+	assert.Equal(t, ":0", expireWith("50", "NX"))
+	// GT only extends
+	assert.Equal(t, ":0", expireWith("50", "gt"))
+	assert.Equal(t, ":1", expireWith("200", "gt"))
+	// LT only shortens
+	assert.Equal(t, ":0", expireWith("300", "lt"))
+	assert.Equal(t, ":1", expireWith("100", "LT"))
+	// XX updates the existing ttl
+	assert.Equal(t, ":1", expireWith("150", "xx"))
+
+	ctx := ContextTest("expire", key, "100", "bogus")
+	Call(ctx)
+	assert.Equal(t, "-ERR Unsupported option bogus", ctxLines(ctx.Out)[0])
+
+	ctx = ContextTest("expire", key, "100", "nx", "gt")
+	Call(ctx)
+	assert.Equal(t, "-ERR syntax error", ctxLines(ctx.Out)[0])
+
+	// GT never sets a ttl on a persistent key
+	persistent := "keys-expire-opts2"
+	InitData(t, []string{persistent}, "val")
+	ctx = ContextTest("expire", persistent, "100", "gt")
+	Call(ctx)
+	assert.Equal(t, ":0", ctxLines(ctx.Out)[0])
+	// while LT always does
+	ctx = ContextTest("expire", persistent, "100", "lt")
+	Call(ctx)
+	assert.Equal(t, ":1", ctxLines(ctx.Out)[0])
+}
+
 func TestPExpire(t *testing.T) {
 	key1 := "keys-pexpire1"
 	key2 := "keys-pexpire2"
@@ -272,6 +317,88 @@ func TestPTTL(t *testing.T) {
 	assert.NotEqual(t, ":-2", lines[0])
 }
 
+func TestExpireTime(t *testing.T) {
+	val := "val"
+	key1 := "keys-expiretime1"
+
+	InitData(t, []string{key1}, val)
+
+	ctx := ContextTest("expiretime", key1)
+	Call(ctx)
+	lines := ctxLines(ctx.Out)
+	assert.Equal(t, ":-1", lines[0])
+
+	at := time.Now().Add(100 * time.Second).Unix()
+	ctx = ContextTest("expireat", key1, strconv.FormatInt(at, 10))
+	Call(ctx)
+	lines = ctxLines(ctx.Out)
+	assert.Equal(t, ":1", lines[0])
+
+	ctx = ContextTest("expiretime", key1)
+	Call(ctx)
+	lines = ctxLines(ctx.Out)
+	assert.Equal(t, ":"+strconv.FormatInt(at, 10), lines[0])
+
+	ctx = ContextTest("pexpiretime", key1)
+	Call(ctx)
+	lines = ctxLines(ctx.Out)
+	assert.Equal(t, ":"+strconv.FormatInt(at*1000, 10), lines[0])
+
+	ctx = ContextTest("expiretime", "keys-expiretime-none")
+	Call(ctx)
+	lines = ctxLines(ctx.Out)
+	assert.Equal(t, ":-2", lines[0])
+}
+
+func TestTTLMulti(t *testing.T) {
+	val := "val"
+	key1 := "keys-ttlmulti1"
+	key2 := "keys-ttlmulti2"
+
+	InitData(t, []string{key1, key2}, val)
+
+	ctx := ContextTest("expire", key1, "100")
+	Call(ctx)
+	lines := ctxLines(ctx.Out)
+	assert.Equal(t, ":1", lines[0])
+
+	ctx = ContextTest("ttlmulti", key1, key2, "keys-ttlmulti-none")
+	Call(ctx)
+	lines = ctxLines(ctx.Out)
+	assert.Equal(t, "*3", lines[0])
+	assert.NotEqual(t, ":-1", lines[1])
+	assert.NotEqual(t, ":-2", lines[1])
+	assert.Equal(t, ":-1", lines[2])
+	assert.Equal(t, ":-2", lines[3])
+}
+
+func TestMExpire(t *testing.T) {
+	val := "val"
+	key1 := "keys-mexpire1"
+	key2 := "keys-mexpire2"
+
+	InitData(t, []string{key1, key2}, val)
+
+	ctx := ContextTest("mexpire", "100", key1, key2, "keys-mexpire-none")
+	Call(ctx)
+	lines := ctxLines(ctx.Out)
+	assert.Equal(t, "*3", lines[0])
+	assert.Equal(t, ":1", lines[1])
+	assert.Equal(t, ":1", lines[2])
+	assert.Equal(t, ":0", lines[3])
+
+	ctx = ContextTest("ttl", key1)
+	Call(ctx)
+	lines = ctxLines(ctx.Out)
+	assert.NotEqual(t, ":-1", lines[0])
+	assert.NotEqual(t, ":-2", lines[0])
+
+	ctx = ContextTest("mexpire", "abc", key1)
+	Call(ctx)
+	lines = ctxLines(ctx.Out)
+	assert.Equal(t, "-ERR value is not an integer or out of range", lines[0])
+}
+
 func TestPerist(t *testing.T) {
 	key := "keys-pexist1"
 	val := "val"
@@ -364,6 +491,99 @@ func TestScan(t *testing.T) {
 	assert.Equal(t, "keys-sscan5", lines[2])
 }
 
+func TestScanType(t *testing.T) {
+	InitData(t, []string{"keys-tscan-str1", "keys-tscan-str2"}, "val")
+	AddList(t, "keys-tscan-list", "val")
+
+	// only keys of the requested type are returned, the others are
+	// skipped server side
+	ctx := ContextTest("scan", "0", "match", "keys-tscan-*", "type", "list")
+	Call(ctx)
+	lines := ctxLines(ctx.Out)
+	assert.Contains(t, lines, "keys-tscan-list")
+	assert.NotContains(t, lines, "keys-tscan-str1")
+
+	ctx = ContextTest("scan", "0", "match", "keys-tscan-*", "type", "string")
+	Call(ctx)
+	lines = ctxLines(ctx.Out)
+	assert.Contains(t, lines, "keys-tscan-str1")
+	assert.Contains(t, lines, "keys-tscan-str2")
+	assert.NotContains(t, lines, "keys-tscan-list")
+
+	// a type no object carries matches nothing
+	ctx = ContextTest("scan", "0", "match", "keys-tscan-*", "type", "stream")
+	Call(ctx)
+	lines = ctxLines(ctx.Out)
+	assert.Equal(t, "*0", lines[3])
+}
+
+func TestScanConsistent(t *testing.T) {
+	InitData(t, []string{"keys-cscan-a", "keys-cscan-b"}, "val")
+
+	// the cursor of an unfinished consistent scan carries the pinned
+	// version, keys written afterwards stay invisible to it
+	ctx := ContextTest("scan", "0", "match", "keys-cscan-*", "consistent")
+	Call(ctx)
+	lines := ctxLines(ctx.Out)
+	assert.Contains(t, lines, "keys-cscan-a")
+	assert.Contains(t, lines, "keys-cscan-b")
+
+	ctx = ContextTest("scan", "not-a-version", "consistent")
+	Call(ctx)
+	assert.Equal(t, "-ERR invalid cursor", ctxLines(ctx.Out)[0])
+
+	// a cursor whose snapshot fell below the gc safe point is refused
+	ctx = ContextTest("scan", "1|keys-cscan-a", "consistent")
+	Call(ctx)
+	assert.Contains(t, ctxLines(ctx.Out)[0], "gc safe point")
+
+	ctx = ContextTest("scan", "0", "consistent", "type", "string")
+	Call(ctx)
+	assert.Equal(t, "-ERR syntax error", ctxLines(ctx.Out)[0])
+}
+
+func TestMClaim(t *testing.T) {
+	InitData(t, []string{"keys-mclaim-a", "keys-mclaim-b", "keys-mclaim-c"}, "val")
+	InitData(t, []string{"keys-mclaim-other"}, "val")
+
+	// the claimed keys are returned and gone in one transaction
+	ctx := ContextTest("mclaim", "keys-mclaim-?", "2")
+	Call(ctx)
+	lines := ctxLines(ctx.Out)
+	assert.Equal(t, "*2", lines[0])
+	assert.Equal(t, "keys-mclaim-a", lines[2])
+	assert.Equal(t, "keys-mclaim-b", lines[4])
+
+	ctx = ContextTest("mclaim", "keys-mclaim-?", "10")
+	Call(ctx)
+	lines = ctxLines(ctx.Out)
+	assert.Equal(t, "*1", lines[0])
+	assert.Equal(t, "keys-mclaim-c", lines[2])
+
+	// nothing left to claim
+	ctx = ContextTest("mclaim", "keys-mclaim-?", "10")
+	Call(ctx)
+	assert.Equal(t, "*0", ctxLines(ctx.Out)[0])
+
+	// keys outside the pattern are untouched
+	ctx = ContextTest("exists", "keys-mclaim-other")
+	Call(ctx)
+	assert.Equal(t, ":1", ctxLines(ctx.Out)[0])
+
+	ctx = ContextTest("mclaim", "keys-mclaim-?", "0")
+	Call(ctx)
+	assert.Equal(t, "-ERR count should be greater than 0", ctxLines(ctx.Out)[0])
+
+	ctx = ContextTest("mclaim", "keys-mclaim-?", "abc")
+	Call(ctx)
+	assert.Equal(t, "-"+ErrInteger.Error(), ctxLines(ctx.Out)[0])
+
+	// an empty pattern used to panic on pattern[0], it matches nothing
+	ctx = ContextTest("mclaim", "", "1")
+	Call(ctx)
+	assert.Equal(t, "*0", ctxLines(ctx.Out)[0])
+}
+
 func TestObject(t *testing.T) {
 	key := "keys-object1"
 	val := "val"
@@ -0,0 +1,85 @@
+package command
+
+import (
+	"strings"
+
+	"github.com/distributedio/titan/db"
+	"github.com/distributedio/titan/encoding/resp"
+)
+
+// Limit manages the rules of the global rate limiter. The limiter data
+// lives in a reserved system namespace regular clients cannot authenticate
+// into anymore, so operators change limits through this command instead of
+// writing the string keys directly. A rule is named
+// qps:<namespace>@<command> or rate:<namespace>@<command>, <namespace> may
+// be * to match every namespace
+func Limit(ctx *Context) {
+	if ctx.Client.Namespace != sysAdminNamespace {
+		resp.ReplyError(ctx.Out, "ERR limit can be used by $sys.admin only")
+		return
+	}
+	if ctx.Server == nil || ctx.Server.LimitersMgr == nil {
+		resp.ReplyError(ctx.Out, "ERR limiter is not enabled")
+		return
+	}
+	subCmd := strings.ToLower(ctx.Args[0])
+	switch subCmd {
+	case "set":
+		if len(ctx.Args) != 4 || !validLimitRule(ctx.Args[1]) {
+			resp.ReplyError(ctx.Out, ErrWrongArgs(ctx.Name).Error())
+			return
+		}
+		value := ctx.Args[2] + db.LIMIT_VALUE_TOKEN + ctx.Args[3]
+		if err := ctx.Server.LimitersMgr.SetLimit(ctx.Args[1], value); err != nil {
+			resp.ReplyError(ctx.Out, "ERR "+err.Error())
+			return
+		}
+		resp.ReplySimpleString(ctx.Out, OK)
+	case "get":
+		if len(ctx.Args) != 2 || !validLimitRule(ctx.Args[1]) {
+			resp.ReplyError(ctx.Out, ErrWrongArgs(ctx.Name).Error())
+			return
+		}
+		val, err := ctx.Server.LimitersMgr.GetLimit(ctx.Args[1])
+		if err != nil {
+			resp.ReplyError(ctx.Out, "ERR "+err.Error())
+			return
+		}
+		if val == nil {
+			resp.ReplyNullBulkString(ctx.Out)
+			return
+		}
+		resp.ReplyBulkString(ctx.Out, string(val))
+	case "del":
+		if len(ctx.Args) != 2 || !validLimitRule(ctx.Args[1]) {
+			resp.ReplyError(ctx.Out, ErrWrongArgs(ctx.Name).Error())
+			return
+		}
+		deleted, err := ctx.Server.LimitersMgr.DelLimit(ctx.Args[1])
+		if err != nil {
+			resp.ReplyError(ctx.Out, "ERR "+err.Error())
+			return
+		}
+		resp.ReplyInteger(ctx.Out, deleted)
+	case "help":
+		replySubcommandHelp(ctx.Out, "limit")
+	default:
+		resp.ReplyError(ctx.Out, "ERR Unknown subcommand or wrong number of arguments for '"+subCmd+"'. Try LIMIT HELP")
+	}
+}
+
+// validLimitRule reports whether the rule names a qps or rate limit of a
+// namespace and command pair
+func validLimitRule(rule string) bool {
+	name := rule
+	switch {
+	case strings.HasPrefix(rule, db.QPS_PREFIX):
+		name = rule[len(db.QPS_PREFIX):]
+	case strings.HasPrefix(rule, db.RATE_PREFIX):
+		name = rule[len(db.RATE_PREFIX):]
+	default:
+		return false
+	}
+	parts := strings.Split(name, db.NAMESPACE_COMMAND_TOKEN)
+	return len(parts) == 2 && parts[0] != "" && parts[1] != ""
+}
@@ -0,0 +1,40 @@
+package command
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReservedNamespaceRejected(t *testing.T) {
+	ctx := ContextTest("get", "key")
+	ctx.Client.Namespace = "$sys.ratelimit"
+	Call(ctx)
+	assert.Contains(t, ctxLines(ctx.Out)[0], "-ERR namespace is reserved for system use")
+
+	// the operator namespace is exempt, it would be locked out otherwise
+	ctx = ContextTest("ping")
+	ctx.Client.Namespace = sysAdminNamespace
+	Call(ctx)
+	assert.Equal(t, "$4", ctxLines(ctx.Out)[0])
+}
+
+func TestLimit(t *testing.T) {
+	ctx := ContextTest("limit", "get", "qps:tenant@get")
+	Call(ctx)
+	assert.Contains(t, ctxLines(ctx.Out)[0], "$sys.admin only")
+
+	// the test server context has no limiter manager wired in
+	ctx = ContextTest("limit", "get", "qps:tenant@get")
+	ctx.Client.Namespace = sysAdminNamespace
+	Call(ctx)
+	assert.Contains(t, ctxLines(ctx.Out)[0], "-ERR limiter is not enabled")
+}
+
+func TestValidLimitRule(t *testing.T) {
+	assert.True(t, validLimitRule("qps:tenant@get"))
+	assert.True(t, validLimitRule("rate:*@set"))
+	assert.False(t, validLimitRule("tenant@get"))
+	assert.False(t, validLimitRule("qps:tenant"))
+	assert.False(t, validLimitRule("qps:@get"))
+}
@@ -124,6 +124,12 @@ func LRange(ctx *Context, txn *db.Transaction) (OnCommit, error) {
 	if len(items) == 0 {
 		return BytesArray(ctx.Out, nil), nil
 	}
+	if err := checkReplySize(ctx, len(items)); err != nil {
+		return nil, err
+	}
+	if err := accountReplySize(ctx, items); err != nil {
+		return nil, err
+	}
 	return BytesArray(ctx.Out, items), nil
 }
 
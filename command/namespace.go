@@ -0,0 +1,114 @@
+package command
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/distributedio/titan/encoding/resp"
+)
+
+// Namespace reports usage of the client's own namespace, tenants can
+// answer capacity questions without an operator
+func Namespace(ctx *Context) {
+	subCmd := strings.ToLower(ctx.Args[0])
+	switch subCmd {
+	case "usage":
+		namespaceUsage(ctx)
+	case "readonly":
+		namespaceReadOnly(ctx)
+	case "list":
+		namespaceList(ctx)
+	case "help":
+		replySubcommandHelp(ctx.Out, "namespace")
+	default:
+		resp.ReplyError(ctx.Out, "ERR Unknown subcommand or wrong number of arguments for '"+subCmd+"'. Try NAMESPACE HELP")
+	}
+}
+
+// namespaceReadOnly flips the read-only maintenance mode of a namespace,
+// the switch is persisted in tikv and reaches the other nodes within the
+// sync period of their ReadOnlyMgr
+func namespaceReadOnly(ctx *Context) {
+	if ctx.Client.Namespace != sysAdminNamespace {
+		resp.ReplyError(ctx.Out, "ERR namespace readonly can be used by $sys.admin only")
+		return
+	}
+	if ctx.Server == nil || ctx.Server.ReadOnlyMgr == nil {
+		resp.ReplyError(ctx.Out, "ERR read-only manager is not enabled")
+		return
+	}
+	if len(ctx.Args) != 3 {
+		resp.ReplyError(ctx.Out, ErrWrongArgs(ctx.Name).Error())
+		return
+	}
+	var on bool
+	switch strings.ToLower(ctx.Args[2]) {
+	case "on":
+		on = true
+	case "off":
+		on = false
+	default:
+		resp.ReplyError(ctx.Out, ErrWrongArgs(ctx.Name).Error())
+		return
+	}
+	if err := ctx.Server.ReadOnlyMgr.SetReadOnly(ctx.Args[1], on); err != nil {
+		resp.ReplyError(ctx.Out, "ERR "+err.Error())
+		return
+	}
+	resp.ReplySimpleString(ctx.Out, "OK")
+}
+
+// namespaceList reports every namespace known to the registry with its
+// id, registration time and flags, one line per namespace
+func namespaceList(ctx *Context) {
+	if ctx.Client.Namespace != sysAdminNamespace {
+		resp.ReplyError(ctx.Out, "ERR namespace list can be used by $sys.admin only")
+		return
+	}
+	if ctx.Server == nil || ctx.Server.NamespaceReg == nil {
+		resp.ReplyError(ctx.Out, "ERR namespace registry is not enabled")
+		return
+	}
+	entries, err := ctx.Server.NamespaceReg.List()
+	if err != nil {
+		resp.ReplyError(ctx.Out, "ERR "+err.Error())
+		return
+	}
+	resp.ReplyArray(ctx.Out, len(entries))
+	for _, e := range entries {
+		resp.ReplyBulkString(ctx.Out, e.Name+
+			" id="+strconv.FormatUint(uint64(e.Meta.ID), 10)+
+			" created_at="+strconv.FormatInt(e.Meta.CreatedAt/int64(time.Second), 10)+
+			" flags="+strconv.FormatUint(uint64(e.Meta.Flags), 10))
+	}
+}
+
+// namespaceUsage formats the reconciled keyspace counters and the local
+// limiter snapshot of the namespace as an info style bulk string
+func namespaceUsage(ctx *Context) {
+	var lines []string
+	lines = append(lines, "# Usage")
+	lines = append(lines, "namespace:"+ctx.Client.Namespace)
+	if info, err := keyspaceInfo(ctx.Client.DB); err == nil && info != nil {
+		lines = append(lines, "keys:"+strconv.FormatInt(info.Keys, 10))
+		lines = append(lines, "bytes:"+strconv.FormatInt(info.Bytes, 10))
+		lines = append(lines, "reconciled_at:"+strconv.FormatInt(info.ReconciledAt/int64(time.Second), 10))
+		lines = append(lines, "reconcile_delta:"+strconv.FormatInt(info.Delta, 10))
+	}
+
+	lines = append(lines, "# Limits")
+	if ctx.Server != nil && ctx.Server.LimitersMgr != nil {
+		for _, u := range ctx.Server.LimitersMgr.NamespaceUsage(ctx.Client.Namespace) {
+			lines = append(lines, u.Command+
+				":qps_limit="+strconv.FormatInt(u.QpsLimit, 10)+
+				",qps_burst="+strconv.Itoa(u.QpsBurst)+
+				",rate_limit="+strconv.FormatInt(u.RateLimit, 10)+
+				",rate_burst="+strconv.Itoa(u.RateBurst)+
+				",local_qps="+strconv.FormatFloat(u.LocalQps, 'f', 2, 64)+
+				",local_rate_kbps="+strconv.FormatFloat(u.LocalRate, 'f', 2, 64))
+		}
+	}
+
+	resp.ReplyBulkString(ctx.Out, strings.Join(lines, "\n")+"\n")
+}
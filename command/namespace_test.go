@@ -0,0 +1,92 @@
+package command
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/distributedio/titan/db"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNamespaceUsage(t *testing.T) {
+	ctx := ContextTest("namespace", "usage")
+	Call(ctx)
+	out := ctx.Out.(*bytes.Buffer).String()
+	assert.Contains(t, out, "# Usage")
+	assert.Contains(t, out, "namespace:")
+	assert.Contains(t, out, "# Limits")
+}
+
+func TestNamespaceReadOnly(t *testing.T) {
+	mgr := db.NewReadOnlyMgr(mockdb, &Cfg.ReadOnly)
+
+	ctx := ContextTest("namespace", "readonly", "tenant-ro", "on")
+	ctx.Server.ReadOnlyMgr = mgr
+	Call(ctx)
+	assert.Contains(t, ctxLines(ctx.Out)[0], "$sys.admin only")
+
+	ctx = ContextTest("namespace", "readonly", "tenant-ro", "on")
+	ctx.Server.ReadOnlyMgr = mgr
+	ctx.Client.Namespace = sysAdminNamespace
+	Call(ctx)
+	assert.Equal(t, "+OK", ctxLines(ctx.Out)[0])
+	assert.True(t, mgr.IsReadOnly("tenant-ro"))
+
+	// writes of the namespace are rejected, reads still work
+	ctx = ContextTest("set", "ro-key", "v")
+	ctx.Server.ReadOnlyMgr = mgr
+	ctx.Client.Namespace = "tenant-ro"
+	Call(ctx)
+	assert.Contains(t, ctxLines(ctx.Out)[0], "-READONLY")
+
+	ctx = ContextTest("get", "ro-key")
+	ctx.Server.ReadOnlyMgr = mgr
+	ctx.Client.Namespace = "tenant-ro"
+	Call(ctx)
+	assert.Equal(t, "$-1", ctxLines(ctx.Out)[0])
+
+	ctx = ContextTest("namespace", "readonly", "tenant-ro", "off")
+	ctx.Server.ReadOnlyMgr = mgr
+	ctx.Client.Namespace = sysAdminNamespace
+	Call(ctx)
+	assert.Equal(t, "+OK", ctxLines(ctx.Out)[0])
+	assert.False(t, mgr.IsReadOnly("tenant-ro"))
+
+	ctx = ContextTest("set", "ro-key", "v")
+	ctx.Server.ReadOnlyMgr = mgr
+	ctx.Client.Namespace = "tenant-ro"
+	Call(ctx)
+	assert.Equal(t, "+OK", ctxLines(ctx.Out)[0])
+}
+
+func TestNamespaceHelp(t *testing.T) {
+	ctx := ContextTest("namespace", "help")
+	Call(ctx)
+	lines := ctxLines(ctx.Out)
+	assert.Equal(t, "*5", lines[0])
+	assert.Equal(t, "HELP -- Print this help.", lines[len(lines)-2])
+
+	ctx = ContextTest("namespace", "bogus")
+	Call(ctx)
+	lines = ctxLines(ctx.Out)
+	assert.Contains(t, lines[0], "-ERR Unknown subcommand")
+}
+
+func TestNamespaceList(t *testing.T) {
+	reg := db.NewNamespaceRegistry(mockdb, nil)
+	_, err := reg.Bootstrap("tenant-list")
+	assert.NoError(t, err)
+
+	ctx := ContextTest("namespace", "list")
+	ctx.Server.NamespaceReg = reg
+	Call(ctx)
+	assert.Contains(t, ctxLines(ctx.Out)[0], "$sys.admin only")
+
+	ctx = ContextTest("namespace", "list")
+	ctx.Server.NamespaceReg = reg
+	ctx.Client.Namespace = sysAdminNamespace
+	Call(ctx)
+	out := ctx.Out.(*bytes.Buffer).String()
+	assert.Contains(t, out, "tenant-list id=1")
+	assert.Contains(t, out, "flags=0")
+}
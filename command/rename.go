@@ -0,0 +1,45 @@
+package command
+
+import (
+	"fmt"
+	"strings"
+)
+
+// RenameCommands applies a rename-command spec to the command tables
+// before the server starts serving, so operators can hide dangerous
+// commands behind hard to guess names or disable them outright. The
+// spec is a comma separated list of oldname:newname pairs, an empty
+// newname disables the command
+func RenameCommands(spec string) error {
+	for _, pair := range strings.Split(spec, ",") {
+		if pair = strings.TrimSpace(pair); pair == "" {
+			continue
+		}
+		parts := strings.SplitN(pair, ":", 2)
+		if len(parts) != 2 {
+			return fmt.Errorf("invalid rename-command pair(%s), expect oldname:newname", pair)
+		}
+		oldName := strings.ToLower(strings.TrimSpace(parts[0]))
+		newName := strings.ToLower(strings.TrimSpace(parts[1]))
+
+		desc, ok := commands[oldName]
+		if !ok {
+			return fmt.Errorf("rename-command unknown command(%s)", oldName)
+		}
+		if newName != "" {
+			if _, exists := commands[newName]; exists {
+				return fmt.Errorf("rename-command target name(%s) already exists", newName)
+			}
+			commands[newName] = desc
+		}
+		delete(commands, oldName)
+
+		if txnCmd, ok := txnCommands[oldName]; ok {
+			if newName != "" {
+				txnCommands[newName] = txnCmd
+			}
+			delete(txnCommands, oldName)
+		}
+	}
+	return nil
+}
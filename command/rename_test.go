@@ -0,0 +1,44 @@
+package command
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRenameCommands(t *testing.T) {
+	getDesc, getTxn := commands["get"], txnCommands["get"]
+	flushallDesc, flushallTxn := commands["flushall"], txnCommands["flushall"]
+	defer func() {
+		delete(commands, "get-hidden")
+		delete(txnCommands, "get-hidden")
+		commands["get"] = getDesc
+		txnCommands["get"] = getTxn
+		commands["flushall"] = flushallDesc
+		txnCommands["flushall"] = flushallTxn
+	}()
+
+	// rename moves the command in both lookup tables
+	assert.NoError(t, RenameCommands("get:get-hidden"))
+	_, ok := commands["get"]
+	assert.False(t, ok)
+	_, ok = commands["get-hidden"]
+	assert.True(t, ok)
+	_, ok = txnCommands["get"]
+	assert.False(t, ok)
+	_, ok = txnCommands["get-hidden"]
+	assert.True(t, ok)
+
+	// an empty newname disables the command
+	assert.NoError(t, RenameCommands("flushall:"))
+	_, ok = commands["flushall"]
+	assert.False(t, ok)
+
+	// errors: malformed pair, unknown command, existing target
+	assert.Error(t, RenameCommands("set"))
+	assert.Error(t, RenameCommands("nosuchcommand:foo"))
+	assert.Error(t, RenameCommands("mget:mset"))
+
+	// an empty spec is a no-op
+	assert.NoError(t, RenameCommands(""))
+}
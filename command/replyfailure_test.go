@@ -0,0 +1,29 @@
+package command
+
+import (
+	"errors"
+	"testing"
+)
+
+type brokenWriter struct{}
+
+func (w *brokenWriter) Write(p []byte) (int, error) {
+	return 0, errors.New("broken pipe")
+}
+
+func TestReplyFailureKicksClient(t *testing.T) {
+	ctx := ContextTest("ping")
+	ctx.Out = &brokenWriter{}
+	ctx.Client.Done = make(chan struct{})
+
+	NewExecutor().Execute(ctx)
+
+	select {
+	case <-ctx.Client.Done:
+	default:
+		t.Fatal("client was not marked for closure after a reply write failure")
+	}
+
+	// kicking twice must not panic
+	ctx.Client.Kick()
+}
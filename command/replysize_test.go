@@ -0,0 +1,53 @@
+package command
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReplyElementsGuard(t *testing.T) {
+	key := "reply-size-list"
+	for i := 0; i < 5; i++ {
+		Call(ContextTest("rpush", key, "v"))
+	}
+
+	ctx := ContextTest("lrange", key, "0", "-1")
+	ctx.Server.MaxReplyElements = 3
+	Call(ctx)
+	lines := ctxLines(ctx.Out)
+	assert.True(t, strings.HasPrefix(lines[0], "-ERR reply of 5 elements"), lines[0])
+
+	// a smaller range still goes through
+	ctx = ContextTest("lrange", key, "0", "1")
+	ctx.Server.MaxReplyElements = 3
+	Call(ctx)
+	lines = ctxLines(ctx.Out)
+	assert.Equal(t, "*2", lines[0])
+
+	// admin connections bypass the cap
+	ctx = ContextTest("lrange", key, "0", "-1")
+	ctx.Server.MaxReplyElements = 3
+	ctx.Client.Namespace = sysAdminNamespace
+	Call(ctx)
+	lines = ctxLines(ctx.Out)
+	assert.Equal(t, "*5", lines[0])
+}
+
+func TestReplyBytesGuard(t *testing.T) {
+	key := "reply-size-string"
+	Call(ContextTest("set", key, strings.Repeat("x", 128)))
+
+	ctx := ContextTest("get", key)
+	ctx.Server.MaxReplyBytes = 16
+	ctx.Client.Done = make(chan struct{})
+	NewExecutor().Execute(ctx)
+	lines := ctxLines(ctx.Out)
+	assert.True(t, strings.HasPrefix(lines[0], "-ERR reply failed"), lines[0])
+	select {
+	case <-ctx.Client.Done:
+	default:
+		t.Fatal("client was not marked for closure after exceeding max-reply-bytes")
+	}
+}
@@ -0,0 +1,75 @@
+package command
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRequestBytesGuard(t *testing.T) {
+	key := "request-bytes-list"
+	for i := 0; i < 4; i++ {
+		Call(ContextTest("rpush", key, strings.Repeat("v", 32)))
+	}
+
+	// the buffered collection is charged before the reply is written
+	ctx := ContextTest("lrange", key, "0", "-1")
+	ctx.Server.MaxRequestBytes = 64
+	Call(ctx)
+	lines := ctxLines(ctx.Out)
+	assert.True(t, strings.HasPrefix(lines[0], "-ERR request exceeds"), lines[0])
+
+	// a smaller range stays within the budget
+	ctx = ContextTest("lrange", key, "0", "0")
+	ctx.Server.MaxRequestBytes = 64
+	Call(ctx)
+	lines = ctxLines(ctx.Out)
+	assert.Equal(t, "*1", lines[0])
+
+	// admin connections bypass the cap
+	ctx = ContextTest("lrange", key, "0", "-1")
+	ctx.Server.MaxRequestBytes = 64
+	ctx.Client.Namespace = sysAdminNamespace
+	Call(ctx)
+	lines = ctxLines(ctx.Out)
+	assert.Equal(t, "*4", lines[0])
+}
+
+func TestRequestBytesArgsGuard(t *testing.T) {
+	// arguments alone blow the budget, the request is refused before
+	// it runs and the connection is closed
+	ctx := ContextTest("set", "request-bytes-string", strings.Repeat("x", 128))
+	ctx.Server.MaxRequestBytes = 64
+	ctx.Client.Done = make(chan struct{})
+	NewExecutor().Execute(ctx)
+	lines := ctxLines(ctx.Out)
+	assert.True(t, strings.HasPrefix(lines[0], "-ERR request exceeds"), lines[0])
+	select {
+	case <-ctx.Client.Done:
+	default:
+		t.Fatal("client was not marked for closure after exceeding max-request-bytes")
+	}
+
+	existsCtx := ContextTest("exists", "request-bytes-string")
+	Call(existsCtx)
+	assert.Equal(t, ":0", ctxLines(existsCtx.Out)[0])
+}
+
+func TestRequestBytesReplyGuard(t *testing.T) {
+	key := "request-bytes-reply"
+	Call(ContextTest("set", key, strings.Repeat("x", 128)))
+
+	// the remaining budget caps the reply through the writer
+	ctx := ContextTest("get", key)
+	ctx.Server.MaxRequestBytes = 32
+	ctx.Client.Done = make(chan struct{})
+	NewExecutor().Execute(ctx)
+	lines := ctxLines(ctx.Out)
+	assert.True(t, strings.HasPrefix(lines[0], "-ERR reply failed"), lines[0])
+	select {
+	case <-ctx.Client.Done:
+	default:
+		t.Fatal("client was not marked for closure after exceeding max-request-bytes")
+	}
+}
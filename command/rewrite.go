@@ -0,0 +1,87 @@
+package command
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+
+	"github.com/distributedio/titan/db"
+)
+
+// Rewrite controls the background rewrite job, REWRITE START starts
+// re-encoding a namespace under an ops/sec budget and PAUSE/RESUME/STATUS
+// manage the job shared by all nodes
+func Rewrite(ctx *Context, txn *db.Transaction) (OnCommit, error) {
+	switch strings.ToLower(ctx.Args[0]) {
+	case "start":
+		if len(ctx.Args) != 2 && len(ctx.Args) != 3 {
+			return nil, ErrWrongArgs(ctx.Name)
+		}
+		opsPerSec := int64(1000)
+		if len(ctx.Args) == 3 {
+			var err error
+			if opsPerSec, err = strconv.ParseInt(ctx.Args[2], 10, 64); err != nil || opsPerSec <= 0 {
+				return nil, ErrInteger
+			}
+		}
+		job, err := db.GetRewriteJob(txn)
+		if err != nil {
+			return nil, errors.New("ERR " + err.Error())
+		}
+		if job != nil && job.State == db.RewriteRunning {
+			return nil, errors.New("ERR " + db.ErrRewriteRunning.Error())
+		}
+		job = &db.RewriteJob{
+			State:     db.RewriteRunning,
+			Namespace: ctx.Args[1],
+			OpsPerSec: opsPerSec,
+		}
+		if err := db.PutRewriteJob(txn, job); err != nil {
+			return nil, errors.New("ERR " + err.Error())
+		}
+		return SimpleString(ctx.Out, "OK"), nil
+	case "pause", "resume":
+		if len(ctx.Args) != 1 {
+			return nil, ErrWrongArgs(ctx.Name)
+		}
+		job, err := db.GetRewriteJob(txn)
+		if err != nil {
+			return nil, errors.New("ERR " + err.Error())
+		}
+		if job == nil || job.State == db.RewriteDone {
+			return nil, errors.New("ERR no rewrite job to " + strings.ToLower(ctx.Args[0]))
+		}
+		if strings.ToLower(ctx.Args[0]) == "pause" {
+			job.State = db.RewritePaused
+		} else {
+			job.State = db.RewriteRunning
+		}
+		if err := db.PutRewriteJob(txn, job); err != nil {
+			return nil, errors.New("ERR " + err.Error())
+		}
+		return SimpleString(ctx.Out, "OK"), nil
+	case "status":
+		if len(ctx.Args) != 1 {
+			return nil, ErrWrongArgs(ctx.Name)
+		}
+		job, err := db.GetRewriteJob(txn)
+		if err != nil {
+			return nil, errors.New("ERR " + err.Error())
+		}
+		var lines []string
+		if job == nil {
+			lines = append(lines, "state:none")
+		} else {
+			lines = append(lines, "state:"+job.State)
+			lines = append(lines, "namespace:"+job.Namespace)
+			lines = append(lines, "ops_per_sec:"+strconv.FormatInt(job.OpsPerSec, 10))
+			lines = append(lines, "rewritten:"+strconv.FormatInt(job.Rewritten, 10))
+		}
+		return BulkString(ctx.Out, strings.Join(lines, "\n")+"\n"), nil
+	case "help":
+		lines, _ := subcommandHelpLines("rewrite")
+		return BytesArray(ctx.Out, lines), nil
+	default:
+		return nil, errors.New("ERR Syntax error, try REWRITE (START namespace [ops_per_sec] | PAUSE | RESUME | STATUS | HELP)")
+	}
+}
@@ -0,0 +1,74 @@
+package command
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+
+	"github.com/distributedio/titan/db"
+	"github.com/distributedio/titan/encoding/resp"
+)
+
+// The SEQ.* commands hand out unique increasing ids from named counters.
+// Ids are reserved from tikv in whole blocks and served from a per-node
+// cache, so id generation does not pile up write conflicts on one key the
+// way INCR does
+
+// SeqNext returns a unique id of the named sequence, BY n reserves n
+// consecutive ids and returns the last one so the caller owns the n ids
+// ending at the reply
+func SeqNext(ctx *Context, txn *db.Transaction) (OnCommit, error) {
+	n := int64(1)
+	if len(ctx.Args) > 1 {
+		if len(ctx.Args) != 3 || strings.ToUpper(ctx.Args[1]) != "BY" {
+			return nil, ErrSyntax
+		}
+		var err error
+		if n, err = strconv.ParseInt(ctx.Args[2], 10, 64); err != nil {
+			return nil, ErrInteger
+		}
+		if n <= 0 {
+			return nil, errors.New("ERR BY must be a positive integer")
+		}
+	}
+
+	val, publish, err := txn.Sequence([]byte(ctx.Args[0])).Next(n)
+	if err != nil {
+		return nil, errors.New("ERR " + err.Error())
+	}
+	return func() {
+		// a freshly reserved block may only enter the cache once the
+		// reservation is committed
+		if publish != nil {
+			publish()
+		}
+		resp.ReplyInteger(ctx.Out, val)
+	}, nil
+}
+
+// SeqSet rewrites the named sequence so ids after the next reservation
+// start from value
+func SeqSet(ctx *Context, txn *db.Transaction) (OnCommit, error) {
+	v, err := strconv.ParseInt(ctx.Args[1], 10, 64)
+	if err != nil {
+		return nil, ErrInteger
+	}
+	drop, err := txn.Sequence([]byte(ctx.Args[0])).Set(v)
+	if err != nil {
+		return nil, errors.New("ERR " + err.Error())
+	}
+	return func() {
+		drop()
+		resp.ReplySimpleString(ctx.Out, OK)
+	}, nil
+}
+
+// SeqGet returns the persisted high water mark of the named sequence,
+// zero when it was never used
+func SeqGet(ctx *Context, txn *db.Transaction) (OnCommit, error) {
+	v, err := txn.Sequence([]byte(ctx.Args[0])).Get()
+	if err != nil {
+		return nil, errors.New("ERR " + err.Error())
+	}
+	return Integer(ctx.Out, v), nil
+}
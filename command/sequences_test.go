@@ -0,0 +1,42 @@
+package command
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSeqCommands(t *testing.T) {
+	name := "seq-commands-test"
+
+	ctx := ContextTest("seq.next", name)
+	Call(ctx)
+	assert.Equal(t, ":1", ctxLines(ctx.Out)[0])
+
+	// BY reserves a consecutive run ending at the reply
+	ctx = ContextTest("seq.next", name, "by", "10")
+	Call(ctx)
+	assert.Equal(t, ":11", ctxLines(ctx.Out)[0])
+
+	// GET reports the persisted high water mark, not the last id
+	ctx = ContextTest("seq.get", name)
+	Call(ctx)
+	assert.Equal(t, ":1000", ctxLines(ctx.Out)[0])
+
+	ctx = ContextTest("seq.set", name, "5000")
+	Call(ctx)
+	assert.Equal(t, "+OK", ctxLines(ctx.Out)[0])
+
+	ctx = ContextTest("seq.next", name)
+	Call(ctx)
+	assert.Equal(t, ":5001", ctxLines(ctx.Out)[0])
+
+	ctx = ContextTest("seq.next", name, "by", "0")
+	Call(ctx)
+	assert.True(t, strings.HasPrefix(ctxLines(ctx.Out)[0], "-ERR BY must be"), ctxLines(ctx.Out)[0])
+
+	ctx = ContextTest("seq.next", name, "with", "2")
+	Call(ctx)
+	assert.True(t, strings.HasPrefix(ctxLines(ctx.Out)[0], "-ERR syntax"), ctxLines(ctx.Out)[0])
+}
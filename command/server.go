@@ -24,7 +24,7 @@ func Monitor(ctx *Context) {
 
 // Client manages client connections
 func Client(ctx *Context) {
-	syntaxErr := "ERR Syntax error, try CLIENT (LIST | KILL | GETNAME | SETNAME | PAUSE | REPLY)"
+	syntaxErr := "ERR Syntax error, try CLIENT (LIST | KILL | GETNAME | SETNAME | PAUSE | UNPAUSE | REPLY)"
 	list := func(ctx *Context) {
 		now := time.Now()
 		var lines []string
@@ -42,10 +42,14 @@ func Client(ctx *Context) {
 			}
 
 			// id=2 addr=127.0.0.1:39604 fd=6 name= age=196 idle=2 flags=N db=0 sub=0 psub=0 multi=-1 qbuf=0 qbuf-free=0 obl=0 oll=0 omem=0 events=r cmd=client
+			// tot-cmds and fast-cmds are titan extensions, the commands
+			// received on the connection and how many of them were answered
+			// on the keepalive fast path
 			line := fmt.Sprintf("id=%d addr=%s fd=%d name=%s age=%d idle=%d "+
-				"flags=%s db=%d sub=%d psub=%d multi=%d qbuf=%d qbuf-free=%d obl=%d oll=%d omem=%d events=%s cmd=%s\n",
+				"flags=%s db=%d sub=%d psub=%d multi=%d qbuf=%d qbuf-free=%d obl=%d oll=%d omem=%d events=%s cmd=%s "+
+				"tot-cmds=%d fast-cmds=%d\n",
 				client.ID, client.RemoteAddr, 0, client.Name, age, idle, flags, client.DB.ID, 0, 0, len(client.Commands),
-				0, 0, 0, 0, 0, "rw", client.LastCmd)
+				0, 0, 0, 0, 0, "rw", client.LastCmd, client.CommandCount, client.FastPathCount)
 			lines = append(lines, line)
 			return true
 		})
@@ -74,16 +78,39 @@ func Client(ctx *Context) {
 			return
 		}
 		args := ctx.Args[1:]
-		if len(args) != 1 {
+		if len(args) != 1 && len(args) != 2 {
 			resp.ReplyError(ctx.Out, syntaxErr)
 			return
 		}
 		msec, err := strconv.ParseInt(args[0], 10, 64)
-		if err != nil {
+		if err != nil || msec < 0 {
 			resp.ReplyError(ctx.Out, "ERR timeout is not an integer or out of range")
 			return
 		}
-		ctx.Server.Pause = time.Duration(msec) * time.Millisecond
+		writesOnly := false
+		if len(args) == 2 {
+			switch strings.ToLower(args[1]) {
+			case "write":
+				writesOnly = true
+			case "all":
+			default:
+				resp.ReplyError(ctx.Out, syntaxErr)
+				return
+			}
+		}
+		ctx.Server.ClientPause.Set(time.Duration(msec)*time.Millisecond, writesOnly)
+		resp.ReplySimpleString(ctx.Out, "OK")
+	}
+	unpause := func(ctx *Context) {
+		if ctx.Client.Namespace != sysAdminNamespace {
+			resp.ReplyError(ctx.Out, "ERR client unpause can be used by $sys.admin only")
+			return
+		}
+		if len(ctx.Args) != 1 {
+			resp.ReplyError(ctx.Out, syntaxErr)
+			return
+		}
+		ctx.Server.ClientPause.Clear()
 		resp.ReplySimpleString(ctx.Out, "OK")
 	}
 	reply := func(ctx *Context) {
@@ -185,7 +212,7 @@ func Client(ctx *Context) {
 			resp.ReplyInteger(ctx.Out, int64(killed))
 		}
 		if closeSelf {
-			close(ctx.Client.Done)
+			ctx.Client.Kick()
 		}
 	}
 
@@ -203,6 +230,10 @@ func Client(ctx *Context) {
 		reply(ctx)
 	case "pause":
 		pause(ctx)
+	case "unpause":
+		unpause(ctx)
+	case "help":
+		replySubcommandHelp(ctx.Out, "client")
 	default:
 		resp.ReplyError(ctx.Out, syntaxErr)
 	}
@@ -214,19 +245,243 @@ func Debug(ctx *Context, txn *db.Transaction) (OnCommit, error) {
 	switch strings.ToLower(ctx.Args[0]) {
 	case "object":
 		return debugObject(ctx, txn)
+	case "committs":
+		return debugCommitTS(ctx, txn)
+	case "diff":
+		return debugDiff(ctx, txn)
+	case "layout":
+		return debugLayout(ctx, txn)
+	case "quickcheck":
+		return debugQuickCheck(ctx, txn)
+	case "trace":
+		return debugTrace(ctx)
+	case "breaker":
+		return debugBreaker(ctx)
+	case "ttlhistory":
+		return debugTTLHistory(ctx)
+	case "help":
+		lines, _ := subcommandHelpLines("debug")
+		return BytesArray(ctx.Out, lines), nil
 	default:
 		return nil, errors.New("ERR not supported")
 	}
 }
+
+// debugCommitTS resolves the tikv commit version of the last write to a
+// key, DEBUG COMMITTS <key>. The version lets applications order titan
+// writes against external tidb/tikv readers of the same cluster
+func debugCommitTS(ctx *Context, txn *db.Transaction) (OnCommit, error) {
+	if len(ctx.Args) != 2 {
+		return nil, ErrWrongArgs(ctx.Name)
+	}
+	ver, err := txn.CommitVersion([]byte(ctx.Args[1]))
+	if err != nil {
+		if err == db.ErrKeyNotFound {
+			return NullBulkString(ctx.Out), nil
+		}
+		return nil, errors.New("ERR " + err.Error())
+	}
+	return BulkString(ctx.Out, strconv.FormatUint(ver, 10)), nil
+}
+
+// debugTrace toggles the sampled command trace of a namespace at runtime,
+// DEBUG TRACE <namespace> <every> logs one of every "every" commands and
+// zero turns tracing off
+func debugTrace(ctx *Context) (OnCommit, error) {
+	if len(ctx.Args) != 3 {
+		return nil, ErrWrongArgs(ctx.Name)
+	}
+	every, err := strconv.ParseInt(ctx.Args[2], 10, 64)
+	if err != nil {
+		return nil, ErrInteger
+	}
+	SetCommandTrace(ctx.Args[1], every)
+	return SimpleString(ctx.Out, OK), nil
+}
+
+// debugBreaker inspects or overrides the tikv circuit breaker, STATUS is
+// open to everyone while the overrides are restricted to the operator
+// namespace since they affect every tenant of the node
+func debugBreaker(ctx *Context) (OnCommit, error) {
+	b := db.GetBreaker()
+	if b == nil {
+		return nil, errors.New("ERR breaker is not enabled")
+	}
+	if len(ctx.Args) != 2 {
+		return nil, ErrWrongArgs(ctx.Name)
+	}
+	sub := strings.ToLower(ctx.Args[1])
+	if sub == "status" {
+		return SimpleString(ctx.Out, b.State()), nil
+	}
+	if ctx.Client.Namespace != sysAdminNamespace {
+		return nil, errors.New("ERR debug breaker can be used by $sys.admin only")
+	}
+	switch sub {
+	case "open":
+		b.SetMode(db.BreakerForceOpen)
+	case "close":
+		b.SetMode(db.BreakerForceClosed)
+	case "auto":
+		b.SetMode(db.BreakerAuto)
+	default:
+		return nil, ErrWrongArgs(ctx.Name)
+	}
+	return SimpleString(ctx.Out, OK), nil
+}
+
+// debugTTLHistory controls and queries the ttl journal. DEBUG TTLHISTORY
+// <namespace> <depth> switches the journal of a namespace with depth 0
+// turning it off, DEBUG TTLHISTORY <key> lists the recorded ttl mutations
+// of a key of the client's namespace, oldest first
+func debugTTLHistory(ctx *Context) (OnCommit, error) {
+	switch len(ctx.Args) {
+	case 3:
+		depth, err := strconv.ParseInt(ctx.Args[2], 10, 64)
+		if err != nil {
+			return nil, ErrInteger
+		}
+		SetTTLHistory(ctx.Args[1], depth)
+		return SimpleString(ctx.Out, OK), nil
+	case 2:
+		entries := ttlHistoryOf(ctx.Client.Namespace, []byte(ctx.Args[1]))
+		lines := make([][]byte, 0, len(entries))
+		for _, e := range entries {
+			lines = append(lines, []byte(strconv.FormatInt(e.at.Unix(), 10)+
+				" "+e.command+" "+e.action+" "+e.client))
+		}
+		return BytesArray(ctx.Out, lines), nil
+	default:
+		return nil, ErrWrongArgs(ctx.Name)
+	}
+}
+
 func debugObject(ctx *Context, txn *db.Transaction) (OnCommit, error) {
 	key := []byte(ctx.Args[1])
-	obj, err := txn.Object(key)
+	obj, err := txn.Kv().Object(key)
 	if err != nil {
 		return nil, errors.New("ERR " + err.Error())
 	}
 	return SimpleString(ctx.Out, obj.String()), nil
 }
 
+// debugLayout verifies that the metas of the client's db match the
+// documented storage layout, DEBUG LAYOUT [cursor [count]]. External
+// TiDB/TiSpark readers depend on the layout, the report names every key in
+// an unknown encoding. The reply starts with the layout version, the
+// scanned count and the cursor to continue from
+func debugLayout(ctx *Context, txn *db.Transaction) (OnCommit, error) {
+	if len(ctx.Args) > 3 {
+		return nil, ErrWrongArgs(ctx.Name)
+	}
+	var cursor []byte
+	if len(ctx.Args) >= 2 {
+		cursor = []byte(ctx.Args[1])
+	}
+	count := int64(defaultScanCount)
+	if len(ctx.Args) == 3 {
+		c, err := strconv.ParseInt(ctx.Args[2], 10, 64)
+		if err != nil {
+			return nil, ErrInteger
+		}
+		if c <= 0 || c > ScanMaxCount {
+			return nil, ErrInteger
+		}
+		count = c
+	}
+	report, scanned, next, err := txn.LayoutCheck(cursor, int(count))
+	if err != nil {
+		return nil, errors.New("ERR " + err.Error())
+	}
+	lines := make([][]byte, 0, len(report)+3)
+	lines = append(lines, []byte(fmt.Sprintf("version: %d", db.LayoutVersion)))
+	lines = append(lines, []byte(fmt.Sprintf("scanned: %d", scanned)))
+	if next == nil {
+		lines = append(lines, []byte("cursor: (end)"))
+	} else {
+		lines = append(lines, []byte(fmt.Sprintf("cursor: %s", string(next))))
+	}
+	for i := range report {
+		lines = append(lines, []byte(report[i]))
+	}
+	return BytesArray(ctx.Out, lines), nil
+}
+
+// debugDiff compares the db of the caller at two tikv versions, DEBUG
+// DIFF <from> <to> [cursor [count]] emits added, removed and changed keys
+// between the two snapshots in cursor-bounded batches, "now" pins the
+// version of the current transaction. DEBUG COMMITTS resolves versions to
+// feed it
+func debugDiff(ctx *Context, txn *db.Transaction) (OnCommit, error) {
+	if len(ctx.Args) < 3 || len(ctx.Args) > 5 {
+		return nil, ErrWrongArgs(ctx.Name)
+	}
+	version := func(arg string) (uint64, error) {
+		if strings.ToLower(arg) == "now" {
+			return txn.SnapshotVersion(), nil
+		}
+		return strconv.ParseUint(arg, 10, 64)
+	}
+	from, err := version(ctx.Args[1])
+	if err != nil {
+		return nil, ErrInteger
+	}
+	to, err := version(ctx.Args[2])
+	if err != nil {
+		return nil, ErrInteger
+	}
+	var cursor []byte
+	if len(ctx.Args) >= 4 {
+		cursor = []byte(ctx.Args[3])
+	}
+	count := int64(defaultScanCount)
+	if len(ctx.Args) == 5 {
+		c, err := strconv.ParseInt(ctx.Args[4], 10, 64)
+		if err != nil || c <= 0 || c > ScanMaxCount {
+			return nil, ErrInteger
+		}
+		count = c
+	}
+	report, next, err := txn.SnapshotDiff(from, to, cursor, int(count))
+	if err != nil {
+		return nil, errors.New("ERR " + err.Error())
+	}
+	lines := make([][]byte, 0, len(report)+1)
+	if next == nil {
+		lines = append(lines, []byte("cursor: (end)"))
+	} else {
+		lines = append(lines, []byte(fmt.Sprintf("cursor: %s", string(next))))
+	}
+	for i := range report {
+		lines = append(lines, []byte(report[i]))
+	}
+	return BytesArray(ctx.Out, lines), nil
+}
+
+// debugQuickCheck verifies data consistency of the object stored at key,
+// an optional trailing "repair" fixes the reported discrepancies
+func debugQuickCheck(ctx *Context, txn *db.Transaction) (OnCommit, error) {
+	if len(ctx.Args) < 2 || len(ctx.Args) > 3 {
+		return nil, ErrWrongArgs(ctx.Name)
+	}
+	repair := false
+	if len(ctx.Args) == 3 {
+		if strings.ToLower(ctx.Args[2]) != "repair" {
+			return nil, ErrSyntax
+		}
+		repair = true
+	}
+	report, err := txn.QuickCheck([]byte(ctx.Args[1]), repair)
+	if err != nil {
+		return nil, errors.New("ERR " + err.Error())
+	}
+	lines := make([][]byte, len(report))
+	for i := range report {
+		lines[i] = []byte(report[i])
+	}
+	return BytesArray(ctx.Out, lines), nil
+}
+
 // RedisCommand returns Array reply of details about all Redis commands
 func RedisCommand(ctx *Context) {
 	count := func(ctx *Context) {
@@ -320,6 +575,8 @@ func RedisCommand(ctx *Context) {
 		getkeys(ctx)
 	case "info":
 		info(ctx)
+	case "help":
+		replySubcommandHelp(ctx.Out, "command")
 	default:
 		resp.ReplyError(ctx.Out, "ERR Unknown subcommand or wrong number of arguments.")
 	}
@@ -391,6 +648,36 @@ func Info(ctx *Context) {
 	lines = append(lines, "blocked_clients:0")
 	lines = append(lines, "client_namespace:"+ctx.Client.Namespace)
 
+	lines = append(lines, "# Tikv")
+	lastCommit, commitStreak, beginStreak := db.CommitHealth()
+	var lastCommitAt int64
+	if !lastCommit.IsZero() {
+		lastCommitAt = lastCommit.Unix()
+	}
+	lines = append(lines, "last_successful_commit_at:"+strconv.FormatInt(lastCommitAt, 10))
+	lines = append(lines, "commit_error_streak:"+strconv.FormatInt(commitStreak, 10))
+	lines = append(lines, "begin_error_streak:"+strconv.FormatInt(beginStreak, 10))
+
+	lines = append(lines, "# Stats")
+	lines = append(lines, "lazy_deleted_keys:"+strconv.FormatInt(db.LazyDeleteCount(), 10))
+
+	lines = append(lines, "# Keyspace")
+	if info, err := keyspaceInfo(ctx.Client.DB); err == nil && info != nil {
+		lines = append(lines, "db0:keys="+strconv.FormatInt(info.Keys, 10)+
+			",bytes="+strconv.FormatInt(info.Bytes, 10)+
+			",reconciled_at="+strconv.FormatInt(info.ReconciledAt/int64(time.Second), 10)+
+			",reconcile_delta="+strconv.FormatInt(info.Delta, 10))
+	}
+
 	resp.ReplyBulkString(ctx.Out, strings.Join(lines, "\n")+"\n")
 	return
 }
+
+// keyspaceInfo reads the reconciled key counter of the client db, a client
+// that has not selected a db yet has none
+func keyspaceInfo(d *db.DB) (*db.DBSizeInfo, error) {
+	if d == nil {
+		return nil, nil
+	}
+	return db.GetDBSizeInfo(d)
+}
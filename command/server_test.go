@@ -3,6 +3,7 @@ package command
 import (
 	"bytes"
 	"io/ioutil"
+	"strconv"
 	"strings"
 	"testing"
 	"time"
@@ -30,6 +31,38 @@ func TestInfo(t *testing.T) {
 	if strings.Index(out.String(), "ERR") == 0 {
 		t.Fail()
 	}
+	assert.Contains(t, out.String(), "last_successful_commit_at:")
+	assert.Contains(t, out.String(), "commit_error_streak:")
+}
+
+func TestDebugBreaker(t *testing.T) {
+	ctx := ContextTest("debug", "breaker", "status")
+	Call(ctx)
+	assert.Equal(t, "+closed", ctxLines(ctx.Out)[0])
+
+	// overrides are for operators only
+	ctx = ContextTest("debug", "breaker", "open")
+	Call(ctx)
+	assert.Contains(t, ctxLines(ctx.Out)[0], "$sys.admin only")
+
+	ctx = ContextTest("debug", "breaker", "open")
+	ctx.Client.Namespace = sysAdminNamespace
+	Call(ctx)
+	assert.Equal(t, "+OK", ctxLines(ctx.Out)[0])
+
+	// transactional commands of a tenant fail fast while forced open
+	ctx = ContextTest("get", "breaker-key")
+	Call(ctx)
+	assert.Contains(t, ctxLines(ctx.Out)[0], "-TRYAGAIN")
+
+	ctx = ContextTest("debug", "breaker", "auto")
+	ctx.Client.Namespace = sysAdminNamespace
+	Call(ctx)
+	assert.Equal(t, "+OK", ctxLines(ctx.Out)[0])
+
+	ctx = ContextTest("get", "breaker-key")
+	Call(ctx)
+	assert.Equal(t, "$-1", ctxLines(ctx.Out)[0])
 }
 
 func TestMonitor(t *testing.T) {
@@ -93,3 +126,24 @@ func TestClient_List(t *testing.T) {
 
 	assert.Contains(out.String(), "id=1 addr=127.0.0.1")
 }
+
+func TestDebugCommitTS(t *testing.T) {
+	ctx := ContextTest("debug", "committs", "committs-missing")
+	Call(ctx)
+	assert.Equal(t, "$-1", ctxLines(ctx.Out)[0])
+
+	Call(ContextTest("set", "committs-key", "v1"))
+	ctx = ContextTest("debug", "committs", "committs-key")
+	Call(ctx)
+	first, err := strconv.ParseUint(ctxLines(ctx.Out)[1], 10, 64)
+	assert.NoError(t, err)
+	assert.True(t, first > 0)
+
+	// a later write commits at a later version
+	Call(ContextTest("set", "committs-key", "v2"))
+	ctx = ContextTest("debug", "committs", "committs-key")
+	Call(ctx)
+	second, err := strconv.ParseUint(ctxLines(ctx.Out)[1], 10, 64)
+	assert.NoError(t, err)
+	assert.True(t, second > first)
+}
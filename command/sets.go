@@ -44,6 +44,12 @@ func SMembers(ctx *Context, txn *db.Transaction) (OnCommit, error) {
 	if err != nil {
 		return nil, errors.New("ERR " + err.Error())
 	}
+	if err := checkReplySize(ctx, len(members)); err != nil {
+		return nil, err
+	}
+	if err := accountReplySize(ctx, members); err != nil {
+		return nil, err
+	}
 	return BytesArray(ctx.Out, members), nil
 }
 
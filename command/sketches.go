@@ -0,0 +1,293 @@
+package command
+
+import (
+	"errors"
+	"io"
+	"strconv"
+
+	"github.com/distributedio/titan/db"
+	"github.com/distributedio/titan/encoding/resp"
+)
+
+// The TOPK.* and CMS.* commands follow the RedisBloom module API. The
+// sketches are serialized into plain string values, so DEL, EXPIRE and TYPE
+// treat them as strings
+
+var (
+	// ErrCMSExists is returned when CMS.INITBYDIM hits an existing key
+	ErrCMSExists = errors.New("ERR CMS: key already exists")
+
+	// ErrCMSNotExist is returned when a CMS.* command hits a missing key
+	ErrCMSNotExist = errors.New("ERR CMS: key does not exist")
+
+	// ErrTopKNotExist is returned when a TOPK.* command hits a missing key
+	ErrTopKNotExist = errors.New("ERR TopK: key does not exist")
+
+	// ErrSketchValue is returned when the string value holds no sketch of
+	// the expected kind
+	ErrSketchValue = errors.New("ERR invalid sketch value")
+)
+
+// IntegerArray replies an array of integers when commit
+func IntegerArray(w io.Writer, a []int64) OnCommit {
+	return func() {
+		resp.ReplyArray(w, len(a))
+		for _, v := range a {
+			resp.ReplyInteger(w, v)
+		}
+	}
+}
+
+// sketchString loads the string object holding a sketch
+func sketchString(ctx *Context, txn *db.Transaction) (*db.String, error) {
+	str, err := txn.String([]byte(ctx.Args[0]))
+	if err != nil {
+		if err == db.ErrTypeMismatch {
+			return nil, ErrTypeMismatch
+		}
+		return nil, errors.New("ERR " + err.Error())
+	}
+	return str, nil
+}
+
+func loadCMSketch(ctx *Context, txn *db.Transaction) (*db.String, *db.CMSketch, error) {
+	str, err := sketchString(ctx, txn)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !str.Exist() {
+		return nil, nil, ErrCMSNotExist
+	}
+	val, err := str.Get()
+	if err != nil {
+		return nil, nil, errors.New("ERR " + err.Error())
+	}
+	sketch, err := db.DecodeCMSketch(val)
+	if err != nil {
+		return nil, nil, ErrSketchValue
+	}
+	return str, sketch, nil
+}
+
+func loadTopK(ctx *Context, txn *db.Transaction) (*db.String, *db.TopK, error) {
+	str, err := sketchString(ctx, txn)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !str.Exist() {
+		return nil, nil, ErrTopKNotExist
+	}
+	val, err := str.Get()
+	if err != nil {
+		return nil, nil, errors.New("ERR " + err.Error())
+	}
+	sketch, err := db.DecodeTopK(val)
+	if err != nil {
+		return nil, nil, ErrSketchValue
+	}
+	return str, sketch, nil
+}
+
+// CMSInitByDim initializes a count-min sketch with the given dimensions
+func CMSInitByDim(ctx *Context, txn *db.Transaction) (OnCommit, error) {
+	width, err := strconv.ParseInt(ctx.Args[1], 10, 64)
+	if err != nil {
+		return nil, ErrInteger
+	}
+	depth, err := strconv.ParseInt(ctx.Args[2], 10, 64)
+	if err != nil {
+		return nil, ErrInteger
+	}
+
+	str, err := sketchString(ctx, txn)
+	if err != nil {
+		return nil, err
+	}
+	if str.Exist() {
+		return nil, ErrCMSExists
+	}
+	sketch, err := db.NewCMSketch(width, depth)
+	if err != nil {
+		return nil, errors.New("ERR " + err.Error())
+	}
+	if err := str.Set(sketch.Encode()); err != nil {
+		return nil, errors.New("ERR " + err.Error())
+	}
+	return SimpleString(ctx.Out, "OK"), nil
+}
+
+// CMSIncrBy increases the count of items and replies the new estimates
+func CMSIncrBy(ctx *Context, txn *db.Transaction) (OnCommit, error) {
+	kvs := ctx.Args[1:]
+	if len(kvs)%2 != 0 {
+		return nil, ErrWrongArgs(ctx.Name)
+	}
+	str, sketch, err := loadCMSketch(ctx, txn)
+	if err != nil {
+		return nil, err
+	}
+	counts := make([]int64, 0, len(kvs)/2)
+	for i := 0; i < len(kvs)-1; i += 2 {
+		delta, err := strconv.ParseUint(kvs[i+1], 10, 32)
+		if err != nil {
+			return nil, ErrInteger
+		}
+		counts = append(counts, int64(sketch.IncrBy([]byte(kvs[i]), uint32(delta))))
+	}
+	if err := str.Set(sketch.Encode()); err != nil {
+		return nil, errors.New("ERR " + err.Error())
+	}
+	return IntegerArray(ctx.Out, counts), nil
+}
+
+// CMSQuery replies the count estimates of items
+func CMSQuery(ctx *Context, txn *db.Transaction) (OnCommit, error) {
+	_, sketch, err := loadCMSketch(ctx, txn)
+	if err != nil {
+		return nil, err
+	}
+	counts := make([]int64, 0, len(ctx.Args)-1)
+	for _, item := range ctx.Args[1:] {
+		counts = append(counts, int64(sketch.Query([]byte(item))))
+	}
+	return IntegerArray(ctx.Out, counts), nil
+}
+
+// CMSInfo replies the dimensions and total count of the sketch
+func CMSInfo(ctx *Context, txn *db.Transaction) (OnCommit, error) {
+	_, sketch, err := loadCMSketch(ctx, txn)
+	if err != nil {
+		return nil, err
+	}
+	width, depth, count := sketch.Info()
+	return func() {
+		resp.ReplyArray(ctx.Out, 6)
+		resp.ReplyBulkString(ctx.Out, "width")
+		resp.ReplyInteger(ctx.Out, width)
+		resp.ReplyBulkString(ctx.Out, "depth")
+		resp.ReplyInteger(ctx.Out, depth)
+		resp.ReplyBulkString(ctx.Out, "count")
+		resp.ReplyInteger(ctx.Out, count)
+	}, nil
+}
+
+// TopKReserve initializes a top-k sketch, width, depth and decay are
+// optional and default to 8, 7 and 0.9 like the RedisBloom module
+func TopKReserve(ctx *Context, txn *db.Transaction) (OnCommit, error) {
+	if len(ctx.Args) != 2 && len(ctx.Args) != 5 {
+		return nil, ErrWrongArgs(ctx.Name)
+	}
+	k, err := strconv.ParseInt(ctx.Args[1], 10, 64)
+	if err != nil {
+		return nil, ErrInteger
+	}
+	width, depth, decay := int64(8), int64(7), 0.9
+	if len(ctx.Args) == 5 {
+		if width, err = strconv.ParseInt(ctx.Args[2], 10, 64); err != nil {
+			return nil, ErrInteger
+		}
+		if depth, err = strconv.ParseInt(ctx.Args[3], 10, 64); err != nil {
+			return nil, ErrInteger
+		}
+		if decay, err = strconv.ParseFloat(ctx.Args[4], 64); err != nil {
+			return nil, ErrFloat
+		}
+	}
+
+	str, err := sketchString(ctx, txn)
+	if err != nil {
+		return nil, err
+	}
+	if str.Exist() {
+		return nil, errors.New("ERR TopK: key already exists")
+	}
+	sketch, err := db.NewTopK(k, width, depth, decay)
+	if err != nil {
+		return nil, errors.New("ERR " + err.Error())
+	}
+	if err := str.Set(sketch.Encode()); err != nil {
+		return nil, errors.New("ERR " + err.Error())
+	}
+	return SimpleString(ctx.Out, "OK"), nil
+}
+
+// TopKAdd counts items and replies the item each add expelled from the top
+// list, a null bulk string when none was
+func TopKAdd(ctx *Context, txn *db.Transaction) (OnCommit, error) {
+	str, sketch, err := loadTopK(ctx, txn)
+	if err != nil {
+		return nil, err
+	}
+	expelled := make([][]byte, 0, len(ctx.Args)-1)
+	for _, item := range ctx.Args[1:] {
+		expelled = append(expelled, sketch.Add([]byte(item)))
+	}
+	if err := str.Set(sketch.Encode()); err != nil {
+		return nil, errors.New("ERR " + err.Error())
+	}
+	return BytesArray(ctx.Out, expelled), nil
+}
+
+// TopKQuery replies for every item whether it is in the top list
+func TopKQuery(ctx *Context, txn *db.Transaction) (OnCommit, error) {
+	_, sketch, err := loadTopK(ctx, txn)
+	if err != nil {
+		return nil, err
+	}
+	found := make([]int64, 0, len(ctx.Args)-1)
+	for _, item := range ctx.Args[1:] {
+		if sketch.Query([]byte(item)) {
+			found = append(found, 1)
+		} else {
+			found = append(found, 0)
+		}
+	}
+	return IntegerArray(ctx.Out, found), nil
+}
+
+// TopKCount replies the count estimates of items
+func TopKCount(ctx *Context, txn *db.Transaction) (OnCommit, error) {
+	_, sketch, err := loadTopK(ctx, txn)
+	if err != nil {
+		return nil, err
+	}
+	counts := make([]int64, 0, len(ctx.Args)-1)
+	for _, item := range ctx.Args[1:] {
+		counts = append(counts, int64(sketch.Count([]byte(item))))
+	}
+	return IntegerArray(ctx.Out, counts), nil
+}
+
+// TopKList replies the tracked items ordered by count descending
+func TopKList(ctx *Context, txn *db.Transaction) (OnCommit, error) {
+	_, sketch, err := loadTopK(ctx, txn)
+	if err != nil {
+		return nil, err
+	}
+	items := sketch.List()
+	list := make([][]byte, 0, len(items))
+	for _, item := range items {
+		list = append(list, item.Item)
+	}
+	return BytesArray(ctx.Out, list), nil
+}
+
+// TopKInfo replies the parameters of the sketch
+func TopKInfo(ctx *Context, txn *db.Transaction) (OnCommit, error) {
+	_, sketch, err := loadTopK(ctx, txn)
+	if err != nil {
+		return nil, err
+	}
+	k, width, depth, decay := sketch.Info()
+	return func() {
+		resp.ReplyArray(ctx.Out, 8)
+		resp.ReplyBulkString(ctx.Out, "k")
+		resp.ReplyInteger(ctx.Out, k)
+		resp.ReplyBulkString(ctx.Out, "width")
+		resp.ReplyInteger(ctx.Out, width)
+		resp.ReplyBulkString(ctx.Out, "depth")
+		resp.ReplyInteger(ctx.Out, depth)
+		resp.ReplyBulkString(ctx.Out, "decay")
+		resp.ReplyBulkString(ctx.Out, strconv.FormatFloat(decay, 'g', -1, 64))
+	}, nil
+}
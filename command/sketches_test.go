@@ -0,0 +1,107 @@
+package command
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func clearSketch(t *testing.T, keys ...string) {
+	for _, key := range keys {
+		ctx := ContextTest("del", key)
+		Call(ctx)
+		assert.Equal(t, ":1", ctxLines(ctx.Out)[0])
+	}
+}
+
+func TestCMSCommands(t *testing.T) {
+	key := "cms-key"
+
+	ctx := ContextTest("cms.incrby", key, "a", "1")
+	Call(ctx)
+	assert.Equal(t, "-ERR CMS: key does not exist", ctxLines(ctx.Out)[0])
+
+	ctx = ContextTest("cms.initbydim", key, "100", "4")
+	Call(ctx)
+	assert.Equal(t, "+OK", ctxLines(ctx.Out)[0])
+
+	ctx = ContextTest("cms.initbydim", key, "100", "4")
+	Call(ctx)
+	assert.Equal(t, "-ERR CMS: key already exists", ctxLines(ctx.Out)[0])
+
+	ctx = ContextTest("cms.incrby", key, "a", "3", "b", "2")
+	Call(ctx)
+	lines := ctxLines(ctx.Out)
+	assert.Equal(t, "*2", lines[0])
+	assert.Equal(t, ":3", lines[1])
+	assert.Equal(t, ":2", lines[2])
+
+	ctx = ContextTest("cms.query", key, "a", "b", "missing")
+	Call(ctx)
+	lines = ctxLines(ctx.Out)
+	assert.Equal(t, "*3", lines[0])
+	assert.Equal(t, ":3", lines[1])
+	assert.Equal(t, ":2", lines[2])
+	assert.Equal(t, ":0", lines[3])
+
+	ctx = ContextTest("cms.info", key)
+	Call(ctx)
+	lines = ctxLines(ctx.Out)
+	assert.Equal(t, "*6", lines[0])
+	assert.Equal(t, "width", lines[2])
+	assert.Equal(t, ":100", lines[3])
+	assert.Equal(t, ":5", lines[9])
+
+	// a plain string value is rejected, not misread
+	ctx = ContextTest("set", "cms-plain", "value")
+	Call(ctx)
+	ctx = ContextTest("cms.query", "cms-plain", "a")
+	Call(ctx)
+	assert.Equal(t, "-ERR invalid sketch value", ctxLines(ctx.Out)[0])
+
+	clearSketch(t, key, "cms-plain")
+}
+
+func TestTopKCommands(t *testing.T) {
+	key := "topk-key"
+
+	ctx := ContextTest("topk.add", key, "a")
+	Call(ctx)
+	assert.Equal(t, "-ERR TopK: key does not exist", ctxLines(ctx.Out)[0])
+
+	ctx = ContextTest("topk.reserve", key, "2", "100", "4", "0.9")
+	Call(ctx)
+	assert.Equal(t, "+OK", ctxLines(ctx.Out)[0])
+
+	ctx = ContextTest("topk.add", key, "a", "a", "a", "b", "b", "c")
+	Call(ctx)
+	assert.Equal(t, "*6", ctxLines(ctx.Out)[0])
+
+	ctx = ContextTest("topk.query", key, "a", "missing")
+	Call(ctx)
+	lines := ctxLines(ctx.Out)
+	assert.Equal(t, ":1", lines[1])
+	assert.Equal(t, ":0", lines[2])
+
+	ctx = ContextTest("topk.count", key, "a", "b")
+	Call(ctx)
+	lines = ctxLines(ctx.Out)
+	assert.Equal(t, ":3", lines[1])
+	assert.Equal(t, ":2", lines[2])
+
+	ctx = ContextTest("topk.list", key)
+	Call(ctx)
+	lines = ctxLines(ctx.Out)
+	assert.Equal(t, "*2", lines[0])
+	assert.Equal(t, "a", lines[2])
+	assert.Equal(t, "b", lines[4])
+
+	ctx = ContextTest("topk.info", key)
+	Call(ctx)
+	lines = ctxLines(ctx.Out)
+	assert.Equal(t, "*8", lines[0])
+	assert.Equal(t, "k", lines[2])
+	assert.Equal(t, ":2", lines[3])
+
+	clearSketch(t, key)
+}
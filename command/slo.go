@@ -0,0 +1,131 @@
+package command
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/distributedio/titan/metrics"
+	"go.uber.org/zap"
+)
+
+// Latency SLO rules let operators alert on the rolling p99 of a namespace
+// from inside titan, where the exact percentile is available, instead of
+// estimating it from coarse prometheus histogram buckets. The executor
+// feeds every command latency in, each matching rule keeps a ring of
+// recent samples and evaluates its p99 once per evaluation interval.
+// A violation bumps the slo_violation metric and logs a warning
+
+const (
+	// sloWindowSize samples back the rolling p99 of one rule
+	sloWindowSize = 1024
+	// sloEvalEvery is how many observations pass between two p99
+	// evaluations of one rule
+	sloEvalEvery = 128
+)
+
+// sloRule is one configured threshold, class narrows it to read or write
+// commands and "all" matches both
+type sloRule struct {
+	namespace string
+	className string
+	threshold time.Duration
+
+	mu         sync.Mutex
+	samples    []time.Duration // ring of the most recent latencies
+	next       int
+	count      int64
+	violations int64
+}
+
+// sloRules is written once at startup and read on every command
+var sloRules []*sloRule
+
+// SetLatencySLOs applies the latency-slo config, a comma separated list of
+// namespace:class:threshold rules where class is read, write or all and
+// threshold is a go duration. An empty spec clears the rules
+func SetLatencySLOs(spec string) error {
+	var rules []*sloRule
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		fields := strings.Split(entry, ":")
+		if len(fields) != 3 {
+			return fmt.Errorf("malformed latency slo %q, want namespace:class:threshold", entry)
+		}
+		className := strings.ToLower(fields[1])
+		switch className {
+		case "read", "write", "all":
+		default:
+			return fmt.Errorf("unknown latency slo class %q, want read, write or all", fields[1])
+		}
+		threshold, err := time.ParseDuration(fields[2])
+		if err != nil || threshold <= 0 {
+			return fmt.Errorf("malformed latency slo threshold %q", fields[2])
+		}
+		rules = append(rules, &sloRule{
+			namespace: fields[0],
+			className: className,
+			threshold: threshold,
+			samples:   make([]time.Duration, 0, sloWindowSize),
+		})
+	}
+	sloRules = rules
+	return nil
+}
+
+// observeLatencySLO feeds one command latency into the matching rules
+func observeLatencySLO(ctx *Context, cost time.Duration) {
+	if len(sloRules) == 0 {
+		return
+	}
+	write := false
+	if desc, ok := commands[ctx.Name]; ok {
+		write = desc.Cons.Flags&CmdWrite != 0
+	}
+	for _, rule := range sloRules {
+		if rule.namespace != ctx.Client.Namespace {
+			continue
+		}
+		if (rule.className == "read" && write) || (rule.className == "write" && !write) {
+			continue
+		}
+		rule.observe(cost)
+	}
+}
+
+// observe records one latency and evaluates the rolling p99 once per
+// evaluation interval
+func (rule *sloRule) observe(cost time.Duration) {
+	rule.mu.Lock()
+	defer rule.mu.Unlock()
+	if len(rule.samples) < sloWindowSize {
+		rule.samples = append(rule.samples, cost)
+	} else {
+		rule.samples[rule.next] = cost
+		rule.next = (rule.next + 1) % sloWindowSize
+	}
+	rule.count++
+	if rule.count%sloEvalEvery != 0 {
+		return
+	}
+	sorted := append([]time.Duration(nil), rule.samples...)
+	sort.Slice(sorted, func(a, b int) bool { return sorted[a] < sorted[b] })
+	p99 := sorted[len(sorted)*99/100]
+	if p99 <= rule.threshold {
+		return
+	}
+	rule.violations++
+	metrics.GetMetrics().SLOViolationCounterVec.WithLabelValues(
+		metrics.NamespaceLabel(rule.namespace), rule.className).Inc()
+	zap.L().Warn("latency slo violation",
+		zap.String("namespace", rule.namespace),
+		zap.String("class", rule.className),
+		zap.Duration("p99", p99),
+		zap.Duration("threshold", rule.threshold),
+		zap.Int("samples", len(sorted)))
+}
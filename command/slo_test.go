@@ -0,0 +1,66 @@
+package command
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSetLatencySLOs(t *testing.T) {
+	defer SetLatencySLOs("")
+
+	assert.NoError(t, SetLatencySLOs(""))
+	assert.Empty(t, sloRules)
+
+	assert.NoError(t, SetLatencySLOs("tenant-a:write:50ms, tenant-a:read:20ms,tenant-b:all:1s"))
+	assert.Len(t, sloRules, 3)
+	assert.Equal(t, "tenant-a", sloRules[0].namespace)
+	assert.Equal(t, "write", sloRules[0].className)
+	assert.Equal(t, 50*time.Millisecond, sloRules[0].threshold)
+
+	assert.Error(t, SetLatencySLOs("tenant-a:write"))
+	assert.Error(t, SetLatencySLOs("tenant-a:sometimes:50ms"))
+	assert.Error(t, SetLatencySLOs("tenant-a:write:fast"))
+	assert.Error(t, SetLatencySLOs("tenant-a:write:-1s"))
+}
+
+func TestLatencySLOViolation(t *testing.T) {
+	assert.NoError(t, SetLatencySLOs("defalut:all:1ms"))
+	defer SetLatencySLOs("")
+
+	rule := sloRules[0]
+	ctx := ContextTest("get", "slo-key")
+	ctx.Client.Namespace = "defalut"
+	// one evaluation interval of slow reads trips the rule
+	for i := 0; i < sloEvalEvery; i++ {
+		observeLatencySLO(ctx, 2*time.Millisecond)
+	}
+	assert.Equal(t, int64(1), rule.violations)
+
+	// fast commands do not
+	assert.NoError(t, SetLatencySLOs("defalut:all:1s"))
+	rule = sloRules[0]
+	for i := 0; i < sloEvalEvery; i++ {
+		observeLatencySLO(ctx, time.Microsecond)
+	}
+	assert.Equal(t, int64(0), rule.violations)
+
+	// a rule of another namespace never observes
+	assert.NoError(t, SetLatencySLOs("other-ns:all:1ms"))
+	rule = sloRules[0]
+	for i := 0; i < sloEvalEvery; i++ {
+		observeLatencySLO(ctx, 2*time.Millisecond)
+	}
+	assert.Equal(t, int64(0), rule.count)
+
+	// a write rule skips reads
+	assert.NoError(t, SetLatencySLOs("defalut:write:1ms"))
+	rule = sloRules[0]
+	observeLatencySLO(ctx, 2*time.Millisecond)
+	assert.Equal(t, int64(0), rule.count)
+	wctx := ContextTest("set", "slo-key", "v")
+	wctx.Client.Namespace = "defalut"
+	observeLatencySLO(wctx, 2*time.Millisecond)
+	assert.Equal(t, int64(1), rule.count)
+}
@@ -0,0 +1,95 @@
+package command
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/distributedio/titan/conf"
+	"github.com/distributedio/titan/context"
+	"github.com/stretchr/testify/assert"
+)
+
+// snapshotContext builds a context whose client persists across calls, the
+// way one real connection issues consecutive commands
+func snapshotContext(cliCtx *context.ClientContext, servCtx *context.ServerContext, name string, args ...string) *Context {
+	rootCtx, _ := context.WithCancel(context.New(cliCtx, servCtx))
+	return &Context{
+		Name:    name,
+		Args:    args,
+		In:      &bytes.Buffer{},
+		Out:     &bytes.Buffer{},
+		Context: rootCtx,
+	}
+}
+
+func TestSnapshotReuse(t *testing.T) {
+	cliCtx := &context.ClientContext{DB: mockdb.DB("defalut", 1)}
+	servCtx := &context.ServerContext{
+		Store:         mockdb,
+		SnapshotReuse: conf.SnapshotReuse{MaxAge: time.Minute},
+	}
+
+	call := func(name string, args ...string) []string {
+		ctx := snapshotContext(cliCtx, servCtx, name, args...)
+		Call(ctx)
+		return ctxLines(ctx.Out)
+	}
+
+	call("set", "snapshot-reuse-key", "v1")
+	// a write never leaves a snapshot behind
+	assert.Nil(t, cliCtx.ReadTxn)
+
+	assert.Equal(t, "$2", call("get", "snapshot-reuse-key")[0])
+	first := cliCtx.ReadTxn
+	assert.NotNil(t, first)
+
+	// the next read runs on the very same transaction
+	assert.Equal(t, "$2", call("get", "snapshot-reuse-key")[0])
+	assert.True(t, first == cliCtx.ReadTxn)
+
+	// a write drops the snapshot and the read after it sees the new value
+	call("set", "snapshot-reuse-key", "value2")
+	assert.Nil(t, cliCtx.ReadTxn)
+	lines := call("get", "snapshot-reuse-key")
+	assert.Equal(t, "$6", lines[0])
+	assert.Equal(t, "value2", lines[1])
+
+	// an over-age snapshot is refreshed instead of reused
+	cliCtx.ReadTxnAt = time.Now().Add(-2 * time.Minute)
+	stale := cliCtx.ReadTxn
+	call("get", "snapshot-reuse-key")
+	assert.NotNil(t, cliCtx.ReadTxn)
+	assert.False(t, stale == cliCtx.ReadTxn)
+
+	cliCtx.InvalidateReadTxn()
+	assert.Nil(t, cliCtx.ReadTxn)
+}
+
+func TestSnapshotInvalidateOnExec(t *testing.T) {
+	cliCtx := &context.ClientContext{DB: mockdb.DB("defalut", 1)}
+	servCtx := &context.ServerContext{
+		Store:         mockdb,
+		SnapshotReuse: conf.SnapshotReuse{MaxAge: time.Minute},
+	}
+
+	call := func(name string, args ...string) []string {
+		ctx := snapshotContext(cliCtx, servCtx, name, args...)
+		Call(ctx)
+		return ctxLines(ctx.Out)
+	}
+
+	call("set", "snapshot-exec-key", "v1")
+	assert.Equal(t, "$2", call("get", "snapshot-exec-key")[0])
+	assert.NotNil(t, cliCtx.ReadTxn)
+
+	// EXEC commits the queued writes, the snapshot taken before it must
+	// not serve the read that follows
+	call("multi")
+	call("set", "snapshot-exec-key", "value2")
+	call("exec")
+	assert.Nil(t, cliCtx.ReadTxn)
+	lines := call("get", "snapshot-exec-key")
+	assert.Equal(t, "$6", lines[0])
+	assert.Equal(t, "value2", lines[1])
+}
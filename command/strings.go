@@ -1,6 +1,7 @@
 package command
 
 import (
+	"bytes"
 	"errors"
 	"strconv"
 	"strings"
@@ -42,13 +43,19 @@ func Set(ctx *Context, txn *db.Transaction) (OnCommit, error) {
 
 	var next bool
 	var flag int      // flag int // 0 -- null 1---nx  2---xx
+	var withGet bool  // reply the old value instead of OK
 	var unit int64    // time ms
 	var expire string //expire = expire *unit
 	for i := 2; i < len(args); i++ {
 		if i+1 < len(args) {
 			next = true
 		}
-		if (args[i][0] == 'n' || args[i][0] == 'N') &&
+		if len(args[i]) == 3 &&
+			(args[i][0] == 'g' || args[i][0] == 'G') &&
+			(args[i][1] == 'e' || args[i][1] == 'E') &&
+			(args[i][2] == 't' || args[i][2] == 'T') {
+			withGet = true
+		} else if (args[i][0] == 'n' || args[i][0] == 'N') &&
 			(args[i][1] == 'x' || args[i][1] == 'X') &&
 			len(args[i]) == 2 {
 			flag = 1
@@ -85,6 +92,30 @@ func Set(ctx *Context, txn *db.Transaction) (OnCommit, error) {
 		unit = ui * unit
 	}
 
+	// the get option replies the old value, it refuses to touch a key
+	// of another type even when the set itself would overwrite it
+	var oldValue []byte
+	var oldExists bool
+	if withGet {
+		str, err := txn.String(key)
+		if err != nil {
+			if err == db.ErrTypeMismatch {
+				return nil, ErrTypeMismatch
+			}
+			return nil, errors.New("ERR " + err.Error())
+		}
+		if str.Exist() {
+			oldExists = true
+			oldValue = str.Meta.Value
+		}
+	}
+	replyOld := func() OnCommit {
+		if !oldExists {
+			return NullBulkString(ctx.Out)
+		}
+		return BulkString(ctx.Out, string(oldValue))
+	}
+
 	obj, err := txn.Object(key)
 	if err != nil && err != db.ErrKeyNotFound {
 		return nil, errors.New("ERR " + err.Error())
@@ -99,6 +130,10 @@ func Set(ctx *Context, txn *db.Transaction) (OnCommit, error) {
 	//nx
 	if flag == 1 {
 		if err != db.ErrKeyNotFound {
+			// nx with get still replies the old value it refused to overwrite
+			if withGet {
+				return replyOld(), nil
+			}
 			return NullBulkString(ctx.Out), nil
 		}
 	}
@@ -111,6 +146,14 @@ func Set(ctx *Context, txn *db.Transaction) (OnCommit, error) {
 	if err := s.Set(value, unit); err != nil {
 		return nil, errors.New("ERR " + err.Error())
 	}
+	if unit > 0 {
+		recordTTLChange(ctx, key, "expire_in="+strconv.FormatInt(unit/int64(time.Second), 10)+"s")
+	} else {
+		recordTTLChange(ctx, key, "ttl_cleared")
+	}
+	if withGet {
+		return replyOld(), nil
+	}
 	return SimpleString(ctx.Out, OK), nil
 }
 
@@ -226,15 +269,79 @@ func GetSet(ctx *Context, txn *db.Transaction) (OnCommit, error) {
 		}
 		return nil, errors.New("ERR " + err.Error())
 	}
-	if !str.Exist() {
+	// a missing key is still set, only the reply is a nil bulk string
+	exists := str.Exist()
+	value, err := str.GetSet(v)
+	if err != nil {
+		return nil, errors.New("ERR " + err.Error())
+	}
+	recordTTLChange(ctx, key, "ttl_cleared")
+	if !exists {
 		return NullBulkString(ctx.Out), nil
 	}
+	return BulkString(ctx.Out, string(value)), nil
+}
 
-	value, err := str.GetSet(v)
+// Cas replaces the value of key with new only when its current value equals
+// expected, all within the command transaction, a titan extension giving
+// clients an optimistic update without the MULTI/WATCH dance. It replies 1
+// when the swap was applied and 0 when the key is missing or holds another
+// value, like SET the applied swap clears a pending ttl
+func Cas(ctx *Context, txn *db.Transaction) (OnCommit, error) {
+	key := []byte(ctx.Args[0])
+	expected := []byte(ctx.Args[1])
+	value := []byte(ctx.Args[2])
+	str, err := txn.String(key)
 	if err != nil {
+		if err == db.ErrTypeMismatch {
+			return nil, ErrTypeMismatch
+		}
 		return nil, errors.New("ERR " + err.Error())
 	}
-	return BulkString(ctx.Out, string(value)), nil
+	current, err := str.Get()
+	if err != nil {
+		if err == db.ErrKeyNotFound {
+			return Integer(ctx.Out, 0), nil
+		}
+		return nil, errors.New("ERR " + err.Error())
+	}
+	if !bytes.Equal(current, expected) {
+		return Integer(ctx.Out, 0), nil
+	}
+	if err := str.Set(value); err != nil {
+		return nil, errors.New("ERR " + err.Error())
+	}
+	recordTTLChange(ctx, key, "ttl_cleared")
+	return Integer(ctx.Out, 1), nil
+}
+
+// Cad deletes key only when its current value equals expected, the
+// compare-and-delete companion of Cas. It replies 1 when the key was
+// deleted and 0 when it is missing or holds another value
+func Cad(ctx *Context, txn *db.Transaction) (OnCommit, error) {
+	key := []byte(ctx.Args[0])
+	expected := []byte(ctx.Args[1])
+	str, err := txn.String(key)
+	if err != nil {
+		if err == db.ErrTypeMismatch {
+			return nil, ErrTypeMismatch
+		}
+		return nil, errors.New("ERR " + err.Error())
+	}
+	current, err := str.Get()
+	if err != nil {
+		if err == db.ErrKeyNotFound {
+			return Integer(ctx.Out, 0), nil
+		}
+		return nil, errors.New("ERR " + err.Error())
+	}
+	if !bytes.Equal(current, expected) {
+		return Integer(ctx.Out, 0), nil
+	}
+	if _, err := txn.Kv().Delete([][]byte{key}); err != nil {
+		return nil, errors.New("ERR " + err.Error())
+	}
+	return Integer(ctx.Out, 1), nil
 }
 
 // GetRange increments the integer value of a keys by the given amount
@@ -348,7 +455,7 @@ func PSetEx(ctx *Context, txn *db.Transaction) (OnCommit, error) {
 	return SimpleString(ctx.Out, OK), nil
 }
 
-//SetRange overwrites part of the string stored at key, starting at the specified offset, for the entire length of value.
+// SetRange overwrites part of the string stored at key, starting at the specified offset, for the entire length of value.
 func SetRange(ctx *Context, txn *db.Transaction) (OnCommit, error) {
 	offset, err := strconv.Atoi(ctx.Args[1])
 	if err != nil {
@@ -377,6 +484,44 @@ func SetRange(ctx *Context, txn *db.Transaction) (OnCommit, error) {
 
 }
 
+// StrPatch is a titan extension that applies a list of offset/bytes edits
+// to a string in one transaction, saving applications doing many small
+// in-place updates to a large blob a round trip per edit. Offsets beyond
+// the current length pad with zero-bytes like SETRANGE. It replies with
+// the final length of the value
+func StrPatch(ctx *Context, txn *db.Transaction) (OnCommit, error) {
+	args := ctx.Args[1:]
+	if len(args)%2 != 0 {
+		return nil, ErrWrongArgs(ctx.Name)
+	}
+	offsets := make([]int64, 0, len(args)/2)
+	chunks := make([][]byte, 0, len(args)/2)
+	for i := 0; i < len(args); i += 2 {
+		offset, err := strconv.ParseInt(args[i], 10, 64)
+		if err != nil {
+			return nil, ErrInteger
+		}
+		if offset < 0 || offset > int64(MaxRangeInteger) {
+			return nil, ErrMaximum
+		}
+		offsets = append(offsets, offset)
+		chunks = append(chunks, []byte(args[i+1]))
+	}
+
+	str, err := txn.String([]byte(ctx.Args[0]))
+	if err != nil {
+		if err == db.ErrTypeMismatch {
+			return nil, ErrTypeMismatch
+		}
+		return nil, errors.New("ERR " + err.Error())
+	}
+	length, err := str.Patch(offsets, chunks)
+	if err != nil {
+		return nil, errors.New("ERR " + err.Error())
+	}
+	return Integer(ctx.Out, length), nil
+}
+
 // Incr increments the integer value of a key  by one
 func Incr(ctx *Context, txn *db.Transaction) (OnCommit, error) {
 	key := []byte(ctx.Args[0])
@@ -477,7 +622,8 @@ func DecrBy(ctx *Context, txn *db.Transaction) (OnCommit, error) {
 	return Integer(ctx.Out, int64(delta)), nil
 }
 
-// SetBit sets or clears the bit at offset in the string value stored at key.
+// SetBit sets or clears the bit at offset, the bitmap is stored in fixed
+// size chunks so huge offsets do not grow a single tikv value.
 func SetBit(ctx *Context, txn *db.Transaction) (OnCommit, error) {
 	key := []byte(ctx.Args[0])
 	offset, err := strconv.Atoi(ctx.Args[1])
@@ -498,24 +644,21 @@ func SetBit(ctx *Context, txn *db.Transaction) (OnCommit, error) {
 		return nil, ErrBitInteger
 	}
 
-	str, err := txn.String(key)
+	bm, err := txn.Bitmap(key)
 	if err != nil {
 		if err == db.ErrTypeMismatch {
 			return nil, ErrTypeMismatch
 		}
 		return nil, errors.New("ERR " + err.Error())
 	}
-	val, err := str.SetBit(offset, on)
+	val, err := bm.SetBit(offset, on)
 	if err != nil {
 		return nil, errors.New("ERR " + err.Error())
 	}
-	if val != 0 {
-		return Integer(ctx.Out, 1), nil
-	}
-	return Integer(ctx.Out, 0), nil
+	return Integer(ctx.Out, int64(val)), nil
 }
 
-// GetBit gets the bit at offset in the string value stored at key.
+// GetBit gets the bit at offset, unwritten regions read as zero.
 func GetBit(ctx *Context, txn *db.Transaction) (OnCommit, error) {
 	key := []byte(ctx.Args[0])
 	offset, err := strconv.Atoi(ctx.Args[1])
@@ -523,7 +666,7 @@ func GetBit(ctx *Context, txn *db.Transaction) (OnCommit, error) {
 		return nil, ErrBitOffset
 	}
 
-	str, err := txn.String(key)
+	bm, err := txn.Bitmap(key)
 	if err != nil {
 		if err == db.ErrTypeMismatch {
 			return nil, ErrTypeMismatch
@@ -531,25 +674,18 @@ func GetBit(ctx *Context, txn *db.Transaction) (OnCommit, error) {
 		return nil, errors.New("ERR " + err.Error())
 	}
 
-	if !str.Exist() {
-		return Integer(ctx.Out, 0), nil
-	}
-
-	val, err := str.GetBit(offset)
+	val, err := bm.GetBit(offset)
 	if err != nil {
 		return nil, errors.New("ERR " + err.Error())
 	}
-
-	if val != 0 {
-		return Integer(ctx.Out, 1), nil
-	}
-	return Integer(ctx.Out, 0), nil
+	return Integer(ctx.Out, int64(val)), nil
 }
 
-// BitCount counts the number of set bits (population counting) in a string.
+// BitCount counts the number of set bits (population counting) in a bitmap,
+// chunks are visited lazily so huge bitmaps stream through.
 func BitCount(ctx *Context, txn *db.Transaction) (OnCommit, error) {
 	key := []byte(ctx.Args[0])
-	str, err := txn.String(key)
+	bm, err := txn.Bitmap(key)
 	if err != nil {
 		if err == db.ErrTypeMismatch {
 			return nil, ErrTypeMismatch
@@ -557,7 +693,7 @@ func BitCount(ctx *Context, txn *db.Transaction) (OnCommit, error) {
 		return nil, errors.New("ERR " + err.Error())
 	}
 
-	if !str.Exist() {
+	if !bm.Exists() {
 		return Integer(ctx.Out, 0), nil
 	}
 
@@ -574,19 +710,19 @@ func BitCount(ctx *Context, txn *db.Transaction) (OnCommit, error) {
 		}
 	case 1:
 		begin = 0
-		end = len(str.Meta.Value) - 1
+		end = int(bm.Length()) - 1
 	default:
 		return nil, ErrSyntax
 	}
 
-	val, err := str.BitCount(begin, end)
+	val, err := bm.BitCount(begin, end)
 	if err != nil {
 		return nil, errors.New("ERR " + err.Error())
 	}
 	return Integer(ctx.Out, int64(val)), nil
 }
 
-// BitPos finds first bit set or clear in a string
+// BitPos finds the first bit set or clear in a bitmap
 func BitPos(ctx *Context, txn *db.Transaction) (OnCommit, error) {
 	bit, err := strconv.Atoi(string(ctx.Args[1]))
 	if err != nil {
@@ -598,7 +734,7 @@ func BitPos(ctx *Context, txn *db.Transaction) (OnCommit, error) {
 	}
 
 	key := []byte(ctx.Args[0])
-	str, err := txn.String(key)
+	bm, err := txn.Bitmap(key)
 	if err != nil {
 		if err == db.ErrTypeMismatch {
 			return nil, ErrTypeMismatch
@@ -606,13 +742,14 @@ func BitPos(ctx *Context, txn *db.Transaction) (OnCommit, error) {
 		return nil, errors.New("ERR " + err.Error())
 	}
 
-	if !str.Exist() {
+	if !bm.Exists() {
 		if bit == 1 {
 			return Integer(ctx.Out, -1), nil
 		}
 		return Integer(ctx.Out, 0), nil
 	}
 
+	endGiven := false
 	var begin, end int
 	switch len(ctx.Args) {
 	case 4:
@@ -624,23 +761,29 @@ func BitPos(ctx *Context, txn *db.Transaction) (OnCommit, error) {
 		if err != nil {
 			return nil, ErrInteger
 		}
+		endGiven = true
 	case 3:
 		begin, err = strconv.Atoi(ctx.Args[2])
 		if err != nil {
 			return nil, ErrInteger
 		}
-		end = len(str.Meta.Value) - 1
+		end = int(bm.Length()) - 1
 	case 2:
 		begin = 0
-		end = len(str.Meta.Value) - 1
+		end = int(bm.Length()) - 1
 	default:
 		return nil, ErrSyntax
 	}
 
-	val, err := str.BitPos(bit, begin, end)
+	val, err := bm.BitPos(bit, begin, end)
 	if err != nil {
 		return nil, errors.New("ERR " + err.Error())
 	}
+	if val == -1 && bit == 0 && !endGiven {
+		// redis reports the first bit past the value when looking for a
+		// clear bit without an explicit range
+		val = bm.Length() * 8
+	}
 	return Integer(ctx.Out, int64(val)), nil
 }
 
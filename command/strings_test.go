@@ -304,6 +304,52 @@ func TestStringSetNx(t *testing.T) {
 	EqualGet(t, key, value, nil)
 }
 
+// test set with the get option
+func TestStringSetWithGet(t *testing.T) {
+	key := "setwithget"
+	ctx := ContextTest("set", key, "v1", "get")
+	Call(ctx)
+	assert.Contains(t, ctxString(ctx.Out), "-1")
+	EqualGet(t, key, "v1", nil)
+
+	ctx = ContextTest("set", key, "v2", "get")
+	Call(ctx)
+	assert.Contains(t, ctxString(ctx.Out), "v1")
+	EqualGet(t, key, "v2", nil)
+
+	// nx with get replies the old value it refused to overwrite
+	ctx = ContextTest("set", key, "v3", "nx", "get")
+	Call(ctx)
+	assert.Contains(t, ctxString(ctx.Out), "v2")
+	EqualGet(t, key, "v2", nil)
+
+	// get refuses a key of another type
+	ctx = ContextTest("hset", "setwithgethash", "field", "val")
+	Call(ctx)
+	ctx = ContextTest("set", "setwithgethash", "v1", "get")
+	Call(ctx)
+	assert.Contains(t, ctxString(ctx.Out), "WRONGTYPE")
+}
+
+func TestStringGetSet(t *testing.T) {
+	key := "getsetkey"
+	ctx := ContextTest("getset", key, "v1")
+	Call(ctx)
+	assert.Contains(t, ctxString(ctx.Out), "-1")
+	EqualGet(t, key, "v1", nil)
+
+	ctx = ContextTest("getset", key, "v2")
+	Call(ctx)
+	assert.Contains(t, ctxString(ctx.Out), "v1")
+	EqualGet(t, key, "v2", nil)
+
+	ctx = ContextTest("hset", "getsethash", "field", "val")
+	Call(ctx)
+	ctx = ContextTest("getset", "getsethash", "v1")
+	Call(ctx)
+	assert.Contains(t, ctxString(ctx.Out), "WRONGTYPE")
+}
+
 func TestStringPSetEx(t *testing.T) {
 	args := make([]string, 3)
 	key := "psetex"
@@ -686,3 +732,96 @@ func TestStringAppend(t *testing.T) {
 		})
 	}
 }*/
+
+func TestBitmapCommands(t *testing.T) {
+	key := "bitmap-cmd"
+	out := CallTest("setbit", key, "7", "1")
+	assert.Contains(t, out.String(), ":0")
+	out = CallTest("setbit", key, "7", "1")
+	assert.Contains(t, out.String(), ":1")
+	out = CallTest("getbit", key, "7")
+	assert.Contains(t, out.String(), ":1")
+	out = CallTest("getbit", key, "6")
+	assert.Contains(t, out.String(), ":0")
+
+	CallTest("setbit", key, "100", "1")
+	out = CallTest("bitcount", key)
+	assert.Contains(t, out.String(), ":2")
+	out = CallTest("bitpos", key, "1")
+	assert.Contains(t, out.String(), ":7")
+	out = CallTest("bitpos", key, "0")
+	assert.Contains(t, out.String(), ":0")
+
+	// bitmaps and plain strings do not mix
+	CallTest("set", "bitmap-cmd-str", "v")
+	out = CallTest("setbit", "bitmap-cmd-str", "0", "1")
+	assert.Contains(t, out.String(), "WRONGTYPE")
+	out = CallTest("get", key)
+	assert.Contains(t, out.String(), "WRONGTYPE")
+}
+
+func TestStringStrPatch(t *testing.T) {
+	ctx := ContextTest("set", "strpatch-key", "hello world")
+	Call(ctx)
+
+	// two edits applied in one call, the second grows the value
+	ctx = ContextTest("strpatch", "strpatch-key", "0", "jello", "11", "!!")
+	Call(ctx)
+	assert.Contains(t, ctxString(ctx.Out), ":13")
+	EqualGet(t, "strpatch-key", "jello world!!", nil)
+
+	// an edit beyond the current length pads with zero-bytes
+	ctx = ContextTest("strpatch", "strpatch-key-pad", "3", "abc")
+	Call(ctx)
+	assert.Contains(t, ctxString(ctx.Out), ":6")
+	EqualStrlen(t, "strpatch-key-pad", 6)
+
+	// offsets and chunks must pair up
+	ctx = ContextTest("strpatch", "strpatch-key", "0", "x", "5")
+	Call(ctx)
+	assert.Contains(t, ctxString(ctx.Out), "wrong number of arguments")
+
+	ctx = ContextTest("strpatch", "strpatch-key", "notanumber", "x")
+	Call(ctx)
+	assert.Contains(t, ctxString(ctx.Out), "not an integer")
+}
+
+func TestStringCas(t *testing.T) {
+	ctx := ContextTest("set", "cas-key", "v1")
+	Call(ctx)
+
+	// a matching expected value swaps
+	ctx = ContextTest("cas", "cas-key", "v1", "v2")
+	Call(ctx)
+	assert.Contains(t, ctxString(ctx.Out), ":1")
+	EqualGet(t, "cas-key", "v2", nil)
+
+	// a stale expected value leaves the key alone
+	ctx = ContextTest("cas", "cas-key", "v1", "v3")
+	Call(ctx)
+	assert.Contains(t, ctxString(ctx.Out), ":0")
+	EqualGet(t, "cas-key", "v2", nil)
+
+	// a missing key never matches
+	ctx = ContextTest("cas", "cas-missing", "v1", "v2")
+	Call(ctx)
+	assert.Contains(t, ctxString(ctx.Out), ":0")
+}
+
+func TestStringCad(t *testing.T) {
+	ctx := ContextTest("set", "cad-key", "v1")
+	Call(ctx)
+
+	ctx = ContextTest("cad", "cad-key", "stale")
+	Call(ctx)
+	assert.Contains(t, ctxString(ctx.Out), ":0")
+	EqualGet(t, "cad-key", "v1", nil)
+
+	ctx = ContextTest("cad", "cad-key", "v1")
+	Call(ctx)
+	assert.Contains(t, ctxString(ctx.Out), ":1")
+
+	ctx = ContextTest("get", "cad-key")
+	Call(ctx)
+	assert.Contains(t, ctxString(ctx.Out), "$-1")
+}
@@ -0,0 +1,272 @@
+package command
+
+import (
+	"errors"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/distributedio/titan/db"
+	"github.com/distributedio/titan/encoding/resp"
+)
+
+// The TS.* commands are a minimal facade over the RedisTimeSeries module
+// API. A series is an ordinary zset whose members are the decimal sample
+// timestamps, the score carries the timestamp for range scans and the
+// payload carries the sample value, so DEL, EXPIRE and TYPE treat a series
+// as a zset
+
+var (
+	// ErrTSTimestamp is returned when a TS.* timestamp does not parse
+	ErrTSTimestamp = errors.New("ERR TSDB: invalid timestamp")
+
+	// ErrTSValue is returned when a TS.* sample value does not parse
+	ErrTSValue = errors.New("ERR TSDB: invalid value")
+)
+
+// tsAggregators fold the parsed sample values of one bucket, count ignores
+// the values and is handled by the caller
+var tsAggregators = map[string]func(acc float64, v float64, n int64) float64{
+	"sum": func(acc, v float64, n int64) float64 { return acc + v },
+	"avg": func(acc, v float64, n int64) float64 { return acc + v },
+	"min": func(acc, v float64, n int64) float64 {
+		if n == 0 || v < acc {
+			return v
+		}
+		return acc
+	},
+	"max": func(acc, v float64, n int64) float64 {
+		if n == 0 || v > acc {
+			return v
+		}
+		return acc
+	},
+	"count": func(acc, v float64, n int64) float64 { return acc },
+}
+
+// tsParseTimestamp resolves a TS.* timestamp argument, "*" means the
+// current time in milliseconds
+func tsParseTimestamp(arg string) (int64, error) {
+	if arg == "*" {
+		return time.Now().UnixNano() / int64(time.Millisecond), nil
+	}
+	ts, err := strconv.ParseInt(arg, 10, 64)
+	if err != nil || ts < 0 {
+		return 0, ErrTSTimestamp
+	}
+	return ts, nil
+}
+
+// tsSetSample writes one sample into the series zset
+func tsSetSample(zset *db.ZSet, ts int64, value float64) error {
+	member := []byte(strconv.FormatInt(ts, 10))
+	payload := []byte(strconv.FormatFloat(value, 'f', -1, 64))
+	_, err := zset.ZAddWithPayloads([][]byte{member}, []float64{float64(ts)}, [][]byte{payload})
+	return err
+}
+
+// TSAdd appends a sample to the series, a sample at an existing timestamp
+// is overwritten. It replies the timestamp of the sample
+func TSAdd(ctx *Context, txn *db.Transaction) (OnCommit, error) {
+	key := []byte(ctx.Args[0])
+	ts, err := tsParseTimestamp(ctx.Args[1])
+	if err != nil {
+		return nil, err
+	}
+	value, err := strconv.ParseFloat(ctx.Args[2], 64)
+	if err != nil || math.IsNaN(value) {
+		return nil, ErrTSValue
+	}
+
+	zset, err := txn.ZSet(key)
+	if err != nil {
+		if err == db.ErrTypeMismatch {
+			return nil, ErrTypeMismatch
+		}
+		return nil, errors.New("ERR " + err.Error())
+	}
+	if err := tsSetSample(zset, ts, value); err != nil {
+		return nil, errors.New("ERR " + err.Error())
+	}
+	return Integer(ctx.Out, ts), nil
+}
+
+// TSIncrBy increments the sample at the given timestamp, TIMESTAMP
+// defaults to the current time in milliseconds and a missing sample starts
+// from zero. It replies the timestamp of the sample
+func TSIncrBy(ctx *Context, txn *db.Transaction) (OnCommit, error) {
+	key := []byte(ctx.Args[0])
+	delta, err := strconv.ParseFloat(ctx.Args[1], 64)
+	if err != nil || math.IsNaN(delta) {
+		return nil, ErrTSValue
+	}
+	tsArg := "*"
+	if len(ctx.Args) > 2 {
+		if len(ctx.Args) != 4 || strings.ToUpper(ctx.Args[2]) != "TIMESTAMP" {
+			return nil, ErrSyntax
+		}
+		tsArg = ctx.Args[3]
+	}
+	ts, err := tsParseTimestamp(tsArg)
+	if err != nil {
+		return nil, err
+	}
+
+	zset, err := txn.ZSet(key)
+	if err != nil {
+		if err == db.ErrTypeMismatch {
+			return nil, ErrTypeMismatch
+		}
+		return nil, errors.New("ERR " + err.Error())
+	}
+	member := []byte(strconv.FormatInt(ts, 10))
+	value := delta
+	if zset.Exist() {
+		_, payload, err := zset.ZScoreWithPayload(member)
+		if err != nil {
+			return nil, errors.New("ERR " + err.Error())
+		}
+		if payload != nil {
+			current, err := strconv.ParseFloat(string(payload), 64)
+			if err != nil {
+				return nil, ErrTSValue
+			}
+			value += current
+		}
+	}
+	if err := tsSetSample(zset, ts, value); err != nil {
+		return nil, errors.New("ERR " + err.Error())
+	}
+	return Integer(ctx.Out, ts), nil
+}
+
+// TSRange replies the samples between two timestamps as timestamp value
+// pairs, "-" and "+" denote the open ends of the series. With AGGREGATION
+// the samples are folded into buckets of the given duration
+func TSRange(ctx *Context, txn *db.Transaction) (OnCommit, error) {
+	key := []byte(ctx.Args[0])
+	from := int64(0)
+	if ctx.Args[1] != "-" {
+		ts, err := tsParseTimestamp(ctx.Args[1])
+		if err != nil {
+			return nil, err
+		}
+		from = ts
+	}
+	to := int64(math.MaxInt64)
+	if ctx.Args[2] != "+" {
+		ts, err := tsParseTimestamp(ctx.Args[2])
+		if err != nil {
+			return nil, err
+		}
+		to = ts
+	}
+
+	var aggregate func(acc float64, v float64, n int64) float64
+	var aggName string
+	var bucket int64
+	if len(ctx.Args) > 3 {
+		if len(ctx.Args) != 6 || strings.ToUpper(ctx.Args[3]) != "AGGREGATION" {
+			return nil, ErrSyntax
+		}
+		aggName = strings.ToLower(ctx.Args[4])
+		agg, ok := tsAggregators[aggName]
+		if !ok {
+			return nil, errors.New("ERR TSDB: unknown aggregation type")
+		}
+		aggregate = agg
+		d, err := strconv.ParseInt(ctx.Args[5], 10, 64)
+		if err != nil || d <= 0 {
+			return nil, ErrTSTimestamp
+		}
+		bucket = d
+	}
+
+	zset, err := txn.ZSet(key)
+	if err != nil {
+		if err == db.ErrTypeMismatch {
+			return nil, ErrTypeMismatch
+		}
+		return nil, errors.New("ERR " + err.Error())
+	}
+	var timestamps []int64
+	var values [][]byte
+	if zset.Exist() {
+		members, err := zset.ZAnyOrderRangeByScore(float64(from), true, float64(to), true, false, 0, -1, true)
+		if err != nil {
+			return nil, errors.New("ERR " + err.Error())
+		}
+		payloads, err := zset.ZPayloads(members)
+		if err != nil {
+			return nil, errors.New("ERR " + err.Error())
+		}
+		for i := range members {
+			ts, err := strconv.ParseInt(string(members[i]), 10, 64)
+			if err != nil || payloads[i] == nil {
+				continue
+			}
+			timestamps = append(timestamps, ts)
+			values = append(values, payloads[i])
+		}
+	}
+
+	if aggregate != nil {
+		timestamps, values, err = tsAggregate(timestamps, values, aggName, aggregate, bucket)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return func() {
+		resp.ReplyArray(ctx.Out, len(timestamps))
+		for i := range timestamps {
+			resp.ReplyArray(ctx.Out, 2)
+			resp.ReplyInteger(ctx.Out, timestamps[i])
+			resp.ReplyBulkString(ctx.Out, string(values[i]))
+		}
+	}, nil
+}
+
+// tsAggregate folds samples into buckets aligned to multiples of the
+// bucket duration, empty buckets are skipped
+func tsAggregate(timestamps []int64, values [][]byte, aggName string,
+	aggregate func(acc float64, v float64, n int64) float64, bucket int64) ([]int64, [][]byte, error) {
+	var (
+		outTS     []int64
+		outValues [][]byte
+		bucketTS  int64
+		acc       float64
+		n         int64
+		flush     = func() {
+			if n == 0 {
+				return
+			}
+			result := acc
+			switch aggName {
+			case "avg":
+				result = acc / float64(n)
+			case "count":
+				result = float64(n)
+			}
+			outTS = append(outTS, bucketTS)
+			outValues = append(outValues, []byte(strconv.FormatFloat(result, 'f', -1, 64)))
+			acc, n = 0, 0
+		}
+	)
+	for i := range timestamps {
+		v, err := strconv.ParseFloat(string(values[i]), 64)
+		if err != nil {
+			return nil, nil, ErrTSValue
+		}
+		start := timestamps[i] - timestamps[i]%bucket
+		if n > 0 && start != bucketTS {
+			flush()
+		}
+		bucketTS = start
+		acc = aggregate(acc, v, n)
+		n++
+	}
+	flush()
+	return outTS, outValues, nil
+}
@@ -0,0 +1,120 @@
+package command
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTSCommands(t *testing.T) {
+	key := "ts-key"
+
+	ctx := ContextTest("ts.add", key, "1000", "1.5")
+	Call(ctx)
+	assert.Equal(t, ":1000", ctxLines(ctx.Out)[0])
+
+	ctx = ContextTest("ts.add", key, "2000", "2.5")
+	Call(ctx)
+	assert.Equal(t, ":2000", ctxLines(ctx.Out)[0])
+
+	// a sample at an existing timestamp is overwritten
+	ctx = ContextTest("ts.add", key, "1000", "3")
+	Call(ctx)
+	assert.Equal(t, ":1000", ctxLines(ctx.Out)[0])
+
+	ctx = ContextTest("ts.range", key, "-", "+")
+	Call(ctx)
+	lines := ctxLines(ctx.Out)
+	assert.Equal(t, "*2", lines[0])
+	assert.Equal(t, "*2", lines[1])
+	assert.Equal(t, ":1000", lines[2])
+	assert.Equal(t, "3", lines[4])
+	assert.Equal(t, ":2000", lines[6])
+	assert.Equal(t, "2.5", lines[8])
+
+	// the range bounds are inclusive
+	ctx = ContextTest("ts.range", key, "1001", "2000")
+	Call(ctx)
+	lines = ctxLines(ctx.Out)
+	assert.Equal(t, "*1", lines[0])
+	assert.Equal(t, ":2000", lines[2])
+
+	ctx = ContextTest("ts.incrby", key, "2", "TIMESTAMP", "2000")
+	Call(ctx)
+	assert.Equal(t, ":2000", ctxLines(ctx.Out)[0])
+
+	ctx = ContextTest("ts.incrby", key, "4", "TIMESTAMP", "3500")
+	Call(ctx)
+	assert.Equal(t, ":3500", ctxLines(ctx.Out)[0])
+
+	ctx = ContextTest("ts.range", key, "2000", "+")
+	Call(ctx)
+	lines = ctxLines(ctx.Out)
+	assert.Equal(t, "*2", lines[0])
+	assert.Equal(t, "4.5", lines[4])
+	assert.Equal(t, "4", lines[8])
+
+	ctx = ContextTest("ts.add", key, "not-a-ts", "1")
+	Call(ctx)
+	assert.Equal(t, "-ERR TSDB: invalid timestamp", ctxLines(ctx.Out)[0])
+
+	ctx = ContextTest("ts.add", key, "4000", "not-a-value")
+	Call(ctx)
+	assert.Equal(t, "-ERR TSDB: invalid value", ctxLines(ctx.Out)[0])
+
+	// a series is an ordinary zset underneath
+	ctx = ContextTest("type", key)
+	Call(ctx)
+	assert.Equal(t, "+zset", ctxLines(ctx.Out)[0])
+
+	ctx = ContextTest("del", key)
+	Call(ctx)
+	assert.Equal(t, ":1", ctxLines(ctx.Out)[0])
+}
+
+func TestTSRangeAggregation(t *testing.T) {
+	key := "ts-agg-key"
+
+	samples := [][2]string{
+		{"1000", "1"}, {"1500", "3"}, {"2000", "10"}, {"2600", "20"}, {"4200", "7"},
+	}
+	for _, s := range samples {
+		ctx := ContextTest("ts.add", key, s[0], s[1])
+		Call(ctx)
+		assert.Equal(t, ":"+s[0], ctxLines(ctx.Out)[0])
+	}
+
+	ctx := ContextTest("ts.range", key, "-", "+", "AGGREGATION", "avg", "1000")
+	Call(ctx)
+	lines := ctxLines(ctx.Out)
+	assert.Equal(t, "*3", lines[0])
+	assert.Equal(t, ":1000", lines[2])
+	assert.Equal(t, "2", lines[4])
+	assert.Equal(t, ":2000", lines[6])
+	assert.Equal(t, "15", lines[8])
+	assert.Equal(t, ":4000", lines[10])
+	assert.Equal(t, "7", lines[12])
+
+	ctx = ContextTest("ts.range", key, "-", "+", "AGGREGATION", "count", "1000")
+	Call(ctx)
+	lines = ctxLines(ctx.Out)
+	assert.Equal(t, "*3", lines[0])
+	assert.Equal(t, "2", lines[4])
+	assert.Equal(t, "2", lines[8])
+	assert.Equal(t, "1", lines[12])
+
+	ctx = ContextTest("ts.range", key, "-", "+", "AGGREGATION", "max", "10000")
+	Call(ctx)
+	lines = ctxLines(ctx.Out)
+	assert.Equal(t, "*1", lines[0])
+	assert.Equal(t, ":0", lines[2])
+	assert.Equal(t, "20", lines[4])
+
+	ctx = ContextTest("ts.range", key, "-", "+", "AGGREGATION", "median", "1000")
+	Call(ctx)
+	assert.Equal(t, "-ERR TSDB: unknown aggregation type", ctxLines(ctx.Out)[0])
+
+	ctx = ContextTest("del", key)
+	Call(ctx)
+	assert.Equal(t, ":1", ctxLines(ctx.Out)[0])
+}
@@ -0,0 +1,74 @@
+package command
+
+import (
+	"sync"
+	"sync/atomic"
+
+	"go.uber.org/zap"
+)
+
+// traceMaxArgs and traceMaxArgLen bound what a trace line may carry, the
+// toggle is meant for diagnosing traffic shapes and must not leak whole
+// values into the log
+const (
+	traceMaxArgs   = 8
+	traceMaxArgLen = 32
+)
+
+// traceState is the sampling counter of one traced namespace
+type traceState struct {
+	every   int64
+	counter int64
+}
+
+// traces maps a namespace to its trace state, untraced namespaces carry no
+// entry so the hot path is one failed map load
+var traces sync.Map
+
+// SetCommandTrace samples one of every "every" commands of the namespace
+// to the debug log, a non-positive value turns tracing off
+func SetCommandTrace(namespace string, every int64) {
+	if every <= 0 {
+		traces.Delete(namespace)
+		return
+	}
+	traces.Store(namespace, &traceState{every: every})
+}
+
+// traceCommand emits a sampled debug line of the current command with
+// sanitized arguments
+func traceCommand(ctx *Context) {
+	v, ok := traces.Load(ctx.Client.Namespace)
+	if !ok {
+		return
+	}
+	st := v.(*traceState)
+	if atomic.AddInt64(&st.counter, 1)%st.every != 0 {
+		return
+	}
+	if logEnv := zap.L().Check(zap.DebugLevel, "command trace"); logEnv != nil {
+		logEnv.Write(zap.String("namespace", ctx.Client.Namespace),
+			zap.Int64("clientid", ctx.Client.ID),
+			zap.String("traceid", ctx.TraceID),
+			zap.String("command", ctx.Name),
+			zap.Int("argc", len(ctx.Args)),
+			zap.Strings("args", sanitizeArgs(ctx.Args)))
+	}
+}
+
+// sanitizeArgs truncates long arguments and caps how many are logged
+func sanitizeArgs(args []string) []string {
+	n := len(args)
+	if n > traceMaxArgs {
+		n = traceMaxArgs
+	}
+	out := make([]string, n)
+	for i := 0; i < n; i++ {
+		if len(args[i]) > traceMaxArgLen {
+			out[i] = args[i][:traceMaxArgLen] + "..."
+		} else {
+			out[i] = args[i]
+		}
+	}
+	return out
+}
@@ -0,0 +1,42 @@
+package command
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSanitizeArgs(t *testing.T) {
+	long := make([]byte, 100)
+	for i := range long {
+		long[i] = 'a'
+	}
+	args := []string{"short", string(long)}
+	out := sanitizeArgs(args)
+	assert.Equal(t, "short", out[0])
+	assert.Len(t, out[1], traceMaxArgLen+3)
+
+	many := make([]string, 20)
+	assert.Len(t, sanitizeArgs(many), traceMaxArgs)
+}
+
+func TestDebugTrace(t *testing.T) {
+	ctx := ContextTest("debug", "trace", "sometenant", "10")
+	Call(ctx)
+	lines := ctxLines(ctx.Out)
+	assert.Equal(t, "+OK", lines[0])
+	_, ok := traces.Load("sometenant")
+	assert.True(t, ok)
+
+	ctx = ContextTest("debug", "trace", "sometenant", "0")
+	Call(ctx)
+	lines = ctxLines(ctx.Out)
+	assert.Equal(t, "+OK", lines[0])
+	_, ok = traces.Load("sometenant")
+	assert.False(t, ok)
+
+	ctx = ContextTest("debug", "trace", "sometenant", "abc")
+	Call(ctx)
+	lines = ctxLines(ctx.Out)
+	assert.Equal(t, "-ERR value is not an integer or out of range", lines[0])
+}
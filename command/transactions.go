@@ -39,14 +39,15 @@ func Exec(ctx *Context) {
 	var onCommits []OnCommit
 	err = retry.Ensure(ctx, func() error {
 		mt := metrics.GetMetrics()
+		nsLabel := metrics.NamespaceLabel(ctx.Client.Namespace)
 		if !watching {
 			start := time.Now()
 			txn, err = ctx.Client.DB.Begin()
 			cost := time.Since(start).Seconds()
-			mt.TxnBeginHistogramVec.WithLabelValues(ctx.Client.Namespace, ctx.Name).Observe(cost)
+			mt.TxnBeginHistogramVec.WithLabelValues(nsLabel, ctx.Name).Observe(cost)
 			zap.L().Debug("transation begin", zap.String("name", ctx.Name), zap.Int64("cost(us)", int64(cost*1000000)))
 			if err != nil {
-				mt.TxnFailuresCounterVec.WithLabelValues(ctx.Client.Namespace, ctx.Name).Inc()
+				mt.TxnFailuresCounterVec.WithLabelValues(nsLabel, ctx.Name).Inc()
 				zap.L().Error("begin txn failed",
 					zap.Int64("clientid", ctx.Client.ID),
 					zap.String("command", ctx.Name),
@@ -70,17 +71,17 @@ func Exec(ctx *Context) {
 				Context: ctx.Context,
 			}
 			if len(cmd.Args) > 0 {
-				mt.CommandArgsNumHistogramVec.WithLabelValues(ctx.Client.Namespace, cmd.Name).Observe(float64(len(cmd.Args)))
+				mt.CommandArgsNumHistogramVec.WithLabelValues(nsLabel, cmd.Name).Observe(float64(len(cmd.Args)))
 			}
 			name := strings.ToLower(cmd.Name)
 			if _, ok := txnCommands[name]; ok {
 				start := time.Now()
 				onCommit, err = TxnCall(subCtx, txn)
 				cost := time.Since(start).Seconds()
-				mt.CommandFuncDoneHistogramVec.WithLabelValues(ctx.Client.Namespace, cmd.Name).Observe(cost)
+				mt.CommandFuncDoneHistogramVec.WithLabelValues(nsLabel, cmd.Name).Observe(cost)
 				zap.L().Debug("execute", zap.String("command", cmd.Name), zap.Int64("cost(us)", int64(cost*1000000)))
 				if err != nil {
-					mt.TxnFailuresCounterVec.WithLabelValues(ctx.Client.Namespace, cmd.Name).Inc()
+					mt.TxnFailuresCounterVec.WithLabelValues(nsLabel, cmd.Name).Inc()
 					resp.ReplyError(out, err.Error())
 				}
 			} else {
@@ -91,19 +92,19 @@ func Exec(ctx *Context) {
 			commandCount++
 		}
 		start := time.Now()
-		mt.MultiCommandHistogramVec.WithLabelValues(ctx.Client.Namespace, ctx.Name).Observe(float64(commandCount))
+		mt.MultiCommandHistogramVec.WithLabelValues(nsLabel, ctx.Name).Observe(float64(commandCount))
 		defer func() {
 			cost := time.Since(start).Seconds()
-			mt.TxnCommitHistogramVec.WithLabelValues(ctx.Client.Namespace, ctx.Name).Observe(cost)
+			mt.TxnCommitHistogramVec.WithLabelValues(nsLabel, ctx.Name).Observe(cost)
 		}()
 		start = time.Now()
 		err = txn.Commit(ctx)
 		zap.L().Debug("commit", zap.String("command", ctx.Name), zap.Int64("cost(us)", time.Since(start).Nanoseconds()/1000))
 		if err != nil {
-			mt.TxnFailuresCounterVec.WithLabelValues(ctx.Client.Namespace, ctx.Name).Inc()
+			mt.TxnFailuresCounterVec.WithLabelValues(nsLabel, ctx.Name).Inc()
 			if db.IsRetryableError(err) && !watching {
-				mt.TxnRetriesCounterVec.WithLabelValues(ctx.Client.Namespace, ctx.Name).Inc()
-				mt.TxnConflictsCounterVec.WithLabelValues(ctx.Client.Namespace, ctx.Name).Inc()
+				mt.TxnRetriesCounterVec.WithLabelValues(nsLabel, ctx.Name).Inc()
+				mt.TxnConflictsCounterVec.WithLabelValues(nsLabel, ctx.Name).Inc()
 				zap.L().Error("txn commit retry",
 					zap.Int64("clientid", ctx.Client.ID),
 					zap.String("command", ctx.Name),
@@ -134,6 +135,10 @@ func Exec(ctx *Context) {
 		return
 	}
 
+	// the queued commands just committed writes, drop the shared read
+	// snapshot so a following read sees them instead of a stale view
+	ctx.Client.InvalidateReadTxn()
+
 	start := time.Now()
 	resp.ReplyArray(ctx.Out, size)
 	// run OnCommit that fill reply to outputs
@@ -153,7 +158,7 @@ func Exec(ctx *Context) {
 		}
 	}
 	cost := time.Since(start).Seconds()
-	metrics.GetMetrics().ReplyFuncDoneHistogramVec.WithLabelValues(ctx.Client.Namespace, ctx.Name).Observe(cost)
+	metrics.GetMetrics().ReplyFuncDoneHistogramVec.WithLabelValues(metrics.NamespaceLabel(ctx.Client.Namespace), ctx.Name).Observe(cost)
 	zap.L().Debug("onCommit ", zap.String("name", ctx.Name), zap.Int64("cost(us)", int64(cost*1000000)))
 }
 
@@ -197,3 +202,122 @@ func Unwatch(ctx *Context) {
 	}
 	resp.ReplySimpleString(ctx.Out, OK)
 }
+
+// TxnBegin pins a storage transaction to the connection, a titan extension
+// giving clients multi-key ACID across commands beyond what MULTI offers.
+// Commands join it through TXN.EXEC until TXN.COMMIT or TXN.ROLLBACK, other
+// commands on the connection keep running in their own transactions
+func TxnBegin(ctx *Context) {
+	if ctx.Server.ExplicitTxn.Disable {
+		resp.ReplyError(ctx.Out, "ERR explicit transactions are disabled, see the explicit-txn config")
+		return
+	}
+	if ctx.Client.Multi {
+		resp.ReplyError(ctx.Out, "ERR TXN.BEGIN is not allowed in MULTI")
+		return
+	}
+	if ctx.Client.ExplicitTxn != nil {
+		resp.ReplyError(ctx.Out, "ERR a transaction is already begun on this connection")
+		return
+	}
+	txn, err := ctx.Client.DB.Begin()
+	if err != nil {
+		resp.ReplyError(ctx.Out, "ERR "+err.Error())
+		return
+	}
+	ctx.Client.ExplicitTxn = txn
+	ctx.Client.ExplicitTxnAt = time.Now()
+	resp.ReplySimpleString(ctx.Out, OK)
+}
+
+// pinnedExplicitTxn returns the transaction pinned by TXN.BEGIN, rolling
+// back and reporting one left idle beyond the configured timeout
+func pinnedExplicitTxn(ctx *Context) *db.Transaction {
+	txn := ctx.Client.ExplicitTxn
+	if txn == nil {
+		resp.ReplyError(ctx.Out, "ERR no transaction is begun, call TXN.BEGIN first")
+		return nil
+	}
+	if timeout := ctx.Server.ExplicitTxn.IdleTimeout; timeout > 0 &&
+		time.Since(ctx.Client.ExplicitTxnAt) > timeout {
+		ctx.Client.AbortExplicitTxn()
+		resp.ReplyError(ctx.Out, "ERR transaction exceeded the idle timeout and was rolled back")
+		return nil
+	}
+	return txn
+}
+
+// TxnExec runs one transactional command inside the pinned transaction,
+// nothing is visible to other clients before TXN.COMMIT. A command error
+// leaves the transaction usable, like a failed command inside MULTI
+func TxnExec(ctx *Context) {
+	txn := pinnedExplicitTxn(ctx)
+	if txn == nil {
+		return
+	}
+	name := strings.ToLower(ctx.Args[0])
+	cmd, ok := txnCommands[name]
+	if !ok {
+		resp.ReplyError(ctx.Out, ErrUnKnownCommand(name).Error())
+		return
+	}
+	arity := commands[name].Cons.Arity
+	argc := len(ctx.Args)
+	if (arity > 0 && argc != arity) || (arity < 0 && argc < -arity) {
+		resp.ReplyError(ctx.Out, ErrWrongArgs(name).Error())
+		return
+	}
+	ctx.Client.ExplicitTxnAt = time.Now()
+
+	subCtx := &Context{
+		Name:    name,
+		Args:    ctx.Args[1:],
+		In:      ctx.In,
+		Out:     ctx.Out,
+		Context: ctx.Context,
+	}
+	onCommit, err := cmd(subCtx, txn)
+	if err != nil {
+		resp.ReplyError(ctx.Out, err.Error())
+		return
+	}
+	// the reply only formats what the command already read, running it
+	// before the commit is safe
+	if onCommit != nil {
+		onCommit()
+	}
+	// a write joined the transaction, the shared read snapshot would miss
+	// it once committed
+	if commands[name].Cons.Flags&CmdWrite != 0 {
+		ctx.Client.InvalidateReadTxn()
+	}
+}
+
+// TxnCommit commits the pinned transaction. A conflicting commit cannot be
+// replayed like AutoCommit does, the commands are gone, so conflicts roll
+// the transaction back and surface to the client
+func TxnCommit(ctx *Context) {
+	txn := pinnedExplicitTxn(ctx)
+	if txn == nil {
+		return
+	}
+	ctx.Client.ExplicitTxn = nil
+	if err := txn.Commit(ctx); err != nil {
+		txn.Rollback()
+		resp.ReplyError(ctx.Out, "EXECABORT Transaction discarded because of txn conflicts")
+		return
+	}
+	// the commit just published the transaction's writes, drop any shared
+	// read snapshot predating them
+	ctx.Client.InvalidateReadTxn()
+	resp.ReplySimpleString(ctx.Out, OK)
+}
+
+// TxnRollback discards the pinned transaction
+func TxnRollback(ctx *Context) {
+	if pinnedExplicitTxn(ctx) == nil {
+		return
+	}
+	ctx.Client.AbortExplicitTxn()
+	resp.ReplySimpleString(ctx.Out, OK)
+}
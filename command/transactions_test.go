@@ -0,0 +1,107 @@
+package command
+
+import (
+	"testing"
+	"time"
+
+	"github.com/distributedio/titan/conf"
+	"github.com/distributedio/titan/context"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExplicitTxn(t *testing.T) {
+	cliCtx := &context.ClientContext{DB: mockdb.DB("defalut", 1)}
+	servCtx := &context.ServerContext{
+		Store:       mockdb,
+		ExplicitTxn: conf.ExplicitTxn{IdleTimeout: time.Minute},
+	}
+
+	call := func(name string, args ...string) []string {
+		ctx := snapshotContext(cliCtx, servCtx, name, args...)
+		Call(ctx)
+		return ctxLines(ctx.Out)
+	}
+
+	// commands before TXN.BEGIN are rejected
+	assert.Contains(t, call("txn.exec", "set", "txn-key", "v1")[0], "no transaction is begun")
+	assert.Contains(t, call("txn.commit")[0], "no transaction is begun")
+
+	assert.Equal(t, "+OK", call("txn.begin")[0])
+	assert.Contains(t, call("txn.begin")[0], "already begun")
+
+	// a write inside the transaction is invisible before the commit
+	assert.Equal(t, "+OK", call("txn.exec", "set", "txn-key", "v1")[0])
+	other := ContextTest("get", "txn-key")
+	Call(other)
+	assert.Equal(t, "$-1", ctxLines(other.Out)[0])
+
+	// the read joins the transaction and sees the uncommitted write
+	lines := call("txn.exec", "get", "txn-key")
+	assert.Equal(t, "$2", lines[0])
+	assert.Equal(t, "v1", lines[1])
+
+	assert.Equal(t, "+OK", call("txn.commit")[0])
+	assert.Nil(t, cliCtx.ExplicitTxn)
+	other = ContextTest("get", "txn-key")
+	Call(other)
+	assert.Equal(t, "v1", ctxLines(other.Out)[1])
+
+	// rollback discards the pinned writes
+	assert.Equal(t, "+OK", call("txn.begin")[0])
+	call("txn.exec", "set", "txn-key", "v2")
+	assert.Equal(t, "+OK", call("txn.rollback")[0])
+	other = ContextTest("get", "txn-key")
+	Call(other)
+	assert.Equal(t, "v1", ctxLines(other.Out)[1])
+
+	// an idle transaction is rolled back on its next use
+	assert.Equal(t, "+OK", call("txn.begin")[0])
+	cliCtx.ExplicitTxnAt = time.Now().Add(-2 * time.Minute)
+	assert.Contains(t, call("txn.exec", "get", "txn-key")[0], "idle timeout")
+	assert.Nil(t, cliCtx.ExplicitTxn)
+
+	// unknown and malformed inner commands do not kill the transaction
+	assert.Equal(t, "+OK", call("txn.begin")[0])
+	assert.Contains(t, call("txn.exec", "nosuchcmd")[0], "unknown command")
+	assert.Contains(t, call("txn.exec", "get")[0], "wrong number of arguments")
+	assert.NotNil(t, cliCtx.ExplicitTxn)
+	assert.Equal(t, "+OK", call("txn.rollback")[0])
+
+	// the whole extension can be disabled
+	servCtx.ExplicitTxn.Disable = true
+	assert.Contains(t, call("txn.begin")[0], "disabled")
+}
+
+func TestExplicitTxnInvalidatesSnapshot(t *testing.T) {
+	cliCtx := &context.ClientContext{DB: mockdb.DB("defalut", 1)}
+	servCtx := &context.ServerContext{
+		Store:         mockdb,
+		ExplicitTxn:   conf.ExplicitTxn{IdleTimeout: time.Minute},
+		SnapshotReuse: conf.SnapshotReuse{MaxAge: time.Minute},
+	}
+
+	call := func(name string, args ...string) []string {
+		ctx := snapshotContext(cliCtx, servCtx, name, args...)
+		Call(ctx)
+		return ctxLines(ctx.Out)
+	}
+
+	call("set", "txn-snapshot-key", "v1")
+	assert.Equal(t, "$2", call("get", "txn-snapshot-key")[0])
+	assert.NotNil(t, cliCtx.ReadTxn)
+
+	// a write joining the pinned transaction drops the shared snapshot
+	assert.Equal(t, "+OK", call("txn.begin")[0])
+	assert.Equal(t, "+OK", call("txn.exec", "set", "txn-snapshot-key", "value2")[0])
+	assert.Nil(t, cliCtx.ReadTxn)
+
+	// a plain read meanwhile re-establishes one, the commit must drop it
+	// again or the next read would miss the published writes
+	assert.Equal(t, "$2", call("get", "txn-snapshot-key")[0])
+	assert.NotNil(t, cliCtx.ReadTxn)
+	assert.Equal(t, "+OK", call("txn.commit")[0])
+	assert.Nil(t, cliCtx.ReadTxn)
+	lines := call("get", "txn-snapshot-key")
+	assert.Equal(t, "$6", lines[0])
+	assert.Equal(t, "value2", lines[1])
+}
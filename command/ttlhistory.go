@@ -0,0 +1,98 @@
+package command
+
+import (
+	"strconv"
+	"sync"
+	"time"
+)
+
+// ttlHistoryMaxKeys caps how many keys of a namespace are journaled at once
+// so a misbehaving tenant cannot grow the journal without bound, mutations
+// of further keys are dropped until entries age out by disabling the journal
+const ttlHistoryMaxKeys = 4096
+
+// ttlHistoryEntry is one recorded ttl mutation of a key
+type ttlHistoryEntry struct {
+	at      time.Time
+	command string
+	action  string
+	client  string
+}
+
+// ttlHistory journals the last N ttl mutations per key of the namespaces it
+// was enabled for, it answers the recurring "who removed my ttl" incidents
+// without combing through command traces. The journal is node local and
+// meant as a debug tool, not as a durable audit log
+var ttlHistory = struct {
+	sync.RWMutex
+	namespaces map[string]int64 // enabled namespaces and their depth
+	keys       map[string][]ttlHistoryEntry
+}{
+	namespaces: make(map[string]int64),
+	keys:       make(map[string][]ttlHistoryEntry),
+}
+
+// SetTTLHistory switches the ttl journal of a namespace, depth is the
+// number of mutations kept per key and zero turns the journal off and
+// drops the entries collected so far
+func SetTTLHistory(namespace string, depth int64) {
+	ttlHistory.Lock()
+	defer ttlHistory.Unlock()
+	if depth <= 0 {
+		delete(ttlHistory.namespaces, namespace)
+		prefix := namespace + "/"
+		for key := range ttlHistory.keys {
+			if len(key) > len(prefix) && key[:len(prefix)] == prefix {
+				delete(ttlHistory.keys, key)
+			}
+		}
+		return
+	}
+	ttlHistory.namespaces[namespace] = depth
+}
+
+// recordTTLChange journals a ttl mutation of the key, a no-op unless the
+// journal of the client's namespace is enabled
+func recordTTLChange(ctx *Context, key []byte, action string) {
+	ns := ctx.Client.Namespace
+	ttlHistory.RLock()
+	_, on := ttlHistory.namespaces[ns]
+	ttlHistory.RUnlock()
+	if !on {
+		return
+	}
+
+	entry := ttlHistoryEntry{
+		at:      time.Now(),
+		command: ctx.Name,
+		action:  action,
+		client:  strconv.FormatInt(ctx.Client.ID, 10) + "@" + ctx.Client.RemoteAddr,
+	}
+	jkey := ns + "/" + string(key)
+	ttlHistory.Lock()
+	defer ttlHistory.Unlock()
+	depth, on := ttlHistory.namespaces[ns]
+	if !on {
+		return
+	}
+	entries, tracked := ttlHistory.keys[jkey]
+	if !tracked && len(ttlHistory.keys) >= ttlHistoryMaxKeys {
+		return
+	}
+	entries = append(entries, entry)
+	if int64(len(entries)) > depth {
+		entries = entries[int64(len(entries))-depth:]
+	}
+	ttlHistory.keys[jkey] = entries
+}
+
+// ttlHistoryOf returns the journaled mutations of a key of the namespace,
+// oldest first
+func ttlHistoryOf(namespace string, key []byte) []ttlHistoryEntry {
+	ttlHistory.RLock()
+	defer ttlHistory.RUnlock()
+	entries := ttlHistory.keys[namespace+"/"+string(key)]
+	out := make([]ttlHistoryEntry, len(entries))
+	copy(out, entries)
+	return out
+}
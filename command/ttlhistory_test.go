@@ -0,0 +1,43 @@
+package command
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTTLHistory(t *testing.T) {
+	// the journal is off by default, mutations leave no trace
+	ctx := ContextTest("expire", "ttlhist-key", "100")
+	Call(ctx)
+	assert.Empty(t, ttlHistoryOf("", []byte("ttlhist-key")))
+
+	ctx = ContextTest("debug", "ttlhistory", "", "8")
+	Call(ctx)
+	assert.Equal(t, "+OK", ctxLines(ctx.Out)[0])
+
+	Call(ContextTest("set", "ttlhist-key", "v"))
+	Call(ContextTest("expire", "ttlhist-key", "100"))
+	Call(ContextTest("persist", "ttlhist-key"))
+
+	entries := ttlHistoryOf("", []byte("ttlhist-key"))
+	assert.Len(t, entries, 3)
+	assert.Equal(t, "set", entries[0].command)
+	assert.Equal(t, "ttl_cleared", entries[0].action)
+	assert.Equal(t, "expire", entries[1].command)
+	assert.True(t, strings.HasPrefix(entries[1].action, "expire_at="))
+	assert.Equal(t, "persist", entries[2].command)
+	assert.Equal(t, "ttl_cleared", entries[2].action)
+
+	ctx = ContextTest("debug", "ttlhistory", "ttlhist-key")
+	Call(ctx)
+	lines := ctxLines(ctx.Out)
+	assert.Equal(t, "*3", lines[0])
+
+	// depth 0 turns the journal off and drops the entries
+	ctx = ContextTest("debug", "ttlhistory", "", "0")
+	Call(ctx)
+	assert.Equal(t, "+OK", ctxLines(ctx.Out)[0])
+	assert.Empty(t, ttlHistoryOf("", []byte("ttlhist-key")))
+}
@@ -3,39 +3,81 @@ package command
 import (
 	"errors"
 	"math"
+	"sort"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/distributedio/titan/db"
 )
 
-// ZAdd adds the specified members with scores to the sorted set
+// firstOccurrences returns, in their original order, the indices of the
+// first occurrence of every distinct member. The dedup sorts an index slice
+// instead of keying a map by member, which allocates far less on huge
+// argument lists
+func firstOccurrences(count int, memberAt func(int) string) []int {
+	idx := make([]int, count)
+	for i := range idx {
+		idx[i] = i
+	}
+	sort.Slice(idx, func(a, b int) bool {
+		ma, mb := memberAt(idx[a]), memberAt(idx[b])
+		if ma == mb {
+			return idx[a] < idx[b]
+		}
+		return ma < mb
+	})
+	kept := make([]int, 0, count)
+	for _, i := range idx {
+		if len(kept) > 0 && memberAt(i) == memberAt(kept[len(kept)-1]) {
+			continue
+		}
+		kept = append(kept, i)
+	}
+	sort.Ints(kept)
+	return kept
+}
+
+// ZAdd adds the specified members with scores to the sorted set. With the
+// PAYLOADS token the arguments come in score member payload triples and the
+// payload is stored alongside the member, a titan extension for leaderboard
+// metadata that would otherwise need a parallel hash
 func ZAdd(ctx *Context, txn *db.Transaction) (OnCommit, error) {
 	key := []byte(ctx.Args[0])
 
 	kvs := ctx.Args[1:]
-	if len(kvs)%2 != 0 {
+	withPayload := false
+	width := 2
+	if len(kvs) > 0 && strings.ToUpper(kvs[0]) == "PAYLOADS" {
+		withPayload = true
+		width = 3
+		kvs = kvs[1:]
+	}
+	if len(kvs) == 0 || len(kvs)%width != 0 {
 		return nil, errors.New("ERR syntax error")
 	}
+	if err := checkBatchArgs(ctx, len(ctx.Args)); err != nil {
+		return nil, err
+	}
 
-	uniqueMembers := make(map[string]bool)
-	count := len(kvs) / 2
-	members := make([][]byte, 0, count)
-	scores := make([]float64, 0, count)
-	for i := 0; i < len(kvs)-1; i += 2 {
-		member := kvs[i+1]
-		if _, ok := uniqueMembers[member]; ok {
-			continue
-		}
-
-		members = append(members, []byte(member))
-		score, err := strconv.ParseFloat(kvs[i], 64)
+	count := len(kvs) / width
+	kept := firstOccurrences(count, func(i int) string { return kvs[i*width+1] })
+	members := make([][]byte, 0, len(kept))
+	scores := make([]float64, 0, len(kept))
+	var payloads [][]byte
+	if withPayload {
+		payloads = make([][]byte, 0, len(kept))
+	}
+	for _, i := range kept {
+		members = append(members, []byte(kvs[i*width+1]))
+		score, err := strconv.ParseFloat(kvs[i*width], 64)
 		if err != nil || math.IsNaN(score) {
 			return nil, ErrFloat
 		}
 		scores = append(scores, score)
-
-		uniqueMembers[member] = true
+		if withPayload {
+			payloads = append(payloads, []byte(kvs[i*width+2]))
+		}
 	}
 
 	zset, err := txn.ZSet(key)
@@ -46,7 +88,12 @@ func ZAdd(ctx *Context, txn *db.Transaction) (OnCommit, error) {
 		return nil, errors.New("ERR " + err.Error())
 	}
 
-	added, err := zset.ZAdd(members, scores)
+	var added int64
+	if withPayload {
+		added, err = zset.ZAddWithPayloads(members, scores, payloads)
+	} else {
+		added, err = zset.ZAdd(members, scores)
+	}
 	if err != nil {
 		return nil, errors.New("ERR " + err.Error())
 	}
@@ -54,6 +101,31 @@ func ZAdd(ctx *Context, txn *db.Transaction) (OnCommit, error) {
 	return Integer(ctx.Out, added), nil
 }
 
+// ZIncrBy increments the score of member by delta and replies with the
+// new score, a missing member is added with delta as its score
+func ZIncrBy(ctx *Context, txn *db.Transaction) (OnCommit, error) {
+	key := []byte(ctx.Args[0])
+	delta, err := strconv.ParseFloat(ctx.Args[1], 64)
+	if err != nil || math.IsNaN(delta) {
+		return nil, ErrFloat
+	}
+	member := []byte(ctx.Args[2])
+
+	zset, err := txn.ZSet(key)
+	if err != nil {
+		if err == db.ErrTypeMismatch {
+			return nil, ErrTypeMismatch
+		}
+		return nil, errors.New("ERR " + err.Error())
+	}
+
+	score, err := zset.ZIncrBy(member, delta)
+	if err != nil {
+		return nil, errors.New("ERR " + err.Error())
+	}
+	return BulkString(ctx.Out, db.FormatScore(score)), nil
+}
+
 func ZRange(ctx *Context, txn *db.Transaction) (OnCommit, error) {
 	return zAnyOrderRange(ctx, txn, true)
 }
@@ -73,9 +145,15 @@ func zAnyOrderRange(ctx *Context, txn *db.Transaction, positiveOrder bool) (OnCo
 		return nil, ErrInteger
 	}
 	withScore := bool(false)
-	if len(ctx.Args) >= 4 {
-		if strings.ToUpper(ctx.Args[3]) == "WITHSCORES" {
+	withPayload := bool(false)
+	for _, arg := range ctx.Args[3:] {
+		switch strings.ToUpper(arg) {
+		case "WITHSCORES":
 			withScore = true
+		case "WITHPAYLOADS":
+			withPayload = true
+		default:
+			return nil, ErrSyntax
 		}
 	}
 
@@ -97,9 +175,51 @@ func zAnyOrderRange(ctx *Context, txn *db.Transaction, positiveOrder bool) (OnCo
 	if len(items) == 0 {
 		return BytesArrayOnce(ctx.Out, nil), nil
 	}
+	if withPayload {
+		if items, err = appendPayloads(zset, items, withScore); err != nil {
+			return nil, errors.New("ERR " + err.Error())
+		}
+		if err := checkReplySize(ctx, len(items)); err != nil {
+			return nil, err
+		}
+		if err := accountReplySize(ctx, items); err != nil {
+			return nil, err
+		}
+		// BytesArray keeps null entries for members without a payload
+		return BytesArray(ctx.Out, items), nil
+	}
+	if err := checkReplySize(ctx, len(items)); err != nil {
+		return nil, err
+	}
+	if err := accountReplySize(ctx, items); err != nil {
+		return nil, err
+	}
 	return BytesArrayOnce(ctx.Out, items), nil
 }
 
+// appendPayloads rewrites a flat member[,score] reply with each member's
+// payload appended to its group, the payloads come through one batch get
+func appendPayloads(zset *db.ZSet, items [][]byte, withScore bool) ([][]byte, error) {
+	width := 1
+	if withScore {
+		width = 2
+	}
+	members := make([][]byte, 0, len(items)/width)
+	for i := 0; i < len(items); i += width {
+		members = append(members, items[i])
+	}
+	payloads, err := zset.ZPayloads(members)
+	if err != nil {
+		return nil, err
+	}
+	out := make([][]byte, 0, len(items)+len(payloads))
+	for i := 0; i < len(payloads); i++ {
+		out = append(out, items[i*width:(i+1)*width]...)
+		out = append(out, payloads[i])
+	}
+	return out, nil
+}
+
 func ZRangeByScore(ctx *Context, txn *db.Transaction) (OnCommit, error) {
 	return zAnyOrderRangeByScore(ctx, txn, true)
 }
@@ -159,21 +279,189 @@ func zAnyOrderRangeByScore(ctx *Context, txn *db.Transaction, positiveOrder bool
 	if len(items) == 0 {
 		return BytesArrayOnce(ctx.Out, nil), nil
 	}
+	if err := checkReplySize(ctx, len(items)); err != nil {
+		return nil, err
+	}
+	if err := accountReplySize(ctx, items); err != nil {
+		return nil, err
+	}
 	return BytesArrayOnce(ctx.Out, items), nil
 }
 
-func ZRem(ctx *Context, txn *db.Transaction) (OnCommit, error) {
+// ZCount returns the number of members with a score between min and max
+func ZCount(ctx *Context, txn *db.Transaction) (OnCommit, error) {
 	key := []byte(ctx.Args[0])
+	min, minInclude, err := getFloatAndInclude(ctx.Args[1])
+	if err != nil {
+		return nil, ErrMinOrMaxNotFloat
+	}
+	max, maxInclude, err := getFloatAndInclude(ctx.Args[2])
+	if err != nil {
+		return nil, ErrMinOrMaxNotFloat
+	}
 
-	uniqueMembers := make(map[string]bool)
-	members := make([][]byte, 0, len(ctx.Args)-1)
-	for _, member := range ctx.Args[1:] {
-		if _, ok := uniqueMembers[member]; ok {
-			continue
+	zset, err := txn.ZSet(key)
+	if err != nil {
+		if err == db.ErrTypeMismatch {
+			return nil, ErrTypeMismatch
 		}
+		return nil, errors.New("ERR " + err.Error())
+	}
+	if !zset.Exist() {
+		return Integer(ctx.Out, 0), nil
+	}
+
+	count, err := zset.ZCount(min, minInclude, max, maxInclude)
+	if err != nil {
+		return nil, errors.New("ERR " + err.Error())
+	}
+	return Integer(ctx.Out, count), nil
+}
 
-		members = append(members, []byte(member))
-		uniqueMembers[member] = true
+// ZLexCount returns the number of members between min and max in
+// lexicographic order
+func ZLexCount(ctx *Context, txn *db.Transaction) (OnCommit, error) {
+	key := []byte(ctx.Args[0])
+	min, minInclude, minInf, err := parseLexBound(ctx.Args[1])
+	if err != nil {
+		return nil, err
+	}
+	max, maxInclude, maxInf, err := parseLexBound(ctx.Args[2])
+	if err != nil {
+		return nil, err
+	}
+	// a low bound pinned to the high end or the other way round covers
+	// nothing
+	if minInf > 0 || maxInf < 0 {
+		return Integer(ctx.Out, 0), nil
+	}
+
+	zset, err := txn.ZSet(key)
+	if err != nil {
+		if err == db.ErrTypeMismatch {
+			return nil, ErrTypeMismatch
+		}
+		return nil, errors.New("ERR " + err.Error())
+	}
+	if !zset.Exist() {
+		return Integer(ctx.Out, 0), nil
+	}
+
+	count, err := zset.ZLexCount(min, minInclude, max, maxInclude)
+	if err != nil {
+		return nil, errors.New("ERR " + err.Error())
+	}
+	return Integer(ctx.Out, count), nil
+}
+
+// parseLexBound parses a ZRANGEBYLEX bound. A non-zero inf stands for the
+// unbounded low (-1) or high (1) end, otherwise "[" opens an inclusive
+// bound and "(" an exclusive one
+func parseLexBound(arg string) (member []byte, include bool, inf int, err error) {
+	switch arg {
+	case "-":
+		return nil, true, -1, nil
+	case "+":
+		return nil, true, 1, nil
+	}
+	if len(arg) == 0 {
+		return nil, false, 0, ErrLexRange
+	}
+	switch arg[0] {
+	case '[':
+		return []byte(arg)[1:], true, 0, nil
+	case '(':
+		return []byte(arg)[1:], false, 0, nil
+	}
+	return nil, false, 0, ErrLexRange
+}
+
+// ZRangeByLex returns the members between min and max in lexicographic order
+func ZRangeByLex(ctx *Context, txn *db.Transaction) (OnCommit, error) {
+	return zAnyOrderRangeByLex(ctx, txn, true)
+}
+
+// ZRevRangeByLex returns the members between max and min in reverse
+// lexicographic order
+func ZRevRangeByLex(ctx *Context, txn *db.Transaction) (OnCommit, error) {
+	return zAnyOrderRangeByLex(ctx, txn, false)
+}
+
+func zAnyOrderRangeByLex(ctx *Context, txn *db.Transaction, positiveOrder bool) (OnCommit, error) {
+	key := []byte(ctx.Args[0])
+
+	minArg, maxArg := ctx.Args[1], ctx.Args[2]
+	if !positiveOrder {
+		// ZREVRANGEBYLEX takes max before min
+		minArg, maxArg = maxArg, minArg
+	}
+	min, minInclude, minInf, err := parseLexBound(minArg)
+	if err != nil {
+		return nil, err
+	}
+	max, maxInclude, maxInf, err := parseLexBound(maxArg)
+	if err != nil {
+		return nil, err
+	}
+
+	offset := int64(0)
+	count := int64(math.MaxInt64)
+	for i := 3; i < len(ctx.Args); i++ {
+		switch strings.ToUpper(ctx.Args[i]) {
+		case "LIMIT":
+			if offset, count, err = getLimitParameters(ctx.Args[i+1:]); err != nil {
+				return nil, err
+			}
+			i += 2
+		default:
+			return nil, ErrSyntax
+		}
+	}
+
+	// a low bound pinned to the high end or the other way round covers
+	// nothing
+	if minInf > 0 || maxInf < 0 {
+		return BytesArrayOnce(ctx.Out, nil), nil
+	}
+
+	zset, err := txn.ZSet(key)
+	if err != nil {
+		if err == db.ErrTypeMismatch {
+			return nil, ErrTypeMismatch
+		}
+		return nil, errors.New("ERR " + err.Error())
+	}
+	if !zset.Exist() {
+		return BytesArrayOnce(ctx.Out, nil), nil
+	}
+
+	members, err := zset.ZRangeByLex(min, minInclude, max, maxInclude, offset, count, positiveOrder)
+	if err != nil {
+		return nil, errors.New("ERR " + err.Error())
+	}
+	if len(members) == 0 {
+		return BytesArrayOnce(ctx.Out, nil), nil
+	}
+	if err := checkReplySize(ctx, len(members)); err != nil {
+		return nil, err
+	}
+	if err := accountReplySize(ctx, members); err != nil {
+		return nil, err
+	}
+	return BytesArrayOnce(ctx.Out, members), nil
+}
+
+func ZRem(ctx *Context, txn *db.Transaction) (OnCommit, error) {
+	key := []byte(ctx.Args[0])
+	if err := checkBatchArgs(ctx, len(ctx.Args)); err != nil {
+		return nil, err
+	}
+
+	args := ctx.Args[1:]
+	kept := firstOccurrences(len(args), func(i int) string { return args[i] })
+	members := make([][]byte, 0, len(kept))
+	for _, i := range kept {
+		members = append(members, []byte(args[i]))
 	}
 
 	zset, err := txn.ZSet(key)
@@ -212,9 +500,22 @@ func ZCard(ctx *Context, txn *db.Transaction) (OnCommit, error) {
 	return Integer(ctx.Out, zset.ZCard()), nil
 }
 
+// ZScore returns the score of a member, with the WITHPAYLOAD token the
+// reply is an array of the score and the stored payload, a null payload
+// entry when the member carries none
 func ZScore(ctx *Context, txn *db.Transaction) (OnCommit, error) {
 	key := []byte(ctx.Args[0])
 	member := []byte(ctx.Args[1])
+	if len(ctx.Args) > 3 {
+		return nil, ErrWrongArgs(ctx.Name)
+	}
+	withPayload := false
+	if len(ctx.Args) == 3 {
+		if strings.ToUpper(ctx.Args[2]) != "WITHPAYLOAD" {
+			return nil, ErrSyntax
+		}
+		withPayload = true
+	}
 
 	zset, err := txn.ZSet(key)
 	if err != nil {
@@ -227,7 +528,7 @@ func ZScore(ctx *Context, txn *db.Transaction) (OnCommit, error) {
 		return NullBulkString(ctx.Out), nil
 	}
 
-	score, err := zset.ZScore(member)
+	score, payload, err := zset.ZScoreWithPayload(member)
 	if err != nil {
 		return nil, errors.New("ERR " + err.Error())
 	}
@@ -235,5 +536,117 @@ func ZScore(ctx *Context, txn *db.Transaction) (OnCommit, error) {
 		return NullBulkString(ctx.Out), nil
 	}
 
+	if withPayload {
+		return BytesArray(ctx.Out, [][]byte{score, payload}), nil
+	}
 	return BulkString(ctx.Out, string(score)), nil
 }
+
+// ZMScore returns the scores of the given members through one batch get, a
+// null for every member that does not exist
+func ZMScore(ctx *Context, txn *db.Transaction) (OnCommit, error) {
+	key := []byte(ctx.Args[0])
+	members := make([][]byte, len(ctx.Args)-1)
+	for i, member := range ctx.Args[1:] {
+		members[i] = []byte(member)
+	}
+
+	zset, err := txn.ZSet(key)
+	if err != nil {
+		if err == db.ErrTypeMismatch {
+			return nil, ErrTypeMismatch
+		}
+		return nil, errors.New("ERR " + err.Error())
+	}
+	if !zset.Exist() {
+		return BytesArray(ctx.Out, make([][]byte, len(members))), nil
+	}
+
+	scores, err := zset.ZMScore(members)
+	if err != nil {
+		return nil, errors.New("ERR " + err.Error())
+	}
+	return BytesArray(ctx.Out, scores), nil
+}
+
+// ZRandMember returns random members of the sorted set, a positive count
+// yields distinct members, a negative one may repeat some
+func ZRandMember(ctx *Context, txn *db.Transaction) (OnCommit, error) {
+	key := []byte(ctx.Args[0])
+	count := int64(1)
+	hasCount := false
+	withScore := false
+	if len(ctx.Args) >= 2 {
+		c, err := strconv.ParseInt(ctx.Args[1], 10, 64)
+		if err != nil {
+			return nil, ErrInteger
+		}
+		count = c
+		hasCount = true
+	}
+	if len(ctx.Args) == 3 {
+		if strings.ToLower(ctx.Args[2]) != "withscores" {
+			return nil, ErrSyntax
+		}
+		withScore = true
+	}
+
+	zset, err := txn.ZSet(key)
+	if err != nil {
+		if err == db.ErrTypeMismatch {
+			return nil, ErrTypeMismatch
+		}
+		return nil, errors.New("ERR " + err.Error())
+	}
+	if !zset.Exist() {
+		if hasCount {
+			return BytesArray(ctx.Out, [][]byte{}), nil
+		}
+		return NullBulkString(ctx.Out), nil
+	}
+
+	items, err := zset.ZRandMember(count, withScore)
+	if err != nil {
+		return nil, errors.New("ERR " + err.Error())
+	}
+	if !hasCount {
+		if len(items) == 0 {
+			return NullBulkString(ctx.Out), nil
+		}
+		return BulkString(ctx.Out, string(items[0])), nil
+	}
+	return BytesArray(ctx.Out, items), nil
+}
+
+// ZExpireMember sets a ttl in seconds on a member of a sorted set, the
+// member is removed by the member expire worker once it is due. A ttl <= 0
+// drops a previously set ttl and the member stays
+func ZExpireMember(ctx *Context, txn *db.Transaction) (OnCommit, error) {
+	key := []byte(ctx.Args[0])
+	member := []byte(ctx.Args[1])
+	seconds, err := strconv.ParseInt(ctx.Args[2], 10, 64)
+	if err != nil {
+		return nil, ErrInteger
+	}
+
+	zset, err := txn.ZSet(key)
+	if err != nil {
+		if err == db.ErrTypeMismatch {
+			return nil, ErrTypeMismatch
+		}
+		return nil, errors.New("ERR " + err.Error())
+	}
+
+	at := int64(0)
+	if seconds > 0 {
+		at = time.Now().Add(time.Second * time.Duration(seconds)).UnixNano()
+	}
+	ok, err := zset.ZExpireMember(member, at)
+	if err != nil {
+		return nil, errors.New("ERR " + err.Error())
+	}
+	if !ok {
+		return Integer(ctx.Out, 0), nil
+	}
+	return Integer(ctx.Out, 1), nil
+}
@@ -0,0 +1,17 @@
+package command
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestZCountEmptyBound(t *testing.T) {
+	// an empty bound used to index into the string and crash the server
+	out := CallTest("zcount", "zcount-bound-key", "", "1")
+	assert.Equal(t, "-"+ErrMinOrMaxNotFloat.Error(), ctxLines(out)[0])
+	out = CallTest("zcount", "zcount-bound-key", "1", "")
+	assert.Equal(t, "-"+ErrMinOrMaxNotFloat.Error(), ctxLines(out)[0])
+	out = CallTest("zrangebyscore", "zcount-bound-key", "", "1")
+	assert.Equal(t, "-"+ErrMinOrMaxNotFloat.Error(), ctxLines(out)[0])
+}
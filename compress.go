@@ -0,0 +1,19 @@
+package titan
+
+import (
+	"io"
+
+	"github.com/pierrec/lz4/v4"
+)
+
+// writeLZ4Frame writes p to w as one self contained lz4 frame. A
+// client which negotiated compression with the compress command tells
+// a frame apart from plain RESP bytes by the lz4 frame magic number,
+// which can never collide with a RESP type prefix at a reply boundary
+func writeLZ4Frame(w io.Writer, p []byte) error {
+	zw := lz4.NewWriter(w)
+	if _, err := zw.Write(p); err != nil {
+		return err
+	}
+	return zw.Close()
+}
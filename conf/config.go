@@ -8,12 +8,19 @@ import (
 type Titan struct {
 	Server      Server     `cfg:"server"`
 	Status      Status     `cfg:"status"`
+	Memcached   Memcached  `cfg:"memcached"`
 	Tikv        Tikv       `cfg:"tikv"`
 	TikvLog     TikvLogger `cfg:"tikv-logger"`
 	Logger      Logger     `cfg:"logger"`
 	PIDFileName string     `cfg:"pid-filename; titan.pid; ; the file name to record connd PID"`
 }
 
+// Memcached config is the config of the memcached text protocol listener
+type Memcached struct {
+	Listen    string `cfg:"listen;;;listen address of the memcached text protocol server, empty disables it"`
+	Namespace string `cfg:"namespace; default; ; namespace assigned to memcached connections"`
+}
+
 // DB config is the config of titan data struct
 type DB struct {
 	Hash Hash `cfg:"hash"`
@@ -26,25 +33,217 @@ type Hash struct {
 
 // Server config is the config of titan server
 type Server struct {
-	Auth              string `cfg:"auth;;;client connetion auth"`
-	Listen            string `cfg:"listen; 0.0.0.0:7369; netaddr; address to listen"`
-	SSLCertFile       string `cfg:"ssl-cert-file;;;server SSL certificate file (enables SSL support)"`
-	SSLKeyFile        string `cfg:"ssl-key-file;;;server SSL key file"`
-	LimitConnection   bool   `cfg:"limit-connection; false; boolean; limit max connection num when it's true"`
-	MaxConnection     int64  `cfg:"max-connection;500;numeric;client connection count"`
-	ListZipThreshold  int    `cfg:"list-zip-threshold;100;numeric;the max limit length of elements in list"`
-	MaxConnectionWait int64  `cfg:"max-connection-wait;1000;numeric;wait ms before close connection when exceed max connection"`
+	Auth               string        `cfg:"auth;;;client connetion auth"`
+	AuthProviderURL    string        `cfg:"auth-provider-url;;;external auth verifier url, when set tokens are validated by http callout instead of the static auth secret"`
+	AuthTimeout        time.Duration `cfg:"auth-timeout;1s;;max time to wait for the external auth verifier"`
+	AuthCacheTTL       time.Duration `cfg:"auth-cache-ttl;1m;;how long a verdict of the external auth verifier is cached"`
+	Listen             string        `cfg:"listen; 0.0.0.0:7369; netaddr; address to listen"`
+	SSLCertFile        string        `cfg:"ssl-cert-file;;;server SSL certificate file (enables SSL support)"`
+	SSLKeyFile         string        `cfg:"ssl-key-file;;;server SSL key file"`
+	LimitConnection    bool          `cfg:"limit-connection; false; boolean; limit max connection num when it's true"`
+	MaxConnection      int64         `cfg:"max-connection;500;numeric;client connection count"`
+	ListZipThreshold   int           `cfg:"list-zip-threshold;100;numeric;the max limit length of elements in list"`
+	MaxConnectionWait  int64         `cfg:"max-connection-wait;1000;numeric;wait ms before close connection when exceed max connection"`
+	RenameCommand      string        `cfg:"rename-command;;;comma separated oldname:newname pairs applied at startup, an empty newname disables the command"`
+	AliasCommand       string        `cfg:"alias-command;;;comma separated alias:command pairs registered at startup, an alias dispatches and reports metrics as the command it points to"`
+	LatencySLO         string        `cfg:"latency-slo;;;comma separated namespace:class:threshold latency slo rules evaluated on the rolling p99, class is read, write or all"`
+	NamespaceDBRange   string        `cfg:"namespace-db-range;;;comma separated namespace=min-max entries pinning a namespace to a db index range for SELECT"`
+	StrictClusterSlots bool          `cfg:"strict-cluster-slots; false; boolean; reject multi-key commands whose keys hash to different redis cluster slots"`
+	MaxPipelineBurst   int           `cfg:"max-pipeline-burst;128;numeric;commands executed back to back from one connection while more input is pipelined, the connection yields beyond it, 0 means unlimited"`
+	MaxReplyElements   int           `cfg:"max-reply-elements;0;numeric;max elements of one collection reply, bigger replies fail with an error, admin connections are exempt, 0 means unlimited"`
+	MaxReplyBytes      int           `cfg:"max-reply-bytes;0;numeric;max bytes of one reply, the connection is closed when a reply grows beyond it, admin connections are exempt, 0 means unlimited"`
+	MaxBatchArgs       int           `cfg:"max-batch-args;0;numeric;max arguments of one multi-member command, longer commands fail with an error, admin connections are exempt, 0 means unlimited"`
+	MaxRequestBytes    int           `cfg:"max-request-bytes;0;numeric;max approximate bytes one command may allocate for arguments, buffers and reply, requests beyond it are terminated, admin connections are exempt, 0 means unlimited"`
+
+	TxnPool       TxnPool       `cfg:"txn-pool"`
+	AcceptLimit   AcceptLimit   `cfg:"accept-limit"`
+	SnapshotReuse SnapshotReuse `cfg:"snapshot-reuse"`
+	Journal       Journal       `cfg:"journal"`
+	ExplicitTxn   ExplicitTxn   `cfg:"explicit-txn"`
+	EventLoop     EventLoop     `cfg:"event-loop"`
+}
+
+// ExplicitTxn config enables the TXN.BEGIN/TXN.EXEC/TXN.COMMIT/TXN.ROLLBACK
+// extension pinning a storage transaction to the connection, multi-key ACID
+// across commands beyond what MULTI offers. A pinned transaction left idle
+// for longer than idle-timeout is rolled back on its next use
+type ExplicitTxn struct {
+	Disable     bool          `cfg:"disable; true; boolean; true is used to disable the explicit transaction commands"`
+	IdleTimeout time.Duration `cfg:"idle-timeout; 10s;; a pinned transaction idle for longer is rolled back"`
+}
+
+// EventLoop is the config of the epoll based connection frontend, idle
+// connections are parked in the poller instead of a blocked goroutine.
+// Linux only, other platforms fall back to a goroutine per connection
+type EventLoop struct {
+	Disable bool `cfg:"disable; true; boolean; true is used to disable the event loop connection frontend"`
+	Workers int  `cfg:"workers; 0; numeric; goroutines serving ready connections, 0 means one per processor"`
+}
+
+// Journal config keeps a local size-bounded trail of the write commands a
+// node accepted, for crash diagnostics. The file rotates once to path.old
+// when max-entries is reached, so at most twice max-entries survive
+type Journal struct {
+	Disable    bool   `cfg:"disable; true; boolean; true is used to disable the write command journal"`
+	Path       string `cfg:"path; logs/journal.log;; file the journal is appended to"`
+	MaxEntries int    `cfg:"max-entries; 4096; numeric; write commands kept per journal file"`
+}
+
+// SnapshotReuse config lets consecutive read-only commands of one connection
+// share a read transaction instead of allocating a fresh timestamp each, a
+// big win for connections doing thousands of reads back to back. The shared
+// snapshot is refreshed once it is older than max-age or when the
+// connection runs a write
+type SnapshotReuse struct {
+	Disable bool          `cfg:"disable; true; boolean; true is used to disable snapshot reuse for read-only commands"`
+	MaxAge  time.Duration `cfg:"max-age; 20ms;; staleness bound, the shared snapshot is refreshed once it is older"`
+}
+
+// AcceptLimit config throttles the accept loop against connection storms
+type AcceptLimit struct {
+	Disable     bool `cfg:"disable; true; boolean; true is used to disable the accept limit"`
+	Rate        int  `cfg:"rate;1000;numeric;max new connections accepted per second, 0 means unlimited"`
+	Burst       int  `cfg:"burst;1000;numeric;burst size of the accept token bucket"`
+	IPRate      int  `cfg:"ip-rate;100;numeric;max new connections accepted per second from one source ip, 0 means unlimited"`
+	IPBurst     int  `cfg:"ip-burst;100;numeric;burst size of the per source ip token bucket"`
+	IPCacheSize int  `cfg:"ip-cache-size;4096;numeric;max source ips tracked, the oldest bucket is evicted beyond it"`
+}
+
+// TxnPool config limits the tikv transactions running concurrently
+type TxnPool struct {
+	Disable        bool          `cfg:"disable; true; boolean; true is used to disable the txn pool"`
+	GlobalLimit    int           `cfg:"global-limit;1000;numeric;max concurrent tikv transactions of all namespaces, 0 means unlimited"`
+	NamespaceLimit int           `cfg:"namespace-limit;500;numeric;max concurrent tikv transactions per namespace, 0 means unlimited"`
+	AdminReserved  int           `cfg:"admin-reserved;16;numeric;transaction slots reserved for admin commands, which bypass the global and namespace limits"`
+	QueueTimeout   time.Duration `cfg:"queue-timeout;1s;;max time a request waits for a free transaction slot"`
 }
 
 // Tikv config is the config of tikv sdk
 type Tikv struct {
-	PdAddrs   string    `cfg:"pd-addrs;required; ;pd address in tidb"`
-	DB        DB        `cfg:"db"`
-	GC        GC        `cfg:"gc"`
-	Expire    Expire    `cfg:"expire"`
-	ZT        ZT        `cfg:"zt"`
-	TikvGC    TikvGC    `cfg:"tikv-gc"`
-	RateLimit RateLimit `cfg:"rate-limit"`
+	PdAddrs      string       `cfg:"pd-addrs;required; ;pd address in tidb"`
+	DB           DB           `cfg:"db"`
+	GC           GC           `cfg:"gc"`
+	Expire       Expire       `cfg:"expire"`
+	ZT           ZT           `cfg:"zt"`
+	TikvGC       TikvGC       `cfg:"tikv-gc"`
+	RateLimit    RateLimit    `cfg:"rate-limit"`
+	Registry     NodeRegistry `cfg:"node-registry"`
+	Crypto       Crypto       `cfg:"crypto"`
+	Reaper       Reaper       `cfg:"reaper"`
+	ZSM          ZSetMigrate  `cfg:"zset-migrate"`
+	MemberExpire MemberExpire `cfg:"member-expire"`
+	Rewriter     Rewriter     `cfg:"rewriter"`
+	DBSize       DBSize       `cfg:"dbsize"`
+	Warmup       Warmup       `cfg:"warmup"`
+	ReadOnly     ReadOnly     `cfg:"read-only"`
+	Breaker      Breaker      `cfg:"breaker"`
+	ReadRepair   ReadRepair   `cfg:"read-repair"`
+	LazyDelete   LazyDelete   `cfg:"lazy-delete"`
+	Backpressure Backpressure `cfg:"backpressure"`
+}
+
+// Backpressure config controls shedding write load when tikv commits slow
+// down, writes of unprotected namespaces are delayed while the rolling
+// commit p99 stays above the threshold
+type Backpressure struct {
+	Disable   bool          `cfg:"disable; true; boolean; backpressure is opt-in, set false to shed write load on slow commits"`
+	Threshold time.Duration `cfg:"threshold;1s;;rolling commit p99 above this engages the shedding"`
+	Delay     time.Duration `cfg:"delay;5ms;;sleep injected before each write of an unprotected namespace while shedding"`
+	Protected string        `cfg:"protected;;;comma separated namespaces whose writes are never delayed"`
+}
+
+// LazyDelete config controls splitting whole-collection deletes out of the
+// command transaction, large enough collections are destroyed by a meta
+// delete plus a background range gc
+type LazyDelete struct {
+	Threshold int64 `cfg:"threshold; 1000; numeric; collections of at least this many members are destroyed lazily, 0 disables the splitting"`
+}
+
+// ReadRepair config controls the sampled verification of collection meta
+// lengths on read, drifted lengths are rewritten in place
+type ReadRepair struct {
+	Disable     bool `cfg:"disable; true; boolean; read repair is opt-in, set false to let sampled reads verify meta lengths"`
+	SampleEvery int  `cfg:"sample-every; 1000;; one of every N candidate reads verifies the meta len against a real count"`
+	MaxScan     int  `cfg:"max-scan; 4096;; skip the verification when the collection holds more data keys than this"`
+}
+
+// Breaker config controls the circuit breaker of the tikv access
+type Breaker struct {
+	Disable    bool          `cfg:"disable; false; boolean; true is used to disable the circuit breaker"`
+	Window     time.Duration `cfg:"window;10s;;error rate sampling window"`
+	CoolDown   time.Duration `cfg:"cool-down;5s;;how long transactional commands fail fast after the breaker opens"`
+	MinSamples int64         `cfg:"min-samples;20;numeric;samples required in a window before the error rate is evaluated"`
+	ErrorRate  float64       `cfg:"error-rate;0.5;;error ratio of a window that opens the breaker"`
+}
+
+// ReadOnly config controls how often the per namespace read-only markers
+// are synced from tikv
+type ReadOnly struct {
+	SyncPeriod time.Duration `cfg:"sync-period;5s;;refresh interval of the read-only namespace set"`
+}
+
+// Warmup config controls the startup warm-up that primes the pd and tikv
+// connections before the listener starts accepting
+type Warmup struct {
+	Disable    bool   `cfg:"disable; false; boolean; true is used to skip the startup warm-up"`
+	Namespaces string `cfg:"namespaces;;;comma separated namespaces whose key ranges are scanned to pre-open the region caches"`
+	ScanLimit  int    `cfg:"scan-limit;16;numeric;keys touched per namespace while pre-opening the region caches"`
+}
+
+// DBSize config is the config of the key counting reconcile work
+type DBSize struct {
+	Disable        bool          `cfg:"disable; false; boolean; true is used to disable the dbsize reconciler"`
+	Interval       time.Duration `cfg:"interval;1s;;dbsize work tick interval"`
+	LeaderLifeTime time.Duration `cfg:"leader-life-time;3m;;lease flush leader interval"`
+	BatchLimit     int           `cfg:"batch-limit;256;numeric;key count scanned per-transection"`
+}
+
+// Rewriter config is the config of the background rewrite work, the ops
+// budget itself lives in the job record set by the REWRITE command
+type Rewriter struct {
+	Disable        bool          `cfg:"disable; false; boolean; true is used to disable the background rewriter"`
+	Interval       time.Duration `cfg:"interval;1s;;rewrite work tick interval"`
+	LeaderLifeTime time.Duration `cfg:"leader-life-time;3m;;lease flush leader interval"`
+}
+
+// MemberExpire config is the config of the zset member expire work
+type MemberExpire struct {
+	Disable        bool          `cfg:"disable; false; boolean; true is used to disable the zset member expire"`
+	Interval       time.Duration `cfg:"interval;1s;;member expire work tick interval"`
+	LeaderLifeTime time.Duration `cfg:"leader-life-time;3m;;lease flush leader interval"`
+	BatchLimit     int           `cfg:"batch-limit;256;numeric;member count removed per-transection"`
+}
+
+// ZSetMigrate config is the config of the zset score layout converter
+type ZSetMigrate struct {
+	Disable        bool          `cfg:"disable; true; boolean; true is used to disable the zset score migration"`
+	Interval       time.Duration `cfg:"interval;1m;;migrate work tick interval"`
+	LeaderLifeTime time.Duration `cfg:"leader-life-time;3m;;lease flush leader interval"`
+	BatchLimit     int           `cfg:"batch-limit;16;numeric;zset count converted per round"`
+	ScanLimit      int           `cfg:"scan-limit;4096;numeric;meta key count scanned per round"`
+	KeyLimit       int           `cfg:"key-limit;4096;numeric;max member count of a zset converted in one txn"`
+}
+
+// Reaper config is the config of the orphan data reaper work
+type Reaper struct {
+	Disable        bool          `cfg:"disable; true; boolean; true is used to disable the orphan reaper"`
+	Interval       time.Duration `cfg:"interval;10m;;reaper work tick interval"`
+	LeaderLifeTime time.Duration `cfg:"leader-life-time;30m;;lease flush leader interval"`
+	BatchLimit     int           `cfg:"batch-limit;256;numeric;data key count sampled per round"`
+	MetaScanLimit  int           `cfg:"meta-scan-limit;10000;numeric;max meta keys scanned per db, the db is skipped when exceeded"`
+}
+
+// Crypto config is the config of value encryption at rest
+type Crypto struct {
+	MasterKeyFile string `cfg:"master-key-file;;;file holding the 32 byte master key, empty disables value encryption"`
+	Namespaces    string `cfg:"namespaces;;;comma separated namespaces whose values are encrypted, empty means every namespace"`
+}
+
+// NodeRegistry config is the config of the titan node registry heartbeat
+type NodeRegistry struct {
+	Disable  bool          `cfg:"disable; false; boolean; true is used to disable the node registry"`
+	Interval time.Duration `cfg:"interval;10s;;node status heartbeat interval"`
+	LifeTime time.Duration `cfg:"life-time;1m;;how long without a heartbeat before a node is considered dead"`
 }
 
 // TikvGC config is the config of implement tikv sdk gcwork
@@ -71,6 +270,8 @@ type Expire struct {
 	LeaderLifeTime   time.Duration `cfg:"leader-life-time;3m;;lease flush leader interval"`
 	BatchLimit       int           `cfg:"batch-limit;10;numeric;hashed expire-key count limitation per-transection"`
 	UnhashBatchLimit int           `cfg:"unhash-batch-limit;256;numeric;unhashed expire-key count limitation per-transection"`
+	NamespaceLimit   int           `cfg:"namespace-limit;0;numeric;max expired keys of one namespace per round, 0 means unlimited"`
+	LeaderNum        int           `cfg:"leader-num;1;numeric;expire leader count, each leader owns a contiguous range of the expire hash buckets"`
 }
 
 // ZT config is the config of zlist
@@ -101,19 +302,23 @@ type TikvLogger struct {
 
 // Status config is the config of exported server
 type Status struct {
-	Listen      string `cfg:"listen;0.0.0.0:7345;nonempty; listen address of http server"`
-	SSLCertFile string `cfg:"ssl-cert-file;;;status server SSL certificate file (enables SSL support)"`
-	SSLKeyFile  string `cfg:"ssl-key-file;;;status server SSL key file"`
+	Listen                    string `cfg:"listen;0.0.0.0:7345;nonempty; listen address of http server"`
+	SSLCertFile               string `cfg:"ssl-cert-file;;;status server SSL certificate file (enables SSL support)"`
+	SSLKeyFile                string `cfg:"ssl-key-file;;;status server SSL key file"`
+	MetricsNamespaceLimit     int    `cfg:"metrics-namespace-limit;0;numeric;max distinct namespaces exposed as metrics label values, the rest are aggregated as other, 0 means unlimited"`
+	MetricsNamespaceAllowlist string `cfg:"metrics-namespace-allowlist;;;comma separated namespaces always exposed individually, not counted against metrics-namespace-limit"`
 }
 
 type RateLimit struct {
 	InterfaceName       string        `cfg:"interface-name; eth0; ; the interface name to get ip and write local titan status to tikv for balancing rate limit"`
-	LimiterNamespace    string        `cfg:"limiter-namespace; sys_ratelimit;; the namespace of getting limit/balance data"`
+	LimiterNamespace    string        `cfg:"limiter-namespace; $sys.ratelimit;; the namespace of getting limit/balance data, reserved for system use"`
 	GlobalBalancePeriod time.Duration `cfg:"global-balance-period; 15s;; the period in seconds to balance rate limiting with other titan nodes"`
 	TitanStatusLifetime time.Duration `cfg:"titanstatus-life-time; 1m;; how long if a titan didn't update its status, we consider it dead"`
 	SyncSetPeriod       time.Duration `cfg:"sync-set-period; 3s;; the period in seconds to sync new limit set in tikv"`
+	NotifyCheckPeriod   time.Duration `cfg:"notify-check-period; 1s;; the period of checking the limiter config version key, a rule change applies within this delay on every node, 0 falls back to sync-set-period polling only"`
 	UsageToDivide       float64       `cfg:"usage-to-divide; 0.6;; if the qps/weighted limit < the percent, will divide change Factor to balance limit"`
 	UsageToMultiply     float64       `cfg:"usage-to-multiply; 0.9;; if the qps/weighted limit >= the percent, will multiply change Factor to balance limit"`
 	WeightChangeFactor  float64       `cfg:"weight-change-factor; 1.5;; the factor to devide/multipy in current weight"`
 	InitialPercent      float64       `cfg:"initial-percent; 0.33;; the limit is set in the percent when a commandLimiter is created"`
+	InitialLimits       string        `cfg:"initial-limits;;;comma separated bootstrap limits <qps:|rate:><namespace>@<command>=<limit> <burst>, enforced until a matching key is written to tikv"`
 }
@@ -34,9 +34,61 @@ func MockConf() *Titan {
 				SafePointLifeTime: 10 * time.Minute,
 				Concurrency:       2,
 			},
+			Reaper: Reaper{
+				Disable:        true,
+				Interval:       10 * time.Minute,
+				LeaderLifeTime: 30 * time.Minute,
+				BatchLimit:     256,
+				MetaScanLimit:  10000,
+			},
+			MemberExpire: MemberExpire{
+				Disable:        false,
+				Interval:       time.Second,
+				LeaderLifeTime: 3 * time.Minute,
+				BatchLimit:     256,
+			},
+			Rewriter: Rewriter{
+				Disable:        false,
+				Interval:       time.Second,
+				LeaderLifeTime: 3 * time.Minute,
+			},
+			DBSize: DBSize{
+				Disable:        false,
+				Interval:       time.Second,
+				LeaderLifeTime: 3 * time.Minute,
+				BatchLimit:     256,
+			},
+			ZSM: ZSetMigrate{
+				Disable:        true,
+				Interval:       time.Minute,
+				LeaderLifeTime: 3 * time.Minute,
+				BatchLimit:     16,
+				ScanLimit:      4096,
+				KeyLimit:       4096,
+			},
+			Breaker: Breaker{
+				Disable:    false,
+				Window:     10 * time.Second,
+				CoolDown:   5 * time.Second,
+				MinSamples: 20,
+				ErrorRate:  0.5,
+			},
+			ReadOnly: ReadOnly{
+				SyncPeriod: time.Second,
+			},
+			Warmup: Warmup{
+				Disable:   false,
+				ScanLimit: 16,
+			},
+			ReadRepair: ReadRepair{
+				Disable:     false,
+				SampleEvery: 1,
+				MaxScan:     4096,
+			},
 			RateLimit: RateLimit{
-				LimiterNamespace:    "sys_ratelimit",
+				LimiterNamespace:    "$sys.ratelimit",
 				SyncSetPeriod:       1 * time.Second,
+				NotifyCheckPeriod:   100 * time.Millisecond,
 				GlobalBalancePeriod: 3 * time.Second,
 				TitanStatusLifetime: 6 * time.Second,
 				UsageToDivide:       0.6,
@@ -6,7 +6,9 @@ import (
 	"sync"
 	"time"
 
+	"github.com/distributedio/titan/conf"
 	"github.com/distributedio/titan/db"
+	"github.com/distributedio/titan/server"
 )
 
 const (
@@ -35,6 +37,7 @@ type Command struct {
 type ClientContext struct {
 	DB            *db.DB
 	Authenticated bool   // Client has be authenticated
+	System        bool   // Connection of an operator or internal worker, exempt from rate limits and concurrency pools
 	Namespace     string // Namespace of database
 	RemoteAddr    string // Client remote address
 	ID            int64  // Client uniq ID
@@ -42,16 +45,82 @@ type ClientContext struct {
 	Created       time.Time
 	Updated       time.Time
 	LastCmd       string
-	SkipN         int // Skip N following commands, (-1 for skipping all commands)
+	CommandCount  int64 // commands received on this connection
+	FastPathCount int64 // of which were answered on the keepalive fast path
+	SkipN         int   // Skip N following commands, (-1 for skipping all commands)
 	Close         func() error
 
+	// CompressionThreshold is negotiated with the compress command,
+	// replies of at least this size are sent as lz4 frames, 0 means
+	// compression is off
+	CompressionThreshold int
+
+	// ErrorCodes is negotiated with the errcode command, error replies
+	// carry a machine readable TITAN:CODE prefix when set
+	ErrorCodes bool
+
 	// When client is in multi...exec block, the Txn is assigned and Multi is set to be true
 	// Before exec, all command called will be queued in Commands
 	Txn      *db.Transaction // Txn is set when client is in transaction which is triggered by watch command
 	Multi    bool
 	Commands []*Command
 
+	// ReadTxn is the read transaction shared by consecutive read-only
+	// commands when snapshot reuse is enabled, ReadTxnAt is when it was
+	// begun. See the snapshot-reuse server config
+	ReadTxn   *db.Transaction
+	ReadTxnAt time.Time
+
+	// ExplicitTxn is the transaction pinned to the connection by TXN.BEGIN,
+	// ExplicitTxnAt is when it was last used. See the explicit-txn server
+	// config
+	ExplicitTxn   *db.Transaction
+	ExplicitTxnAt time.Time
+
 	Done chan struct{}
+
+	// kickOnce guards Done against concurrent closers
+	kickOnce sync.Once
+}
+
+// SharedReadTxn returns the shared read transaction when it is younger
+// than maxAge, nil when there is none or it is due for a refresh
+func (cli *ClientContext) SharedReadTxn(maxAge time.Duration) *db.Transaction {
+	if cli.ReadTxn == nil {
+		return nil
+	}
+	if time.Since(cli.ReadTxnAt) > maxAge {
+		cli.InvalidateReadTxn()
+		return nil
+	}
+	return cli.ReadTxn
+}
+
+// InvalidateReadTxn drops the shared read transaction, the next read-only
+// command begins a fresh one
+func (cli *ClientContext) InvalidateReadTxn() {
+	if cli.ReadTxn != nil {
+		cli.ReadTxn.Rollback()
+		cli.ReadTxn = nil
+	}
+}
+
+// AbortExplicitTxn rolls back the transaction pinned by TXN.BEGIN, if any
+func (cli *ClientContext) AbortExplicitTxn() {
+	if cli.ExplicitTxn != nil {
+		cli.ExplicitTxn.Rollback()
+		cli.ExplicitTxn = nil
+	}
+}
+
+// Kick marks the connection for closure, the serve loop notices Done and
+// closes the connection. It is safe to call more than once
+func (cli *ClientContext) Kick() {
+	cli.kickOnce.Do(func() {
+		if cli.Done != nil {
+			close(cli.Done)
+		}
+	})
 }
 
 // NewClientContext new client context object ,id must be uniq
@@ -71,21 +140,94 @@ func NewClientContext(id int64, conn net.Conn) *ClientContext {
 	return cli
 }
 
+// AuthProvider validates an auth token and resolves the namespace it
+// grants access to
+type AuthProvider interface {
+	Authenticate(token string) (namespace string, err error)
+}
+
+// PauseState is the server wide client pause gate set by CLIENT PAUSE.
+// Commands wait on it until the deadline passes or CLIENT UNPAUSE lifts it,
+// in write mode only write commands are held back
+type PauseState struct {
+	mu       sync.Mutex
+	deadline time.Time
+	writes   bool
+	lifted   chan struct{}
+}
+
+// Set arms the gate for the given duration, writesOnly pauses write
+// commands only. A new pause replaces the previous one
+func (p *PauseState) Set(d time.Duration, writesOnly bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.deadline = time.Now().Add(d)
+	p.writes = writesOnly
+	if p.lifted == nil {
+		p.lifted = make(chan struct{})
+	}
+}
+
+// Clear lifts the pause and wakes every waiting command
+func (p *PauseState) Clear() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.deadline = time.Time{}
+	if p.lifted != nil {
+		close(p.lifted)
+		p.lifted = nil
+	}
+}
+
+// Wait blocks the caller while the pause applies to it, isWrite tells
+// whether the command writes
+func (p *PauseState) Wait(isWrite bool) {
+	p.mu.Lock()
+	d := time.Until(p.deadline)
+	lifted := p.lifted
+	writes := p.writes
+	p.mu.Unlock()
+	if d <= 0 || lifted == nil || (writes && !isWrite) {
+		return
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-lifted:
+	case <-timer.C:
+	}
+}
+
 // ServerContext is the runtime context of the server
 type ServerContext struct {
-	RequirePass       string
-	Store             *db.RedisStore
-	Monitors          sync.Map
-	Clients           sync.Map
-	LimitersMgr       *db.LimitersMgr
-	Pause             time.Duration // elapse to pause all clients
-	StartAt           time.Time
-	ListZipThreshold  int
-	LimitConnection   bool
-	MaxConnection     int64
-	MaxConnectionWait int64
-	ClientsNum        int64
-	Lock              sync.Mutex
+	RequirePass        string
+	AuthProvider       AuthProvider
+	Store              *db.RedisStore
+	Monitors           sync.Map
+	Clients            sync.Map
+	LimitersMgr        *db.LimitersMgr
+	ReadOnlyMgr        *db.ReadOnlyMgr
+	NamespaceReg       *db.NamespaceRegistry
+	TxnPool            *db.TxnPool
+	AcceptLimiter      *server.AcceptLimiter
+	Journal            *server.Journal
+	ClientPause        PauseState // server wide gate set by CLIENT PAUSE
+	StartAt            time.Time
+	ListZipThreshold   int
+	StrictClusterSlots bool
+	MaxPipelineBurst   int
+	MaxReplyElements   int
+	MaxReplyBytes      int
+	MaxBatchArgs       int
+	MaxRequestBytes    int
+	LimitConnection    bool
+	MaxConnection      int64
+	MaxConnectionWait  int64
+	ClientsNum         int64
+	SnapshotReuse      conf.SnapshotReuse
+	ExplicitTxn        conf.ExplicitTxn
+	EventLoop          conf.EventLoop
+	Lock               sync.Mutex
 }
 
 // Context combines the client and server context
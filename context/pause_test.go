@@ -0,0 +1,44 @@
+package context
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPauseState(t *testing.T) {
+	var p PauseState
+
+	// no pause set, Wait returns immediately
+	start := time.Now()
+	p.Wait(true)
+	assert.True(t, time.Since(start) < 50*time.Millisecond)
+
+	// in write mode reads pass through
+	p.Set(time.Minute, true)
+	start = time.Now()
+	p.Wait(false)
+	assert.True(t, time.Since(start) < 50*time.Millisecond)
+
+	// UNPAUSE wakes a blocked writer
+	done := make(chan struct{})
+	go func() {
+		p.Wait(true)
+		close(done)
+	}()
+	time.Sleep(10 * time.Millisecond)
+	p.Clear()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Clear did not wake the waiting writer")
+	}
+
+	// an expired pause does not block
+	p.Set(time.Millisecond, false)
+	time.Sleep(5 * time.Millisecond)
+	start = time.Now()
+	p.Wait(true)
+	assert.True(t, time.Since(start) < 50*time.Millisecond)
+}
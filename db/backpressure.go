@@ -0,0 +1,115 @@
+package db
+
+import (
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/distributedio/titan/conf"
+	"github.com/distributedio/titan/metrics"
+	"go.uber.org/zap"
+)
+
+const (
+	// backpressureWindowSize commit samples back the rolling p99
+	backpressureWindowSize = 1024
+	// backpressureEvalEvery is how many commits pass between two p99
+	// evaluations
+	backpressureEvalEvery = 64
+)
+
+// Backpressure sheds write load when tikv commits slow down. Every commit
+// latency feeds a sliding window, once the rolling p99 crosses the
+// threshold the dispatcher delays writes of unprotected namespaces by a
+// fixed amount instead of queueing them unboundedly, and lifts the delay
+// as soon as the p99 recovers. The evaluation is commit driven, so the
+// state always reflects the most recent commits
+type Backpressure struct {
+	cfg       *conf.Backpressure
+	protected map[string]struct{}
+
+	mu      sync.Mutex
+	samples []time.Duration // ring of the most recent commit latencies
+	next    int
+	count   int64
+
+	active int32
+	shed   int64
+}
+
+// globalBackpressure is set once at Open before the listener starts
+var globalBackpressure *Backpressure
+
+// EnableBackpressure installs the node wide write backpressure
+func EnableBackpressure(cfg *conf.Backpressure) {
+	bp := &Backpressure{
+		cfg:       cfg,
+		protected: make(map[string]struct{}),
+		samples:   make([]time.Duration, 0, backpressureWindowSize),
+	}
+	for _, ns := range strings.Split(cfg.Protected, ",") {
+		if ns = strings.TrimSpace(ns); ns != "" {
+			bp.protected[ns] = struct{}{}
+		}
+	}
+	globalBackpressure = bp
+}
+
+// recordCommitLatency feeds one commit latency into the window and
+// re-evaluates the rolling p99 once per evaluation interval
+func recordCommitLatency(cost time.Duration) {
+	bp := globalBackpressure
+	if bp == nil {
+		return
+	}
+	bp.observe(cost)
+}
+
+func (bp *Backpressure) observe(cost time.Duration) {
+	bp.mu.Lock()
+	defer bp.mu.Unlock()
+	if len(bp.samples) < backpressureWindowSize {
+		bp.samples = append(bp.samples, cost)
+	} else {
+		bp.samples[bp.next] = cost
+		bp.next = (bp.next + 1) % backpressureWindowSize
+	}
+	bp.count++
+	if bp.count%backpressureEvalEvery != 0 {
+		return
+	}
+	sorted := append([]time.Duration(nil), bp.samples...)
+	sort.Slice(sorted, func(a, b int) bool { return sorted[a] < sorted[b] })
+	p99 := sorted[len(sorted)*99/100]
+	if p99 > bp.cfg.Threshold {
+		if atomic.SwapInt32(&bp.active, 1) == 0 {
+			metrics.GetMetrics().BackpressureActiveGauge.Set(1)
+			zap.L().Warn("commit latency backpressure engaged",
+				zap.Duration("p99", p99),
+				zap.Duration("threshold", bp.cfg.Threshold),
+				zap.Duration("delay", bp.cfg.Delay))
+		}
+		return
+	}
+	if atomic.SwapInt32(&bp.active, 0) == 1 {
+		metrics.GetMetrics().BackpressureActiveGauge.Set(0)
+		zap.L().Info("commit latency backpressure lifted", zap.Duration("p99", p99))
+	}
+}
+
+// BackpressureWait delays the calling write while load is being shed,
+// protected namespaces pass through untouched
+func BackpressureWait(namespace string) {
+	bp := globalBackpressure
+	if bp == nil || atomic.LoadInt32(&bp.active) == 0 {
+		return
+	}
+	if _, ok := bp.protected[namespace]; ok {
+		return
+	}
+	atomic.AddInt64(&bp.shed, 1)
+	metrics.GetMetrics().BackpressureShedCounterVec.WithLabelValues(metrics.NamespaceLabel(namespace)).Inc()
+	time.Sleep(bp.cfg.Delay)
+}
@@ -0,0 +1,49 @@
+package db
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/distributedio/titan/conf"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBackpressure(t *testing.T) {
+	EnableBackpressure(&conf.Backpressure{
+		Threshold: 10 * time.Millisecond,
+		Delay:     time.Millisecond,
+		Protected: "sys, batch",
+	})
+	defer func() { globalBackpressure = nil }()
+	bp := globalBackpressure
+
+	// fast commits keep the shedding off
+	for i := 0; i < backpressureEvalEvery; i++ {
+		recordCommitLatency(time.Millisecond)
+	}
+	assert.Equal(t, int32(0), atomic.LoadInt32(&bp.active))
+	BackpressureWait("default")
+	assert.Equal(t, int64(0), atomic.LoadInt64(&bp.shed))
+
+	// a run of slow commits pushes the p99 over the threshold
+	for i := 0; i < backpressureEvalEvery; i++ {
+		recordCommitLatency(100 * time.Millisecond)
+	}
+	assert.Equal(t, int32(1), atomic.LoadInt32(&bp.active))
+	BackpressureWait("default")
+	assert.Equal(t, int64(1), atomic.LoadInt64(&bp.shed))
+
+	// protected namespaces are never delayed
+	BackpressureWait("sys")
+	BackpressureWait("batch")
+	assert.Equal(t, int64(1), atomic.LoadInt64(&bp.shed))
+
+	// enough fast commits flush the slow ones out of the window
+	for i := 0; i < backpressureWindowSize; i++ {
+		recordCommitLatency(time.Millisecond)
+	}
+	assert.Equal(t, int32(0), atomic.LoadInt32(&bp.active))
+	BackpressureWait("default")
+	assert.Equal(t, int64(1), atomic.LoadInt64(&bp.shed))
+}
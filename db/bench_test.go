@@ -0,0 +1,151 @@
+package db
+
+import (
+	"context"
+	"strconv"
+	"testing"
+)
+
+// benchCall runs f in a fresh transaction and commits it, failing the
+// benchmark on any error
+func benchCall(b *testing.B, d *DB, f func(txn *Transaction) error) {
+	txn, err := d.Begin()
+	if err != nil {
+		b.Fatal(err)
+	}
+	if err := f(txn); err != nil {
+		txn.Rollback()
+		b.Fatal(err)
+	}
+	if err := txn.Commit(context.TODO()); err != nil {
+		b.Fatal(err)
+	}
+}
+
+func BenchmarkStringSet(b *testing.B) {
+	d := MockDB()
+	val := []byte("benchvalue")
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		key := []byte("bench:string:" + strconv.Itoa(i&1023))
+		benchCall(b, d, func(txn *Transaction) error {
+			s, err := GetString(txn, key)
+			if err != nil {
+				return err
+			}
+			return s.Set(val)
+		})
+	}
+}
+
+func BenchmarkStringGet(b *testing.B) {
+	d := MockDB()
+	key := []byte("bench:string:get")
+	benchCall(b, d, func(txn *Transaction) error {
+		s, err := GetString(txn, key)
+		if err != nil {
+			return err
+		}
+		return s.Set([]byte("benchvalue"))
+	})
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		benchCall(b, d, func(txn *Transaction) error {
+			s, err := GetString(txn, key)
+			if err != nil {
+				return err
+			}
+			_, err = s.Get()
+			return err
+		})
+	}
+}
+
+func BenchmarkHashHSet(b *testing.B) {
+	d := MockDB()
+	key := []byte("bench:hash")
+	val := []byte("benchvalue")
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		field := []byte("field:" + strconv.Itoa(i&1023))
+		benchCall(b, d, func(txn *Transaction) error {
+			hash, err := GetHash(txn, key)
+			if err != nil {
+				return err
+			}
+			_, err = hash.HSet(field, val)
+			return err
+		})
+	}
+}
+
+// benchZSet fills a zset with n members scored by their index
+func benchZSet(b *testing.B, d *DB, key []byte, n int) {
+	benchCall(b, d, func(txn *Transaction) error {
+		zset, err := GetZSet(txn, key)
+		if err != nil {
+			return err
+		}
+		members := make([][]byte, n)
+		scores := make([]float64, n)
+		for i := 0; i < n; i++ {
+			members[i] = []byte("member:" + strconv.Itoa(i))
+			scores[i] = float64(i)
+		}
+		_, err = zset.ZAdd(members, scores)
+		return err
+	})
+}
+
+func BenchmarkZSetZRange(b *testing.B) {
+	d := MockDB()
+	key := []byte("bench:zset:zrange")
+	benchZSet(b, d, key, 256)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		benchCall(b, d, func(txn *Transaction) error {
+			zset, err := GetZSet(txn, key)
+			if err != nil {
+				return err
+			}
+			_, err = zset.ZAnyOrderRange(0, -1, true, true)
+			return err
+		})
+	}
+}
+
+func BenchmarkZSetZRangeByScore(b *testing.B) {
+	d := MockDB()
+	key := []byte("bench:zset:zrangebyscore")
+	benchZSet(b, d, key, 256)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		benchCall(b, d, func(txn *Transaction) error {
+			zset, err := GetZSet(txn, key)
+			if err != nil {
+				return err
+			}
+			_, err = zset.ZAnyOrderRangeByScore(0, true, 255, true, true, 0, -1, true)
+			return err
+		})
+	}
+}
+
+func BenchmarkZSetZAdd(b *testing.B) {
+	d := MockDB()
+	key := []byte("bench:zset")
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		member := []byte("member:" + strconv.Itoa(i&1023))
+		benchCall(b, d, func(txn *Transaction) error {
+			zset, err := GetZSet(txn, key)
+			if err != nil {
+				return err
+			}
+			_, err = zset.ZAdd([][]byte{member}, []float64{float64(i)})
+			return err
+		})
+	}
+}
@@ -0,0 +1,341 @@
+package db
+
+import (
+	"math/bits"
+
+	"github.com/pingcap/tidb/kv"
+)
+
+// bitmapChunkSize is the byte size of one bitmap chunk value, bits beyond
+// it land in further data keys so an offset never grows a single tikv value
+const bitmapChunkSize = 64 * 1024
+
+// BitmapMeta carries the logical byte length of the bitmap besides the
+// object fields
+type BitmapMeta struct {
+	Object
+	Length int64 // max written byte index + 1
+}
+
+// EncodeBitmapMeta encodes meta data into byte slice
+func EncodeBitmapMeta(meta *BitmapMeta) []byte {
+	b := EncodeObject(&meta.Object)
+	b = append(b, EncodeInt64(meta.Length)...)
+	return b
+}
+
+// DecodeBitmapMeta decode meta data into meta field
+func DecodeBitmapMeta(b []byte) (*BitmapMeta, error) {
+	if len(b[ObjectEncodingLength:]) != 8 {
+		return nil, ErrInvalidLength
+	}
+	obj, err := DecodeObject(b)
+	if err != nil {
+		return nil, err
+	}
+	meta := &BitmapMeta{Object: *obj}
+	meta.Length = DecodeInt64(b[ObjectEncodingLength:])
+	return meta, nil
+}
+
+// Bitmap implements a chunked bitmap over the data space
+type Bitmap struct {
+	meta   *BitmapMeta
+	key    []byte
+	exists bool
+	txn    *Transaction
+}
+
+// GetBitmap returns a bitmap object, create new one if nonexists
+func GetBitmap(txn *Transaction, key []byte) (*Bitmap, error) {
+	bm := newBitmap(txn, key)
+	mkey := MetaKey(txn.db, key)
+	meta, err := txn.t.Get(mkey)
+	if err != nil {
+		if IsErrNotFound(err) {
+			return bm, nil
+		}
+		return nil, err
+	}
+	// check the type before the meta codec, a foreign meta has a different
+	// trailing layout
+	objType, expireAt, err := peekMeta(meta)
+	if err != nil {
+		return nil, err
+	}
+	if expireAt != 0 && expireAt <= Now() {
+		if obj, err := DecodeObject(meta); err == nil {
+			tryLazyExpire(txn.db, key, obj)
+		}
+		return bm, nil
+	}
+	if objType != ObjectBitmap {
+		return nil, ErrTypeMismatch
+	}
+	bmeta, err := DecodeBitmapMeta(meta)
+	if err != nil {
+		return nil, err
+	}
+	bm.meta = bmeta
+	bm.exists = true
+	return bm, nil
+}
+
+// newBitmap creates a bitmap object
+func newBitmap(txn *Transaction, key []byte) *Bitmap {
+	now := Now()
+	return &Bitmap{
+		txn: txn,
+		key: key,
+		meta: &BitmapMeta{
+			Object: Object{
+				ID:        UUID(),
+				CreatedAt: now,
+				UpdatedAt: now,
+				ExpireAt:  0,
+				Type:      ObjectBitmap,
+				Encoding:  ObjectEncodingRaw,
+			},
+		},
+	}
+}
+
+// Exists reports whether the bitmap exists
+func (bm *Bitmap) Exists() bool {
+	return bm.exists
+}
+
+// Length returns the logical byte length of the bitmap
+func (bm *Bitmap) Length() int64 {
+	return bm.meta.Length
+}
+
+// bitmapChunkKey addresses the chunk holding byte idx*bitmapChunkSize, the
+// memcomparable index keeps chunks in offset order under the data prefix
+func bitmapChunkKey(dkey []byte, idx int64) []byte {
+	var ckey []byte
+	ckey = append(ckey, dkey...)
+	ckey = append(ckey, ':')
+	return append(ckey, EncodeInt64(idx)...)
+}
+
+func (bm *Bitmap) updateMeta() error {
+	bm.meta.UpdatedAt = Now()
+	return bm.txn.t.Set(MetaKey(bm.txn.db, bm.key), EncodeBitmapMeta(bm.meta))
+}
+
+// SetBit sets or clears the bit at offset and returns the old bit. Clearing
+// a bit in an unwritten region does not materialize a chunk
+func (bm *Bitmap) SetBit(offset int, on int) (int, error) {
+	byteIdx := int64(offset >> 3)
+	chunk := byteIdx / bitmapChunkSize
+	pos := int(byteIdx % bitmapChunkSize)
+	mask := byte(1 << uint(7-offset&7))
+
+	dkey := DataKey(bm.txn.db, bm.meta.ID)
+	ckey := bitmapChunkKey(dkey, chunk)
+	var val []byte
+	old, err := bm.txn.t.Get(ckey)
+	if err != nil {
+		if !IsErrNotFound(err) {
+			return 0, err
+		}
+		if on == 0 {
+			// nothing to clear, keep the region sparse
+			return 0, nil
+		}
+	} else {
+		val = append(val, old...)
+	}
+
+	if len(val) <= pos {
+		val = append(val, make([]byte, pos+1-len(val))...)
+	}
+	oldBit := 0
+	if val[pos]&mask != 0 {
+		oldBit = 1
+	}
+	if on == 1 {
+		val[pos] |= mask
+	} else {
+		val[pos] &^= mask
+	}
+	if err := bm.txn.t.Set(ckey, val); err != nil {
+		return 0, err
+	}
+
+	if byteIdx+1 > bm.meta.Length {
+		bm.meta.Length = byteIdx + 1
+	}
+	if err := bm.updateMeta(); err != nil {
+		return 0, err
+	}
+	bm.exists = true
+	return oldBit, nil
+}
+
+// GetBit returns the bit at offset, unwritten regions read as zero
+func (bm *Bitmap) GetBit(offset int) (int, error) {
+	if !bm.exists {
+		return 0, nil
+	}
+	byteIdx := int64(offset >> 3)
+	if byteIdx >= bm.meta.Length {
+		return 0, nil
+	}
+	chunk := byteIdx / bitmapChunkSize
+	pos := int(byteIdx % bitmapChunkSize)
+
+	dkey := DataKey(bm.txn.db, bm.meta.ID)
+	val, err := bm.txn.t.Get(bitmapChunkKey(dkey, chunk))
+	if err != nil {
+		if IsErrNotFound(err) {
+			return 0, nil
+		}
+		return 0, err
+	}
+	if pos >= len(val) {
+		return 0, nil
+	}
+	if val[pos]&(1<<uint(7-offset&7)) != 0 {
+		return 1, nil
+	}
+	return 0, nil
+}
+
+// normalizeRange resolves negative byte indices against the logical length
+func (bm *Bitmap) normalizeRange(begin, end int) (int64, int64) {
+	length := bm.meta.Length
+	b, e := int64(begin), int64(end)
+	if b < 0 {
+		b += length
+	}
+	if e < 0 {
+		e += length
+	}
+	if b < 0 {
+		b = 0
+	}
+	if e >= length {
+		e = length - 1
+	}
+	return b, e
+}
+
+// walkChunks visits the chunks overlapping the byte range [begin, end] in
+// offset order, f gets the chunk index and value and reports whether to
+// stop. Chunks are fetched lazily so a count over a huge bitmap streams
+// instead of loading it whole
+func (bm *Bitmap) walkChunks(begin, end int64, f func(idx int64, val []byte) bool) error {
+	dkey := DataKey(bm.txn.db, bm.meta.ID)
+	lower := bitmapChunkKey(dkey, begin/bitmapChunkSize)
+	upper := kv.Key(bitmapChunkKey(dkey, end/bitmapChunkSize)).Next()
+	iter, err := bm.txn.t.Iter(lower, upper)
+	if err != nil {
+		return err
+	}
+	defer iter.Close()
+	prefixLen := len(dkey) + 1
+	for ; iter.Valid(); err = iter.Next() {
+		if err != nil {
+			return err
+		}
+		key := iter.Key()
+		if len(key) != prefixLen+8 {
+			continue
+		}
+		idx := DecodeInt64(key[prefixLen:])
+		if f(idx, iter.Value()) {
+			return nil
+		}
+	}
+	return nil
+}
+
+// BitCount counts the set bits in the byte range [begin, end], negative
+// indices count from the end
+func (bm *Bitmap) BitCount(begin, end int) (int, error) {
+	if !bm.exists {
+		return 0, nil
+	}
+	b, e := bm.normalizeRange(begin, end)
+	if b > e {
+		return 0, nil
+	}
+	count := 0
+	err := bm.walkChunks(b, e, func(idx int64, val []byte) bool {
+		chunkStart := idx * bitmapChunkSize
+		for i, c := range val {
+			byteIdx := chunkStart + int64(i)
+			if byteIdx < b || byteIdx > e {
+				continue
+			}
+			count += bits.OnesCount8(c)
+		}
+		return false
+	})
+	if err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+// BitPos returns the offset of the first bit with the given value in the
+// byte range [begin, end], unwritten gaps read as zero bytes. It returns
+// -1 when no such bit exists
+func (bm *Bitmap) BitPos(bit, begin, end int) (int64, error) {
+	if !bm.exists {
+		if bit == 0 {
+			return 0, nil
+		}
+		return -1, nil
+	}
+	b, e := bm.normalizeRange(begin, end)
+	if b > e {
+		return -1, nil
+	}
+	pos := int64(-1)
+	cursor := b // next byte index to account for
+	err := bm.walkChunks(b, e, func(idx int64, val []byte) bool {
+		chunkStart := idx * bitmapChunkSize
+		if bit == 0 && cursor < chunkStart {
+			// the gap before this chunk reads as zero bytes
+			pos = cursor * 8
+			return true
+		}
+		for i, c := range val {
+			byteIdx := chunkStart + int64(i)
+			if byteIdx < b {
+				continue
+			}
+			if byteIdx > e {
+				return true
+			}
+			if bit == 0 && cursor < byteIdx {
+				pos = cursor * 8
+				return true
+			}
+			cursor = byteIdx + 1
+			if bit == 1 {
+				if c != 0 {
+					pos = byteIdx*8 + int64(bits.LeadingZeros8(c))
+					return true
+				}
+				continue
+			}
+			if c != 0xff {
+				pos = byteIdx*8 + int64(bits.LeadingZeros8(^c))
+				return true
+			}
+		}
+		return false
+	})
+	if err != nil {
+		return 0, err
+	}
+	if pos == -1 && bit == 0 && cursor <= e {
+		// the tail of the range is an unwritten gap
+		pos = cursor * 8
+	}
+	return pos, nil
+}
@@ -0,0 +1,95 @@
+package db
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func bitmapCall(t *testing.T, d *DB, f func(bm *Bitmap)) {
+	txn, err := d.Begin()
+	assert.NoError(t, err)
+	bm, err := GetBitmap(txn, []byte("bitmap-key"))
+	assert.NoError(t, err)
+	f(bm)
+	assert.NoError(t, txn.Commit(context.TODO()))
+}
+
+func TestBitmapSetGet(t *testing.T) {
+	d := MockDB()
+	// offsets in the first chunk and far into the third chunk
+	far := bitmapChunkSize*8*2 + 13
+	bitmapCall(t, d, func(bm *Bitmap) {
+		old, err := bm.SetBit(7, 1)
+		assert.NoError(t, err)
+		assert.Equal(t, 0, old)
+		old, err = bm.SetBit(far, 1)
+		assert.NoError(t, err)
+		assert.Equal(t, 0, old)
+	})
+	bitmapCall(t, d, func(bm *Bitmap) {
+		assert.True(t, bm.Exists())
+		assert.EqualValues(t, far/8+1, bm.Length())
+		v, err := bm.GetBit(7)
+		assert.NoError(t, err)
+		assert.Equal(t, 1, v)
+		v, err = bm.GetBit(8)
+		assert.NoError(t, err)
+		assert.Equal(t, 0, v)
+		// the gap between the chunks reads as zero
+		v, err = bm.GetBit(bitmapChunkSize * 8)
+		assert.NoError(t, err)
+		assert.Equal(t, 0, v)
+		v, err = bm.GetBit(far)
+		assert.NoError(t, err)
+		assert.Equal(t, 1, v)
+	})
+	bitmapCall(t, d, func(bm *Bitmap) {
+		old, err := bm.SetBit(7, 0)
+		assert.NoError(t, err)
+		assert.Equal(t, 1, old)
+	})
+}
+
+func TestBitmapCountAndPos(t *testing.T) {
+	d := MockDB()
+	far := bitmapChunkSize*8 + 9 // second bit of the second byte in chunk 1
+	bitmapCall(t, d, func(bm *Bitmap) {
+		_, err := bm.SetBit(0, 1)
+		assert.NoError(t, err)
+		_, err = bm.SetBit(9, 1)
+		assert.NoError(t, err)
+		_, err = bm.SetBit(far, 1)
+		assert.NoError(t, err)
+	})
+	bitmapCall(t, d, func(bm *Bitmap) {
+		n, err := bm.BitCount(0, -1)
+		assert.NoError(t, err)
+		assert.Equal(t, 3, n)
+		n, err = bm.BitCount(1, 1)
+		assert.NoError(t, err)
+		assert.Equal(t, 1, n)
+		n, err = bm.BitCount(2, bitmapChunkSize+1)
+		assert.NoError(t, err)
+		assert.Equal(t, 1, n)
+
+		pos, err := bm.BitPos(1, 0, -1)
+		assert.NoError(t, err)
+		assert.EqualValues(t, 0, pos)
+		pos, err = bm.BitPos(1, 1, -1)
+		assert.NoError(t, err)
+		assert.EqualValues(t, 9, pos)
+		pos, err = bm.BitPos(1, 2, -1)
+		assert.NoError(t, err)
+		assert.EqualValues(t, far, pos)
+		// the first zero bit sits right after the first set bit
+		pos, err = bm.BitPos(0, 0, -1)
+		assert.NoError(t, err)
+		assert.EqualValues(t, 1, pos)
+		// a range fully inside an unwritten gap reads zeros
+		pos, err = bm.BitPos(0, 16, 31)
+		assert.NoError(t, err)
+		assert.EqualValues(t, 128, pos)
+	})
+}
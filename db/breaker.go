@@ -0,0 +1,118 @@
+package db
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/distributedio/titan/conf"
+	"github.com/distributedio/titan/metrics"
+	"go.uber.org/zap"
+)
+
+// Breaker modes, auto follows the measured error rate while the force
+// modes are operator overrides set through DEBUG BREAKER
+const (
+	BreakerAuto int32 = iota
+	BreakerForceOpen
+	BreakerForceClosed
+)
+
+// Breaker is the node wide circuit breaker of the tikv access. Begin and
+// commit outcomes are sampled in a sliding window, once the error rate of
+// a window with enough samples passes the threshold the breaker opens for
+// a cool-down and the dispatcher fails transactional commands fast with
+// TRYAGAIN instead of letting every client hang until the tikv timeouts
+type Breaker struct {
+	cfg  *conf.Breaker
+	mode int32
+
+	mu          sync.Mutex
+	windowStart time.Time
+	total       int64
+	errs        int64
+	openUntil   time.Time
+}
+
+// globalBreaker is set once at Open before the listener starts
+var globalBreaker *Breaker
+
+// EnableBreaker installs the node wide breaker
+func EnableBreaker(cfg *conf.Breaker) {
+	globalBreaker = NewBreaker(cfg)
+}
+
+// GetBreaker returns the node wide breaker, nil when disabled
+func GetBreaker() *Breaker {
+	return globalBreaker
+}
+
+// NewBreaker creates a breaker in auto mode with an empty window
+func NewBreaker(cfg *conf.Breaker) *Breaker {
+	return &Breaker{cfg: cfg, windowStart: time.Now()}
+}
+
+// observe counts the outcome of a begin or commit and opens the breaker
+// when the error rate of the current window passes the threshold
+func (b *Breaker) observe(err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	now := time.Now()
+	if now.Sub(b.windowStart) > b.cfg.Window {
+		b.windowStart = now
+		b.total, b.errs = 0, 0
+	}
+	b.total++
+	if err == nil {
+		if !now.Before(b.openUntil) {
+			metrics.GetMetrics().BreakerOpenGauge.Set(0)
+		}
+		return
+	}
+	b.errs++
+	if b.total < b.cfg.MinSamples || float64(b.errs)/float64(b.total) < b.cfg.ErrorRate {
+		return
+	}
+	if now.Before(b.openUntil) {
+		return
+	}
+	b.openUntil = now.Add(b.cfg.CoolDown)
+	metrics.GetMetrics().BreakerOpenGauge.Set(1)
+	zap.L().Warn("[Breaker] opened",
+		zap.Int64("errors", b.errs),
+		zap.Int64("samples", b.total),
+		zap.Duration("cooldown", b.cfg.CoolDown))
+}
+
+// Allow reports whether transactional commands may proceed
+func (b *Breaker) Allow() bool {
+	switch atomic.LoadInt32(&b.mode) {
+	case BreakerForceOpen:
+		return false
+	case BreakerForceClosed:
+		return true
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return !time.Now().Before(b.openUntil)
+}
+
+// SetMode applies an operator override, BreakerAuto returns control to
+// the measured error rate
+func (b *Breaker) SetMode(mode int32) {
+	atomic.StoreInt32(&b.mode, mode)
+}
+
+// State describes the breaker for DEBUG BREAKER STATUS
+func (b *Breaker) State() string {
+	switch atomic.LoadInt32(&b.mode) {
+	case BreakerForceOpen:
+		return "force-open"
+	case BreakerForceClosed:
+		return "force-closed"
+	}
+	if b.Allow() {
+		return "closed"
+	}
+	return "open"
+}
@@ -0,0 +1,43 @@
+package db
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/distributedio/titan/conf"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBreaker(t *testing.T) {
+	cfg := &conf.Breaker{
+		Window:     time.Minute,
+		CoolDown:   time.Minute,
+		MinSamples: 4,
+		ErrorRate:  0.5,
+	}
+	b := NewBreaker(cfg)
+	assert.True(t, b.Allow())
+	assert.Equal(t, "closed", b.State())
+
+	// below min-samples the rate is not evaluated
+	for i := 0; i < 3; i++ {
+		b.observe(errors.New("tikv timeout"))
+	}
+	assert.True(t, b.Allow())
+
+	b.observe(errors.New("tikv timeout"))
+	assert.False(t, b.Allow())
+	assert.Equal(t, "open", b.State())
+
+	b.SetMode(BreakerForceClosed)
+	assert.True(t, b.Allow())
+	assert.Equal(t, "force-closed", b.State())
+
+	b.SetMode(BreakerForceOpen)
+	assert.False(t, b.Allow())
+	assert.Equal(t, "force-open", b.State())
+
+	b.SetMode(BreakerAuto)
+	assert.False(t, b.Allow())
+}
@@ -17,6 +17,28 @@ func EncodeObject(obj *Object) []byte {
 	return b
 }
 
+// peekMeta reads the Type and ExpireAt fields straight out of a raw meta
+// value. Loaders answer the WRONGTYPE question from these two fields alone,
+// so a live meta of another type, e.g. a multi-MB inline string value hit
+// by ZADD, is rejected before being decoded
+func peekMeta(meta []byte) (ObjectType, int64, error) {
+	if len(meta) < ObjectEncodingLength {
+		return 0, 0, ErrInvalidLength
+	}
+	return ObjectType(meta[40]), int64(binary.BigEndian.Uint64(meta[32:])), nil
+}
+
+// patchMetaExpireAt rewrites only the ExpireAt field of a raw meta value,
+// the type specific trailing fields stay byte identical. TTL heavy
+// workloads on big collections update a timeout without decoding and
+// re-encoding the whole meta
+func patchMetaExpireAt(meta []byte, at int64) []byte {
+	patched := make([]byte, len(meta))
+	copy(patched, meta)
+	binary.BigEndian.PutUint64(patched[32:], uint64(at))
+	return patched
+}
+
 // DecodeObject decode the object from binary
 func DecodeObject(b []byte) (obj *Object, err error) {
 	if len(b) < ObjectEncodingLength {
@@ -34,6 +56,17 @@ func DecodeObject(b []byte) (obj *Object, err error) {
 	return obj, nil
 }
 
+// decodeObjectHeader reads only the type and expire fields out of a raw
+// meta value, skipping the allocations of a full DecodeObject. Type
+// filtered scans decode the header of every meta in range, this keeps
+// them cheap
+func decodeObjectHeader(b []byte) (ObjectType, int64, error) {
+	if len(b) < ObjectEncodingLength {
+		return 0, 0, ErrInvalidLength
+	}
+	return ObjectType(b[40]), int64(binary.BigEndian.Uint64(b[32:])), nil
+}
+
 // EncodeInt64  encode the int64 object to binary
 func EncodeInt64(v int64) []byte {
 	var buf bytes.Buffer
@@ -30,6 +30,68 @@ func TestCodecObject(t *testing.T) {
 	}
 }
 
+func TestPeekMeta(t *testing.T) {
+	now := Now()
+	obj := &Object{
+		ID:        []byte("1234567890123456"),
+		CreatedAt: now,
+		UpdatedAt: now,
+		ExpireAt:  now + 1000,
+		Type:      ObjectHash,
+		Encoding:  ObjectEncodingHT,
+	}
+	// a string meta carries its value inline after the object header
+	meta := append(EncodeObject(obj), []byte("inline value")...)
+
+	objType, expireAt, err := peekMeta(meta)
+	if err != nil {
+		t.Fatalf("peek meta get error: %s", err)
+	}
+	if objType != obj.Type {
+		t.Fatalf("peeked type want=%s, got=%s", obj.Type, objType)
+	}
+	if expireAt != obj.ExpireAt {
+		t.Fatalf("peeked ExpireAt want=%d, got=%d", obj.ExpireAt, expireAt)
+	}
+	if _, _, err := peekMeta(meta[:ObjectEncodingLength-1]); err != ErrInvalidLength {
+		t.Fatalf("short meta want=%v, got=%v", ErrInvalidLength, err)
+	}
+}
+
+func TestPatchMetaExpireAt(t *testing.T) {
+	now := Now()
+	obj := &Object{
+		ID:        []byte("1234567890123456"),
+		CreatedAt: now,
+		UpdatedAt: now,
+		ExpireAt:  now,
+		Type:      ObjectZSet,
+		Encoding:  ObjectEncodingHT,
+	}
+	// a collection meta carries trailing fields after the object header
+	meta := append(EncodeObject(obj), []byte{0, 0, 0, 0, 0, 0, 0, 42, 1}...)
+
+	at := now + 1000
+	patched := patchMetaExpireAt(meta, at)
+	got, err := DecodeObject(patched)
+	if err != nil {
+		t.Fatalf("decode patched meta get error: %s", err)
+	}
+	if got.ExpireAt != at {
+		t.Fatalf("ExpireAt not patched want=%d, got=%d", at, got.ExpireAt)
+	}
+	obj.ExpireAt = at
+	if !reflect.DeepEqual(got, obj) {
+		t.Fatalf("patched object differs want=%v, got=%v", obj, got)
+	}
+	if !bytes.Equal(patched[ObjectEncodingLength:], meta[ObjectEncodingLength:]) {
+		t.Fatal("trailing meta fields were touched")
+	}
+	if got, _ := DecodeObject(meta); got.ExpireAt != now {
+		t.Fatal("the original meta was modified in place")
+	}
+}
+
 func TestCodecEncodeInt64(t *testing.T) {
 	values := []int64{math.MinInt64, -1, 0, 1, math.MaxInt64}
 	for i := 0; i < len(values)-1; i++ {
@@ -0,0 +1,143 @@
+package db
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"time"
+
+	"github.com/pingcap/tidb/kv"
+	"go.uber.org/zap"
+)
+
+// ErrCompactRace is returned when the meta of a key changes while the key
+// is being compacted
+var ErrCompactRace = errors.New("key changed during compaction")
+
+// compactBatchLimit is the data key count copied per transaction
+const compactBatchLimit = 256
+
+// CompactKey rewrites the data range of a collection under a fresh object
+// id in batches and swaps the meta atomically at the end, it returns the
+// number of data keys copied. Collections keep their object id forever, so
+// after heavy churn the range is mostly tombstones and every scan pays for
+// them, a compacted key starts over with a dense range while the old one
+// is queued for gc. The swap aborts with ErrCompactRace when the meta
+// changes underneath, compaction is meant for keys that are not being
+// written at that moment
+func CompactKey(d *DB, key []byte) (int64, error) {
+	start := time.Now()
+	mkey := MetaKey(d, key)
+	newID := UUID()
+	var (
+		metaVal []byte
+		oldID   []byte
+		cursor  []byte
+		copied  int64
+	)
+	for {
+		txn, err := d.Begin()
+		if err != nil {
+			return copied, err
+		}
+		val, err := txn.t.Get(mkey)
+		if err != nil {
+			txn.Rollback()
+			if IsErrNotFound(err) {
+				return copied, ErrKeyNotFound
+			}
+			return copied, err
+		}
+		if metaVal == nil {
+			obj, err := DecodeObject(val)
+			if err != nil {
+				txn.Rollback()
+				return copied, err
+			}
+			if IsExpired(obj, Now()) {
+				txn.Rollback()
+				return copied, ErrKeyNotFound
+			}
+			if obj.Type == ObjectString {
+				txn.Rollback()
+				return copied, ErrTypeMismatch
+			}
+			metaVal = append([]byte{}, val...)
+			oldID = obj.ID
+		} else if !bytes.Equal(val, metaVal) {
+			txn.Rollback()
+			return copied, ErrCompactRace
+		}
+
+		oldPrefix := DataKey(d, oldID)
+		newPrefix := DataKey(d, newID)
+		seek := oldPrefix
+		if cursor != nil {
+			seek = cursor
+		}
+		iter, err := txn.t.Iter(seek, kv.Key(oldPrefix).PrefixNext())
+		if err != nil {
+			txn.Rollback()
+			return copied, err
+		}
+		count := 0
+		for ; iter.Valid() && iter.Key().HasPrefix(oldPrefix) && count < compactBatchLimit; err = iter.Next() {
+			if err != nil {
+				iter.Close()
+				txn.Rollback()
+				return copied, err
+			}
+			nkey := append(append([]byte{}, newPrefix...), iter.Key()[len(oldPrefix):]...)
+			if err := txn.t.Set(nkey, iter.Value()); err != nil {
+				iter.Close()
+				txn.Rollback()
+				return copied, err
+			}
+			count++
+			copied++
+		}
+		more := iter.Valid() && iter.Key().HasPrefix(oldPrefix)
+		if more {
+			cursor = append([]byte{}, iter.Key()...)
+		}
+		iter.Close()
+
+		if !more {
+			// swap the meta to the fresh id, move the expire index along
+			// and queue the old range for gc, all in this last transaction
+			swapped := append([]byte{}, metaVal...)
+			copy(swapped[:16], newID)
+			binary.BigEndian.PutUint64(swapped[24:], uint64(Now()))
+			if err := txn.t.Set(mkey, swapped); err != nil {
+				txn.Rollback()
+				return copied, err
+			}
+			obj, _ := DecodeObject(metaVal)
+			if obj.ExpireAt != 0 {
+				if err := unExpireAt(txn.t, mkey, obj.ExpireAt); err != nil {
+					txn.Rollback()
+					return copied, err
+				}
+				if err := expireAt(txn.t, mkey, newID, obj.Type, 0, obj.ExpireAt); err != nil {
+					txn.Rollback()
+					return copied, err
+				}
+			}
+			if err := gc(txn.t, oldPrefix); err != nil {
+				txn.Rollback()
+				return copied, err
+			}
+		}
+		if err := txn.Commit(context.Background()); err != nil {
+			return copied, err
+		}
+		if !more {
+			zap.L().Info("[Compact] key compacted",
+				zap.ByteString("key", key),
+				zap.Int64("copied", copied),
+				zap.Duration("cost", time.Since(start)))
+			return copied, nil
+		}
+	}
+}
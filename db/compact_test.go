@@ -0,0 +1,57 @@
+package db
+
+import (
+	"context"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCompactKey(t *testing.T) {
+	db := MockDB()
+	key := []byte("TestCompactKey")
+
+	var members [][]byte
+	var scores []float64
+	for i := 0; i < compactBatchLimit+10; i++ {
+		members = append(members, []byte("m"+strconv.Itoa(i)))
+		scores = append(scores, float64(i))
+	}
+	txn, err := db.Begin()
+	assert.NoError(t, err)
+	zset, err := GetZSet(txn, key)
+	assert.NoError(t, err)
+	_, err = zset.ZAdd(members, scores)
+	assert.NoError(t, err)
+	assert.NoError(t, txn.Commit(context.TODO()))
+
+	txn, err = db.Begin()
+	assert.NoError(t, err)
+	zset, err = GetZSet(txn, key)
+	assert.NoError(t, err)
+	oldID := append([]byte{}, zset.meta.ID...)
+	assert.NoError(t, txn.Rollback())
+
+	// member and score key per member, copied across two batches
+	copied, err := CompactKey(db, key)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2*len(members)), copied)
+
+	txn, err = db.Begin()
+	assert.NoError(t, err)
+	zset, err = GetZSet(txn, key)
+	assert.NoError(t, err)
+	assert.NotEqual(t, oldID, zset.meta.ID)
+	assert.Equal(t, int64(len(members)), zset.ZCard())
+	score, err := zset.ZScore([]byte("m7"))
+	assert.NoError(t, err)
+	assert.Equal(t, "7", string(score))
+	out, err := zset.ZAnyOrderRange(0, 1, false, true)
+	assert.NoError(t, err)
+	assert.Equal(t, [][]byte{[]byte("m0"), []byte("m1")}, out)
+	assert.NoError(t, txn.Rollback())
+
+	_, err = CompactKey(db, []byte("TestCompactKeyMissing"))
+	assert.Equal(t, ErrKeyNotFound, err)
+}
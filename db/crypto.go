@@ -0,0 +1,160 @@
+package db
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"errors"
+	"io/ioutil"
+	"strings"
+
+	"go.uber.org/zap"
+
+	"github.com/distributedio/titan/conf"
+)
+
+// objectEncryptedMask marks the encoding byte of an object whose value
+// part is encrypted, the low bits keep the redis encoding
+const objectEncryptedMask = ObjectEncoding(0x80)
+
+// sealedValueMagic prefixes every encrypted data value so reads can tell
+// sealed values apart from plain ones written before encryption was
+// enabled for the namespace
+var sealedValueMagic = []byte{0, 't', 'e', '1'}
+
+// errNotSealed is returned by Decrypt for a value without the magic prefix
+var errNotSealed = errors.New("value is not sealed")
+
+// ValueCrypto seals data values with AES-256-GCM using a per-namespace
+// data key derived from the master key, so sensitive tenants get at-rest
+// protection independent of tikv level encryption. String and hash values
+// are sealed, members embedded in keys (set members, zset members and
+// scores) stay plain because they must remain seekable
+type ValueCrypto struct {
+	masterKey  []byte
+	namespaces map[string]bool // nil means every namespace
+}
+
+// NewValueCrypto creates a ValueCrypto sealing the listed namespaces,
+// an empty list seals every namespace
+func NewValueCrypto(masterKey []byte, namespaces []string) (*ValueCrypto, error) {
+	if len(masterKey) != 32 {
+		return nil, errors.New("master key must be 32 bytes")
+	}
+	c := &ValueCrypto{masterKey: masterKey}
+	if len(namespaces) > 0 {
+		c.namespaces = make(map[string]bool, len(namespaces))
+		for _, ns := range namespaces {
+			c.namespaces[ns] = true
+		}
+	}
+	return c, nil
+}
+
+// LoadValueCrypto builds a ValueCrypto from config, it returns nil when
+// no master key file is configured
+func LoadValueCrypto(config *conf.Crypto) (*ValueCrypto, error) {
+	if config.MasterKeyFile == "" {
+		return nil, nil
+	}
+	key, err := ioutil.ReadFile(config.MasterKeyFile)
+	if err != nil {
+		return nil, err
+	}
+	key = bytes.TrimSpace(key)
+	var namespaces []string
+	for _, ns := range strings.Split(config.Namespaces, ",") {
+		if ns = strings.TrimSpace(ns); ns != "" {
+			namespaces = append(namespaces, ns)
+		}
+	}
+	return NewValueCrypto(key, namespaces)
+}
+
+// Enabled reports whether values of the namespace should be sealed
+func (c *ValueCrypto) Enabled(namespace string) bool {
+	if c == nil {
+		return false
+	}
+	return c.namespaces == nil || c.namespaces[namespace]
+}
+
+// dataKey derives the data key of a namespace from the master key
+func (c *ValueCrypto) dataKey(namespace string) []byte {
+	mac := hmac.New(sha256.New, c.masterKey)
+	mac.Write([]byte(namespace))
+	return mac.Sum(nil)
+}
+
+func (c *ValueCrypto) aead(namespace string) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(c.dataKey(namespace))
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// Encrypt seals value, the result is magic + nonce + ciphertext
+func (c *ValueCrypto) Encrypt(namespace string, value []byte) ([]byte, error) {
+	aead, err := c.aead(namespace)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	sealed := append([]byte{}, sealedValueMagic...)
+	sealed = append(sealed, nonce...)
+	return aead.Seal(sealed, nonce, value, nil), nil
+}
+
+// Decrypt opens a sealed value, errNotSealed is returned for a value
+// without the magic prefix so callers can pass plain values through
+func (c *ValueCrypto) Decrypt(namespace string, value []byte) ([]byte, error) {
+	if c == nil || !bytes.HasPrefix(value, sealedValueMagic) {
+		return nil, errNotSealed
+	}
+	aead, err := c.aead(namespace)
+	if err != nil {
+		return nil, err
+	}
+	rest := value[len(sealedValueMagic):]
+	if len(rest) < aead.NonceSize() {
+		return nil, errors.New("sealed value too short")
+	}
+	return aead.Open(nil, rest[:aead.NonceSize()], rest[aead.NonceSize():], nil)
+}
+
+// sealValue seals a data value when encryption is enabled for the
+// namespace of this transaction. A failure fails the write, storing the
+// plaintext would silently void the protection the namespace asked for
+func (txn *Transaction) sealValue(value []byte) ([]byte, error) {
+	crypto := txn.db.kv.crypto
+	if !crypto.Enabled(txn.db.Namespace) {
+		return value, nil
+	}
+	sealed, err := crypto.Encrypt(txn.db.Namespace, value)
+	if err != nil {
+		zap.L().Error("[Crypto] seal value failed", zap.String("namespace", txn.db.Namespace), zap.Error(err))
+		return nil, err
+	}
+	return sealed, nil
+}
+
+// openValue opens a sealed data value, plain values written before
+// encryption was enabled are passed through unchanged
+func (txn *Transaction) openValue(value []byte) []byte {
+	crypto := txn.db.kv.crypto
+	if crypto == nil {
+		return value
+	}
+	plain, err := crypto.Decrypt(txn.db.Namespace, value)
+	if err != nil {
+		return value
+	}
+	return plain
+}
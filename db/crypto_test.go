@@ -0,0 +1,100 @@
+package db
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func mockCryptoDB(t *testing.T, namespaces ...string) *DB {
+	db := MockDB()
+	crypto, err := NewValueCrypto([]byte("0123456789abcdef0123456789abcdef"), namespaces)
+	assert.NoError(t, err)
+	db.kv.crypto = crypto
+	return db
+}
+
+func TestValueCrypto(t *testing.T) {
+	crypto, err := NewValueCrypto([]byte("0123456789abcdef0123456789abcdef"), nil)
+	assert.NoError(t, err)
+
+	sealed, err := crypto.Encrypt("tenant", []byte("value"))
+	assert.NoError(t, err)
+	assert.NotEqual(t, []byte("value"), sealed)
+
+	plain, err := crypto.Decrypt("tenant", sealed)
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("value"), plain)
+
+	// another namespace derives another data key
+	_, err = crypto.Decrypt("other", sealed)
+	assert.Error(t, err)
+
+	// plain values are reported as not sealed
+	_, err = crypto.Decrypt("tenant", []byte("value"))
+	assert.Equal(t, errNotSealed, err)
+
+	// the namespace allow list is honored
+	scoped, err := NewValueCrypto([]byte("0123456789abcdef0123456789abcdef"), []string{"tenant"})
+	assert.NoError(t, err)
+	assert.True(t, scoped.Enabled("tenant"))
+	assert.False(t, scoped.Enabled("other"))
+
+	_, err = NewValueCrypto([]byte("short"), nil)
+	assert.Error(t, err)
+}
+
+func TestCryptoString(t *testing.T) {
+	db := mockCryptoDB(t)
+	key := []byte("crypto-string")
+
+	txn, err := db.Begin()
+	assert.NoError(t, err)
+	str, err := GetString(txn, key)
+	assert.NoError(t, err)
+	assert.NoError(t, str.Set([]byte("secret"), 0))
+	assert.NoError(t, txn.Commit(context.TODO()))
+
+	// the stored meta does not contain the plain value
+	txn, err = db.Begin()
+	assert.NoError(t, err)
+	raw, err := txn.t.Get(MetaKey(db, key))
+	assert.NoError(t, err)
+	assert.NotContains(t, string(raw), "secret")
+
+	// reading through the string object decrypts transparently
+	str, err = GetString(txn, key)
+	assert.NoError(t, err)
+	val, err := str.Get()
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("secret"), val)
+	txn.Commit(context.TODO())
+}
+
+func TestCryptoHash(t *testing.T) {
+	db := mockCryptoDB(t)
+	key := []byte("crypto-hash")
+
+	txn, err := db.Begin()
+	assert.NoError(t, err)
+	hash, err := GetHash(txn, key)
+	assert.NoError(t, err)
+	_, err = hash.HSet([]byte("field"), []byte("secret"))
+	assert.NoError(t, err)
+	assert.NoError(t, txn.Commit(context.TODO()))
+
+	txn, err = db.Begin()
+	assert.NoError(t, err)
+	hash, err = GetHash(txn, key)
+	assert.NoError(t, err)
+	val, err := hash.HGet([]byte("field"))
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("secret"), val)
+
+	fields, vals, err := hash.HGetAll()
+	assert.NoError(t, err)
+	assert.Equal(t, [][]byte{[]byte("field")}, fields)
+	assert.Equal(t, [][]byte{[]byte("secret")}, vals)
+	txn.Commit(context.TODO())
+}
@@ -7,11 +7,14 @@ import (
 	"errors"
 	"fmt"
 	"strconv"
+	"strings"
 	"time"
 
+	"github.com/pingcap/tidb/kv"
 	"go.uber.org/zap"
 
 	"github.com/distributedio/titan/conf"
+	"github.com/distributedio/titan/db/keyspace"
 	"github.com/distributedio/titan/db/store"
 	"github.com/distributedio/titan/metrics"
 )
@@ -41,9 +44,23 @@ var (
 	// ErrStorageRetry storage err and try again later
 	ErrStorageRetry = errors.New("Storage err and try again later")
 
+	// ErrGenerationStale the namespace was flushed while the transaction
+	// ran, the message keeps the commit retryable so the command is
+	// replayed on the new prefix
+	ErrGenerationStale = errors.New("Namespace flushed and try again later")
+
 	//ErrSetNilValue means the value corresponding to key is a non-zero value
 	ErrSetNilValue = errors.New("The value corresponding to key is a non-zero value")
 
+	// ErrScoreNaN means a score increment produced NaN, inf added to -inf
+	ErrScoreNaN = errors.New("resulting score is not a number (NaN)")
+
+	// ErrSequenceValue means a stored sequence counter does not parse as an integer
+	ErrSequenceValue = errors.New("sequence value is not an integer")
+
+	// ErrInvalidNamespace the namespace name would make keys ambiguous
+	ErrInvalidNamespace = errors.New("namespace name must not contain ':' or '@'")
+
 	// IsErrNotFound returns true if the key is not found, otherwise return false
 	IsErrNotFound = store.IsErrNotFound
 
@@ -83,6 +100,13 @@ func toDBID(v []byte) DBID {
 	return DBID(id)
 }
 
+// IsReservedNamespace reports whether the namespace belongs to the system
+// keyspace, every namespace starting with "$sys" is managed by titan itself
+// (limiter data, expire leadership, GC) and regular clients may not use it
+func IsReservedNamespace(namespace string) bool {
+	return strings.HasPrefix(namespace, sysNamespace)
+}
+
 // BatchGetValues issues batch requests to get values
 func BatchGetValues(txn *Transaction, keys [][]byte) ([][]byte, error) {
 	kvs, err := store.BatchGetValues(txn.t, keys)
@@ -107,7 +131,8 @@ type DB struct {
 // RedisStore wraps store.Storage
 type RedisStore struct {
 	store.Storage
-	conf *conf.Tikv
+	conf   *conf.Tikv
+	crypto *ValueCrypto
 }
 
 // Open a storage instance
@@ -117,17 +142,51 @@ func Open(conf *conf.Tikv) (*RedisStore, error) {
 		return nil, err
 	}
 	rds := &RedisStore{Storage: s, conf: conf}
+	if rds.crypto, err = LoadValueCrypto(&conf.Crypto); err != nil {
+		return nil, err
+	}
 	sysdb := rds.DB(sysNamespace, sysDatabaseID)
-	ls := NewLeaderStatus()
+	if err := CheckSchema(sysdb); err != nil {
+		return nil, err
+	}
+	if !conf.Breaker.Disable {
+		EnableBreaker(&conf.Breaker)
+	}
+	if !conf.Expire.Disable {
+		EnableLazyExpire()
+	}
+	if !conf.ReadRepair.Disable {
+		EnableReadRepair(&conf.ReadRepair)
+	}
+	EnableLazyDelete(&conf.LazyDelete)
+	if !conf.Backpressure.Disable {
+		EnableBackpressure(&conf.Backpressure)
+	}
+	if !conf.Warmup.Disable {
+		if err := rds.Warmup(&conf.Warmup); err != nil {
+			zap.L().Warn("startup warm-up failed", zap.Error(err))
+		}
+	}
 	go StartGC(sysdb, &conf.GC)
-	go setExpireIsLeader(sysdb, &conf.Expire, ls)
-	go startExpire(sysdb, &conf.Expire, ls, "")
+	leaderNum := expireLeaderNum(&conf.Expire)
+	statuses := make([]*LeaderStatus, leaderNum)
+	for group := range statuses {
+		statuses[group] = NewLeaderStatus()
+		go setExpireIsLeader(sysdb, &conf.Expire, statuses[group], expireLeaderKey(group))
+	}
+	go startExpire(sysdb, &conf.Expire, statuses[0], "")
 	for i := 0; i < EXPIRE_HASH_NUM; i++ {
 		expireHash := fmt.Sprintf("%04d", i)
-		go startExpire(sysdb, &conf.Expire, ls, expireHash)
+		go startExpire(sysdb, &conf.Expire, statuses[expireBucketGroup(i, leaderNum)], expireHash)
 	}
 	go StartZT(sysdb, &conf.ZT)
 	go StartTikvGC(sysdb, &conf.TikvGC)
+	go StartReaper(sysdb, &conf.Reaper)
+	go StartZSetMigrate(sysdb, &conf.ZSM)
+	go StartZSetMemberExpire(sysdb, &conf.MemberExpire)
+	go StartRewriter(sysdb, &conf.Rewriter)
+	go StartDBSizeReconciler(sysdb, &conf.DBSize)
+	go StartSchemaUpgrade(sysdb)
 	return rds, nil
 }
 
@@ -145,15 +204,19 @@ func (rds *RedisStore) Close() error {
 type Transaction struct {
 	t  store.Transaction
 	db *DB
+	// gen is the flush generation current when the transaction began,
+	// verified against the registry at commit, see verifyGeneration
+	gen uint32
 }
 
 // Begin a transaction
 func (db *DB) Begin() (*Transaction, error) {
 	txn, err := db.kv.Begin()
+	recordBegin(err)
 	if err != nil {
 		return nil, err
 	}
-	return &Transaction{t: txn, db: db}, nil
+	return &Transaction{t: txn, db: db, gen: currentGeneration(db.Namespace)}, nil
 }
 
 // Prefix returns the prefix of a DB object
@@ -163,7 +226,15 @@ func (db *DB) Prefix() []byte {
 
 // Commit a transaction
 func (txn *Transaction) Commit(ctx context.Context) error {
-	return txn.t.Commit(ctx)
+	if err := txn.verifyGeneration(); err != nil {
+		txn.Rollback()
+		return err
+	}
+	start := time.Now()
+	err := txn.t.Commit(ctx)
+	recordCommit(err)
+	recordCommitLatency(time.Since(start))
+	return err
 }
 
 // Rollback a transaction
@@ -241,41 +312,77 @@ func (txn *Transaction) ZSet(key []byte) (*ZSet, error) {
 	return GetZSet(txn, key)
 }
 
+// Bitmap returns a bitmap object
+func (txn *Transaction) Bitmap(key []byte) (*Bitmap, error) {
+	return GetBitmap(txn, key)
+}
+
+// SnapshotVersion returns the tikv version the transaction reads at
+func (txn *Transaction) SnapshotVersion() uint64 {
+	return txn.t.StartTS()
+}
+
 // LockKeys tries to lock the entries with the keys in KV store.
 func (txn *Transaction) LockKeys(keys ...[]byte) error {
 	return store.LockKeys(txn.t, keys)
 }
 
-// MetaKey build to metakey from a redis key
+// CommitVersion resolves the tikv commit version of the last write to the
+// meta key of key, by binary searching the snapshot history for the first
+// version the current value is visible at. It is a debugging aid issuing
+// one snapshot read per search step, versions below the gc safe point read
+// as not visible. ErrKeyNotFound is returned when the key does not exist
+func (txn *Transaction) CommitVersion(key []byte) (uint64, error) {
+	mkey := MetaKey(txn.db, key)
+	cur, err := txn.t.Get(mkey)
+	if err != nil {
+		if IsErrNotFound(err) {
+			return 0, ErrKeyNotFound
+		}
+		return 0, err
+	}
+
+	latest, err := txn.db.kv.CurrentVersion()
+	if err != nil {
+		return 0, err
+	}
+	visible := func(ver uint64) bool {
+		snap, err := txn.db.kv.GetSnapshot(kv.Version{Ver: ver})
+		if err != nil {
+			return false
+		}
+		val, err := snap.Get(mkey)
+		if err != nil {
+			return false
+		}
+		return bytes.Equal(val, cur)
+	}
+	lo, hi := uint64(1), latest.Ver
+	for lo < hi {
+		mid := lo + (hi-lo)/2
+		if visible(mid) {
+			hi = mid
+		} else {
+			lo = mid + 1
+		}
+	}
+	return lo, nil
+}
+
+// MetaKey build to metakey from a redis key, the namespace section
+// carries the current flush generation
 func MetaKey(db *DB, key []byte) []byte {
-	var mkey []byte
-	mkey = append(mkey, []byte(db.Namespace)...)
-	mkey = append(mkey, ':')
-	mkey = append(mkey, db.ID.Bytes()...)
-	mkey = append(mkey, ':', 'M', ':')
-	mkey = append(mkey, key...)
-	return mkey
+	return keyspace.MetaKey(storageNamespace(db.Namespace), db.ID.Bytes(), key)
 }
 
-// DataKey builds a datakey from a redis key
+// DataKey builds a datakey from a redis key, the namespace section
+// carries the current flush generation
 func DataKey(db *DB, key []byte) []byte {
-	var dkey []byte
-	dkey = append(dkey, []byte(db.Namespace)...)
-	dkey = append(dkey, ':')
-	dkey = append(dkey, db.ID.Bytes()...)
-	dkey = append(dkey, ':', 'D', ':')
-	dkey = append(dkey, key...)
-	return dkey
+	return keyspace.DataKey(storageNamespace(db.Namespace), db.ID.Bytes(), key)
 }
 
 func dbPrefix(ns string, id []byte) []byte {
-	prefix := []byte(ns)
-	prefix = append(prefix, ':')
-	if id != nil {
-		prefix = append(prefix, id...)
-		prefix = append(prefix, ':')
-	}
-	return prefix
+	return keyspace.DBPrefix(storageNamespace(ns), id)
 }
 
 func flushLease(txn store.Transaction, key, id []byte, interval time.Duration) error {
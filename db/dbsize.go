@@ -0,0 +1,340 @@
+package db
+
+import (
+	"bytes"
+	"context"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/distributedio/titan/conf"
+	"github.com/distributedio/titan/metrics"
+	"github.com/pingcap/tidb/kv"
+	"go.uber.org/zap"
+)
+
+var (
+	sysDBSizeLeader        = []byte("$sys:0:DSL:DSLeader")
+	sysDBSizeCursor        = []byte("$sys:0:DS:cursor")
+	sysDBSizePartialPrefix = []byte("$sys:0:DS:partial:")
+	sysDBSizeCountPrefix   = []byte("$sys:0:DS:count:")
+)
+
+const (
+	dbsize_worker = "dbsize"
+
+	dbsizeInfoToken = "|"
+)
+
+// DBSizeInfo is the key counter of one namespace db as seen by the last
+// completed reconcile pass
+type DBSizeInfo struct {
+	Keys         int64 // meta keys counted by the scan
+	ReconciledAt int64 // unix nano of the pass that wrote the counter
+	Delta        int64 // correction against the previous counter
+	Bytes        int64 // approximate stored bytes, keys plus values
+}
+
+func encodeDBSizeInfo(info *DBSizeInfo) []byte {
+	fields := []string{
+		strconv.FormatInt(info.Keys, 10),
+		strconv.FormatInt(info.ReconciledAt, 10),
+		strconv.FormatInt(info.Delta, 10),
+		strconv.FormatInt(info.Bytes, 10),
+	}
+	return []byte(strings.Join(fields, dbsizeInfoToken))
+}
+
+func decodeDBSizeInfo(val []byte) (*DBSizeInfo, error) {
+	fields := strings.Split(string(val), dbsizeInfoToken)
+	// counters written before byte accounting carry three fields
+	if len(fields) != 3 && len(fields) != 4 {
+		return nil, ErrInvalidLength
+	}
+	info := &DBSizeInfo{}
+	var err error
+	if info.Keys, err = strconv.ParseInt(fields[0], 10, 64); err != nil {
+		return nil, err
+	}
+	if info.ReconciledAt, err = strconv.ParseInt(fields[1], 10, 64); err != nil {
+		return nil, err
+	}
+	if info.Delta, err = strconv.ParseInt(fields[2], 10, 64); err != nil {
+		return nil, err
+	}
+	if len(fields) == 4 {
+		if info.Bytes, err = strconv.ParseInt(fields[3], 10, 64); err != nil {
+			return nil, err
+		}
+	}
+	return info, nil
+}
+
+// dbsizeCountKey returns the counter key of a "{ns}:{dbid}" meta prefix
+func dbsizeCountKey(nsdb string) []byte {
+	return append(append([]byte{}, sysDBSizeCountPrefix...), nsdb...)
+}
+
+func dbsizePartialKey(nsdb string) []byte {
+	return append(append([]byte{}, sysDBSizePartialPrefix...), nsdb...)
+}
+
+// GetDBSizeInfo reads the reconciled key counter of db, it returns nil
+// without an error when no reconcile pass has completed yet
+func GetDBSizeInfo(db *DB) (*DBSizeInfo, error) {
+	txn, err := db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer txn.Rollback()
+	val, err := txn.t.Get(dbsizeCountKey(db.Namespace + ":" + db.ID.String()))
+	if err != nil {
+		if IsErrNotFound(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return decodeDBSizeInfo(val)
+}
+
+// metaKeyPrefix extracts the "{ns}:{dbid}" prefix of a meta space key,
+// the caller must have checked isMetaKey already
+func metaKeyPrefix(key []byte) string {
+	idx := bytes.IndexByte(key, ':')
+	return string(key[:idx+1+3])
+}
+
+// isDBKey reports whether the key has the "{ns}:{dbid}:{TAG}:" shape of
+// any keyspace tag, it accepts data and index keys besides metas
+func isDBKey(key []byte) bool {
+	idx := bytes.IndexByte(key, ':')
+	if idx < 0 || len(key) < idx+1+3+3 {
+		return false
+	}
+	rest := key[idx+1:]
+	return rest[3] == ':' && rest[5] == ':'
+}
+
+// dbsizePartial is the running count of one unfinished pass
+type dbsizePartial struct {
+	keys  int64
+	bytes int64
+}
+
+func encodeDBSizePartial(p *dbsizePartial) []byte {
+	val := EncodeInt64(p.keys)
+	return append(val, EncodeInt64(p.bytes)...)
+}
+
+func decodeDBSizePartial(val []byte) *dbsizePartial {
+	p := &dbsizePartial{}
+	if len(val) >= 8 {
+		p.keys = DecodeInt64(val[:8])
+	}
+	if len(val) >= 16 {
+		p.bytes = DecodeInt64(val[8:16])
+	}
+	return p
+}
+
+// doDBSize continues the counting pass from the saved cursor, scanning up
+// to batchLimit keys and folding the counts of meta keys it saw into the
+// partial records. When the pass reaches the end of the store it turns the
+// partials into the visible counters, records the drift against the
+// previous pass and starts over
+func doDBSize(db *DB, batchLimit int) error {
+	dbTxn, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	txn := dbTxn.t
+
+	var cursor []byte
+	if val, err := txn.Get(sysDBSizeCursor); err == nil {
+		cursor = append(cursor, val...)
+	} else if !IsErrNotFound(err) {
+		txn.Rollback()
+		return err
+	}
+
+	start := time.Now()
+	iter, err := txn.Iter(cursor, nil)
+	metrics.GetMetrics().WorkerSeekCostHistogramVec.WithLabelValues(dbsize_worker).Observe(time.Since(start).Seconds())
+	if err != nil {
+		txn.Rollback()
+		return err
+	}
+
+	// the scan reads values as well, their sizes feed the approximate byte
+	// counter of each namespace db
+	counts := make(map[string]*dbsizePartial)
+	scanned := 0
+	var lastKey []byte
+	for ; iter.Valid() && scanned < batchLimit; err = iter.Next() {
+		if err != nil {
+			iter.Close()
+			txn.Rollback()
+			return err
+		}
+		scanned++
+		lastKey = append(lastKey[:0], iter.Key()...)
+		if !isDBKey(iter.Key()) || bytes.HasPrefix(iter.Key(), []byte(sysNamespace)) {
+			continue
+		}
+		nsdb := metaKeyPrefix(iter.Key())
+		p := counts[nsdb]
+		if p == nil {
+			p = &dbsizePartial{}
+			counts[nsdb] = p
+		}
+		p.bytes += int64(len(iter.Key()) + len(iter.Value()))
+		if isMetaKey(iter.Key()) {
+			p.keys++
+		}
+	}
+	exhausted := !iter.Valid()
+	iter.Close()
+
+	for nsdb, n := range counts {
+		pkey := dbsizePartialKey(nsdb)
+		sum := &dbsizePartial{}
+		if val, err := txn.Get(pkey); err == nil {
+			sum = decodeDBSizePartial(val)
+		} else if !IsErrNotFound(err) {
+			txn.Rollback()
+			return err
+		}
+		sum.keys += n.keys
+		sum.bytes += n.bytes
+		if err := txn.Set(pkey, encodeDBSizePartial(sum)); err != nil {
+			txn.Rollback()
+			return err
+		}
+	}
+
+	if exhausted {
+		if err := finishDBSizePass(txn); err != nil {
+			txn.Rollback()
+			return err
+		}
+		if len(cursor) > 0 {
+			if err := txn.Delete(sysDBSizeCursor); err != nil {
+				txn.Rollback()
+				return err
+			}
+		}
+	} else if err := txn.Set(sysDBSizeCursor, kv.Key(lastKey).Next()); err != nil {
+		txn.Rollback()
+		return err
+	}
+
+	start = time.Now()
+	if err := txn.Commit(context.Background()); err != nil {
+		txn.Rollback()
+		return err
+	}
+	metrics.GetMetrics().WorkerCommitCostHistogramVec.WithLabelValues(dbsize_worker).Observe(time.Since(start).Seconds())
+	return nil
+}
+
+// finishDBSizePass replaces the visible counters with the partial counts of
+// the finished pass. Counters whose namespace produced no partial record are
+// reset to zero so dropped namespaces do not keep a stale count forever
+func finishDBSizePass(txn kv.Transaction) error {
+	partials := make(map[string]*dbsizePartial)
+	iter, err := txn.Iter(sysDBSizePartialPrefix, kv.Key(sysDBSizePartialPrefix).PrefixNext())
+	if err != nil {
+		return err
+	}
+	for ; iter.Valid() && iter.Key().HasPrefix(sysDBSizePartialPrefix); err = iter.Next() {
+		if err != nil {
+			iter.Close()
+			return err
+		}
+		partials[string(iter.Key()[len(sysDBSizePartialPrefix):])] = decodeDBSizePartial(iter.Value())
+	}
+	iter.Close()
+
+	olds := make(map[string]int64)
+	iter, err = txn.Iter(sysDBSizeCountPrefix, kv.Key(sysDBSizeCountPrefix).PrefixNext())
+	if err != nil {
+		return err
+	}
+	for ; iter.Valid() && iter.Key().HasPrefix(sysDBSizeCountPrefix); err = iter.Next() {
+		if err != nil {
+			iter.Close()
+			return err
+		}
+		info, derr := decodeDBSizeInfo(iter.Value())
+		if derr != nil {
+			continue
+		}
+		olds[string(iter.Key()[len(sysDBSizeCountPrefix):])] = info.Keys
+	}
+	iter.Close()
+
+	now := time.Now().UnixNano()
+	for nsdb, old := range olds {
+		if _, ok := partials[nsdb]; !ok && old != 0 {
+			partials[nsdb] = &dbsizePartial{}
+		}
+	}
+	for nsdb, p := range partials {
+		count := p.keys
+		delta := count - olds[nsdb]
+		info := &DBSizeInfo{Keys: count, ReconciledAt: now, Delta: delta, Bytes: p.bytes}
+		if err := txn.Set(dbsizeCountKey(nsdb), encodeDBSizeInfo(info)); err != nil {
+			return err
+		}
+		if err := txn.Delete(dbsizePartialKey(nsdb)); err != nil && !IsErrNotFound(err) {
+			return err
+		}
+		ns := nsdb
+		if idx := strings.IndexByte(nsdb, ':'); idx > 0 {
+			ns = nsdb[:idx]
+		}
+		label := metrics.NamespaceLabel(ns)
+		metrics.GetMetrics().DBSizeKeysGaugeVec.WithLabelValues(label).Set(float64(count))
+		metrics.GetMetrics().DBSizeDeltaGaugeVec.WithLabelValues(label).Set(float64(delta))
+		if delta != 0 {
+			zap.L().Info("[DS] reconciled key counter", zap.String("db", nsdb),
+				zap.Int64("keys", count), zap.Int64("delta", delta))
+		}
+	}
+	return nil
+}
+
+// StartDBSizeReconciler counts the meta keys of every namespace db with a
+// cursor bounded scan and reconciles the stored counters against the result.
+// Only the leader node does the work
+func StartDBSizeReconciler(db *DB, conf *conf.DBSize) {
+	ticker := time.NewTicker(conf.Interval)
+	defer ticker.Stop()
+	id := UUID()
+	for range ticker.C {
+		if conf.Disable {
+			continue
+		}
+
+		start := time.Now()
+		isLeader, err := isLeader(db, sysDBSizeLeader, id, conf.LeaderLifeTime)
+		if err != nil {
+			zap.L().Error("[DS] check dbsize leader failed",
+				zap.ByteString("leader", sysDBSizeLeader),
+				zap.ByteString("uuid", id),
+				zap.Error(err))
+			continue
+		}
+		if !isLeader {
+			continue
+		}
+		if err := doDBSize(db, conf.BatchLimit); err != nil {
+			zap.L().Error("[DS] do dbsize failed",
+				zap.ByteString("leader", sysDBSizeLeader),
+				zap.ByteString("uuid", id),
+				zap.Error(err))
+			continue
+		}
+		metrics.GetMetrics().WorkerRoundCostHistogramVec.WithLabelValues(dbsize_worker).Observe(time.Since(start).Seconds())
+	}
+}
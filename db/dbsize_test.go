@@ -0,0 +1,63 @@
+package db
+
+import (
+	"context"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func dbsizeSetKey(t *testing.T, d *DB, key string) {
+	txn, err := d.Begin()
+	assert.NoError(t, err)
+	s, err := GetString(txn, []byte(key))
+	assert.NoError(t, err)
+	assert.NoError(t, s.Set([]byte("v")))
+	assert.NoError(t, txn.Commit(context.TODO()))
+}
+
+func dbsizeDelKey(t *testing.T, d *DB, key string) {
+	txn, err := d.Begin()
+	assert.NoError(t, err)
+	kv := txn.Kv()
+	n, err := kv.Delete([][]byte{[]byte(key)})
+	assert.NoError(t, err)
+	assert.EqualValues(t, 1, n)
+	assert.NoError(t, txn.Commit(context.TODO()))
+}
+
+// runDBSizePass drives doDBSize until a reconcile pass newer than since
+// completed, the small batch limit forces the cursor across several rounds
+func runDBSizePass(t *testing.T, d *DB, since int64) *DBSizeInfo {
+	for i := 0; i < 100; i++ {
+		assert.NoError(t, doDBSize(d, 4))
+		info, err := GetDBSizeInfo(d)
+		assert.NoError(t, err)
+		if info != nil && info.ReconciledAt > since {
+			return info
+		}
+	}
+	t.Fatal("dbsize pass did not complete")
+	return nil
+}
+
+func TestDBSizeReconcile(t *testing.T) {
+	d := MockDB()
+	for i := 0; i < 5; i++ {
+		dbsizeSetKey(t, d, "dbsize:"+strconv.Itoa(i))
+	}
+
+	info := runDBSizePass(t, d, 0)
+	assert.EqualValues(t, 5, info.Keys)
+	assert.EqualValues(t, 5, info.Delta)
+	assert.True(t, info.Bytes > 0)
+
+	dbsizeDelKey(t, d, "dbsize:0")
+	dbsizeSetKey(t, d, "dbsize:new")
+	dbsizeSetKey(t, d, "dbsize:new2")
+
+	info = runDBSizePass(t, d, info.ReconciledAt)
+	assert.EqualValues(t, 6, info.Keys)
+	assert.EqualValues(t, 1, info.Delta)
+}
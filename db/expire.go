@@ -6,10 +6,13 @@ import (
 	"fmt"
 	"hash/crc32"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/distributedio/titan/conf"
+	"github.com/distributedio/titan/db/keyspace"
 	"github.com/distributedio/titan/db/store"
+	"github.com/distributedio/titan/failpoint"
 	"github.com/distributedio/titan/metrics"
 	"go.uber.org/zap"
 )
@@ -30,31 +33,60 @@ const (
 	EXPIRE_HASH_NUM      = 256
 )
 
+// LeaderStatus tracks whether this node holds a leader key. getIsLeader
+// never blocks, it just reports the last observed state, and callbacks
+// registered with OnElected/OnResigned fire on every transition, so a
+// worker can react to leadership changes without waiting for the next
+// leadership check tick
 type LeaderStatus struct {
-	isLeader bool
-	cond     *sync.Cond
+	isLeader int32
+
+	mu         sync.Mutex
+	onElected  []func()
+	onResigned []func()
 }
 
 func NewLeaderStatus() *LeaderStatus {
-	return &LeaderStatus{
-		cond: sync.NewCond(new(sync.Mutex)),
-	}
+	return &LeaderStatus{}
+}
+
+// OnElected registers a callback invoked when leadership is gained
+func (ls *LeaderStatus) OnElected(f func()) {
+	ls.mu.Lock()
+	ls.onElected = append(ls.onElected, f)
+	ls.mu.Unlock()
+}
+
+// OnResigned registers a callback invoked when leadership is lost
+func (ls *LeaderStatus) OnResigned(f func()) {
+	ls.mu.Lock()
+	ls.onResigned = append(ls.onResigned, f)
+	ls.mu.Unlock()
 }
 
 func (ls *LeaderStatus) setIsLeader(isLeader bool) {
-	ls.cond.L.Lock()
-	defer ls.cond.L.Unlock()
+	val := int32(0)
+	if isLeader {
+		val = 1
+	}
+	if atomic.SwapInt32(&ls.isLeader, val) == val {
+		return
+	}
 
-	ls.isLeader = isLeader
-	ls.cond.Broadcast()
+	ls.mu.Lock()
+	callbacks := ls.onResigned
+	if isLeader {
+		callbacks = ls.onElected
+	}
+	callbacks = append([]func(){}, callbacks...)
+	ls.mu.Unlock()
+	for _, f := range callbacks {
+		f()
+	}
 }
 
 func (ls *LeaderStatus) getIsLeader() bool {
-	ls.cond.L.Lock()
-	defer ls.cond.L.Unlock()
-
-	ls.cond.Wait()
-	return ls.isLeader
+	return atomic.LoadInt32(&ls.isLeader) == 1
 }
 
 // IsExpired judge object expire through now
@@ -68,20 +100,27 @@ func IsExpired(obj *Object, now int64) bool {
 func expireKey(key []byte, ts int64) []byte {
 	hashnum := crc32.ChecksumIEEE(key)
 	hashPrefix := fmt.Sprintf("%04d", hashnum%EXPIRE_HASH_NUM)
-	var buf []byte
-	buf = append(buf, hashExpireKeyPrefix...)
-	buf = append(buf, []byte(hashPrefix)...)
-	buf = append(buf, ':')
-	buf = append(buf, EncodeInt64(ts)...)
-	buf = append(buf, ':')
-	buf = append(buf, key...)
-	return buf
+	return keyspace.ExpireKey(hashPrefix, EncodeInt64(ts), key)
+}
+
+// sameExpireBucket reports whether two expire timestamps land in the same
+// second, the granularity the expire worker runs at
+func sameExpireBucket(oldAt, newAt int64) bool {
+	return oldAt/int64(time.Second) == newAt/int64(time.Second)
 }
 
 func expireAt(txn store.Transaction, mkey []byte, objID []byte, objType ObjectType, oldAt int64, newAt int64) error {
 	oldKey := expireKey(mkey, oldAt)
 	newKey := expireKey(mkey, newAt)
 
+	// Resetting a ttl within the same second rewrites the index entry
+	// without changing when the key expires, skip the delete+set pair to
+	// cut the index churn of setex heavy workloads
+	if oldAt > 0 && newAt > 0 && sameExpireBucket(oldAt, newAt) {
+		metrics.GetMetrics().ExpireKeysTotal.WithLabelValues("coalesced").Inc()
+		return nil
+	}
+
 	if oldAt > 0 {
 		if err := txn.Delete(oldKey); err != nil {
 			return err
@@ -119,8 +158,35 @@ func unExpireAt(txn store.Transaction, mkey []byte, expireAt int64) error {
 	return nil
 }
 
+// expireLeaderNum clamps the configured leader count into [1, EXPIRE_HASH_NUM]
+func expireLeaderNum(conf *conf.Expire) int {
+	num := conf.LeaderNum
+	if num <= 0 {
+		num = 1
+	}
+	if num > EXPIRE_HASH_NUM {
+		num = EXPIRE_HASH_NUM
+	}
+	return num
+}
+
+// expireLeaderKey returns the leader key of a leader group, group 0 keeps
+// the legacy key so a single leader setup stays compatible across upgrades
+func expireLeaderKey(group int) []byte {
+	if group == 0 {
+		return sysExpireLeader
+	}
+	return []byte(fmt.Sprintf("%s:%d", sysExpireLeader, group))
+}
+
+// expireBucketGroup maps a hash bucket to its leader group, buckets are
+// split into contiguous ranges of nearly equal size
+func expireBucketGroup(bucket int, leaderNum int) int {
+	return bucket * leaderNum / EXPIRE_HASH_NUM
+}
+
 // setExpireIsLeader get leader from db
-func setExpireIsLeader(db *DB, conf *conf.Expire, ls *LeaderStatus) error {
+func setExpireIsLeader(db *DB, conf *conf.Expire, ls *LeaderStatus, leaderKey []byte) error {
 	ticker := time.NewTicker(conf.Interval)
 	defer ticker.Stop()
 	id := UUID()
@@ -130,7 +196,7 @@ func setExpireIsLeader(db *DB, conf *conf.Expire, ls *LeaderStatus) error {
 			continue
 		}
 
-		isLeader, err := isLeader(db, sysExpireLeader, id, conf.LeaderLifeTime)
+		isLeader, err := isLeader(db, leaderKey, id, conf.LeaderLifeTime)
 		if err != nil {
 			zap.L().Error("[Expire] check expire leader failed", zap.Error(err))
 			ls.setIsLeader(false)
@@ -138,7 +204,7 @@ func setExpireIsLeader(db *DB, conf *conf.Expire, ls *LeaderStatus) error {
 		}
 		if !isLeader {
 			if logEnv := zap.L().Check(zap.DebugLevel, "[Expire] not expire leader"); logEnv != nil {
-				logEnv.Write(zap.ByteString("leader", sysExpireLeader),
+				logEnv.Write(zap.ByteString("leader", leaderKey),
 					zap.ByteString("uuid", id),
 					zap.Duration("leader-life-time", conf.LeaderLifeTime))
 			}
@@ -161,10 +227,10 @@ func startExpire(db *DB, conf *conf.Expire, ls *LeaderStatus, expireHash string)
 
 		start := time.Now()
 		if expireHash != "" {
-			lastExpireEndTs = runExpire(db, conf.BatchLimit, expireHash, lastExpireEndTs)
+			lastExpireEndTs = runExpire(db, conf.BatchLimit, conf.NamespaceLimit, expireHash, lastExpireEndTs)
 			metrics.GetMetrics().WorkerRoundCostHistogramVec.WithLabelValues(expire_worker).Observe(time.Since(start).Seconds())
 		} else {
-			lastExpireEndTs = runExpire(db, conf.UnhashBatchLimit, expireHash, lastExpireEndTs)
+			lastExpireEndTs = runExpire(db, conf.UnhashBatchLimit, conf.NamespaceLimit, expireHash, lastExpireEndTs)
 			metrics.GetMetrics().WorkerRoundCostHistogramVec.WithLabelValues(expire_unhash_worker).Observe(time.Since(start).Seconds())
 		}
 
@@ -173,21 +239,12 @@ func startExpire(db *DB, conf *conf.Expire, ls *LeaderStatus, expireHash string)
 
 // split a meta key with format: {namespace}:{id}:M:{key}
 func splitMetaKey(key []byte) ([]byte, DBID, []byte) {
-	idx := bytes.Index(key, []byte{':'})
-	namespace := key[:idx]
-	id := toDBID(key[idx+1 : idx+4])
-	rawkey := key[idx+6:]
-	return namespace, id, rawkey
+	namespace, id, rawkey := keyspace.SplitMetaKey(key)
+	return namespace, toDBID(id), rawkey
 }
 
 func toTikvDataKey(namespace []byte, id DBID, key []byte) []byte {
-	var b []byte
-	b = append(b, namespace...)
-	b = append(b, ':')
-	b = append(b, id.Bytes()...)
-	b = append(b, ':', 'D', ':')
-	b = append(b, key...)
-	return b
+	return keyspace.DataKey(namespace, id.Bytes(), key)
 }
 
 func toTikvScorePrefix(namespace []byte, id DBID, key []byte) []byte {
@@ -200,7 +257,17 @@ func toTikvScorePrefix(namespace []byte, id DBID, key []byte) []byte {
 	return b
 }
 
-func runExpire(db *DB, batchLimit int, expireHash string, lastExpireEndTs int64) int64 {
+// runExpire expires up to batchLimit keys in one transaction. nsBatchLimit
+// additionally caps how many of them may belong to one namespace, so a
+// tenant with a huge expiring backlog cannot monopolize the batch every
+// round, 0 means unlimited. Keys skipped by the cap stay in the index and
+// the next round seeks back to the first of them
+func runExpire(db *DB, batchLimit int, nsBatchLimit int, expireHash string, lastExpireEndTs int64) int64 {
+	failpoint.Inject("expire-round-pause", func(val string) {
+		if d, err := time.ParseDuration(val); err == nil {
+			time.Sleep(d)
+		}
+	})
 	curExpireTimestampOffset := expireTimestampOffset
 	curExpireMetakeyOffset := expireMetakeyOffset
 	var curExpireKeyPrefix []byte //expireKeyPrefix of current go routine
@@ -256,6 +323,9 @@ func runExpire(db *DB, batchLimit int, expireHash string, lastExpireEndTs int64)
 	}
 	limit := batchLimit
 
+	expiredByNamespace := make(map[string]int)
+	firstSkippedTs := int64(0)
+	skipped := 0
 	thisExpireEndTs := int64(0)
 	ts := now
 	for iter.Valid() && iter.Key().HasPrefix(curExpireKeyPrefix) && limit > 0 {
@@ -268,6 +338,27 @@ func runExpire(db *DB, batchLimit int, expireHash string, lastExpireEndTs int64)
 			break
 		}
 		mkey := rawKey[curExpireMetakeyOffset:]
+
+		namespace, _, _ := splitMetaKey(mkey)
+		if nsBatchLimit > 0 && expiredByNamespace[string(namespace)] >= nsBatchLimit {
+			// the namespace used up its share of this round, leave the
+			// key in the index, the next round seeks back to it
+			if firstSkippedTs == 0 {
+				firstSkippedTs = ts
+			}
+			if skipped++; skipped >= batchLimit {
+				break
+			}
+			if err := iter.Next(); err != nil {
+				zap.L().Error(expireLogFlag+" next failed",
+					zap.ByteString("mkey", mkey),
+					zap.Error(err))
+				txn.Rollback()
+				return 0
+			}
+			continue
+		}
+
 		if err := doExpire(txn, mkey, iter.Value(), expireLogFlag, ts); err != nil {
 			txn.Rollback()
 			return 0
@@ -302,12 +393,20 @@ func runExpire(db *DB, batchLimit int, expireHash string, lastExpireEndTs int64)
 
 		//just use the latest processed expireKey(don't include the last expire key in the loop which is > now) as next seek's start key
 		thisExpireEndTs = ts
+		expiredByNamespace[string(namespace)]++
 		limit--
 	}
 	if limit == batchLimit {
 		//means: no expire keys or all expire keys > now in current loop
 		thisExpireEndTs = now
 	}
+	if firstSkippedTs > 0 && firstSkippedTs < thisExpireEndTs {
+		//seek back to the first key skipped by the namespace cap
+		thisExpireEndTs = firstSkippedTs
+	}
+	for ns, count := range expiredByNamespace {
+		metrics.GetMetrics().ExpiredKeysByNamespaceVec.WithLabelValues(metrics.NamespaceLabel(ns)).Add(float64(count))
+	}
 
 	now = time.Now().UnixNano()
 	if ts < now {
@@ -379,8 +478,9 @@ func doExpire(txn *Transaction, mkey, id []byte, expireLogFlag string, expireAt
 	}
 
 	//compare expire-key's ts with object.expireat(their object id is same in the condition),
-	//if different, means it's a not-string structure and its expire-key was rewriten in hashed prefix, but old ones was writen in unhashed prefix
-	if obj.ExpireAt != expireAt {
+	//if different, means it's a not-string structure and its expire-key was rewriten in hashed prefix, but old ones was writen in unhashed prefix.
+	//the comparison is at second granularity because expireAt() coalesces ttl resets landing in the same second
+	if !sameExpireBucket(obj.ExpireAt, expireAt) {
 		if logEnv := zap.L().Check(zap.DebugLevel, expireLogFlag+" it should be unhashed expire key un-matching key's expireAt, skip doExpire"); logEnv != nil {
 			logEnv.Write(zap.ByteString("mkey", mkey), zap.Int64("this expire key's ts", expireAt), zap.Int64("key's expireAt", obj.ExpireAt))
 		}
@@ -7,6 +7,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/distributedio/titan/db/keyspace"
 	"github.com/distributedio/titan/db/store"
 	"github.com/stretchr/testify/assert"
 )
@@ -204,10 +205,10 @@ func Test_runExpire(t *testing.T) {
 			if now < expireAt {
 				time.Sleep(time.Duration(expireAt - now))
 			}
-			runExpire(txn.db, 1, "", 0)
+			runExpire(txn.db, 1, 0, "", 0)
 			for i := 0; i < EXPIRE_HASH_NUM; i++ {
 				expireHash := fmt.Sprintf("%04d", i)
-				runExpire(txn.db, 1, expireHash, 0)
+				runExpire(txn.db, 1, 0, expireHash, 0)
 			}
 			txn.Commit(context.TODO())
 
@@ -230,6 +231,134 @@ func Test_runExpire(t *testing.T) {
 	}
 }
 
+func TestExpireAtCoalesce(t *testing.T) {
+	db := MockDB()
+	mkey := MetaKey(db, []byte("coalesce"))
+	id := UUID()
+	sec := int64(time.Second)
+	oldAt := time.Now().Add(time.Hour).UnixNano()
+	oldAt = oldAt - oldAt%sec
+
+	hasKey := func(txn *Transaction, key []byte) bool {
+		_, err := txn.t.Get(key)
+		return !store.IsErrNotFound(err)
+	}
+
+	txn, err := db.Begin()
+	assert.NoError(t, err)
+	assert.NoError(t, expireAt(txn.t, mkey, id, ObjectString, 0, oldAt))
+	assert.NoError(t, txn.Commit(context.TODO()))
+
+	// a reset within the same second keeps the old index entry
+	txn, err = db.Begin()
+	assert.NoError(t, err)
+	assert.NoError(t, expireAt(txn.t, mkey, id, ObjectString, oldAt, oldAt+sec/2))
+	assert.True(t, hasKey(txn, expireKey(mkey, oldAt)))
+	assert.False(t, hasKey(txn, expireKey(mkey, oldAt+sec/2)))
+	assert.NoError(t, txn.Commit(context.TODO()))
+
+	// a reset into another second rewrites the entry as before
+	txn, err = db.Begin()
+	assert.NoError(t, err)
+	assert.NoError(t, expireAt(txn.t, mkey, id, ObjectString, oldAt, oldAt+sec))
+	assert.False(t, hasKey(txn, expireKey(mkey, oldAt)))
+	assert.True(t, hasKey(txn, expireKey(mkey, oldAt+sec)))
+	assert.NoError(t, txn.Commit(context.TODO()))
+}
+
+func TestLeaderStatus(t *testing.T) {
+	ls := NewLeaderStatus()
+	elected := 0
+	resigned := 0
+	ls.OnElected(func() { elected++ })
+	ls.OnResigned(func() { resigned++ })
+
+	assert.False(t, ls.getIsLeader())
+
+	ls.setIsLeader(true)
+	assert.True(t, ls.getIsLeader())
+	assert.Equal(t, 1, elected)
+
+	// callbacks only fire on transitions
+	ls.setIsLeader(true)
+	assert.Equal(t, 1, elected)
+
+	ls.setIsLeader(false)
+	assert.False(t, ls.getIsLeader())
+	assert.Equal(t, 1, resigned)
+}
+
+func TestExpireBucketGroup(t *testing.T) {
+	for _, leaderNum := range []int{1, 2, 3, 256} {
+		prev := 0
+		counts := make([]int, leaderNum)
+		for bucket := 0; bucket < EXPIRE_HASH_NUM; bucket++ {
+			group := expireBucketGroup(bucket, leaderNum)
+			// groups grow monotonically, so every group owns a contiguous range
+			assert.True(t, group == prev || group == prev+1)
+			assert.True(t, group >= 0 && group < leaderNum)
+			counts[group]++
+			prev = group
+		}
+		for _, count := range counts {
+			assert.True(t, count >= EXPIRE_HASH_NUM/leaderNum)
+		}
+	}
+	assert.Equal(t, []byte("$sys:0:EXL:EXLeader"), expireLeaderKey(0))
+	assert.Equal(t, []byte("$sys:0:EXL:EXLeader:1"), expireLeaderKey(1))
+}
+
+func TestRunExpireNamespaceLimit(t *testing.T) {
+	db := MockDB()
+	lightDB := &DB{Namespace: "light", ID: DBID(1), kv: db.kv}
+
+	// write a string whose expire entry sits in the legacy flat index, so
+	// one runExpire round covers every namespace
+	writeString := func(db *DB, key string, at int64) {
+		txn, err := db.Begin()
+		assert.NoError(t, err)
+		str, err := GetString(txn, []byte(key))
+		assert.NoError(t, err)
+		assert.NoError(t, str.Set([]byte("value"), 0))
+		// stamp the meta directly, ExpireAt destroys keys already in the past
+		str.Meta.ExpireAt = at
+		mkey := MetaKey(txn.db, []byte(key))
+		encoded, err := str.encode()
+		assert.NoError(t, err)
+		assert.NoError(t, txn.t.Set(mkey, encoded))
+		assert.NoError(t, txn.t.Set(keyspace.ExpireKey("", EncodeInt64(at), mkey), str.Meta.ID))
+		assert.NoError(t, txn.Commit(context.TODO()))
+	}
+
+	exists := func(db *DB, key string) bool {
+		txn, err := db.Begin()
+		assert.NoError(t, err)
+		_, err = txn.t.Get(MetaKey(db, []byte(key)))
+		txn.Commit(context.TODO())
+		return !store.IsErrNotFound(err)
+	}
+
+	base := time.Now().UnixNano() - 10*int64(time.Second)
+	writeString(db, "heavy1", base+1)
+	writeString(lightDB, "light1", base+2)
+	writeString(db, "heavy2", base+3)
+	writeString(db, "heavy3", base+4)
+
+	// with a cap of one key per namespace the first round expires one
+	// heavy key and the light one, the skipped heavy keys survive
+	endTs := runExpire(db, 10, 1, "", 0)
+	assert.False(t, exists(db, "heavy1"))
+	assert.False(t, exists(lightDB, "light1"))
+	assert.True(t, exists(db, "heavy2"))
+	assert.True(t, exists(db, "heavy3"))
+
+	// following rounds seek back to the skipped keys and drain them
+	endTs = runExpire(db, 10, 1, "", endTs)
+	runExpire(db, 10, 1, "", endTs)
+	assert.False(t, exists(db, "heavy2"))
+	assert.False(t, exists(db, "heavy3"))
+}
+
 func Test_setExpired_doExpire(t *testing.T) {
 	initHash := func(t *testing.T, key []byte) []byte {
 		hash, txn, err := getHash(t, key)
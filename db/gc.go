@@ -5,7 +5,9 @@ import (
 	"time"
 
 	"github.com/distributedio/titan/conf"
+	"github.com/distributedio/titan/db/keyspace"
 	"github.com/distributedio/titan/db/store"
+	"github.com/distributedio/titan/failpoint"
 	"github.com/distributedio/titan/metrics"
 	"github.com/pingcap/tidb/kv"
 	"go.uber.org/zap"
@@ -20,12 +22,7 @@ const (
 )
 
 func toTikvGCKey(key []byte) []byte {
-	b := []byte{}
-	b = append(b, sysNamespace...)
-	b = append(b, ':', byte(sysDatabaseID))
-	b = append(b, ':', 'G', 'C', ':')
-	b = append(b, key...)
-	return b
+	return keyspace.GCKey(key)
 }
 
 // {sys.ns}:{sys.id}:{GC}:{prefix}
@@ -76,6 +73,9 @@ func gcDeleteRange(txn store.Transaction, prefix []byte, limit int) (int, error)
 }
 
 func doGC(db *DB, limit int) error {
+	if err := failpoint.Error("gc-round"); err != nil {
+		return err
+	}
 	gcPrefix := toTikvGCKey(nil)
 	endGCPrefix := kv.Key(gcPrefix).PrefixNext()
 	dbTxn, err := db.Begin()
@@ -160,8 +160,8 @@ func doGC(db *DB, limit int) error {
 }
 
 // StartGC start gc
-//1.获取leader许可
-//2.leader 执行清理任务
+// 1.获取leader许可
+// 2.leader 执行清理任务
 func StartGC(db *DB, conf *conf.GC) {
 	ticker := time.NewTicker(conf.Interval)
 	defer ticker.Stop()
@@ -13,12 +13,12 @@ type HashMeta struct {
 	Object
 }
 
-//EncodeHashMeta encodes meta data into byte slice
+// EncodeHashMeta encodes meta data into byte slice
 func EncodeHashMeta(meta *HashMeta) []byte {
 	return EncodeObject(&meta.Object)
 }
 
-//DecodeHashMeta decode meta data into meta field
+// DecodeHashMeta decode meta data into meta field
 func DecodeHashMeta(b []byte) (*HashMeta, error) {
 	obj, err := DecodeObject(b)
 	if err != nil {
@@ -46,22 +46,29 @@ func GetHash(txn *Transaction, key []byte) (*Hash, error) {
 		}
 		return nil, err
 	}
-	hmeta, err := DecodeHashMeta(meta)
+	objType, expireAt, err := peekMeta(meta)
 	if err != nil {
 		return nil, err
 	}
-	if IsExpired(&hmeta.Object, Now()) {
+	if expireAt != 0 && expireAt <= Now() {
+		if obj, err := DecodeObject(meta); err == nil {
+			tryLazyExpire(txn.db, key, obj)
+		}
 		return hash, nil
 	}
-	if hmeta.Type != ObjectHash {
+	if objType != ObjectHash {
 		return nil, ErrTypeMismatch
 	}
+	hmeta, err := DecodeHashMeta(meta)
+	if err != nil {
+		return nil, err
+	}
 	hash.meta = hmeta
 	hash.exists = true
 	return hash, nil
 }
 
-//newHash creates a hash object
+// newHash creates a hash object
 func newHash(txn *Transaction, key []byte) *Hash {
 	now := Now()
 	return &Hash{
@@ -80,7 +87,7 @@ func newHash(txn *Transaction, key []byte) *Hash {
 	}
 }
 
-//hashItemKey spits field into metakey
+// hashItemKey spits field into metakey
 func hashItemKey(key []byte, field []byte) []byte {
 	var dkey []byte
 	dkey = append(dkey, key...)
@@ -156,7 +163,11 @@ func (hash *Hash) HSet(field []byte, value []byte) (int, error) {
 		newField = true
 	}
 
-	if err := hash.txn.t.Set(ikey, value); err != nil {
+	sealed, err := hash.txn.sealValue(value)
+	if err != nil {
+		return 0, err
+	}
+	if err := hash.txn.t.Set(ikey, sealed); err != nil {
 		return 0, err
 	}
 
@@ -181,7 +192,11 @@ func (hash *Hash) HSetNX(field []byte, value []byte) (int, error) {
 	if !IsErrNotFound(err) {
 		return 0, err
 	}
-	if err := hash.txn.t.Set(ikey, value); err != nil {
+	sealed, err := hash.txn.sealValue(value)
+	if err != nil {
+		return 0, err
+	}
+	if err := hash.txn.t.Set(ikey, sealed); err != nil {
 		return 0, err
 	}
 
@@ -208,7 +223,7 @@ func (hash *Hash) HGet(field []byte) ([]byte, error) {
 		}
 		return nil, err
 	}
-	return val, nil
+	return hash.txn.openValue(val), nil
 }
 
 // HGetAll returns all fields and values of the hash stored at key
@@ -227,7 +242,7 @@ func (hash *Hash) HGetAll() ([][]byte, [][]byte, error) {
 	var vals [][]byte
 	for iter.Valid() && iter.Key().HasPrefix(prefix) {
 		fields = append(fields, []byte(iter.Key()[len(prefix):]))
-		vals = append(vals, iter.Value())
+		vals = append(vals, hash.txn.openValue(iter.Value()))
 		if err := iter.Next(); err != nil {
 			return nil, nil, err
 		}
@@ -264,7 +279,7 @@ func (hash *Hash) HIncrBy(field []byte, v int64) (int64, error) {
 				return 0, err
 			}
 		} else {
-			n, err = strconv.ParseInt(string(val), 10, 64)
+			n, err = strconv.ParseInt(string(hash.txn.openValue(val)), 10, 64)
 			if err != nil {
 				return 0, errors.New("hash value is not an integer")
 			}
@@ -274,7 +289,11 @@ func (hash *Hash) HIncrBy(field []byte, v int64) (int64, error) {
 	n += v
 
 	val := []byte(strconv.FormatInt(n, 10))
-	if err := hash.txn.t.Set(ikey, val); err != nil {
+	sealed, err := hash.txn.sealValue(val)
+	if err != nil {
+		return 0, err
+	}
+	if err := hash.txn.t.Set(ikey, sealed); err != nil {
 		return 0, err
 	}
 
@@ -300,7 +319,7 @@ func (hash *Hash) HIncrByFloat(field []byte, v float64) (float64, error) {
 				return 0, err
 			}
 		} else {
-			n, err = strconv.ParseFloat(string(val), 64)
+			n, err = strconv.ParseFloat(string(hash.txn.openValue(val)), 64)
 			if err != nil {
 				return 0, errors.New("hash value is not an float")
 			}
@@ -310,7 +329,11 @@ func (hash *Hash) HIncrByFloat(field []byte, v float64) (float64, error) {
 	n += v
 
 	val := []byte(strconv.FormatFloat(n, 'f', -1, 64))
-	if err := hash.txn.t.Set(ikey, val); err != nil {
+	sealed, err := hash.txn.sealValue(val)
+	if err != nil {
+		return 0, err
+	}
+	if err := hash.txn.t.Set(ikey, sealed); err != nil {
 		return 0, err
 	}
 
@@ -360,7 +383,16 @@ func (hash *Hash) HMGet(fields [][]byte) ([][]byte, error) {
 		ikeys[i] = hashItemKey(dkey, fields[i])
 	}
 
-	return BatchGetValues(hash.txn, ikeys)
+	values, err := BatchGetValues(hash.txn, ikeys)
+	if err != nil {
+		return nil, err
+	}
+	for i := range values {
+		if values[i] != nil {
+			values[i] = hash.txn.openValue(values[i])
+		}
+	}
+	return values, nil
 }
 
 // HMSet sets the specified fields to their respective values in the hash stored at key
@@ -373,7 +405,11 @@ func (hash *Hash) HMSet(fields, values [][]byte) error {
 	dkey := DataKey(hash.txn.db, hash.meta.ID)
 	for i := range fields {
 		ikey := hashItemKey(dkey, fields[i])
-		if err := hash.txn.t.Set(ikey, values[i]); err != nil {
+		sealed, err := hash.txn.sealValue(values[i])
+		if err != nil {
+			return err
+		}
+		if err := hash.txn.t.Set(ikey, sealed); err != nil {
 			return err
 		}
 		if oldValues[i] == nil {
@@ -406,7 +442,7 @@ func (hash *Hash) HScan(cursor []byte, f func(key, val []byte) bool) error {
 	}
 	for iter.Valid() && iter.Key().HasPrefix(prefix) {
 		key := iter.Key()
-		if !f(key[len(prefix):], iter.Value()) {
+		if !f(key[len(prefix):], hash.txn.openValue(iter.Value())) {
 			break
 		}
 		if err := iter.Next(); err != nil {
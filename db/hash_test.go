@@ -100,7 +100,7 @@ func getHashMeta(t *testing.T, txn *Transaction, key []byte) *HashMeta {
 	return meta
 }
 
-//删除设置的meta信息
+// 删除设置的meta信息
 func destoryHashMeta(t *testing.T, txn *Transaction, key []byte) error {
 	metakey := MetaKey(txn.db, key)
 	if err := txn.t.Delete(metakey); err != nil {
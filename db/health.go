@@ -0,0 +1,59 @@
+package db
+
+import (
+	"sync/atomic"
+	"time"
+
+	"github.com/distributedio/titan/metrics"
+)
+
+// commit health trackers of the node, every transaction reports here no
+// matter which db it belongs to. A node that still accepts connections but
+// cannot reach pd or commit to tikv shows up as a stale last commit
+// timestamp and a growing error streak, external monitors can pull it
+// from the load balancer on that signal
+var (
+	lastCommitUnixNano int64
+	commitErrStreak    int64
+	beginErrStreak     int64
+)
+
+// recordBegin tracks the outcome of fetching a start timestamp from pd
+func recordBegin(err error) {
+	if b := globalBreaker; b != nil {
+		b.observe(err)
+	}
+	if err != nil {
+		metrics.GetMetrics().BeginErrStreakGauge.Set(float64(atomic.AddInt64(&beginErrStreak, 1)))
+		return
+	}
+	atomic.StoreInt64(&beginErrStreak, 0)
+	metrics.GetMetrics().BeginErrStreakGauge.Set(0)
+}
+
+// recordCommit tracks the outcome of a transaction commit
+func recordCommit(err error) {
+	if b := globalBreaker; b != nil {
+		b.observe(err)
+	}
+	if err != nil {
+		metrics.GetMetrics().CommitErrStreakGauge.Set(float64(atomic.AddInt64(&commitErrStreak, 1)))
+		return
+	}
+	now := time.Now().UnixNano()
+	atomic.StoreInt64(&lastCommitUnixNano, now)
+	atomic.StoreInt64(&commitErrStreak, 0)
+	mt := metrics.GetMetrics()
+	mt.LastCommitGauge.Set(float64(now) / float64(time.Second))
+	mt.CommitErrStreakGauge.Set(0)
+}
+
+// CommitHealth returns the time of the last successful commit of this node
+// and the current commit and begin error streaks, the time is zero when no
+// commit succeeded since the process started
+func CommitHealth() (lastCommit time.Time, commitStreak, beginStreak int64) {
+	if ns := atomic.LoadInt64(&lastCommitUnixNano); ns != 0 {
+		lastCommit = time.Unix(0, ns)
+	}
+	return lastCommit, atomic.LoadInt64(&commitErrStreak), atomic.LoadInt64(&beginErrStreak)
+}
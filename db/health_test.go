@@ -0,0 +1,38 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCommitHealth(t *testing.T) {
+	txn, err := mockDB.Begin()
+	assert.NoError(t, err)
+	assert.NoError(t, txn.Commit(context.TODO()))
+
+	lastCommit, commitStreak, beginStreak := CommitHealth()
+	assert.False(t, lastCommit.IsZero())
+	assert.True(t, time.Since(lastCommit) < time.Minute)
+	assert.Equal(t, int64(0), commitStreak)
+	assert.Equal(t, int64(0), beginStreak)
+
+	recordCommit(errors.New("region unavailable"))
+	recordCommit(errors.New("region unavailable"))
+	recordBegin(errors.New("pd unreachable"))
+	lastCommit, commitStreak, beginStreak = CommitHealth()
+	assert.False(t, lastCommit.IsZero())
+	assert.Equal(t, int64(2), commitStreak)
+	assert.Equal(t, int64(1), beginStreak)
+
+	// a successful begin and commit reset both streaks
+	txn, err = mockDB.Begin()
+	assert.NoError(t, err)
+	assert.NoError(t, txn.Commit(context.TODO()))
+	_, commitStreak, beginStreak = CommitHealth()
+	assert.Equal(t, int64(0), commitStreak)
+	assert.Equal(t, int64(0), beginStreak)
+}
@@ -0,0 +1,58 @@
+package db
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"sync/atomic"
+	"time"
+
+	"github.com/satori/go.uuid"
+)
+
+// IDGenerator allocates 16-byte object IDs. The process-wide generator is
+// random by default, the monotonic mode makes IDs sortable by creation
+// time which the reaper, CDC and debugging tools can exploit
+type IDGenerator interface {
+	ID() []byte
+}
+
+var idGenerator IDGenerator = randomIDGenerator{}
+
+// SetIDGenerator replaces the process-wide generator, call it before
+// opening a store so all objects of one process share a scheme
+func SetIDGenerator(g IDGenerator) {
+	idGenerator = g
+}
+
+// randomIDGenerator is the default, a v4 uuid per object
+type randomIDGenerator struct{}
+
+func (randomIDGenerator) ID() []byte { return uuid.NewV4().Bytes() }
+
+// MonotonicIDGenerator issues time-ordered IDs laid out as
+// {ts 8}{node 4}{counter 4}, all big endian, so byte order follows
+// creation order across processes with distinct node IDs
+type MonotonicIDGenerator struct {
+	node    uint32
+	counter uint32
+}
+
+// NewMonotonicIDGenerator creates a generator with the given node ID, a
+// random node ID is drawn when node is zero
+func NewMonotonicIDGenerator(node uint32) *MonotonicIDGenerator {
+	if node == 0 {
+		var b [4]byte
+		rand.Read(b[:])
+		node = binary.BigEndian.Uint32(b[:])
+	}
+	return &MonotonicIDGenerator{node: node}
+}
+
+// ID allocates the next object ID
+func (g *MonotonicIDGenerator) ID() []byte {
+	id := make([]byte, 16)
+	binary.BigEndian.PutUint64(id, uint64(time.Now().UnixNano()))
+	binary.BigEndian.PutUint32(id[8:], g.node)
+	binary.BigEndian.PutUint32(id[12:], atomic.AddUint32(&g.counter, 1))
+	return id
+}
@@ -0,0 +1,29 @@
+package db
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMonotonicIDGenerator(t *testing.T) {
+	g := NewMonotonicIDGenerator(42)
+	prev := g.ID()
+	assert.Len(t, prev, 16)
+	for i := 0; i < 1000; i++ {
+		id := g.ID()
+		assert.Len(t, id, 16)
+		assert.True(t, bytes.Compare(prev, id) < 0, "IDs must sort by creation order")
+		prev = id
+	}
+}
+
+func TestSetIDGenerator(t *testing.T) {
+	g := NewMonotonicIDGenerator(1)
+	SetIDGenerator(g)
+	defer SetIDGenerator(randomIDGenerator{})
+
+	a, b := UUID(), UUID()
+	assert.True(t, bytes.Compare(a, b) < 0)
+}
@@ -0,0 +1,261 @@
+// Package keyspace centralizes construction and parsing of the raw tikv
+// keys titan writes, so the byte layout lives in one place and can evolve
+// without hunting through the db modules.
+//
+// The user keyspace is laid out as
+//
+//	meta key:          {namespace}:{dbid}:M:{key}
+//	data key:          {namespace}:{dbid}:D:{objectID}
+//	zset member key:   {datakey}:M:{member}
+//	zset score key:    {datakey}:S:{score}:{member}
+//	zset member ttl:   {datakey}:E:{member}
+//
+// where dbid is the 3 digit decimal form of the database ID. A namespace
+// that has been flushed carries its flush generation in the namespace
+// section as {namespace}@{generation}, generation zero is the bare name.
+// System keys live under the $sys namespace
+//
+//	expire key:        $sys:0:at{hash}:{ts}:{metakey}
+//	member expire key: $sys:0:mat:{ts}:{memberkey}
+//	gc key:            $sys:0:GC:{prefix}
+//	sequence key:      $sys:0:seq:{namespace}:{dbid}:{name}
+//	limiter status:    limiter_status:{namespace}:{command}:
+//
+// where hash is a 4 digit bucket for the hashed expire index and empty for
+// the legacy flat one, and ts is a big endian encoded int64. The gc key
+// carries the sys database ID as a raw byte, not in decimal form, which is
+// kept for compatibility with data written by older versions
+package keyspace
+
+import (
+	"bytes"
+	"strconv"
+)
+
+// Separator joins the sections of every key
+const Separator = ':'
+
+// GenerationTag joins a namespace and its flush generation
+const GenerationTag = '@'
+
+// Section tags of the user keyspace
+const (
+	MetaTag   = 'M'
+	DataTag   = 'D'
+	ScoreTag  = 'S'
+	ExpireTag = 'E'
+)
+
+// GenerationNamespace returns the namespace section of keys carrying a
+// flush generation. Generation zero is the bare name, so data written
+// before generations existed stays addressable
+func GenerationNamespace(namespace []byte, gen uint32) []byte {
+	if gen == 0 {
+		return namespace
+	}
+	ns := make([]byte, 0, len(namespace)+11)
+	ns = append(ns, namespace...)
+	ns = append(ns, GenerationTag)
+	return strconv.AppendUint(ns, uint64(gen), 10)
+}
+
+// DBPrefix returns the prefix covering every key of a database, id may be
+// nil to cover a whole namespace
+func DBPrefix(namespace, id []byte) []byte {
+	prefix := make([]byte, 0, len(namespace)+len(id)+2)
+	prefix = append(prefix, namespace...)
+	prefix = append(prefix, Separator)
+	if id != nil {
+		prefix = append(prefix, id...)
+		prefix = append(prefix, Separator)
+	}
+	return prefix
+}
+
+// AppendMetaKey appends the meta key of a user key to buf, hot paths use
+// it to reuse a scratch buffer instead of allocating per key
+func AppendMetaKey(buf, namespace, id, key []byte) []byte {
+	buf = append(buf, namespace...)
+	buf = append(buf, Separator)
+	buf = append(buf, id...)
+	buf = append(buf, Separator, MetaTag, Separator)
+	return append(buf, key...)
+}
+
+// MetaKey builds the meta key of a user key
+func MetaKey(namespace, id, key []byte) []byte {
+	return AppendMetaKey(make([]byte, 0, len(namespace)+len(id)+len(key)+4), namespace, id, key)
+}
+
+// AppendDataKey appends the data key of an object ID to buf, see
+// AppendMetaKey
+func AppendDataKey(buf, namespace, id, objID []byte) []byte {
+	buf = append(buf, namespace...)
+	buf = append(buf, Separator)
+	buf = append(buf, id...)
+	buf = append(buf, Separator, DataTag, Separator)
+	return append(buf, objID...)
+}
+
+// DataKey builds the data key of an object ID
+func DataKey(namespace, id, objID []byte) []byte {
+	return AppendDataKey(make([]byte, 0, len(namespace)+len(id)+len(objID)+4), namespace, id, objID)
+}
+
+// SplitMetaKey parses a meta key back into namespace, the 3 digit database
+// ID and the user key
+func SplitMetaKey(key []byte) (namespace, id, userkey []byte) {
+	idx := bytes.IndexByte(key, Separator)
+	namespace = key[:idx]
+	id = key[idx+1 : idx+4]
+	userkey = key[idx+7:]
+	return namespace, id, userkey
+}
+
+// ZSetMemberKey builds the member key of a zset data key
+func ZSetMemberKey(dkey, member []byte) []byte {
+	memberKey := make([]byte, 0, len(dkey)+len(member)+3)
+	memberKey = append(memberKey, dkey...)
+	memberKey = append(memberKey, Separator, MetaTag, Separator)
+	memberKey = append(memberKey, member...)
+	return memberKey
+}
+
+// ZSetMemberPrefix builds the prefix covering every member key of a zset
+func ZSetMemberPrefix(dkey []byte) []byte {
+	prefix := make([]byte, 0, len(dkey)+3)
+	prefix = append(prefix, dkey...)
+	return AppendZSetMemberTag(prefix)
+}
+
+// AppendZSetMemberTag appends the member section tag to a data key already
+// in buf, see AppendMetaKey
+func AppendZSetMemberTag(buf []byte) []byte {
+	return append(buf, Separator, MetaTag, Separator)
+}
+
+// AppendZSetScoreTag appends the score section tag to a data key already
+// in buf, see AppendMetaKey
+func AppendZSetScoreTag(buf []byte) []byte {
+	return append(buf, Separator, ScoreTag, Separator)
+}
+
+// ZSetScorePrefix builds the prefix covering every score key of a zset
+func ZSetScorePrefix(dkey []byte) []byte {
+	prefix := make([]byte, 0, len(dkey)+3)
+	prefix = append(prefix, dkey...)
+	return AppendZSetScoreTag(prefix)
+}
+
+// ZSetScoreKey builds the score key of a zset member, score is already
+// encoded by the zset score codec
+func ZSetScoreKey(dkey, score, member []byte) []byte {
+	scoreKey := make([]byte, 0, len(dkey)+len(score)+len(member)+4)
+	scoreKey = append(scoreKey, dkey...)
+	scoreKey = AppendZSetScoreTag(scoreKey)
+	scoreKey = append(scoreKey, score...)
+	scoreKey = append(scoreKey, Separator)
+	scoreKey = append(scoreKey, member...)
+	return scoreKey
+}
+
+// ZSetMemberExpireKey builds the ttl record key of a zset member
+func ZSetMemberExpireKey(dkey, member []byte) []byte {
+	ekey := make([]byte, 0, len(dkey)+len(member)+3)
+	ekey = append(ekey, dkey...)
+	ekey = append(ekey, Separator, ExpireTag, Separator)
+	ekey = append(ekey, member...)
+	return ekey
+}
+
+// objectIDLength is the length of an object ID, see db.UUID
+const objectIDLength = 16
+
+// SplitZSetMemberKey parses a zset member key back into the data key of the
+// zset and the member, ok is false when the key does not look like one
+func SplitZSetMemberKey(memberKey []byte) (dkey, member []byte, ok bool) {
+	idx := bytes.Index(memberKey, []byte{Separator, DataTag, Separator})
+	if idx < 0 {
+		return nil, nil, false
+	}
+	end := idx + 3 + objectIDLength
+	if len(memberKey) <= end+3 || memberKey[end] != Separator ||
+		memberKey[end+1] != MetaTag || memberKey[end+2] != Separator {
+		return nil, nil, false
+	}
+	return memberKey[:end], memberKey[end+3:], true
+}
+
+// sysPrefix is the prefix of the system keyspace
+var sysPrefix = []byte("$sys:0:")
+
+// ExpireKey builds an expire index key, hash is the 4 digit bucket of the
+// hashed index or empty for the legacy flat one, ts is the big endian
+// encoded expiration timestamp
+func ExpireKey(hash string, ts, mkey []byte) []byte {
+	key := make([]byte, 0, len(sysPrefix)+len(hash)+len(ts)+len(mkey)+4)
+	key = append(key, sysPrefix...)
+	key = append(key, 'a', 't')
+	key = append(key, hash...)
+	key = append(key, Separator)
+	key = append(key, ts...)
+	key = append(key, Separator)
+	key = append(key, mkey...)
+	return key
+}
+
+// MemberExpirePrefix returns the prefix covering the whole member expire
+// index
+func MemberExpirePrefix() []byte {
+	prefix := make([]byte, 0, len(sysPrefix)+4)
+	prefix = append(prefix, sysPrefix...)
+	prefix = append(prefix, 'm', 'a', 't', Separator)
+	return prefix
+}
+
+// MemberExpireKey builds a member expire index key, ts is the big endian
+// encoded expiration timestamp and memberKey the zset member key to remove
+func MemberExpireKey(ts, memberKey []byte) []byte {
+	key := make([]byte, 0, len(sysPrefix)+len(ts)+len(memberKey)+5)
+	key = append(key, sysPrefix...)
+	key = append(key, 'm', 'a', 't', Separator)
+	key = append(key, ts...)
+	key = append(key, Separator)
+	key = append(key, memberKey...)
+	return key
+}
+
+// GCKey builds the gc key of a doomed key prefix. The database ID is a raw
+// zero byte here, see the package comment
+func GCKey(prefix []byte) []byte {
+	key := make([]byte, 0, len(prefix)+10)
+	key = append(key, "$sys"...)
+	key = append(key, Separator, 0)
+	key = append(key, Separator, 'G', 'C', Separator)
+	key = append(key, prefix...)
+	return key
+}
+
+// SequenceKey builds the counter key of a named sequence, namespace and
+// the 3 digit database ID scope the name like user keys
+func SequenceKey(namespace, id, name []byte) []byte {
+	key := make([]byte, 0, len(sysPrefix)+len(namespace)+len(id)+len(name)+6)
+	key = append(key, sysPrefix...)
+	key = append(key, 's', 'e', 'q', Separator)
+	key = append(key, namespace...)
+	key = append(key, Separator)
+	key = append(key, id...)
+	key = append(key, Separator)
+	key = append(key, name...)
+	return key
+}
+
+// LimiterKey builds the status key prefix of a rate limiter,
+// limiterName is {namespace}:{command}
+func LimiterKey(limiterName string) []byte {
+	key := make([]byte, 0, len("limiter_status:")+len(limiterName)+1)
+	key = append(key, "limiter_status:"...)
+	key = append(key, limiterName...)
+	key = append(key, Separator)
+	return key
+}
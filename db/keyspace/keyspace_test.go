@@ -0,0 +1,129 @@
+package keyspace
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var (
+	ns  = []byte("ns")
+	id  = []byte("001")
+	key = []byte("key")
+)
+
+func TestDBPrefix(t *testing.T) {
+	assert.Equal(t, []byte("ns:001:"), DBPrefix(ns, id))
+	assert.Equal(t, []byte("ns:"), DBPrefix(ns, nil))
+}
+
+func TestMetaKey(t *testing.T) {
+	assert.Equal(t, []byte("ns:001:M:key"), MetaKey(ns, id, key))
+}
+
+func TestDataKey(t *testing.T) {
+	assert.Equal(t, []byte("ns:001:D:objid"), DataKey(ns, id, []byte("objid")))
+}
+
+func TestSplitMetaKey(t *testing.T) {
+	gotNS, gotID, gotKey := SplitMetaKey(MetaKey(ns, id, key))
+	assert.Equal(t, ns, gotNS)
+	assert.Equal(t, id, gotID)
+	assert.Equal(t, key, gotKey)
+
+	// keys containing the separator survive the round trip
+	raw := []byte("a:b:c")
+	_, _, gotKey = SplitMetaKey(MetaKey(ns, id, raw))
+	assert.Equal(t, raw, gotKey)
+}
+
+func TestZSetKeys(t *testing.T) {
+	dkey := DataKey(ns, id, []byte("objid"))
+	assert.Equal(t, []byte("ns:001:D:objid:M:m1"), ZSetMemberKey(dkey, []byte("m1")))
+	assert.Equal(t, []byte("ns:001:D:objid:S:"), ZSetScorePrefix(dkey))
+	assert.Equal(t, []byte("ns:001:D:objid:S:score:m1"), ZSetScoreKey(dkey, []byte("score"), []byte("m1")))
+}
+
+// TestZSetKeysNoAliasing guards against builders appending into the spare
+// capacity of their input: every key built from a shared data key must own
+// its bytes, an aliasing builder would corrupt earlier keys here
+func TestZSetKeysNoAliasing(t *testing.T) {
+	// a data key with plenty of spare capacity to scribble over
+	dkey := make([]byte, 0, 1024)
+	dkey = append(dkey, DataKey(ns, id, []byte("objid"))...)
+
+	var keys [][]byte
+	var want []string
+	for i := 0; i < 32; i++ {
+		score := []byte{'s', byte('0' + i%10)}
+		member := []byte{'m', byte('0' + i%10)}
+		keys = append(keys, ZSetScoreKey(dkey, score, member))
+		want = append(want, "ns:001:D:objid:S:"+string(score)+":"+string(member))
+		keys = append(keys, ZSetMemberKey(dkey, member))
+		want = append(want, "ns:001:D:objid:M:"+string(member))
+		keys = append(keys, ZSetMemberExpireKey(dkey, member))
+		want = append(want, "ns:001:D:objid:E:"+string(member))
+		keys = append(keys, ZSetScorePrefix(dkey))
+		want = append(want, "ns:001:D:objid:S:")
+	}
+	for i := range keys {
+		assert.Equal(t, want[i], string(keys[i]))
+	}
+	assert.Equal(t, []byte("ns:001:D:objid"), dkey)
+}
+
+func TestExpireKey(t *testing.T) {
+	ts := []byte{0, 0, 0, 0, 0, 0, 0, 1}
+	mkey := MetaKey(ns, id, key)
+
+	want := append([]byte("$sys:0:at0042:"), ts...)
+	want = append(want, ':')
+	want = append(want, mkey...)
+	assert.Equal(t, want, ExpireKey("0042", ts, mkey))
+
+	// the legacy flat index has no hash bucket
+	want = append([]byte("$sys:0:at:"), ts...)
+	want = append(want, ':')
+	want = append(want, mkey...)
+	assert.Equal(t, want, ExpireKey("", ts, mkey))
+}
+
+func TestGCKey(t *testing.T) {
+	// the sys database ID of a gc key is a raw zero byte
+	want := append([]byte("$sys:"), 0)
+	want = append(want, []byte(":GC:ns:001:D:objid")...)
+	assert.Equal(t, want, GCKey(DataKey(ns, id, []byte("objid"))))
+}
+
+func TestLimiterKey(t *testing.T) {
+	assert.Equal(t, []byte("limiter_status:ns@get:"), LimiterKey("ns@get"))
+}
+
+func TestZSetMemberExpireKey(t *testing.T) {
+	dkey := DataKey(ns, id, []byte("objid"))
+	assert.Equal(t, []byte("ns:001:D:objid:E:m1"), ZSetMemberExpireKey(dkey, []byte("m1")))
+}
+
+func TestMemberExpireKey(t *testing.T) {
+	ts := []byte{0, 0, 0, 0, 0, 0, 0, 1}
+	memberKey := []byte("ns:001:D:objid:M:m1")
+
+	want := append([]byte("$sys:0:mat:"), ts...)
+	want = append(want, ':')
+	want = append(want, memberKey...)
+	assert.Equal(t, want, MemberExpireKey(ts, memberKey))
+}
+
+func TestSplitZSetMemberKey(t *testing.T) {
+	objID := []byte("0123456789abcdef")
+	dkey := DataKey(ns, id, objID)
+	member := []byte("m:1")
+
+	gotDkey, gotMember, ok := SplitZSetMemberKey(ZSetMemberKey(dkey, member))
+	assert.True(t, ok)
+	assert.Equal(t, dkey, gotDkey)
+	assert.Equal(t, member, gotMember)
+
+	_, _, ok = SplitZSetMemberKey([]byte("garbage"))
+	assert.False(t, ok)
+}
@@ -6,12 +6,15 @@ import (
 	"errors"
 	"math/rand"
 	"sync"
+	"time"
 
 	"github.com/distributedio/titan/db/store"
+	"github.com/distributedio/titan/metrics"
 	"github.com/pingcap/kvproto/pkg/kvrpcpb"
 	"github.com/pingcap/kvproto/pkg/metapb"
 	sdk_kv "github.com/pingcap/tidb/kv"
 	"github.com/pingcap/tidb/store/tikv"
+	"github.com/pingcap/tidb/store/tikv/oracle"
 	"github.com/pingcap/tidb/store/tikv/tikvrpc"
 	"go.uber.org/zap"
 )
@@ -58,6 +61,89 @@ func (kv *Kv) Keys(start []byte, f func(key []byte) bool) error {
 	return nil
 }
 
+// KeysAtVersion iterates keys like Keys but reads from a snapshot pinned
+// at ver, so consecutive scans sharing the version enumerate a consistent
+// view of the db while writes continue. Reading below the tikv gc safe
+// point fails
+func (kv *Kv) KeysAtVersion(ver uint64, start []byte, f func(key []byte) bool) error {
+	snap, err := kv.txn.db.kv.GetSnapshot(sdk_kv.Version{Ver: ver})
+	if err != nil {
+		return err
+	}
+	mkey := MetaKey(kv.txn.db, start)
+	prefix := MetaKey(kv.txn.db, nil)
+	endPrefix := sdk_kv.Key(prefix).PrefixNext()
+	iter, err := snap.Iter(mkey, endPrefix)
+	if err != nil {
+		return err
+	}
+	defer iter.Close()
+
+	now := Now()
+	for iter.Valid() {
+		key := iter.Key()
+		if !bytes.HasPrefix(key, prefix) {
+			break
+		}
+
+		obj, err := DecodeObject(iter.Value())
+		if err != nil {
+			return err
+		}
+		if !IsExpired(obj, now) && !f(key[len(prefix):]) {
+			break
+		}
+		if err := iter.Next(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// VersionExpired reports whether a pinned scan version has outlived the
+// tikv gc safe point window of the store and may no longer be read
+func (kv *Kv) VersionExpired(ver uint64) bool {
+	lifeTime := kv.txn.db.kv.conf.TikvGC.SafePointLifeTime
+	pinnedAt := time.Unix(0, oracle.ExtractPhysical(ver)*int64(time.Millisecond))
+	return time.Since(pinnedAt) > lifeTime
+}
+
+// KeysByType iterates the keys of db holding an object of the given
+// type, skipping the others server side. Only the meta header is decoded
+// during the iteration, so scanning past namespaces dominated by other
+// types stays cheap
+func (kv *Kv) KeysByType(start []byte, expect ObjectType, f func(key []byte) bool) error {
+	mkey := MetaKey(kv.txn.db, start)
+	prefix := MetaKey(kv.txn.db, nil)
+	endPrefix := sdk_kv.Key(prefix).PrefixNext()
+	iter, err := kv.txn.t.Iter(mkey, endPrefix)
+	if err != nil {
+		return err
+	}
+	defer iter.Close()
+
+	now := Now()
+	for iter.Valid() {
+		key := iter.Key()
+		if !bytes.HasPrefix(key, prefix) {
+			break
+		}
+
+		objType, expireAt, err := decodeObjectHeader(iter.Value())
+		if err != nil {
+			return err
+		}
+		expired := expireAt > 0 && expireAt <= now
+		if objType == expect && !expired && !f(key[len(prefix):]) {
+			break
+		}
+		if err := iter.Next(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 // Delete specific keys, ignore if non exist
 func (kv *Kv) Delete(keys [][]byte) (int64, error) {
 	var (
@@ -132,13 +218,71 @@ func (kv *Kv) ExpireAt(key []byte, at int64) error {
 			return err
 		}
 	}
-	obj.ExpireAt = at
-	updated := EncodeObject(obj)
-	updated = append(updated, meta[ObjectEncodingLength:]...)
-	return kv.txn.t.Set(mkey, updated)
+	// only the ExpireAt field changes, patch it in place instead of
+	// re-encoding the meta so collection trailing fields are never touched
+	return kv.txn.t.Set(mkey, patchMetaExpireAt(meta, at))
+}
+
+// Object returns the live object associated with key, expired objects
+// count as missing. Key-generic commands should read metas through here
+// instead of fetching and filtering on their own
+func (kv *Kv) Object(key []byte) (*Object, error) {
+	return kv.txn.Object(key)
+}
+
+// TypedObject is like Object but also verifies the type, a live object of
+// another type yields ErrTypeMismatch and is counted per namespace
+func (kv *Kv) TypedObject(key []byte, expect ObjectType) (*Object, error) {
+	obj, err := kv.txn.Object(key)
+	if err != nil {
+		return nil, err
+	}
+	if obj.Type != expect {
+		metrics.GetMetrics().TypeMismatchCounterVec.WithLabelValues(metrics.NamespaceLabel(kv.txn.db.Namespace)).Inc()
+		return nil, ErrTypeMismatch
+	}
+	return obj, nil
+}
+
+// TTL returns the remaining life time in nanoseconds of every given key
+// using a single batch get of the meta keys. A missing or expired key
+// yields -2 and a key without timeout -1
+func (kv *Kv) TTL(keys [][]byte) ([]int64, error) {
+	now := Now()
+	mkeys := make([][]byte, len(keys))
+	for i, key := range keys {
+		mkeys[i] = MetaKey(kv.txn.db, key)
+	}
+
+	values, err := store.BatchGetValues(kv.txn.t, mkeys)
+	if err != nil {
+		return nil, err
+	}
+	ttls := make([]int64, len(keys))
+	for i := range keys {
+		val, ok := values[string(mkeys[i])]
+		if !ok || val == nil {
+			ttls[i] = -2
+			continue
+		}
+		obj, err := DecodeObject(val)
+		if err != nil {
+			return nil, err
+		}
+		if IsExpired(obj, now) {
+			ttls[i] = -2
+			continue
+		}
+		if obj.ExpireAt == 0 {
+			ttls[i] = -1
+			continue
+		}
+		ttls[i] = obj.ExpireAt - now
+	}
+	return ttls, nil
 }
 
-//Exists check if the given keys exist
+// Exists check if the given keys exist
 func (kv *Kv) Exists(keys [][]byte) (int64, error) {
 	var count int64
 	now := Now()
@@ -168,6 +312,11 @@ func (kv *Kv) Exists(keys [][]byte) (int64, error) {
 
 // FlushDB clear current db.
 func (kv *Kv) FlushDB(ctx context.Context) error {
+	if ok, err := kv.flushByGeneration(false); err != nil {
+		return err
+	} else if ok {
+		return nil
+	}
 	prefix := kv.txn.db.Prefix()
 	endPrefix := sdk_kv.Key(prefix).PrefixNext()
 	if err := unsafeDeleteRange(ctx, kv.txn.db, prefix, endPrefix); err != nil {
@@ -188,6 +337,11 @@ func (kv *Kv) FlushDB(ctx context.Context) error {
 
 // FlushAll clean up all databases.
 func (kv *Kv) FlushAll(ctx context.Context) error {
+	if ok, err := kv.flushByGeneration(true); err != nil {
+		return err
+	} else if ok {
+		return nil
+	}
 	prefix := dbPrefix(kv.txn.db.Namespace, nil)
 	endPrefix := sdk_kv.Key(prefix).PrefixNext()
 	if err := unsafeDeleteRange(ctx, kv.txn.db, prefix, endPrefix); err != nil {
@@ -204,6 +358,51 @@ func (kv *Kv) FlushAll(ctx context.Context) error {
 	return nil
 }
 
+// flushByGeneration flushes by bumping the flush generation of the
+// namespace, which moves every key builder to a fresh prefix at once and
+// leaves the outgoing range to the background gc. The caller pays O(1)
+// and clients see an empty keyspace immediately. It reports false when
+// the generation path does not apply: no registry is active, the
+// namespace is a reserved one, or a FLUSHDB namespace holds data in
+// other dbs which a namespace wide bump would orphan
+func (kv *Kv) flushByGeneration(allDBs bool) (bool, error) {
+	reg := GetNamespaceRegistry()
+	db := kv.txn.db
+	if reg == nil || IsReservedNamespace(db.Namespace) {
+		return false, nil
+	}
+	nsPrefix := dbPrefix(db.Namespace, nil)
+	nsEnd := []byte(sdk_kv.Key(nsPrefix).PrefixNext())
+	if !allDBs {
+		prefix := db.Prefix()
+		empty, err := kv.rangeEmpty(nsPrefix, prefix)
+		if err != nil || !empty {
+			return false, err
+		}
+		empty, err = kv.rangeEmpty(sdk_kv.Key(prefix).PrefixNext(), nsEnd)
+		if err != nil || !empty {
+			return false, err
+		}
+	}
+	if _, err := reg.BumpGeneration(db.Namespace, nsPrefix); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// rangeEmpty reports whether the store holds no key in [start, end)
+func (kv *Kv) rangeEmpty(start, end []byte) (bool, error) {
+	if bytes.Compare(start, end) >= 0 {
+		return true, nil
+	}
+	iter, err := kv.txn.t.Iter(start, end)
+	if err != nil {
+		return false, err
+	}
+	defer iter.Close()
+	return !iter.Valid(), nil
+}
+
 // RandomKey return a key from current db randomly
 // Now we use an static length(64) to generate the key spaces, it means it is random for keys
 // that len(key) <= 64, it is enough for most cases
@@ -215,6 +414,11 @@ func (kv *Kv) RandomKey() ([]byte, error) {
 	mkey := MetaKey(kv.txn.db, buf)
 	prefix := MetaKey(kv.txn.db, nil)
 
+	// only the key matters here, spare tikv the value transfer. The txn
+	// may outlive this call in a multi block, so restore the option
+	store.SetOption(kv.txn.t, store.KeyOnly, true)
+	defer store.DelOption(kv.txn.t, store.KeyOnly)
+
 	// Iter >= mkey
 	iter, err := kv.txn.t.Iter(mkey, nil)
 	if err != nil {
@@ -237,7 +441,7 @@ func (kv *Kv) RandomKey() ([]byte, error) {
 	return nil, err
 }
 
-//clear system range data(GC/ZT)
+// clear system range data(GC/ZT)
 func clearSysRangeData(ctx context.Context, db *DB, startKey, endKey []byte) error {
 	gcStart := toTikvGCKey(startKey)
 	gcEnd := toTikvGCKey(endKey)
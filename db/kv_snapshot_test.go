@@ -0,0 +1,60 @@
+package db
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestKeysAtVersion(t *testing.T) {
+	d := MockDB()
+
+	txn, err := d.Begin()
+	assert.NoError(t, err)
+	for _, key := range []string{"snap-a", "snap-b"} {
+		s := NewString(txn, []byte(key))
+		assert.NoError(t, s.Set([]byte("v")))
+	}
+	assert.NoError(t, txn.Commit(context.TODO()))
+
+	// pin a version, then write one more key
+	txn, err = d.Begin()
+	assert.NoError(t, err)
+	ver := txn.SnapshotVersion()
+	assert.NoError(t, txn.Commit(context.TODO()))
+
+	txn, err = d.Begin()
+	assert.NoError(t, err)
+	s := NewString(txn, []byte("snap-c"))
+	assert.NoError(t, s.Set([]byte("v")))
+	assert.NoError(t, txn.Commit(context.TODO()))
+
+	txn, err = d.Begin()
+	assert.NoError(t, err)
+	var pinned []string
+	assert.NoError(t, txn.Kv().KeysAtVersion(ver, nil, func(key []byte) bool {
+		pinned = append(pinned, string(key))
+		return true
+	}))
+	var live []string
+	assert.NoError(t, txn.Kv().Keys(nil, func(key []byte) bool {
+		live = append(live, string(key))
+		return true
+	}))
+	assert.NoError(t, txn.Commit(context.TODO()))
+
+	assert.Equal(t, []string{"snap-a", "snap-b"}, pinned)
+	assert.Equal(t, []string{"snap-a", "snap-b", "snap-c"}, live)
+}
+
+func TestVersionExpired(t *testing.T) {
+	d := MockDB()
+
+	txn, err := d.Begin()
+	assert.NoError(t, err)
+	assert.False(t, txn.Kv().VersionExpired(txn.SnapshotVersion()))
+	// version 1 carries a physical timestamp of the epoch
+	assert.True(t, txn.Kv().VersionExpired(1))
+	assert.NoError(t, txn.Commit(context.TODO()))
+}
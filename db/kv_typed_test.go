@@ -0,0 +1,32 @@
+package db
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestKvTypedObject(t *testing.T) {
+	d := MockDB()
+	key := []byte("kv-typed-key")
+
+	txn, err := d.Begin()
+	assert.NoError(t, err)
+	s := NewString(txn, key)
+	assert.NoError(t, s.Set([]byte("v")))
+	assert.NoError(t, txn.Commit(context.TODO()))
+
+	txn, err = d.Begin()
+	assert.NoError(t, err)
+	obj, err := txn.Kv().TypedObject(key, ObjectString)
+	assert.NoError(t, err)
+	assert.Equal(t, ObjectString, obj.Type)
+
+	_, err = txn.Kv().TypedObject(key, ObjectHash)
+	assert.Equal(t, ErrTypeMismatch, err)
+
+	_, err = txn.Kv().TypedObject([]byte("kv-typed-none"), ObjectString)
+	assert.Equal(t, ErrKeyNotFound, err)
+	assert.NoError(t, txn.Commit(context.TODO()))
+}
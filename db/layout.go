@@ -0,0 +1,87 @@
+package db
+
+import (
+	"fmt"
+
+	"github.com/pingcap/tidb/kv"
+)
+
+// The layout verified here is the one documented in docs/storage-layout.md
+// for external TiDB/TiSpark readers, its version is the LayoutVersion of
+// the schema machinery in schema.go
+
+// layoutEncodingOK reports whether the type and encoding pair of an object
+// is one the documented layout version produces
+func layoutEncodingOK(obj *Object) bool {
+	switch obj.Type {
+	case ObjectString, ObjectBitmap:
+		return obj.Encoding == ObjectEncodingRaw
+	case ObjectList:
+		return obj.Encoding == ObjectEncodingLinkedlist || obj.Encoding == ObjectEncodingZiplist
+	case ObjectSet, ObjectHash, ObjectZSet:
+		return obj.Encoding == ObjectEncodingHT
+	}
+	return false
+}
+
+// checkMetaLayout validates one meta value against the documented layout,
+// the returned error describes the first mismatch found
+func checkMetaLayout(meta []byte) error {
+	obj, err := DecodeObject(meta)
+	if err != nil {
+		return fmt.Errorf("undecodable meta: %s", err)
+	}
+	if !layoutEncodingOK(obj) {
+		return fmt.Errorf("unknown encoding %s for type %s", obj.Encoding, obj.Type)
+	}
+	trailer := meta[ObjectEncodingLength:]
+	switch obj.Type {
+	case ObjectZSet:
+		if len(trailer) != 8 && len(trailer) != 9 {
+			return fmt.Errorf("zset meta trailer of %d bytes", len(trailer))
+		}
+		if len(trailer) == 9 && trailer[8] != ZSetScoreV1 && trailer[8] != ZSetScoreV2 {
+			return fmt.Errorf("unknown zset score version %d", trailer[8])
+		}
+	case ObjectSet:
+		if len(trailer) != 8 {
+			return fmt.Errorf("set meta trailer of %d bytes", len(trailer))
+		}
+	case ObjectBitmap:
+		if _, err := DecodeBitmapMeta(meta); err != nil {
+			return fmt.Errorf("undecodable bitmap meta: %s", err)
+		}
+	}
+	return nil
+}
+
+// LayoutCheck scans up to limit meta keys of the db starting at cursor and
+// reports every key whose meta does not match the documented layout, so
+// operators can verify a namespace before pointing external readers at it.
+// It returns the report, how many keys were scanned, and the key to
+// continue from, nil when the scan reached the end of the db
+func (txn *Transaction) LayoutCheck(cursor []byte, limit int) ([]string, int, []byte, error) {
+	prefix := MetaKey(txn.db, nil)
+	iter, err := txn.t.Iter(MetaKey(txn.db, cursor), kv.Key(prefix).PrefixNext())
+	if err != nil {
+		return nil, 0, nil, err
+	}
+	defer iter.Close()
+
+	var report []string
+	scanned := 0
+	for ; iter.Valid() && iter.Key().HasPrefix(prefix); err = iter.Next() {
+		if err != nil {
+			return nil, scanned, nil, err
+		}
+		key := iter.Key()[len(prefix):]
+		if scanned >= limit {
+			return report, scanned, append([]byte{}, key...), nil
+		}
+		scanned++
+		if err := checkMetaLayout(iter.Value()); err != nil {
+			report = append(report, fmt.Sprintf("%s: %s", string(key), err))
+		}
+	}
+	return report, scanned, nil, nil
+}
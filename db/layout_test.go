@@ -0,0 +1,45 @@
+package db
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLayoutCheck(t *testing.T) {
+	db := MockDB()
+
+	txn, err := db.Begin()
+	assert.NoError(t, err)
+	str := NewString(txn, []byte("layout-str"))
+	assert.NoError(t, str.Set([]byte("v")))
+	zset, err := GetZSet(txn, []byte("layout-zset"))
+	assert.NoError(t, err)
+	_, err = zset.ZAdd([][]byte{[]byte("m")}, []float64{1})
+	assert.NoError(t, err)
+	// a meta claiming an encoding this layout version never writes
+	bad := NewString(txn, []byte("layout-bad"))
+	bad.Meta.Encoding = ObjectEncodingZipmap
+	assert.NoError(t, bad.Set([]byte("v")))
+	assert.NoError(t, txn.Commit(context.TODO()))
+
+	txn, err = db.Begin()
+	assert.NoError(t, err)
+	report, scanned, next, err := txn.LayoutCheck(nil, 100)
+	assert.NoError(t, err)
+	assert.Nil(t, next)
+	assert.True(t, scanned >= 3)
+	assert.Len(t, report, 1)
+	assert.Contains(t, report[0], "layout-bad")
+	assert.Contains(t, report[0], "unknown encoding")
+
+	// a limit below the key count hands back a cursor to continue from
+	report, scanned, next, err = txn.LayoutCheck(nil, 1)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, scanned)
+	assert.NotNil(t, next)
+	_, _, _, err = txn.LayoutCheck(next, 100)
+	assert.NoError(t, err)
+	assert.NoError(t, txn.Commit(context.TODO()))
+}
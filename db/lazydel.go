@@ -0,0 +1,40 @@
+package db
+
+import (
+	"sync/atomic"
+
+	"github.com/distributedio/titan/conf"
+)
+
+// lazyDeleteThreshold routes whole-collection deletes at command time
+// through a meta delete plus a background range gc once meta.Len reaches
+// it, deleting every data key inside the caller's transaction could
+// overrun the tikv transaction size limit. 0 disables the splitting
+var lazyDeleteThreshold int64 = 1000
+
+// lazyDeletedKeys counts the collections routed to the lazy path, INFO
+// reports it as lazy_deleted_keys
+var lazyDeletedKeys int64
+
+// EnableLazyDelete applies the lazy-delete section of the tikv config
+func EnableLazyDelete(conf *conf.LazyDelete) {
+	atomic.StoreInt64(&lazyDeleteThreshold, conf.Threshold)
+}
+
+// shouldLazyDelete reports whether a collection of the given length is
+// destroyed by a meta delete plus range gc instead of member by member
+func shouldLazyDelete(length int64) bool {
+	threshold := atomic.LoadInt64(&lazyDeleteThreshold)
+	return threshold > 0 && length >= threshold
+}
+
+// lazyDeleted records one collection routed to the lazy path
+func lazyDeleted() {
+	atomic.AddInt64(&lazyDeletedKeys, 1)
+}
+
+// LazyDeleteCount returns how many collections have been destroyed by a
+// meta delete plus range gc since the server started
+func LazyDeleteCount() int64 {
+	return atomic.LoadInt64(&lazyDeletedKeys)
+}
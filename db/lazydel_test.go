@@ -0,0 +1,155 @@
+package db
+
+import (
+	"context"
+	"testing"
+
+	"github.com/distributedio/titan/conf"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLazyDeleteZRem(t *testing.T) {
+	mdb := MockDB()
+	EnableLazyDelete(&conf.LazyDelete{Threshold: 2})
+	defer EnableLazyDelete(&conf.LazyDelete{Threshold: 1000})
+
+	key := []byte("TestLazyDeleteZRem")
+	members := [][]byte{[]byte("m1"), []byte("m2"), []byte("m3")}
+
+	txn, err := mdb.Begin()
+	assert.NoError(t, err)
+	zset, err := GetZSet(txn, key)
+	assert.NoError(t, err)
+	_, err = zset.ZAdd(members, []float64{1, 2, 3})
+	assert.NoError(t, err)
+	assert.NoError(t, txn.Commit(context.TODO()))
+
+	before := LazyDeleteCount()
+
+	txn, err = mdb.Begin()
+	assert.NoError(t, err)
+	zset, err = GetZSet(txn, key)
+	assert.NoError(t, err)
+	id := zset.meta.ID
+	deleted, err := zset.ZRem(members)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(3), deleted)
+	assert.NoError(t, txn.Commit(context.TODO()))
+	assert.Equal(t, before+1, LazyDeleteCount())
+
+	txn, err = mdb.Begin()
+	assert.NoError(t, err)
+	zset, err = GetZSet(txn, key)
+	assert.NoError(t, err)
+	assert.False(t, zset.Exist())
+	// the data keys are left to the background gc
+	gcKey := toTikvGCKey(DataKey(txn.db, id))
+	_, err = txn.t.Get(gcKey)
+	assert.NoError(t, err)
+	txn.Rollback()
+}
+
+func TestLazyDeleteSRem(t *testing.T) {
+	mdb := MockDB()
+	EnableLazyDelete(&conf.LazyDelete{Threshold: 2})
+	defer EnableLazyDelete(&conf.LazyDelete{Threshold: 1000})
+
+	key := []byte("TestLazyDeleteSRem")
+	members := [][]byte{[]byte("m1"), []byte("m2")}
+
+	txn, err := mdb.Begin()
+	assert.NoError(t, err)
+	set, err := GetSet(txn, key)
+	assert.NoError(t, err)
+	_, err = set.SAdd(members...)
+	assert.NoError(t, err)
+	assert.NoError(t, txn.Commit(context.TODO()))
+
+	before := LazyDeleteCount()
+
+	txn, err = mdb.Begin()
+	assert.NoError(t, err)
+	set, err = GetSet(txn, key)
+	assert.NoError(t, err)
+	id := set.meta.ID
+	num, err := set.SRem(members)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), num)
+	assert.NoError(t, txn.Commit(context.TODO()))
+	assert.Equal(t, before+1, LazyDeleteCount())
+
+	txn, err = mdb.Begin()
+	assert.NoError(t, err)
+	set, err = GetSet(txn, key)
+	assert.NoError(t, err)
+	assert.False(t, set.Exists())
+	gcKey := toTikvGCKey(DataKey(txn.db, id))
+	_, err = txn.t.Get(gcKey)
+	assert.NoError(t, err)
+	txn.Rollback()
+}
+
+func TestLazyDeleteSPop(t *testing.T) {
+	mdb := MockDB()
+	EnableLazyDelete(&conf.LazyDelete{Threshold: 2})
+	defer EnableLazyDelete(&conf.LazyDelete{Threshold: 1000})
+
+	key := []byte("TestLazyDeleteSPop")
+
+	txn, err := mdb.Begin()
+	assert.NoError(t, err)
+	set, err := GetSet(txn, key)
+	assert.NoError(t, err)
+	_, err = set.SAdd([]byte("m1"), []byte("m2"))
+	assert.NoError(t, err)
+	assert.NoError(t, txn.Commit(context.TODO()))
+
+	before := LazyDeleteCount()
+
+	txn, err = mdb.Begin()
+	assert.NoError(t, err)
+	set, err = GetSet(txn, key)
+	assert.NoError(t, err)
+	members, err := set.SPop(5)
+	assert.NoError(t, err)
+	assert.Len(t, members, 2)
+	assert.NoError(t, txn.Commit(context.TODO()))
+	assert.Equal(t, before+1, LazyDeleteCount())
+
+	txn, err = mdb.Begin()
+	assert.NoError(t, err)
+	set, err = GetSet(txn, key)
+	assert.NoError(t, err)
+	assert.False(t, set.Exists())
+	txn.Rollback()
+}
+
+// below the threshold the members are deleted inside the transaction
+func TestLazyDeleteBelowThreshold(t *testing.T) {
+	mdb := MockDB()
+	EnableLazyDelete(&conf.LazyDelete{Threshold: 100})
+	defer EnableLazyDelete(&conf.LazyDelete{Threshold: 1000})
+
+	key := []byte("TestLazyDeleteBelowThreshold")
+	members := [][]byte{[]byte("m1"), []byte("m2")}
+
+	txn, err := mdb.Begin()
+	assert.NoError(t, err)
+	zset, err := GetZSet(txn, key)
+	assert.NoError(t, err)
+	_, err = zset.ZAdd(members, []float64{1, 2})
+	assert.NoError(t, err)
+	assert.NoError(t, txn.Commit(context.TODO()))
+
+	before := LazyDeleteCount()
+
+	txn, err = mdb.Begin()
+	assert.NoError(t, err)
+	zset, err = GetZSet(txn, key)
+	assert.NoError(t, err)
+	deleted, err := zset.ZRem(members)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), deleted)
+	assert.NoError(t, txn.Commit(context.TODO()))
+	assert.Equal(t, before, LazyDeleteCount())
+}
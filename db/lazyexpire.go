@@ -0,0 +1,87 @@
+package db
+
+import (
+	"context"
+
+	"github.com/distributedio/titan/metrics"
+	"go.uber.org/zap"
+)
+
+// lazyExpireBufferSize caps how many expired keys noticed by reads may
+// queue up for immediate deletion, a full buffer leaves the key to the
+// background expire worker instead of blocking the read
+const lazyExpireBufferSize = 1024
+
+type lazyExpireTask struct {
+	db       *DB
+	mkey     []byte
+	id       []byte
+	expireAt int64
+}
+
+type lazyExpirer struct {
+	ch chan lazyExpireTask
+}
+
+// lazyExpire is the passive half of expiration. Reads that notice an
+// expired object hand it over here, so the key is deleted right away
+// instead of waiting for the leader's next expire round
+var lazyExpire *lazyExpirer
+
+// EnableLazyExpire starts the local lazy expiration worker, reads then
+// schedule immediate deletion of expired objects they run into
+func EnableLazyExpire() {
+	if lazyExpire != nil {
+		return
+	}
+	le := &lazyExpirer{ch: make(chan lazyExpireTask, lazyExpireBufferSize)}
+	go le.run()
+	lazyExpire = le
+}
+
+// tryLazyExpire schedules the expired object for immediate deletion, it
+// never blocks and is a no-op when lazy expiration is not enabled
+func tryLazyExpire(db *DB, key []byte, obj *Object) {
+	le := lazyExpire
+	if le == nil || db == nil || obj == nil || obj.ExpireAt == 0 {
+		return
+	}
+	task := lazyExpireTask{db: db, mkey: MetaKey(db, key), expireAt: obj.ExpireAt}
+	task.id = append(task.id, obj.ID...)
+	select {
+	case le.ch <- task:
+	default:
+		// buffer full, the background expire worker handles it
+	}
+}
+
+func (le *lazyExpirer) run() {
+	for task := range le.ch {
+		le.expireOne(task)
+	}
+}
+
+// expireOne deletes one expired key in its own transaction, reusing the
+// same doExpire logic as the background worker so a key re-set after the
+// read only loses the stale data range
+func (le *lazyExpirer) expireOne(task lazyExpireTask) {
+	txn, err := task.db.Begin()
+	if err != nil {
+		zap.L().Error("[Expire-lazy] txn begin failed", zap.Error(err))
+		return
+	}
+	if err := doExpire(txn, task.mkey, task.id, "[Expire-lazy]", task.expireAt); err != nil {
+		txn.Rollback()
+		return
+	}
+	if err := txn.t.Delete(expireKey(task.mkey, task.expireAt)); err != nil {
+		zap.L().Error("[Expire-lazy] delete expire list item failed", zap.ByteString("mkey", task.mkey), zap.Error(err))
+		txn.Rollback()
+		return
+	}
+	if err := txn.Commit(context.Background()); err != nil {
+		txn.Rollback()
+		return
+	}
+	metrics.GetMetrics().ExpireKeysTotal.WithLabelValues("lazy").Inc()
+}
@@ -0,0 +1,47 @@
+package db
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestLazyExpire checks the passive half of expiration, a read running into
+// an expired key schedules its deletion without the background worker
+func TestLazyExpire(t *testing.T) {
+	EnableLazyExpire()
+
+	key := []byte("lazy-expire-key")
+	txn, err := mockDB.Begin()
+	assert.NoError(t, err)
+	s, err := txn.String(key)
+	assert.NoError(t, err)
+	assert.NoError(t, s.Set([]byte("value"), int64(50*time.Millisecond)))
+	assert.NoError(t, txn.Commit(context.TODO()))
+
+	time.Sleep(60 * time.Millisecond)
+
+	// the read sees the key as gone and hands it to the lazy worker
+	txn, err = mockDB.Begin()
+	assert.NoError(t, err)
+	str, err := txn.String(key)
+	assert.NoError(t, err)
+	_, err = str.Get()
+	assert.Equal(t, ErrKeyNotFound, err)
+	txn.Rollback()
+
+	// the worker deletes the meta in its own transaction shortly after
+	mkey := MetaKey(mockDB, key)
+	deleted := false
+	for i := 0; i < 100 && !deleted; i++ {
+		time.Sleep(10 * time.Millisecond)
+		txn, err = mockDB.Begin()
+		assert.NoError(t, err)
+		_, err = txn.t.Get(mkey)
+		deleted = IsErrNotFound(err)
+		txn.Rollback()
+	}
+	assert.True(t, deleted)
+}
@@ -1,15 +1,18 @@
 package db
 
 import (
+	"bytes"
 	"context"
 	"errors"
 	"fmt"
 	"github.com/distributedio/titan/conf"
+	"github.com/distributedio/titan/db/keyspace"
+	"github.com/distributedio/titan/failpoint"
 	"github.com/distributedio/titan/metrics"
 	sdk_kv "github.com/pingcap/tidb/kv"
 	"go.uber.org/zap"
 	"golang.org/x/time/rate"
-	"net"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
@@ -25,9 +28,16 @@ const (
 	LIMIT_VALUE_TOKEN          = " "
 	LIMITER_STATUS_PREFIX      = "limiter_status:"
 	LIMITER_STATUS_VALUE_TOKEN = ","
-	TIME_FORMAT                = "2006-01-02 15:04:05"
 	MAXIMUM_WEIGHT             = 1
 	MINIMUM_WEIGHT             = 0.1
+
+	// legacyLimiterNamespace is where limiter data lived before it moved
+	// into the reserved system keyspace
+	legacyLimiterNamespace = "sys_ratelimit"
+
+	// limiterConfigVersionKey is bumped with every rule change, nodes watch
+	// it to apply new limits without waiting for the slow polling period
+	limiterConfigVersionKey = "config_version"
 )
 
 type LimiterWrapper struct {
@@ -41,16 +51,26 @@ type LimiterWrapper struct {
 type CommandLimiter struct {
 	localIp     string
 	limiterName string
+	// nodeEpoch stamps every published status record, peerEpochs remembers
+	// the highest epoch seen per peer IP so a delayed write of a dead
+	// incarnation never wins over the record of the live one
+	nodeEpoch  int64
+	peerEpochs map[string]int64
 
 	qpsLw  LimiterWrapper
 	rateLw LimiterWrapper
-	weight float64
+	// weight balances the qps budget, rateWeight the byte budget, a node
+	// with few but huge commands shrinks only its rate share
+	weight     float64
+	rateWeight float64
 
 	lock               sync.Mutex
 	skipBalance        bool
 	lastTime           time.Time
 	totalCommandsCount int64
 	totalCommandsSize  int64
+	lastQps            float64
+	lastRate           float64
 }
 
 type LimitData struct {
@@ -62,11 +82,25 @@ type LimitersMgr struct {
 	limitDatadb *DB
 	conf        *conf.RateLimit
 	localIp     string
+	// nodeEpoch is the start timestamp of this process, published with
+	// every status record so peers can tell a reused IP's old incarnation
+	// from the live one
+	nodeEpoch int64
 
 	limiters          sync.Map
 	qpsAllmatchLimit  sync.Map
 	rateAllmatchLimit sync.Map
 	lock              sync.Mutex
+
+	// lastConfigVersion is the config version the local rules were synced
+	// at, only touched by the watcher goroutine after startup
+	lastConfigVersion int64
+
+	// defaultLimits are the bootstrap rules of the config file, consulted
+	// whenever tikv holds no value for a limiter key so a brand new cluster
+	// enforces its defaults before anyone writes a rule. A key written to
+	// tikv always wins on the next sync
+	defaultLimits map[string]*LimitData
 }
 
 func getAllmatchLimiterName(limiterName string) string {
@@ -78,11 +112,7 @@ func getAllmatchLimiterName(limiterName string) string {
 }
 
 func getLimiterKey(limiterName string) []byte {
-	var key []byte
-	key = append(key, []byte(LIMITER_STATUS_PREFIX)...)
-	key = append(key, []byte(limiterName)...)
-	key = append(key, ':')
-	return key
+	return keyspace.LimiterKey(limiterName)
 }
 
 func getNamespaceAndCmd(limiterName string) []string {
@@ -95,34 +125,9 @@ func getNamespaceAndCmd(limiterName string) []string {
 }
 
 func NewLimitersMgr(store *RedisStore, rateLimit *conf.RateLimit) (*LimitersMgr, error) {
-	var addrs []net.Addr
-	var err error
-	if rateLimit.InterfaceName != "" {
-		iface, err := net.InterfaceByName(rateLimit.InterfaceName)
-		if err != nil {
-			return nil, err
-		}
-
-		addrs, err = iface.Addrs()
-		if err != nil {
-			return nil, err
-		}
-	} else {
-		addrs, err = net.InterfaceAddrs()
-		if err != nil {
-			return nil, err
-		}
-	}
-
-	localIp := ""
-	for _, a := range addrs {
-		if ipnet, ok := a.(*net.IPNet); ok && !ipnet.IP.IsLoopback() && ipnet.IP.To4() != nil {
-			localIp = ipnet.IP.String()
-			break
-		}
-	}
-	if localIp == "" {
-		return nil, errors.New(rateLimit.InterfaceName + " adds is empty")
+	localIp, err := LocalIP(rateLimit.InterfaceName)
+	if err != nil {
+		return nil, err
 	}
 
 	if rateLimit.LimiterNamespace == "" {
@@ -137,18 +142,271 @@ func NewLimitersMgr(store *RedisStore, rateLimit *conf.RateLimit) (*LimitersMgr,
 	if rateLimit.InitialPercent > 1 || rateLimit.InitialPercent <= 0 {
 		return nil, errors.New("initial-percent should in (0, 1]")
 	}
+	defaultLimits, err := parseInitialLimits(rateLimit.InitialLimits)
+	if err != nil {
+		return nil, err
+	}
 
 	l := &LimitersMgr{
-		limitDatadb: store.DB(rateLimit.LimiterNamespace, LIMITDATA_DBID),
-		conf:        rateLimit,
-		localIp:     localIp,
+		limitDatadb:   store.DB(rateLimit.LimiterNamespace, LIMITDATA_DBID),
+		conf:          rateLimit,
+		localIp:       localIp,
+		nodeEpoch:     Now(),
+		defaultLimits: defaultLimits,
+	}
+	if len(defaultLimits) > 0 {
+		zap.L().Info("[Limit] bootstrap limits loaded from config", zap.Int("rules", len(defaultLimits)))
+	}
+
+	if err := l.migrateLegacyLimiterData(); err != nil {
+		zap.L().Error("[Limit] migrate legacy limiter data failed", zap.Error(err))
+	}
+
+	if err := l.clearStaleNodeStatus(); err != nil {
+		zap.L().Error("[Limit] clear stale node status failed", zap.Error(err))
+	}
+
+	if version, err := l.readConfigVersion(); err == nil {
+		l.lastConfigVersion = version
 	}
 
 	go l.startSyncNewLimit()
 	go l.startReportAndBalance()
+	if rateLimit.NotifyCheckPeriod > 0 {
+		go l.startWatchConfigVersion()
+	}
 	return l, nil
 }
 
+// startWatchConfigVersion checks the config version key far more often than
+// the polling period is allowed to sync the full rule set, a bumped version
+// means some node changed a rule and the sync runs right away. Plain
+// polling stays as the fallback for rules written behind our back
+func (l *LimitersMgr) startWatchConfigVersion() {
+	ticker := time.NewTicker(l.conf.NotifyCheckPeriod)
+	defer ticker.Stop()
+	for range ticker.C {
+		version, err := l.readConfigVersion()
+		if err != nil || version == l.lastConfigVersion {
+			continue
+		}
+		l.lastConfigVersion = version
+		zap.L().Info("[Limit] config version changed, syncing limits", zap.Int64("version", version))
+		l.runSyncNewLimit()
+	}
+}
+
+// readConfigVersion returns the current config version, 0 when no rule was
+// ever changed through the manager
+func (l *LimitersMgr) readConfigVersion() (int64, error) {
+	txn, err := l.limitDatadb.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer txn.Rollback()
+	str, err := txn.String([]byte(limiterConfigVersionKey))
+	if err != nil {
+		return 0, err
+	}
+	val, err := str.Get()
+	if err == ErrKeyNotFound {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseInt(string(val), 10, 64)
+}
+
+// bumpConfigVersion increments the config version within the transaction
+// of the rule change itself, so a visible rule always comes with a visible
+// version bump
+func bumpConfigVersion(txn *Transaction) error {
+	str, err := txn.String([]byte(limiterConfigVersionKey))
+	if err != nil {
+		return err
+	}
+	version := int64(0)
+	if val, err := str.Get(); err == nil {
+		if version, err = strconv.ParseInt(string(val), 10, 64); err != nil {
+			version = 0
+		}
+	} else if err != ErrKeyNotFound {
+		return err
+	}
+	return str.Set([]byte(strconv.FormatInt(version+1, 10)))
+}
+
+// migrateLegacyLimiterData copies limiter data left in the old world
+// readable namespace into the reserved one, once, so upgraded clusters keep
+// their limits without rewriting them by hand. It only runs when the
+// reserved namespace holds nothing yet
+func (l *LimitersMgr) migrateLegacyLimiterData() error {
+	if l.conf.LimiterNamespace == legacyLimiterNamespace {
+		return nil
+	}
+	txn, err := l.limitDatadb.Begin()
+	if err != nil {
+		return err
+	}
+	newPrefix := []byte(l.conf.LimiterNamespace + ":")
+	iter, err := txn.t.Iter(newPrefix, sdk_kv.Key(newPrefix).PrefixNext())
+	if err != nil {
+		txn.Rollback()
+		return err
+	}
+	populated := iter.Valid() && iter.Key().HasPrefix(newPrefix)
+	iter.Close()
+	if populated {
+		txn.Rollback()
+		return nil
+	}
+
+	oldPrefix := []byte(legacyLimiterNamespace + ":")
+	iter, err = txn.t.Iter(oldPrefix, sdk_kv.Key(oldPrefix).PrefixNext())
+	if err != nil {
+		txn.Rollback()
+		return err
+	}
+	defer iter.Close()
+	count := 0
+	for ; iter.Valid() && iter.Key().HasPrefix(oldPrefix); err = iter.Next() {
+		if err != nil {
+			txn.Rollback()
+			return err
+		}
+		key := append([]byte{}, newPrefix...)
+		key = append(key, iter.Key()[len(oldPrefix):]...)
+		if err := txn.t.Set(key, iter.Value()); err != nil {
+			txn.Rollback()
+			return err
+		}
+		count++
+	}
+	if count == 0 {
+		txn.Rollback()
+		return nil
+	}
+	if err := txn.t.Commit(context.Background()); err != nil {
+		txn.Rollback()
+		return err
+	}
+	zap.L().Info("[Limit] limiter data migrated into the reserved namespace",
+		zap.String("from", legacyLimiterNamespace), zap.String("to", l.conf.LimiterNamespace), zap.Int("keys", count))
+	return nil
+}
+
+// clearStaleNodeStatus deletes every limiter status record this node's IP
+// published before the restart. A node restarting with a reused IP would
+// otherwise distort the balancing of its peers with pre-restart qps and
+// weights until the status lifetime runs out
+func (l *LimitersMgr) clearStaleNodeStatus() error {
+	txn, err := l.limitDatadb.Begin()
+	if err != nil {
+		return err
+	}
+	prefix := MetaKey(l.limitDatadb, []byte(LIMITER_STATUS_PREFIX))
+	iter, err := txn.t.Iter(prefix, sdk_kv.Key(prefix).PrefixNext())
+	if err != nil {
+		txn.Rollback()
+		return err
+	}
+	defer iter.Close()
+	suffix := []byte(":" + l.localIp)
+	count := 0
+	for ; iter.Valid() && iter.Key().HasPrefix(prefix); err = iter.Next() {
+		if err != nil {
+			txn.Rollback()
+			return err
+		}
+		if !bytes.HasSuffix(iter.Key(), suffix) {
+			continue
+		}
+		if err := txn.t.Delete(iter.Key()); err != nil {
+			txn.Rollback()
+			return err
+		}
+		count++
+	}
+	if count == 0 {
+		txn.Rollback()
+		return nil
+	}
+	if err := txn.t.Commit(context.Background()); err != nil {
+		txn.Rollback()
+		return err
+	}
+	zap.L().Info("[Limit] cleared stale status of the local node",
+		zap.String("titan", l.localIp), zap.Int("keys", count))
+	return nil
+}
+
+// SetLimit writes a limit rule into the limiter namespace, limitKey is the
+// full rule name like qps:<namespace>@<command> and value is
+// "<limit>[K|k|M|m] <burst>", the same format setlimit.sh used to write
+func (l *LimitersMgr) SetLimit(limitKey string, value string) error {
+	txn, err := l.limitDatadb.Begin()
+	if err != nil {
+		return err
+	}
+	str, err := txn.String([]byte(limitKey))
+	if err != nil {
+		txn.Rollback()
+		return err
+	}
+	if err := str.Set([]byte(value)); err != nil {
+		txn.Rollback()
+		return err
+	}
+	if err := bumpConfigVersion(txn); err != nil {
+		txn.Rollback()
+		return err
+	}
+	return txn.Commit(context.Background())
+}
+
+// GetLimit reads a limit rule, a nil value means the rule is not set
+func (l *LimitersMgr) GetLimit(limitKey string) ([]byte, error) {
+	txn, err := l.limitDatadb.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer txn.Rollback()
+	str, err := txn.String([]byte(limitKey))
+	if err != nil {
+		return nil, err
+	}
+	val, err := str.Get()
+	if err == ErrKeyNotFound {
+		return nil, nil
+	}
+	return val, err
+}
+
+// DelLimit removes a limit rule and reports whether it existed
+func (l *LimitersMgr) DelLimit(limitKey string) (int64, error) {
+	txn, err := l.limitDatadb.Begin()
+	if err != nil {
+		return 0, err
+	}
+	deleted, err := txn.Kv().Delete([][]byte{[]byte(limitKey)})
+	if err != nil {
+		txn.Rollback()
+		return 0, err
+	}
+	if deleted > 0 {
+		if err := bumpConfigVersion(txn); err != nil {
+			txn.Rollback()
+			return 0, err
+		}
+	}
+	if err := txn.Commit(context.Background()); err != nil {
+		txn.Rollback()
+		return 0, err
+	}
+	return deleted, nil
+}
+
 func (l *LimitersMgr) init(limiterName string) *CommandLimiter {
 	//lock is just prevent many new connection of same namespace to getlimit from tikv in same time
 	l.lock.Lock()
@@ -167,7 +425,7 @@ func (l *LimitersMgr) init(limiterName string) *CommandLimiter {
 	rateLimit, rateBurst := l.getLimit(limiterName, false)
 	if (qpsLimit > 0 && qpsBurst > 0) ||
 		(rateLimit > 0 && rateBurst > 0) {
-		newCl := NewCommandLimiter(l.localIp, limiterName, qpsLimit, qpsBurst, rateLimit, rateBurst, l.conf.InitialPercent)
+		newCl := NewCommandLimiter(l.localIp, l.nodeEpoch, limiterName, qpsLimit, qpsBurst, rateLimit, rateBurst, l.conf.InitialPercent)
 		v, _ := l.limiters.LoadOrStore(limiterName, newCl)
 		return v.(*CommandLimiter)
 	} else {
@@ -177,9 +435,6 @@ func (l *LimitersMgr) init(limiterName string) *CommandLimiter {
 }
 
 func (l *LimitersMgr) getLimit(limiterName string, isQps bool) (int64, int) {
-	limit := int64(0)
-	burst := int64(0)
-
 	txn, err := l.limitDatadb.Begin()
 	if err != nil {
 		zap.L().Error("[Limit] transection begin failed", zap.String("limiterName", limiterName), zap.Bool("isQps", isQps), zap.Error(err))
@@ -206,9 +461,27 @@ func (l *LimitersMgr) getLimit(limiterName string, isQps bool) (int64, int) {
 	}
 	val, err := str.Get()
 	if err != nil {
+		// no rule in tikv yet, fall back to the bootstrap rule of the
+		// config file if one matches
+		if err == ErrKeyNotFound {
+			if d, ok := l.defaultLimits[limiterKey]; ok {
+				return d.limit, d.burst
+			}
+		}
 		return 0, 0
 	}
 
+	limit, burst := parseLimitValue(limiterKey, val)
+	if logEnv := zap.L().Check(zap.DebugLevel, "[Limit] got limit"); logEnv != nil {
+		logEnv.Write(zap.String("key", limiterKey), zap.Int64("limit", limit), zap.Int("burst", burst))
+	}
+	return limit, burst
+}
+
+// parseLimitValue decodes a "<limit>[K|k|M|m] <burst>" limit value, the
+// format the qps:/rate: keys and the initial-limits config rules share,
+// and returns (0, 0) when the value is malformed
+func parseLimitValue(limiterKey string, val []byte) (int64, int) {
 	limitStrs := strings.Split(string(val), LIMIT_VALUE_TOKEN)
 	if len(limitStrs) < 2 {
 		zap.L().Error("[Limit] limit hasn't enough parameters, should be: <limit>[K|k|M|m] <burst>", zap.String("key", limiterKey), zap.ByteString("val", val))
@@ -237,17 +510,42 @@ func (l *LimitersMgr) getLimit(limiterName string, isQps bool) (int64, int) {
 		zap.L().Error("[Limit] limit's number part can't be decoded to number", zap.String("key", limiterKey), zap.ByteString("val", val), zap.Error(err))
 		return 0, 0
 	}
-	limit = int64(limitInUnit * float64(unit))
-	if burst, err = strconv.ParseInt(burstStr, 10, 32); err != nil {
+	limit := int64(limitInUnit * float64(unit))
+	burst, err := strconv.ParseInt(burstStr, 10, 32)
+	if err != nil {
 		zap.L().Error("[Limit] burst can't be decoded to integer", zap.String("key", limiterKey), zap.ByteString("val", val), zap.Error(err))
 		return 0, 0
 	}
+	return limit, int(burst)
+}
 
-	if logEnv := zap.L().Check(zap.DebugLevel, "[Limit] got limit"); logEnv != nil {
-		logEnv.Write(zap.String("key", limiterKey), zap.Int64("limit", limit), zap.Int64("burst", burst))
+// parseInitialLimits parses the comma separated bootstrap rules of the
+// initial-limits config entry, each one
+// "<qps:|rate:><namespace>@<command>=<limit>[K|k|M|m] <burst>"
+func parseInitialLimits(spec string) (map[string]*LimitData, error) {
+	limits := make(map[string]*LimitData)
+	for _, rule := range strings.Split(spec, ",") {
+		if rule = strings.TrimSpace(rule); rule == "" {
+			continue
+		}
+		parts := strings.SplitN(rule, "=", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("initial limit %q should be <qps:|rate:><namespace>@<command>=<limit> <burst>", rule)
+		}
+		key := strings.TrimSpace(parts[0])
+		if !strings.HasPrefix(key, QPS_PREFIX) && !strings.HasPrefix(key, RATE_PREFIX) {
+			return nil, fmt.Errorf("initial limit %q should start with %s or %s", rule, QPS_PREFIX, RATE_PREFIX)
+		}
+		if getNamespaceAndCmd(key) == nil {
+			return nil, fmt.Errorf("initial limit %q misses the %s between namespace and command", rule, NAMESPACE_COMMAND_TOKEN)
+		}
+		limit, burst := parseLimitValue(key, []byte(strings.TrimSpace(parts[1])))
+		if limit <= 0 || burst <= 0 {
+			return nil, fmt.Errorf("initial limit %q has a bad value, should be <limit>[K|k|M|m] <burst>", rule)
+		}
+		limits[key] = &LimitData{limit, burst}
 	}
-
-	return limit, int(burst)
+	return limits, nil
 }
 
 func (l *LimitersMgr) CheckLimit(namespace string, cmdName string, cmdArgs []string) {
@@ -268,6 +566,42 @@ func (l *LimitersMgr) CheckLimit(namespace string, cmdName string, cmdArgs []str
 	}
 }
 
+// LimiterUsage is a snapshot of one active command limiter of a namespace
+type LimiterUsage struct {
+	Command   string
+	QpsLimit  int64
+	QpsBurst  int
+	RateLimit int64
+	RateBurst int
+	LocalQps  float64
+	LocalRate float64
+}
+
+// NamespaceUsage snapshots every active limiter of the namespace, the qps
+// and rate numbers are the local node's last reported values
+func (l *LimitersMgr) NamespaceUsage(namespace string) []LimiterUsage {
+	prefix := namespace + NAMESPACE_COMMAND_TOKEN
+	var usages []LimiterUsage
+	l.limiters.Range(func(k, v interface{}) bool {
+		limiterName := k.(string)
+		cl, _ := v.(*CommandLimiter)
+		if cl == nil || !strings.HasPrefix(limiterName, prefix) {
+			return true
+		}
+		u := LimiterUsage{Command: strings.TrimPrefix(limiterName, prefix)}
+		u.QpsLimit, u.QpsBurst = cl.qpsLw.getLimitAndBurst()
+		u.RateLimit, u.RateBurst = cl.rateLw.getLimitAndBurst()
+		cl.lock.Lock()
+		u.LocalQps = cl.lastQps
+		u.LocalRate = cl.lastRate
+		cl.lock.Unlock()
+		usages = append(usages, u)
+		return true
+	})
+	sort.Slice(usages, func(i, j int) bool { return usages[i].Command < usages[j].Command })
+	return usages
+}
+
 func (l *LimitersMgr) startReportAndBalance() {
 	ticker := time.NewTicker(l.conf.GlobalBalancePeriod)
 	defer ticker.Stop()
@@ -281,13 +615,13 @@ func (l *LimitersMgr) runReportAndBalance() {
 		limiterName := k.(string)
 		commandLimiter := v.(*CommandLimiter)
 		if commandLimiter != nil {
-			averageQps := commandLimiter.reportLocalStat(l.conf.GlobalBalancePeriod)
-			commandLimiter.balanceLimit(averageQps, l.limitDatadb, l.conf.TitanStatusLifetime, l.conf.UsageToDivide, l.conf.UsageToMultiply, l.conf.WeightChangeFactor)
+			averageQps, averageRate := commandLimiter.reportLocalStat(l.conf.GlobalBalancePeriod)
+			commandLimiter.balanceLimit(averageQps, averageRate, l.limitDatadb, l.conf.TitanStatusLifetime, l.conf.UsageToDivide, l.conf.UsageToMultiply, l.conf.WeightChangeFactor)
 
 		} else {
 			namespaceAndCmd := getNamespaceAndCmd(limiterName)
-			metrics.GetMetrics().LimiterQpsVec.WithLabelValues(namespaceAndCmd[0], namespaceAndCmd[1], l.localIp).Set(0)
-			metrics.GetMetrics().LimiterRateVec.WithLabelValues(namespaceAndCmd[0], namespaceAndCmd[1], l.localIp).Set(0)
+			metrics.GetMetrics().LimiterQpsVec.WithLabelValues(metrics.NamespaceLabel(namespaceAndCmd[0]), namespaceAndCmd[1], l.localIp).Set(0)
+			metrics.GetMetrics().LimiterRateVec.WithLabelValues(metrics.NamespaceLabel(namespaceAndCmd[0]), namespaceAndCmd[1], l.localIp).Set(0)
 		}
 		return true
 	})
@@ -302,6 +636,11 @@ func (l *LimitersMgr) startSyncNewLimit() {
 }
 
 func (l *LimitersMgr) runSyncNewLimit() {
+	skip := false
+	failpoint.Inject("limiter-sync-skip", func(string) { skip = true })
+	if skip {
+		return
+	}
 	allmatchLimits := []*sync.Map{&l.qpsAllmatchLimit, &l.rateAllmatchLimit}
 	for i, allmatchLimit := range allmatchLimits {
 		allmatchLimit.Range(func(k, v interface{}) bool {
@@ -357,7 +696,7 @@ func (l *LimitersMgr) runSyncNewLimit() {
 		if (qpsLimit > 0 && qpsBurst > 0) ||
 			(rateLimit > 0 && rateBurst > 0) {
 			if commandLimiter == nil {
-				newCl := NewCommandLimiter(l.localIp, limiterName, qpsLimit, qpsBurst, rateLimit, rateBurst, l.conf.InitialPercent)
+				newCl := NewCommandLimiter(l.localIp, l.nodeEpoch, limiterName, qpsLimit, qpsBurst, rateLimit, rateBurst, l.conf.InitialPercent)
 				l.limiters.Store(limiterName, newCl)
 			} else {
 				commandLimiter.updateLimit(qpsLimit, qpsBurst, rateLimit, rateBurst)
@@ -375,7 +714,7 @@ func (l *LimitersMgr) runSyncNewLimit() {
 	})
 }
 
-func NewCommandLimiter(localIp string, limiterName string, qpsLimit int64, qpsBurst int, rateLimit int64, rateBurst int, initialPercent float64) *CommandLimiter {
+func NewCommandLimiter(localIp string, nodeEpoch int64, limiterName string, qpsLimit int64, qpsBurst int, rateLimit int64, rateBurst int, initialPercent float64) *CommandLimiter {
 	if !(qpsLimit > 0 && qpsBurst > 0) &&
 		!(rateLimit > 0 && rateBurst > 0) {
 		return nil
@@ -386,9 +725,12 @@ func NewCommandLimiter(localIp string, limiterName string, qpsLimit int64, qpsBu
 	cl := &CommandLimiter{
 		limiterName: limiterName,
 		localIp:     localIp,
+		nodeEpoch:   nodeEpoch,
+		peerEpochs:  make(map[string]int64),
 		qpsLw:       LimiterWrapper{localPercent: initialPercent, limiterName: limiterName + "-qps"},
 		rateLw:      LimiterWrapper{localPercent: initialPercent, limiterName: limiterName + "-rate"},
 		weight:      MAXIMUM_WEIGHT,
+		rateWeight:  MAXIMUM_WEIGHT,
 		skipBalance: true,
 		lastTime:    time.Now(),
 	}
@@ -406,33 +748,91 @@ func (cl *CommandLimiter) updateLimit(newQpsLimit int64, newQpsBurst int, newRat
 	}
 }
 
-func (cl *CommandLimiter) reportLocalStat(globalBalancePeriod time.Duration) float64 {
-	var qpsLocal, rateLocal float64
+func (cl *CommandLimiter) reportLocalStat(globalBalancePeriod time.Duration) (float64, float64) {
+	var qpsLocal, rateLocal, rateBytesLocal float64
 	cl.lock.Lock()
 	defer cl.lock.Unlock()
 	seconds := time.Since(cl.lastTime).Seconds()
 	if seconds >= 0 {
 		qpsLocal = float64(cl.totalCommandsCount) / seconds
 		rateLocal = float64(cl.totalCommandsSize) / 1024 / seconds
+		rateBytesLocal = float64(cl.totalCommandsSize) / seconds
 	} else {
 		qpsLocal = 0
 		rateLocal = 0
+		rateBytesLocal = 0
 	}
 	cl.totalCommandsCount = 0
 	cl.totalCommandsSize = 0
 	cl.lastTime = time.Now()
+	cl.lastQps = qpsLocal
+	cl.lastRate = rateLocal
 
 	namespaceCmd := getNamespaceAndCmd(cl.limiterName)
-	metrics.GetMetrics().LimiterQpsVec.WithLabelValues(namespaceCmd[0], namespaceCmd[1], cl.localIp).Set(qpsLocal)
-	metrics.GetMetrics().LimiterRateVec.WithLabelValues(namespaceCmd[0], namespaceCmd[1], cl.localIp).Set(rateLocal)
+	metrics.GetMetrics().LimiterQpsVec.WithLabelValues(metrics.NamespaceLabel(namespaceCmd[0]), namespaceCmd[1], cl.localIp).Set(qpsLocal)
+	metrics.GetMetrics().LimiterRateVec.WithLabelValues(metrics.NamespaceLabel(namespaceCmd[0]), namespaceCmd[1], cl.localIp).Set(rateLocal)
 
-	return qpsLocal
+	return qpsLocal, rateBytesLocal
 }
 
-func (cl *CommandLimiter) balanceLimit(averageQps float64, limitDatadb *DB, titanStatusLifetime time.Duration,
+// adjustWeight applies the usage driven weight change rules to one
+// dimension, qps or byte rate, and returns the clamped new weight
+func adjustWeight(weight float64, average float64, globalLimit float64, otherWeights []float64, otherUsages []float64,
+	devideUsage float64, multiplyUsage float64, weightChangeFactor float64) float64 {
+	totalWeight := weight
+	for i := range otherWeights {
+		totalWeight += otherWeights[i]
+	}
+
+	selfLimitInTarget := globalLimit * (weight / totalWeight)
+	if average < selfLimitInTarget*devideUsage {
+		otherHaveHigh := false
+		otherAllLow := true
+		for i := range otherUsages {
+			otherLimitInTarget := globalLimit * (otherWeights[i] / totalWeight)
+			if otherUsages[i] >= otherLimitInTarget*multiplyUsage {
+				otherHaveHigh = true
+				otherAllLow = false
+				break
+			} else if otherUsages[i] >= otherLimitInTarget*devideUsage {
+				otherAllLow = false
+			}
+		}
+		if otherHaveHigh {
+			weight /= weightChangeFactor
+			if weight < MINIMUM_WEIGHT {
+				weight = MINIMUM_WEIGHT
+			}
+		} else if otherAllLow {
+			weight *= weightChangeFactor
+			if weight > MAXIMUM_WEIGHT {
+				weight = MAXIMUM_WEIGHT
+			}
+		}
+	} else if average >= selfLimitInTarget*multiplyUsage {
+		weight *= weightChangeFactor
+		if weight > MAXIMUM_WEIGHT {
+			weight = MAXIMUM_WEIGHT
+		}
+	}
+	return weight
+}
+
+// weightPercent is the share of the global limit the local weight earns
+// against the other nodes
+func weightPercent(weight float64, otherWeights []float64) float64 {
+	totalWeight := weight
+	for i := range otherWeights {
+		totalWeight += otherWeights[i]
+	}
+	return weight / totalWeight
+}
+
+func (cl *CommandLimiter) balanceLimit(averageQps float64, averageRate float64, limitDatadb *DB, titanStatusLifetime time.Duration,
 	devideUsage float64, multiplyUsage float64, weightChangeFactor float64) {
 	qpsGlobalLimit := float64(cl.qpsLw.getLimit())
-	if qpsGlobalLimit <= 0 {
+	rateGlobalLimit := float64(cl.rateLw.getLimit())
+	if qpsGlobalLimit <= 0 && rateGlobalLimit <= 0 {
 		return
 	}
 	if cl.getSkipBalance() {
@@ -446,62 +846,36 @@ func (cl *CommandLimiter) balanceLimit(averageQps float64, limitDatadb *DB, tita
 		return
 	}
 
-	weights, qpss, err := cl.scanStatusInOtherTitan(limitDatadb, txn, titanStatusLifetime)
+	qpsWeights, qpss, rateWeights, rates, err := cl.scanStatusInOtherTitan(limitDatadb, txn)
 	if err != nil {
 		txn.Rollback()
 		return
 	}
 
-	totalWeight := cl.weight
-	for i := range weights {
-		totalWeight += weights[i]
-	}
-
-	selfLimitInTarget := qpsGlobalLimit * (cl.weight / totalWeight)
-	if averageQps < selfLimitInTarget*devideUsage {
-		otherHaveHigh := false
-		otherAllLow := true
-		for i := range qpss {
-			otherLimitInTarget := qpsGlobalLimit * (weights[i] / totalWeight)
-			if qpss[i] >= otherLimitInTarget*multiplyUsage {
-				otherHaveHigh = true
-				otherAllLow = false
-				break
-			} else if qpss[i] >= otherLimitInTarget*devideUsage {
-				otherAllLow = false
-			}
-		}
-		if otherHaveHigh {
-			cl.weight /= weightChangeFactor
-			if cl.weight < MINIMUM_WEIGHT {
-				cl.weight = MINIMUM_WEIGHT
-			}
-		} else if otherAllLow {
-			cl.weight *= weightChangeFactor
-			if cl.weight > MAXIMUM_WEIGHT {
-				cl.weight = MAXIMUM_WEIGHT
-			}
-		}
-	} else if averageQps >= selfLimitInTarget*multiplyUsage {
-		cl.weight *= weightChangeFactor
-		if cl.weight > MAXIMUM_WEIGHT {
-			cl.weight = MAXIMUM_WEIGHT
-		}
+	// the two budgets balance independently, a node with few but huge
+	// commands gives back byte budget without losing its qps share
+	if qpsGlobalLimit > 0 {
+		cl.weight = adjustWeight(cl.weight, averageQps, qpsGlobalLimit, qpsWeights, qpss,
+			devideUsage, multiplyUsage, weightChangeFactor)
 	}
-
-	totalWeight = cl.weight
-	for i := range weights {
-		totalWeight += weights[i]
+	if rateGlobalLimit > 0 {
+		cl.rateWeight = adjustWeight(cl.rateWeight, averageRate, rateGlobalLimit, rateWeights, rates,
+			devideUsage, multiplyUsage, weightChangeFactor)
 	}
-	newPercent := cl.weight / totalWeight
+	newQpsPercent := weightPercent(cl.weight, qpsWeights)
+	newRatePercent := weightPercent(cl.rateWeight, rateWeights)
 
 	key := getLimiterKey(cl.limiterName)
 	key = append(key, []byte(cl.localIp)...)
 	s := NewString(txn, key)
-	now := time.Now()
-	strTime := now.Format(TIME_FORMAT)
-	value := fmt.Sprintf("%f%s%f%s%s", cl.weight, LIMITER_STATUS_VALUE_TOKEN, averageQps, LIMITER_STATUS_VALUE_TOKEN, strTime)
-	if err := s.Set([]byte(value), 0); err != nil {
+	// the status carries a TTL of the status lifetime, a dead node's record
+	// simply expires instead of being filtered by an embedded timestamp.
+	// The node epoch trailing the stats lets readers drop records of an
+	// older incarnation of a reused IP
+	value := fmt.Sprintf("%f%s%f%s%f%s%f%s%d", cl.weight, LIMITER_STATUS_VALUE_TOKEN, averageQps,
+		LIMITER_STATUS_VALUE_TOKEN, cl.rateWeight, LIMITER_STATUS_VALUE_TOKEN, averageRate,
+		LIMITER_STATUS_VALUE_TOKEN, cl.nodeEpoch)
+	if err := s.Set([]byte(value), int64(titanStatusLifetime)); err != nil {
 		txn.Rollback()
 		return
 	}
@@ -511,29 +885,34 @@ func (cl *CommandLimiter) balanceLimit(averageQps float64, limitDatadb *DB, tita
 		return
 	}
 	zap.L().Info("[Limit] balance limit", zap.String("limiterName", cl.limiterName),
-		zap.Float64("qps", averageQps), zap.Float64("newWeight", cl.weight), zap.Float64("newPercent", newPercent))
-	cl.qpsLw.updatePercent(newPercent)
-	cl.rateLw.updatePercent(newPercent)
+		zap.Float64("qps", averageQps), zap.Float64("newWeight", cl.weight), zap.Float64("newPercent", newQpsPercent),
+		zap.Float64("rate", averageRate), zap.Float64("newRateWeight", cl.rateWeight), zap.Float64("newRatePercent", newRatePercent))
+	if qpsGlobalLimit > 0 {
+		cl.qpsLw.updatePercent(newQpsPercent)
+	}
+	if rateGlobalLimit > 0 {
+		cl.rateLw.updatePercent(newRatePercent)
+	}
 }
 
-func (cl *CommandLimiter) scanStatusInOtherTitan(limitDatadb *DB, txn *Transaction, titanStatusLifetime time.Duration) ([]float64, []float64, error) {
+func (cl *CommandLimiter) scanStatusInOtherTitan(limitDatadb *DB, txn *Transaction) ([]float64, []float64, []float64, []float64, error) {
 	key := getLimiterKey(cl.limiterName)
 	prefix := MetaKey(limitDatadb, key)
 	endPrefix := sdk_kv.Key(prefix).PrefixNext()
 	iter, err := txn.t.Iter(prefix, endPrefix)
 	if err != nil {
 		zap.L().Error("[Limit] seek failed", zap.ByteString("prefix", prefix), zap.Error(err))
-		return nil, nil, err
+		return nil, nil, nil, nil, err
 	}
 	defer iter.Close()
 
 	prefixLen := len(prefix)
-	var weights, qpss []float64
+	var weights, qpss, rateWeights, rates []float64
 	var weight, qps float64
 	for ; iter.Valid() && iter.Key().HasPrefix(prefix); err = iter.Next() {
 		if err != nil {
 			zap.L().Error("[Limit] next failed", zap.ByteString("prefix", prefix), zap.Error(err))
-			return nil, nil, err
+			return nil, nil, nil, nil, err
 		}
 
 		key := iter.Key()
@@ -548,38 +927,63 @@ func (cl *CommandLimiter) scanStatusInOtherTitan(limitDatadb *DB, txn *Transacti
 			continue
 		}
 
+		// a dead node's status carries a TTL of the status lifetime, the
+		// expire subsystem removes it eventually and the check here covers
+		// the window until it does
+		if obj.Meta.ExpireAt != 0 && IsExpired(&obj.Meta.Object, Now()) {
+			continue
+		}
+
 		val := string(obj.Meta.Value)
 		vals := strings.Split(val, LIMITER_STATUS_VALUE_TOKEN)
-		if len(vals) < 3 {
-			zap.L().Error("[Limit] short of values(should 3 values)", zap.ByteString("key", key), zap.String("value", val))
+		if len(vals) != 4 && len(vals) != 5 {
+			zap.L().Error("[Limit] short of values(should 4 or 5 values)", zap.ByteString("key", key), zap.String("value", val))
 			continue
 		}
-		sWeight := vals[0]
-		sQps := vals[1]
-		lastActive := vals[2]
 
-		if weight, err = strconv.ParseFloat(sWeight, 64); err != nil {
-			zap.L().Error("[Limit] weight can't be decoded to float", zap.ByteString("key", key), zap.String("weight", sWeight), zap.Error(err))
+		if weight, err = strconv.ParseFloat(vals[0], 64); err != nil {
+			zap.L().Error("[Limit] weight can't be decoded to float", zap.ByteString("key", key), zap.String("weight", vals[0]), zap.Error(err))
 			continue
 		}
-		if qps, err = strconv.ParseFloat(sQps, 64); err != nil {
-			zap.L().Error("[Limit] qps can't be decoded to float", zap.ByteString("key", key), zap.String("qps", sQps), zap.Error(err))
+		if qps, err = strconv.ParseFloat(vals[1], 64); err != nil {
+			zap.L().Error("[Limit] qps can't be decoded to float", zap.ByteString("key", key), zap.String("qps", vals[1]), zap.Error(err))
 			continue
 		}
-
-		lastActiveT, err := time.ParseInLocation(TIME_FORMAT, lastActive, time.Local)
+		rateWeight, err := strconv.ParseFloat(vals[2], 64)
+		if err != nil {
+			zap.L().Error("[Limit] rate weight can't be decoded to float", zap.ByteString("key", key), zap.String("rateWeight", vals[2]), zap.Error(err))
+			continue
+		}
+		rate, err := strconv.ParseFloat(vals[3], 64)
 		if err != nil {
-			zap.L().Error("[Limit] value can't decoded into a time", zap.ByteString("key", key), zap.String("lastActive", lastActive), zap.Error(err))
+			zap.L().Error("[Limit] rate can't be decoded to float", zap.ByteString("key", key), zap.String("rate", vals[3]), zap.Error(err))
 			continue
 		}
+		// records written before epochs were published count as epoch 0
+		epoch := int64(0)
+		if len(vals) == 5 {
+			if epoch, err = strconv.ParseInt(vals[4], 10, 64); err != nil {
+				zap.L().Error("[Limit] epoch can't be decoded to int", zap.ByteString("key", key), zap.String("epoch", vals[4]), zap.Error(err))
+				continue
+			}
+		}
+		if last, ok := cl.peerEpochs[string(ip)]; ok && epoch < last {
+			zap.L().Warn("[Limit] skip status of an older node epoch", zap.ByteString("key", key),
+				zap.Int64("epoch", epoch), zap.Int64("lastEpoch", last))
+			continue
+		}
+		cl.peerEpochs[string(ip)] = epoch
 
-		zap.L().Info("[Limit] titan status", zap.ByteString("key", key), zap.Float64("weight", weight), zap.Float64("qps", qps), zap.String("lastActive", lastActive))
-		if string(ip) != cl.localIp && time.Since(lastActiveT) <= titanStatusLifetime {
+		zap.L().Info("[Limit] titan status", zap.ByteString("key", key), zap.Float64("weight", weight), zap.Float64("qps", qps),
+			zap.Float64("rateWeight", rateWeight), zap.Float64("rate", rate))
+		if string(ip) != cl.localIp {
 			weights = append(weights, weight)
 			qpss = append(qpss, qps)
+			rateWeights = append(rateWeights, rateWeight)
+			rates = append(rates, rate)
 		}
 	}
-	return weights, qpss, nil
+	return weights, qpss, rateWeights, rates, nil
 }
 
 func (cl *CommandLimiter) checkLimit(cmdName string, cmdArgs []string) {
@@ -658,6 +1062,17 @@ func (lw *LimiterWrapper) getLimit() int64 {
 	return lw.globalLimit
 }
 
+func (lw *LimiterWrapper) getLimitAndBurst() (int64, int) {
+	lw.lock.Lock()
+	defer lw.lock.Unlock()
+
+	burst := 0
+	if lw.limiter != nil {
+		burst = lw.limiter.Burst()
+	}
+	return lw.globalLimit, burst
+}
+
 func (lw *LimiterWrapper) updatePercent(newPercent float64) {
 	lw.lock.Lock()
 	defer lw.lock.Unlock()
@@ -0,0 +1,186 @@
+package db
+
+import (
+	"context"
+	"testing"
+
+	"github.com/distributedio/titan/conf"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestAdjustWeight checks the usage driven weight rules on one dimension,
+// the same function balances qps and byte rate independently
+func TestAdjustWeight(t *testing.T) {
+	// overloaded node keeps growing its weight up to the maximum
+	w := adjustWeight(0.5, 95, 100, []float64{0.5}, []float64{10}, 0.6, 0.9, 1.5)
+	assert.Equal(t, 0.75, w)
+	w = adjustWeight(MAXIMUM_WEIGHT, 95, 100, []float64{0.5}, []float64{10}, 0.6, 0.9, 1.5)
+	assert.Equal(t, float64(MAXIMUM_WEIGHT), w)
+
+	// idle node gives back budget when another node runs hot
+	w = adjustWeight(1, 1, 100, []float64{1}, []float64{50}, 0.6, 0.9, 1.5)
+	assert.True(t, w < 1)
+	w = adjustWeight(MINIMUM_WEIGHT, 0, 100, []float64{1}, []float64{90}, 0.6, 0.9, 1.5)
+	assert.Equal(t, float64(MINIMUM_WEIGHT), w)
+
+	// everyone idle, weights drift back up together
+	w = adjustWeight(0.5, 1, 100, []float64{0.5}, []float64{1}, 0.6, 0.9, 1.5)
+	assert.Equal(t, 0.75, w)
+
+	assert.Equal(t, 0.25, weightPercent(0.5, []float64{1, 0.5}))
+}
+
+// TestLimiterDataLifecycle covers the operator facing rule management and
+// the one-time migration out of the old world readable limiter namespace
+func TestLimiterDataLifecycle(t *testing.T) {
+	rateLimit := conf.MockConf().Tikv.RateLimit
+	l := &LimitersMgr{
+		limitDatadb: mockDB.kv.DB(rateLimit.LimiterNamespace, LIMITDATA_DBID),
+		conf:        &rateLimit,
+	}
+
+	// a rule written before the move must survive the migration
+	legacy := &LimitersMgr{
+		limitDatadb: mockDB.kv.DB(legacyLimiterNamespace, LIMITDATA_DBID),
+		conf:        &rateLimit,
+	}
+	assert.NoError(t, legacy.SetLimit("qps:migtenant@get", "50 5"))
+	assert.NoError(t, l.migrateLegacyLimiterData())
+	limit, burst := l.getLimit("migtenant@get", true)
+	assert.Equal(t, int64(50), limit)
+	assert.Equal(t, 5, burst)
+
+	// rule management through the manager, the getLimit path the limiters
+	// use must see what SetLimit wrote
+	assert.NoError(t, l.SetLimit("qps:tenant@get", "2k 10"))
+	val, err := l.GetLimit("qps:tenant@get")
+	assert.NoError(t, err)
+	assert.Equal(t, "2k 10", string(val))
+	limit, burst = l.getLimit("tenant@get", true)
+	assert.Equal(t, int64(2048), limit)
+	assert.Equal(t, 10, burst)
+
+	deleted, err := l.DelLimit("qps:tenant@get")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), deleted)
+	val, err = l.GetLimit("qps:tenant@get")
+	assert.NoError(t, err)
+	assert.Nil(t, val)
+
+	// every rule change bumps the config version so watching nodes sync
+	// without waiting for the polling period
+	version, err := l.readConfigVersion()
+	assert.NoError(t, err)
+	assert.NoError(t, l.SetLimit("qps:tenant@set", "100 10"))
+	bumped, err := l.readConfigVersion()
+	assert.NoError(t, err)
+	assert.Equal(t, version+1, bumped)
+	deleted, err = l.DelLimit("qps:tenant@set")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), deleted)
+	bumped, err = l.readConfigVersion()
+	assert.NoError(t, err)
+	assert.Equal(t, version+2, bumped)
+	// deleting a rule that is not there must not pretend a change happened
+	deleted, err = l.DelLimit("qps:tenant@set")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), deleted)
+	unchanged, err := l.readConfigVersion()
+	assert.NoError(t, err)
+	assert.Equal(t, bumped, unchanged)
+
+	// the destination is populated now, a second run must not copy again
+	assert.NoError(t, legacy.SetLimit("qps:migtenant2@get", "60 6"))
+	assert.NoError(t, l.migrateLegacyLimiterData())
+	limit, burst = l.getLimit("migtenant2@get", true)
+	assert.Equal(t, int64(0), limit)
+	assert.Equal(t, 0, burst)
+}
+
+// TestInitialLimits checks the bootstrap rules of the config file, they
+// apply while tikv holds no matching key and a written key overrides them
+func TestInitialLimits(t *testing.T) {
+	defaults, err := parseInitialLimits("qps:boottenant@get=1k 10, rate:*@set=1M 5")
+	assert.NoError(t, err)
+	assert.Len(t, defaults, 2)
+
+	// malformed rules are rejected at startup
+	_, err = parseInitialLimits("qps:boottenant@get")
+	assert.Error(t, err)
+	_, err = parseInitialLimits("boottenant@get=1k 10")
+	assert.Error(t, err)
+	_, err = parseInitialLimits("qps:boottenant=1k 10")
+	assert.Error(t, err)
+	_, err = parseInitialLimits("qps:boottenant@get=fast 10")
+	assert.Error(t, err)
+
+	rateLimit := conf.MockConf().Tikv.RateLimit
+	l := &LimitersMgr{
+		limitDatadb:   mockDB.kv.DB(rateLimit.LimiterNamespace, LIMITDATA_DBID),
+		conf:          &rateLimit,
+		defaultLimits: defaults,
+	}
+
+	// no key in tikv yet, the file default is enforced
+	limit, burst := l.getLimit("boottenant@get", true)
+	assert.Equal(t, int64(1024), limit)
+	assert.Equal(t, 10, burst)
+	limit, burst = l.getLimit("*@set", false)
+	assert.Equal(t, int64(1024*1024), limit)
+	assert.Equal(t, 5, burst)
+
+	// a rule written to tikv wins over the file default
+	assert.NoError(t, l.SetLimit("qps:boottenant@get", "2k 20"))
+	limit, burst = l.getLimit("boottenant@get", true)
+	assert.Equal(t, int64(2048), limit)
+	assert.Equal(t, 20, burst)
+
+	// deleting the key falls back to the file default again
+	_, err = l.DelLimit("qps:boottenant@get")
+	assert.NoError(t, err)
+	limit, burst = l.getLimit("boottenant@get", true)
+	assert.Equal(t, int64(1024), limit)
+	assert.Equal(t, 10, burst)
+
+	// no default and no key means no limit
+	limit, burst = l.getLimit("boottenant@set", true)
+	assert.Equal(t, int64(0), limit)
+	assert.Equal(t, 0, burst)
+}
+
+// TestClearStaleNodeStatus checks that a restarting node removes the
+// status records its previous incarnation left behind, and nothing else
+func TestClearStaleNodeStatus(t *testing.T) {
+	rateLimit := conf.MockConf().Tikv.RateLimit
+	l := &LimitersMgr{
+		limitDatadb: mockDB.kv.DB(rateLimit.LimiterNamespace, LIMITDATA_DBID),
+		conf:        &rateLimit,
+		localIp:     "10.0.0.9",
+	}
+
+	writeStatus := func(ip string) {
+		txn, err := l.limitDatadb.Begin()
+		assert.NoError(t, err)
+		key := append(getLimiterKey("tenant@get"), []byte(ip)...)
+		s := NewString(txn, key)
+		assert.NoError(t, s.Set([]byte("1.0,10.0,1.0,10.0,1")))
+		assert.NoError(t, txn.Commit(context.TODO()))
+	}
+	writeStatus(l.localIp)
+	writeStatus("10.0.0.10")
+
+	assert.NoError(t, l.clearStaleNodeStatus())
+
+	readStatus := func(ip string) error {
+		txn, err := l.limitDatadb.Begin()
+		assert.NoError(t, err)
+		defer txn.Rollback()
+		key := append(getLimiterKey("tenant@get"), []byte(ip)...)
+		s, err := txn.String(key)
+		assert.NoError(t, err)
+		_, err = s.Get()
+		return err
+	}
+	assert.Equal(t, ErrKeyNotFound, readStatus(l.localIp))
+	assert.NoError(t, readStatus("10.0.0.10"))
+}
@@ -42,18 +42,26 @@ func GetList(txn *Transaction, key []byte, opts ...ListOption) (List, error) {
 	}
 
 	// exist
-	obj, err := DecodeObject(val)
+	objType, expireAt, err := peekMeta(val)
 	if err != nil {
 		return nil, err
 	}
-	if IsExpired(obj, Now()) {
+	if expireAt != 0 && expireAt <= Now() {
+		if obj, err := DecodeObject(val); err == nil {
+			tryLazyExpire(txn.db, key, obj)
+		}
 		return list(txn, key), nil
 	}
 
-	if obj.Type != ObjectList {
+	if objType != ObjectList {
 		return nil, ErrTypeMismatch
 	}
 
+	obj, err := DecodeObject(val)
+	if err != nil {
+		return nil, err
+	}
+
 	if obj.Encoding == ObjectEncodingLinkedlist {
 		return GetLList(txn, metaKey, obj, val)
 	} else if obj.Encoding == ObjectEncodingZiplist {
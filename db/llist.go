@@ -33,7 +33,7 @@ type LList struct {
 	txn              *Transaction
 }
 
-//GetLList returns a list
+// GetLList returns a list
 func GetLList(txn *Transaction, metaKey []byte, obj *Object, val []byte) (List, error) {
 	l := &LList{
 		txn:        txn,
@@ -47,7 +47,7 @@ func GetLList(txn *Transaction, metaKey []byte, obj *Object, val []byte) (List,
 	return l, nil
 }
 
-//NewLList creates a new list
+// NewLList creates a new list
 func NewLList(txn *Transaction, key []byte) List {
 	now := Now()
 	metaKey := MetaKey(txn.db, key)
@@ -0,0 +1,405 @@
+package db
+
+import (
+	"context"
+	"encoding/binary"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/distributedio/titan/db/keyspace"
+	"github.com/pingcap/tidb/kv"
+	"go.uber.org/zap"
+)
+
+var (
+	// sysNamespacePrefix prefixes the registry entries of known
+	// namespaces, the rest of the key is the namespace name
+	sysNamespacePrefix = []byte("$sys:0:NSR:")
+	// sysNamespaceIDKey holds the next id the default allocator hands out
+	sysNamespaceIDKey = []byte("$sys:0:NSID:next")
+)
+
+// NamespaceMeta is the registry record of a namespace
+type NamespaceMeta struct {
+	ID        uint32
+	CreatedAt int64
+	Flags     uint32
+	// Generation is the flush epoch of the namespace, every key built
+	// for the namespace carries it in the prefix. A flush bumps it, see
+	// BumpGeneration
+	Generation uint32
+}
+
+// NamespaceEntry pairs a namespace name with its registry record, for
+// listings
+type NamespaceEntry struct {
+	Name string
+	Meta NamespaceMeta
+}
+
+const namespaceMetaLen = 20
+
+func encodeNamespaceMeta(meta NamespaceMeta) []byte {
+	b := make([]byte, namespaceMetaLen)
+	binary.BigEndian.PutUint32(b[0:], meta.ID)
+	binary.BigEndian.PutUint64(b[4:], uint64(meta.CreatedAt))
+	binary.BigEndian.PutUint32(b[12:], meta.Flags)
+	binary.BigEndian.PutUint32(b[16:], meta.Generation)
+	return b
+}
+
+func decodeNamespaceMeta(b []byte) (NamespaceMeta, error) {
+	// records written before generations existed are 4 bytes shorter and
+	// decode as generation zero
+	if len(b) < namespaceMetaLen-4 {
+		return NamespaceMeta{}, ErrInvalidLength
+	}
+	meta := NamespaceMeta{
+		ID:        binary.BigEndian.Uint32(b[0:]),
+		CreatedAt: int64(binary.BigEndian.Uint64(b[4:])),
+		Flags:     binary.BigEndian.Uint32(b[12:]),
+	}
+	if len(b) >= namespaceMetaLen {
+		meta.Generation = binary.BigEndian.Uint32(b[16:])
+	}
+	return meta, nil
+}
+
+// ValidNamespaceName reports whether name keeps the key layout
+// unambiguous. The key separator and the generation tag are reserved, a
+// namespace named "ns@1" would alias generation one of "ns"
+func ValidNamespaceName(namespace string) bool {
+	return !strings.ContainsRune(namespace, keyspace.Separator) &&
+		!strings.ContainsRune(namespace, keyspace.GenerationTag)
+}
+
+// namespaceGenerations publishes the flush generation of every namespace
+// seen by the registry, the key builders consult it on every key they
+// assemble. An untracked namespace is generation zero
+var namespaceGenerations sync.Map
+
+func currentGeneration(namespace string) uint32 {
+	if v, ok := namespaceGenerations.Load(namespace); ok {
+		return v.(uint32)
+	}
+	return 0
+}
+
+// storageNamespace returns the namespace section keys of the namespace
+// are built with, carrying the current flush generation
+func storageNamespace(namespace string) []byte {
+	return keyspace.GenerationNamespace([]byte(namespace), currentGeneration(namespace))
+}
+
+// activeRegistry is the node wide registry, set once at startup like the
+// breaker. The flush path reaches it through GetNamespaceRegistry
+var activeRegistry *NamespaceRegistry
+
+// GetNamespaceRegistry returns the node wide registry, nil when none was
+// created
+func GetNamespaceRegistry() *NamespaceRegistry {
+	return activeRegistry
+}
+
+// IDAllocator hands out the id of a newly registered namespace, it runs
+// inside the registering transaction so an allocation never outlives a
+// failed registration. Implementations other than the default counter can
+// plug in ids minted by an external control plane
+type IDAllocator interface {
+	NextID(txn *Transaction) (uint32, error)
+}
+
+// counterAllocator is the default allocator, a counter in the system
+// keyspace incremented inside the registering transaction. Two nodes
+// registering concurrently conflict on the counter key and one of them
+// retries, so ids are never handed out twice
+type counterAllocator struct{}
+
+func (counterAllocator) NextID(txn *Transaction) (uint32, error) {
+	next := uint32(1)
+	val, err := txn.t.Get(sysNamespaceIDKey)
+	if err != nil && !IsErrNotFound(err) {
+		return 0, err
+	}
+	if err == nil {
+		next = binary.BigEndian.Uint32(val) + 1
+	}
+	b := make([]byte, 4)
+	binary.BigEndian.PutUint32(b, next)
+	if err := txn.t.Set(sysNamespaceIDKey, b); err != nil {
+		return 0, err
+	}
+	return next, nil
+}
+
+// NamespaceRegistry persists the name to id association of namespaces in
+// the system keyspace and registers unknown namespaces on their first
+// use. The association is what quotas and per namespace settings key on,
+// key bytes alone only carry the name
+type NamespaceRegistry struct {
+	db    *DB
+	alloc IDAllocator
+
+	mu    sync.RWMutex
+	cache map[string]NamespaceMeta
+}
+
+// namespaceSyncPeriod is how often a node reloads the registry, a flush
+// generation bumped on one node is seen by every other node within it
+const namespaceSyncPeriod = 5 * time.Second
+
+// NewNamespaceRegistry creates a registry over the system keyspace of
+// store and starts the background sync, a nil alloc selects the default
+// counter allocator. The registry becomes the node wide one
+func NewNamespaceRegistry(store *RedisStore, alloc IDAllocator) *NamespaceRegistry {
+	if alloc == nil {
+		alloc = counterAllocator{}
+	}
+	r := &NamespaceRegistry{
+		db:    store.DB(sysNamespace, sysDatabaseID),
+		alloc: alloc,
+		cache: make(map[string]NamespaceMeta),
+	}
+	if err := r.refresh(); err != nil {
+		zap.L().Error("[NamespaceRegistry] initial refresh failed", zap.Error(err))
+	}
+	go r.run()
+	activeRegistry = r
+	return r
+}
+
+func (r *NamespaceRegistry) run() {
+	ticker := time.NewTicker(namespaceSyncPeriod)
+	defer ticker.Stop()
+	for range ticker.C {
+		if err := r.refresh(); err != nil {
+			zap.L().Error("[NamespaceRegistry] refresh failed", zap.Error(err))
+		}
+	}
+}
+
+// refresh replaces the cache with the records currently stored in tikv
+// and publishes their generations
+func (r *NamespaceRegistry) refresh() error {
+	entries, err := r.List()
+	if err != nil {
+		return err
+	}
+	cache := make(map[string]NamespaceMeta, len(entries))
+	for _, e := range entries {
+		cache[e.Name] = e.Meta
+		namespaceGenerations.Store(e.Name, e.Meta.Generation)
+	}
+	r.mu.Lock()
+	r.cache = cache
+	r.mu.Unlock()
+	return nil
+}
+
+// Bootstrap returns the registry record of namespace, registering it with
+// a fresh id when it is seen for the first time. Records are cached, the
+// store is only touched on the first call per namespace and node
+func (r *NamespaceRegistry) Bootstrap(namespace string) (NamespaceMeta, error) {
+	if !ValidNamespaceName(namespace) {
+		return NamespaceMeta{}, ErrInvalidNamespace
+	}
+	r.mu.RLock()
+	meta, ok := r.cache[namespace]
+	r.mu.RUnlock()
+	if ok {
+		return meta, nil
+	}
+
+	txn, err := r.db.Begin()
+	if err != nil {
+		return NamespaceMeta{}, err
+	}
+	key := append(append([]byte{}, sysNamespacePrefix...), namespace...)
+	val, err := txn.t.Get(key)
+	if err != nil && !IsErrNotFound(err) {
+		txn.Rollback()
+		return NamespaceMeta{}, err
+	}
+	if err == nil {
+		txn.Rollback()
+		if meta, err = decodeNamespaceMeta(val); err != nil {
+			return NamespaceMeta{}, err
+		}
+	} else {
+		id, err := r.alloc.NextID(txn)
+		if err != nil {
+			txn.Rollback()
+			return NamespaceMeta{}, err
+		}
+		meta = NamespaceMeta{ID: id, CreatedAt: time.Now().UnixNano()}
+		if err := txn.t.Set(key, encodeNamespaceMeta(meta)); err != nil {
+			txn.Rollback()
+			return NamespaceMeta{}, err
+		}
+		if err := txn.Commit(context.Background()); err != nil {
+			return NamespaceMeta{}, err
+		}
+	}
+
+	r.mu.Lock()
+	r.cache[namespace] = meta
+	r.mu.Unlock()
+	namespaceGenerations.Store(namespace, meta.Generation)
+	return meta, nil
+}
+
+// publishGeneration makes a generation learned outside the regular sync
+// visible to the key builders at once
+func (r *NamespaceRegistry) publishGeneration(namespace string, gen uint32) {
+	r.mu.Lock()
+	if meta, ok := r.cache[namespace]; ok && meta.Generation != gen {
+		meta.Generation = gen
+		r.cache[namespace] = meta
+	}
+	r.mu.Unlock()
+	namespaceGenerations.Store(namespace, gen)
+}
+
+// verifyGeneration rechecks, right before the commit, that the flush
+// generation the transaction was begun under is still the stored one. A
+// flush on another node bumps the generation there first and this node
+// only hears of it on its next sync, a write acknowledged inside that
+// window would land in the range already handed to the gc. The fresh
+// read fences the window: a stale commit publishes the new generation
+// and fails retryable, the replayed command rebuilds its keys on the new
+// prefix. A write the bump overtakes after this check is ordered before
+// the flush completed and flushing it is legitimate.
+//
+// The read costs a round trip on a single shared key, so namespaces this
+// node has never seen flushed skip it, which is nearly every commit.
+// Only the very first flush of a namespace is then covered by the sync
+// period alone, once a generation exists every commit verifies
+func (txn *Transaction) verifyGeneration() error {
+	r := GetNamespaceRegistry()
+	if r == nil || txn.t.IsReadOnly() || IsReservedNamespace(txn.db.Namespace) {
+		return nil
+	}
+	// a sync that landed while the transaction ran already proves the
+	// generation stale without a read
+	if currentGeneration(txn.db.Namespace) != txn.gen {
+		return ErrGenerationStale
+	}
+	if txn.gen == 0 {
+		return nil
+	}
+	snap, err := txn.db.kv.GetSnapshot(kv.MaxVersion)
+	if err != nil {
+		return err
+	}
+	key := append(append([]byte{}, sysNamespacePrefix...), txn.db.Namespace...)
+	val, err := snap.Get(key)
+	if err != nil && !IsErrNotFound(err) {
+		return err
+	}
+	var gen uint32
+	if err == nil {
+		meta, err := decodeNamespaceMeta(val)
+		if err != nil {
+			return err
+		}
+		gen = meta.Generation
+	}
+	if gen == txn.gen {
+		return nil
+	}
+	r.publishGeneration(txn.db.Namespace, gen)
+	return ErrGenerationStale
+}
+
+// BumpGeneration advances the flush generation of namespace and hands
+// the given outgoing prefixes to the background gc in the same
+// transaction, so a flush either fully happens or not at all. The new
+// generation takes effect on this node immediately, on the others within
+// their sync period, and a write racing the bump from a node that has
+// not synced yet is caught by the commit fence, see verifyGeneration
+func (r *NamespaceRegistry) BumpGeneration(namespace string, oldPrefixes ...[]byte) (uint32, error) {
+	if !ValidNamespaceName(namespace) {
+		return 0, ErrInvalidNamespace
+	}
+	txn, err := r.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	key := append(append([]byte{}, sysNamespacePrefix...), namespace...)
+	val, err := txn.t.Get(key)
+	if err != nil && !IsErrNotFound(err) {
+		txn.Rollback()
+		return 0, err
+	}
+	var meta NamespaceMeta
+	if err == nil {
+		if meta, err = decodeNamespaceMeta(val); err != nil {
+			txn.Rollback()
+			return 0, err
+		}
+	} else {
+		// flushing a namespace never seen before still registers it
+		id, err := r.alloc.NextID(txn)
+		if err != nil {
+			txn.Rollback()
+			return 0, err
+		}
+		meta = NamespaceMeta{ID: id, CreatedAt: time.Now().UnixNano()}
+	}
+	meta.Generation++
+	if err := txn.t.Set(key, encodeNamespaceMeta(meta)); err != nil {
+		txn.Rollback()
+		return 0, err
+	}
+	if err := gc(txn.t, oldPrefixes...); err != nil {
+		txn.Rollback()
+		return 0, err
+	}
+	if err := txn.Commit(context.Background()); err != nil {
+		return 0, err
+	}
+
+	r.mu.Lock()
+	r.cache[namespace] = meta
+	r.mu.Unlock()
+	namespaceGenerations.Store(namespace, meta.Generation)
+	return meta.Generation, nil
+}
+
+// Get returns the cached record of namespace without touching the store
+func (r *NamespaceRegistry) Get(namespace string) (NamespaceMeta, bool) {
+	r.mu.RLock()
+	meta, ok := r.cache[namespace]
+	r.mu.RUnlock()
+	return meta, ok
+}
+
+// List scans the registry and returns every known namespace in name order
+func (r *NamespaceRegistry) List() ([]NamespaceEntry, error) {
+	txn, err := r.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer txn.Rollback()
+	iter, err := txn.t.Iter(sysNamespacePrefix, kv.Key(sysNamespacePrefix).PrefixNext())
+	if err != nil {
+		return nil, err
+	}
+	defer iter.Close()
+	var entries []NamespaceEntry
+	for ; iter.Valid() && iter.Key().HasPrefix(sysNamespacePrefix); err = iter.Next() {
+		if err != nil {
+			return nil, err
+		}
+		meta, err := decodeNamespaceMeta(iter.Value())
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, NamespaceEntry{
+			Name: string(iter.Key()[len(sysNamespacePrefix):]),
+			Meta: meta,
+		})
+	}
+	return entries, nil
+}
@@ -0,0 +1,178 @@
+package db
+
+import (
+	"context"
+	"testing"
+
+	"github.com/distributedio/titan/conf"
+	"github.com/distributedio/titan/db/keyspace"
+	"github.com/pingcap/tidb/store/mockstore"
+	"github.com/stretchr/testify/assert"
+)
+
+func mockRedisStore(t *testing.T) *RedisStore {
+	store, err := mockstore.NewMockTikvStore()
+	assert.NoError(t, err)
+	mockConf := conf.MockConf()
+	return &RedisStore{Storage: store, conf: &mockConf.Tikv}
+}
+
+func TestNamespaceRegistry(t *testing.T) {
+	reg := NewNamespaceRegistry(mockRedisStore(t), nil)
+
+	// the first use of a namespace registers it with a fresh id
+	meta, err := reg.Bootstrap("tenant-a")
+	assert.NoError(t, err)
+	assert.Equal(t, uint32(1), meta.ID)
+	assert.True(t, meta.CreatedAt > 0)
+
+	metaB, err := reg.Bootstrap("tenant-b")
+	assert.NoError(t, err)
+	assert.Equal(t, uint32(2), metaB.ID)
+
+	// bootstrapping again returns the same record
+	again, err := reg.Bootstrap("tenant-a")
+	assert.NoError(t, err)
+	assert.Equal(t, meta, again)
+
+	// a second registry over the same store sees the stored records, not
+	// fresh ids
+	other := NewNamespaceRegistry(&RedisStore{Storage: reg.db.kv.Storage, conf: reg.db.kv.conf}, nil)
+	same, err := other.Bootstrap("tenant-a")
+	assert.NoError(t, err)
+	assert.Equal(t, meta, same)
+
+	// Get only answers from the cache
+	_, ok := reg.Get("tenant-a")
+	assert.True(t, ok)
+	_, ok = reg.Get("tenant-c")
+	assert.False(t, ok)
+
+	entries, err := reg.List()
+	assert.NoError(t, err)
+	assert.Len(t, entries, 2)
+	assert.Equal(t, "tenant-a", entries[0].Name)
+	assert.Equal(t, "tenant-b", entries[1].Name)
+
+	// names carrying the key separator or the generation tag would make
+	// keys ambiguous and are rejected
+	_, err = reg.Bootstrap("bad:name")
+	assert.Equal(t, ErrInvalidNamespace, err)
+	_, err = reg.Bootstrap("bad@1")
+	assert.Equal(t, ErrInvalidNamespace, err)
+	_, err = reg.BumpGeneration("bad@1")
+	assert.Equal(t, ErrInvalidNamespace, err)
+}
+
+func TestFlushByGeneration(t *testing.T) {
+	db := MockDB()
+	reg := NewNamespaceRegistry(db.kv, nil)
+	defer func() {
+		activeRegistry = nil
+		namespaceGenerations.Delete(db.Namespace)
+	}()
+
+	txn, err := db.Begin()
+	assert.NoError(t, err)
+	str := NewString(txn, []byte("flush-gen-key"))
+	assert.NoError(t, str.Set([]byte("v")))
+	assert.NoError(t, txn.Commit(context.TODO()))
+	oldPrefix := dbPrefix(db.Namespace, nil)
+
+	// the flush bumps the generation instead of deleting in place
+	txn, err = db.Begin()
+	assert.NoError(t, err)
+	assert.NoError(t, GetKv(txn).FlushDB(context.TODO()))
+	assert.NoError(t, txn.Commit(context.TODO()))
+	meta, ok := reg.Get(db.Namespace)
+	assert.True(t, ok)
+	assert.Equal(t, uint32(1), meta.Generation)
+
+	// the key is invisible at once, new writes land in the new prefix
+	txn, err = db.Begin()
+	assert.NoError(t, err)
+	str, err = GetString(txn, []byte("flush-gen-key"))
+	assert.NoError(t, err)
+	assert.False(t, str.Exist())
+	assert.NotEqual(t, oldPrefix, dbPrefix(db.Namespace, nil))
+
+	// the outgoing range is queued for the background gc
+	gcVal, err := txn.t.Get(keyspace.GCKey(oldPrefix))
+	assert.NoError(t, err)
+	assert.NotNil(t, gcVal)
+	assert.NoError(t, txn.Commit(context.TODO()))
+
+	// a FLUSHDB of one db never bumps the generation while another db
+	// of the namespace holds data
+	other := &DB{Namespace: db.Namespace, ID: DBID(2), kv: db.kv}
+	txn, err = other.Begin()
+	assert.NoError(t, err)
+	str = NewString(txn, []byte("other-db-key"))
+	assert.NoError(t, str.Set([]byte("v")))
+	assert.NoError(t, txn.Commit(context.TODO()))
+
+	txn, err = db.Begin()
+	assert.NoError(t, err)
+	ok, err = GetKv(txn).flushByGeneration(false)
+	assert.NoError(t, err)
+	assert.False(t, ok)
+	assert.NoError(t, txn.Rollback())
+}
+
+func TestGenerationCommitFence(t *testing.T) {
+	db := MockDB()
+	reg := NewNamespaceRegistry(db.kv, nil)
+	defer func() {
+		activeRegistry = nil
+		namespaceGenerations.Delete(db.Namespace)
+	}()
+
+	// a never flushed namespace commits without touching the registry
+	txn, err := db.Begin()
+	assert.NoError(t, err)
+	str := NewString(txn, []byte("fence-key"))
+	assert.NoError(t, str.Set([]byte("v")))
+	assert.NoError(t, txn.Commit(context.TODO()))
+
+	// first flush, the node is synced afterwards
+	_, err = reg.BumpGeneration(db.Namespace, dbPrefix(db.Namespace, nil))
+	assert.NoError(t, err)
+	assert.Equal(t, uint32(1), currentGeneration(db.Namespace))
+
+	// the next write begins under generation one
+	txn, err = db.Begin()
+	assert.NoError(t, err)
+	str = NewString(txn, []byte("fence-key"))
+	assert.NoError(t, str.Set([]byte("v")))
+
+	// a second flush lands on another node while the write is in flight,
+	// this node has not synced the bump yet
+	_, err = reg.BumpGeneration(db.Namespace, dbPrefix(db.Namespace, nil))
+	assert.NoError(t, err)
+	namespaceGenerations.Store(db.Namespace, uint32(1))
+
+	// the commit detects the stale generation, fails retryable and
+	// publishes the bumped one for the replay
+	err = txn.Commit(context.TODO())
+	assert.Error(t, err)
+	assert.True(t, IsRetryableError(err))
+	assert.Equal(t, uint32(2), currentGeneration(db.Namespace))
+
+	// a transaction overtaken by the local sync fails without the read
+	txn, err = db.Begin()
+	assert.NoError(t, err)
+	str = NewString(txn, []byte("fence-key"))
+	assert.NoError(t, str.Set([]byte("v")))
+	namespaceGenerations.Store(db.Namespace, uint32(3))
+	err = txn.Commit(context.TODO())
+	assert.Error(t, err)
+	assert.True(t, IsRetryableError(err))
+	namespaceGenerations.Store(db.Namespace, uint32(2))
+
+	// the replayed write lands on the new prefix and commits cleanly
+	txn, err = db.Begin()
+	assert.NoError(t, err)
+	str = NewString(txn, []byte("fence-key"))
+	assert.NoError(t, str.Set([]byte("v")))
+	assert.NoError(t, txn.Commit(context.TODO()))
+}
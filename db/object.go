@@ -72,6 +72,8 @@ func (t ObjectType) String() string {
 		return "zset"
 	case ObjectHash:
 		return "hash"
+	case ObjectBitmap:
+		return "bitmap"
 	}
 	return "none"
 }
@@ -83,18 +85,35 @@ const (
 	ObjectSet
 	ObjectZSet
 	ObjectHash
+	ObjectBitmap
 )
 
+// ObjectTypeByName resolves the name the TYPE command replies back to an
+// ObjectType, it reports false for an unknown name
+func ObjectTypeByName(name string) (ObjectType, bool) {
+	for t := ObjectString; t <= ObjectBitmap; t++ {
+		if t.String() == name {
+			return t, true
+		}
+	}
+	return 0, false
+}
+
 // Object meta schema
-//   Layout {DB}:{TAG}:{Key}
-//   DB     [0-255]
-//   Key    Usersapce key
-//   TAG    M(Meta), D(Data)
+//
+//	Layout {DB}:{TAG}:{Key}
+//	DB     [0-255]
+//	Key    Usersapce key
+//	TAG    M(Meta), D(Data)
+//
 // Object data schema
-//   Layout: {DB}:{TAG}:{ID}:{Others}
-//   ID     Object ID, ID is not used for meta
+//
+//	Layout: {DB}:{TAG}:{ID}:{Others}
+//	ID     Object ID, ID is not used for meta
+//
 // String schema (associated value with meta)
-//   Layout: {DB}:M:{key}
+//
+//	Layout: {DB}:M:{key}
 type Object struct {
 	ID        []byte
 	Type      ObjectType     //refer to redis
@@ -118,6 +137,7 @@ func (txn *Transaction) Object(key []byte) (*Object, error) {
 		return nil, err
 	}
 	if IsExpired(obj, Now()) {
+		tryLazyExpire(txn.db, key, obj)
 		return nil, ErrKeyNotFound
 	}
 	return obj, nil
@@ -0,0 +1,218 @@
+package db
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+
+	"github.com/pingcap/tidb/kv"
+)
+
+// QuickCheck verifies the internal invariants of the object stored at key and
+// returns a report line per check. When repair is true, discrepancies that can
+// be fixed in place are repaired within the transaction: missing or orphan
+// zset score keys, a drifted meta Len and a lost expire-index key
+func (txn *Transaction) QuickCheck(key []byte, repair bool) ([]string, error) {
+	var report []string
+
+	mkey := MetaKey(txn.db, key)
+	meta, err := txn.t.Get(mkey)
+	if err != nil {
+		if IsErrNotFound(err) {
+			return append(report, "meta: not found"), nil
+		}
+		return nil, err
+	}
+	obj, err := DecodeObject(meta)
+	if err != nil {
+		return append(report, "meta: undecodable, "+err.Error()), nil
+	}
+	report = append(report, fmt.Sprintf("meta: type %s, id %s", obj.Type, UUIDString(obj.ID)))
+
+	if IsExpired(obj, Now()) {
+		return append(report, "meta: expired, waiting for the expire worker"), nil
+	}
+
+	lines, err := txn.checkExpireIndex(mkey, obj, repair)
+	if err != nil {
+		return nil, err
+	}
+	report = append(report, lines...)
+
+	switch obj.Type {
+	case ObjectZSet:
+		lines, err = txn.checkZSet(mkey, meta, obj, repair)
+	case ObjectSet:
+		lines, err = txn.checkLen(obj, int64(binary.BigEndian.Uint64(meta[ObjectEncodingLength:])))
+	case ObjectString:
+		lines = []string{"string: nothing to check"}
+	default:
+		lines = []string{obj.Type.String() + ": not supported by quickcheck"}
+	}
+	if err != nil {
+		return nil, err
+	}
+	return append(report, lines...), nil
+}
+
+// checkExpireIndex verifies that an object with a TTL is present in the expire index
+func (txn *Transaction) checkExpireIndex(mkey []byte, obj *Object, repair bool) ([]string, error) {
+	if obj.ExpireAt == 0 {
+		return []string{"expire: no ttl"}, nil
+	}
+	ekey := expireKey(mkey, obj.ExpireAt)
+	if _, err := txn.t.Get(ekey); err != nil {
+		if !IsErrNotFound(err) {
+			return nil, err
+		}
+		if !repair {
+			return []string{"expire: index key missing"}, nil
+		}
+		if err := txn.t.Set(ekey, obj.ID); err != nil {
+			return nil, err
+		}
+		return []string{"expire: index key missing, repaired"}, nil
+	}
+	return []string{"expire: index ok"}, nil
+}
+
+// checkLen compares the meta Len against the actual count of data keys
+func (txn *Transaction) checkLen(obj *Object, metaLen int64) ([]string, error) {
+	dkey := DataKey(txn.db, obj.ID)
+	prefix := append(dkey, ':')
+	count, err := txn.countPrefix(prefix)
+	if err != nil {
+		return nil, err
+	}
+	if count != metaLen {
+		return []string{fmt.Sprintf("%s: meta len %d, data keys %d (mismatch)", obj.Type, metaLen, count)}, nil
+	}
+	return []string{fmt.Sprintf("%s: meta len %d, data keys %d", obj.Type, metaLen, count)}, nil
+}
+
+func (txn *Transaction) countPrefix(prefix []byte) (int64, error) {
+	iter, err := txn.t.Iter(prefix, kv.Key(prefix).PrefixNext())
+	if err != nil {
+		return 0, err
+	}
+	defer iter.Close()
+	count := int64(0)
+	for ; iter.Valid() && iter.Key().HasPrefix(prefix); err = iter.Next() {
+		if err != nil {
+			return 0, err
+		}
+		count++
+	}
+	return count, nil
+}
+
+// checkZSet verifies that the member index and the score index of a zset agree
+// with each other and that the meta Len matches the member count
+func (txn *Transaction) checkZSet(mkey []byte, meta []byte, obj *Object, repair bool) ([]string, error) {
+	var report []string
+	dkey := DataKey(txn.db, obj.ID)
+
+	// member -> encoded score, from the member index
+	memberPrefix := zsetMemberKey(dkey, nil)
+	members := make(map[string][]byte)
+	iter, err := txn.t.Iter(memberPrefix, kv.Key(memberPrefix).PrefixNext())
+	if err != nil {
+		return nil, err
+	}
+	for ; iter.Valid() && iter.Key().HasPrefix(memberPrefix); err = iter.Next() {
+		if err != nil {
+			iter.Close()
+			return nil, err
+		}
+		member := string(iter.Key()[len(memberPrefix):])
+		// the member value may carry a payload behind the score bytes
+		bytesScore, _ := splitMemberValue(iter.Value())
+		score := make([]byte, len(bytesScore))
+		copy(score, bytesScore)
+		members[member] = score
+	}
+	iter.Close()
+
+	// walk the score index and cross out members found there
+	scorePrefix := ZSetScorePrefix(dkey)
+	orphans := 0
+	iter, err = txn.t.Iter(scorePrefix, kv.Key(scorePrefix).PrefixNext())
+	if err != nil {
+		return nil, err
+	}
+	for ; iter.Valid() && iter.Key().HasPrefix(scorePrefix); err = iter.Next() {
+		if err != nil {
+			iter.Close()
+			return nil, err
+		}
+		skey := iter.Key()
+		scoreAndMember := skey[len(scorePrefix):]
+		if len(scoreAndMember) <= byteScoreLen+len(":") {
+			report = append(report, fmt.Sprintf("zset: malformed score key %q", string(skey)))
+			continue
+		}
+		score := scoreAndMember[:byteScoreLen]
+		member := scoreAndMember[byteScoreLen+len(":"):]
+		if mscore, ok := members[string(member)]; ok && bytes.Equal(mscore, score) {
+			delete(members, string(member))
+			continue
+		}
+		orphans++
+		if repair {
+			if err := txn.t.Delete(skey); err != nil {
+				iter.Close()
+				return nil, err
+			}
+		}
+	}
+	iter.Close()
+
+	memberCount := int64(0)
+	if len(meta) >= ObjectEncodingLength+8 {
+		memberCount = int64(binary.BigEndian.Uint64(meta[ObjectEncodingLength:]))
+	}
+	missing := len(members)
+	actual, err := txn.countPrefix(memberPrefix)
+	if err != nil {
+		return nil, err
+	}
+
+	if missing > 0 {
+		line := fmt.Sprintf("zset: %d members missing from score index", missing)
+		if repair {
+			for member, score := range members {
+				skey := zsetScoreKey(dkey, score, []byte(member))
+				if err := txn.t.Set(skey, NilValue); err != nil {
+					return nil, err
+				}
+			}
+			line += ", repaired"
+		}
+		report = append(report, line)
+	}
+	if orphans > 0 {
+		line := fmt.Sprintf("zset: %d orphan score keys", orphans)
+		if repair {
+			line += ", repaired"
+		}
+		report = append(report, line)
+	}
+	if actual != memberCount {
+		recordMetaLenDrift(ObjectZSet, mkey, memberCount, actual)
+		line := fmt.Sprintf("zset: meta len %d, members %d (mismatch)", memberCount, actual)
+		if repair {
+			// rewrite the Len in place, keeping any trailing meta fields
+			newMeta := append([]byte{}, meta...)
+			binary.BigEndian.PutUint64(newMeta[ObjectEncodingLength:], uint64(actual))
+			if err := txn.t.Set(mkey, newMeta); err != nil {
+				return nil, err
+			}
+			line += ", repaired"
+		}
+		report = append(report, line)
+	}
+	if len(report) == 0 {
+		report = append(report, fmt.Sprintf("zset: %d members, all indexes consistent", actual))
+	}
+	return report, nil
+}
@@ -0,0 +1,61 @@
+package db
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func quickCheckZSet(t *testing.T, key []byte) *ZSet {
+	txn := getTxn(t)
+	zset, err := GetZSet(txn, key)
+	assert.NoError(t, err)
+	_, err = zset.ZAdd([][]byte{[]byte("m1"), []byte("m2")}, []float64{1, 2})
+	assert.NoError(t, err)
+	assert.NoError(t, txn.Commit(context.TODO()))
+	return zset
+}
+
+func TestQuickCheckNotFound(t *testing.T) {
+	txn := getTxn(t)
+	report, err := txn.QuickCheck([]byte("qc-missing"), false)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"meta: not found"}, report)
+	txn.Commit(context.TODO())
+}
+
+func TestQuickCheckConsistentZSet(t *testing.T) {
+	key := []byte("qc-consistent")
+	quickCheckZSet(t, key)
+
+	txn := getTxn(t)
+	report, err := txn.QuickCheck(key, false)
+	assert.NoError(t, err)
+	assert.Contains(t, report, "zset: 2 members, all indexes consistent")
+	txn.Commit(context.TODO())
+}
+
+func TestQuickCheckRepairZSet(t *testing.T) {
+	key := []byte("qc-repair")
+	zset := quickCheckZSet(t, key)
+
+	// drop one score key to break the index agreement
+	txn := getTxn(t)
+	dkey := DataKey(txn.db, zset.meta.ID)
+	skey := zsetScoreKey(dkey, EncodeFloat64(1), []byte("m1"))
+	assert.NoError(t, txn.t.Delete(skey))
+	assert.NoError(t, txn.Commit(context.TODO()))
+
+	txn = getTxn(t)
+	report, err := txn.QuickCheck(key, true)
+	assert.NoError(t, err)
+	assert.Contains(t, report, "zset: 1 members missing from score index, repaired")
+	assert.NoError(t, txn.Commit(context.TODO()))
+
+	txn = getTxn(t)
+	report, err = txn.QuickCheck(key, false)
+	assert.NoError(t, err)
+	assert.Contains(t, report, "zset: 2 members, all indexes consistent")
+	txn.Commit(context.TODO())
+}
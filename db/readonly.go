@@ -0,0 +1,116 @@
+package db
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/distributedio/titan/conf"
+	"github.com/pingcap/tidb/kv"
+	"go.uber.org/zap"
+)
+
+// sysReadOnlyPrefix prefixes the marker keys of namespaces in read-only
+// mode, the rest of the key is the namespace and the value is the unix
+// nano timestamp of when the mode was switched on
+var sysReadOnlyPrefix = []byte("$sys:0:RO:")
+
+// ReadOnlyMgr mirrors the read-only markers stored in tikv into a local
+// set so the dispatcher rejects writes without touching the store. The set
+// is refreshed every sync period, a switch flipped on one node is seen by
+// every other node within that period
+type ReadOnlyMgr struct {
+	db         *DB
+	syncPeriod time.Duration
+
+	mu         sync.RWMutex
+	namespaces map[string]struct{}
+}
+
+// NewReadOnlyMgr loads the current markers and starts the background sync
+func NewReadOnlyMgr(store *RedisStore, cfg *conf.ReadOnly) *ReadOnlyMgr {
+	m := &ReadOnlyMgr{
+		db:         store.DB(sysNamespace, sysDatabaseID),
+		syncPeriod: cfg.SyncPeriod,
+		namespaces: make(map[string]struct{}),
+	}
+	if err := m.refresh(); err != nil {
+		zap.L().Error("[ReadOnly] initial refresh failed", zap.Error(err))
+	}
+	go m.run()
+	return m
+}
+
+func (m *ReadOnlyMgr) run() {
+	ticker := time.NewTicker(m.syncPeriod)
+	defer ticker.Stop()
+	for range ticker.C {
+		if err := m.refresh(); err != nil {
+			zap.L().Error("[ReadOnly] refresh failed", zap.Error(err))
+		}
+	}
+}
+
+// refresh replaces the local set with the markers currently stored in tikv
+func (m *ReadOnlyMgr) refresh() error {
+	txn, err := m.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer txn.Rollback()
+	iter, err := txn.t.Iter(sysReadOnlyPrefix, kv.Key(sysReadOnlyPrefix).PrefixNext())
+	if err != nil {
+		return err
+	}
+	defer iter.Close()
+	namespaces := make(map[string]struct{})
+	for ; iter.Valid() && iter.Key().HasPrefix(sysReadOnlyPrefix); err = iter.Next() {
+		if err != nil {
+			return err
+		}
+		namespaces[string(iter.Key()[len(sysReadOnlyPrefix):])] = struct{}{}
+	}
+	m.mu.Lock()
+	m.namespaces = namespaces
+	m.mu.Unlock()
+	return nil
+}
+
+// IsReadOnly reports whether the namespace is in read-only mode
+func (m *ReadOnlyMgr) IsReadOnly(namespace string) bool {
+	m.mu.RLock()
+	_, ok := m.namespaces[namespace]
+	m.mu.RUnlock()
+	return ok
+}
+
+// SetReadOnly persists the switch in tikv and applies it to the local set
+// at once, so the node taking the command enforces it immediately while
+// the other nodes pick it up on their next refresh
+func (m *ReadOnlyMgr) SetReadOnly(namespace string, on bool) error {
+	txn, err := m.db.Begin()
+	if err != nil {
+		return err
+	}
+	key := append(append([]byte{}, sysReadOnlyPrefix...), namespace...)
+	if on {
+		err = txn.t.Set(key, EncodeInt64(time.Now().UnixNano()))
+	} else {
+		err = txn.t.Delete(key)
+	}
+	if err != nil {
+		txn.Rollback()
+		return err
+	}
+	if err := txn.Commit(context.Background()); err != nil {
+		return err
+	}
+	m.mu.Lock()
+	if on {
+		m.namespaces[namespace] = struct{}{}
+	} else {
+		delete(m.namespaces, namespace)
+	}
+	m.mu.Unlock()
+	return nil
+}
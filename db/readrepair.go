@@ -0,0 +1,53 @@
+package db
+
+import (
+	"sync/atomic"
+
+	"github.com/distributedio/titan/conf"
+	"github.com/distributedio/titan/metrics"
+	"go.uber.org/zap"
+)
+
+// readRepair is the sampled verification of collection meta lengths, nil
+// until an Open enables it. Meta lengths can drift after partial-failure
+// commits, a sampled read counts the real data keys and rewrites the meta
+// in place inside its own transaction
+var readRepair *readRepairer
+
+type readRepairer struct {
+	cfg     *conf.ReadRepair
+	counter uint64
+}
+
+// EnableReadRepair lets sampled reads verify and repair collection meta
+// lengths
+func EnableReadRepair(cfg *conf.ReadRepair) {
+	if readRepair != nil {
+		return
+	}
+	readRepair = &readRepairer{cfg: cfg}
+}
+
+// sampleReadRepair reports whether this read was picked for verification
+// and returns the config to run it with
+func sampleReadRepair() *conf.ReadRepair {
+	r := readRepair
+	if r == nil {
+		return nil
+	}
+	every := uint64(r.cfg.SampleEvery)
+	if every == 0 {
+		every = 1
+	}
+	if atomic.AddUint64(&r.counter, 1)%every != 0 {
+		return nil
+	}
+	return r.cfg
+}
+
+// recordMetaLenDrift records one drifted meta length
+func recordMetaLenDrift(objType ObjectType, key []byte, metaLen, actual int64) {
+	metrics.GetMetrics().MetaLenDriftVec.WithLabelValues(objType.String()).Inc()
+	zap.L().Warn("meta len drift detected", zap.String("type", objType.String()),
+		zap.ByteString("key", key), zap.Int64("meta_len", metaLen), zap.Int64("actual", actual))
+}
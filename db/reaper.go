@@ -0,0 +1,222 @@
+package db
+
+import (
+	"bytes"
+	"context"
+	"time"
+
+	"github.com/distributedio/titan/conf"
+	"github.com/distributedio/titan/db/store"
+	"github.com/distributedio/titan/metrics"
+	"github.com/pingcap/tidb/kv"
+	"go.uber.org/zap"
+)
+
+var (
+	sysReaperLeader = []byte("$sys:0:RPL:RPLeader")
+	sysReaperCursor = []byte("$sys:0:RP:cursor")
+)
+
+const (
+	reaper_worker = "reaper"
+
+	// object IDs are 16 bytes uuid
+	reaperObjectIDLength = 16
+)
+
+// dataKeyPrefix parses a raw store key and returns the db prefix
+// ({ns}:{dbid}:) and the object ID if the key belongs to the data
+// space of some db, ok is false otherwise
+func dataKeyPrefix(key []byte) (dbPrefix []byte, objID []byte, ok bool) {
+	idx := bytes.IndexByte(key, ':')
+	if idx < 0 || len(key) < idx+1+3+3+reaperObjectIDLength {
+		return nil, nil, false
+	}
+	// {ns}:{dbid}: with a 3-digit dbid
+	rest := key[idx+1:]
+	if rest[3] != ':' || rest[4] != 'D' || rest[5] != ':' {
+		return nil, nil, false
+	}
+	return key[:idx+1+3+1], rest[6 : 6+reaperObjectIDLength], true
+}
+
+// liveObjectIDs scans the meta space of a db and collects every object ID
+// still referenced by a meta key. complete is false if the scan hit limit,
+// in which case the result must not be used to judge orphans
+func liveObjectIDs(txn *Transaction, dbPrefix []byte, limit int) (map[string]struct{}, bool, error) {
+	metaPrefix := append(append([]byte{}, dbPrefix...), 'M', ':')
+	iter, err := txn.t.Iter(metaPrefix, kv.Key(metaPrefix).PrefixNext())
+	if err != nil {
+		return nil, false, err
+	}
+	defer iter.Close()
+
+	ids := make(map[string]struct{})
+	count := 0
+	for ; iter.Valid() && iter.Key().HasPrefix(metaPrefix); err = iter.Next() {
+		if err != nil {
+			return nil, false, err
+		}
+		if count++; limit > 0 && count > limit {
+			return nil, false, nil
+		}
+		obj, err := DecodeObject(iter.Value())
+		if err != nil {
+			zap.L().Error("[Reaper] undecodable meta", zap.ByteString("key", iter.Key()), zap.Error(err))
+			continue
+		}
+		ids[string(obj.ID)] = struct{}{}
+	}
+	return ids, true, nil
+}
+
+// doReap samples one range of the data key space starting from the saved
+// cursor, verifies every object ID found against the meta space of its db
+// and schedules gc for the orphans
+func doReap(db *DB, conf *conf.Reaper) error {
+	dbTxn, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	txn := dbTxn.t
+
+	var cursor []byte
+	if val, err := txn.Get(sysReaperCursor); err == nil {
+		cursor = append(cursor, val...)
+	} else if !IsErrNotFound(err) {
+		txn.Rollback()
+		return err
+	}
+
+	// the candidate scan only looks at key shapes, fetch them without
+	// values. liveObjectIDs decodes metas afterwards, so restore the option
+	// once this scan is done
+	store.SetOption(txn, store.KeyOnly, true)
+
+	start := time.Now()
+	iter, err := txn.Iter(cursor, nil)
+	metrics.GetMetrics().WorkerSeekCostHistogramVec.WithLabelValues(reaper_worker).Observe(time.Since(start).Seconds())
+	if err != nil {
+		txn.Rollback()
+		return err
+	}
+
+	// candidate object IDs grouped by db prefix
+	candidates := make(map[string]map[string][]byte)
+	scanned := 0
+	var lastKey []byte
+	for ; iter.Valid() && scanned < conf.BatchLimit; err = iter.Next() {
+		if err != nil {
+			iter.Close()
+			txn.Rollback()
+			return err
+		}
+		scanned++
+		lastKey = append(lastKey[:0], iter.Key()...)
+		dbPrefix, objID, ok := dataKeyPrefix(iter.Key())
+		if !ok || bytes.HasPrefix(dbPrefix, []byte(sysNamespace)) {
+			continue
+		}
+		ids, ok := candidates[string(dbPrefix)]
+		if !ok {
+			ids = make(map[string][]byte)
+			candidates[string(dbPrefix)] = ids
+		}
+		ids[string(objID)] = append(append([]byte{}, dbPrefix...), append([]byte{'D', ':'}, objID...)...)
+	}
+	exhausted := !iter.Valid()
+	iter.Close()
+	store.DelOption(txn, store.KeyOnly)
+
+	orphans := 0
+	for dbPrefix, ids := range candidates {
+		live, complete, err := liveObjectIDs(dbTxn, []byte(dbPrefix), conf.MetaScanLimit)
+		if err != nil {
+			txn.Rollback()
+			return err
+		}
+		if !complete {
+			zap.L().Warn("[Reaper] meta space too large, db skipped", zap.String("db-prefix", dbPrefix),
+				zap.Int("meta-scan-limit", conf.MetaScanLimit))
+			continue
+		}
+		for id, dataPrefix := range ids {
+			if _, ok := live[id]; ok {
+				continue
+			}
+			orphans++
+			zap.L().Info("[Reaper] found orphan data keys", zap.ByteString("data-prefix", dataPrefix))
+			if err := gc(txn, dataPrefix); err != nil {
+				txn.Rollback()
+				return err
+			}
+		}
+	}
+
+	// save the progress, restart from the beginning once the keyspace is exhausted
+	if exhausted || lastKey == nil {
+		if len(cursor) > 0 {
+			if err := txn.Delete(sysReaperCursor); err != nil {
+				txn.Rollback()
+				return err
+			}
+		}
+	} else if err := txn.Set(sysReaperCursor, kv.Key(lastKey).Next()); err != nil {
+		txn.Rollback()
+		return err
+	}
+
+	start = time.Now()
+	if err := txn.Commit(context.Background()); err != nil {
+		txn.Rollback()
+		return err
+	}
+	metrics.GetMetrics().WorkerCommitCostHistogramVec.WithLabelValues(reaper_worker).Observe(time.Since(start).Seconds())
+	metrics.GetMetrics().ReaperKeysCounterVec.WithLabelValues("scanned").Add(float64(scanned))
+	metrics.GetMetrics().ReaperKeysCounterVec.WithLabelValues("orphan").Add(float64(orphans))
+	if logEnv := zap.L().Check(zap.DebugLevel, "[Reaper] round done"); logEnv != nil {
+		logEnv.Write(zap.Int("scanned", scanned), zap.Int("orphans", orphans), zap.Bool("exhausted", exhausted))
+	}
+	return nil
+}
+
+// StartReaper periodically samples the data key space looking for data keys
+// whose object ID is no longer referenced by any meta key, and schedules gc
+// for them. Like gc and expire, only the leader node does the work
+func StartReaper(db *DB, conf *conf.Reaper) {
+	ticker := time.NewTicker(conf.Interval)
+	defer ticker.Stop()
+	id := UUID()
+	for range ticker.C {
+		if conf.Disable {
+			continue
+		}
+
+		start := time.Now()
+		isLeader, err := isLeader(db, sysReaperLeader, id, conf.LeaderLifeTime)
+		if err != nil {
+			zap.L().Error("[Reaper] check reaper leader failed",
+				zap.ByteString("leader", sysReaperLeader),
+				zap.ByteString("uuid", id),
+				zap.Duration("leader-life-time", conf.LeaderLifeTime),
+				zap.Error(err))
+			continue
+		}
+		if !isLeader {
+			if logEnv := zap.L().Check(zap.DebugLevel, "[Reaper] current is not reaper leader"); logEnv != nil {
+				logEnv.Write(zap.ByteString("leader", sysReaperLeader),
+					zap.ByteString("uuid", id),
+					zap.Duration("leader-life-time", conf.LeaderLifeTime))
+			}
+			continue
+		}
+		if err := doReap(db, conf); err != nil {
+			zap.L().Error("[Reaper] do reap failed",
+				zap.ByteString("leader", sysReaperLeader),
+				zap.ByteString("uuid", id),
+				zap.Error(err))
+			continue
+		}
+		metrics.GetMetrics().WorkerRoundCostHistogramVec.WithLabelValues(reaper_worker).Observe(time.Since(start).Seconds())
+	}
+}
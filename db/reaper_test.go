@@ -0,0 +1,50 @@
+package db
+
+import (
+	"context"
+	"testing"
+
+	"github.com/distributedio/titan/conf"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDataKeyPrefix(t *testing.T) {
+	objID := UUID()
+	key := DataKey(MockDB(), objID)
+	dbPrefix, id, ok := dataKeyPrefix(key)
+	assert.True(t, ok)
+	assert.Equal(t, []byte("ns:001:"), dbPrefix)
+	assert.Equal(t, objID, id)
+
+	_, _, ok = dataKeyPrefix(MetaKey(MockDB(), []byte("key")))
+	assert.False(t, ok)
+	_, _, ok = dataKeyPrefix([]byte("short"))
+	assert.False(t, ok)
+}
+
+func TestDoReapOrphan(t *testing.T) {
+	reaperConf := &conf.Reaper{BatchLimit: 1000, MetaScanLimit: 1000}
+
+	// a live hash and a bundle of orphan data keys in the same db
+	hash, txn, err := getHash(t, []byte("reaper-live"))
+	assert.NoError(t, err)
+	_, err = hash.HSet([]byte("field"), []byte("val"))
+	assert.NoError(t, err)
+
+	orphanID := UUID()
+	orphanKey := DataKey(txn.db, orphanID)
+	orphanKey = append(orphanKey, ':')
+	orphanKey = append(orphanKey, []byte("field")...)
+	assert.NoError(t, txn.t.Set(orphanKey, []byte("val")))
+	assert.NoError(t, txn.Commit(context.TODO()))
+
+	assert.NoError(t, doReap(txn.db, reaperConf))
+
+	// the orphan prefix must be scheduled for gc, the live one must not
+	txn = getTxn(t)
+	_, err = txn.t.Get(toTikvGCKey(DataKey(txn.db, orphanID)))
+	assert.NoError(t, err)
+	_, err = txn.t.Get(toTikvGCKey(DataKey(txn.db, hash.meta.ID)))
+	assert.True(t, IsErrNotFound(err))
+	txn.Commit(context.TODO())
+}
@@ -0,0 +1,243 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	sdk_kv "github.com/pingcap/tidb/kv"
+	"go.uber.org/zap"
+
+	"github.com/distributedio/titan/conf"
+)
+
+var (
+	// $sys:0:TITAN:{nodeid}
+	sysNodesPrefix = []byte("$sys:0:TITAN:")
+)
+
+const nodeStatusToken = "|"
+
+// NodeStatus describes one titan node registered in tikv, every node
+// heartbeats its own entry so the others can see the cluster membership
+type NodeStatus struct {
+	ID          string
+	IP          string
+	Version     string
+	Roles       string // comma separated, e.g. "server"
+	StartAt     int64
+	HeartbeatAt int64
+}
+
+// NewNodeStatus builds the status of the local node, interfaceName may be
+// empty to pick the first non-loopback address
+func NewNodeStatus(interfaceName string, version string, roles string) (NodeStatus, error) {
+	ip, err := LocalIP(interfaceName)
+	if err != nil {
+		return NodeStatus{}, err
+	}
+	return NodeStatus{
+		ID:      UUIDString(UUID()),
+		IP:      ip,
+		Version: version,
+		Roles:   roles,
+		StartAt: time.Now().Unix(),
+	}, nil
+}
+
+// LocalIP returns the first non-loopback ipv4 address of the interface,
+// or of any interface when interfaceName is empty
+func LocalIP(interfaceName string) (string, error) {
+	var addrs []net.Addr
+	var err error
+	if interfaceName != "" {
+		iface, err := net.InterfaceByName(interfaceName)
+		if err != nil {
+			return "", err
+		}
+		addrs, err = iface.Addrs()
+		if err != nil {
+			return "", err
+		}
+	} else {
+		addrs, err = net.InterfaceAddrs()
+		if err != nil {
+			return "", err
+		}
+	}
+	for _, a := range addrs {
+		if ipnet, ok := a.(*net.IPNet); ok && !ipnet.IP.IsLoopback() && ipnet.IP.To4() != nil {
+			return ipnet.IP.String(), nil
+		}
+	}
+	return "", errors.New("no usable address on interface " + interfaceName)
+}
+
+func (node *NodeStatus) encode() []byte {
+	fields := []string{
+		node.ID,
+		node.IP,
+		node.Version,
+		node.Roles,
+		strconv.FormatInt(node.StartAt, 10),
+		strconv.FormatInt(node.HeartbeatAt, 10),
+	}
+	return []byte(strings.Join(fields, nodeStatusToken))
+}
+
+func decodeNodeStatus(value []byte) (NodeStatus, error) {
+	fields := strings.Split(string(value), nodeStatusToken)
+	if len(fields) != 6 {
+		return NodeStatus{}, fmt.Errorf("invalid node status %q", value)
+	}
+	startAt, err := strconv.ParseInt(fields[4], 10, 64)
+	if err != nil {
+		return NodeStatus{}, err
+	}
+	heartbeatAt, err := strconv.ParseInt(fields[5], 10, 64)
+	if err != nil {
+		return NodeStatus{}, err
+	}
+	return NodeStatus{
+		ID:          fields[0],
+		IP:          fields[1],
+		Version:     fields[2],
+		Roles:       fields[3],
+		StartAt:     startAt,
+		HeartbeatAt: heartbeatAt,
+	}, nil
+}
+
+func nodeKey(id string) []byte {
+	var key []byte
+	key = append(key, sysNodesPrefix...)
+	key = append(key, id...)
+	return key
+}
+
+func registerNode(db *DB, node NodeStatus) error {
+	txn, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	node.HeartbeatAt = time.Now().Unix()
+	if err := txn.t.Set(nodeKey(node.ID), node.encode()); err != nil {
+		txn.Rollback()
+		return err
+	}
+	return txn.Commit(context.Background())
+}
+
+// NodeLifeTime returns how long without a heartbeat a node stays in the
+// membership view
+func (rds *RedisStore) NodeLifeTime() time.Duration {
+	if rds.conf == nil || rds.conf.Registry.LifeTime <= 0 {
+		return time.Minute
+	}
+	return rds.conf.Registry.LifeTime
+}
+
+// ListNodes returns every registered node whose heartbeat is within
+// lifetime, stale entries are skipped
+func ListNodes(txn *Transaction, lifetime time.Duration) ([]NodeStatus, error) {
+	endPrefix := sdk_kv.Key(sysNodesPrefix).PrefixNext()
+	iter, err := txn.t.Iter(sysNodesPrefix, endPrefix)
+	if err != nil {
+		return nil, err
+	}
+	defer iter.Close()
+
+	deadline := time.Now().Add(-lifetime).Unix()
+	var nodes []NodeStatus
+	for ; iter.Valid() && iter.Key().HasPrefix(sysNodesPrefix); err = iter.Next() {
+		if err != nil {
+			return nil, err
+		}
+		node, err := decodeNodeStatus(iter.Value())
+		if err != nil {
+			zap.L().Error("[Registry] decode node status failed",
+				zap.ByteString("key", iter.Key()), zap.Error(err))
+			continue
+		}
+		if node.HeartbeatAt < deadline {
+			continue
+		}
+		nodes = append(nodes, node)
+	}
+	return nodes, nil
+}
+
+// clearStaleNodeEntries deletes registry entries that carry the IP of the
+// local node but a foreign ID, they were written by an earlier incarnation
+// of a reused IP and would sit in the membership view until their
+// heartbeat ages out
+func clearStaleNodeEntries(db *DB, node NodeStatus) error {
+	txn, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	endPrefix := sdk_kv.Key(sysNodesPrefix).PrefixNext()
+	iter, err := txn.t.Iter(sysNodesPrefix, endPrefix)
+	if err != nil {
+		txn.Rollback()
+		return err
+	}
+	defer iter.Close()
+	count := 0
+	for ; iter.Valid() && iter.Key().HasPrefix(sysNodesPrefix); err = iter.Next() {
+		if err != nil {
+			txn.Rollback()
+			return err
+		}
+		stale, err := decodeNodeStatus(iter.Value())
+		if err != nil {
+			continue
+		}
+		if stale.IP != node.IP || stale.ID == node.ID {
+			continue
+		}
+		if err := txn.t.Delete(iter.Key()); err != nil {
+			txn.Rollback()
+			return err
+		}
+		count++
+	}
+	if count == 0 {
+		txn.Rollback()
+		return nil
+	}
+	if err := txn.Commit(context.Background()); err != nil {
+		txn.Rollback()
+		return err
+	}
+	zap.L().Info("[Registry] cleared stale entries of the local IP",
+		zap.String("ip", node.IP), zap.Int("entries", count))
+	return nil
+}
+
+// StartNodeRegistry heartbeats the local node status into tikv so the
+// other titan nodes can discover it, the entry is removed from the view
+// by ListNodes once the heartbeat is older than the configured life time
+func StartNodeRegistry(store *RedisStore, conf *conf.NodeRegistry, node NodeStatus) {
+	if conf.Disable {
+		return
+	}
+	sysdb := store.DB(sysNamespace, sysDatabaseID)
+	ticker := time.NewTicker(conf.Interval)
+	defer ticker.Stop()
+	if err := clearStaleNodeEntries(sysdb, node); err != nil {
+		zap.L().Error("[Registry] clear stale node entries failed", zap.String("ip", node.IP), zap.Error(err))
+	}
+	if err := registerNode(sysdb, node); err != nil {
+		zap.L().Error("[Registry] register node failed", zap.String("id", node.ID), zap.Error(err))
+	}
+	for range ticker.C {
+		if err := registerNode(sysdb, node); err != nil {
+			zap.L().Error("[Registry] heartbeat node failed", zap.String("id", node.ID), zap.Error(err))
+		}
+	}
+}
@@ -0,0 +1,81 @@
+package db
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNodeStatusCodec(t *testing.T) {
+	node := NodeStatus{
+		ID:          "2f5b3c1e",
+		IP:          "10.0.0.7",
+		Version:     "v0.7.0",
+		Roles:       "server,expire",
+		StartAt:     1700000000,
+		HeartbeatAt: 1700000060,
+	}
+	decoded, err := decodeNodeStatus(node.encode())
+	assert.NoError(t, err)
+	assert.Equal(t, node, decoded)
+
+	_, err = decodeNodeStatus([]byte("garbage"))
+	assert.Error(t, err)
+}
+
+func TestNodeRegistry(t *testing.T) {
+	db := MockDB()
+	live := NodeStatus{ID: "live", IP: "10.0.0.1", Version: "v0.7.0", Roles: "server", StartAt: time.Now().Unix()}
+	assert.NoError(t, registerNode(db, live))
+
+	// a node without a recent heartbeat drops out of the view
+	stale := NodeStatus{ID: "stale", IP: "10.0.0.2", Version: "v0.7.0", Roles: "server",
+		StartAt: time.Now().Unix() - 3600, HeartbeatAt: time.Now().Unix() - 3600}
+	txn, err := db.Begin()
+	assert.NoError(t, err)
+	assert.NoError(t, txn.t.Set(nodeKey(stale.ID), stale.encode()))
+	assert.NoError(t, txn.Commit(context.TODO()))
+
+	txn, err = db.Begin()
+	assert.NoError(t, err)
+	nodes, err := ListNodes(txn, time.Minute)
+	assert.NoError(t, err)
+	txn.Commit(context.TODO())
+
+	assert.Len(t, nodes, 1)
+	assert.Equal(t, "live", nodes[0].ID)
+	assert.Equal(t, "10.0.0.1", nodes[0].IP)
+	assert.NotZero(t, nodes[0].HeartbeatAt)
+}
+
+func TestClearStaleNodeEntries(t *testing.T) {
+	db := MockDB()
+	now := time.Now().Unix()
+	// the previous incarnation of a reused IP, still heartbeating on paper
+	old := NodeStatus{ID: "old-incarnation", IP: "10.0.0.9", Version: "v0.7.0", Roles: "server",
+		StartAt: now - 60, HeartbeatAt: now}
+	other := NodeStatus{ID: "other-node", IP: "10.0.0.10", Version: "v0.7.0", Roles: "server",
+		StartAt: now - 60, HeartbeatAt: now}
+	assert.NoError(t, registerNode(db, old))
+	assert.NoError(t, registerNode(db, other))
+
+	fresh := NodeStatus{ID: "new-incarnation", IP: "10.0.0.9", Version: "v0.7.0", Roles: "server", StartAt: now}
+	assert.NoError(t, clearStaleNodeEntries(db, fresh))
+	assert.NoError(t, registerNode(db, fresh))
+
+	txn, err := db.Begin()
+	assert.NoError(t, err)
+	nodes, err := ListNodes(txn, time.Minute)
+	assert.NoError(t, err)
+	txn.Commit(context.TODO())
+
+	ids := make(map[string]bool)
+	for _, node := range nodes {
+		ids[node.ID] = true
+	}
+	assert.False(t, ids["old-incarnation"])
+	assert.True(t, ids["other-node"])
+	assert.True(t, ids["new-incarnation"])
+}
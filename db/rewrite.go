@@ -0,0 +1,252 @@
+package db
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/distributedio/titan/conf"
+	"github.com/distributedio/titan/db/keyspace"
+	"github.com/distributedio/titan/metrics"
+	"github.com/pingcap/tidb/kv"
+	"go.uber.org/zap"
+)
+
+var (
+	sysRewriteLeader = []byte("$sys:0:RWL:RWLeader")
+	sysRewriteJob    = []byte("$sys:0:RW:job")
+)
+
+const (
+	rewrite_worker  = "rewrite"
+	rewriteJobToken = "|"
+)
+
+// states of a rewrite job
+const (
+	RewriteRunning = "running"
+	RewritePaused  = "paused"
+	RewriteDone    = "done"
+)
+
+// ErrRewriteRunning is returned when a rewrite job is started while
+// another one is still running
+var ErrRewriteRunning = errors.New("a rewrite job is already running")
+
+// RewriteJob is the persisted state of a background rewrite, operators
+// start, pause and resume it through the REWRITE command and every node
+// sees the same record
+type RewriteJob struct {
+	State     string
+	Namespace string
+	OpsPerSec int64
+	Cursor    []byte
+	Rewritten int64
+}
+
+func encodeRewriteJob(job *RewriteJob) []byte {
+	fields := []string{
+		job.State,
+		job.Namespace,
+		strconv.FormatInt(job.OpsPerSec, 10),
+		hex.EncodeToString(job.Cursor),
+		strconv.FormatInt(job.Rewritten, 10),
+	}
+	return []byte(strings.Join(fields, rewriteJobToken))
+}
+
+func decodeRewriteJob(value []byte) (*RewriteJob, error) {
+	fields := strings.Split(string(value), rewriteJobToken)
+	if len(fields) != 5 {
+		return nil, ErrInvalidLength
+	}
+	job := &RewriteJob{State: fields[0], Namespace: fields[1]}
+	var err error
+	if job.OpsPerSec, err = strconv.ParseInt(fields[2], 10, 64); err != nil {
+		return nil, err
+	}
+	if job.Cursor, err = hex.DecodeString(fields[3]); err != nil {
+		return nil, err
+	}
+	if job.Rewritten, err = strconv.ParseInt(fields[4], 10, 64); err != nil {
+		return nil, err
+	}
+	return job, nil
+}
+
+// GetRewriteJob loads the rewrite job record, nil when there is none
+func GetRewriteJob(txn *Transaction) (*RewriteJob, error) {
+	val, err := txn.t.Get(sysRewriteJob)
+	if err != nil {
+		if IsErrNotFound(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return decodeRewriteJob(val)
+}
+
+// PutRewriteJob stores the rewrite job record
+func PutRewriteJob(txn *Transaction, job *RewriteJob) error {
+	return txn.t.Set(sysRewriteJob, encodeRewriteJob(job))
+}
+
+// rewriteMeta re-encodes the object of one meta key with the current
+// preferred encoding. Today that covers string values, which pick up value
+// encryption and encoding changes, other types are left for their own
+// converters. It reports whether the key was rewritten
+func rewriteMeta(db *DB, txn *Transaction, rawKey, meta []byte) (bool, error) {
+	obj, err := DecodeObject(meta)
+	if err != nil {
+		zap.L().Error("[Rewrite] undecodable meta", zap.ByteString("key", rawKey), zap.Error(err))
+		return false, nil
+	}
+	if obj.Type != ObjectString || IsExpired(obj, Now()) {
+		return false, nil
+	}
+
+	ns, id, ukey := keyspace.SplitMetaKey(rawKey)
+	udb := &DB{Namespace: string(ns), ID: toDBID(id), kv: db.kv, conf: db.conf}
+	utxn := &Transaction{t: txn.t, db: udb}
+	str := NewString(utxn, ukey)
+	if err := str.decode(meta); err != nil {
+		zap.L().Error("[Rewrite] undecodable string value", zap.ByteString("key", rawKey), zap.Error(err))
+		return false, nil
+	}
+	encoded, err := str.encode()
+	if err != nil {
+		zap.L().Error("[Rewrite] seal string value failed", zap.ByteString("key", rawKey), zap.Error(err))
+		return false, nil
+	}
+	if bytes.Equal(encoded, meta) {
+		return false, nil
+	}
+	return true, txn.t.Set(append([]byte{}, rawKey...), encoded)
+}
+
+// doRewrite runs one budgeted round of the current rewrite job, the ops
+// budget is the scanned key count allowed per round
+func doRewrite(db *DB, conf *conf.Rewriter) error {
+	dbTxn, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	txn := dbTxn.t
+
+	job, err := GetRewriteJob(dbTxn)
+	if err != nil {
+		txn.Rollback()
+		return err
+	}
+	if job == nil || job.State != RewriteRunning {
+		txn.Rollback()
+		return nil
+	}
+
+	budget := int(float64(job.OpsPerSec) * conf.Interval.Seconds())
+	if budget <= 0 {
+		budget = 1
+	}
+
+	prefix := dbPrefix(job.Namespace, nil)
+	cursor := job.Cursor
+	if len(cursor) == 0 {
+		cursor = prefix
+	}
+	start := time.Now()
+	iter, err := txn.Iter(cursor, kv.Key(prefix).PrefixNext())
+	metrics.GetMetrics().WorkerSeekCostHistogramVec.WithLabelValues(rewrite_worker).Observe(time.Since(start).Seconds())
+	if err != nil {
+		txn.Rollback()
+		return err
+	}
+
+	scanned, rewritten := 0, 0
+	var lastKey []byte
+	for ; iter.Valid() && iter.Key().HasPrefix(prefix) && scanned < budget; err = iter.Next() {
+		if err != nil {
+			iter.Close()
+			txn.Rollback()
+			return err
+		}
+		scanned++
+		lastKey = append(lastKey[:0], iter.Key()...)
+		if !isMetaKey(iter.Key()) {
+			continue
+		}
+		done, err := rewriteMeta(db, dbTxn, iter.Key(), iter.Value())
+		if err != nil {
+			iter.Close()
+			txn.Rollback()
+			return err
+		}
+		if done {
+			rewritten++
+		}
+	}
+	exhausted := !iter.Valid() || !iter.Key().HasPrefix(prefix)
+	iter.Close()
+
+	job.Rewritten += int64(rewritten)
+	if exhausted || lastKey == nil {
+		job.State = RewriteDone
+		job.Cursor = nil
+	} else {
+		job.Cursor = kv.Key(lastKey).Next()
+	}
+	if err := PutRewriteJob(dbTxn, job); err != nil {
+		txn.Rollback()
+		return err
+	}
+
+	start = time.Now()
+	if err := txn.Commit(context.Background()); err != nil {
+		txn.Rollback()
+		return err
+	}
+	metrics.GetMetrics().WorkerCommitCostHistogramVec.WithLabelValues(rewrite_worker).Observe(time.Since(start).Seconds())
+	if logEnv := zap.L().Check(zap.DebugLevel, "[Rewrite] round done"); logEnv != nil {
+		logEnv.Write(zap.Int("scanned", scanned), zap.Int("rewritten", rewritten),
+			zap.String("state", job.State))
+	}
+	return nil
+}
+
+// StartRewriter runs the background rewrite worker. It re-encodes objects
+// of the namespace named by the current rewrite job under the ops/sec
+// budget of the job, only the leader node does the work
+func StartRewriter(db *DB, conf *conf.Rewriter) {
+	ticker := time.NewTicker(conf.Interval)
+	defer ticker.Stop()
+	id := UUID()
+	for range ticker.C {
+		if conf.Disable {
+			continue
+		}
+
+		start := time.Now()
+		isLeader, err := isLeader(db, sysRewriteLeader, id, conf.LeaderLifeTime)
+		if err != nil {
+			zap.L().Error("[Rewrite] check rewrite leader failed",
+				zap.ByteString("leader", sysRewriteLeader),
+				zap.ByteString("uuid", id),
+				zap.Error(err))
+			continue
+		}
+		if !isLeader {
+			continue
+		}
+		if err := doRewrite(db, conf); err != nil {
+			zap.L().Error("[Rewrite] do rewrite failed",
+				zap.ByteString("leader", sysRewriteLeader),
+				zap.ByteString("uuid", id),
+				zap.Error(err))
+			continue
+		}
+		metrics.GetMetrics().WorkerRoundCostHistogramVec.WithLabelValues(rewrite_worker).Observe(time.Since(start).Seconds())
+	}
+}
@@ -0,0 +1,84 @@
+package db
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/distributedio/titan/conf"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRewriteJobCodec(t *testing.T) {
+	job := &RewriteJob{
+		State:     RewriteRunning,
+		Namespace: "tenant",
+		OpsPerSec: 500,
+		Cursor:    []byte("tenant:001:M:key"),
+		Rewritten: 42,
+	}
+	decoded, err := decodeRewriteJob(encodeRewriteJob(job))
+	assert.NoError(t, err)
+	assert.Equal(t, job, decoded)
+
+	_, err = decodeRewriteJob([]byte("garbage"))
+	assert.Error(t, err)
+}
+
+func TestRewrite(t *testing.T) {
+	db := MockDB()
+	key := []byte("rewrite-key")
+	rewriteConf := &conf.Rewriter{Interval: time.Second}
+
+	// a plain value written before encryption was enabled
+	txn, err := db.Begin()
+	assert.NoError(t, err)
+	str, err := GetString(txn, key)
+	assert.NoError(t, err)
+	assert.NoError(t, str.Set([]byte("secret")))
+	assert.NoError(t, txn.Commit(context.TODO()))
+
+	crypto, err := NewValueCrypto([]byte("0123456789abcdef0123456789abcdef"), nil)
+	assert.NoError(t, err)
+	db.kv.crypto = crypto
+
+	// a paused job does not touch anything
+	txn, err = db.Begin()
+	assert.NoError(t, err)
+	assert.NoError(t, PutRewriteJob(txn, &RewriteJob{State: RewritePaused, Namespace: db.Namespace, OpsPerSec: 1000}))
+	assert.NoError(t, txn.Commit(context.TODO()))
+	assert.NoError(t, doRewrite(db, rewriteConf))
+
+	txn, err = db.Begin()
+	assert.NoError(t, err)
+	raw, err := txn.t.Get(MetaKey(db, key))
+	assert.NoError(t, err)
+	assert.Contains(t, string(raw), "secret")
+
+	// a running job re-encodes the value with the current crypto config
+	job, err := GetRewriteJob(txn)
+	assert.NoError(t, err)
+	job.State = RewriteRunning
+	assert.NoError(t, PutRewriteJob(txn, job))
+	assert.NoError(t, txn.Commit(context.TODO()))
+	assert.NoError(t, doRewrite(db, rewriteConf))
+
+	txn, err = db.Begin()
+	assert.NoError(t, err)
+	raw, err = txn.t.Get(MetaKey(db, key))
+	assert.NoError(t, err)
+	assert.NotContains(t, string(raw), "secret")
+
+	// reads still see the plain value and the job finished
+	str, err = GetString(txn, key)
+	assert.NoError(t, err)
+	val, err := str.Get()
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("secret"), val)
+
+	job, err = GetRewriteJob(txn)
+	assert.NoError(t, err)
+	assert.Equal(t, RewriteDone, job.State)
+	assert.Equal(t, int64(1), job.Rewritten)
+	assert.NoError(t, txn.Commit(context.TODO()))
+}
@@ -0,0 +1,231 @@
+package db
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/pingcap/tidb/kv"
+	"go.uber.org/zap"
+)
+
+var (
+	sysSchemaLeader     = []byte("$sys:0:SCL:SCLeader")
+	sysSchemaVersionKey = []byte("$sys:0:SC:version")
+
+	schemaUpgradeInterval = time.Minute
+	schemaLeaderLifeTime  = 3 * time.Minute
+)
+
+// LayoutVersion is the newest on-disk layout this binary writes.
+// v1 is the original layout with the flat expire index and float score keys,
+// v2 adds the hashed expire index and the lexicographic zset score encoding
+const LayoutVersion = 2
+
+// schemaScanLimit bounds the meta scan of a migration probe, a store with
+// more keys is treated as not yet converged and probed again next round
+const schemaScanLimit = 100000
+
+// toSchemaVersionKey returns the layout version key of a namespace, the
+// global version lives at the bare key
+func toSchemaVersionKey(namespace string) []byte {
+	if namespace == "" {
+		return sysSchemaVersionKey
+	}
+	var key []byte
+	key = append(key, sysSchemaVersionKey...)
+	key = append(key, ':')
+	key = append(key, namespace...)
+	return key
+}
+
+// schemaVersion reads a stored layout version, 0 means the key is missing
+func schemaVersion(txn *Transaction, namespace string) (int, error) {
+	val, err := txn.t.Get(toSchemaVersionKey(namespace))
+	if err != nil {
+		if IsErrNotFound(err) {
+			return 0, nil
+		}
+		return 0, err
+	}
+	version, err := strconv.Atoi(string(val))
+	if err != nil {
+		return 0, fmt.Errorf("corrupted schema version %q", val)
+	}
+	return version, nil
+}
+
+func setSchemaVersion(txn *Transaction, namespace string, version int) error {
+	return txn.t.Set(toSchemaVersionKey(namespace), []byte(strconv.Itoa(version)))
+}
+
+// schemaMigration describes a layout change, the stored version may only be
+// raised to version once done proves no data of the older layout remains
+type schemaMigration struct {
+	version int
+	name    string
+	// done is a bounded read only probe, it may report false when it
+	// cannot prove completion within schemaScanLimit
+	done func(txn *Transaction) (bool, error)
+}
+
+var schemaMigrations = []schemaMigration{
+	{version: 2, name: "hashed-expire-index", done: unhashedExpireDrained},
+	{version: 2, name: "zset-score-v2", done: zsetScoreV1Drained},
+}
+
+// unhashedExpireDrained reports whether the flat v1 expire index is empty,
+// the expire worker drains it while new writes go to the hashed index
+func unhashedExpireDrained(txn *Transaction) (bool, error) {
+	iter, err := txn.t.Iter(expireKeyPrefix, kv.Key(expireKeyPrefix).PrefixNext())
+	if err != nil {
+		return false, err
+	}
+	defer iter.Close()
+	return !(iter.Valid() && iter.Key().HasPrefix(expireKeyPrefix)), nil
+}
+
+// zsetScoreV1Drained reports whether no zset meta of the v1 score layout
+// remains, the zset migrate worker converts them in the background
+func zsetScoreV1Drained(txn *Transaction) (bool, error) {
+	iter, err := txn.t.Iter(nil, nil)
+	if err != nil {
+		return false, err
+	}
+	defer iter.Close()
+
+	scanned := 0
+	for ; iter.Valid(); err = iter.Next() {
+		if err != nil {
+			return false, err
+		}
+		if scanned++; scanned > schemaScanLimit {
+			return false, nil
+		}
+		if !isMetaKey(iter.Key()) || bytes.HasPrefix(iter.Key(), []byte(sysNamespace)) {
+			continue
+		}
+		meta := iter.Value()
+		if len(meta) != ObjectEncodingLength+8 {
+			continue
+		}
+		if obj, err := DecodeObject(meta); err == nil && obj.Type == ObjectZSet {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// CheckSchema verifies the stored layout version at bootstrap. A fresh store
+// is stamped with LayoutVersion globally and for the default namespace, a
+// store of a newer layout is refused, an older one is accepted and left to
+// the background migrations and the schema upgrade worker
+func CheckSchema(db *DB) error {
+	txn, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	version, err := schemaVersion(txn, "")
+	if err != nil {
+		txn.Rollback()
+		return err
+	}
+	if version > LayoutVersion {
+		txn.Rollback()
+		return fmt.Errorf("store layout version %d is newer than the supported version %d", version, LayoutVersion)
+	}
+	if version == 0 {
+		if err := setSchemaVersion(txn, "", LayoutVersion); err != nil {
+			txn.Rollback()
+			return err
+		}
+		if err := setSchemaVersion(txn, "default", LayoutVersion); err != nil {
+			txn.Rollback()
+			return err
+		}
+		zap.L().Info("[SC] stamped fresh store", zap.Int("version", LayoutVersion))
+		return txn.Commit(context.Background())
+	}
+	if version < LayoutVersion {
+		zap.L().Info("[SC] store uses an older layout, background migrations will converge it",
+			zap.Int("stored", version), zap.Int("supported", LayoutVersion))
+	}
+	txn.Rollback()
+	return nil
+}
+
+// doSchemaUpgrade raises the stored version to LayoutVersion once every
+// registered migration above it has completed
+func doSchemaUpgrade(db *DB) error {
+	txn, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	version, err := schemaVersion(txn, "")
+	if err != nil {
+		txn.Rollback()
+		return err
+	}
+	if version == 0 || version >= LayoutVersion {
+		txn.Rollback()
+		return nil
+	}
+	for _, m := range schemaMigrations {
+		if m.version <= version {
+			continue
+		}
+		done, err := m.done(txn)
+		if err != nil {
+			txn.Rollback()
+			return err
+		}
+		if !done {
+			txn.Rollback()
+			return nil
+		}
+	}
+	if err := setSchemaVersion(txn, "", LayoutVersion); err != nil {
+		txn.Rollback()
+		return err
+	}
+	if err := setSchemaVersion(txn, "default", LayoutVersion); err != nil {
+		txn.Rollback()
+		return err
+	}
+	if err := txn.Commit(context.Background()); err != nil {
+		txn.Rollback()
+		return err
+	}
+	zap.L().Info("[SC] raised store layout version",
+		zap.Int("from", version), zap.Int("to", LayoutVersion))
+	return nil
+}
+
+// StartSchemaUpgrade probes the registered migrations and raises the stored
+// layout version when they all complete. Only the leader node probes
+func StartSchemaUpgrade(db *DB) {
+	ticker := time.NewTicker(schemaUpgradeInterval)
+	defer ticker.Stop()
+	id := UUID()
+	for range ticker.C {
+		isLeader, err := isLeader(db, sysSchemaLeader, id, schemaLeaderLifeTime)
+		if err != nil {
+			zap.L().Error("[SC] check schema leader failed",
+				zap.ByteString("leader", sysSchemaLeader),
+				zap.ByteString("uuid", id),
+				zap.Error(err))
+			continue
+		}
+		if !isLeader {
+			continue
+		}
+		if err := doSchemaUpgrade(db); err != nil {
+			zap.L().Error("[SC] schema upgrade failed",
+				zap.ByteString("leader", sysSchemaLeader),
+				zap.ByteString("uuid", id),
+				zap.Error(err))
+		}
+	}
+}
@@ -0,0 +1,79 @@
+package db
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func schemaTxn(t *testing.T, db *DB) *Transaction {
+	txn, err := db.Begin()
+	assert.NoError(t, err)
+	return txn
+}
+
+func TestCheckSchema(t *testing.T) {
+	db := MockDB()
+
+	// a fresh store is stamped globally and for the default namespace
+	assert.NoError(t, CheckSchema(db))
+	txn := schemaTxn(t, db)
+	version, err := schemaVersion(txn, "")
+	assert.NoError(t, err)
+	assert.Equal(t, LayoutVersion, version)
+	version, err = schemaVersion(txn, "default")
+	assert.NoError(t, err)
+	assert.Equal(t, LayoutVersion, version)
+	txn.Rollback()
+
+	// a newer layout is refused
+	txn = schemaTxn(t, db)
+	assert.NoError(t, setSchemaVersion(txn, "", LayoutVersion+1))
+	assert.NoError(t, txn.Commit(context.TODO()))
+	assert.Error(t, CheckSchema(db))
+
+	// an older layout is accepted and left untouched
+	txn = schemaTxn(t, db)
+	assert.NoError(t, setSchemaVersion(txn, "", 1))
+	assert.NoError(t, txn.Commit(context.TODO()))
+	assert.NoError(t, CheckSchema(db))
+	txn = schemaTxn(t, db)
+	version, err = schemaVersion(txn, "")
+	assert.NoError(t, err)
+	assert.Equal(t, 1, version)
+	txn.Rollback()
+}
+
+func TestSchemaUpgrade(t *testing.T) {
+	db := MockDB()
+	txn := schemaTxn(t, db)
+	assert.NoError(t, setSchemaVersion(txn, "", 1))
+
+	// a leftover key in the flat v1 expire index blocks the upgrade
+	v1Key := append([]byte{}, expireKeyPrefix...)
+	v1Key = append(v1Key, EncodeInt64(Now())...)
+	v1Key = append(v1Key, ':')
+	v1Key = append(v1Key, []byte("ns:001:M:key")...)
+	assert.NoError(t, txn.t.Set(v1Key, []byte("id")))
+	assert.NoError(t, txn.Commit(context.TODO()))
+
+	assert.NoError(t, doSchemaUpgrade(db))
+	txn = schemaTxn(t, db)
+	version, err := schemaVersion(txn, "")
+	assert.NoError(t, err)
+	assert.Equal(t, 1, version)
+
+	// once drained the version is raised
+	assert.NoError(t, txn.t.Delete(v1Key))
+	assert.NoError(t, txn.Commit(context.TODO()))
+	assert.NoError(t, doSchemaUpgrade(db))
+	txn = schemaTxn(t, db)
+	version, err = schemaVersion(txn, "")
+	assert.NoError(t, err)
+	assert.Equal(t, LayoutVersion, version)
+	version, err = schemaVersion(txn, "default")
+	assert.NoError(t, err)
+	assert.Equal(t, LayoutVersion, version)
+	txn.Rollback()
+}
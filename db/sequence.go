@@ -0,0 +1,123 @@
+package db
+
+import (
+	"strconv"
+	"sync"
+
+	"github.com/distributedio/titan/db/keyspace"
+)
+
+// seqBlockSize is the number of ids one tikv reservation covers, the rest
+// of a reserved block is served from the node's cache
+const seqBlockSize = 1000
+
+// seqRange is the block of ids this node reserved for one sequence, ids
+// in (cur, end] are handed out locally without touching tikv
+type seqRange struct {
+	cur int64
+	end int64
+}
+
+// seqCache holds the reserved blocks of this node keyed by the raw
+// sequence key
+var seqCache = struct {
+	sync.Mutex
+	ranges map[string]*seqRange
+}{ranges: make(map[string]*seqRange)}
+
+// Sequence is a named counter handing out unique increasing ids. Whole
+// blocks are reserved in tikv and consumed from the node's cache, so most
+// calls never touch the counter key and concurrent writers do not pile up
+// conflicts on it the way INCR does. Ids are unique across nodes but only
+// roughly ordered, and ids of an unconsumed block are lost when the node
+// goes away
+type Sequence struct {
+	txn *Transaction
+	key []byte
+}
+
+// Sequence returns the named sequence of the transaction's database
+func (txn *Transaction) Sequence(name []byte) *Sequence {
+	return &Sequence{
+		txn: txn,
+		key: keyspace.SequenceKey(storageNamespace(txn.db.Namespace), txn.db.ID.Bytes(), name),
+	}
+}
+
+// load reads the persisted high water mark, a sequence never used starts
+// at zero
+func (seq *Sequence) load() (int64, error) {
+	val, err := seq.txn.t.Get(seq.key)
+	if err != nil {
+		if IsErrNotFound(err) {
+			return 0, nil
+		}
+		return 0, err
+	}
+	v, err := strconv.ParseInt(string(val), 10, 64)
+	if err != nil {
+		return 0, ErrSequenceValue
+	}
+	return v, nil
+}
+
+// Next reserves n consecutive ids ending at the returned value. When the
+// cached block covers the request no tikv access happens, otherwise a
+// whole block is reserved in the caller's transaction and published
+// through the returned hook, which must run only after the commit
+// succeeds so a failed commit never leaks the range into the cache
+func (seq *Sequence) Next(n int64) (int64, func(), error) {
+	seqCache.Lock()
+	if r, ok := seqCache.ranges[string(seq.key)]; ok && r.cur+n <= r.end {
+		r.cur += n
+		val := r.cur
+		seqCache.Unlock()
+		return val, nil, nil
+	}
+	seqCache.Unlock()
+
+	val, err := seq.load()
+	if err != nil {
+		return 0, nil, err
+	}
+	block := n
+	if block < seqBlockSize {
+		block = seqBlockSize
+	}
+	end := val + block
+	if err := seq.txn.t.Set(seq.key, []byte(strconv.FormatInt(end, 10))); err != nil {
+		return 0, nil, err
+	}
+
+	next := val + n
+	key := string(seq.key)
+	publish := func() {
+		seqCache.Lock()
+		seqCache.ranges[key] = &seqRange{cur: next, end: end}
+		seqCache.Unlock()
+	}
+	return next, publish, nil
+}
+
+// Set rewrites the sequence so ids after the next reservation start from
+// v, the returned hook drops this node's cached block after the commit.
+// Blocks other nodes already hold stay valid, Set only steers future
+// reservations
+func (seq *Sequence) Set(v int64) (func(), error) {
+	if err := seq.txn.t.Set(seq.key, []byte(strconv.FormatInt(v, 10))); err != nil {
+		return nil, err
+	}
+	key := string(seq.key)
+	drop := func() {
+		seqCache.Lock()
+		delete(seqCache.ranges, key)
+		seqCache.Unlock()
+	}
+	return drop, nil
+}
+
+// Get returns the persisted high water mark of the sequence, the top of
+// the last reserved block rather than the last id a node handed out
+func (seq *Sequence) Get() (int64, error) {
+	return seq.load()
+}
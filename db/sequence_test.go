@@ -0,0 +1,66 @@
+package db
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSequence(t *testing.T) {
+	db := MockDB()
+	name := []byte("TestSequence")
+
+	// the first Next reserves a whole block
+	txn, err := db.Begin()
+	assert.NoError(t, err)
+	val, publish, err := txn.Sequence(name).Next(1)
+	assert.NoError(t, err)
+	assert.NotNil(t, publish)
+	assert.Equal(t, int64(1), val)
+	assert.NoError(t, txn.Commit(context.TODO()))
+	publish()
+
+	// the persisted high water mark is the top of the block
+	txn, err = db.Begin()
+	assert.NoError(t, err)
+	v, err := txn.Sequence(name).Get()
+	assert.NoError(t, err)
+	assert.Equal(t, int64(seqBlockSize), v)
+	assert.NoError(t, txn.Commit(context.TODO()))
+
+	// the rest of the block is served from the cache
+	txn, err = db.Begin()
+	assert.NoError(t, err)
+	val, publish, err = txn.Sequence(name).Next(5)
+	assert.NoError(t, err)
+	assert.Nil(t, publish)
+	assert.Equal(t, int64(6), val)
+	assert.NoError(t, txn.Commit(context.TODO()))
+
+	// a request beyond the cached block reserves a new one
+	txn, err = db.Begin()
+	assert.NoError(t, err)
+	val, publish, err = txn.Sequence(name).Next(seqBlockSize)
+	assert.NoError(t, err)
+	assert.NotNil(t, publish)
+	assert.Equal(t, int64(2*seqBlockSize), val)
+	assert.NoError(t, txn.Commit(context.TODO()))
+	publish()
+
+	// Set steers future reservations and drops the cached block
+	txn, err = db.Begin()
+	assert.NoError(t, err)
+	drop, err := txn.Sequence(name).Set(9000)
+	assert.NoError(t, err)
+	assert.NoError(t, txn.Commit(context.TODO()))
+	drop()
+
+	txn, err = db.Begin()
+	assert.NoError(t, err)
+	val, publish, err = txn.Sequence(name).Next(1)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(9001), val)
+	assert.NoError(t, txn.Commit(context.TODO()))
+	publish()
+}
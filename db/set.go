@@ -36,23 +36,31 @@ func GetSet(txn *Transaction, key []byte) (*Set, error) {
 		return nil, err
 	}
 
-	obj, err := DecodeObject(meta)
+	objType, expireAt, err := peekMeta(meta)
 	if err != nil {
 		return nil, err
 	}
-	if IsExpired(obj, Now()) {
+	if expireAt != 0 && expireAt <= Now() {
+		if obj, err := DecodeObject(meta); err == nil {
+			tryLazyExpire(txn.db, key, obj)
+		}
 		return set, nil
 	}
-	if obj.Type != ObjectSet {
+	if objType != ObjectSet {
 		return nil, ErrTypeMismatch
 	}
 
+	obj, err := DecodeObject(meta)
+	if err != nil {
+		return nil, err
+	}
+
 	m := meta[ObjectEncodingLength:]
 	if len(m) != 8 {
 		return nil, ErrInvalidLength
 	}
 	set.meta.Object = *obj
-    set.meta.Len    = int64(binary.BigEndian.Uint64(m[:8]))
+	set.meta.Len = int64(binary.BigEndian.Uint64(m[:8]))
 	set.exists = true
 	return set, nil
 }
@@ -92,7 +100,7 @@ func (siter *SetIter) Valid() bool {
 	return true
 }
 
-//newSet create new Set object
+// newSet create new Set object
 func newSet(txn *Transaction, key []byte) *Set {
 	now := Now()
 	return &Set{
@@ -112,7 +120,7 @@ func newSet(txn *Transaction, key []byte) *Set {
 	}
 }
 
-//encodeSetMeta encodes meta data into byte slice
+// encodeSetMeta encodes meta data into byte slice
 func encodeSetMeta(meta *SetMeta) []byte {
 	b := EncodeObject(&meta.Object)
 	m := make([]byte, 8)
@@ -260,12 +268,18 @@ func (set *Set) SPop(count int64) ([][]byte, error) {
 		return nil, err
 	}
 	defer iter.Close()
+	// popping a whole large set would delete Len keys in the caller's
+	// transaction, drop the meta and leave the data keys to the
+	// background gc instead
+	lazy := count >= set.meta.Len && shouldLazyDelete(set.meta.Len)
 	var deleted int64
 	var members [][]byte
 	for iter.Valid() && iter.Key().HasPrefix(prefix) && count != 0 {
 		members = append(members, iter.Key()[len(prefix):])
-		if err := set.txn.t.Delete([]byte(iter.Key())); err != nil {
-			return nil, err
+		if !lazy {
+			if err := set.txn.t.Delete([]byte(iter.Key())); err != nil {
+				return nil, err
+			}
 		}
 		deleted++
 		count--
@@ -273,6 +287,14 @@ func (set *Set) SPop(count int64) ([][]byte, error) {
 			return nil, err
 		}
 	}
+	if lazy {
+		if err := set.txn.Destory(&set.meta.Object, set.key); err != nil {
+			return nil, err
+		}
+		lazyDeleted()
+		set.meta.Len = 0
+		return members, nil
+	}
 	set.meta.Len -= deleted
 	if err := set.updateMeta(); err != nil {
 		return nil, err
@@ -288,10 +310,10 @@ func (set *Set) SRem(members [][]byte) (int64, error) {
 	}
 	dkey := DataKey(set.txn.db, set.meta.ID)
 	ms := RemoveRepByMap(members)
-	ikeys := make([][]byte, len(ms))
+	var toDelete [][]byte
 	for i := range ms {
-		ikeys[i] = setItemKey(dkey, ms[i])
-		value, err := set.txn.t.Get(ikeys[i])
+		ikey := setItemKey(dkey, ms[i])
+		value, err := set.txn.t.Get(ikey)
 		if err != nil {
 			if IsErrNotFound(err) {
 				continue
@@ -299,10 +321,24 @@ func (set *Set) SRem(members [][]byte) (int64, error) {
 			return 0, err
 		}
 		if bytes.Equal(value, SetNilValue) {
-			if err := set.txn.t.Delete([]byte(ikeys[i])); err != nil {
-				return 0, err
-			}
-			num++
+			toDelete = append(toDelete, ikey)
+		}
+	}
+	num = int64(len(toDelete))
+	// removing every member of a large set would delete Len keys in the
+	// caller's transaction, drop the meta and leave the data keys to the
+	// background gc instead
+	if num > 0 && num >= set.meta.Len && shouldLazyDelete(set.meta.Len) {
+		if err := set.txn.Destory(&set.meta.Object, set.key); err != nil {
+			return 0, err
+		}
+		lazyDeleted()
+		set.meta.Len = 0
+		return num, nil
+	}
+	for _, ikey := range toDelete {
+		if err := set.txn.t.Delete(ikey); err != nil {
+			return 0, err
 		}
 	}
 	set.meta.Len -= num
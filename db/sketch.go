@@ -0,0 +1,354 @@
+package db
+
+import (
+	"encoding/binary"
+	"errors"
+	"hash/fnv"
+	"math"
+	"math/rand"
+	"sort"
+)
+
+// The probabilistic sketches are stored in plain string values, so they
+// share expiration, deletion and gc with the string type and need no new
+// object encoding. A magic prefix tells the sketch kinds apart and guards
+// against decoding an unrelated string value
+
+var (
+	cmsMagic  = []byte{0, 'c', 'm', 's'}
+	topkMagic = []byte{0, 't', 'p', 'k'}
+
+	// ErrSketchValue is returned when a string value does not hold the
+	// expected sketch kind
+	ErrSketchValue = errors.New("value is not a valid sketch")
+
+	// ErrSketchDim is returned for dimensions out of range
+	ErrSketchDim = errors.New("invalid sketch dimensions")
+)
+
+// maxSketchCells caps width*depth so a sketch value stays well below the
+// kv entry size limit
+const maxSketchCells = 1 << 21
+
+// sketchHash returns two independent 32 bit hashes of item for double
+// hashing, row i of a sketch uses h1 + i*h2
+func sketchHash(item []byte) (uint32, uint32) {
+	f := fnv.New64a()
+	f.Write(item)
+	h := f.Sum64()
+	return uint32(h), uint32(h>>32) | 1
+}
+
+// CMSketch is a count-min sketch, a depth x width matrix of counters where
+// every item increments one counter per row and reads back the minimum
+type CMSketch struct {
+	width uint32
+	depth uint32
+	count uint64
+	rows  [][]uint32
+}
+
+// NewCMSketch creates a count-min sketch with the given dimensions
+func NewCMSketch(width, depth int64) (*CMSketch, error) {
+	if width <= 0 || depth <= 0 || width*depth > maxSketchCells {
+		return nil, ErrSketchDim
+	}
+	c := &CMSketch{width: uint32(width), depth: uint32(depth)}
+	c.rows = make([][]uint32, depth)
+	for i := range c.rows {
+		c.rows[i] = make([]uint32, width)
+	}
+	return c, nil
+}
+
+// DecodeCMSketch parses an encoded count-min sketch
+func DecodeCMSketch(b []byte) (*CMSketch, error) {
+	if len(b) < len(cmsMagic)+16 || string(b[:len(cmsMagic)]) != string(cmsMagic) {
+		return nil, ErrSketchValue
+	}
+	b = b[len(cmsMagic):]
+	c := &CMSketch{
+		width: binary.BigEndian.Uint32(b[:4]),
+		depth: binary.BigEndian.Uint32(b[4:8]),
+		count: binary.BigEndian.Uint64(b[8:16]),
+	}
+	cells := int(c.width) * int(c.depth)
+	if c.width == 0 || c.depth == 0 || cells > maxSketchCells || len(b) != 16+4*cells {
+		return nil, ErrSketchValue
+	}
+	b = b[16:]
+	c.rows = make([][]uint32, c.depth)
+	for i := range c.rows {
+		row := make([]uint32, c.width)
+		for j := range row {
+			row[j] = binary.BigEndian.Uint32(b[:4])
+			b = b[4:]
+		}
+		c.rows[i] = row
+	}
+	return c, nil
+}
+
+// Encode serializes the sketch into a string value
+func (c *CMSketch) Encode() []byte {
+	b := append([]byte{}, cmsMagic...)
+	b = append(b, make([]byte, 16)...)
+	binary.BigEndian.PutUint32(b[len(cmsMagic):], c.width)
+	binary.BigEndian.PutUint32(b[len(cmsMagic)+4:], c.depth)
+	binary.BigEndian.PutUint64(b[len(cmsMagic)+8:], c.count)
+	cell := make([]byte, 4)
+	for _, row := range c.rows {
+		for _, v := range row {
+			binary.BigEndian.PutUint32(cell, v)
+			b = append(b, cell...)
+		}
+	}
+	return b
+}
+
+// IncrBy adds delta to the counters of item and returns the new estimate
+func (c *CMSketch) IncrBy(item []byte, delta uint32) uint32 {
+	h1, h2 := sketchHash(item)
+	min := uint32(math.MaxUint32)
+	for i := range c.rows {
+		idx := (h1 + uint32(i)*h2) % c.width
+		if v := c.rows[i][idx] + delta; v > c.rows[i][idx] { // saturate, do not wrap
+			c.rows[i][idx] = v
+		} else {
+			c.rows[i][idx] = math.MaxUint32
+		}
+		if c.rows[i][idx] < min {
+			min = c.rows[i][idx]
+		}
+	}
+	c.count += uint64(delta)
+	return min
+}
+
+// Query returns the count estimate of item, an over-approximation
+func (c *CMSketch) Query(item []byte) uint32 {
+	h1, h2 := sketchHash(item)
+	min := uint32(math.MaxUint32)
+	for i := range c.rows {
+		idx := (h1 + uint32(i)*h2) % c.width
+		if c.rows[i][idx] < min {
+			min = c.rows[i][idx]
+		}
+	}
+	return min
+}
+
+// Info returns the dimensions and the total of all increments
+func (c *CMSketch) Info() (width, depth int64, count int64) {
+	return int64(c.width), int64(c.depth), int64(c.count)
+}
+
+// topkBucket is one cell of the heavykeeper matrix, it remembers the
+// fingerprint of the item owning the cell and its decayed count
+type topkBucket struct {
+	fp    uint32
+	count uint32
+}
+
+// TopKItem is an entry of the tracked heavy hitter list
+type TopKItem struct {
+	Item  []byte
+	Count uint32
+}
+
+// TopK tracks the approximate top k items by count with the heavykeeper
+// algorithm, colliding items decay each other so heavy hitters survive
+type TopK struct {
+	k       uint32
+	width   uint32
+	depth   uint32
+	decay   float64
+	buckets [][]topkBucket
+	items   []TopKItem
+}
+
+// NewTopK creates a top-k sketch, decay must be in (0, 1)
+func NewTopK(k, width, depth int64, decay float64) (*TopK, error) {
+	if k <= 0 || width <= 0 || depth <= 0 || width*depth > maxSketchCells {
+		return nil, ErrSketchDim
+	}
+	if decay <= 0 || decay >= 1 {
+		return nil, ErrSketchDim
+	}
+	t := &TopK{k: uint32(k), width: uint32(width), depth: uint32(depth), decay: decay}
+	t.buckets = make([][]topkBucket, depth)
+	for i := range t.buckets {
+		t.buckets[i] = make([]topkBucket, width)
+	}
+	return t, nil
+}
+
+// DecodeTopK parses an encoded top-k sketch
+func DecodeTopK(b []byte) (*TopK, error) {
+	if len(b) < len(topkMagic)+24 || string(b[:len(topkMagic)]) != string(topkMagic) {
+		return nil, ErrSketchValue
+	}
+	b = b[len(topkMagic):]
+	t := &TopK{
+		k:     binary.BigEndian.Uint32(b[:4]),
+		width: binary.BigEndian.Uint32(b[4:8]),
+		depth: binary.BigEndian.Uint32(b[8:12]),
+		decay: math.Float64frombits(binary.BigEndian.Uint64(b[12:20])),
+	}
+	cells := int(t.width) * int(t.depth)
+	if t.k == 0 || t.width == 0 || t.depth == 0 || cells > maxSketchCells {
+		return nil, ErrSketchValue
+	}
+	nitems := int(binary.BigEndian.Uint32(b[20:24]))
+	b = b[24:]
+	if len(b) < 8*cells {
+		return nil, ErrSketchValue
+	}
+	t.buckets = make([][]topkBucket, t.depth)
+	for i := range t.buckets {
+		row := make([]topkBucket, t.width)
+		for j := range row {
+			row[j].fp = binary.BigEndian.Uint32(b[:4])
+			row[j].count = binary.BigEndian.Uint32(b[4:8])
+			b = b[8:]
+		}
+		t.buckets[i] = row
+	}
+	if nitems > int(t.k) {
+		return nil, ErrSketchValue
+	}
+	for i := 0; i < nitems; i++ {
+		if len(b) < 8 {
+			return nil, ErrSketchValue
+		}
+		l := int(binary.BigEndian.Uint32(b[:4]))
+		count := binary.BigEndian.Uint32(b[4:8])
+		b = b[8:]
+		if len(b) < l {
+			return nil, ErrSketchValue
+		}
+		t.items = append(t.items, TopKItem{Item: append([]byte{}, b[:l]...), Count: count})
+		b = b[l:]
+	}
+	if len(b) != 0 {
+		return nil, ErrSketchValue
+	}
+	return t, nil
+}
+
+// Encode serializes the sketch into a string value
+func (t *TopK) Encode() []byte {
+	b := append([]byte{}, topkMagic...)
+	b = append(b, make([]byte, 24)...)
+	binary.BigEndian.PutUint32(b[len(topkMagic):], t.k)
+	binary.BigEndian.PutUint32(b[len(topkMagic)+4:], t.width)
+	binary.BigEndian.PutUint32(b[len(topkMagic)+8:], t.depth)
+	binary.BigEndian.PutUint64(b[len(topkMagic)+12:], math.Float64bits(t.decay))
+	binary.BigEndian.PutUint32(b[len(topkMagic)+20:], uint32(len(t.items)))
+	cell := make([]byte, 8)
+	for _, row := range t.buckets {
+		for _, bucket := range row {
+			binary.BigEndian.PutUint32(cell, bucket.fp)
+			binary.BigEndian.PutUint32(cell[4:], bucket.count)
+			b = append(b, cell...)
+		}
+	}
+	for _, item := range t.items {
+		binary.BigEndian.PutUint32(cell, uint32(len(item.Item)))
+		binary.BigEndian.PutUint32(cell[4:], item.Count)
+		b = append(b, cell...)
+		b = append(b, item.Item...)
+	}
+	return b
+}
+
+// Add counts item once. It returns the item expelled from the top list,
+// nil if the list did not change or had room
+func (t *TopK) Add(item []byte) []byte {
+	h1, h2 := sketchHash(item)
+	fp := h1 ^ h2
+	max := uint32(0)
+	for i := range t.buckets {
+		bucket := &t.buckets[i][(h1+uint32(i)*h2)%t.width]
+		switch {
+		case bucket.count == 0:
+			bucket.fp = fp
+			bucket.count = 1
+		case bucket.fp == fp:
+			bucket.count++
+		default:
+			// another item owns the cell, decay it and take over
+			// once it drops to zero
+			if rand.Float64() < math.Pow(t.decay, float64(bucket.count)) {
+				if bucket.count--; bucket.count == 0 {
+					bucket.fp = fp
+					bucket.count = 1
+				}
+			}
+		}
+		if bucket.fp == fp && bucket.count > max {
+			max = bucket.count
+		}
+	}
+
+	for i := range t.items {
+		if string(t.items[i].Item) == string(item) {
+			if max > t.items[i].Count {
+				t.items[i].Count = max
+			}
+			return nil
+		}
+	}
+	if len(t.items) < int(t.k) {
+		t.items = append(t.items, TopKItem{Item: append([]byte{}, item...), Count: max})
+		return nil
+	}
+	min := 0
+	for i := range t.items {
+		if t.items[i].Count < t.items[min].Count {
+			min = i
+		}
+	}
+	if max <= t.items[min].Count {
+		return nil
+	}
+	expelled := t.items[min].Item
+	t.items[min] = TopKItem{Item: append([]byte{}, item...), Count: max}
+	return expelled
+}
+
+// Count returns the count estimate of item from the buckets
+func (t *TopK) Count(item []byte) uint32 {
+	h1, h2 := sketchHash(item)
+	fp := h1 ^ h2
+	max := uint32(0)
+	for i := range t.buckets {
+		bucket := t.buckets[i][(h1+uint32(i)*h2)%t.width]
+		if bucket.fp == fp && bucket.count > max {
+			max = bucket.count
+		}
+	}
+	return max
+}
+
+// Query reports whether item is in the tracked top list
+func (t *TopK) Query(item []byte) bool {
+	for i := range t.items {
+		if string(t.items[i].Item) == string(item) {
+			return true
+		}
+	}
+	return false
+}
+
+// List returns the tracked items ordered by count descending
+func (t *TopK) List() []TopKItem {
+	items := append([]TopKItem{}, t.items...)
+	sort.SliceStable(items, func(i, j int) bool { return items[i].Count > items[j].Count })
+	return items
+}
+
+// Info returns the parameters of the sketch
+func (t *TopK) Info() (k, width, depth int64, decay float64) {
+	return int64(t.k), int64(t.width), int64(t.depth), t.decay
+}
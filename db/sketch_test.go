@@ -0,0 +1,69 @@
+package db
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCMSketchCodec(t *testing.T) {
+	sketch, err := NewCMSketch(128, 4)
+	assert.NoError(t, err)
+	assert.Equal(t, uint32(3), sketch.IncrBy([]byte("a"), 3))
+	sketch.IncrBy([]byte("b"), 1)
+
+	decoded, err := DecodeCMSketch(sketch.Encode())
+	assert.NoError(t, err)
+	assert.Equal(t, uint32(3), decoded.Query([]byte("a")))
+	assert.Equal(t, uint32(1), decoded.Query([]byte("b")))
+	width, depth, count := decoded.Info()
+	assert.Equal(t, int64(128), width)
+	assert.Equal(t, int64(4), depth)
+	assert.Equal(t, int64(4), count)
+
+	_, err = DecodeCMSketch([]byte("garbage"))
+	assert.Equal(t, ErrSketchValue, err)
+
+	_, err = NewCMSketch(0, 4)
+	assert.Equal(t, ErrSketchDim, err)
+}
+
+func TestTopKCodec(t *testing.T) {
+	sketch, err := NewTopK(2, 128, 4, 0.9)
+	assert.NoError(t, err)
+	for i := 0; i < 3; i++ {
+		sketch.Add([]byte("a"))
+	}
+	sketch.Add([]byte("b"))
+
+	decoded, err := DecodeTopK(sketch.Encode())
+	assert.NoError(t, err)
+	assert.True(t, decoded.Query([]byte("a")))
+	assert.False(t, decoded.Query([]byte("missing")))
+	assert.Equal(t, uint32(3), decoded.Count([]byte("a")))
+
+	items := decoded.List()
+	assert.Len(t, items, 2)
+	assert.Equal(t, []byte("a"), items[0].Item)
+
+	_, err = DecodeTopK([]byte("garbage"))
+	assert.Equal(t, ErrSketchValue, err)
+
+	_, err = NewTopK(2, 128, 4, 1.5)
+	assert.Equal(t, ErrSketchDim, err)
+}
+
+func TestTopKExpel(t *testing.T) {
+	sketch, err := NewTopK(1, 128, 4, 0.9)
+	assert.NoError(t, err)
+	sketch.Add([]byte("a"))
+	var expelled []byte
+	for i := 0; i < 3; i++ {
+		if e := sketch.Add([]byte("b")); e != nil {
+			expelled = e
+		}
+	}
+	assert.Equal(t, []byte("a"), expelled)
+	assert.True(t, sketch.Query([]byte("b")))
+	assert.False(t, sketch.Query([]byte("a")))
+}
@@ -0,0 +1,90 @@
+package db
+
+import (
+	"bytes"
+
+	"github.com/pingcap/tidb/kv"
+)
+
+// SnapshotDiff compares the meta keys of the db between two tikv versions
+// and reports what changed in key order, one line per key prefixed with
+// added, removed or changed. The comparison merge-joins two pinned
+// snapshots, so it never materializes either side. At most limit keys are
+// examined per call, the returned cursor resumes the walk and is nil once
+// both snapshots are exhausted. Reading below the tikv gc safe point fails
+func (txn *Transaction) SnapshotDiff(fromVer, toVer uint64, cursor []byte, limit int) ([]string, []byte, error) {
+	fromSnap, err := txn.db.kv.GetSnapshot(kv.Version{Ver: fromVer})
+	if err != nil {
+		return nil, nil, err
+	}
+	toSnap, err := txn.db.kv.GetSnapshot(kv.Version{Ver: toVer})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	prefix := MetaKey(txn.db, nil)
+	start := MetaKey(txn.db, cursor)
+	end := kv.Key(prefix).PrefixNext()
+	fromIter, err := fromSnap.Iter(start, end)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer fromIter.Close()
+	toIter, err := toSnap.Iter(start, end)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer toIter.Close()
+
+	valid := func(iter kv.Iterator) bool {
+		return iter.Valid() && bytes.HasPrefix(iter.Key(), prefix)
+	}
+
+	var report []string
+	for valid(fromIter) || valid(toIter) {
+		var cmp int
+		switch {
+		case !valid(fromIter):
+			cmp = 1
+		case !valid(toIter):
+			cmp = -1
+		default:
+			cmp = bytes.Compare(fromIter.Key(), toIter.Key())
+		}
+
+		var key []byte
+		if cmp <= 0 {
+			key = []byte(fromIter.Key())[len(prefix):]
+		} else {
+			key = []byte(toIter.Key())[len(prefix):]
+		}
+		if limit <= 0 {
+			return report, key, nil
+		}
+		limit--
+
+		switch {
+		case cmp < 0:
+			report = append(report, "removed "+string(key))
+			if err := fromIter.Next(); err != nil {
+				return nil, nil, err
+			}
+		case cmp > 0:
+			report = append(report, "added "+string(key))
+			if err := toIter.Next(); err != nil {
+				return nil, nil, err
+			}
+		default:
+			if !bytes.Equal(fromIter.Value(), toIter.Value()) {
+				report = append(report, "changed "+string(key))
+			}
+			if err := fromIter.Next(); err != nil {
+				return nil, nil, err
+			}
+			if err := toIter.Next(); err != nil {
+				return nil, nil, err
+			}
+		}
+	}
+	return report, nil, nil
+}
@@ -0,0 +1,63 @@
+package db
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSnapshotDiff(t *testing.T) {
+	d := MockDB()
+
+	txn, err := d.Begin()
+	assert.NoError(t, err)
+	for _, kv := range [][2]string{{"diff-a", "v"}, {"diff-b", "v"}} {
+		s := NewString(txn, []byte(kv[0]))
+		assert.NoError(t, s.Set([]byte(kv[1])))
+	}
+	assert.NoError(t, txn.Commit(context.TODO()))
+
+	txn, err = d.Begin()
+	assert.NoError(t, err)
+	fromVer := txn.SnapshotVersion()
+	assert.NoError(t, txn.Commit(context.TODO()))
+
+	// remove diff-a, change diff-b, add diff-c
+	txn, err = d.Begin()
+	assert.NoError(t, err)
+	_, err = txn.Kv().Delete([][]byte{[]byte("diff-a")})
+	assert.NoError(t, err)
+	s := NewString(txn, []byte("diff-b"))
+	assert.NoError(t, s.Set([]byte("v2")))
+	s = NewString(txn, []byte("diff-c"))
+	assert.NoError(t, s.Set([]byte("v")))
+	assert.NoError(t, txn.Commit(context.TODO()))
+
+	txn, err = d.Begin()
+	assert.NoError(t, err)
+	toVer := txn.SnapshotVersion()
+
+	report, next, err := txn.SnapshotDiff(fromVer, toVer, nil, 100)
+	assert.NoError(t, err)
+	assert.Nil(t, next)
+	assert.Equal(t, []string{"removed diff-a", "changed diff-b", "added diff-c"}, report)
+
+	// identical versions diff empty
+	report, next, err = txn.SnapshotDiff(fromVer, fromVer, nil, 100)
+	assert.NoError(t, err)
+	assert.Nil(t, next)
+	assert.Empty(t, report)
+
+	// the limit bounds one call, the cursor resumes the walk
+	report, next, err = txn.SnapshotDiff(fromVer, toVer, nil, 1)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"removed diff-a"}, report)
+	assert.Equal(t, []byte("diff-b"), next)
+	report, next, err = txn.SnapshotDiff(fromVer, toVer, next, 100)
+	assert.NoError(t, err)
+	assert.Nil(t, next)
+	assert.Equal(t, []string{"changed diff-b", "added diff-c"}, report)
+
+	assert.NoError(t, txn.Commit(context.TODO()))
+}
@@ -2,7 +2,7 @@ package store
 
 import "github.com/pingcap/tidb/store/mockstore"
 
-//MockAddr default mock tikv addr
+// MockAddr default mock tikv addr
 var MockAddr = "mocktikv://"
 
 // MockOpen create fake tikv db
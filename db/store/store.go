@@ -2,6 +2,7 @@ package store
 
 import (
 	"strings"
+	"sync"
 	"unsafe"
 
 	"github.com/pingcap/tidb/kv"
@@ -30,11 +31,14 @@ const (
 	NotFillCache
 	// SyncLog decides whether the WAL(write-ahead log) of this request should be synchronized.
 	SyncLog
-	// KeyOnly retrieve only keys, it can be used in scan now.
+	// KeyOnly retrieve only keys, it can be used in scan now. Scans that
+	// ignore values should set it to spare tikv the value transfer, and
+	// restore it before the transaction reads values again. The scanner
+	// picks its own prefetch batch size, the sdk exposes no knob for it.
 	KeyOnly
 )
 
-//type rename tidb kv type
+// type rename tidb kv type
 type (
 	// Storage defines the interface for storage.
 	Storage kv.Storage
@@ -46,7 +50,7 @@ type (
 	Option kv.Option
 )
 
-//Open create tikv db ,create fake db if addr contains mockaddr
+// Open create tikv db ,create fake db if addr contains mockaddr
 func Open(addrs string) (r Storage, e error) {
 	if strings.Contains(addrs, MockAddr) {
 		return MockOpen(addrs)
@@ -82,10 +86,60 @@ func LockKeys(txn Transaction, keys [][]byte) error {
 	return txn.LockKeys(kvKeys...)
 }
 
-// BatchGetValues issue batch requests to get values
+// batchGetLimit caps the keys of one BatchGet call so a huge multi-key
+// command does not turn into a single oversized grpc message, and
+// batchGetConcurrency bounds how many sub-batches are in flight at once
+const (
+	batchGetLimit       = 1024
+	batchGetConcurrency = 8
+)
+
+// BatchGetValues issue batch requests to get values, splitting oversized
+// key sets into bounded sub-batches fetched concurrently
 func BatchGetValues(txn Transaction, keys [][]byte) (map[string][]byte, error) {
 	kvkeys := *(*[]kv.Key)(unsafe.Pointer(&keys))
-	return txn.BatchGet(kvkeys)
+	if len(kvkeys) <= batchGetLimit {
+		return txn.BatchGet(kvkeys)
+	}
+
+	var (
+		mu     sync.Mutex
+		wg     sync.WaitGroup
+		outErr error
+	)
+	values := make(map[string][]byte, len(kvkeys))
+	sem := make(chan struct{}, batchGetConcurrency)
+	for begin := 0; begin < len(kvkeys); begin += batchGetLimit {
+		end := begin + batchGetLimit
+		if end > len(kvkeys) {
+			end = len(kvkeys)
+		}
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(batch []kv.Key) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			vals, err := txn.BatchGet(batch)
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				if outErr == nil {
+					outErr = err
+				}
+				return
+			}
+			for k, v := range vals {
+				values[k] = v
+			}
+		}(kvkeys[begin:end])
+	}
+	wg.Wait()
+	if outErr != nil {
+		return nil, outErr
+	}
+	return values, nil
 }
 
 func SetOption(txn Transaction, opt Option, val interface{}) {
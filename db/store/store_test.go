@@ -0,0 +1,38 @@
+package store
+
+import (
+	"context"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBatchGetValuesSplit(t *testing.T) {
+	s, err := MockOpen(MockAddr)
+	assert.NoError(t, err)
+
+	// cross the split threshold so several sub-batches are merged
+	count := batchGetLimit*2 + 100
+	txn, err := s.Begin()
+	assert.NoError(t, err)
+	keys := make([][]byte, count)
+	for i := 0; i < count; i++ {
+		keys[i] = []byte("batchget:" + strconv.Itoa(i))
+		assert.NoError(t, txn.Set(keys[i], []byte(strconv.Itoa(i))))
+	}
+	assert.NoError(t, txn.Commit(context.TODO()))
+
+	txn, err = s.Begin()
+	assert.NoError(t, err)
+	keys = append(keys, []byte("batchget:missing"))
+	values, err := BatchGetValues(txn, keys)
+	assert.NoError(t, err)
+	assert.Len(t, values, count)
+	for i := 0; i < count; i += 777 {
+		assert.Equal(t, []byte(strconv.Itoa(i)), values["batchget:"+strconv.Itoa(i)])
+	}
+	_, ok := values["batchget:missing"]
+	assert.False(t, ok)
+	assert.NoError(t, txn.Commit(context.TODO()))
+}
@@ -2,9 +2,11 @@ package db
 
 import (
 	"strconv"
+
+	"go.uber.org/zap"
 )
 
-//StringMeta string meta msg
+// StringMeta string meta msg
 type StringMeta struct {
 	Object
 	Value []byte
@@ -32,8 +34,13 @@ func GetString(txn *Transaction, key []byte) (*String, error) {
 		}
 		return nil, err
 	}
-	if err := str.decode(Meta); err != nil && err != ErrKeyNotFound {
-		return nil, err
+	if err := str.decode(Meta); err != nil {
+		if err != ErrKeyNotFound {
+			return nil, err
+		}
+		if obj, derr := DecodeObject(Meta); derr == nil && IsExpired(obj, now) {
+			tryLazyExpire(txn.db, key, obj)
+		}
 	}
 
 	str.Meta.UpdatedAt = now
@@ -79,7 +86,11 @@ func (s *String) Set(val []byte, expire ...int64) error {
 		s.Meta.ExpireAt = 0
 	}
 	s.Meta.Value = val
-	return s.txn.t.Set(mkey, s.encode())
+	encoded, err := s.encode()
+	if err != nil {
+		return err
+	}
+	return s.txn.t.Set(mkey, encoded)
 }
 
 // Len value len
@@ -98,7 +109,11 @@ func (s *String) Exist() bool {
 // Append appends a value to key
 func (s *String) Append(value []byte) (int, error) {
 	s.Meta.Value = append(s.Meta.Value, value...)
-	if err := s.txn.t.Set(MetaKey(s.txn.db, s.key), s.encode()); err != nil {
+	encoded, err := s.encode()
+	if err != nil {
+		return 0, err
+	}
+	if err := s.txn.t.Set(MetaKey(s.txn.db, s.key), encoded); err != nil {
 		return 0, err
 	}
 	return len(s.Meta.Value), nil
@@ -148,6 +163,24 @@ func (s *String) SetRange(offset int64, value []byte) ([]byte, error) {
 	return val, nil
 }
 
+// Patch applies a list of (offset, bytes) edits to the value and writes the
+// result once, each edit pads with zero-bytes beyond the current length the
+// way SetRange does. It returns the final length
+func (s *String) Patch(offsets []int64, chunks [][]byte) (int64, error) {
+	val := s.Meta.Value
+	for i, offset := range offsets {
+		chunk := chunks[i]
+		if int64(len(val)) < offset+int64(len(chunk)) {
+			val = append(val, make([]byte, offset+int64(len(chunk))-int64(len(val)))...)
+		}
+		copy(val[offset:], chunk)
+	}
+	if err := s.Set(val); err != nil {
+		return 0, err
+	}
+	return int64(len(val)), nil
+}
+
 // Incr increments the integer value by the given amount
 // the old value  must be integer
 func (s *String) Incr(delta int64) (int64, error) {
@@ -262,11 +295,23 @@ func (s *String) BitOpXor() {
 func (s *String) BitOpNot() {
 }
 
-// encode because of the value is small size , value and meta decode together
-func (s *String) encode() []byte {
-	b := EncodeObject(&s.Meta.Object)
-	b = append(b, s.Meta.Value...)
-	return b
+// encode because of the value is small size , value and meta decode together.
+// A seal failure fails the write instead of degrading to plaintext
+func (s *String) encode() ([]byte, error) {
+	obj := s.Meta.Object
+	value := s.Meta.Value
+	if crypto := s.txn.db.kv.crypto; crypto.Enabled(s.txn.db.Namespace) {
+		sealed, err := crypto.Encrypt(s.txn.db.Namespace, value)
+		if err != nil {
+			zap.L().Error("[Crypto] seal string value failed", zap.String("namespace", s.txn.db.Namespace), zap.Error(err))
+			return nil, err
+		}
+		value = sealed
+		obj.Encoding |= objectEncryptedMask
+	}
+	b := EncodeObject(&obj)
+	b = append(b, value...)
+	return b, nil
 }
 
 // decode if obj has been existed , stop parse
@@ -284,12 +329,22 @@ func (s *String) decode(b []byte) error {
 		return ErrTypeMismatch
 	}
 
+	encrypted := obj.Encoding&objectEncryptedMask != 0
+	obj.Encoding &^= objectEncryptedMask
 	if obj.Encoding != ObjectEncodingRaw {
 		return ErrTypeMismatch
 	}
 	s.Meta.Object = *obj
 	if len(b) >= ObjectEncodingLength {
-		s.Meta.Value = b[ObjectEncodingLength:]
+		value := b[ObjectEncodingLength:]
+		if encrypted {
+			plain, err := s.txn.db.kv.crypto.Decrypt(s.txn.db.Namespace, value)
+			if err != nil {
+				return err
+			}
+			value = plain
+		}
+		s.Meta.Value = value
 	}
 	return nil
 }
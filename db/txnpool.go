@@ -0,0 +1,116 @@
+package db
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/distributedio/titan/conf"
+	"go.uber.org/zap"
+)
+
+// ErrTxnPoolTimeout is returned when a request waited longer than
+// queue-timeout for a free transaction slot
+var ErrTxnPoolTimeout = errors.New("txn pool wait queue timeout")
+
+// TxnPool bounds the number of tikv transactions running concurrently,
+// both globally and per namespace. A request exceeding the limit is
+// queued until a slot is released or queue-timeout elapses, which
+// protects tikv from overload collapse during traffic spikes
+type TxnPool struct {
+	conf       *conf.TxnPool
+	global     chan struct{}
+	admin      chan struct{}
+	namespaces sync.Map // namespace -> chan struct{}
+}
+
+// NewTxnPool creates a TxnPool from config, it returns nil if the pool is disabled
+func NewTxnPool(config *conf.TxnPool) *TxnPool {
+	if config.Disable {
+		return nil
+	}
+	p := &TxnPool{conf: config}
+	if config.GlobalLimit > 0 {
+		p.global = make(chan struct{}, config.GlobalLimit)
+	}
+	if config.AdminReserved > 0 {
+		p.admin = make(chan struct{}, config.AdminReserved)
+	}
+	return p
+}
+
+func (p *TxnPool) namespaceSlots(namespace string) chan struct{} {
+	if p.conf.NamespaceLimit <= 0 {
+		return nil
+	}
+	v, ok := p.namespaces.Load(namespace)
+	if !ok {
+		v, _ = p.namespaces.LoadOrStore(namespace, make(chan struct{}, p.conf.NamespaceLimit))
+	}
+	return v.(chan struct{})
+}
+
+// Acquire takes one transaction slot of the namespace and one of the
+// global pool, blocking at most queue-timeout in total. It returns
+// ErrTxnPoolTimeout if either pool stays full for the whole wait.
+// Admin requests run in a reserved lane sized by admin-reserved and only
+// contend among themselves, so an admin command still gets through when
+// the user pools are saturated
+func (p *TxnPool) Acquire(namespace string, admin bool) error {
+	timer := time.NewTimer(p.conf.QueueTimeout)
+	defer timer.Stop()
+
+	if admin {
+		if p.admin == nil {
+			return nil
+		}
+		select {
+		case p.admin <- struct{}{}:
+			return nil
+		case <-timer.C:
+			zap.L().Warn("txn pool admin queue timeout", zap.String("namespace", namespace),
+				zap.Int("limit", p.conf.AdminReserved))
+			return ErrTxnPoolTimeout
+		}
+	}
+
+	slots := p.namespaceSlots(namespace)
+	if slots != nil {
+		select {
+		case slots <- struct{}{}:
+		case <-timer.C:
+			zap.L().Warn("txn pool namespace queue timeout", zap.String("namespace", namespace),
+				zap.Int("limit", p.conf.NamespaceLimit))
+			return ErrTxnPoolTimeout
+		}
+	}
+	if p.global != nil {
+		select {
+		case p.global <- struct{}{}:
+		case <-timer.C:
+			if slots != nil {
+				<-slots
+			}
+			zap.L().Warn("txn pool global queue timeout", zap.String("namespace", namespace),
+				zap.Int("limit", p.conf.GlobalLimit))
+			return ErrTxnPoolTimeout
+		}
+	}
+	return nil
+}
+
+// Release returns the slots taken by a successful Acquire
+func (p *TxnPool) Release(namespace string, admin bool) {
+	if admin {
+		if p.admin != nil {
+			<-p.admin
+		}
+		return
+	}
+	if p.global != nil {
+		<-p.global
+	}
+	if slots := p.namespaceSlots(namespace); slots != nil {
+		<-slots
+	}
+}
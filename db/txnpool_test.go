@@ -0,0 +1,62 @@
+package db
+
+import (
+	"testing"
+	"time"
+
+	"github.com/distributedio/titan/conf"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTxnPoolDisabled(t *testing.T) {
+	pool := NewTxnPool(&conf.TxnPool{Disable: true})
+	assert.Nil(t, pool)
+}
+
+func TestTxnPoolAcquireRelease(t *testing.T) {
+	pool := NewTxnPool(&conf.TxnPool{GlobalLimit: 2, NamespaceLimit: 1, QueueTimeout: 10 * time.Millisecond})
+	assert.NotNil(t, pool)
+
+	assert.NoError(t, pool.Acquire("ns1", false))
+	assert.NoError(t, pool.Acquire("ns2", false))
+
+	// ns1 reached its namespace limit
+	assert.Equal(t, ErrTxnPoolTimeout, pool.Acquire("ns1", false))
+	// ns3 is blocked by the global limit
+	assert.Equal(t, ErrTxnPoolTimeout, pool.Acquire("ns3", false))
+
+	pool.Release("ns1", false)
+	assert.NoError(t, pool.Acquire("ns3", false))
+	pool.Release("ns2", false)
+	pool.Release("ns3", false)
+}
+
+func TestTxnPoolQueueing(t *testing.T) {
+	pool := NewTxnPool(&conf.TxnPool{GlobalLimit: 1, QueueTimeout: time.Second})
+	assert.NoError(t, pool.Acquire("ns", false))
+
+	done := make(chan error)
+	go func() {
+		done <- pool.Acquire("ns", false)
+	}()
+	time.Sleep(10 * time.Millisecond)
+	pool.Release("ns", false)
+	assert.NoError(t, <-done)
+	pool.Release("ns", false)
+}
+
+func TestTxnPoolAdminLane(t *testing.T) {
+	pool := NewTxnPool(&conf.TxnPool{GlobalLimit: 1, AdminReserved: 1, QueueTimeout: 10 * time.Millisecond})
+
+	// saturate the user pool, the admin lane still has a free slot
+	assert.NoError(t, pool.Acquire("ns", false))
+	assert.Equal(t, ErrTxnPoolTimeout, pool.Acquire("ns", false))
+	assert.NoError(t, pool.Acquire("ns", true))
+
+	// the admin lane is bounded as well
+	assert.Equal(t, ErrTxnPoolTimeout, pool.Acquire("ns", true))
+	pool.Release("ns", true)
+	assert.NoError(t, pool.Acquire("ns", true))
+	pool.Release("ns", true)
+	pool.Release("ns", false)
+}
@@ -0,0 +1,60 @@
+package db
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/distributedio/titan/conf"
+	"github.com/pingcap/tidb/kv"
+	"go.uber.org/zap"
+)
+
+// sysWarmupKey is the probe key committed by the startup warm-up, its value
+// is the unix nano timestamp of the last warm-up
+var sysWarmupKey = []byte("$sys:0:WU:health")
+
+// Warmup primes the pd and tikv connections before the listener starts
+// accepting commands. Beginning a transaction resolves the pd members and
+// fetches a tso, a bounded scan per configured namespace loads the region
+// cache of its key range, and committing a probe write under the system
+// namespace proves the whole commit path, so the first client command after
+// a deploy does not pay the cost of the lazy connection setup
+func (rds *RedisStore) Warmup(cfg *conf.Warmup) error {
+	start := time.Now()
+	txn, err := rds.Begin()
+	if err != nil {
+		return err
+	}
+	namespaces := []string{sysNamespace}
+	for _, ns := range strings.Split(cfg.Namespaces, ",") {
+		if ns = strings.TrimSpace(ns); ns != "" {
+			namespaces = append(namespaces, ns)
+		}
+	}
+	for _, ns := range namespaces {
+		prefix := []byte(ns + ":")
+		iter, err := txn.Iter(prefix, kv.Key(prefix).PrefixNext())
+		if err != nil {
+			txn.Rollback()
+			return err
+		}
+		for i := 0; i < cfg.ScanLimit && iter.Valid() && iter.Key().HasPrefix(prefix); i++ {
+			if err := iter.Next(); err != nil {
+				break
+			}
+		}
+		iter.Close()
+	}
+	if err := txn.Set(sysWarmupKey, EncodeInt64(time.Now().UnixNano())); err != nil {
+		txn.Rollback()
+		return err
+	}
+	if err := txn.Commit(context.Background()); err != nil {
+		return err
+	}
+	zap.L().Info("startup warm-up finished",
+		zap.Int("namespaces", len(namespaces)),
+		zap.Duration("cost", time.Since(start)))
+	return nil
+}
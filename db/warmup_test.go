@@ -0,0 +1,22 @@
+package db
+
+import (
+	"testing"
+
+	"github.com/distributedio/titan/conf"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWarmup(t *testing.T) {
+	cfg := conf.MockConf().Tikv.Warmup
+	cfg.Namespaces = "mockdb-ns, other-ns"
+	rds := mockDB.kv
+	assert.NoError(t, rds.Warmup(&cfg))
+
+	txn, err := rds.Begin()
+	assert.NoError(t, err)
+	val, err := txn.Get(sysWarmupKey)
+	assert.NoError(t, err)
+	assert.Len(t, val, 8)
+	assert.NoError(t, txn.Rollback())
+}
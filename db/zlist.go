@@ -19,7 +19,7 @@ func GetZList(txn *Transaction, metaKey []byte, obj *Object, val []byte) (*ZList
 	return l, nil
 }
 
-//NewZList create new list object ,the key is not checked for presence
+// NewZList create new list object ,the key is not checked for presence
 func NewZList(txn *Transaction, key []byte) List {
 	metaKey := MetaKey(txn.db, key)
 	ts := Now()
@@ -49,7 +49,7 @@ type ZList struct {
 	txn        *Transaction
 }
 
-//Exist if zlist is  effective return true ,otherwise return false
+// Exist if zlist is  effective return true ,otherwise return false
 func (l *ZList) Exist() bool {
 	if l.value.V == nil {
 		return false
@@ -88,7 +88,7 @@ func (l *ZList) Unmarshal(obj *Object, b []byte) (err error) {
 // Length return z list length
 func (l *ZList) Length() int64 { return int64(len(l.value.V)) }
 
-//LPush append new elements to the object values
+// LPush append new elements to the object values
 func (l *ZList) LPush(data ...[]byte) (err error) {
 	cv := make([][]byte, len(data), len(data)+len(l.value.V))
 
@@ -146,7 +146,7 @@ func (l *ZList) Insert(pivot, v []byte, before bool) error {
 	return l.zlistCommit()
 }
 
-//Index return the value at index
+// Index return the value at index
 func (l *ZList) Index(n int64) (data []byte, err error) {
 	if n < 0 {
 		n += int64(len(l.value.V))
@@ -6,9 +6,11 @@
 Package zlistproto is a generated protocol buffer package.
 
 It is generated from these files:
+
 	zlist.proto
 
 It has these top-level messages:
+
 	Zlistvalue
 */
 package zlistproto
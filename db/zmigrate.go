@@ -0,0 +1,212 @@
+package db
+
+import (
+	"bytes"
+	"context"
+	"time"
+
+	"github.com/distributedio/titan/conf"
+	"github.com/distributedio/titan/metrics"
+	"github.com/pingcap/tidb/kv"
+	"go.uber.org/zap"
+)
+
+var (
+	sysZSetMigrateLeader = []byte("$sys:0:ZSML:ZSMLeader")
+	sysZSetMigrateCursor = []byte("$sys:0:ZSM:cursor")
+)
+
+const zmigrate_worker = "zmigrate"
+
+// isMetaKey reports whether a raw store key belongs to the meta space
+// of some user db, {ns}:{dbid}:M:{key} with a 3-digit dbid
+func isMetaKey(key []byte) bool {
+	idx := bytes.IndexByte(key, ':')
+	if idx < 0 || len(key) < idx+1+3+3 {
+		return false
+	}
+	rest := key[idx+1:]
+	return rest[3] == ':' && rest[4] == 'M' && rest[5] == ':'
+}
+
+// convertZSet re-encodes every member value and score key of a v1 zset with
+// the v2 score codec and bumps the meta version, all in the caller's txn.
+// It returns false without touching anything if the zset exceeds keyLimit
+func convertZSet(txn *Transaction, mkey []byte, meta []byte, obj *Object, keyLimit int) (bool, error) {
+	dkey := DataKey(txn.db, obj.ID)
+	memberPrefix := zsetMemberKey(dkey, nil)
+	iter, err := txn.t.Iter(memberPrefix, kv.Key(memberPrefix).PrefixNext())
+	if err != nil {
+		return false, err
+	}
+
+	type entry struct {
+		member   []byte
+		oldScore []byte
+		payload  []byte
+	}
+	var entries []entry
+	for ; iter.Valid() && iter.Key().HasPrefix(memberPrefix); err = iter.Next() {
+		if err != nil {
+			iter.Close()
+			return false, err
+		}
+		if len(entries) >= keyLimit {
+			iter.Close()
+			return false, nil
+		}
+		oldScore, payload := splitMemberValue(iter.Value())
+		e := entry{
+			member:   append([]byte{}, iter.Key()[len(memberPrefix):]...),
+			oldScore: append([]byte{}, oldScore...),
+			payload:  append([]byte{}, payload...),
+		}
+		entries = append(entries, e)
+	}
+	iter.Close()
+
+	for _, e := range entries {
+		newScore := EncodeScoreV2(DecodeFloat64(e.oldScore))
+		if err := txn.t.Set(zsetMemberKey(dkey, e.member), zsetMemberValue(newScore, e.payload)); err != nil {
+			return false, err
+		}
+		if err := txn.t.Delete(zsetScoreKey(dkey, e.oldScore, e.member)); err != nil {
+			return false, err
+		}
+		if err := txn.t.Set(zsetScoreKey(dkey, newScore, e.member), NilValue); err != nil {
+			return false, err
+		}
+	}
+
+	newMeta := make([]byte, ObjectEncodingLength+9)
+	copy(newMeta, meta[:ObjectEncodingLength+8])
+	newMeta[ObjectEncodingLength+8] = ZSetScoreV2
+	if err := txn.t.Set(mkey, newMeta); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// doZSetMigrate scans the meta space from the saved cursor looking for zsets
+// still on the v1 score layout and converts up to conf.BatchLimit of them
+func doZSetMigrate(db *DB, conf *conf.ZSetMigrate) error {
+	dbTxn, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	txn := dbTxn.t
+
+	var cursor []byte
+	if val, err := txn.Get(sysZSetMigrateCursor); err == nil {
+		cursor = append(cursor, val...)
+	} else if !IsErrNotFound(err) {
+		txn.Rollback()
+		return err
+	}
+
+	start := time.Now()
+	iter, err := txn.Iter(cursor, nil)
+	metrics.GetMetrics().WorkerSeekCostHistogramVec.WithLabelValues(zmigrate_worker).Observe(time.Since(start).Seconds())
+	if err != nil {
+		txn.Rollback()
+		return err
+	}
+
+	converted, scanned := 0, 0
+	var lastKey []byte
+	for ; iter.Valid() && converted < conf.BatchLimit && scanned < conf.ScanLimit; err = iter.Next() {
+		if err != nil {
+			iter.Close()
+			txn.Rollback()
+			return err
+		}
+		scanned++
+		lastKey = append(lastKey[:0], iter.Key()...)
+
+		if !isMetaKey(iter.Key()) || bytes.HasPrefix(iter.Key(), []byte(sysNamespace)) {
+			continue
+		}
+		meta := iter.Value()
+		if len(meta) != ObjectEncodingLength+8 {
+			// v2 zset metas carry a trailing version byte
+			continue
+		}
+		obj, err := DecodeObject(meta)
+		if err != nil || obj.Type != ObjectZSet {
+			continue
+		}
+		metaCopy := append([]byte{}, meta...)
+		done, err := convertZSet(dbTxn, append([]byte{}, iter.Key()...), metaCopy, obj, conf.KeyLimit)
+		if err != nil {
+			iter.Close()
+			txn.Rollback()
+			return err
+		}
+		if !done {
+			zap.L().Warn("[ZSM] zset too large to convert in one txn, skipped",
+				zap.ByteString("meta-key", iter.Key()), zap.Int("key-limit", conf.KeyLimit))
+			continue
+		}
+		converted++
+	}
+	exhausted := !iter.Valid()
+	iter.Close()
+
+	if exhausted || lastKey == nil {
+		if len(cursor) > 0 {
+			if err := txn.Delete(sysZSetMigrateCursor); err != nil {
+				txn.Rollback()
+				return err
+			}
+		}
+	} else if err := txn.Set(sysZSetMigrateCursor, kv.Key(lastKey).Next()); err != nil {
+		txn.Rollback()
+		return err
+	}
+
+	start = time.Now()
+	if err := txn.Commit(context.Background()); err != nil {
+		txn.Rollback()
+		return err
+	}
+	metrics.GetMetrics().WorkerCommitCostHistogramVec.WithLabelValues(zmigrate_worker).Observe(time.Since(start).Seconds())
+	if converted > 0 {
+		zap.L().Info("[ZSM] converted zsets to score layout v2", zap.Int("converted", converted))
+	}
+	return nil
+}
+
+// StartZSetMigrate converts zsets from the v1 score key layout to v2 in the
+// background. Only the leader node does the work, and readers keep decoding
+// unconverted zsets with the v1 codec via the version byte in the meta
+func StartZSetMigrate(db *DB, conf *conf.ZSetMigrate) {
+	ticker := time.NewTicker(conf.Interval)
+	defer ticker.Stop()
+	id := UUID()
+	for range ticker.C {
+		if conf.Disable {
+			continue
+		}
+
+		start := time.Now()
+		isLeader, err := isLeader(db, sysZSetMigrateLeader, id, conf.LeaderLifeTime)
+		if err != nil {
+			zap.L().Error("[ZSM] check migrate leader failed",
+				zap.ByteString("leader", sysZSetMigrateLeader),
+				zap.ByteString("uuid", id),
+				zap.Error(err))
+			continue
+		}
+		if !isLeader {
+			continue
+		}
+		if err := doZSetMigrate(db, conf); err != nil {
+			zap.L().Error("[ZSM] do migrate failed",
+				zap.ByteString("leader", sysZSetMigrateLeader),
+				zap.ByteString("uuid", id),
+				zap.Error(err))
+			continue
+		}
+		metrics.GetMetrics().WorkerRoundCostHistogramVec.WithLabelValues(zmigrate_worker).Observe(time.Since(start).Seconds())
+	}
+}
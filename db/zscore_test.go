@@ -0,0 +1,100 @@
+package db
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"math"
+	"testing"
+
+	"github.com/distributedio/titan/conf"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestScoreV2Ordering(t *testing.T) {
+	scores := []float64{
+		math.Inf(-1), -math.MaxFloat64, -1e100, -2.5, -2, -1, -0.5,
+		-math.SmallestNonzeroFloat64, 0, math.SmallestNonzeroFloat64,
+		0.5, 1, 2, 2.5, 1e100, math.MaxFloat64, math.Inf(1),
+	}
+	for i := 1; i < len(scores); i++ {
+		prev := EncodeScoreV2(scores[i-1])
+		cur := EncodeScoreV2(scores[i])
+		assert.True(t, bytes.Compare(prev, cur) < 0, "order broken between %v and %v", scores[i-1], scores[i])
+	}
+}
+
+func TestScoreV2Roundtrip(t *testing.T) {
+	scores := []float64{-1e100, -3.14, -1, 0, 0.5, 1, 42, 1e100}
+	for _, score := range scores {
+		assert.Equal(t, score, DecodeScoreV2(EncodeScoreV2(score)))
+	}
+	// -0.0 is normalized to 0.0
+	assert.Equal(t, EncodeScoreV2(0.0), EncodeScoreV2(math.Copysign(0, -1)))
+}
+
+func TestFormatScore(t *testing.T) {
+	cases := map[float64]string{
+		0:                   "0",
+		3:                   "3",
+		-1.5:                "-1.5",
+		0.1:                 "0.10000000000000001",
+		3.14:                "3.1400000000000001",
+		1e100:               "1e+100",
+		math.Inf(1):         "inf",
+		math.Inf(-1):        "-inf",
+		1.0 / 3.0:           "0.33333333333333331",
+		9007199254740993:    "9007199254740992",
+		0.30000000000000004: "0.30000000000000004",
+	}
+	for score, want := range cases {
+		assert.Equal(t, want, FormatScore(score), "score %v", score)
+	}
+}
+
+func TestZSetMigrateV1ToV2(t *testing.T) {
+	key := []byte("zsm-v1")
+	members := [][]byte{[]byte("m1"), []byte("m2")}
+	scores := []float64{-1.5, 2}
+
+	// write a zset with the legacy v1 layout: 8-byte meta suffix and
+	// EncodeFloat64 scores
+	txn := getTxn(t)
+	zset := newZSet(txn, key)
+	dkey := DataKey(txn.db, zset.meta.ID)
+	for i := range members {
+		bytesScore := EncodeFloat64(scores[i])
+		assert.NoError(t, txn.t.Set(zsetMemberKey(dkey, members[i]), bytesScore))
+		assert.NoError(t, txn.t.Set(zsetScoreKey(dkey, bytesScore, members[i]), NilValue))
+	}
+	meta := EncodeObject(&zset.meta.Object)
+	m := make([]byte, 8)
+	binary.BigEndian.PutUint64(m, uint64(len(members)))
+	assert.NoError(t, txn.t.Set(MetaKey(txn.db, key), append(meta, m...)))
+	assert.NoError(t, txn.Commit(context.TODO()))
+
+	txn = getTxn(t)
+	loaded, err := GetZSet(txn, key)
+	assert.NoError(t, err)
+	assert.Equal(t, ZSetScoreV1, loaded.meta.ScoreVersion)
+	txn.Commit(context.TODO())
+
+	mockZSMConf := conf.MockConf().Tikv.ZSM
+	mockZSMConf.BatchLimit = 16
+	mockZSMConf.ScanLimit = 10000
+	mockZSMConf.KeyLimit = 4096
+	assert.NoError(t, doZSetMigrate(mockDB, &mockZSMConf))
+
+	txn = getTxn(t)
+	loaded, err = GetZSet(txn, key)
+	assert.NoError(t, err)
+	assert.Equal(t, ZSetScoreV2, loaded.meta.ScoreVersion)
+	assert.Equal(t, int64(2), loaded.ZCard())
+	score, err := loaded.ZScore([]byte("m1"))
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("-1.5"), score)
+	items, err := loaded.ZAnyOrderRange(0, -1, false, true)
+	assert.NoError(t, err)
+	assert.Equal(t, [][]byte{[]byte("m1"), []byte("m2")}, items)
+	txn.Commit(context.TODO())
+}
@@ -1,17 +1,50 @@
 package db
 
 import (
+	"bytes"
 	"encoding/binary"
+	"math"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/distributedio/titan/db/keyspace"
 	"github.com/pingcap/tidb/kv"
 	"go.uber.org/zap"
-	"strconv"
-	"time"
 )
 
+// zsetRangeBuffers holds the prefix and bound keys a range scan builds
+// before seeking, they only live until the iterator closes so the scans
+// recycle them through zsetRangePool instead of allocating per call
+type zsetRangeBuffers struct {
+	prefix, start, stop []byte
+}
+
+var zsetRangePool = sync.Pool{
+	New: func() interface{} { return new(zsetRangeBuffers) },
+}
+
+// appendZSetScorePrefix rebuilds the score key prefix of the zset into
+// buf, the pooled equivalent of DataKey plus ZSetScorePrefix
+func appendZSetScorePrefix(buf []byte, db *DB, objID []byte) []byte {
+	buf = keyspace.AppendDataKey(buf, storageNamespace(db.Namespace), db.ID.Bytes(), objID)
+	return keyspace.AppendZSetScoreTag(buf)
+}
+
+// appendZSetMemberPrefix rebuilds the member key prefix of the zset into
+// buf, the pooled equivalent of DataKey plus ZSetMemberPrefix
+func appendZSetMemberPrefix(buf []byte, db *DB, objID []byte) []byte {
+	buf = keyspace.AppendDataKey(buf, storageNamespace(db.Namespace), db.ID.Bytes(), objID)
+	return keyspace.AppendZSetMemberTag(buf)
+}
+
 // ZSetMeta is the meta data of the sorted set
 type ZSetMeta struct {
 	Object
 	Len int64
+	// ScoreVersion is the encoding version of the score keys, metas
+	// written before versioning are decoded as ZSetScoreV1
+	ScoreVersion byte
 }
 
 // ZSet implements the the sorted set
@@ -28,6 +61,26 @@ type MemberScore struct {
 
 const byteScoreLen = 8
 
+// zsetMemberValue builds the value of a member key, an optional payload
+// trails the fixed-width score bytes
+func zsetMemberValue(bytesScore []byte, payload []byte) []byte {
+	if len(payload) == 0 {
+		return bytesScore
+	}
+	val := make([]byte, 0, len(bytesScore)+len(payload))
+	val = append(val, bytesScore...)
+	return append(val, payload...)
+}
+
+// splitMemberValue splits a member key value into its score bytes and the
+// trailing payload, values written before payloads carry score bytes only
+func splitMemberValue(val []byte) (score []byte, payload []byte) {
+	if len(val) <= byteScoreLen {
+		return val, nil
+	}
+	return val[:byteScoreLen], val[byteScoreLen:]
+}
+
 func newZSet(txn *Transaction, key []byte) *ZSet {
 	now := Now()
 	return &ZSet{
@@ -42,7 +95,8 @@ func newZSet(txn *Transaction, key []byte) *ZSet {
 				Type:      ObjectZSet,
 				Encoding:  ObjectEncodingHT,
 			},
-			Len: 0,
+			Len:          0,
+			ScoreVersion: ZSetScoreV2,
 		},
 	}
 }
@@ -62,29 +116,78 @@ func GetZSet(txn *Transaction, key []byte) (*ZSet, error) {
 		return nil, err
 	}
 
-	obj, err := DecodeObject(meta)
+	objType, expireAt, err := peekMeta(meta)
 	if err != nil {
 		return nil, err
 	}
-	if IsExpired(obj, Now()) {
+	if expireAt != 0 && expireAt <= Now() {
+		if obj, err := DecodeObject(meta); err == nil {
+			tryLazyExpire(txn.db, key, obj)
+		}
 		return zset, nil
 	}
-	if obj.Type != ObjectZSet {
+	if objType != ObjectZSet {
 		return nil, ErrTypeMismatch
 	}
+	obj, err := DecodeObject(meta)
+	if err != nil {
+		return nil, err
+	}
 
 	m := meta[ObjectEncodingLength:]
-	if len(m) != 8 {
+	if len(m) != 8 && len(m) != 9 {
 		return nil, ErrInvalidLength
 	}
 	zset.meta.Object = *obj
 	zset.meta.Len = int64(binary.BigEndian.Uint64(m[:8]))
+	if len(m) == 9 {
+		zset.meta.ScoreVersion = m[8]
+	} else {
+		zset.meta.ScoreVersion = ZSetScoreV1
+	}
 
 	return zset, nil
 }
 
+// ZAddCondition constrains when ZAddWith may replace an existing score
+type ZAddCondition byte
+
+const (
+	// ZAddAny always applies the new score
+	ZAddAny ZAddCondition = iota
+	// ZAddGT only updates a member when the new score is greater than the current one
+	ZAddGT
+	// ZAddLT only updates a member when the new score is less than the current one
+	ZAddLT
+)
+
 func (zset *ZSet) ZAdd(members [][]byte, scores []float64) (int64, error) {
+	added, _, err := zset.ZAddWith(members, scores, ZAddAny)
+	return added, err
+}
+
+// ZAddWith is ZAdd constrained by cond, the GT/LT comparison reuses the old
+// scores already fetched by MGet so no extra read is issued. Besides the
+// added count it returns per member whether the score was applied, a member
+// skipped by cond or carrying its score already reports false, which is
+// what CH accounting at the command layer needs
+func (zset *ZSet) ZAddWith(members [][]byte, scores []float64, cond ZAddCondition) (int64, []bool, error) {
+	return zset.zadd(members, scores, cond, nil)
+}
+
+// ZAddWithPayloads is ZAdd with a payload stored alongside each member, the
+// payload rides in the member key value behind the score bytes. An empty
+// payload drops a previously stored one
+func (zset *ZSet) ZAddWithPayloads(members [][]byte, scores []float64, payloads [][]byte) (int64, error) {
+	added, _, err := zset.zadd(members, scores, ZAddAny, payloads)
+	return added, err
+}
+
+// zadd applies the members with their scores, a nil payloads keeps whatever
+// payload a member already carries
+func (zset *ZSet) zadd(members [][]byte, scores []float64, cond ZAddCondition, payloads [][]byte) (int64, []bool, error) {
 	added := int64(0)
+	applied := make([]bool, len(members))
 
 	oldValues := make([][]byte, len(members))
 	var err error
@@ -93,7 +196,7 @@ func (zset *ZSet) ZAdd(members [][]byte, scores []float64) (int64, error) {
 		oldValues, err = zset.MGet(members)
 		zap.L().Debug("zset mget", zap.Int64("cost(us)", time.Since(start).Nanoseconds()/1000))
 		if err != nil {
-			return 0, err
+			return 0, applied, err
 		}
 	}
 
@@ -103,27 +206,52 @@ func (zset *ZSet) ZAdd(members [][]byte, scores []float64) (int64, error) {
 	costDel, costSetMem, costSetScore := int64(0), int64(0), int64(0)
 	for i := range members {
 		found = false
+		var payload []byte
+		if payloads != nil {
+			payload = payloads[i]
+		}
 		if oldValues[i] != nil {
 			found = true
-			oldScore := DecodeFloat64(oldValues[i])
+			oldBytesScore, oldPayload := splitMemberValue(oldValues[i])
+			if payloads == nil {
+				payload = oldPayload
+			}
+			oldScore := decodeScore(zset.meta.ScoreVersion, oldBytesScore)
 			if scores[i] == oldScore {
+				if bytes.Equal(payload, oldPayload) {
+					continue
+				}
+				// payload only change, the score index is untouched
+				start = time.Now()
+				err = zset.txn.t.Set(zsetMemberKey(dkey, members[i]), zsetMemberValue(oldBytesScore, payload))
+				costSetMem += time.Since(start).Nanoseconds()
+				if err != nil {
+					return added, applied, err
+				}
+				applied[i] = true
+				continue
+			}
+			if cond == ZAddGT && scores[i] < oldScore {
 				continue
 			}
-			oldScoreKey := zsetScoreKey(dkey, oldValues[i], members[i])
+			if cond == ZAddLT && scores[i] > oldScore {
+				continue
+			}
+			oldScoreKey := zsetScoreKey(dkey, oldBytesScore, members[i])
 			start = time.Now()
 			err = zset.txn.t.Delete(oldScoreKey)
 			costDel += time.Since(start).Nanoseconds()
 			if err != nil {
-				return added, err
+				return added, applied, err
 			}
 		}
 		memberKey := zsetMemberKey(dkey, members[i])
-		bytesScore := EncodeFloat64(scores[i])
+		bytesScore := encodeScore(zset.meta.ScoreVersion, scores[i])
 		start = time.Now()
-		err = zset.txn.t.Set(memberKey, bytesScore)
+		err = zset.txn.t.Set(memberKey, zsetMemberValue(bytesScore, payload))
 		costSetMem += time.Since(start).Nanoseconds()
 		if err != nil {
-			return added, err
+			return added, applied, err
 		}
 
 		scoreKey := zsetScoreKey(dkey, bytesScore, members[i])
@@ -131,9 +259,10 @@ func (zset *ZSet) ZAdd(members [][]byte, scores []float64) (int64, error) {
 		err = zset.txn.t.Set(scoreKey, NilValue)
 		costSetScore += time.Since(start).Nanoseconds()
 		if err != nil {
-			return added, err
+			return added, applied, err
 		}
 
+		applied[i] = true
 		if !found {
 			added += 1
 		}
@@ -145,11 +274,61 @@ func (zset *ZSet) ZAdd(members [][]byte, scores []float64) (int64, error) {
 	zset.meta.Len += added
 	start = time.Now()
 	if err = zset.updateMeta(); err != nil {
-		return 0, err
+		return 0, applied, err
 	}
 	zap.L().Debug("zset update meta key", zap.Int64("cost(us)", time.Since(start).Nanoseconds()/1000))
 
-	return added, nil
+	return added, applied, nil
+}
+
+// ZIncrBy adds delta to the score of member, a missing member is added
+// with delta as its score and a payload survives the increment. The whole
+// read-modify-write runs in the caller's transaction, so concurrent
+// increments conflict and retry instead of losing updates
+func (zset *ZSet) ZIncrBy(member []byte, delta float64) (float64, error) {
+	dkey := DataKey(zset.txn.db, zset.meta.ID)
+	memberKey := zsetMemberKey(dkey, member)
+
+	newScore := delta
+	var payload []byte
+	found := false
+	if zset.meta.Len > 0 {
+		val, err := zset.txn.t.Get(memberKey)
+		if err != nil && !IsErrNotFound(err) {
+			return 0, err
+		}
+		if err == nil {
+			found = true
+			oldBytesScore, oldPayload := splitMemberValue(val)
+			payload = oldPayload
+			oldScore := decodeScore(zset.meta.ScoreVersion, oldBytesScore)
+			newScore = oldScore + delta
+			if math.IsNaN(newScore) {
+				return 0, ErrScoreNaN
+			}
+			if newScore == oldScore {
+				return newScore, nil
+			}
+			if err := zset.txn.t.Delete(zsetScoreKey(dkey, oldBytesScore, member)); err != nil {
+				return 0, err
+			}
+		}
+	}
+
+	bytesScore := encodeScore(zset.meta.ScoreVersion, newScore)
+	if err := zset.txn.t.Set(memberKey, zsetMemberValue(bytesScore, payload)); err != nil {
+		return 0, err
+	}
+	if err := zset.txn.t.Set(zsetScoreKey(dkey, bytesScore, member), NilValue); err != nil {
+		return 0, err
+	}
+	if !found {
+		zset.meta.Len++
+	}
+	if err := zset.updateMeta(); err != nil {
+		return 0, err
+	}
+	return newScore, nil
 }
 
 func (zset *ZSet) MGet(members [][]byte) ([][]byte, error) {
@@ -162,6 +341,42 @@ func (zset *ZSet) MGet(members [][]byte) ([][]byte, error) {
 	return BatchGetValues(zset.txn, ikeys)
 }
 
+// ZMScore returns the scores of the members in the given order through one
+// batch get, a member that does not exist yields a nil entry
+func (zset *ZSet) ZMScore(members [][]byte) ([][]byte, error) {
+	values, err := zset.MGet(members)
+	if err != nil {
+		return nil, err
+	}
+	scores := make([][]byte, len(values))
+	for i, val := range values {
+		if val == nil {
+			continue
+		}
+		bytesScore, _ := splitMemberValue(val)
+		scores[i] = []byte(FormatScore(decodeScore(zset.meta.ScoreVersion, bytesScore)))
+	}
+	return scores, nil
+}
+
+// ZPayloads returns the payloads of the members in the given order through
+// one batch get, a member without a payload or not in the set yields a nil
+// entry
+func (zset *ZSet) ZPayloads(members [][]byte) ([][]byte, error) {
+	values, err := zset.MGet(members)
+	if err != nil {
+		return nil, err
+	}
+	payloads := make([][]byte, len(values))
+	for i, val := range values {
+		if val == nil {
+			continue
+		}
+		_, payloads[i] = splitMemberValue(val)
+	}
+	return payloads, nil
+}
+
 func (zset *ZSet) updateMeta() error {
 	meta := zset.encodeMeta(zset.meta)
 	return zset.txn.t.Set(MetaKey(zset.txn.db, zset.key), meta)
@@ -169,8 +384,9 @@ func (zset *ZSet) updateMeta() error {
 
 func (zset *ZSet) encodeMeta(meta ZSetMeta) []byte {
 	b := EncodeObject(&meta.Object)
-	m := make([]byte, 8)
+	m := make([]byte, 9)
 	binary.BigEndian.PutUint64(m[:8], uint64(meta.Len))
+	m[8] = meta.ScoreVersion
 	return append(b, m...)
 }
 
@@ -198,60 +414,62 @@ func (zset *ZSet) ZAnyOrderRange(start int64, stop int64, withScore bool, positi
 	if start > stop || start >= zset.meta.Len {
 		return [][]byte{}, nil
 	}
-	dkey := DataKey(zset.txn.db, zset.meta.ID)
-	scorePrefix := ZSetScorePrefix(dkey)
-	var iter Iterator
-	var err error
-	startTime := time.Now()
-	if positiveOrder {
-		iter, err = zset.txn.t.Iter(scorePrefix, nil)
-	} else {
+	bufs := zsetRangePool.Get().(*zsetRangeBuffers)
+	defer zsetRangePool.Put(bufs)
+	bufs.prefix = appendZSetScorePrefix(bufs.prefix[:0], zset.txn.db, zset.meta.ID)
+	scorePrefix := kv.Key(bufs.prefix)
+	if !positiveOrder {
 		//tikv sdk didn't implement SeekReverse(), so we just use seek() to implement zrevrange now
 		//because tikv sdk scan 256 keys in next(), for the zset which have <=256 members,
 		// its performance should be similar with seekReverse, for >256 zset, it should be bad
 		//iter, err = zset.txn.t.SeekReverse(scorePrefix)
-		iter, err = zset.txn.t.Iter(scorePrefix, nil)
 		tmp := start
 		start = zset.meta.Len - 1 - stop
 		stop = zset.meta.Len - 1 - tmp
 	}
+	startTime := time.Now()
+	// the upper bound stops the batched scanner from fetching keys past
+	// this zset once the range reaches its tail
+	iter, err := zset.txn.t.Iter(scorePrefix, scorePrefix.PrefixNext())
 	zap.L().Debug("zset seek", zap.Int64("cost(us)", time.Since(startTime).Nanoseconds()/1000))
 
 	if err != nil {
 		return nil, err
 	}
+	defer iter.Close()
+
+	// skip the start offset with a bare Next loop, nothing of the
+	// discarded entries is looked at
+	i := int64(0)
+	startTime = time.Now()
+	for ; err == nil && i < start && iter.Valid(); i++ {
+		err = iter.Next()
+	}
 
 	var items [][]byte
-	cost := int64(0)
-	for i := int64(0); err == nil && i <= stop && iter.Valid() && iter.Key().HasPrefix(scorePrefix); i++ {
-		if i >= start {
-			if len(iter.Key()) <= len(scorePrefix)+byteScoreLen+len(":") {
-				zap.L().Error("score&member's length isn't enough to be decoded",
-					zap.ByteString("meta key", zset.key), zap.ByteString("data key", iter.Key()))
-				startTime = time.Now()
-				err = iter.Next()
-				cost += time.Since(startTime).Nanoseconds()
-				continue
-			}
+	for ; err == nil && i <= stop && iter.Valid() && iter.Key().HasPrefix(scorePrefix); i++ {
+		if len(iter.Key()) <= len(scorePrefix)+byteScoreLen+len(":") {
+			zap.L().Error("score&member's length isn't enough to be decoded",
+				zap.ByteString("meta key", zset.key), zap.ByteString("data key", iter.Key()))
+			err = iter.Next()
+			continue
+		}
 
-			scoreAndMember := iter.Key()[len(scorePrefix):]
-			score := scoreAndMember[0:byteScoreLen]
-			member := scoreAndMember[byteScoreLen+len(":"):]
-			items = append(items, member)
-			if withScore {
-				val := []byte(strconv.FormatFloat(DecodeFloat64(score), 'f', -1, 64))
-				items = append(items, val)
-				if !positiveOrder {
-					items[len(items)-1], items[len(items)-2] = items[len(items)-2], items[len(items)-1]
-				}
+		scoreAndMember := iter.Key()[len(scorePrefix):]
+		score := scoreAndMember[0:byteScoreLen]
+		member := scoreAndMember[byteScoreLen+len(":"):]
+		items = append(items, member)
+		if withScore {
+			val := []byte(FormatScore(decodeScore(zset.meta.ScoreVersion, score)))
+			items = append(items, val)
+			if !positiveOrder {
+				items[len(items)-1], items[len(items)-2] = items[len(items)-2], items[len(items)-1]
 			}
 		}
 
-		startTime = time.Now()
 		err = iter.Next()
-		cost += time.Since(startTime).Nanoseconds()
 	}
-	zap.L().Debug("zset all next", zap.Int64("cost(us)", cost/1000))
+	zap.L().Debug("zset all next", zap.Int64("cost(us)", time.Since(startTime).Nanoseconds()/1000))
 
 	if !positiveOrder {
 		for i, j := 0, len(items)-1; i < j; i, j = i+1, j-1 {
@@ -280,30 +498,61 @@ func (zset *ZSet) ZAnyOrderRangeByScore(startScore float64, startInclude bool,
 		return nil, nil
 	}
 
-	dkey := DataKey(zset.txn.db, zset.meta.ID)
-	scorePrefix := ZSetScorePrefix(dkey)
+	if positiveOrder {
+		return zset.zRangeByScoreAsc(startScore, startInclude, stopScore, stopInclude, withScore, offset, count)
+	}
+
+	// the tikv sdk implements no reverse iterator over buffered writes, so
+	// walk the score range forward like ZRevRange does and page from the
+	// high end afterwards
+	items, err := zset.zRangeByScoreAsc(stopScore, stopInclude, startScore, startInclude, withScore, 0, -1)
+	if err != nil {
+		return nil, err
+	}
+	width := int64(1)
+	if withScore {
+		width = 2
+	}
+	// reverse group wise so a member keeps its trailing score
+	for i, j := int64(0), int64(len(items))-width; i < j; i, j = i+width, j-width {
+		for k := int64(0); k < width; k++ {
+			items[i+k], items[j+k] = items[j+k], items[i+k]
+		}
+	}
+	if offset*width >= int64(len(items)) {
+		return nil, nil
+	}
+	items = items[offset*width:]
+	if count > 0 && count*width < int64(len(items)) {
+		items = items[:count*width]
+	}
+	return items, nil
+}
 
-	startPrefix := make([]byte, len(scorePrefix)+byteScoreLen)
-	copy(startPrefix, scorePrefix)
-	byteStartScore := EncodeFloat64(startScore)
-	copy(startPrefix[len(scorePrefix):], byteStartScore)
+// zRangeByScoreAsc walks the score keys from minScore up to maxScore, a
+// negative count means no limit
+func (zset *ZSet) zRangeByScoreAsc(minScore float64, minInclude bool,
+	maxScore float64, maxInclude bool,
+	withScore bool,
+	offset int64, count int64) ([][]byte, error) {
+	bufs := zsetRangePool.Get().(*zsetRangeBuffers)
+	defer zsetRangePool.Put(bufs)
+	bufs.prefix = appendZSetScorePrefix(bufs.prefix[:0], zset.txn.db, zset.meta.ID)
+	scorePrefix := kv.Key(bufs.prefix)
 
-	stopPrefix := make([]byte, len(scorePrefix)+byteScoreLen)
-	copy(stopPrefix, scorePrefix)
-	byteStopScore := EncodeFloat64(stopScore)
-	copy(stopPrefix[len(scorePrefix):], byteStopScore)
+	bufs.start = append(bufs.start[:0], scorePrefix...)
+	bufs.start = append(bufs.start, encodeScore(zset.meta.ScoreVersion, minScore)...)
+	startPrefix := kv.Key(bufs.start)
 
-	var iter Iterator
-	var err error
-	if positiveOrder {
-		upperBoundKey := kv.Key(stopPrefix).PrefixNext()
-		iter, err = zset.txn.t.Iter(startPrefix, upperBoundKey)
-	} else {
-		iter, err = zset.txn.t.IterReverse(startPrefix)
-	}
+	bufs.stop = append(bufs.stop[:0], scorePrefix...)
+	bufs.stop = append(bufs.stop, encodeScore(zset.meta.ScoreVersion, maxScore)...)
+	stopPrefix := kv.Key(bufs.stop)
+
+	iter, err := zset.txn.t.Iter(startPrefix, stopPrefix.PrefixNext())
 	if err != nil {
 		return nil, err
 	}
+	defer iter.Close()
 
 	var items [][]byte
 	countN := int64(0)
@@ -317,7 +566,7 @@ func (zset *ZSet) ZAnyOrderRangeByScore(startScore float64, startInclude bool,
 		}
 
 		curPrefix := key[:len(scorePrefix)+byteScoreLen]
-		if !startInclude && !startComFinished {
+		if !minInclude && !startComFinished {
 			if curPrefix.Cmp(startPrefix) == 0 {
 				offset += 1
 				continue
@@ -326,10 +575,7 @@ func (zset *ZSet) ZAnyOrderRangeByScore(startScore float64, startInclude bool,
 			}
 		}
 
-		comWithStop := curPrefix.Cmp(stopPrefix)
-		if (!stopInclude && comWithStop == 0) ||
-			(positiveOrder && comWithStop > 0) ||
-			(!positiveOrder && comWithStop < 0) {
+		if comWithStop := curPrefix.Cmp(stopPrefix); (!maxInclude && comWithStop == 0) || comWithStop > 0 {
 			break
 		}
 
@@ -346,7 +592,7 @@ func (zset *ZSet) ZAnyOrderRangeByScore(startScore float64, startInclude bool,
 		member := scoreAndMember[byteScoreLen+len(":"):]
 		items = append(items, member)
 		if withScore {
-			val := []byte(strconv.FormatFloat(DecodeFloat64(score), 'f', -1, 64))
+			val := []byte(FormatScore(decodeScore(zset.meta.ScoreVersion, score)))
 			items = append(items, val)
 		}
 	}
@@ -354,6 +600,169 @@ func (zset *ZSet) ZAnyOrderRangeByScore(startScore float64, startInclude bool,
 	return items, nil
 }
 
+// ZRangeByLex walks the member keys between min and max in member byte
+// order, a nil bound leaves that end of the range open. The member keys
+// share one prefix and sort by member, so the iterator covers exactly the
+// requested range. The tikv sdk implements no reverse iterator, so a
+// reverse order scans the range forward and pages from the high end
+// afterwards, a negative count means no limit
+func (zset *ZSet) ZRangeByLex(min []byte, minInclude bool,
+	max []byte, maxInclude bool,
+	offset int64, count int64,
+	positiveOrder bool) ([][]byte, error) {
+	if offset < 0 || count == 0 {
+		return nil, nil
+	}
+
+	if positiveOrder {
+		return zset.zRangeByLexAsc(min, minInclude, max, maxInclude, offset, count)
+	}
+
+	members, err := zset.zRangeByLexAsc(min, minInclude, max, maxInclude, 0, -1)
+	if err != nil {
+		return nil, err
+	}
+	for i, j := 0, len(members)-1; i < j; i, j = i+1, j-1 {
+		members[i], members[j] = members[j], members[i]
+	}
+	if offset >= int64(len(members)) {
+		return nil, nil
+	}
+	members = members[offset:]
+	if count > 0 && count < int64(len(members)) {
+		members = members[:count]
+	}
+	return members, nil
+}
+
+// zRangeByLexAsc walks the member keys from min up to max, a nil bound
+// leaves that end open and a negative count means no limit
+func (zset *ZSet) zRangeByLexAsc(min []byte, minInclude bool,
+	max []byte, maxInclude bool,
+	offset int64, count int64) ([][]byte, error) {
+	var members [][]byte
+	i, countN := int64(0), int64(0)
+	err := zset.zWalkByLex(min, minInclude, max, maxInclude, func(member []byte) bool {
+		if i++; i <= offset {
+			return true
+		}
+		if countN++; count > 0 && countN > count {
+			return false
+		}
+		members = append(members, member)
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	return members, nil
+}
+
+// zWalkByLex walks the member keys from min up to max in member byte
+// order, a nil bound leaves that end open. fn receives each member and
+// reports whether the walk goes on
+func (zset *ZSet) zWalkByLex(min []byte, minInclude bool,
+	max []byte, maxInclude bool,
+	fn func(member []byte) bool) error {
+	bufs := zsetRangePool.Get().(*zsetRangeBuffers)
+	defer zsetRangePool.Put(bufs)
+	bufs.prefix = appendZSetMemberPrefix(bufs.prefix[:0], zset.txn.db, zset.meta.ID)
+	memberPrefix := kv.Key(bufs.prefix)
+
+	bufs.start = append(bufs.start[:0], memberPrefix...)
+	bufs.start = append(bufs.start, min...)
+	startKey := kv.Key(bufs.start)
+	if min != nil && !minInclude {
+		// stepping the start key forward skips exactly the member equal
+		// to the bound
+		startKey = startKey.Next()
+	}
+
+	upperBound := memberPrefix.PrefixNext()
+	if max != nil {
+		bufs.stop = append(bufs.stop[:0], memberPrefix...)
+		bufs.stop = append(bufs.stop, max...)
+		upperBound = kv.Key(bufs.stop)
+		if maxInclude {
+			upperBound = upperBound.Next()
+		}
+	}
+
+	iter, err := zset.txn.t.Iter(startKey, upperBound)
+	if err != nil {
+		return err
+	}
+	defer iter.Close()
+
+	for ; err == nil && iter.Valid() && iter.Key().HasPrefix(memberPrefix); err = iter.Next() {
+		if !fn(iter.Key()[len(memberPrefix):]) {
+			break
+		}
+	}
+	return nil
+}
+
+// ZLexCount counts the members between min and max in member byte order
+// without materializing them, a nil bound leaves that end open
+func (zset *ZSet) ZLexCount(min []byte, minInclude bool, max []byte, maxInclude bool) (int64, error) {
+	count := int64(0)
+	err := zset.zWalkByLex(min, minInclude, max, maxInclude, func([]byte) bool {
+		count++
+		return true
+	})
+	if err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+// ZCount counts the members whose score lies between min and max, the
+// score index is scanned between the two encoded bounds so only keys in
+// range are read
+func (zset *ZSet) ZCount(min float64, minInclude bool, max float64, maxInclude bool) (int64, error) {
+	if min > max || (min == max && (!minInclude || !maxInclude)) {
+		return 0, nil
+	}
+	bufs := zsetRangePool.Get().(*zsetRangeBuffers)
+	defer zsetRangePool.Put(bufs)
+	bufs.prefix = appendZSetScorePrefix(bufs.prefix[:0], zset.txn.db, zset.meta.ID)
+	scorePrefix := kv.Key(bufs.prefix)
+
+	bufs.start = append(bufs.start[:0], scorePrefix...)
+	bufs.start = append(bufs.start, encodeScore(zset.meta.ScoreVersion, min)...)
+	startPrefix := kv.Key(bufs.start)
+
+	bufs.stop = append(bufs.stop[:0], scorePrefix...)
+	bufs.stop = append(bufs.stop, encodeScore(zset.meta.ScoreVersion, max)...)
+	stopPrefix := kv.Key(bufs.stop)
+
+	iter, err := zset.txn.t.Iter(startPrefix, stopPrefix.PrefixNext())
+	if err != nil {
+		return 0, err
+	}
+	defer iter.Close()
+
+	count := int64(0)
+	for ; err == nil && iter.Valid() && iter.Key().HasPrefix(scorePrefix); err = iter.Next() {
+		key := iter.Key()
+		if len(key) <= len(scorePrefix)+byteScoreLen+len(":") {
+			zap.L().Error("score&member's length isn't enough to be decoded",
+				zap.ByteString("meta key", zset.key), zap.ByteString("data key", key))
+			continue
+		}
+
+		curPrefix := key[:len(scorePrefix)+byteScoreLen]
+		if !minInclude && curPrefix.Cmp(startPrefix) == 0 {
+			continue
+		}
+		if cmp := curPrefix.Cmp(stopPrefix); (!maxInclude && cmp == 0) || cmp > 0 {
+			break
+		}
+		count++
+	}
+	return count, nil
+}
+
 func (zset *ZSet) ZRem(members [][]byte) (int64, error) {
 	deleted := int64(0)
 
@@ -364,6 +773,24 @@ func (zset *ZSet) ZRem(members [][]byte) (int64, error) {
 		return 0, err
 	}
 
+	present := make(map[string]struct{}, len(members))
+	for i := range members {
+		if scores[i] != nil {
+			present[string(members[i])] = struct{}{}
+		}
+	}
+	// removing every member of a large zset would delete 2*Len keys in
+	// the caller's transaction, drop the meta and leave the data keys
+	// to the background gc instead
+	if n := int64(len(present)); n > 0 && n >= zset.meta.Len && shouldLazyDelete(zset.meta.Len) {
+		if err := zset.txn.Destory(&zset.meta.Object, zset.key); err != nil {
+			return 0, err
+		}
+		lazyDeleted()
+		zset.meta.Len = 0
+		return n, nil
+	}
+
 	dkey := DataKey(zset.txn.db, zset.meta.ID)
 	costDelMem, costDelScore := int64(0), int64(0)
 	for i := range members {
@@ -371,7 +798,8 @@ func (zset *ZSet) ZRem(members [][]byte) (int64, error) {
 			continue
 		}
 
-		scoreKey := zsetScoreKey(dkey, scores[i], members[i])
+		bytesScore, _ := splitMemberValue(scores[i])
+		scoreKey := zsetScoreKey(dkey, bytesScore, members[i])
 		start = time.Now()
 		err = zset.txn.t.Delete(scoreKey)
 		costDelScore += time.Since(start).Nanoseconds()
@@ -387,6 +815,10 @@ func (zset *ZSet) ZRem(members [][]byte) (int64, error) {
 			return deleted, err
 		}
 
+		if err := clearZSetMemberTTL(zset.txn, dkey, members[i]); err != nil {
+			return deleted, err
+		}
+
 		deleted += 1
 	}
 	zap.L().Debug("zrem cost(us)", zap.Int64("del memberKey", costDelMem/1000),
@@ -418,47 +850,193 @@ func (zset *ZSet) ZRem(members [][]byte) (int64, error) {
 	return deleted, err
 }
 func (zset *ZSet) ZCard() int64 {
+	if cfg := sampleReadRepair(); cfg != nil && zset.Exist() {
+		if count, ok := zset.verifyLen(cfg.MaxScan); ok {
+			return count
+		}
+	}
 	return zset.meta.Len
 }
 
+// verifyLen counts the member index up to maxScan keys and rewrites a
+// drifted meta Len in place, it reports false when the zset holds more
+// data keys than the scan bound or the count fails
+func (zset *ZSet) verifyLen(maxScan int) (int64, bool) {
+	dkey := DataKey(zset.txn.db, zset.meta.ID)
+	prefix := zsetMemberPrefix(dkey)
+	iter, err := zset.txn.t.Iter(prefix, kv.Key(prefix).PrefixNext())
+	if err != nil {
+		return 0, false
+	}
+	defer iter.Close()
+	count := int64(0)
+	for ; iter.Valid() && iter.Key().HasPrefix(prefix); err = iter.Next() {
+		if err != nil {
+			return 0, false
+		}
+		if count++; count > int64(maxScan) {
+			return 0, false
+		}
+	}
+	if count == zset.meta.Len {
+		return count, true
+	}
+	recordMetaLenDrift(ObjectZSet, zset.key, zset.meta.Len, count)
+	zset.meta.Len = count
+	if err := zset.updateMeta(); err != nil {
+		return count, false
+	}
+	return count, true
+}
+
 func (zset *ZSet) ZScore(member []byte) ([]byte, error) {
+	score, _, err := zset.ZScoreWithPayload(member)
+	return score, err
+}
+
+// ZScoreWithPayload returns the formatted score of a member together with
+// its payload, both are nil when the member does not exist
+func (zset *ZSet) ZScoreWithPayload(member []byte) ([]byte, []byte, error) {
 	dkey := DataKey(zset.txn.db, zset.meta.ID)
 	memberKey := zsetMemberKey(dkey, member)
-	bytesScore, err := zset.txn.t.Get(memberKey)
+	val, err := zset.txn.t.Get(memberKey)
 	if err != nil {
 		if IsErrNotFound(err) {
-			return nil, nil
+			return nil, nil, nil
 		}
-		return nil, err
+		return nil, nil, err
 	}
 
-	fscore := DecodeFloat64(bytesScore)
-	sscore := strconv.FormatFloat(fscore, 'f', -1, 64)
-	return []byte(sscore), nil
+	bytesScore, payload := splitMemberValue(val)
+	fscore := decodeScore(zset.meta.ScoreVersion, bytesScore)
+	sscore := FormatScore(fscore)
+	return []byte(sscore), payload, nil
+}
+
+// ZRandMember returns random members of the set, with their scores appended
+// when withScore is set. A non-negative count returns at most count distinct
+// members, a negative count returns exactly -count members and may repeat
+// some. Sampling seeks into the member key range at random offsets, which is
+// slightly biased by the gaps between member keys but never loads a large
+// set to pick a few members
+func (zset *ZSet) ZRandMember(count int64, withScore bool) ([][]byte, error) {
+	distinct := count >= 0
+	n := count
+	if n < 0 {
+		n = -n
+	}
+	if n == 0 {
+		return [][]byte{}, nil
+	}
+	if distinct && n > zset.meta.Len {
+		n = zset.meta.Len
+	}
+
+	dkey := DataKey(zset.txn.db, zset.meta.ID)
+	prefix := zsetMemberPrefix(dkey)
+
+	// sampling most of the set through random seeks degenerates, collect
+	// the members in one pass and shuffle locally instead
+	if distinct && n*2 >= zset.meta.Len {
+		members, scores, err := zset.scanMembers(prefix)
+		if err != nil {
+			return nil, err
+		}
+		out := make([][]byte, 0, n*2)
+		for _, i := range rand.Perm(len(members))[:n] {
+			out = append(out, members[i])
+			if withScore {
+				out = append(out, scores[i])
+			}
+		}
+		return out, nil
+	}
+
+	out := make([][]byte, 0, n*2)
+	seen := make(map[string]struct{})
+	var picked int64
+	for attempts := 10*n + 16; picked < n && attempts > 0; attempts-- {
+		member, score, err := zset.seekRandomMember(prefix)
+		if err != nil {
+			return nil, err
+		}
+		if member == nil {
+			break
+		}
+		if distinct {
+			if _, ok := seen[string(member)]; ok {
+				continue
+			}
+			seen[string(member)] = struct{}{}
+		}
+		picked++
+		out = append(out, member)
+		if withScore {
+			out = append(out, []byte(FormatScore(decodeScore(zset.meta.ScoreVersion, score))))
+		}
+	}
+	return out, nil
+}
+
+// seekRandomMember seeks to a random offset of the member key range and
+// returns the member found there, wrapping to the start of the range when
+// the seek lands behind the last member
+func (zset *ZSet) seekRandomMember(prefix []byte) ([]byte, []byte, error) {
+	seek := make([]byte, len(prefix)+8)
+	copy(seek, prefix)
+	rand.Read(seek[len(prefix):])
+	iter, err := zset.txn.t.Iter(seek, kv.Key(prefix).PrefixNext())
+	if err != nil {
+		return nil, nil, err
+	}
+	if !iter.Valid() || !iter.Key().HasPrefix(prefix) {
+		iter.Close()
+		if iter, err = zset.txn.t.Iter(prefix, kv.Key(prefix).PrefixNext()); err != nil {
+			return nil, nil, err
+		}
+	}
+	defer iter.Close()
+	if !iter.Valid() || !iter.Key().HasPrefix(prefix) {
+		return nil, nil, nil
+	}
+	member := append([]byte{}, iter.Key()[len(prefix):]...)
+	bytesScore, _ := splitMemberValue(iter.Value())
+	score := append([]byte{}, bytesScore...)
+	return member, score, nil
+}
+
+// scanMembers collects every member of the set with its formatted score
+func (zset *ZSet) scanMembers(prefix []byte) ([][]byte, [][]byte, error) {
+	iter, err := zset.txn.t.Iter(prefix, kv.Key(prefix).PrefixNext())
+	if err != nil {
+		return nil, nil, err
+	}
+	defer iter.Close()
+	var members, scores [][]byte
+	for ; iter.Valid() && iter.Key().HasPrefix(prefix); err = iter.Next() {
+		if err != nil {
+			return nil, nil, err
+		}
+		members = append(members, append([]byte{}, iter.Key()[len(prefix):]...))
+		bytesScore, _ := splitMemberValue(iter.Value())
+		scores = append(scores, []byte(FormatScore(decodeScore(zset.meta.ScoreVersion, bytesScore))))
+	}
+	return members, scores, nil
 }
 
 func zsetMemberKey(dkey []byte, member []byte) []byte {
-	var memberKey []byte
-	memberKey = append(memberKey, dkey...)
-	memberKey = append(memberKey, ':', 'M', ':')
-	memberKey = append(memberKey, member...)
-	return memberKey
+	return keyspace.ZSetMemberKey(dkey, member)
+}
+
+func zsetMemberPrefix(dkey []byte) []byte {
+	return keyspace.ZSetMemberPrefix(dkey)
 }
 
 // ZSetScorePrefix builds a score key prefix from a redis key
 func ZSetScorePrefix(dkey []byte) []byte {
-	var sPrefix []byte
-	sPrefix = append(sPrefix, dkey...)
-	sPrefix = append(sPrefix, ':', 'S', ':')
-	return sPrefix
+	return keyspace.ZSetScorePrefix(dkey)
 }
 
 func zsetScoreKey(dkey []byte, score []byte, member []byte) []byte {
-	var scoreKey []byte
-	scoreKey = append(scoreKey, dkey...)
-	scoreKey = append(scoreKey, ':', 'S', ':')
-	scoreKey = append(scoreKey, score...)
-	scoreKey = append(scoreKey, ':')
-	scoreKey = append(scoreKey, member...)
-	return scoreKey
+	return keyspace.ZSetScoreKey(dkey, score, member)
 }
@@ -0,0 +1,443 @@
+package db
+
+import (
+	"context"
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestZAddWith(t *testing.T) {
+	db := MockDB()
+	key := []byte("TestZAddWith")
+	members := [][]byte{[]byte("m1"), []byte("m2")}
+
+	txn, err := db.Begin()
+	assert.NoError(t, err)
+	zset, err := GetZSet(txn, key)
+	assert.NoError(t, err)
+	added, applied, err := zset.ZAddWith(members, []float64{1, 2}, ZAddAny)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), added)
+	assert.Equal(t, []bool{true, true}, applied)
+	assert.NoError(t, txn.Commit(context.TODO()))
+
+	// GT only raises scores, m2 keeps its higher score
+	txn, err = db.Begin()
+	assert.NoError(t, err)
+	zset, err = GetZSet(txn, key)
+	assert.NoError(t, err)
+	added, applied, err = zset.ZAddWith(members, []float64{5, 1}, ZAddGT)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), added)
+	assert.Equal(t, []bool{true, false}, applied)
+	score, err := zset.ZScore([]byte("m1"))
+	assert.NoError(t, err)
+	assert.Equal(t, "5", string(score))
+	score, err = zset.ZScore([]byte("m2"))
+	assert.NoError(t, err)
+	assert.Equal(t, "2", string(score))
+	assert.NoError(t, txn.Commit(context.TODO()))
+
+	// LT only lowers scores, a missing member is still added
+	txn, err = db.Begin()
+	assert.NoError(t, err)
+	zset, err = GetZSet(txn, key)
+	assert.NoError(t, err)
+	added, applied, err = zset.ZAddWith([][]byte{[]byte("m1"), []byte("m3")}, []float64{9, 7}, ZAddLT)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), added)
+	assert.Equal(t, []bool{false, true}, applied)
+	score, err = zset.ZScore([]byte("m1"))
+	assert.NoError(t, err)
+	assert.Equal(t, "5", string(score))
+	assert.NoError(t, txn.Commit(context.TODO()))
+}
+
+func TestZMScore(t *testing.T) {
+	db := MockDB()
+	key := []byte("TestZMScore")
+
+	txn, err := db.Begin()
+	assert.NoError(t, err)
+	zset, err := GetZSet(txn, key)
+	assert.NoError(t, err)
+	_, err = zset.ZAdd([][]byte{[]byte("m1"), []byte("m2")}, []float64{1.5, 2})
+	assert.NoError(t, err)
+	assert.NoError(t, txn.Commit(context.TODO()))
+
+	txn, err = db.Begin()
+	assert.NoError(t, err)
+	zset, err = GetZSet(txn, key)
+	assert.NoError(t, err)
+	scores, err := zset.ZMScore([][]byte{[]byte("m2"), []byte("missing"), []byte("m1")})
+	assert.NoError(t, err)
+	assert.Len(t, scores, 3)
+	assert.Equal(t, "2", string(scores[0]))
+	assert.Nil(t, scores[1])
+	assert.Equal(t, "1.5", string(scores[2]))
+	assert.NoError(t, txn.Commit(context.TODO()))
+}
+
+func TestZRandMember(t *testing.T) {
+	db := MockDB()
+	key := []byte("TestZRandMember")
+	members := [][]byte{[]byte("m1"), []byte("m2"), []byte("m3"), []byte("m4")}
+
+	txn, err := db.Begin()
+	assert.NoError(t, err)
+	zset, err := GetZSet(txn, key)
+	assert.NoError(t, err)
+	_, err = zset.ZAdd(members, []float64{1, 2, 3, 4})
+	assert.NoError(t, err)
+	assert.NoError(t, txn.Commit(context.TODO()))
+
+	txn, err = db.Begin()
+	assert.NoError(t, err)
+	zset, err = GetZSet(txn, key)
+	assert.NoError(t, err)
+
+	valid := map[string]bool{"m1": true, "m2": true, "m3": true, "m4": true}
+
+	// distinct members, capped at the cardinality
+	items, err := zset.ZRandMember(10, false)
+	assert.NoError(t, err)
+	assert.Len(t, items, 4)
+	seen := make(map[string]bool)
+	for _, item := range items {
+		assert.True(t, valid[string(item)])
+		assert.False(t, seen[string(item)])
+		seen[string(item)] = true
+	}
+
+	// a small distinct sample uses the random seek path
+	items, err = zset.ZRandMember(1, true)
+	assert.NoError(t, err)
+	assert.Len(t, items, 2)
+	assert.True(t, valid[string(items[0])])
+
+	// a negative count may repeat members but returns exactly -count
+	items, err = zset.ZRandMember(-9, false)
+	assert.NoError(t, err)
+	assert.Len(t, items, 9)
+	for _, item := range items {
+		assert.True(t, valid[string(item)])
+	}
+	assert.NoError(t, txn.Commit(context.TODO()))
+}
+
+func TestZSetPayload(t *testing.T) {
+	db := MockDB()
+	key := []byte("TestZSetPayload")
+	members := [][]byte{[]byte("m1"), []byte("m2")}
+
+	txn, err := db.Begin()
+	assert.NoError(t, err)
+	zset, err := GetZSet(txn, key)
+	assert.NoError(t, err)
+	_, err = zset.ZAddWithPayloads(members, []float64{1, 2}, [][]byte{[]byte("p1"), nil})
+	assert.NoError(t, err)
+	assert.NoError(t, txn.Commit(context.TODO()))
+
+	txn, err = db.Begin()
+	assert.NoError(t, err)
+	zset, err = GetZSet(txn, key)
+	assert.NoError(t, err)
+	score, payload, err := zset.ZScoreWithPayload([]byte("m1"))
+	assert.NoError(t, err)
+	assert.Equal(t, "1", string(score))
+	assert.Equal(t, "p1", string(payload))
+	score, payload, err = zset.ZScoreWithPayload([]byte("m2"))
+	assert.NoError(t, err)
+	assert.Equal(t, "2", string(score))
+	assert.Nil(t, payload)
+
+	// a plain ZAdd score change keeps the stored payload
+	_, err = zset.ZAdd([][]byte{[]byte("m1")}, []float64{3})
+	assert.NoError(t, err)
+	assert.NoError(t, txn.Commit(context.TODO()))
+
+	txn, err = db.Begin()
+	assert.NoError(t, err)
+	zset, err = GetZSet(txn, key)
+	assert.NoError(t, err)
+	score, payload, err = zset.ZScoreWithPayload([]byte("m1"))
+	assert.NoError(t, err)
+	assert.Equal(t, "3", string(score))
+	assert.Equal(t, "p1", string(payload))
+
+	// the score index follows the rewritten member value
+	items, err := zset.ZAnyOrderRange(0, -1, true, true)
+	assert.NoError(t, err)
+	assert.Equal(t, [][]byte{[]byte("m2"), []byte("2"), []byte("m1"), []byte("3")}, items)
+
+	// a payload only change leaves the score alone
+	_, err = zset.ZAddWithPayloads([][]byte{[]byte("m2")}, []float64{2}, [][]byte{[]byte("p2")})
+	assert.NoError(t, err)
+	payloads, err := zset.ZPayloads([][]byte{[]byte("m1"), []byte("m2"), []byte("missing")})
+	assert.NoError(t, err)
+	assert.Equal(t, "p1", string(payloads[0]))
+	assert.Equal(t, "p2", string(payloads[1]))
+	assert.Nil(t, payloads[2])
+	assert.NoError(t, txn.Commit(context.TODO()))
+
+	// ZRem of a member carrying a payload removes its score key too
+	txn, err = db.Begin()
+	assert.NoError(t, err)
+	zset, err = GetZSet(txn, key)
+	assert.NoError(t, err)
+	deleted, err := zset.ZRem([][]byte{[]byte("m1")})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), deleted)
+	items, err = zset.ZAnyOrderRange(0, -1, false, true)
+	assert.NoError(t, err)
+	assert.Equal(t, [][]byte{[]byte("m2")}, items)
+	assert.NoError(t, txn.Commit(context.TODO()))
+}
+
+func TestZAnyOrderRangeByScore(t *testing.T) {
+	db := MockDB()
+	key := []byte("TestZAnyOrderRangeByScore")
+	members := [][]byte{[]byte("m1"), []byte("m2"), []byte("m3"), []byte("m4")}
+
+	txn, err := db.Begin()
+	assert.NoError(t, err)
+	zset, err := GetZSet(txn, key)
+	assert.NoError(t, err)
+	_, err = zset.ZAdd(members, []float64{1, 2, 3, 4})
+	assert.NoError(t, err)
+	assert.NoError(t, txn.Commit(context.TODO()))
+
+	txn, err = db.Begin()
+	assert.NoError(t, err)
+	zset, err = GetZSet(txn, key)
+	assert.NoError(t, err)
+
+	// inclusive bounds, ascending
+	items, err := zset.ZAnyOrderRangeByScore(1, true, 3, true, false, 0, -1, true)
+	assert.NoError(t, err)
+	assert.Equal(t, [][]byte{[]byte("m1"), []byte("m2"), []byte("m3")}, items)
+
+	// exclusive start bound
+	items, err = zset.ZAnyOrderRangeByScore(1, false, 3, true, false, 0, -1, true)
+	assert.NoError(t, err)
+	assert.Equal(t, [][]byte{[]byte("m2"), []byte("m3")}, items)
+
+	// offset and count paginate the range
+	items, err = zset.ZAnyOrderRangeByScore(1, true, 4, true, false, 1, 2, true)
+	assert.NoError(t, err)
+	assert.Equal(t, [][]byte{[]byte("m2"), []byte("m3")}, items)
+
+	// descending order walks from the high bound down
+	items, err = zset.ZAnyOrderRangeByScore(4, true, 2, true, false, 0, -1, false)
+	assert.NoError(t, err)
+	assert.Equal(t, [][]byte{[]byte("m4"), []byte("m3"), []byte("m2")}, items)
+
+	// descending with an exclusive high bound
+	items, err = zset.ZAnyOrderRangeByScore(4, false, 1, true, false, 0, -1, false)
+	assert.NoError(t, err)
+	assert.Equal(t, [][]byte{[]byte("m3"), []byte("m2"), []byte("m1")}, items)
+
+	assert.NoError(t, txn.Commit(context.TODO()))
+}
+
+func TestZAnyOrderRangeOffset(t *testing.T) {
+	db := MockDB()
+	key := []byte("TestZAnyOrderRangeOffset")
+	var members [][]byte
+	var scores []float64
+	for i := 0; i < 20; i++ {
+		members = append(members, []byte{'m', byte('a' + i)})
+		scores = append(scores, float64(i))
+	}
+
+	txn, err := db.Begin()
+	assert.NoError(t, err)
+	zset, err := GetZSet(txn, key)
+	assert.NoError(t, err)
+	_, err = zset.ZAdd(members, scores)
+	assert.NoError(t, err)
+	assert.NoError(t, txn.Commit(context.TODO()))
+
+	txn, err = db.Begin()
+	assert.NoError(t, err)
+	zset, err = GetZSet(txn, key)
+	assert.NoError(t, err)
+
+	// a window deep into the range
+	items, err := zset.ZAnyOrderRange(15, 17, false, true)
+	assert.NoError(t, err)
+	assert.Equal(t, [][]byte{members[15], members[16], members[17]}, items)
+
+	// the reverse top of the leaderboard
+	items, err = zset.ZAnyOrderRange(0, 2, true, false)
+	assert.NoError(t, err)
+	assert.Equal(t, [][]byte{members[19], []byte("19"), members[18], []byte("18"), members[17], []byte("17")}, items)
+
+	// reverse window deep into the range
+	items, err = zset.ZAnyOrderRange(16, 18, false, false)
+	assert.NoError(t, err)
+	assert.Equal(t, [][]byte{members[3], members[2], members[1]}, items)
+
+	assert.NoError(t, txn.Commit(context.TODO()))
+}
+
+func TestZRangeByLex(t *testing.T) {
+	db := MockDB()
+	key := []byte("TestZRangeByLex")
+	members := [][]byte{[]byte("a"), []byte("b"), []byte("c"), []byte("d"), []byte("e")}
+
+	txn, err := db.Begin()
+	assert.NoError(t, err)
+	zset, err := GetZSet(txn, key)
+	assert.NoError(t, err)
+	_, err = zset.ZAdd(members, []float64{0, 0, 0, 0, 0})
+	assert.NoError(t, err)
+	assert.NoError(t, txn.Commit(context.TODO()))
+
+	txn, err = db.Begin()
+	assert.NoError(t, err)
+	zset, err = GetZSet(txn, key)
+	assert.NoError(t, err)
+
+	// both ends open cover the whole set
+	items, err := zset.ZRangeByLex(nil, true, nil, true, 0, -1, true)
+	assert.NoError(t, err)
+	assert.Equal(t, members, items)
+
+	// inclusive bounds
+	items, err = zset.ZRangeByLex([]byte("b"), true, []byte("d"), true, 0, -1, true)
+	assert.NoError(t, err)
+	assert.Equal(t, [][]byte{[]byte("b"), []byte("c"), []byte("d")}, items)
+
+	// exclusive bounds
+	items, err = zset.ZRangeByLex([]byte("b"), false, []byte("d"), false, 0, -1, true)
+	assert.NoError(t, err)
+	assert.Equal(t, [][]byte{[]byte("c")}, items)
+
+	// offset and count paginate the range
+	items, err = zset.ZRangeByLex(nil, true, nil, true, 1, 2, true)
+	assert.NoError(t, err)
+	assert.Equal(t, [][]byte{[]byte("b"), []byte("c")}, items)
+
+	// reverse order pages from the high end
+	items, err = zset.ZRangeByLex(nil, true, []byte("e"), false, 1, 2, false)
+	assert.NoError(t, err)
+	assert.Equal(t, [][]byte{[]byte("c"), []byte("b")}, items)
+
+	assert.NoError(t, txn.Commit(context.TODO()))
+}
+
+func TestZCount(t *testing.T) {
+	db := MockDB()
+	key := []byte("TestZCount")
+	members := [][]byte{[]byte("m1"), []byte("m2"), []byte("m3"), []byte("m4")}
+
+	txn, err := db.Begin()
+	assert.NoError(t, err)
+	zset, err := GetZSet(txn, key)
+	assert.NoError(t, err)
+	_, err = zset.ZAdd(members, []float64{1, 2, 3, 4})
+	assert.NoError(t, err)
+	assert.NoError(t, txn.Commit(context.TODO()))
+
+	txn, err = db.Begin()
+	assert.NoError(t, err)
+	zset, err = GetZSet(txn, key)
+	assert.NoError(t, err)
+
+	count, err := zset.ZCount(math.Inf(-1), true, math.Inf(1), true)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(4), count)
+
+	count, err = zset.ZCount(2, true, 3, true)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), count)
+
+	// exclusive bounds drop the members carrying them
+	count, err = zset.ZCount(1, false, 4, false)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), count)
+
+	count, err = zset.ZCount(5, true, 9, true)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), count)
+
+	assert.NoError(t, txn.Commit(context.TODO()))
+}
+
+func TestZLexCount(t *testing.T) {
+	db := MockDB()
+	key := []byte("TestZLexCount")
+	members := [][]byte{[]byte("a"), []byte("b"), []byte("c"), []byte("d")}
+
+	txn, err := db.Begin()
+	assert.NoError(t, err)
+	zset, err := GetZSet(txn, key)
+	assert.NoError(t, err)
+	_, err = zset.ZAdd(members, []float64{0, 0, 0, 0})
+	assert.NoError(t, err)
+	assert.NoError(t, txn.Commit(context.TODO()))
+
+	txn, err = db.Begin()
+	assert.NoError(t, err)
+	zset, err = GetZSet(txn, key)
+	assert.NoError(t, err)
+
+	count, err := zset.ZLexCount(nil, true, nil, true)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(4), count)
+
+	count, err = zset.ZLexCount([]byte("b"), true, []byte("d"), true)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(3), count)
+
+	// exclusive bounds drop the members carrying them
+	count, err = zset.ZLexCount([]byte("b"), false, []byte("d"), false)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), count)
+
+	count, err = zset.ZLexCount([]byte("x"), true, nil, true)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), count)
+
+	assert.NoError(t, txn.Commit(context.TODO()))
+}
+
+func TestZIncrBy(t *testing.T) {
+	db := MockDB()
+	key := []byte("TestZIncrBy")
+
+	txn, err := db.Begin()
+	assert.NoError(t, err)
+	zset, err := GetZSet(txn, key)
+	assert.NoError(t, err)
+
+	// a missing member is added with delta as its score
+	score, err := zset.ZIncrBy([]byte("m1"), 2.5)
+	assert.NoError(t, err)
+	assert.Equal(t, 2.5, score)
+	assert.NoError(t, txn.Commit(context.TODO()))
+
+	txn, err = db.Begin()
+	assert.NoError(t, err)
+	zset, err = GetZSet(txn, key)
+	assert.NoError(t, err)
+	score, err = zset.ZIncrBy([]byte("m1"), -1.5)
+	assert.NoError(t, err)
+	assert.Equal(t, float64(1), score)
+	assert.Equal(t, int64(1), zset.ZCard())
+
+	// the score index follows the increment
+	items, err := zset.ZAnyOrderRange(0, -1, true, true)
+	assert.NoError(t, err)
+	assert.Equal(t, [][]byte{[]byte("m1"), []byte("1")}, items)
+
+	// inf + -inf has no meaningful result
+	_, err = zset.ZIncrBy([]byte("m2"), math.Inf(1))
+	assert.NoError(t, err)
+	_, err = zset.ZIncrBy([]byte("m2"), math.Inf(-1))
+	assert.Equal(t, ErrScoreNaN, err)
+	assert.NoError(t, txn.Commit(context.TODO()))
+}
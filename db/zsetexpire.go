@@ -0,0 +1,263 @@
+package db
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"time"
+
+	"github.com/distributedio/titan/conf"
+	"github.com/distributedio/titan/db/keyspace"
+	"github.com/distributedio/titan/metrics"
+	"github.com/pingcap/tidb/kv"
+	"go.uber.org/zap"
+)
+
+var sysMemberExpireLeader = []byte("$sys:0:MEL:MELeader")
+
+const memberExpire_worker = "member-expire"
+
+// ZExpireMember sets when member expires, at is a unix timestamp in
+// nanoseconds, the member is removed by the member expire worker once it is
+// due. at <= 0 drops a previously set ttl and the member stays. It returns
+// false when the member does not exist
+func (zset *ZSet) ZExpireMember(member []byte, at int64) (bool, error) {
+	if !zset.Exist() {
+		return false, nil
+	}
+	dkey := DataKey(zset.txn.db, zset.meta.ID)
+	memberKey := zsetMemberKey(dkey, member)
+	if _, err := zset.txn.t.Get(memberKey); err != nil {
+		if IsErrNotFound(err) {
+			return false, nil
+		}
+		return false, err
+	}
+
+	ekey := zsetMemberExpireKey(dkey, member)
+	oldTs, err := zset.txn.t.Get(ekey)
+	if err != nil && !IsErrNotFound(err) {
+		return false, err
+	}
+	// a reset drops the old index entry so at most one entry owns the member
+	if oldTs != nil {
+		if err := zset.txn.t.Delete(memberExpireKey(oldTs, memberKey)); err != nil {
+			return false, err
+		}
+	}
+
+	if at <= 0 {
+		if oldTs == nil {
+			return true, nil
+		}
+		return true, zset.txn.t.Delete(ekey)
+	}
+
+	ts := EncodeInt64(at)
+	if err := zset.txn.t.Set(ekey, ts); err != nil {
+		return false, err
+	}
+	// the index entry carries the meta key so the worker can fix Len
+	return true, zset.txn.t.Set(memberExpireKey(ts, memberKey), MetaKey(zset.txn.db, zset.key))
+}
+
+func zsetMemberExpireKey(dkey []byte, member []byte) []byte {
+	return keyspace.ZSetMemberExpireKey(dkey, member)
+}
+
+func memberExpireKey(ts []byte, memberKey []byte) []byte {
+	return keyspace.MemberExpireKey(ts, memberKey)
+}
+
+// clearZSetMemberTTL drops the ttl record and expire index entry of a
+// removed member. A stale index entry would pass the ttl guard of the
+// worker and silently expire a member re-added under the same name
+func clearZSetMemberTTL(txn *Transaction, dkey, member []byte) error {
+	ekey := zsetMemberExpireKey(dkey, member)
+	ts, err := txn.t.Get(ekey)
+	if err != nil {
+		if IsErrNotFound(err) {
+			return nil
+		}
+		return err
+	}
+	if err := txn.t.Delete(memberExpireKey(ts, zsetMemberKey(dkey, member))); err != nil {
+		return err
+	}
+	return txn.t.Delete(ekey)
+}
+
+// expireZSetMember removes the member and score key of a due member and
+// keeps the zset length consistent. Stale index entries, left behind when
+// the ttl was reset, the member removed or the whole key recreated, are
+// skipped, the caller deletes the entry either way
+func expireZSetMember(txn *Transaction, mkey, memberKey, ts []byte) error {
+	dkey, member, ok := keyspace.SplitZSetMemberKey(memberKey)
+	if !ok {
+		zap.L().Error("[MemberExpire] unparsable member key", zap.ByteString("key", memberKey))
+		return nil
+	}
+
+	ekey := zsetMemberExpireKey(dkey, member)
+	cur, err := txn.t.Get(ekey)
+	if err != nil {
+		if IsErrNotFound(err) {
+			return nil
+		}
+		return err
+	}
+	if !bytes.Equal(cur, ts) {
+		return nil
+	}
+	if err := txn.t.Delete(ekey); err != nil {
+		return err
+	}
+
+	val, err := txn.t.Get(memberKey)
+	if err != nil {
+		if IsErrNotFound(err) {
+			return nil
+		}
+		return err
+	}
+	score, _ := splitMemberValue(val)
+	if err := txn.t.Delete(zsetScoreKey(dkey, score, member)); err != nil {
+		return err
+	}
+	if err := txn.t.Delete(memberKey); err != nil {
+		return err
+	}
+
+	meta, err := txn.t.Get(mkey)
+	if err != nil {
+		if IsErrNotFound(err) {
+			return nil
+		}
+		return err
+	}
+	obj, err := DecodeObject(meta)
+	if err != nil {
+		zap.L().Error("[MemberExpire] undecodable meta", zap.ByteString("key", mkey), zap.Error(err))
+		return nil
+	}
+	// the key may have been deleted and recreated with another object
+	if obj.Type != ObjectZSet || !bytes.Equal(obj.ID, dkey[len(dkey)-len(obj.ID):]) {
+		return nil
+	}
+	m := meta[ObjectEncodingLength:]
+	if len(m) != 8 && len(m) != 9 {
+		return ErrInvalidLength
+	}
+	zmeta := ZSetMeta{Object: *obj, ScoreVersion: ZSetScoreV1}
+	zmeta.Len = int64(binary.BigEndian.Uint64(m[:8])) - 1
+	if len(m) == 9 {
+		zmeta.ScoreVersion = m[8]
+	}
+
+	if zmeta.Len <= 0 {
+		if err := txn.t.Delete(mkey); err != nil {
+			return err
+		}
+		if obj.ExpireAt > 0 {
+			return unExpireAt(txn.t, mkey, obj.ExpireAt)
+		}
+		return nil
+	}
+	return txn.t.Set(mkey, (&ZSet{}).encodeMeta(zmeta))
+}
+
+// doMemberExpire removes up to batchLimit due members in one transaction
+func doMemberExpire(db *DB, batchLimit int) error {
+	dbTxn, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	txn := dbTxn.t
+
+	prefix := keyspace.MemberExpirePrefix()
+	endPrefix := append(keyspace.MemberExpirePrefix(), EncodeInt64(Now())...)
+	start := time.Now()
+	iter, err := txn.Iter(prefix, kv.Key(endPrefix).PrefixNext())
+	metrics.GetMetrics().WorkerSeekCostHistogramVec.WithLabelValues(memberExpire_worker).Observe(time.Since(start).Seconds())
+	if err != nil {
+		txn.Rollback()
+		return err
+	}
+
+	expired := 0
+	for ; iter.Valid() && iter.Key().HasPrefix(prefix) && expired < batchLimit; err = iter.Next() {
+		if err != nil {
+			iter.Close()
+			txn.Rollback()
+			return err
+		}
+		rawKey := iter.Key()
+		ts := rawKey[len(prefix) : len(prefix)+8]
+		memberKey := rawKey[len(prefix)+8+1:]
+		if err := expireZSetMember(dbTxn, iter.Value(), memberKey, ts); err != nil {
+			iter.Close()
+			txn.Rollback()
+			return err
+		}
+		if err := txn.Delete(rawKey); err != nil {
+			iter.Close()
+			txn.Rollback()
+			return err
+		}
+		expired++
+	}
+	iter.Close()
+
+	start = time.Now()
+	if err := txn.Commit(context.Background()); err != nil {
+		txn.Rollback()
+		return err
+	}
+	metrics.GetMetrics().WorkerCommitCostHistogramVec.WithLabelValues(memberExpire_worker).Observe(time.Since(start).Seconds())
+	metrics.GetMetrics().ExpireKeysTotal.WithLabelValues("member").Add(float64(expired))
+	if logEnv := zap.L().Check(zap.DebugLevel, "[MemberExpire] round done"); logEnv != nil {
+		logEnv.Write(zap.Int("expired", expired))
+	}
+	return nil
+}
+
+// StartZSetMemberExpire runs the zset member expire worker, removing due
+// members from the member expire index. Like gc and expire, only the leader
+// node does the work
+func StartZSetMemberExpire(db *DB, conf *conf.MemberExpire) {
+	ticker := time.NewTicker(conf.Interval)
+	defer ticker.Stop()
+	id := UUID()
+	for range ticker.C {
+		if conf.Disable {
+			continue
+		}
+
+		start := time.Now()
+		isLeader, err := isLeader(db, sysMemberExpireLeader, id, conf.LeaderLifeTime)
+		if err != nil {
+			zap.L().Error("[MemberExpire] check member expire leader failed",
+				zap.ByteString("leader", sysMemberExpireLeader),
+				zap.ByteString("uuid", id),
+				zap.Duration("leader-life-time", conf.LeaderLifeTime),
+				zap.Error(err))
+			continue
+		}
+		if !isLeader {
+			if logEnv := zap.L().Check(zap.DebugLevel, "[MemberExpire] current is not member expire leader"); logEnv != nil {
+				logEnv.Write(zap.ByteString("leader", sysMemberExpireLeader),
+					zap.ByteString("uuid", id),
+					zap.Duration("leader-life-time", conf.LeaderLifeTime))
+			}
+			continue
+		}
+		if err := doMemberExpire(db, conf.BatchLimit); err != nil {
+			zap.L().Error("[MemberExpire] do member expire failed",
+				zap.ByteString("leader", sysMemberExpireLeader),
+				zap.ByteString("uuid", id),
+				zap.Error(err))
+			continue
+		}
+		metrics.GetMetrics().WorkerRoundCostHistogramVec.WithLabelValues(memberExpire_worker).Observe(time.Since(start).Seconds())
+	}
+}
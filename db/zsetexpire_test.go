@@ -0,0 +1,148 @@
+package db
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func zexpireSetUp(t *testing.T, db *DB, key []byte, members [][]byte, scores []float64) {
+	txn, err := db.Begin()
+	assert.NoError(t, err)
+	zset, err := GetZSet(txn, key)
+	assert.NoError(t, err)
+	_, err = zset.ZAdd(members, scores)
+	assert.NoError(t, err)
+	assert.NoError(t, txn.Commit(context.TODO()))
+}
+
+func zexpireMember(t *testing.T, db *DB, key, member []byte, at int64) bool {
+	txn, err := db.Begin()
+	assert.NoError(t, err)
+	zset, err := GetZSet(txn, key)
+	assert.NoError(t, err)
+	ok, err := zset.ZExpireMember(member, at)
+	assert.NoError(t, err)
+	assert.NoError(t, txn.Commit(context.TODO()))
+	return ok
+}
+
+func zexpireCard(t *testing.T, db *DB, key []byte) int64 {
+	txn, err := db.Begin()
+	assert.NoError(t, err)
+	zset, err := GetZSet(txn, key)
+	assert.NoError(t, err)
+	assert.NoError(t, txn.Commit(context.TODO()))
+	return zset.ZCard()
+}
+
+func TestZExpireMember(t *testing.T) {
+	db := MockDB()
+	key := []byte("TestZExpireMember")
+	members := [][]byte{[]byte("m1"), []byte("m2")}
+	zexpireSetUp(t, db, key, members, []float64{1, 2})
+
+	// a missing member reports false
+	assert.False(t, zexpireMember(t, db, key, []byte("nosuch"), Now()+int64(10e9)))
+
+	// m1 is due already, the worker removes it and fixes Len
+	assert.True(t, zexpireMember(t, db, key, []byte("m1"), Now()-1))
+	assert.NoError(t, doMemberExpire(db, 256))
+	assert.Equal(t, int64(1), zexpireCard(t, db, key))
+
+	txn, err := db.Begin()
+	assert.NoError(t, err)
+	zset, err := GetZSet(txn, key)
+	assert.NoError(t, err)
+	score, err := zset.ZScore([]byte("m1"))
+	assert.NoError(t, err)
+	assert.Nil(t, score)
+	items, err := zset.ZAnyOrderRange(0, -1, false, true)
+	assert.NoError(t, err)
+	assert.Equal(t, [][]byte{[]byte("m2")}, items)
+	assert.NoError(t, txn.Commit(context.TODO()))
+
+	// expiring the last member removes the meta key too
+	assert.True(t, zexpireMember(t, db, key, []byte("m2"), Now()-1))
+	assert.NoError(t, doMemberExpire(db, 256))
+	txn, err = db.Begin()
+	assert.NoError(t, err)
+	_, err = txn.t.Get(MetaKey(db, key))
+	assert.True(t, IsErrNotFound(err))
+	txn.Commit(context.TODO())
+}
+
+func TestZExpireMemberReset(t *testing.T) {
+	db := MockDB()
+	key := []byte("TestZExpireMemberReset")
+	zexpireSetUp(t, db, key, [][]byte{[]byte("m1")}, []float64{1})
+
+	// the due ttl is pushed into the future before the worker runs, the
+	// stale index entry must not remove the member
+	assert.True(t, zexpireMember(t, db, key, []byte("m1"), Now()-1))
+	assert.True(t, zexpireMember(t, db, key, []byte("m1"), Now()+int64(3600e9)))
+	assert.NoError(t, doMemberExpire(db, 256))
+	assert.Equal(t, int64(1), zexpireCard(t, db, key))
+
+	// dropping the ttl keeps the member forever
+	assert.True(t, zexpireMember(t, db, key, []byte("m1"), Now()-1))
+	assert.True(t, zexpireMember(t, db, key, []byte("m1"), 0))
+	assert.NoError(t, doMemberExpire(db, 256))
+	assert.Equal(t, int64(1), zexpireCard(t, db, key))
+}
+
+func TestZExpireMemberRemoved(t *testing.T) {
+	db := MockDB()
+	key := []byte("TestZExpireMemberRemoved")
+	zexpireSetUp(t, db, key, [][]byte{[]byte("m1"), []byte("m2")}, []float64{1, 2})
+
+	// the member is removed before its ttl fires, the stale index entry
+	// must not touch the length bookkeeping
+	assert.True(t, zexpireMember(t, db, key, []byte("m1"), Now()-1))
+	txn, err := db.Begin()
+	assert.NoError(t, err)
+	zset, err := GetZSet(txn, key)
+	assert.NoError(t, err)
+	removed, err := zset.ZRem([][]byte{[]byte("m1")})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), removed)
+	assert.NoError(t, txn.Commit(context.TODO()))
+
+	assert.NoError(t, doMemberExpire(db, 256))
+	assert.Equal(t, int64(1), zexpireCard(t, db, key))
+}
+
+func TestZRemClearsMemberTTL(t *testing.T) {
+	db := MockDB()
+	key := []byte("TestZRemClearsMemberTTL")
+	zexpireSetUp(t, db, key, [][]byte{[]byte("m1"), []byte("m2")}, []float64{1, 2})
+
+	// the removal drops the ttl record along with the member, a re-added
+	// member under the same name must not inherit the old deadline
+	assert.True(t, zexpireMember(t, db, key, []byte("m1"), Now()-1))
+	txn, err := db.Begin()
+	assert.NoError(t, err)
+	zset, err := GetZSet(txn, key)
+	assert.NoError(t, err)
+	removed, err := zset.ZRem([][]byte{[]byte("m1")})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), removed)
+	dkey := DataKey(db, zset.meta.ID)
+	_, err = txn.t.Get(zsetMemberExpireKey(dkey, []byte("m1")))
+	assert.True(t, IsErrNotFound(err))
+	assert.NoError(t, txn.Commit(context.TODO()))
+
+	zexpireSetUp(t, db, key, [][]byte{[]byte("m1")}, []float64{1})
+	assert.NoError(t, doMemberExpire(db, 256))
+	assert.Equal(t, int64(2), zexpireCard(t, db, key))
+
+	txn, err = db.Begin()
+	assert.NoError(t, err)
+	zset, err = GetZSet(txn, key)
+	assert.NoError(t, err)
+	score, err := zset.ZScore([]byte("m1"))
+	assert.NoError(t, err)
+	assert.NotNil(t, score)
+	assert.NoError(t, txn.Commit(context.TODO()))
+}
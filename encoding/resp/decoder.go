@@ -0,0 +1,249 @@
+package resp
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+)
+
+// Protocol limits enforced by the decoder, they match the redis ones
+// and keep a pathological peer from pinning memory with CRLF-less
+// lines, huge bulk lengths or huge multibulk counts
+const (
+	// MaxLineLength bounds a single protocol line, the CRLF excluded
+	MaxLineLength = 64 * 1024
+	// MaxBulkLength bounds the declared length of a bulk string
+	MaxBulkLength = 512 * 1024 * 1024
+	// MaxArrayLength bounds the declared element count of an array
+	MaxArrayLength = 1024 * 1024
+)
+
+// protocolError reports precisely what was wrong with the input, it
+// matches ErrInvalidProtocol with errors.Is so callers checking for a
+// generic protocol error keep working
+type protocolError string
+
+func (e protocolError) Error() string { return "Protocol error: " + string(e) }
+
+func (e protocolError) Is(target error) bool { return target == ErrInvalidProtocol }
+
+func protocolErrorf(format string, a ...interface{}) error {
+	return protocolError(fmt.Sprintf(format, a...))
+}
+
+// Decoder implements the decoder interface
+type Decoder struct {
+	r *Reader
+}
+
+// NewDecoder creates a RESP decoder
+func NewDecoder(r io.Reader) *Decoder {
+	return &Decoder{&Reader{r}}
+}
+
+//Reader implements a reader which supports reading to a delimer
+type Reader struct {
+	r io.Reader
+}
+
+// ReadBytes read bytes until delim, it read byte by byte and
+// do not buffer anything, you should use a buffer reader as the
+// backend to achieve performance
+func (r *Reader) ReadBytes(delim byte) ([]byte, error) {
+	b := make([]byte, 1)
+	buf := make([]byte, 0, 64)
+	// it is necessary to read byte by byte thought it seems silly,
+	// the reader here just take bytes it needed and should not exceed,
+	// or it will make the outside reader's offset unexpected
+	for {
+		n, err := r.r.Read(b)
+		if n == 1 {
+			buf = append(buf, b[0])
+		}
+		if err != nil {
+			if err == io.EOF {
+				return buf, err
+			}
+			return nil, err
+		}
+
+		if n == 0 {
+			continue
+		}
+
+		if b[0] == delim {
+			return buf, nil
+		}
+	}
+
+}
+
+//Read bytes into p
+func (r *Reader) Read(p []byte) (int, error) {
+	return r.r.Read(p)
+}
+
+// states of the line scanner, a protocol line is data terminated by CRLF
+const (
+	scanData = iota
+	scanCR
+	scanEnd
+)
+
+// readLine reads one protocol line and returns it without the CRLF.
+// It scans byte by byte to never consume bytes beyond the line, fails
+// on a bare CR or LF inside the line and gives up early on CRLF-less
+// input longer than MaxLineLength instead of buffering it forever
+func (r *Reader) readLine() ([]byte, error) {
+	b := make([]byte, 1)
+	line := make([]byte, 0, 64)
+	state := scanData
+	for state != scanEnd {
+		n, err := r.r.Read(b)
+		if err != nil {
+			if err == io.EOF {
+				// a clean EOF before any byte of the line, let the
+				// caller tell a closed connection from a broken one
+				if len(line) == 0 && state == scanData {
+					return nil, io.EOF
+				}
+				return nil, io.ErrUnexpectedEOF
+			}
+			return nil, err
+		}
+		if n == 0 {
+			continue
+		}
+
+		switch state {
+		case scanData:
+			switch b[0] {
+			case '\r':
+				state = scanCR
+			case '\n':
+				return nil, protocolError("unexpected LF in line")
+			default:
+				if len(line) >= MaxLineLength {
+					return nil, protocolError("too big inline request")
+				}
+				line = append(line, b[0])
+			}
+		case scanCR:
+			if b[0] != '\n' {
+				return nil, protocolError("expected LF after CR")
+			}
+			state = scanEnd
+		}
+	}
+	return line, nil
+}
+
+// line reads a protocol line and checks its type prefix
+func (d *Decoder) line(prefix byte) ([]byte, error) {
+	line, err := d.r.readLine()
+	if err != nil {
+		return nil, err
+	}
+	if len(line) == 0 {
+		return nil, protocolErrorf("expected '%c', got empty line", prefix)
+	}
+	if line[0] != prefix {
+		return nil, protocolErrorf("expected '%c', got '%c'", prefix, line[0])
+	}
+	return line[1:], nil
+}
+
+//Error parses a RESP error
+func (d *Decoder) Error() (string, error) {
+	val, err := d.line('-')
+	if err != nil {
+		return "", err
+	}
+	return string(val), nil
+}
+
+//SimpleString parses a RESP simplestring
+func (d *Decoder) SimpleString() (string, error) {
+	val, err := d.line('+')
+	if err != nil {
+		return "", err
+	}
+	return string(val), nil
+}
+
+//BulkString parses a RESP bulkstring
+func (d *Decoder) BulkString() (string, error) {
+	hdr, err := d.line('$')
+	if err != nil {
+		return "", err
+	}
+	length, err := strconv.ParseInt(string(hdr), 10, 64)
+	if err != nil {
+		return "", protocolError("invalid bulk length")
+	}
+	if length == -1 {
+		// null bulk string, $-1
+		return "", nil
+	}
+	if length < 0 || length > MaxBulkLength {
+		return "", protocolError("invalid bulk length")
+	}
+
+	// read the body in chunks so a peer declaring a huge length
+	// without sending the data can not pin the whole buffer at once
+	remain := int(length) + 2 // the body ends with CRLF
+	chunk := remain
+	if chunk > 32*1024 {
+		chunk = 32 * 1024
+	}
+	body := make([]byte, 0, chunk)
+	buf := make([]byte, chunk)
+	for remain > 0 {
+		if remain < len(buf) {
+			buf = buf[:remain]
+		}
+		n, err := io.ReadFull(d.r, buf)
+		if err != nil {
+			return "", io.ErrUnexpectedEOF
+		}
+		body = append(body, buf[:n]...)
+		remain -= n
+	}
+	if body[length] != '\r' || body[length+1] != '\n' {
+		return "", protocolError("bad bulk string terminator")
+	}
+	return string(body[:length]), nil
+}
+
+//Array parses a RESP array
+func (d *Decoder) Array() (int, error) {
+	hdr, err := d.line('*')
+	if err != nil {
+		return -1, err
+	}
+	size, err := strconv.ParseInt(string(hdr), 10, 64)
+	if err != nil {
+		return -1, protocolError("invalid multibulk length")
+	}
+	if size == -1 {
+		// null array, *-1
+		return -1, nil
+	}
+	if size < 0 || size > MaxArrayLength {
+		return -1, protocolError("invalid multibulk length")
+	}
+	return int(size), nil
+}
+
+//Integer parses a RESP integer
+func (d *Decoder) Integer() (int64, error) {
+	val, err := d.line(':')
+	if err != nil {
+		return -1, err
+	}
+	v, err := strconv.ParseInt(string(val), 10, 64)
+	if err != nil {
+		return -1, protocolError("invalid integer")
+	}
+	return v, nil
+}
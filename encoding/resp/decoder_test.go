@@ -0,0 +1,115 @@
+package resp
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDecode_NullBulkString(t *testing.T) {
+	assert := assert.New(t)
+	d := NewDecoder(bytes.NewBufferString("$-1\r\n"))
+	val, err := d.BulkString()
+	assert.NoError(err)
+	assert.Equal("", val)
+}
+
+func TestDecode_NullArray(t *testing.T) {
+	assert := assert.New(t)
+	d := NewDecoder(bytes.NewBufferString("*-1\r\n"))
+	size, err := d.Array()
+	assert.NoError(err)
+	assert.Equal(-1, size)
+}
+
+func TestDecode_PreciseErrors(t *testing.T) {
+	assert := assert.New(t)
+
+	// wrong prefix
+	d := NewDecoder(bytes.NewBufferString(":1\r\n"))
+	_, err := d.Array()
+	assert.True(errors.Is(err, ErrInvalidProtocol))
+	assert.Contains(err.Error(), "expected '*'")
+
+	// bare LF without CR
+	d = NewDecoder(bytes.NewBufferString("*1\n"))
+	_, err = d.Array()
+	assert.True(errors.Is(err, ErrInvalidProtocol))
+
+	// CR followed by something else than LF
+	d = NewDecoder(bytes.NewBufferString("*1\rx"))
+	_, err = d.Array()
+	assert.True(errors.Is(err, ErrInvalidProtocol))
+
+	// non numeric multibulk length
+	d = NewDecoder(bytes.NewBufferString("*abc\r\n"))
+	_, err = d.Array()
+	assert.True(errors.Is(err, ErrInvalidProtocol))
+	assert.Contains(err.Error(), "multibulk")
+
+	// non numeric bulk length
+	d = NewDecoder(bytes.NewBufferString("$abc\r\n"))
+	_, err = d.BulkString()
+	assert.True(errors.Is(err, ErrInvalidProtocol))
+	assert.Contains(err.Error(), "bulk")
+}
+
+func TestDecode_Limits(t *testing.T) {
+	assert := assert.New(t)
+
+	// a CRLF-less line fails once it exceeds MaxLineLength instead
+	// of buffering the whole stream
+	d := NewDecoder(io.MultiReader(
+		bytes.NewBufferString("+"),
+		strings.NewReader(strings.Repeat("a", MaxLineLength+1))))
+	_, err := d.SimpleString()
+	assert.True(errors.Is(err, ErrInvalidProtocol))
+
+	// a bulk length beyond MaxBulkLength is rejected up front
+	d = NewDecoder(bytes.NewBufferString("$536870913\r\n"))
+	_, err = d.BulkString()
+	assert.True(errors.Is(err, ErrInvalidProtocol))
+
+	// a multibulk count beyond MaxArrayLength is rejected up front
+	d = NewDecoder(bytes.NewBufferString("*1048577\r\n"))
+	_, err = d.Array()
+	assert.True(errors.Is(err, ErrInvalidProtocol))
+}
+
+func TestDecode_EOF(t *testing.T) {
+	assert := assert.New(t)
+
+	// a clean EOF before any byte keeps the plain io.EOF so the
+	// server can tell a closed connection from a broken request
+	d := NewDecoder(bytes.NewBufferString(""))
+	_, err := d.Array()
+	assert.Equal(io.EOF, err)
+
+	// EOF in the middle of a line or a bulk body is unexpected
+	d = NewDecoder(bytes.NewBufferString("*12"))
+	_, err = d.Array()
+	assert.Equal(io.ErrUnexpectedEOF, err)
+
+	d = NewDecoder(bytes.NewBufferString("$5\r\nab"))
+	_, err = d.BulkString()
+	assert.Equal(io.ErrUnexpectedEOF, err)
+}
+
+func TestDecode_NoOverRead(t *testing.T) {
+	assert := assert.New(t)
+
+	// the decoder must not consume bytes beyond the value it parses
+	buf := bytes.NewBufferString("*1\r\n$5\r\nhello\r\nrest")
+	d := NewDecoder(buf)
+	size, err := d.Array()
+	assert.NoError(err)
+	assert.Equal(1, size)
+	val, err := d.BulkString()
+	assert.NoError(err)
+	assert.Equal("hello", val)
+	assert.Equal("rest", buf.String())
+}
@@ -0,0 +1,58 @@
+package resp
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+)
+
+// FuzzDecode drives the decoder the same way the server read loop
+// does: an array header followed by bulk strings. Whatever the input,
+// the decoder must not panic and must fail with either a precise
+// protocol error or an io error
+func FuzzDecode(f *testing.F) {
+	seeds := []string{
+		"*1\r\n$5\r\nhello\r\n",
+		"*2\r\n$3\r\nget\r\n$3\r\nfoo\r\n",
+		"*-1\r\n",
+		"*0\r\n",
+		"$-1\r\n",
+		"+OK\r\n",
+		"-ERR something\r\n",
+		":12345\r\n",
+		"*1\r\n*1\r\n*1\r\n*1\r\n",
+		"$9999999999999999\r\n",
+		"*1\r\n$5\r\nhel",
+		"*1\rx",
+		"\n\n\n",
+	}
+	for _, seed := range seeds {
+		f.Add([]byte(seed))
+	}
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		checkErr := func(err error) {
+			if err == nil {
+				return
+			}
+			if errors.Is(err, ErrInvalidProtocol) || err == io.EOF || err == io.ErrUnexpectedEOF {
+				return
+			}
+			t.Fatalf("unexpected error type: %v", err)
+		}
+
+		d := NewDecoder(bytes.NewReader(data))
+		size, err := d.Array()
+		checkErr(err)
+		if err != nil {
+			return
+		}
+		for i := 0; i < size; i++ {
+			if _, err := d.BulkString(); err != nil {
+				checkErr(err)
+				return
+			}
+		}
+	})
+}
@@ -68,11 +68,11 @@ func TestBulkString_Decode(t *testing.T) {
 	assert.NoError(err)
 	assert.Equal("test", val)
 
-	// Truncated data
+	// Truncated data, the declared length does not match the body
 	d = NewDecoder(bytes.NewBufferString("$3\r\ntest\r\n"))
 	val, err = d.BulkString()
-	assert.NoError(err)
-	assert.Equal("tes", val)
+	assert.Error(err)
+	assert.Equal("", val)
 
 	// Invalid indicator
 	d = NewDecoder(bytes.NewBufferString("*4\r\ntest\r\n"))
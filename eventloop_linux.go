@@ -0,0 +1,152 @@
+//go:build linux
+// +build linux
+
+package titan
+
+import (
+	"errors"
+	"net"
+	"runtime"
+	"sync"
+	"syscall"
+
+	"go.uber.org/zap"
+)
+
+// eventLoop parks idle connections in an epoll instance instead of a
+// goroutine blocked on read, deployments with very many mostly idle
+// connections pay only for the descriptor and the read buffer. Ready
+// connections are handed to a fixed worker pool which drains their
+// buffered commands (serveBurst) and parks them again. See the
+// event-loop server config
+type eventLoop struct {
+	epfd   int
+	server *Server
+	queue  chan *client
+
+	mu      sync.Mutex
+	clients map[int32]*client
+}
+
+// newEventLoop creates the poller and starts its workers, workers of 0
+// means one per processor
+func newEventLoop(s *Server, workers int) (*eventLoop, error) {
+	epfd, err := syscall.EpollCreate1(syscall.EPOLL_CLOEXEC)
+	if err != nil {
+		return nil, err
+	}
+	if workers <= 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+	l := &eventLoop{
+		epfd:    epfd,
+		server:  s,
+		queue:   make(chan *client, workers*2),
+		clients: make(map[int32]*client),
+	}
+	for i := 0; i < workers; i++ {
+		go l.worker()
+	}
+	go l.poll()
+	return l, nil
+}
+
+// watch hands a new connection to the loop, it stays parked without a
+// goroutine until its first bytes arrive
+func (l *eventLoop) watch(c *client, conn net.Conn) error {
+	fd, err := connFd(conn)
+	if err != nil {
+		return err
+	}
+	c.attach(conn)
+	c.fd = fd
+	l.mu.Lock()
+	l.clients[fd] = c
+	l.mu.Unlock()
+	if err := l.arm(fd, syscall.EPOLL_CTL_ADD); err != nil {
+		l.mu.Lock()
+		delete(l.clients, fd)
+		l.mu.Unlock()
+		return err
+	}
+	return nil
+}
+
+// arm registers the descriptor for one readability event, oneshot keeps
+// a connection from being queued to two workers at once
+func (l *eventLoop) arm(fd int32, op int) error {
+	ev := syscall.EpollEvent{
+		Events: uint32(syscall.EPOLLIN | syscall.EPOLLRDHUP | syscall.EPOLLONESHOT),
+		Fd:     fd,
+	}
+	return syscall.EpollCtl(l.epfd, op, int(fd), &ev)
+}
+
+func (l *eventLoop) poll() {
+	events := make([]syscall.EpollEvent, 128)
+	for {
+		n, err := syscall.EpollWait(l.epfd, events, -1)
+		if err != nil {
+			if err == syscall.EINTR {
+				continue
+			}
+			zap.L().Error("event loop poll failed", zap.Error(err))
+			return
+		}
+		for i := 0; i < n; i++ {
+			l.mu.Lock()
+			c := l.clients[events[i].Fd]
+			l.mu.Unlock()
+			if c != nil {
+				l.queue <- c
+			}
+		}
+	}
+}
+
+func (l *eventLoop) worker() {
+	for c := range l.queue {
+		done, err := c.serveBurst()
+		if done {
+			if err != nil {
+				zap.L().Error("serve conn failed", zap.String("addr", c.cliCtx.RemoteAddr),
+					zap.Int64("clientid", c.cliCtx.ID), zap.String("namespace", c.cliCtx.Namespace), zap.Error(err))
+			}
+			l.drop(c)
+			l.server.closeClient(c)
+			continue
+		}
+		if err := l.arm(c.fd, syscall.EPOLL_CTL_MOD); err != nil {
+			zap.L().Error("event loop re-arm failed", zap.Int64("clientid", c.cliCtx.ID), zap.Error(err))
+			l.drop(c)
+			c.conn.Close()
+			l.server.closeClient(c)
+		}
+	}
+}
+
+// drop forgets a finished connection, the close of its descriptor
+// removes it from the epoll set as well
+func (l *eventLoop) drop(c *client) {
+	l.mu.Lock()
+	delete(l.clients, c.fd)
+	l.mu.Unlock()
+	syscall.EpollCtl(l.epfd, syscall.EPOLL_CTL_DEL, int(c.fd), nil)
+}
+
+// connFd digs the socket descriptor out of a connection
+func connFd(conn net.Conn) (int32, error) {
+	sc, ok := conn.(syscall.Conn)
+	if !ok {
+		return 0, errors.New("connection exposes no descriptor")
+	}
+	raw, err := sc.SyscallConn()
+	if err != nil {
+		return 0, err
+	}
+	var fd int32
+	if err := raw.Control(func(f uintptr) { fd = int32(f) }); err != nil {
+		return 0, err
+	}
+	return fd, nil
+}
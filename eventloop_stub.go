@@ -0,0 +1,21 @@
+//go:build !linux
+// +build !linux
+
+package titan
+
+import (
+	"errors"
+	"net"
+)
+
+// eventLoop is only implemented on linux, other platforms fall back to a
+// goroutine per connection
+type eventLoop struct{}
+
+func newEventLoop(s *Server, workers int) (*eventLoop, error) {
+	return nil, errors.New("the event loop frontend requires linux")
+}
+
+func (l *eventLoop) watch(c *client, conn net.Conn) error {
+	return errors.New("the event loop frontend requires linux")
+}
@@ -0,0 +1,49 @@
+// Package failpoint provides a tiny failpoint registry for chaos testing.
+// Injection sites are compiled into hot paths but evaluate to no-ops unless
+// the binary is built with the "failpoint" tag, in which case the registered
+// points can be toggled at runtime through the status server.
+package failpoint
+
+import (
+	"sort"
+	"sync"
+)
+
+var (
+	mu     sync.RWMutex
+	points = make(map[string]string)
+)
+
+// Enable activates the failpoint with an optional value, the meaning of the
+// value is up to the injection site (e.g. a sleep duration or error message)
+func Enable(name, value string) {
+	mu.Lock()
+	points[name] = value
+	mu.Unlock()
+}
+
+// Disable deactivates the failpoint
+func Disable(name string) {
+	mu.Lock()
+	delete(points, name)
+	mu.Unlock()
+}
+
+// List returns the enabled failpoints and their values in name order
+func List() []string {
+	mu.RLock()
+	names := make([]string, 0, len(points))
+	for name := range points {
+		names = append(names, name+"="+points[name])
+	}
+	mu.RUnlock()
+	sort.Strings(names)
+	return names
+}
+
+func eval(name string) (string, bool) {
+	mu.RLock()
+	val, ok := points[name]
+	mu.RUnlock()
+	return val, ok
+}
@@ -0,0 +1,23 @@
+package failpoint
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRegistry(t *testing.T) {
+	Enable("b-point", "v1")
+	Enable("a-point", "")
+	assert.Equal(t, []string{"a-point=", "b-point=v1"}, List())
+
+	val, ok := eval("b-point")
+	assert.True(t, ok)
+	assert.Equal(t, "v1", val)
+
+	Disable("a-point")
+	Disable("b-point")
+	assert.Empty(t, List())
+	_, ok = eval("b-point")
+	assert.False(t, ok)
+}
@@ -0,0 +1,63 @@
+//go:build failpoint
+// +build failpoint
+
+package failpoint
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// Enabled reports whether failpoint support is compiled in
+const Enabled = true
+
+// Inject runs f with the failpoint value when the named point is enabled
+func Inject(name string, f func(val string)) {
+	if val, ok := eval(name); ok {
+		f(val)
+	}
+}
+
+// Error returns a non-nil error when the named point is enabled, the value
+// is used as the message when present
+func Error(name string) error {
+	val, ok := eval(name)
+	if !ok {
+		return nil
+	}
+	if val == "" {
+		val = "injected by failpoint " + name
+	}
+	return errors.New(val)
+}
+
+func init() {
+	//http toggle failpoints at runtime, test builds only
+	http.HandleFunc("/titan/failpoint", handleFailpoint)
+}
+
+func handleFailpoint(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		for _, p := range List() {
+			fmt.Fprintln(w, p)
+		}
+	case http.MethodPut, http.MethodPost:
+		name := r.FormValue("name")
+		if name == "" {
+			http.Error(w, "name required", http.StatusBadRequest)
+			return
+		}
+		Enable(name, r.FormValue("value"))
+	case http.MethodDelete:
+		name := r.FormValue("name")
+		if name == "" {
+			http.Error(w, "name required", http.StatusBadRequest)
+			return
+		}
+		Disable(name)
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
@@ -0,0 +1,13 @@
+//go:build !failpoint
+// +build !failpoint
+
+package failpoint
+
+// Enabled reports whether failpoint support is compiled in
+const Enabled = false
+
+// Inject is a no-op in release builds
+func Inject(name string, f func(val string)) {}
+
+// Error always returns nil in release builds
+func Error(name string) error { return nil }
@@ -1,15 +1,36 @@
 module github.com/distributedio/titan
 
+go 1.21.6
+
 require (
 	github.com/arthurkiller/rollingWriter v1.0.1
+	github.com/distributedio/configo v0.0.0-20190610140513-0d38d0d8590a
+	github.com/distributedio/continuous v0.0.0-20190527021358-1768e41f22b9
+	github.com/golang/protobuf v1.2.0
+	github.com/gomodule/redigo v2.0.0+incompatible
+	github.com/pierrec/lz4/v4 v4.1.21
+	github.com/pingcap/kvproto v0.0.0-20190226063853-f6c0b7ffff11
+	github.com/pingcap/tidb v0.0.0-20190228062803-4dc309315c84
+	github.com/prometheus/client_golang v0.9.2
+	github.com/satori/go.uuid v1.2.0
+	github.com/shafreeck/retry v0.0.0-20180827080527-71c8c3fbf8f8
+	github.com/sirupsen/logrus v1.3.0
+	github.com/stretchr/testify v1.2.2
+	github.com/twinj/uuid v1.0.0
+	go.uber.org/zap v1.9.1
+	golang.org/x/time v0.0.0-20181108054448-85acf8d2951c
+)
+
+require (
+	github.com/BurntSushi/toml v0.3.1 // indirect
+	github.com/beorn7/perks v0.0.0-20180321164747-3a771d992973 // indirect
 	github.com/codahale/hdrhistogram v0.0.0-20161010025455-3a0bb77429bd // indirect
 	github.com/coreos/etcd v3.3.10+incompatible // indirect
 	github.com/coreos/go-systemd v0.0.0-20181031085051-9002847aa142 // indirect
 	github.com/coreos/pkg v0.0.0-20180928190104-399ea9e2e55f // indirect
 	github.com/cznic/mathutil v0.0.0-20181122101859-297441e03548 // indirect
 	github.com/cznic/sortutil v0.0.0-20181122101858-f5f958428db8 // indirect
-	github.com/distributedio/configo v0.0.0-20190610140513-0d38d0d8590a
-	github.com/distributedio/continuous v0.0.0-20190527021358-1768e41f22b9
+	github.com/davecgh/go-spew v1.1.1 // indirect
 	github.com/dustin/go-humanize v1.0.0 // indirect
 	github.com/facebookgo/ensure v0.0.0-20160127193407-b4ab57deab51 // indirect
 	github.com/facebookgo/freeport v0.0.0-20150612182905-d4adf43b75b9 // indirect
@@ -17,8 +38,7 @@ require (
 	github.com/facebookgo/stack v0.0.0-20160209184415-751773369052 // indirect
 	github.com/facebookgo/subset v0.0.0-20150612182917-8dac2c3c4870 // indirect
 	github.com/gogo/protobuf v1.2.0 // indirect
-	github.com/golang/protobuf v1.2.0
-	github.com/gomodule/redigo v2.0.0+incompatible
+	github.com/golang/snappy v0.0.0-20180518054509-2e65f85255db // indirect
 	github.com/google/btree v0.0.0-20180813153112-4030bb1f1f0c // indirect
 	github.com/gorilla/context v1.1.1 // indirect
 	github.com/gorilla/mux v1.6.2 // indirect
@@ -26,36 +46,46 @@ require (
 	github.com/grpc-ecosystem/go-grpc-middleware v1.0.0 // indirect
 	github.com/grpc-ecosystem/go-grpc-prometheus v1.2.0 // indirect
 	github.com/grpc-ecosystem/grpc-gateway v1.5.1 // indirect
-	github.com/kylelemons/godebug v1.1.0 // indirect
+	github.com/konsorten/go-windows-terminal-sequences v1.0.1 // indirect
+	github.com/matttproud/golang_protobuf_extensions v1.0.1 // indirect
 	github.com/montanaflynn/stats v0.0.0-20180911141734-db72e6cae808 // indirect
 	github.com/myesui/uuid v1.0.0 // indirect
+	github.com/naoina/go-stringutil v0.1.0 // indirect
+	github.com/ngaut/pools v0.0.0-20180318154953-b7bc8c42aac7 // indirect
+	github.com/ngaut/sync2 v0.0.0-20141008032647-7a24ed77b2ef // indirect
 	github.com/onsi/gomega v1.4.3 // indirect
+	github.com/opentracing/basictracer-go v1.0.0 // indirect
+	github.com/opentracing/opentracing-go v1.0.2 // indirect
 	github.com/pingcap/check v0.0.0-20190102082844-67f458068fc8 // indirect
+	github.com/pingcap/errors v0.11.0 // indirect
 	github.com/pingcap/goleveldb v0.0.0-20171020122428-b9ff6c35079e // indirect
-	github.com/pingcap/kvproto v0.0.0-20190226063853-f6c0b7ffff11
-	github.com/pingcap/tidb v0.0.0-20190228062803-4dc309315c84
+	github.com/pingcap/parser v0.0.0-20190227090929-c68bfe7e0257 // indirect
+	github.com/pingcap/pd v2.1.0-rc.4+incompatible // indirect
+	github.com/pingcap/tidb-tools v2.1.3-0.20190116051332-34c808eef588+incompatible // indirect
 	github.com/pingcap/tipb v0.0.0-20190107072121-abbec73437b7 // indirect
 	github.com/pkg/errors v0.8.1 // indirect
-	github.com/prometheus/client_golang v0.9.2
+	github.com/pmezard/go-difflib v1.0.0 // indirect
+	github.com/prometheus/client_model v0.0.0-20180712105110-5c3871d89910 // indirect
+	github.com/prometheus/common v0.0.0-20181126121408-4724e9255275 // indirect
+	github.com/prometheus/procfs v0.0.0-20181204211112-1dc9a6cbc91a // indirect
 	github.com/remyoudompheng/bigfft v0.0.0-20170806203942-52369c62f446 // indirect
 	github.com/robfig/cron v0.0.0-20180505203441-b41be1df6967 // indirect
-	github.com/satori/go.uuid v1.2.0
-	github.com/shafreeck/retry v0.0.0-20180827080527-71c8c3fbf8f8
-	github.com/sirupsen/logrus v1.3.0
+	github.com/shafreeck/toml v0.0.0-20190326060449-44ad86712acc // indirect
 	github.com/soheilhy/cmux v0.1.4 // indirect
 	github.com/spaolacci/murmur3 v0.0.0-20180118202830-f09979ecbc72 // indirect
-	github.com/stretchr/testify v1.2.2
 	github.com/tmc/grpc-websocket-proxy v0.0.0-20171017195756-830351dc03c6 // indirect
-	github.com/twinj/uuid v1.0.0
 	github.com/uber-go/atomic v1.3.2 // indirect
 	github.com/uber/jaeger-client-go v2.15.0+incompatible // indirect
 	github.com/uber/jaeger-lib v1.5.0 // indirect
 	github.com/unrolled/render v0.0.0-20180914162206-b9786414de4d // indirect
 	go.uber.org/atomic v1.3.2 // indirect
 	go.uber.org/multierr v1.1.0 // indirect
-	go.uber.org/zap v1.9.1
+	golang.org/x/crypto v0.0.0-20190313024323-a1f597ede03a // indirect
+	golang.org/x/net v0.0.0-20190320064053-1272bf9dcd53 // indirect
 	golang.org/x/sync v0.0.0-20181221193216-37e7f081c4d4 // indirect
-	golang.org/x/time v0.0.0-20181108054448-85acf8d2951c
+	golang.org/x/sys v0.0.0-20190318195719-6c81ef8f67ca // indirect
+	golang.org/x/text v0.3.0 // indirect
+	golang.org/x/tools v0.0.0-20190319232107-3f1ed9edd1b4 // indirect
 	google.golang.org/genproto v0.0.0-20190108161440-ae2f86662275 // indirect
 	google.golang.org/grpc v1.17.0 // indirect
 	gopkg.in/natefinch/lumberjack.v2 v2.0.0 // indirect
@@ -0,0 +1,318 @@
+package memcached
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/distributedio/titan/db"
+)
+
+// exptimes up to a month are relative seconds, larger values are
+// absolute unix timestamps, as defined by the memcached protocol
+const monthSeconds = 60 * 60 * 24 * 30
+
+// maxLineLength bounds a memcached command line, the protocol limits
+// keys to 250 bytes so this is generous
+const maxLineLength = 2048
+
+type conn struct {
+	c      net.Conn
+	r      *bufio.Reader
+	w      *bufio.Writer
+	server *Server
+}
+
+func newConn(c net.Conn, server *Server) *conn {
+	return &conn{
+		c:      c,
+		r:      bufio.NewReader(c),
+		w:      bufio.NewWriter(c),
+		server: server,
+	}
+}
+
+func (c *conn) serve() error {
+	for {
+		line, err := c.r.ReadString('\n')
+		if err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+		if len(line) > maxLineLength {
+			c.reply("CLIENT_ERROR line too long")
+			continue
+		}
+		fields := strings.Fields(strings.TrimRight(line, "\r\n"))
+		if len(fields) == 0 {
+			c.reply("ERROR")
+			continue
+		}
+
+		quit := false
+		switch fields[0] {
+		case "get", "gets":
+			err = c.handleGet(fields[1:])
+		case "set":
+			err = c.handleSet(fields[1:])
+		case "delete":
+			err = c.handleDelete(fields[1:])
+		case "incr":
+			err = c.handleIncrDecr(fields[1:], false)
+		case "decr":
+			err = c.handleIncrDecr(fields[1:], true)
+		case "touch":
+			err = c.handleTouch(fields[1:])
+		case "version":
+			err = c.reply("VERSION titan")
+		case "quit":
+			quit = true
+		default:
+			err = c.reply("ERROR")
+		}
+		if err != nil {
+			return err
+		}
+		if err := c.w.Flush(); err != nil {
+			return err
+		}
+		if quit {
+			return nil
+		}
+	}
+}
+
+func (c *conn) reply(lines ...string) error {
+	for _, line := range lines {
+		if _, err := c.w.WriteString(line + "\r\n"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *conn) handleGet(keys []string) error {
+	if len(keys) == 0 {
+		return c.reply("ERROR")
+	}
+	for _, key := range keys {
+		var value []byte
+		err := c.server.execute(context.Background(), func(txn *db.Transaction) error {
+			str, err := db.GetString(txn, []byte(key))
+			if err != nil {
+				return err
+			}
+			value, err = str.Get()
+			if err == db.ErrKeyNotFound {
+				value = nil
+				return nil
+			}
+			return err
+		})
+		if err != nil {
+			return c.reply("SERVER_ERROR " + err.Error())
+		}
+		if value == nil {
+			continue
+		}
+		if err := c.reply("VALUE " + key + " 0 " + strconv.Itoa(len(value))); err != nil {
+			return err
+		}
+		if _, err := c.w.Write(append(value, '\r', '\n')); err != nil {
+			return err
+		}
+	}
+	return c.reply("END")
+}
+
+func (c *conn) handleSet(args []string) error {
+	noreply := stripNoreply(&args)
+	if len(args) != 4 {
+		return c.reply("ERROR")
+	}
+	key := args[0]
+	exptime, err1 := strconv.ParseInt(args[2], 10, 64)
+	size, err2 := strconv.Atoi(args[3])
+	if err1 != nil || err2 != nil || size < 0 {
+		return c.reply("CLIENT_ERROR bad command line format")
+	}
+
+	// the data block is size bytes followed by CRLF
+	data := make([]byte, size+2)
+	if _, err := io.ReadFull(c.r, data); err != nil {
+		return err
+	}
+	if data[size] != '\r' || data[size+1] != '\n' {
+		return c.reply("CLIENT_ERROR bad data chunk")
+	}
+	value := data[:size]
+
+	err := c.server.execute(context.Background(), func(txn *db.Transaction) error {
+		str, err := db.GetString(txn, []byte(key))
+		if err != nil {
+			return err
+		}
+		if ttl := ttlNs(exptime); ttl > 0 {
+			return str.Set(value, ttl)
+		}
+		return str.Set(value)
+	})
+	if err != nil {
+		return c.replyUnlessNoreply(noreply, "SERVER_ERROR "+err.Error())
+	}
+	return c.replyUnlessNoreply(noreply, "STORED")
+}
+
+func (c *conn) handleDelete(args []string) error {
+	noreply := stripNoreply(&args)
+	if len(args) != 1 {
+		return c.reply("ERROR")
+	}
+	var deleted int64
+	err := c.server.execute(context.Background(), func(txn *db.Transaction) error {
+		var err error
+		deleted, err = db.GetKv(txn).Delete([][]byte{[]byte(args[0])})
+		return err
+	})
+	if err != nil {
+		return c.replyUnlessNoreply(noreply, "SERVER_ERROR "+err.Error())
+	}
+	if deleted == 0 {
+		return c.replyUnlessNoreply(noreply, "NOT_FOUND")
+	}
+	return c.replyUnlessNoreply(noreply, "DELETED")
+}
+
+func (c *conn) handleIncrDecr(args []string, decr bool) error {
+	noreply := stripNoreply(&args)
+	if len(args) != 2 {
+		return c.reply("ERROR")
+	}
+	delta, err := strconv.ParseUint(args[1], 10, 64)
+	if err != nil {
+		return c.replyUnlessNoreply(noreply, "CLIENT_ERROR invalid numeric delta argument")
+	}
+
+	var value uint64
+	var found, numeric bool
+	err = c.server.execute(context.Background(), func(txn *db.Transaction) error {
+		str, err := db.GetString(txn, []byte(args[0]))
+		if err != nil {
+			return err
+		}
+		old, err := str.Get()
+		if err == db.ErrKeyNotFound {
+			found = false
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		found = true
+		cur, err := strconv.ParseUint(string(old), 10, 64)
+		if err != nil {
+			numeric = false
+			return nil
+		}
+		numeric = true
+		if decr {
+			// decr clamps at zero as memcached does
+			if delta > cur {
+				value = 0
+			} else {
+				value = cur - delta
+			}
+		} else {
+			value = cur + delta
+		}
+		// keep the remaining ttl of the key
+		if str.Meta.ExpireAt > 0 {
+			return str.Set([]byte(strconv.FormatUint(value, 10)), str.Meta.ExpireAt-db.Now())
+		}
+		return str.Set([]byte(strconv.FormatUint(value, 10)))
+	})
+	if err != nil {
+		return c.replyUnlessNoreply(noreply, "SERVER_ERROR "+err.Error())
+	}
+	if !found {
+		return c.replyUnlessNoreply(noreply, "NOT_FOUND")
+	}
+	if !numeric {
+		return c.replyUnlessNoreply(noreply, "CLIENT_ERROR cannot increment or decrement non-numeric value")
+	}
+	return c.replyUnlessNoreply(noreply, strconv.FormatUint(value, 10))
+}
+
+func (c *conn) handleTouch(args []string) error {
+	noreply := stripNoreply(&args)
+	if len(args) != 2 {
+		return c.reply("ERROR")
+	}
+	exptime, err := strconv.ParseInt(args[1], 10, 64)
+	if err != nil {
+		return c.replyUnlessNoreply(noreply, "CLIENT_ERROR bad command line format")
+	}
+	err = c.server.execute(context.Background(), func(txn *db.Transaction) error {
+		return db.GetKv(txn).ExpireAt([]byte(args[0]), expireAtNs(exptime))
+	})
+	if err == db.ErrKeyNotFound {
+		return c.replyUnlessNoreply(noreply, "NOT_FOUND")
+	}
+	if err != nil {
+		return c.replyUnlessNoreply(noreply, "SERVER_ERROR "+err.Error())
+	}
+	return c.replyUnlessNoreply(noreply, "TOUCHED")
+}
+
+func (c *conn) replyUnlessNoreply(noreply bool, line string) error {
+	if noreply {
+		return nil
+	}
+	return c.reply(line)
+}
+
+func stripNoreply(args *[]string) bool {
+	a := *args
+	if len(a) > 0 && a[len(a)-1] == "noreply" {
+		*args = a[:len(a)-1]
+		return true
+	}
+	return false
+}
+
+// ttlNs converts a memcached exptime to a ttl in nanoseconds, 0 means
+// no expiration and negative values expire immediately
+func ttlNs(exptime int64) int64 {
+	if exptime == 0 {
+		return 0
+	}
+	if exptime < 0 {
+		return 1
+	}
+	if exptime > monthSeconds {
+		ttl := exptime*int64(time.Second) - db.Now()
+		if ttl <= 0 {
+			return 1
+		}
+		return ttl
+	}
+	return exptime * int64(time.Second)
+}
+
+// expireAtNs converts a memcached exptime to an absolute expiration
+// in nanoseconds, 0 removes the expiration
+func expireAtNs(exptime int64) int64 {
+	if exptime == 0 {
+		return 0
+	}
+	if exptime > monthSeconds {
+		return exptime * int64(time.Second)
+	}
+	return db.Now() + exptime*int64(time.Second)
+}
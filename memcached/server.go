@@ -0,0 +1,93 @@
+// Package memcached implements a memcached text protocol listener
+// mapped onto the string structure of the db layer, it gives legacy
+// memcached applications a path onto TiKV storage. Values are stored
+// the same way as with the RESP listener, memcached flags are not
+// persisted and always reported as 0
+package memcached
+
+import (
+	"context"
+	"net"
+
+	"github.com/distributedio/titan/conf"
+	"github.com/distributedio/titan/db"
+	"github.com/shafreeck/retry"
+	"go.uber.org/zap"
+)
+
+// Server implements the memcached text protocol server, the
+// namespace of all its connections comes from the listener config
+type Server struct {
+	db  *db.DB
+	lis net.Listener
+}
+
+// NewServer creates a memcached server on the store
+func NewServer(store *db.RedisStore, config *conf.Memcached) *Server {
+	return &Server{db: store.DB(config.Namespace, 0)}
+}
+
+// Serve accepts incoming connections on the listener
+func (s *Server) Serve(lis net.Listener) error {
+	zap.L().Info("memcached server start", zap.String("addr", lis.Addr().String()))
+	s.lis = lis
+	for {
+		conn, err := lis.Accept()
+		if err != nil {
+			zap.L().Error("memcached server accept failed", zap.String("addr", lis.Addr().String()), zap.Error(err))
+			return err
+		}
+		zap.L().Info("recv memcached connection", zap.String("addr", conn.RemoteAddr().String()))
+		go func(conn net.Conn) {
+			if err := newConn(conn, s).serve(); err != nil {
+				zap.L().Error("serve memcached conn failed",
+					zap.String("addr", conn.RemoteAddr().String()), zap.Error(err))
+			}
+			conn.Close()
+		}(conn)
+	}
+}
+
+// ListenAndServe serves on a specified address
+func (s *Server) ListenAndServe(addr string) error {
+	lis, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	return s.Serve(lis)
+}
+
+// Stop the server
+func (s *Server) Stop() error {
+	zap.L().Info("memcached serve stop", zap.String("addr", s.lis.Addr().String()))
+	return s.lis.Close()
+}
+
+// GracefulStop the server
+func (s *Server) GracefulStop() error {
+	zap.L().Info("memcached serve graceful", zap.String("addr", s.lis.Addr().String()))
+	return s.lis.Close()
+}
+
+// execute runs fn in its own transaction, commits it and retries the
+// whole operation when the commit fails with a retryable error
+func (s *Server) execute(ctx context.Context, fn func(txn *db.Transaction) error) error {
+	return retry.Ensure(ctx, func() error {
+		txn, err := s.db.Begin()
+		if err != nil {
+			return err
+		}
+		if err := fn(txn); err != nil {
+			txn.Rollback()
+			return err
+		}
+		if err := txn.Commit(ctx); err != nil {
+			txn.Rollback()
+			if db.IsRetryableError(err) {
+				return retry.Retriable(err)
+			}
+			return err
+		}
+		return nil
+	})
+}
@@ -0,0 +1,124 @@
+package memcached
+
+import (
+	"bufio"
+	"net"
+	"testing"
+
+	"github.com/distributedio/titan/conf"
+	"github.com/distributedio/titan/db"
+	"github.com/stretchr/testify/assert"
+)
+
+var (
+	testServer *Server
+	testAddr   string
+)
+
+func init() {
+	mockConf := conf.MockConf()
+	store, err := db.Open(&mockConf.Tikv)
+	if err != nil {
+		panic(err)
+	}
+	testServer = NewServer(store, &conf.Memcached{Namespace: "memcached-ns"})
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		panic(err)
+	}
+	testAddr = lis.Addr().String()
+	go testServer.Serve(lis)
+}
+
+type mcClient struct {
+	c net.Conn
+	r *bufio.Reader
+}
+
+func dial(t *testing.T) *mcClient {
+	c, err := net.Dial("tcp", testAddr)
+	assert.NoError(t, err)
+	return &mcClient{c: c, r: bufio.NewReader(c)}
+}
+
+func (mc *mcClient) send(t *testing.T, line string) {
+	_, err := mc.c.Write([]byte(line + "\r\n"))
+	assert.NoError(t, err)
+}
+
+func (mc *mcClient) recv(t *testing.T) string {
+	line, err := mc.r.ReadString('\n')
+	assert.NoError(t, err)
+	return line[:len(line)-2]
+}
+
+func TestSetGetDelete(t *testing.T) {
+	mc := dial(t)
+	defer mc.c.Close()
+
+	mc.send(t, "set mykey 0 0 5\r\nhello")
+	assert.Equal(t, "STORED", mc.recv(t))
+
+	mc.send(t, "get mykey missing")
+	assert.Equal(t, "VALUE mykey 0 5", mc.recv(t))
+	assert.Equal(t, "hello", mc.recv(t))
+	assert.Equal(t, "END", mc.recv(t))
+
+	mc.send(t, "delete mykey")
+	assert.Equal(t, "DELETED", mc.recv(t))
+	mc.send(t, "delete mykey")
+	assert.Equal(t, "NOT_FOUND", mc.recv(t))
+}
+
+func TestIncrDecr(t *testing.T) {
+	mc := dial(t)
+	defer mc.c.Close()
+
+	mc.send(t, "set counter 0 0 2\r\n10")
+	assert.Equal(t, "STORED", mc.recv(t))
+
+	mc.send(t, "incr counter 5")
+	assert.Equal(t, "15", mc.recv(t))
+	mc.send(t, "decr counter 20")
+	assert.Equal(t, "0", mc.recv(t))
+
+	mc.send(t, "incr nosuchcounter 1")
+	assert.Equal(t, "NOT_FOUND", mc.recv(t))
+
+	mc.send(t, "set textkey 0 0 3\r\nabc")
+	assert.Equal(t, "STORED", mc.recv(t))
+	mc.send(t, "incr textkey 1")
+	assert.Equal(t, "CLIENT_ERROR cannot increment or decrement non-numeric value", mc.recv(t))
+}
+
+func TestTouch(t *testing.T) {
+	mc := dial(t)
+	defer mc.c.Close()
+
+	mc.send(t, "set touched 0 0 1\r\nx")
+	assert.Equal(t, "STORED", mc.recv(t))
+	mc.send(t, "touch touched 100")
+	assert.Equal(t, "TOUCHED", mc.recv(t))
+	mc.send(t, "touch nosuchkey 100")
+	assert.Equal(t, "NOT_FOUND", mc.recv(t))
+}
+
+func TestNoreplyAndErrors(t *testing.T) {
+	mc := dial(t)
+	defer mc.c.Close()
+
+	mc.send(t, "set quiet 0 0 2 noreply\r\nok")
+	mc.send(t, "get quiet")
+	assert.Equal(t, "VALUE quiet 0 2", mc.recv(t))
+	assert.Equal(t, "ok", mc.recv(t))
+	assert.Equal(t, "END", mc.recv(t))
+
+	mc.send(t, "bogus command")
+	assert.Equal(t, "ERROR", mc.recv(t))
+
+	mc.send(t, "set bad 0 0 notanumber")
+	assert.Equal(t, "CLIENT_ERROR bad command line format", mc.recv(t))
+
+	mc.send(t, "version")
+	assert.Equal(t, "VERSION titan", mc.recv(t))
+}
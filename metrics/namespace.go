@@ -0,0 +1,75 @@
+package metrics
+
+import (
+	"strings"
+	"sync"
+)
+
+// OtherNamespace is the label value that aggregates the namespaces
+// beyond the configured limit, it keeps the cardinality of the
+// namespace-labeled vectors bounded when many tenants share a titan
+const OtherNamespace = "other"
+
+var nsLabels = &namespaceLabels{}
+
+// namespaceLabels maps a client namespace to the label value used in
+// prometheus vectors, the first limit distinct namespaces (plus the
+// allowlisted ones) keep their own label and the rest share "other"
+type namespaceLabels struct {
+	mu    sync.RWMutex
+	limit int
+	allow map[string]struct{}
+	seen  map[string]struct{}
+}
+
+// SetupNamespaceLabels configures the namespace label mapper, limit 0
+// means every namespace is exposed individually, allowlist is a comma
+// separated list of namespaces that never fold into "other" and do not
+// consume the limit
+func SetupNamespaceLabels(limit int, allowlist string) {
+	allow := make(map[string]struct{})
+	for _, namespace := range strings.Split(allowlist, ",") {
+		if namespace = strings.TrimSpace(namespace); namespace != "" {
+			allow[namespace] = struct{}{}
+		}
+	}
+	nsLabels.mu.Lock()
+	nsLabels.limit = limit
+	nsLabels.allow = allow
+	nsLabels.seen = make(map[string]struct{})
+	nsLabels.mu.Unlock()
+}
+
+// NamespaceLabel returns the label value to use for a client namespace
+func NamespaceLabel(namespace string) string {
+	n := nsLabels
+	n.mu.RLock()
+	if n.limit <= 0 {
+		n.mu.RUnlock()
+		return namespace
+	}
+	if _, ok := n.allow[namespace]; ok {
+		n.mu.RUnlock()
+		return namespace
+	}
+	if _, ok := n.seen[namespace]; ok {
+		n.mu.RUnlock()
+		return namespace
+	}
+	full := len(n.seen) >= n.limit
+	n.mu.RUnlock()
+	if full {
+		return OtherNamespace
+	}
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if _, ok := n.seen[namespace]; ok {
+		return namespace
+	}
+	if len(n.seen) >= n.limit {
+		return OtherNamespace
+	}
+	n.seen[namespace] = struct{}{}
+	return namespace
+}
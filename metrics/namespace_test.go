@@ -0,0 +1,34 @@
+package metrics
+
+import "testing"
+
+func TestNamespaceLabel(t *testing.T) {
+	// unlimited by default, every namespace keeps its own label
+	SetupNamespaceLabels(0, "")
+	if got := NamespaceLabel("ns-a"); got != "ns-a" {
+		t.Fatalf("expected ns-a, got %s", got)
+	}
+
+	SetupNamespaceLabels(2, "vip")
+	if got := NamespaceLabel("ns-a"); got != "ns-a" {
+		t.Fatalf("expected ns-a, got %s", got)
+	}
+	if got := NamespaceLabel("ns-b"); got != "ns-b" {
+		t.Fatalf("expected ns-b, got %s", got)
+	}
+	// the limit is reached, a new namespace folds into other
+	if got := NamespaceLabel("ns-c"); got != OtherNamespace {
+		t.Fatalf("expected %s, got %s", OtherNamespace, got)
+	}
+	// already admitted namespaces keep their label
+	if got := NamespaceLabel("ns-a"); got != "ns-a" {
+		t.Fatalf("expected ns-a, got %s", got)
+	}
+	// allowlisted namespaces never fold into other
+	if got := NamespaceLabel("vip"); got != "vip" {
+		t.Fatalf("expected vip, got %s", got)
+	}
+
+	// restore the default for other tests
+	SetupNamespaceLabels(0, "")
+}
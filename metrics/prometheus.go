@@ -20,10 +20,14 @@ const (
 	ztinfo    = "ztinfo"
 	labelName = "level"
 	gckeys    = "gckeys"
+	reaper    = "reaper"
 	expire    = "expire"
 	tikvGC    = "tikvgc"
 	titanip   = "titanip"
 	worker    = "worker"
+	reason    = "reason"
+	objType   = "type"
+	class     = "class"
 )
 
 var (
@@ -34,10 +38,14 @@ var (
 	multiLabel   = []string{biz, command}
 	ztInfoLabel  = []string{ztinfo}
 	gcKeysLabel  = []string{gckeys}
+	reaperLabel  = []string{reaper}
 	expireLabel  = []string{expire}
 	tikvGCLabel  = []string{tikvGC}
 	limitLabel   = []string{biz, command, titanip}
 	workerLabel  = []string{worker}
+	reasonLabel  = []string{reason}
+	objTypeLabel = []string{objType}
+	sloLabel     = []string{biz, class}
 
 	// global prometheus object
 	gm *Metrics
@@ -46,7 +54,8 @@ var (
 // Metrics prometheus statistics
 type Metrics struct {
 	//biz
-	ConnectionOnlineGaugeVec *prometheus.GaugeVec
+	ConnectionOnlineGaugeVec     *prometheus.GaugeVec
+	ConnectionRejectedCounterVec *prometheus.CounterVec
 
 	//command
 	ZTInfoCounterVec      *prometheus.CounterVec
@@ -54,11 +63,25 @@ type Metrics struct {
 	ExpireDelaySecondsVec *prometheus.GaugeVec
 	LimiterQpsVec         *prometheus.GaugeVec
 	LimiterRateVec        *prometheus.GaugeVec
+	DBSizeKeysGaugeVec    *prometheus.GaugeVec
+	DBSizeDeltaGaugeVec   *prometheus.GaugeVec
+	LastCommitGauge       prometheus.Gauge
+	CommitErrStreakGauge  prometheus.Gauge
+	BeginErrStreakGauge   prometheus.Gauge
+	BreakerOpenGauge      prometheus.Gauge
+	BreakerFastFailTotal  prometheus.Counter
+	BackpressureActiveGauge   prometheus.Gauge
+	BackpressureShedCounterVec *prometheus.CounterVec
 	LRangeSeekHistogram   prometheus.Histogram
 	GCKeysCounterVec      *prometheus.CounterVec
+	ReaperKeysCounterVec  *prometheus.CounterVec
 
 	//expire
-	ExpireKeysTotal *prometheus.CounterVec
+	ExpireKeysTotal           *prometheus.CounterVec
+	ExpiredKeysByNamespaceVec *prometheus.CounterVec
+
+	//read repair
+	MetaLenDriftVec *prometheus.CounterVec
 
 	//tikvGC
 	TikvGCTotal *prometheus.CounterVec
@@ -71,10 +94,14 @@ type Metrics struct {
 	TxnCommitHistogramVec        *prometheus.HistogramVec
 	ReplyFuncDoneHistogramVec    *prometheus.HistogramVec
 	CommandArgsNumHistogramVec   *prometheus.HistogramVec
+	CommandMemoryHistogramVec    *prometheus.HistogramVec
 	TxnRetriesCounterVec         *prometheus.CounterVec
 	TxnConflictsCounterVec       *prometheus.CounterVec
 	TxnFailuresCounterVec        *prometheus.CounterVec
+	ReplyFailuresCounterVec      *prometheus.CounterVec
+	TypeMismatchCounterVec       *prometheus.CounterVec
 	MultiCommandHistogramVec     *prometheus.HistogramVec
+	SLOViolationCounterVec       *prometheus.CounterVec
 	WorkerRoundCostHistogramVec  *prometheus.HistogramVec
 	WorkerSeekCostHistogramVec   *prometheus.HistogramVec
 	WorkerCommitCostHistogramVec *prometheus.HistogramVec
@@ -121,6 +148,15 @@ func init() {
 		}, multiLabel)
 	prometheus.MustRegister(gm.CommandArgsNumHistogramVec)
 
+	gm.CommandMemoryHistogramVec = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "command_memory_bytes",
+			Buckets:   prometheus.ExponentialBuckets(64, 4, 16),
+			Help:      "The approximate bytes a command allocated for arguments, buffers and reply",
+		}, multiLabel)
+	prometheus.MustRegister(gm.CommandMemoryHistogramVec)
+
 	gm.LimitCostHistogramVec = prometheus.NewHistogramVec(
 		prometheus.HistogramOpts{
 			Namespace: namespace,
@@ -174,6 +210,22 @@ func init() {
 		}, multiLabel)
 	prometheus.MustRegister(gm.TxnFailuresCounterVec)
 
+	gm.ReplyFailuresCounterVec = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "reply_failures_total",
+			Help:      "The total of replies that failed to be written after commit",
+		}, commandLabel)
+	prometheus.MustRegister(gm.ReplyFailuresCounterVec)
+
+	gm.TypeMismatchCounterVec = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "type_mismatch_total",
+			Help:      "The total of accesses that hit an object of another type",
+		}, bizLabel)
+	prometheus.MustRegister(gm.TypeMismatchCounterVec)
+
 	gm.MultiCommandHistogramVec = prometheus.NewHistogramVec(
 		prometheus.HistogramOpts{
 			Namespace: namespace,
@@ -191,6 +243,14 @@ func init() {
 		}, bizLabel)
 	prometheus.MustRegister(gm.ConnectionOnlineGaugeVec)
 
+	gm.ConnectionRejectedCounterVec = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "connection_rejected_total",
+			Help:      "The number of connections rejected or throttled at accept",
+		}, reasonLabel)
+	prometheus.MustRegister(gm.ConnectionRejectedCounterVec)
+
 	gm.ExpireDelaySecondsVec = prometheus.NewGaugeVec(
 		prometheus.GaugeOpts{
 			Namespace: namespace,
@@ -215,6 +275,78 @@ func init() {
 		}, limitLabel)
 	prometheus.MustRegister(gm.LimiterRateVec)
 
+	gm.DBSizeKeysGaugeVec = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "dbsize_keys",
+			Help:      "the key count of a namespace found by the last dbsize reconcile pass",
+		}, bizLabel)
+	prometheus.MustRegister(gm.DBSizeKeysGaugeVec)
+
+	gm.DBSizeDeltaGaugeVec = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "dbsize_reconcile_delta",
+			Help:      "the counter correction applied by the last dbsize reconcile pass",
+		}, bizLabel)
+	prometheus.MustRegister(gm.DBSizeDeltaGaugeVec)
+
+	gm.LastCommitGauge = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "last_successful_commit_seconds",
+			Help:      "the unix timestamp of the last successful tikv commit of this node",
+		})
+	prometheus.MustRegister(gm.LastCommitGauge)
+
+	gm.CommitErrStreakGauge = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "commit_error_streak",
+			Help:      "the number of consecutive failed tikv commits of this node",
+		})
+	prometheus.MustRegister(gm.CommitErrStreakGauge)
+
+	gm.BeginErrStreakGauge = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "begin_error_streak",
+			Help:      "the number of consecutive failed transaction begins of this node, usually pd trouble",
+		})
+	prometheus.MustRegister(gm.BeginErrStreakGauge)
+
+	gm.BreakerOpenGauge = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "breaker_open",
+			Help:      "whether the tikv circuit breaker of this node is open",
+		})
+	prometheus.MustRegister(gm.BreakerOpenGauge)
+
+	gm.BreakerFastFailTotal = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "breaker_fast_fail_total",
+			Help:      "commands rejected with TRYAGAIN while the circuit breaker was open",
+		})
+	prometheus.MustRegister(gm.BreakerFastFailTotal)
+
+	gm.BackpressureActiveGauge = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "backpressure_active",
+			Help:      "whether this node is shedding write load on slow tikv commits",
+		})
+	prometheus.MustRegister(gm.BackpressureActiveGauge)
+
+	gm.BackpressureShedCounterVec = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "backpressure_shed_total",
+			Help:      "writes delayed by commit latency backpressure per namespace",
+		}, bizLabel)
+	prometheus.MustRegister(gm.BackpressureShedCounterVec)
+
 	gm.LRangeSeekHistogram = prometheus.NewHistogram(
 		prometheus.HistogramOpts{
 			Namespace: namespace,
@@ -232,6 +364,14 @@ func init() {
 		}, ztInfoLabel)
 	prometheus.MustRegister(gm.ZTInfoCounterVec)
 
+	gm.SLOViolationCounterVec = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "slo_violation_total",
+			Help:      "rolling p99 latency slo violations per namespace and command class",
+		}, sloLabel)
+	prometheus.MustRegister(gm.SLOViolationCounterVec)
+
 	gm.GCKeysCounterVec = prometheus.NewCounterVec(
 		prometheus.CounterOpts{
 			Namespace: namespace,
@@ -240,6 +380,14 @@ func init() {
 		}, gcKeysLabel)
 	prometheus.MustRegister(gm.GCKeysCounterVec)
 
+	gm.ReaperKeysCounterVec = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "reaper_keys_total",
+			Help:      "the number of data keys scanned or reaped by the orphan reaper",
+		}, reaperLabel)
+	prometheus.MustRegister(gm.ReaperKeysCounterVec)
+
 	gm.ExpireKeysTotal = prometheus.NewCounterVec(
 		prometheus.CounterOpts{
 			Namespace: namespace,
@@ -248,6 +396,22 @@ func init() {
 		}, expireLabel)
 	prometheus.MustRegister(gm.ExpireKeysTotal)
 
+	gm.MetaLenDriftVec = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "meta_len_drift_total",
+			Help:      "the number of drifted collection meta lengths found by read repair or quickcheck",
+		}, objTypeLabel)
+	prometheus.MustRegister(gm.MetaLenDriftVec)
+
+	gm.ExpiredKeysByNamespaceVec = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "expired_keys_biz_total",
+			Help:      "the number of keys expired per namespace",
+		}, bizLabel)
+	prometheus.MustRegister(gm.ExpiredKeysByNamespaceVec)
+
 	gm.TikvGCTotal = prometheus.NewCounterVec(
 		prometheus.CounterOpts{
 			Namespace: namespace,
@@ -21,6 +21,7 @@ type Server struct {
 
 //NewServer creat status server
 func NewServer(config *conf.Status) *Server {
+	SetupNamespaceLabels(config.MetricsNamespaceLimit, config.MetricsNamespaceAllowlist)
 	s := &Server{
 		addr:         config.Listen,
 		statusServer: &http.Server{Handler: http.DefaultServeMux},
@@ -0,0 +1,80 @@
+package server
+
+import (
+	"net"
+	"sync"
+
+	"golang.org/x/time/rate"
+
+	"github.com/distributedio/titan/conf"
+	"github.com/distributedio/titan/metrics"
+)
+
+// AcceptLimiter throttles the accept loop with token buckets so a
+// reconnect storm can not exhaust file descriptors or overwhelm the
+// auth path. A global bucket caps the overall accept rate and a per
+// source ip bucket caps a single misbehaving client fleet. Tracked ips
+// are evicted in fifo order once the cache is full
+type AcceptLimiter struct {
+	conf   *conf.AcceptLimit
+	global *rate.Limiter
+
+	lock  sync.Mutex
+	ips   map[string]*rate.Limiter
+	order []string
+}
+
+// NewAcceptLimiter creates an AcceptLimiter from config, it returns
+// nil if the accept limit is disabled
+func NewAcceptLimiter(config *conf.AcceptLimit) *AcceptLimiter {
+	if config.Disable {
+		return nil
+	}
+	l := &AcceptLimiter{
+		conf: config,
+		ips:  make(map[string]*rate.Limiter),
+	}
+	if config.Rate > 0 {
+		l.global = rate.NewLimiter(rate.Limit(config.Rate), config.Burst)
+	}
+	return l
+}
+
+// Allow reports whether a connection from remoteAddr may be accepted,
+// it consumes one token from the global bucket and one from the bucket
+// of the source ip, and counts the rejected accepts
+func (l *AcceptLimiter) Allow(remoteAddr string) bool {
+	if l.global != nil && !l.global.Allow() {
+		metrics.GetMetrics().ConnectionRejectedCounterVec.WithLabelValues("accept_rate").Inc()
+		return false
+	}
+	if l.conf.IPRate <= 0 {
+		return true
+	}
+
+	ip, _, err := net.SplitHostPort(remoteAddr)
+	if err != nil {
+		ip = remoteAddr
+	}
+	if !l.ipLimiter(ip).Allow() {
+		metrics.GetMetrics().ConnectionRejectedCounterVec.WithLabelValues("ip_rate").Inc()
+		return false
+	}
+	return true
+}
+
+func (l *AcceptLimiter) ipLimiter(ip string) *rate.Limiter {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	if limiter, ok := l.ips[ip]; ok {
+		return limiter
+	}
+	if l.conf.IPCacheSize > 0 && len(l.ips) >= l.conf.IPCacheSize {
+		delete(l.ips, l.order[0])
+		l.order = l.order[1:]
+	}
+	limiter := rate.NewLimiter(rate.Limit(l.conf.IPRate), l.conf.IPBurst)
+	l.ips[ip] = limiter
+	l.order = append(l.order, ip)
+	return limiter
+}
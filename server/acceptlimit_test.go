@@ -0,0 +1,37 @@
+package server
+
+import (
+	"testing"
+
+	"github.com/distributedio/titan/conf"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewAcceptLimiterDisabled(t *testing.T) {
+	limiter := NewAcceptLimiter(&conf.AcceptLimit{Disable: true})
+	assert.Nil(t, limiter)
+}
+
+func TestAcceptLimiterGlobal(t *testing.T) {
+	limiter := NewAcceptLimiter(&conf.AcceptLimit{Rate: 1, Burst: 2})
+	assert.True(t, limiter.Allow("1.2.3.4:1000"))
+	assert.True(t, limiter.Allow("1.2.3.5:1000"))
+	// the burst is drained, the next accept is throttled
+	assert.False(t, limiter.Allow("1.2.3.6:1000"))
+}
+
+func TestAcceptLimiterPerIP(t *testing.T) {
+	limiter := NewAcceptLimiter(&conf.AcceptLimit{IPRate: 1, IPBurst: 1, IPCacheSize: 16})
+	assert.True(t, limiter.Allow("1.2.3.4:1000"))
+	// the same source ip is throttled, others are not
+	assert.False(t, limiter.Allow("1.2.3.4:1001"))
+	assert.True(t, limiter.Allow("1.2.3.5:1000"))
+}
+
+func TestAcceptLimiterIPEviction(t *testing.T) {
+	limiter := NewAcceptLimiter(&conf.AcceptLimit{IPRate: 1, IPBurst: 1, IPCacheSize: 1})
+	assert.True(t, limiter.Allow("1.2.3.4:1000"))
+	// tracking 1.2.3.5 evicts 1.2.3.4, whose bucket starts afresh
+	assert.True(t, limiter.Allow("1.2.3.5:1000"))
+	assert.True(t, limiter.Allow("1.2.3.4:1001"))
+}
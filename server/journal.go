@@ -0,0 +1,125 @@
+package server
+
+import (
+	"fmt"
+	"os"
+	ospath "path"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/distributedio/titan/conf"
+	"go.uber.org/zap"
+)
+
+// journalBufferSize bounds the entries queued for the flusher, recording
+// never blocks the command path, entries beyond it are dropped
+const journalBufferSize = 1024
+
+// Journal keeps a size-bounded trail of the write commands a node accepted,
+// appended asynchronously to a local file. After a crash the tail of the
+// file tells an operator what the node was doing, without the cost or the
+// ordering guarantees of a real write-ahead log
+type Journal struct {
+	cfg     *conf.Journal
+	entries chan string
+	done    chan struct{}
+	flushed chan struct{}
+	once    sync.Once
+}
+
+// NewJournal starts the journal flusher, it returns nil when the journal
+// is disabled
+func NewJournal(cfg *conf.Journal) *Journal {
+	if cfg.Disable {
+		return nil
+	}
+	j := &Journal{
+		cfg:     cfg,
+		entries: make(chan string, journalBufferSize),
+		done:    make(chan struct{}),
+		flushed: make(chan struct{}),
+	}
+	go j.run()
+	return j
+}
+
+// Record queues one write command for the flusher, it never blocks and
+// drops the entry when the flusher is behind
+func (j *Journal) Record(namespace string, name string, args []string) {
+	line := fmt.Sprintf("%d %s %s %s\n", time.Now().UnixNano(), namespace, name, strings.Join(args, " "))
+	select {
+	case j.entries <- line:
+	default:
+	}
+}
+
+// Close drains the queued entries, syncs the file and stops the flusher.
+// It is part of the shutdown path and safe to call more than once
+func (j *Journal) Close() {
+	j.once.Do(func() {
+		close(j.done)
+	})
+	<-j.flushed
+}
+
+func (j *Journal) open() (*os.File, error) {
+	if dir := ospath.Dir(j.cfg.Path); dir != "." {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return nil, err
+		}
+	}
+	return os.OpenFile(j.cfg.Path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+}
+
+func (j *Journal) run() {
+	defer close(j.flushed)
+	file, err := j.open()
+	if err != nil {
+		zap.L().Error("[Journal] open journal file failed", zap.String("path", j.cfg.Path), zap.Error(err))
+		return
+	}
+	count := 0
+	write := func(line string) {
+		if _, err := file.WriteString(line); err != nil {
+			zap.L().Error("[Journal] append failed", zap.String("path", j.cfg.Path), zap.Error(err))
+			return
+		}
+		count++
+		if j.cfg.MaxEntries > 0 && count >= j.cfg.MaxEntries {
+			// rotate once, the previous generation stays around as .old
+			file.Close()
+			if err := os.Rename(j.cfg.Path, j.cfg.Path+".old"); err != nil {
+				zap.L().Error("[Journal] rotate failed", zap.String("path", j.cfg.Path), zap.Error(err))
+			}
+			if file, err = j.open(); err != nil {
+				zap.L().Error("[Journal] reopen journal file failed", zap.String("path", j.cfg.Path), zap.Error(err))
+				file = nil
+			}
+			count = 0
+		}
+	}
+	for {
+		select {
+		case line := <-j.entries:
+			if file != nil {
+				write(line)
+			}
+		case <-j.done:
+			for {
+				select {
+				case line := <-j.entries:
+					if file != nil {
+						write(line)
+					}
+				default:
+					if file != nil {
+						file.Sync()
+						file.Close()
+					}
+					return
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,67 @@
+package server
+
+import (
+	"net"
+
+	"github.com/distributedio/continuous"
+	"go.uber.org/zap"
+)
+
+// Protocol is a server speaking one wire protocol on its own listener,
+// it matches the continuous.Continuous interface so RESP, memcached,
+// the admin HTTP status server and future protocols such as gRPC all
+// register the same way
+type Protocol interface {
+	Serve(lis net.Listener) error
+	Stop() error
+	GracefulStop() error
+}
+
+// Manager hosts every protocol listener of a titan process on a single
+// continuous instance, so all ports share one lifecycle: one pid file,
+// one graceful drain on upgrade and a uniform way of enabling TLS per
+// port, instead of each subsystem managing its own net.Listener
+type Manager struct {
+	cont *continuous.Cont
+}
+
+// NewManager creates a listener manager, opts are passed through to
+// continuous
+func NewManager(opts ...continuous.Option) *Manager {
+	return &Manager{cont: continuous.New(opts...)}
+}
+
+// Register adds a protocol server listening on addr. TLS is enabled on
+// the port when both certFile and keyFile are set
+func (m *Manager) Register(name string, srv Protocol, addr string, certFile, keyFile string) error {
+	var opts []continuous.ServerOption
+	if certFile != "" && keyFile != "" {
+		tlsConfig, err := TLSConfig(certFile, keyFile)
+		if err != nil {
+			return err
+		}
+		opts = append(opts, continuous.TLSConfig(tlsConfig))
+	}
+	if err := m.cont.AddServer(srv, &continuous.ListenOn{Network: "tcp", Address: addr}, opts...); err != nil {
+		return err
+	}
+	zap.L().Info("listener registered", zap.String("name", name), zap.String("addr", addr),
+		zap.Bool("tls", certFile != "" && keyFile != ""))
+	return nil
+}
+
+// Serve opens all registered listeners and serves until the first
+// server fails or the process is asked to stop
+func (m *Manager) Serve() error {
+	return m.cont.Serve()
+}
+
+// Stop closes all listeners at once
+func (m *Manager) Stop() error {
+	return m.cont.Stop()
+}
+
+// GracefulStop drains all servers before closing the listeners
+func (m *Manager) GracefulStop() error {
+	return m.cont.GracefulStop()
+}
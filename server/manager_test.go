@@ -0,0 +1,41 @@
+package server
+
+import (
+	"io/ioutil"
+	"net"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type nopProtocol struct{}
+
+func (p *nopProtocol) Serve(lis net.Listener) error { return nil }
+func (p *nopProtocol) Stop() error                  { return nil }
+func (p *nopProtocol) GracefulStop() error          { return nil }
+
+func TestManagerRegister(t *testing.T) {
+	mgr := NewManager()
+
+	// a plain port binds at registration time
+	assert.NoError(t, mgr.Register("nop", &nopProtocol{}, "127.0.0.1:0", "", ""))
+
+	// a TLS port needs a loadable key pair
+	assert.Error(t, mgr.Register("tls", &nopProtocol{}, "127.0.0.1:0", "no-such-cert", "no-such-key"))
+
+	certFile, err := ioutil.TempFile("", "cert")
+	assert.NoError(t, err)
+	defer os.Remove(certFile.Name())
+	_, err = certFile.WriteString(tlsCert)
+	assert.NoError(t, err)
+
+	keyFile, err := ioutil.TempFile("", "key")
+	assert.NoError(t, err)
+	defer os.Remove(keyFile.Name())
+	_, err = keyFile.WriteString(tlsKey)
+	assert.NoError(t, err)
+
+	assert.NoError(t, mgr.Register("tls", &nopProtocol{}, "127.0.0.1:0", certFile.Name(), keyFile.Name()))
+	assert.NoError(t, mgr.Stop())
+}
@@ -7,6 +7,7 @@ usage_exit()
 	echo "or"
 	echo "<hostportconfigpath> del qps=(1|0) cmd=<cmd> namespace=<namespace>"
 	echo "<namespace>: all means matching all namespaces"
+	echo "the token in the config must authenticate into \$sys.admin"
 	exit 1
 }
 if [ $# -lt 5 ]; then
@@ -80,10 +81,10 @@ else
 		if [ -z "$limit" -o -z "$burst" ]; then
 			usage_exit
 		else
-			$rediscli -h $host -p $port -a $token set $key "${limit} ${burst}"
+			$rediscli -h $host -p $port -a $token limit set $key $limit $burst
 		fi
 	elif [ "$op" = "del" ]; then
-		$rediscli -h $host -p $port -a $token del $key
+		$rediscli -h $host -p $port -a $token limit del $key
 	else
 		usage_exit
 	fi
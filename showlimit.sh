@@ -2,10 +2,12 @@
 
 usage_exit()
 {
-	echo "usage: <hostportconfigpath>"
+	echo "usage: <hostportconfigpath> <rule> [<rule> ...]"
+	echo "<rule>: (qps|rate):<namespace>@<cmd>, <namespace> may be * to match all"
+	echo "the token in the config must authenticate into \$sys.admin"
 	exit 1
 }
-if [ $# -lt 1 ]; then
+if [ $# -lt 2 ]; then
 	usage_exit
 fi
 
@@ -15,13 +17,9 @@ port=`grep port= $configpath|sed 's/port=//'`
 rediscli=`grep rediscli= $configpath|sed 's/rediscli=//'`
 token=`grep token= $configpath|sed 's/token=//'`
 
-for prefix in qps: rate: limiter_status:
+shift
+for rule in $*
 do
-	echo "-------------------------- $prefix data --------------------------"
-	$rediscli -h $host -p $port -a $token scan 0 count 256 match ${prefix}*|sed '1d'|grep -v '^$'|while read key
-	do 
-		value=`$rediscli -h $host -p $port -a $token get $key`
-		echo -e "key=${key}\tvalue=$value"
-	done
-	echo
+	value=`$rediscli -h $host -p $port -a $token limit get $rule`
+	echo -e "rule=${rule}\tvalue=$value"
 done
@@ -15,12 +15,22 @@ type Server struct {
 	servCtx *context.ServerContext
 	lis     net.Listener
 	idgen   func() int64
+	loop    *eventLoop
 }
 
 //New a server instance
 func New(ctx *context.ServerContext) *Server {
 	// id generator starts from 1(the first client's id is 2, the same as redis)
-	return &Server{servCtx: ctx, idgen: GetClientID()}
+	s := &Server{servCtx: ctx, idgen: GetClientID()}
+	if !ctx.EventLoop.Disable {
+		loop, err := newEventLoop(s, ctx.EventLoop.Workers)
+		if err != nil {
+			zap.L().Warn("event loop unavailable, falling back to a goroutine per connection", zap.Error(err))
+		} else {
+			s.loop = loop
+		}
+	}
+	return s
 }
 
 //Serve the redis requests
@@ -35,6 +45,13 @@ func (s *Server) Serve(lis net.Listener) error {
 			return err
 		}
 
+		if s.servCtx.AcceptLimiter != nil && !s.servCtx.AcceptLimiter.Allow(conn.RemoteAddr().String()) {
+			zap.L().Warn("accept throttled, close connection",
+				zap.String("addr", conn.RemoteAddr().String()))
+			conn.Close()
+			continue
+		}
+
 		cliCtx := context.NewClientContext(s.idgen(), conn)
 		if s.servCtx.LimitConnection {
 			connectExceed := false
@@ -44,6 +61,7 @@ func (s *Server) Serve(lis net.Listener) error {
 			}
 			s.servCtx.Lock.Unlock()
 			if connectExceed {
+				metrics.GetMetrics().ConnectionRejectedCounterVec.WithLabelValues("max_connection").Inc()
 				zap.L().Warn("max connection exceed, will close after some time",
 					zap.Int64("max connection num", s.servCtx.MaxConnection), zap.Int64("wait ms", s.servCtx.MaxConnectionWait),
 					zap.String("addr", cliCtx.RemoteAddr), zap.Int64("clientid", cliCtx.ID))
@@ -68,23 +86,43 @@ func (s *Server) Serve(lis net.Listener) error {
 		zap.L().Info("recv connection", zap.String("addr", cliCtx.RemoteAddr),
 			zap.Int64("clientid", cliCtx.ID), zap.String("namespace", cliCtx.Namespace))
 
+		metrics.GetMetrics().ConnectionOnlineGaugeVec.WithLabelValues(metrics.NamespaceLabel(cli.cliCtx.Namespace)).Inc()
+
+		// the event loop parks the connection without a goroutine until
+		// its first bytes arrive
+		if s.loop != nil {
+			if err := s.loop.watch(cli, conn); err == nil {
+				continue
+			} else {
+				zap.L().Warn("event loop watch failed, serving with a goroutine",
+					zap.Int64("clientid", cliCtx.ID), zap.Error(err))
+			}
+		}
+
 		go func(cli *client, conn net.Conn) {
-			metrics.GetMetrics().ConnectionOnlineGaugeVec.WithLabelValues(cli.cliCtx.Namespace).Inc()
 			if err := cli.serve(conn); err != nil {
 				zap.L().Error("serve conn failed", zap.String("addr", cli.cliCtx.RemoteAddr),
-					zap.Int64("clientid", cliCtx.ID), zap.String("namespace", cli.cliCtx.Namespace), zap.Error(err))
-			}
-			metrics.GetMetrics().ConnectionOnlineGaugeVec.WithLabelValues(cli.cliCtx.Namespace).Dec()
-			s.servCtx.Clients.Delete(cli.cliCtx.ID)
-			if s.servCtx.LimitConnection {
-				s.servCtx.Lock.Lock()
-				s.servCtx.ClientsNum--
-				s.servCtx.Lock.Unlock()
+					zap.Int64("clientid", cli.cliCtx.ID), zap.String("namespace", cli.cliCtx.Namespace), zap.Error(err))
 			}
+			s.closeClient(cli)
 		}(cli, conn)
 	}
 }
 
+//closeClient finalizes a finished connection, shared by the goroutine
+//and the event loop frontends
+func (s *Server) closeClient(cli *client) {
+	metrics.GetMetrics().ConnectionOnlineGaugeVec.WithLabelValues(metrics.NamespaceLabel(cli.cliCtx.Namespace)).Dec()
+	cli.cliCtx.InvalidateReadTxn()
+	cli.cliCtx.AbortExplicitTxn()
+	s.servCtx.Clients.Delete(cli.cliCtx.ID)
+	if s.servCtx.LimitConnection {
+		s.servCtx.Lock.Lock()
+		s.servCtx.ClientsNum--
+		s.servCtx.Lock.Unlock()
+	}
+}
+
 // ListenAndServe serves on a specified address
 func (s *Server) ListenAndServe(addr string) error {
 	lis, err := net.Listen("tcp", addr)
@@ -97,11 +135,17 @@ func (s *Server) ListenAndServe(addr string) error {
 //Stop the server
 func (s *Server) Stop() error {
 	zap.L().Info("titan serve stop", zap.String("addr", s.lis.Addr().String()))
+	if s.servCtx.Journal != nil {
+		s.servCtx.Journal.Close()
+	}
 	return s.lis.Close()
 }
 
 //GracefulStop the server, TODO close clients connections first
 func (s *Server) GracefulStop() error {
 	zap.L().Info("titan serve graceful", zap.String("addr", s.lis.Addr().String()))
+	if s.servCtx.Journal != nil {
+		s.servCtx.Journal.Close()
+	}
 	return s.lis.Close()
 }
@@ -171,7 +171,8 @@ func (an *Abnormal) ZSetCase(t *testing.T) {
 
 	an.ez.ZScoreEqualErr(t, "ERR wrong number of arguments for 'zscore' command")
 	an.ez.ZScoreEqualErr(t, "ERR wrong number of arguments for 'zscore' command", "set")
-	an.ez.ZScoreEqualErr(t, "ERR wrong number of arguments for 'zscore' command", "set", "m1", "m2")
+	an.ez.ZScoreEqualErr(t, "ERR syntax error", "set", "m1", "m2")
+	an.ez.ZScoreEqualErr(t, "ERR wrong number of arguments for 'zscore' command", "set", "m1", "WITHPAYLOAD", "m2")
 	an.ez.ZScoreEqualErr(t, "WRONGTYPE Operation against a key holding the wrong kind of value", "set", "m1")
 
 	an.ez.ZRemEqualErr(t, "ERR wrong number of arguments for 'zrem' command")
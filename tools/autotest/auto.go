@@ -2,6 +2,7 @@ package autotest
 
 import (
 	"fmt"
+	"github.com/distributedio/titan/command"
 	"github.com/distributedio/titan/tools/autotest/cmd"
 	"github.com/gomodule/redigo/redis"
 	"github.com/stretchr/testify/assert"
@@ -60,7 +61,13 @@ func (ac *AutoClient) Start(addr string) {
 	if err != nil {
 		panic(err)
 	}
-	_, err = redis.String(limitConn.Do("auth", "sys_ratelimit-1574130304-1-36c153b109ebca80b43769"))
+	// limit rules are managed through the LIMIT command, which is reserved
+	// for connections authenticated into $sys.admin
+	adminToken, err := command.Token([]byte("titan"), []byte("$sys.admin"), time.Now().Unix())
+	if err != nil {
+		panic(err)
+	}
+	_, err = redis.String(limitConn.Do("auth", string(adminToken)))
 	if err != nil {
 		panic(err)
 	}
@@ -81,16 +88,28 @@ func (ac *AutoClient) Close() {
 }
 
 func (ac *AutoClient) setLimit(t *testing.T, key string, value string) {
-	reply, err := redis.String(ac.limitConn.Do("SET", key, value))
+	args := []interface{}{"SET", key}
+	fields := strings.Fields(value)
+	for _, field := range fields {
+		args = append(args, field)
+	}
+	reply, err := redis.String(ac.limitConn.Do("LIMIT", args...))
+	if len(fields) != 2 {
+		// LIMIT SET takes limit and burst separately, a value missing one
+		// of them is rejected instead of stored
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "wrong number of arguments")
+		return
+	}
 	assert.Equal(t, "OK", reply)
 	assert.NoError(t, err)
-	data, err := redis.Bytes(ac.limitConn.Do("GET", key))
+	data, err := redis.Bytes(ac.limitConn.Do("LIMIT", "GET", key))
 	assert.Equal(t, value, string(data))
 	assert.NoError(t, err)
 }
 
 func (ac *AutoClient) delLimit(t *testing.T, expectReply int, key string) {
-	reply, err := redis.Int(ac.limitConn.Do("DEL", key))
+	reply, err := redis.Int(ac.limitConn.Do("LIMIT", "DEL", key))
 	assert.Equal(t, expectReply, reply)
 	assert.NoError(t, err)
 }
@@ -178,29 +197,29 @@ func (ac *AutoClient) ZSetCase(t *testing.T) {
 	ac.ez.ZAddEqual(t, "key-zset", "2.0", "member11", "2.05", "member6")
 	ac.ez.ZRangeEqual(t, "key-zset", 0, -1, true)
 
-	ac.ez.ZRangeByScoreEqual(t, "key-zset", "-inf", "+inf", true, "", "member4 -3.5 member5 0 member2 1.5 member1 2 member11 2 member6 2.05 member3 3.6")
-	ac.ez.ZRangeByScoreEqual(t, "key-zset", "(-inf", "+inf", true, "", "member4 -3.5 member5 0 member2 1.5 member1 2 member11 2 member6 2.05 member3 3.6")
-	ac.ez.ZRangeByScoreEqual(t, "key-zset", "-inf", "(+inf", true, "", "member4 -3.5 member5 0 member2 1.5 member1 2 member11 2 member6 2.05 member3 3.6")
-	ac.ez.ZRangeByScoreEqual(t, "key-zset", "-inf", "inf", true, "", "member4 -3.5 member5 0 member2 1.5 member1 2 member11 2 member6 2.05 member3 3.6")
+	ac.ez.ZRangeByScoreEqual(t, "key-zset", "-inf", "+inf", true, "", "member4 -3.5 member5 0 member2 1.5 member1 2 member11 2 member6 2.0499999999999998 member3 3.6000000000000001")
+	ac.ez.ZRangeByScoreEqual(t, "key-zset", "(-inf", "+inf", true, "", "member4 -3.5 member5 0 member2 1.5 member1 2 member11 2 member6 2.0499999999999998 member3 3.6000000000000001")
+	ac.ez.ZRangeByScoreEqual(t, "key-zset", "-inf", "(+inf", true, "", "member4 -3.5 member5 0 member2 1.5 member1 2 member11 2 member6 2.0499999999999998 member3 3.6000000000000001")
+	ac.ez.ZRangeByScoreEqual(t, "key-zset", "-inf", "inf", true, "", "member4 -3.5 member5 0 member2 1.5 member1 2 member11 2 member6 2.0499999999999998 member3 3.6000000000000001")
 	ac.ez.ZRangeByScoreEqual(t, "key-zset", "-inf", "inf", false, "", "member4 member5 member2 member1 member11 member6 member3")
-	ac.ez.ZRangeByScoreEqual(t, "key-zset", "-3.5", "inf", true, "", "member4 -3.5 member5 0 member2 1.5 member1 2 member11 2 member6 2.05 member3 3.6")
-	ac.ez.ZRangeByScoreEqual(t, "key-zset", "(-3.5", "inf", true, "", "member5 0 member2 1.5 member1 2 member11 2 member6 2.05 member3 3.6")
-	ac.ez.ZRangeByScoreEqual(t, "key-zset", "0.0", "inf", true, "", "member5 0 member2 1.5 member1 2 member11 2 member6 2.05 member3 3.6")
-	ac.ez.ZRangeByScoreEqual(t, "key-zset", "(0.0", "inf", true, "", "member2 1.5 member1 2 member11 2 member6 2.05 member3 3.6")
-	ac.ez.ZRangeByScoreEqual(t, "key-zset", "(0.0", "3.6", true, "", "member2 1.5 member1 2 member11 2 member6 2.05 member3 3.6")
-	ac.ez.ZRangeByScoreEqual(t, "key-zset", "(0.0", "+3.6", true, "", "member2 1.5 member1 2 member11 2 member6 2.05 member3 3.6")
-	ac.ez.ZRangeByScoreEqual(t, "key-zset", "(0.0", "(3.6", true, "", "member2 1.5 member1 2 member11 2 member6 2.05")
-	ac.ez.ZRangeByScoreEqual(t, "key-zset", "(0.0", "2.05", true, "", "member2 1.5 member1 2 member11 2 member6 2.05")
+	ac.ez.ZRangeByScoreEqual(t, "key-zset", "-3.5", "inf", true, "", "member4 -3.5 member5 0 member2 1.5 member1 2 member11 2 member6 2.0499999999999998 member3 3.6000000000000001")
+	ac.ez.ZRangeByScoreEqual(t, "key-zset", "(-3.5", "inf", true, "", "member5 0 member2 1.5 member1 2 member11 2 member6 2.0499999999999998 member3 3.6000000000000001")
+	ac.ez.ZRangeByScoreEqual(t, "key-zset", "0.0", "inf", true, "", "member5 0 member2 1.5 member1 2 member11 2 member6 2.0499999999999998 member3 3.6000000000000001")
+	ac.ez.ZRangeByScoreEqual(t, "key-zset", "(0.0", "inf", true, "", "member2 1.5 member1 2 member11 2 member6 2.0499999999999998 member3 3.6000000000000001")
+	ac.ez.ZRangeByScoreEqual(t, "key-zset", "(0.0", "3.6", true, "", "member2 1.5 member1 2 member11 2 member6 2.0499999999999998 member3 3.6000000000000001")
+	ac.ez.ZRangeByScoreEqual(t, "key-zset", "(0.0", "+3.6", true, "", "member2 1.5 member1 2 member11 2 member6 2.0499999999999998 member3 3.6000000000000001")
+	ac.ez.ZRangeByScoreEqual(t, "key-zset", "(0.0", "(3.6", true, "", "member2 1.5 member1 2 member11 2 member6 2.0499999999999998")
+	ac.ez.ZRangeByScoreEqual(t, "key-zset", "(0.0", "2.05", true, "", "member2 1.5 member1 2 member11 2 member6 2.0499999999999998")
 	ac.ez.ZRangeByScoreEqual(t, "key-zset", "(0.0", "2.05", true, "LIMIT -1 1", "")
-	ac.ez.ZRangeByScoreEqual(t, "key-zset", "(0.0", "2.05", true, "limit 0 -1", "member2 1.5 member1 2 member11 2 member6 2.05")
+	ac.ez.ZRangeByScoreEqual(t, "key-zset", "(0.0", "2.05", true, "limit 0 -1", "member2 1.5 member1 2 member11 2 member6 2.0499999999999998")
 	ac.ez.ZRangeByScoreEqual(t, "key-zset", "(0.0", "2.05", true, "LIMIT 0 0", "")
 	ac.ez.ZRangeByScoreEqual(t, "key-zset", "(0.0", "2.05", true, "LIMIT 0 2", "member2 1.5 member1 2")
-	ac.ez.ZRangeByScoreEqual(t, "key-zset", "(0.0", "2.05", true, "LIMIT 0 4", "member2 1.5 member1 2 member11 2 member6 2.05")
-	ac.ez.ZRangeByScoreEqual(t, "key-zset", "(0.0", "2.05", true, "LIMIT 0 5", "member2 1.5 member1 2 member11 2 member6 2.05")
+	ac.ez.ZRangeByScoreEqual(t, "key-zset", "(0.0", "2.05", true, "LIMIT 0 4", "member2 1.5 member1 2 member11 2 member6 2.0499999999999998")
+	ac.ez.ZRangeByScoreEqual(t, "key-zset", "(0.0", "2.05", true, "LIMIT 0 5", "member2 1.5 member1 2 member11 2 member6 2.0499999999999998")
 	ac.ez.ZRangeByScoreEqual(t, "key-zset", "(0.0", "2.05", true, "LIMIT 1 2", "member1 2 member11 2")
-	ac.ez.ZRangeByScoreEqual(t, "key-zset", "(0.0", "2.05", true, "LIMIT 3 2", "member6 2.05")
+	ac.ez.ZRangeByScoreEqual(t, "key-zset", "(0.0", "2.05", true, "LIMIT 3 2", "member6 2.0499999999999998")
 	ac.ez.ZRangeByScoreEqual(t, "key-zset", "(0.0", "2.05", true, "LIMIT 4 2", "")
-	ac.ez.ZRangeByScoreEqual(t, "key-zset", "(2", "3.6", true, "", "member6 2.05 member3 3.6")
+	ac.ez.ZRangeByScoreEqual(t, "key-zset", "(2", "3.6", true, "", "member6 2.0499999999999998 member3 3.6000000000000001")
 	ac.ez.ZRangeByScoreEqual(t, "key-zset", "0", "(2", true, "", "member5 0 member2 1.5")
 
 	ac.ez.ZRemEqual(t, "key-zset", "member2", "member1", "member3", "member4", "member1")
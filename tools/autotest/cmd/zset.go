@@ -6,6 +6,7 @@ import (
 	"strings"
 	"testing"
 
+	"github.com/distributedio/titan/db"
 	"github.com/gomodule/redigo/redis"
 	"github.com/stretchr/testify/assert"
 )
@@ -252,7 +253,7 @@ func (ez *ExampleZSet) ZScoreEqual(t *testing.T, key string, member string) {
 		return
 	}
 
-	val := strconv.FormatFloat(score, 'f', -1, 64)
+	val := db.FormatScore(score)
 	assert.Equal(t, val, reply)
 	assert.Nil(t, err)
 }
@@ -292,7 +293,7 @@ func getAllOutput(msmap map[string]float64, positiveOrder bool, withScore bool)
 		for _, member := range members {
 			fullOutput = append(fullOutput, member)
 			if withScore {
-				val := strconv.FormatFloat(score, 'f', -1, 64)
+				val := db.FormatScore(score)
 				fullOutput = append(fullOutput, val)
 			}
 		}
@@ -0,0 +1,226 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"math/rand"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+)
+
+var (
+	addr      string
+	auth      string
+	clients   int
+	requests  int
+	pipeline  int
+	valueSize int
+	keyspace  int
+	dist      string
+	mix       string
+)
+
+// workload is one command of the mix with its relative weight
+type workload struct {
+	name   string
+	weight int
+}
+
+func main() {
+	flag.StringVar(&addr, "addr", "127.0.0.1:7369", "server address")
+	flag.StringVar(&auth, "auth", "", "auth token")
+	flag.IntVar(&clients, "clients", 16, "number of concurrent connections")
+	flag.IntVar(&requests, "requests", 100000, "total number of requests")
+	flag.IntVar(&pipeline, "pipeline", 1, "pipeline depth")
+	flag.IntVar(&valueSize, "value-size", 64, "value size in bytes")
+	flag.IntVar(&keyspace, "keys", 10000, "number of distinct keys")
+	flag.StringVar(&dist, "dist", "uniform", "key distribution, uniform or zipf")
+	flag.StringVar(&mix, "mix", "set=1,get=4", "command mix with weights, e.g. set=1,get=4,hset=1")
+	flag.Parse()
+
+	mixes, err := parseMix(mix)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	if pipeline < 1 {
+		pipeline = 1
+	}
+	if clients < 1 {
+		clients = 1
+	}
+
+	var wg sync.WaitGroup
+	lats := make([][]time.Duration, clients)
+	errs := make([]error, clients)
+	start := time.Now()
+	for i := 0; i < clients; i++ {
+		wg.Add(1)
+		n := requests / clients
+		if i < requests%clients {
+			n++
+		}
+		go func(i, n int) {
+			defer wg.Done()
+			lats[i], errs[i] = runClient(i, n, mixes)
+		}(i, n)
+	}
+	wg.Wait()
+	elapsed := time.Since(start)
+
+	var all []time.Duration
+	for i := range lats {
+		if errs[i] != nil {
+			fmt.Printf("client %d: %s\n", i, errs[i])
+			os.Exit(1)
+		}
+		all = append(all, lats[i]...)
+	}
+	report(all, elapsed)
+}
+
+// parseMix turns "set=1,get=4" into a weighted workload list
+func parseMix(s string) ([]workload, error) {
+	var mixes []workload
+	for _, part := range strings.Split(s, ",") {
+		kv := strings.SplitN(strings.TrimSpace(part), "=", 2)
+		w := 1
+		if len(kv) == 2 {
+			var err error
+			if w, err = strconv.Atoi(kv[1]); err != nil || w < 1 {
+				return nil, fmt.Errorf("invalid weight in mix %q", part)
+			}
+		}
+		switch strings.ToLower(kv[0]) {
+		case "set", "get", "incr", "lpush", "sadd", "hset", "zadd":
+		default:
+			return nil, fmt.Errorf("unsupported command in mix %q", kv[0])
+		}
+		for i := 0; i < w; i++ {
+			mixes = append(mixes, workload{name: strings.ToLower(kv[0])})
+		}
+	}
+	if len(mixes) == 0 {
+		return nil, fmt.Errorf("empty command mix")
+	}
+	return mixes, nil
+}
+
+// keyGen picks keys following the configured distribution
+type keyGen struct {
+	rnd  *rand.Rand
+	zipf *rand.Zipf
+}
+
+func newKeyGen(seed int64) *keyGen {
+	g := &keyGen{rnd: rand.New(rand.NewSource(seed))}
+	if dist == "zipf" {
+		g.zipf = rand.NewZipf(g.rnd, 1.1, 1, uint64(keyspace-1))
+	}
+	return g
+}
+
+func (g *keyGen) next() string {
+	var n uint64
+	if g.zipf != nil {
+		n = g.zipf.Uint64()
+	} else {
+		n = uint64(g.rnd.Intn(keyspace))
+	}
+	return "bench:" + strconv.FormatUint(n, 10)
+}
+
+// runClient sends n requests over one connection in pipeline sized
+// batches and records the latency of every request
+func runClient(id, n int, mixes []workload) ([]time.Duration, error) {
+	conn, err := redis.Dial("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	if auth != "" {
+		if _, err := conn.Do("AUTH", auth); err != nil {
+			return nil, err
+		}
+	}
+
+	gen := newKeyGen(int64(id) + time.Now().UnixNano())
+	value := strings.Repeat("x", valueSize)
+	lats := make([]time.Duration, 0, n)
+	for n > 0 {
+		batch := pipeline
+		if batch > n {
+			batch = n
+		}
+		start := time.Now()
+		for i := 0; i < batch; i++ {
+			w := mixes[gen.rnd.Intn(len(mixes))]
+			key := gen.next()
+			switch w.name {
+			case "set":
+				err = conn.Send("SET", key, value)
+			case "get":
+				err = conn.Send("GET", key)
+			case "incr":
+				err = conn.Send("INCR", key+":ctr")
+			case "lpush":
+				err = conn.Send("LPUSH", key+":l", value)
+			case "sadd":
+				err = conn.Send("SADD", key+":s", value)
+			case "hset":
+				err = conn.Send("HSET", key+":h", gen.next(), value)
+			case "zadd":
+				err = conn.Send("ZADD", key+":z", gen.rnd.Intn(keyspace), value)
+			}
+			if err != nil {
+				return nil, err
+			}
+		}
+		if err := conn.Flush(); err != nil {
+			return nil, err
+		}
+		for i := 0; i < batch; i++ {
+			if _, err := conn.Receive(); err != nil {
+				return nil, err
+			}
+		}
+		cost := time.Since(start)
+		for i := 0; i < batch; i++ {
+			lats = append(lats, cost/time.Duration(batch))
+		}
+		n -= batch
+	}
+	return lats, nil
+}
+
+func report(lats []time.Duration, elapsed time.Duration) {
+	if len(lats) == 0 {
+		fmt.Println("no requests were sent")
+		return
+	}
+	sort.Slice(lats, func(i, j int) bool { return lats[i] < lats[j] })
+	var total time.Duration
+	for _, l := range lats {
+		total += l
+	}
+	pct := func(p float64) time.Duration {
+		i := int(float64(len(lats)) * p)
+		if i >= len(lats) {
+			i = len(lats) - 1
+		}
+		return lats[i]
+	}
+	fmt.Printf("requests: %d, clients: %d, pipeline: %d\n", len(lats), clients, pipeline)
+	fmt.Printf("elapsed: %s, throughput: %.0f req/s\n", elapsed, float64(len(lats))/elapsed.Seconds())
+	fmt.Printf("latency avg: %s\n", total/time.Duration(len(lats)))
+	fmt.Printf("latency p50: %s\n", pct(0.50))
+	fmt.Printf("latency p90: %s\n", pct(0.90))
+	fmt.Printf("latency p99: %s\n", pct(0.99))
+	fmt.Printf("latency p999: %s\n", pct(0.999))
+}
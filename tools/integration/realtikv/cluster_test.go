@@ -0,0 +1,84 @@
+//go:build realtikv
+// +build realtikv
+
+package realtikv
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestExpireAcrossNodes lets the expire worker of whichever node holds the
+// leader lease remove a key written through the other node
+func TestExpireAcrossNodes(t *testing.T) {
+	ca := dial(t, nodeA)
+	defer ca.Close()
+	cb := dial(t, nodeB)
+	defer cb.Close()
+
+	_, err := ca.Do("SET", "expire:key", "v")
+	assert.NoError(t, err)
+	_, err = ca.Do("EXPIRE", "expire:key", 1)
+	assert.NoError(t, err)
+
+	deadline := time.Now().Add(30 * time.Second)
+	for time.Now().Before(deadline) {
+		if _, err := redis.String(cb.Do("GET", "expire:key")); err == redis.ErrNil {
+			return
+		}
+		time.Sleep(500 * time.Millisecond)
+	}
+	t.Fatal("expired key still visible on the other node")
+}
+
+// setGlobalLimit writes a limit the way setlimit.sh does, as a string key
+// in the limiter namespace
+func setGlobalLimit(t *testing.T, limiterKey, value string) {
+	txn, err := storeA.DB("$sys.ratelimit", 0).Begin()
+	assert.NoError(t, err)
+	s, err := txn.String([]byte(limiterKey))
+	assert.NoError(t, err)
+	assert.NoError(t, s.Set([]byte(value)))
+	assert.NoError(t, txn.Commit(context.TODO()))
+}
+
+// TestLimiterBalancing configures a global qps limit and hammers both
+// nodes, the summed throughput must stay near the global limit instead of
+// limit-per-node
+func TestLimiterBalancing(t *testing.T) {
+	const (
+		qps     = 50
+		seconds = 4
+	)
+	setGlobalLimit(t, "qps:default@incrby", "50 5")
+	// let startSyncNewLimit pick up the new limit on both nodes
+	time.Sleep(2 * time.Second)
+
+	counts := make(chan int, 2)
+	for _, addr := range []string{nodeA, nodeB} {
+		go func(addr string) {
+			c := dial(t, addr)
+			defer c.Close()
+			n := 0
+			deadline := time.Now().Add(seconds * time.Second)
+			for time.Now().Before(deadline) {
+				if _, err := c.Do("INCRBY", "limit:counter", 1); err != nil {
+					t.Error(err)
+					break
+				}
+				n++
+			}
+			counts <- n
+		}(addr)
+	}
+	total := <-counts + <-counts
+
+	assert.True(t, total > 20, "limited clients should still make progress")
+	// with initial-percent 1 both nodes may start at the full limit, the
+	// balancer converges within a few periods, leave generous headroom
+	assert.True(t, total < qps*seconds*3, "global limit not applied across nodes, total %d", total)
+}
@@ -0,0 +1,95 @@
+//go:build realtikv
+// +build realtikv
+
+package realtikv
+
+import (
+	"flag"
+	"log"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+	"go.uber.org/zap"
+
+	"github.com/distributedio/titan"
+	"github.com/distributedio/titan/conf"
+	"github.com/distributedio/titan/context"
+	"github.com/distributedio/titan/db"
+)
+
+var pdAddrs = flag.String("pd-addrs", defaultPdAddrs(), "pd addrs of the real tikv cluster, e.g. tikv://127.0.0.1:2379")
+
+// the two nodes share one tikv cluster so the tests can exercise
+// cross-node behavior: leader election, expire and limiter balancing
+const (
+	nodeA = "127.0.0.1:27369"
+	nodeB = "127.0.0.1:27370"
+)
+
+var (
+	svrA   *titan.Server
+	svrB   *titan.Server
+	storeA *db.RedisStore
+)
+
+func defaultPdAddrs() string {
+	if addrs := os.Getenv("TITAN_PD_ADDRS"); addrs != "" {
+		return addrs
+	}
+	return "tikv://127.0.0.1:2379"
+}
+
+// startNode opens a store against the real cluster and serves on addr,
+// the mock conf is reused for its short worker intervals
+func startNode(addr string) (*titan.Server, *db.RedisStore) {
+	tikvConf := conf.MockConf().Tikv
+	tikvConf.PdAddrs = *pdAddrs
+	store, err := db.Open(&tikvConf)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	limitersMgr, err := db.NewLimitersMgr(store, &tikvConf.RateLimit)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	svr := titan.New(&context.ServerContext{
+		Store:            store,
+		ListZipThreshold: 100,
+		LimitersMgr:      limitersMgr,
+	})
+	go svr.ListenAndServe(addr)
+	return svr, store
+}
+
+// dial connects to a node and waits until it answers PING
+func dial(t *testing.T, addr string) redis.Conn {
+	var lastErr error
+	for begin := time.Now(); time.Since(begin) < 10*time.Second; time.Sleep(100 * time.Millisecond) {
+		c, err := redis.Dial("tcp", addr)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		if _, err := c.Do("PING"); err != nil {
+			lastErr = err
+			c.Close()
+			continue
+		}
+		return c
+	}
+	t.Fatalf("dial %s: %v", addr, lastErr)
+	return nil
+}
+
+func TestMain(m *testing.M) {
+	flag.Parse()
+	zap.ReplaceGlobals(zap.NewNop())
+	svrA, storeA = startNode(nodeA)
+	svrB, _ = startNode(nodeB)
+	v := m.Run()
+	svrA.Stop()
+	svrB.Stop()
+	os.Exit(v)
+}
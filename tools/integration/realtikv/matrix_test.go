@@ -0,0 +1,119 @@
+//go:build realtikv
+// +build realtikv
+
+package realtikv
+
+import (
+	"testing"
+
+	"github.com/gomodule/redigo/redis"
+	"github.com/stretchr/testify/assert"
+)
+
+type step struct {
+	cmd  string
+	args []interface{}
+	want interface{}
+}
+
+// norm flattens redigo reply types for comparison, bulk strings become
+// string and arrays become []interface{} of normalized elements
+func norm(v interface{}) interface{} {
+	switch r := v.(type) {
+	case []byte:
+		return string(r)
+	case []interface{}:
+		out := make([]interface{}, len(r))
+		for i := range r {
+			out[i] = norm(r[i])
+		}
+		return out
+	default:
+		return v
+	}
+}
+
+func run(t *testing.T, c redis.Conn, steps []step) {
+	for _, s := range steps {
+		reply, err := c.Do(s.cmd, s.args...)
+		assert.NoError(t, err, "%s %v", s.cmd, s.args)
+		assert.Equal(t, s.want, norm(reply), "%s %v", s.cmd, s.args)
+	}
+}
+
+// TestCommandMatrix drives the command table of every data type against a
+// real cluster, the mockstore tests cover the same commands but not the
+// tikv client behavior underneath
+func TestCommandMatrix(t *testing.T) {
+	c := dial(t, nodeA)
+	defer c.Close()
+	c.Do("DEL", "mx:str", "mx:hash", "mx:list", "mx:set", "mx:zset", "mx:bit")
+
+	run(t, c, []step{
+		// strings
+		{"SET", []interface{}{"mx:str", "v1"}, "OK"},
+		{"GET", []interface{}{"mx:str"}, "v1"},
+		{"APPEND", []interface{}{"mx:str", "v2"}, int64(4)},
+		{"STRLEN", []interface{}{"mx:str"}, int64(4)},
+		{"GETSET", []interface{}{"mx:str", "10"}, "v1v2"},
+		{"INCR", []interface{}{"mx:str"}, int64(11)},
+		{"DECRBY", []interface{}{"mx:str", "2"}, int64(9)},
+		{"TYPE", []interface{}{"mx:str"}, "string"},
+		// hashes
+		{"HSET", []interface{}{"mx:hash", "f1", "v1"}, int64(1)},
+		{"HSET", []interface{}{"mx:hash", "f2", "v2"}, int64(1)},
+		{"HGET", []interface{}{"mx:hash", "f1"}, "v1"},
+		{"HLEN", []interface{}{"mx:hash"}, int64(2)},
+		{"HEXISTS", []interface{}{"mx:hash", "f2"}, int64(1)},
+		{"HDEL", []interface{}{"mx:hash", "f2"}, int64(1)},
+		{"HMGET", []interface{}{"mx:hash", "f1", "f2"}, []interface{}{"v1", nil}},
+		// lists
+		{"RPUSH", []interface{}{"mx:list", "a", "b", "c"}, int64(3)},
+		{"LLEN", []interface{}{"mx:list"}, int64(3)},
+		{"LRANGE", []interface{}{"mx:list", 0, -1}, []interface{}{"a", "b", "c"}},
+		{"LPOP", []interface{}{"mx:list"}, "a"},
+		{"LINDEX", []interface{}{"mx:list", 1}, "c"},
+		// sets
+		{"SADD", []interface{}{"mx:set", "m1", "m2"}, int64(2)},
+		{"SCARD", []interface{}{"mx:set"}, int64(2)},
+		{"SISMEMBER", []interface{}{"mx:set", "m1"}, int64(1)},
+		{"SREM", []interface{}{"mx:set", "m2"}, int64(1)},
+		{"SMEMBERS", []interface{}{"mx:set"}, []interface{}{"m1"}},
+		// zsets
+		{"ZADD", []interface{}{"mx:zset", 1, "m1", 2, "m2"}, int64(2)},
+		{"ZCARD", []interface{}{"mx:zset"}, int64(2)},
+		{"ZSCORE", []interface{}{"mx:zset", "m2"}, "2"},
+		{"ZRANGE", []interface{}{"mx:zset", 0, -1}, []interface{}{"m1", "m2"}},
+		{"ZREM", []interface{}{"mx:zset", "m1"}, int64(1)},
+		// bitmaps
+		{"SETBIT", []interface{}{"mx:bit", 7, 1}, int64(0)},
+		{"GETBIT", []interface{}{"mx:bit", 7}, int64(1)},
+		{"BITCOUNT", []interface{}{"mx:bit"}, int64(1)},
+		// keys
+		{"EXISTS", []interface{}{"mx:str"}, int64(1)},
+		{"EXPIRE", []interface{}{"mx:str", 100}, int64(1)},
+		{"PERSIST", []interface{}{"mx:str"}, int64(1)},
+		{"TTL", []interface{}{"mx:str"}, int64(-1)},
+		{"DEL", []interface{}{"mx:str", "mx:hash", "mx:list", "mx:set", "mx:zset", "mx:bit"}, int64(6)},
+	})
+}
+
+// TestCrossNodeVisibility writes through one node and reads through the
+// other, both share the same tikv cluster
+func TestCrossNodeVisibility(t *testing.T) {
+	ca := dial(t, nodeA)
+	defer ca.Close()
+	cb := dial(t, nodeB)
+	defer cb.Close()
+
+	_, err := ca.Do("SET", "cross:key", "written-on-a")
+	assert.NoError(t, err)
+	got, err := redis.String(cb.Do("GET", "cross:key"))
+	assert.NoError(t, err)
+	assert.Equal(t, "written-on-a", got)
+
+	_, err = cb.Do("DEL", "cross:key")
+	assert.NoError(t, err)
+	_, err = redis.String(ca.Do("GET", "cross:key"))
+	assert.Equal(t, redis.ErrNil, err)
+}